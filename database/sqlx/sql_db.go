@@ -40,6 +40,23 @@ func (d *sqlDB) Close() error {
 	}
 }
 
+// Ping 实现health.Pinger,读写分离时同时检查读库和写库
+func (d *sqlDB) Ping(ctx context.Context) error {
+	if d.wdb == nil {
+		return fmt.Errorf("sql: db closed")
+	}
+
+	if err := d.wdb.PingContext(ctx); err != nil {
+		return err
+	}
+
+	if d.rdb != d.wdb {
+		return d.rdb.PingContext(ctx)
+	}
+
+	return nil
+}
+
 func (d *sqlDB) Indexes(ctx context.Context, table string) ([]*Index, error) {
 	return d.dialect.Indexes(ctx, d.wdb, table)
 }
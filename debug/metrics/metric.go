@@ -152,6 +152,7 @@ func (s *metricSet) init(reg Registry, desc *Desc, labelNames []string, creator
 	s.desc = desc
 	s.labelNames = labelNames
 	s.creator = creator
+	s.metrics = make(map[uint64][]Metric)
 }
 
 // getOrCreateByLabels 通过标签查找Metric,如果标签与注册时不一致,则返回空
@@ -0,0 +1,90 @@
+// Package cbor 无第三方依赖的CBOR编解码,格式见RFC 8949
+//	结构体编码为map,字段名优先使用cbor tag,其次使用json tag,支持omitempty
+//	编码仅使用定长格式,map的key按编码后字节序排序(RFC 8949 4.2.1),解码支持不定长格式及半精度浮点数
+//	time.Time使用tag 1,idgen.ID使用TagID,可通过RegisterTag注册其他tag
+package cbor
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/foredata/nova/encoding"
+)
+
+// some error
+var (
+	ErrShortBuffer  = errors.New("cbor: short buffer")
+	ErrDepthLimit   = errors.New("cbor: exceeded max depth")
+	ErrInvalidValue = errors.New("cbor: Unmarshal(non-pointer or nil)")
+	ErrTrailingData = errors.New("cbor: trailing data")
+	ErrMalformed    = errors.New("cbor: malformed data")
+)
+
+// maxDepth 最大嵌套深度,防止恶意数据导致栈溢出
+const maxDepth = 512
+
+const tagName = "cbor"
+
+// UnsupportedTypeError 不支持编码的类型
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "cbor: unsupported type " + e.Type.String()
+}
+
+// TypeError 数据类型与目标类型不匹配
+type TypeError struct {
+	Major byte         // 数据主类型
+	Type  reflect.Type // 目标类型
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("cbor: cannot unmarshal major type %d into %s", e.Major, e.Type)
+}
+
+// Marshal 编码
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+
+	return e.buf, nil
+}
+
+// Unmarshal 解码,v必须为非nil指针
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrInvalidValue
+	}
+
+	d := &decoder{data: data}
+	if err := d.decode(rv.Elem(), 0); err != nil {
+		return err
+	}
+	if d.off != len(d.data) {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// NewCodec 创建encoding.Codec
+func NewCodec() encoding.Codec {
+	return &cborCodec{}
+}
+
+type cborCodec struct {
+}
+
+func (c *cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return Marshal(v)
+}
+
+func (c *cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return Unmarshal(data, v)
+}
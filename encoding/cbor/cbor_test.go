@@ -0,0 +1,240 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/idgen"
+)
+
+type testUser struct {
+	ID    idgen.ID         `cbor:"id"`
+	Name  string           `cbor:"name"`
+	Age   uint8            `json:"age,omitempty"`
+	Score float32          `cbor:"score"`
+	Tags  []string         `cbor:"tags"`
+	Attrs map[string]int64 `cbor:"attrs"`
+	Hash  [2]byte          `cbor:"hash"`
+	Ctime time.Time        `cbor:"ctime"`
+	Mtime *time.Time       `cbor:"mtime"`
+	Next  *testUser        `cbor:"next"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	u := &testUser{
+		ID:    idgen.ID(1) << 40,
+		Name:  "nova",
+		Score: 0.5,
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]int64{"x": -1000, "y": 1 << 40},
+		Hash:  [2]byte{0xab, 0xcd},
+		Ctime: now,
+		Mtime: &now,
+		Next:  &testUser{Name: "next", Ctime: now},
+	}
+
+	data, err := Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &testUser{}
+	if err := Unmarshal(data, out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(u, out) {
+		t.Errorf("not equal, %+v", out)
+	}
+
+	var m map[string]interface{}
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["age"]; ok {
+		t.Errorf("age should omit")
+	}
+	if m["id"] != u.ID || !m["ctime"].(time.Time).Equal(now) || m["score"] != 0.5 {
+		t.Errorf("bad map, %+v", m)
+	}
+}
+
+// 测试数据来自RFC 8949 Appendix A
+func TestVectors(t *testing.T) {
+	tests := []struct {
+		hex string
+		v   interface{}
+	}{
+		{"00", int64(0)},
+		{"17", int64(23)},
+		{"1818", int64(24)},
+		{"1903e8", int64(1000)},
+		{"1bffffffffffffffff", uint64(math.MaxUint64)},
+		{"20", int64(-1)},
+		{"3903e7", int64(-1000)},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"6449455446", "IETF"},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"a26161016162820203", map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"fb3ff199999999999a", 1.1},
+		{"a56161614161626142616361436164614461656145", map[string]interface{}{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}},
+	}
+
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		enc, err := Marshal(tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(enc, data) {
+			t.Errorf("encode %v, got %x, want %s", tt.v, enc, tt.hex)
+		}
+
+		var v interface{}
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatalf("decode %s, %v", tt.hex, err)
+		}
+		if !reflect.DeepEqual(v, tt.v) {
+			t.Errorf("decode %s, got %#v", tt.hex, v)
+		}
+	}
+
+	// 仅解码: 半精度浮点数,不定长格式,tag
+	decodes := []struct {
+		hex string
+		v   interface{}
+	}{
+		{"f93c00", 1.0},
+		{"f9c400", -4.0},
+		{"f97bff", 65504.0},
+		{"f90001", 5.960464477539063e-8},
+		{"f97c00", math.Inf(1)},
+		{"fa47c35000", 100000.0},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9f018202039f0405ffff", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"c11a514b67b0", time.Unix(1363896240, 0)},
+		{"c1fb41d452d9ec200000", time.Unix(1363896240, 500000000)},
+		{"d74401020304", &Tag{Number: 23, Content: []byte{1, 2, 3, 4}}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+	}
+	for _, tt := range decodes {
+		data, _ := hex.DecodeString(tt.hex)
+		var v interface{}
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatalf("decode %s, %v", tt.hex, err)
+		}
+		if tm, ok := tt.v.(time.Time); ok {
+			if !tm.Equal(v.(time.Time)) {
+				t.Errorf("decode %s, got %v", tt.hex, v)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.v) {
+			t.Errorf("decode %s, got %#v", tt.hex, v)
+		}
+	}
+}
+
+func TestTypedDecode(t *testing.T) {
+	// 不定长map解码到结构体,忽略未知字段
+	data, _ := hex.DecodeString("bf646e616d65646e6f7661657874726173a1616101ff")
+	out := struct {
+		Name string `cbor:"name"`
+	}{}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "nova" {
+		t.Errorf("bad name, %s", out.Name)
+	}
+
+	// 未注册的tag直接解码内容
+	var s string
+	data, _ = hex.DecodeString("d82063616263")
+	if err := Unmarshal(data, &s); err != nil || s != "abc" {
+		t.Errorf("bad tag content, %v %s", err, s)
+	}
+
+	var f float32
+	data, _ = hex.DecodeString("f93e00")
+	if err := Unmarshal(data, &f); err != nil || f != 1.5 {
+		t.Errorf("bad float16, %v %v", err, f)
+	}
+
+	var i8 int8
+	data, _ = Marshal(1000)
+	if _, ok := Unmarshal(data, &i8).(*TypeError); !ok {
+		t.Errorf("expect overflow error")
+	}
+	var u uint
+	data, _ = Marshal(-1)
+	if _, ok := Unmarshal(data, &u).(*TypeError); !ok {
+		t.Errorf("expect type error")
+	}
+}
+
+func TestError(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{"a": "hello", "b": []int{1, 2, 3}})
+	var v interface{}
+	for i := 0; i < len(data); i++ {
+		if err := Unmarshal(data[:i], &v); err == nil {
+			t.Errorf("expect error for truncated data, %d", i)
+		}
+	}
+
+	if err := Unmarshal(append(data, 0x01), &v); err != ErrTrailingData {
+		t.Errorf("expect trailing error, %v", err)
+	}
+	if err := Unmarshal(data, v); err != ErrInvalidValue {
+		t.Errorf("expect invalid value, %v", err)
+	}
+
+	malformed := []string{
+		"1c",       // 保留的附加信息
+		"ff",       // 容器外的break
+		"1f",       // 整数不支持不定长
+		"5f6161ff", // 不定长bytes中的text分段
+		"9b00000000ffffffff",
+	}
+	for _, x := range malformed {
+		data, _ := hex.DecodeString(x)
+		if err := Unmarshal(data, &v); err == nil {
+			t.Errorf("expect error, %s", x)
+		}
+	}
+
+	deep := bytes.Repeat([]byte{0x81}, maxDepth+10)
+	deep = append(deep, 0xf6)
+	if err := Unmarshal(deep, &v); err != ErrDepthLimit {
+		t.Errorf("expect depth limit, %v", err)
+	}
+
+	if _, err := Marshal(make(chan int)); err == nil {
+		t.Errorf("expect unsupported type")
+	}
+}
+
+func TestCodec(t *testing.T) {
+	c := NewCodec()
+	data, err := c.Marshal([]int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []int
+	if err := c.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, []int{1, 2}) {
+		t.Errorf("bad value, %+v", out)
+	}
+}
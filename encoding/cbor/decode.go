@@ -0,0 +1,706 @@
+package cbor
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+
+	"github.com/foredata/nova/encoding/internal/structs"
+)
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// head 数据项头部,indefinite为true时arg无意义
+type head struct {
+	major      byte
+	info       byte
+	arg        uint64
+	indefinite bool
+}
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+func (d *decoder) readN(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, ErrShortBuffer
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// isBreak 判断下一个字节是否为不定长结束标识,是则跳过
+func (d *decoder) isBreak() (bool, error) {
+	if d.off >= len(d.data) {
+		return false, ErrShortBuffer
+	}
+	if d.data[d.off] == simpleBreak {
+		d.off++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (d *decoder) readHead() (h head, err error) {
+	if d.off >= len(d.data) {
+		return h, ErrShortBuffer
+	}
+	c := d.data[d.off]
+	d.off++
+	h.major, h.info = c>>5, c&0x1f
+
+	switch {
+	case h.info < 24:
+		h.arg = uint64(h.info)
+	case h.info <= 27:
+		b, err := d.readN(1 << (h.info - 24))
+		if err != nil {
+			return h, err
+		}
+		switch len(b) {
+		case 1:
+			h.arg = uint64(b[0])
+		case 2:
+			h.arg = uint64(binary.BigEndian.Uint16(b))
+		case 4:
+			h.arg = uint64(binary.BigEndian.Uint32(b))
+		default:
+			h.arg = binary.BigEndian.Uint64(b)
+		}
+	case h.info == infoIndefinite:
+		// 仅字符串,数组,map支持不定长,break单独处理
+		if h.major < majorBytes || h.major > majorMap {
+			return h, ErrMalformed
+		}
+		h.indefinite = true
+	default:
+		return h, ErrMalformed
+	}
+
+	return h, nil
+}
+
+// checkLen 校验长度,min为每个元素最少占用的字节数
+func (d *decoder) checkLen(n uint64, min uint64) (int, error) {
+	if n > uint64(len(d.data)-d.off)/min {
+		return 0, ErrShortBuffer
+	}
+
+	return int(n), nil
+}
+
+// readString 读取bytes或text,不定长时合并所有分段
+func (d *decoder) readString(h head) ([]byte, error) {
+	if !h.indefinite {
+		return d.readN(h.arg)
+	}
+
+	var res []byte
+	for {
+		end, err := d.isBreak()
+		if err != nil {
+			return nil, err
+		}
+		if end {
+			break
+		}
+		ch, err := d.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if ch.major != h.major || ch.indefinite {
+			return nil, ErrMalformed
+		}
+		b, err := d.readN(ch.arg)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b...)
+	}
+	if res == nil {
+		res = []byte{}
+	}
+
+	return res, nil
+}
+
+// readFloat 解析主类型7中的浮点数
+func readFloat(h head) (float64, bool) {
+	switch h.info {
+	case 25:
+		return float16(uint16(h.arg)), true
+	case 26:
+		return float64(math.Float32frombits(uint32(h.arg))), true
+	case 27:
+		return math.Float64frombits(h.arg), true
+	}
+
+	return 0, false
+}
+
+// float16 半精度浮点数转换,见RFC 8949 Appendix D
+func float16(v uint16) float64 {
+	exp := int(v>>10) & 0x1f
+	mant := float64(v & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if v&0x8000 != 0 {
+		f = -f
+	}
+
+	return f
+}
+
+func isNull(h head) bool {
+	return h.major == majorSimple && (h.info == 22 || h.info == 23)
+}
+
+func (d *decoder) decode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrDepthLimit
+	}
+
+	start := d.off
+	h, err := d.readHead()
+	if err != nil {
+		return err
+	}
+
+	if isNull(h) {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		d.off = start
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem(), depth+1)
+	}
+
+	if h.major == majorTag {
+		return d.decodeTag(h, v, depth)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		// 非空interface只能解码到已有的指针中
+		d.off = start
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr && !v.Elem().IsNil() {
+			return d.decode(v.Elem(), depth+1)
+		}
+		if v.NumMethod() != 0 {
+			return &TypeError{Major: h.major, Type: v.Type()}
+		}
+		x, err := d.decodeAny(depth)
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	}
+
+	mismatch := &TypeError{Major: h.major, Type: v.Type()}
+	switch v.Kind() {
+	case reflect.Bool:
+		if h.major != majorSimple || (h.info != 20 && h.info != 21) {
+			return mismatch
+		}
+		v.SetBool(h.info == 21)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if (h.major != majorUint && h.major != majorNegInt) || h.arg > math.MaxInt64 {
+			return mismatch
+		}
+		x := int64(h.arg)
+		if h.major == majorNegInt {
+			x = -1 - x
+		}
+		if v.OverflowInt(x) {
+			return mismatch
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if h.major != majorUint || v.OverflowUint(h.arg) {
+			return mismatch
+		}
+		v.SetUint(h.arg)
+	case reflect.Float32, reflect.Float64:
+		switch h.major {
+		case majorUint:
+			v.SetFloat(float64(h.arg))
+		case majorNegInt:
+			v.SetFloat(-1 - float64(h.arg))
+		case majorSimple:
+			f, ok := readFloat(h)
+			if !ok {
+				return mismatch
+			}
+			v.SetFloat(f)
+		default:
+			return mismatch
+		}
+	case reflect.String:
+		if h.major != majorText && h.major != majorBytes {
+			return mismatch
+		}
+		b, err := d.readString(h)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (h.major == majorBytes || h.major == majorText) {
+			b, err := d.readString(h)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		if h.major != majorArray {
+			return mismatch
+		}
+		return d.decodeSlice(h, v, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (h.major == majorBytes || h.major == majorText) {
+			b, err := d.readString(h)
+			if err != nil {
+				return err
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			for i := len(b); i < v.Len(); i++ {
+				v.Index(i).SetUint(0)
+			}
+			return nil
+		}
+		if h.major != majorArray {
+			return mismatch
+		}
+		return d.decodeArray(h, v, depth)
+	case reflect.Map:
+		if h.major != majorMap {
+			return mismatch
+		}
+		return d.decodeMap(h, v, depth)
+	case reflect.Struct:
+		if h.major != majorMap {
+			return mismatch
+		}
+		return d.decodeStruct(h, v, depth)
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return nil
+}
+
+// decodeTag 目标类型已注册时使用注册的解码函数,否则直接解码tag的内容
+func (d *decoder) decodeTag(h head, v reflect.Value, depth int) error {
+	tag, ok := gTagNumbers[h.arg]
+	if !ok || tag.typ != v.Type() {
+		if _, registered := gTagTypes[v.Type()]; registered {
+			return &TypeError{Major: h.major, Type: v.Type()}
+		}
+		if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+			x, err := d.decodeTagged(h, depth)
+			if err != nil {
+				return err
+			}
+			if x != nil {
+				v.Set(reflect.ValueOf(x))
+			}
+			return nil
+		}
+		return d.decode(v, depth+1)
+	}
+
+	content, err := d.decodeAny(depth + 1)
+	if err != nil {
+		return err
+	}
+	x, err := tag.unmarshal(content)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(x))
+	return nil
+}
+
+// containerLen 返回数组或map的长度,不定长时返回-1
+func (d *decoder) containerLen(h head) (int, error) {
+	if h.indefinite {
+		return -1, nil
+	}
+	if h.major == majorMap {
+		return d.checkLen(h.arg, 2)
+	}
+
+	return d.checkLen(h.arg, 1)
+}
+
+// next 判断容器中是否还有下一个元素
+func (d *decoder) next(n int, i int) (bool, error) {
+	if n >= 0 {
+		return i < n, nil
+	}
+
+	end, err := d.isBreak()
+	return !end, err
+}
+
+func (d *decoder) decodeSlice(h head, v reflect.Value, depth int) error {
+	n, err := d.containerLen(h)
+	if err != nil {
+		return err
+	}
+
+	t := v.Type()
+	s := reflect.MakeSlice(t, 0, maxInt(n, 0))
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		s = reflect.Append(s, reflect.Zero(t.Elem()))
+		if err := d.decode(s.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	v.Set(s)
+
+	return nil
+}
+
+func (d *decoder) decodeArray(h head, v reflect.Value, depth int) error {
+	n, err := d.containerLen(h)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for ; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if i >= v.Len() {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	for ; i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+	}
+
+	return nil
+}
+
+func (d *decoder) decodeMap(h head, v reflect.Value, depth int) error {
+	n, err := d.containerLen(h)
+	if err != nil {
+		return err
+	}
+
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, maxInt(n, 0)))
+	}
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		key := reflect.New(t.Key()).Elem()
+		if err := d.decode(key, depth+1); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.decode(elem, depth+1); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+
+	return nil
+}
+
+// decodeStruct 从map中解码,未知字段会被忽略
+func (d *decoder) decodeStruct(h head, v reflect.Value, depth int) error {
+	n, err := d.containerLen(h)
+	if err != nil {
+		return err
+	}
+
+	info := structs.Get(v.Type(), tagName)
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		start := d.off
+		kh, err := d.readHead()
+		if err != nil {
+			return err
+		}
+		var f *structs.Field
+		if kh.major == majorText || kh.major == majorBytes {
+			key, err := d.readString(kh)
+			if err != nil {
+				return err
+			}
+			f = info.Lookup(string(key))
+		} else {
+			// 非字符串key
+			d.off = start
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+
+		var fv reflect.Value
+		if f != nil {
+			fv, _ = structs.FieldByIndex(v, f.Index, true)
+		}
+		if !fv.IsValid() || !fv.CanSet() {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(fv, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeAny 解码到interface{},整数为int64(超出范围时为uint64),浮点数为float64
+//	map的key均为字符串时返回map[string]interface{},否则返回map[interface{}]interface{}
+func (d *decoder) decodeAny(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, ErrDepthLimit
+	}
+
+	h, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.major {
+	case majorUint:
+		if h.arg > math.MaxInt64 {
+			return h.arg, nil
+		}
+		return int64(h.arg), nil
+	case majorNegInt:
+		if h.arg > math.MaxInt64 {
+			return nil, &TypeError{Major: h.major, Type: anyType}
+		}
+		return -1 - int64(h.arg), nil
+	case majorBytes:
+		b, err := d.readString(h)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	case majorText:
+		b, err := d.readString(h)
+		return string(b), err
+	case majorArray:
+		n, err := d.containerLen(h)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, 0, maxInt(n, 0))
+		for i := 0; ; i++ {
+			ok, err := d.next(n, i)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			x, err := d.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, x)
+		}
+		return res, nil
+	case majorMap:
+		return d.decodeAnyMap(h, depth)
+	case majorTag:
+		return d.decodeTagged(h, depth)
+	}
+
+	switch {
+	case h.info == 20:
+		return false, nil
+	case h.info == 21:
+		return true, nil
+	case isNull(h):
+		return nil, nil
+	}
+	if f, ok := readFloat(h); ok {
+		return f, nil
+	}
+
+	return nil, ErrMalformed
+}
+
+// decodeTagged 解码tag内容,未注册的tag返回*Tag
+func (d *decoder) decodeTagged(h head, depth int) (interface{}, error) {
+	content, err := d.decodeAny(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if tag, ok := gTagNumbers[h.arg]; ok {
+		return tag.unmarshal(content)
+	}
+
+	return &Tag{Number: h.arg, Content: content}, nil
+}
+
+func (d *decoder) decodeAnyMap(h head, depth int) (interface{}, error) {
+	n, err := d.containerLen(h)
+	if err != nil {
+		return nil, err
+	}
+
+	strMap := make(map[string]interface{}, maxInt(n, 0))
+	var anyMap map[interface{}]interface{}
+	for i := 0; ; i++ {
+		ok, err := d.next(n, i)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		key, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if s, ok := key.(string); ok && anyMap == nil {
+			strMap[s] = value
+			continue
+		}
+		if anyMap == nil {
+			anyMap = make(map[interface{}]interface{}, maxInt(n, 0))
+			for k, v := range strMap {
+				anyMap[k] = v
+			}
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			return nil, &TypeError{Major: h.major, Type: reflect.TypeOf(anyMap)}
+		}
+		anyMap[key] = value
+	}
+
+	if anyMap != nil {
+		return anyMap, nil
+	}
+	return strMap, nil
+}
+
+// skip 跳过一个完整的数据项
+func (d *decoder) skip(depth int) error {
+	if depth > maxDepth {
+		return ErrDepthLimit
+	}
+
+	h, err := d.readHead()
+	if err != nil {
+		return err
+	}
+
+	switch h.major {
+	case majorBytes, majorText:
+		_, err := d.readString(h)
+		return err
+	case majorArray, majorMap:
+		n, err := d.containerLen(h)
+		if err != nil {
+			return err
+		}
+		if n >= 0 && h.major == majorMap {
+			n *= 2
+		}
+		for i := 0; ; i++ {
+			ok, err := d.next(n, i)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				break
+			}
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			if n < 0 && h.major == majorMap {
+				if err := d.skip(depth + 1); err != nil {
+					return err
+				}
+			}
+		}
+	case majorTag:
+		return d.skip(depth + 1)
+	}
+
+	return nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
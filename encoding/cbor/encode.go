@@ -0,0 +1,249 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/foredata/nova/encoding/internal/structs"
+)
+
+// 主类型
+const (
+	majorUint   byte = 0
+	majorNegInt byte = 1
+	majorBytes  byte = 2
+	majorText   byte = 3
+	majorArray  byte = 4
+	majorMap    byte = 5
+	majorTag    byte = 6
+	majorSimple byte = 7
+)
+
+// 主类型7中的简单值及浮点数
+const (
+	simpleFalse  = 0xf4
+	simpleTrue   = 0xf5
+	simpleNull   = 0xf6
+	simpleUndef  = 0xf7
+	simpleFloat  = 0xf9 // 半精度
+	simpleFloat4 = 0xfa
+	simpleFloat8 = 0xfb
+	simpleBreak  = 0xff
+)
+
+// infoIndefinite 不定长标识
+const infoIndefinite = 31
+
+type encoder struct {
+	buf []byte
+}
+
+// writeHead 写入主类型及参数,选择最短格式
+func (e *encoder) writeHead(major byte, v uint64) {
+	m := major << 5
+	switch {
+	case v < 24:
+		e.buf = append(e.buf, m|byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, m|24, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = append(e.buf, m|25, 0, 0)
+		binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = append(e.buf, m|26, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(v))
+	default:
+		e.buf = append(e.buf, m|27, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], v)
+	}
+}
+
+func (e *encoder) writeInt(v int64) {
+	if v >= 0 {
+		e.writeHead(majorUint, uint64(v))
+	} else {
+		e.writeHead(majorNegInt, uint64(-1-v))
+	}
+}
+
+func (e *encoder) writeFloat32(v float32) {
+	e.buf = append(e.buf, simpleFloat4, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], math.Float32bits(v))
+}
+
+func (e *encoder) writeFloat64(v float64) {
+	e.buf = append(e.buf, simpleFloat8, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], math.Float64bits(v))
+}
+
+func (e *encoder) writeString(s string) {
+	e.writeHead(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) writeBytes(b []byte) {
+	e.writeHead(majorBytes, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) encode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrDepthLimit
+	}
+
+	if !v.IsValid() {
+		e.buf = append(e.buf, simpleNull)
+		return nil
+	}
+
+	if tag, ok := gTagTypes[v.Type()]; ok {
+		content, err := tag.marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		e.writeHead(majorTag, tag.number)
+		return e.encode(reflect.ValueOf(content), depth+1)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, simpleTrue)
+		} else {
+			e.buf = append(e.buf, simpleFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeHead(majorUint, v.Uint())
+	case reflect.Float32:
+		e.writeFloat32(float32(v.Float()))
+	case reflect.Float64:
+		e.writeFloat64(v.Float())
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, simpleNull)
+			return nil
+		}
+		return e.encode(v.Elem(), depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, simpleNull)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			e.writeBytes(b)
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, simpleNull)
+			return nil
+		}
+		return e.encodeMap(v, depth)
+	case reflect.Struct:
+		return e.encodeStruct(v, depth)
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return nil
+}
+
+// encodeMap 按RFC 8949 4.2.1的确定性编码要求,key按编码后的字节序排序
+func (e *encoder) encodeMap(v reflect.Value, depth int) error {
+	e.writeHead(majorMap, uint64(v.Len()))
+	// 先依次编码到buf,记录每个kv的位置,排序后再整体回写
+	type entry struct {
+		key, kv []byte
+	}
+	start := len(e.buf)
+	bounds := make([]int, 0, v.Len()*2)
+	iter := v.MapRange()
+	for iter.Next() {
+		if err := e.encode(iter.Key(), depth+1); err != nil {
+			return err
+		}
+		bounds = append(bounds, len(e.buf))
+		if err := e.encode(iter.Value(), depth+1); err != nil {
+			return err
+		}
+		bounds = append(bounds, len(e.buf))
+	}
+	if len(bounds) <= 2 {
+		return nil
+	}
+
+	entries := make([]entry, 0, len(bounds)/2)
+	data := append([]byte(nil), e.buf[start:]...)
+	off := 0
+	for i := 0; i < len(bounds); i += 2 {
+		keyEnd, end := bounds[i]-start, bounds[i+1]-start
+		entries = append(entries, entry{key: data[off:keyEnd], kv: data[off:end]})
+		off = end
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	e.buf = e.buf[:start]
+	for _, x := range entries {
+		e.buf = append(e.buf, x.kv...)
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeArray(v reflect.Value, depth int) error {
+	n := v.Len()
+	e.writeHead(majorArray, uint64(n))
+	for i := 0; i < n; i++ {
+		if err := e.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeStruct 编码为map,key为字段名
+func (e *encoder) encodeStruct(v reflect.Value, depth int) error {
+	info := structs.Get(v.Type(), tagName)
+	fields := make([]reflect.Value, len(info.Fields))
+	count := 0
+	for i, f := range info.Fields {
+		fv, ok := structs.FieldByIndex(v, f.Index, false)
+		if !ok || (f.OmitEmpty && structs.IsEmpty(fv)) {
+			continue
+		}
+		fields[i] = fv
+		count++
+	}
+
+	e.writeHead(majorMap, uint64(count))
+	for i, f := range info.Fields {
+		if !fields[i].IsValid() {
+			continue
+		}
+		e.writeString(f.Name)
+		if err := e.encode(fields[i], depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package cbor
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/foredata/nova/idgen"
+)
+
+// 内置tag
+const (
+	TagDateTime uint64 = 0      // RFC3339字符串,仅用于解码
+	TagEpoch    uint64 = 1      // unix时间戳,整数或浮点数
+	TagID       uint64 = 0x4e56 // idgen.ID,内容为整数
+)
+
+var errInvalidTag = errors.New("cbor: invalid tag content")
+
+// Tag 未注册的tag,解码到interface{}时返回
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// tagType tag编解码,marshal返回tag的内容,unmarshal的参数为解码到interface{}后的内容
+type tagType struct {
+	number    uint64
+	typ       reflect.Type
+	marshal   func(v interface{}) (interface{}, error)
+	unmarshal func(content interface{}) (interface{}, error)
+}
+
+var (
+	gTagTypes   = make(map[reflect.Type]*tagType)
+	gTagNumbers = make(map[uint64]*tagType)
+)
+
+// RegisterTag 注册tag,value为该类型的任意值,unmarshal返回值需为该类型
+//	非线程安全,通常仅在init中注册
+func RegisterTag(number uint64, value interface{}, marshal func(v interface{}) (interface{}, error), unmarshal func(content interface{}) (interface{}, error)) {
+	t := &tagType{number: number, typ: reflect.TypeOf(value), marshal: marshal, unmarshal: unmarshal}
+	gTagTypes[t.typ] = t
+	gTagNumbers[number] = t
+}
+
+func init() {
+	RegisterTag(TagEpoch, time.Time{}, marshalTime, unmarshalTime)
+	RegisterTag(TagID, idgen.ID(0), marshalID, unmarshalID)
+	// 仅用于解码
+	gTagNumbers[TagDateTime] = &tagType{number: TagDateTime, typ: reflect.TypeOf(time.Time{}), unmarshal: unmarshalDateTime}
+}
+
+// marshalTime 无纳秒时编码为整数,否则编码为浮点数
+func marshalTime(v interface{}) (interface{}, error) {
+	t := v.(time.Time)
+	if t.Nanosecond() == 0 {
+		return t.Unix(), nil
+	}
+
+	return float64(t.UnixNano()) / float64(time.Second), nil
+}
+
+func unmarshalTime(content interface{}) (interface{}, error) {
+	switch x := content.(type) {
+	case int64:
+		return time.Unix(x, 0), nil
+	case uint64:
+		if x > math.MaxInt64 {
+			return nil, errInvalidTag
+		}
+		return time.Unix(int64(x), 0), nil
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, errInvalidTag
+		}
+		sec, frac := math.Modf(x)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	}
+
+	return nil, errInvalidTag
+}
+
+func unmarshalDateTime(content interface{}) (interface{}, error) {
+	s, ok := content.(string)
+	if !ok {
+		return nil, errInvalidTag
+	}
+
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func marshalID(v interface{}) (interface{}, error) {
+	return int64(v.(idgen.ID)), nil
+}
+
+func unmarshalID(content interface{}) (interface{}, error) {
+	switch x := content.(type) {
+	case int64:
+		return idgen.ID(x), nil
+	case uint64:
+		return idgen.ID(x), nil
+	}
+
+	return nil, errInvalidTag
+}
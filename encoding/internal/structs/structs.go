@@ -0,0 +1,215 @@
+// Package structs 缓存结构体字段信息,供msgpack,cbor等编解码使用
+//	字段名优先使用指定的tag,其次使用json tag,都没有时使用字段名
+//	匿名结构体字段在没有tag时会被展开,规则与encoding/json一致
+package structs
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Field 字段信息
+type Field struct {
+	Name      string       // 编码后的字段名
+	Index     []int        // 字段路径,匿名字段展开后会有多级
+	Type      reflect.Type //
+	OmitEmpty bool         // 零值时忽略
+}
+
+// Struct 结构体信息
+type Struct struct {
+	Fields []*Field          // 按定义顺序排列
+	names  map[string]*Field // 精确匹配
+	folds  map[string]*Field // 忽略大小写匹配
+}
+
+// Lookup 根据名字查找字段,优先精确匹配
+func (s *Struct) Lookup(name string) *Field {
+	if f, ok := s.names[name]; ok {
+		return f
+	}
+
+	return s.folds[strings.ToLower(name)]
+}
+
+type cacheKey struct {
+	typ reflect.Type
+	tag string
+}
+
+var gCache sync.Map // cacheKey -> *Struct
+
+// Get 获取结构体字段信息,t必须为struct类型
+func Get(t reflect.Type, tag string) *Struct {
+	key := cacheKey{typ: t, tag: tag}
+	if v, ok := gCache.Load(key); ok {
+		return v.(*Struct)
+	}
+
+	s := parse(t, tag)
+	v, _ := gCache.LoadOrStore(key, s)
+	return v.(*Struct)
+}
+
+// candidate 解析过程中的字段,depth用于处理匿名字段中的同名字段
+type candidate struct {
+	*Field
+	depth  int
+	tagged bool
+}
+
+func parse(t reflect.Type, tag string) *Struct {
+	var fields []*candidate
+	visited := map[reflect.Type]bool{t: true}
+	collect(t, tag, nil, 0, visited, &fields)
+
+	// 同名字段,深度浅的优先,同深度时有tag的优先,否则都忽略
+	byName := make(map[string][]*candidate)
+	for _, f := range fields {
+		byName[f.Name] = append(byName[f.Name], f)
+	}
+
+	s := &Struct{names: make(map[string]*Field), folds: make(map[string]*Field)}
+	for _, f := range fields {
+		if !dominant(f, byName[f.Name]) {
+			continue
+		}
+		s.Fields = append(s.Fields, f.Field)
+		s.names[f.Name] = f.Field
+		if lower := strings.ToLower(f.Name); s.folds[lower] == nil {
+			s.folds[lower] = f.Field
+		}
+	}
+
+	return s
+}
+
+func dominant(f *candidate, list []*candidate) bool {
+	for _, o := range list {
+		if o == f {
+			continue
+		}
+		if o.depth < f.depth || (o.depth == f.depth && (o.tagged || !f.tagged)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func collect(t reflect.Type, tag string, index []int, depth int, visited map[reflect.Type]bool, out *[]*candidate) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, opts, tagged := lookupTag(sf, tag)
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		ft := sf.Type
+		if sf.Anonymous && !tagged {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !visited[ft] {
+					visited[ft] = true
+					collect(ft, tag, idx, depth+1, visited, out)
+				}
+				continue
+			}
+		}
+
+		if sf.PkgPath != "" {
+			// 非导出字段
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		*out = append(*out, &candidate{
+			Field:  &Field{Name: name, Index: idx, Type: sf.Type, OmitEmpty: hasOption(opts, "omitempty")},
+			depth:  depth,
+			tagged: tagged,
+		})
+	}
+}
+
+// lookupTag 返回tag中的名字及选项
+func lookupTag(sf reflect.StructField, tag string) (name string, opts string, ok bool) {
+	v, ok := sf.Tag.Lookup(tag)
+	if !ok {
+		v, ok = sf.Tag.Lookup("json")
+	}
+	if !ok {
+		return "", "", false
+	}
+
+	if idx := strings.IndexByte(v, ','); idx != -1 {
+		return v[:idx], v[idx+1:], true
+	}
+
+	return v, "", true
+}
+
+func hasOption(opts string, name string) bool {
+	for opts != "" {
+		var opt string
+		if idx := strings.IndexByte(opts, ','); idx != -1 {
+			opt, opts = opts[:idx], opts[idx+1:]
+		} else {
+			opt, opts = opts, ""
+		}
+		if opt == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FieldByIndex 获取字段值,alloc为true时会为nil的匿名指针分配内存
+//	alloc为false且路径上存在nil指针时返回false
+func FieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// IsEmpty 判断是否为零值,用于omitempty
+func IsEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		return v.IsZero()
+	}
+
+	return false
+}
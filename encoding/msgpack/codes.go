@@ -0,0 +1,69 @@
+package msgpack
+
+// 格式标识
+const (
+	posFixintMax   = 0x7f
+	fixmapPrefix   = 0x80
+	fixmapMax      = 0x0f
+	fixarrayPrefix = 0x90
+	fixarrayMax    = 0x0f
+	fixstrPrefix   = 0xa0
+	fixstrMax      = 0x1f
+	negFixintMin   = 0xe0
+
+	codeNil      = 0xc0
+	codeFalse    = 0xc2
+	codeTrue     = 0xc3
+	codeBin8     = 0xc4
+	codeBin16    = 0xc5
+	codeBin32    = 0xc6
+	codeExt8     = 0xc7
+	codeExt16    = 0xc8
+	codeExt32    = 0xc9
+	codeFloat32  = 0xca
+	codeFloat64  = 0xcb
+	codeUint8    = 0xcc
+	codeUint16   = 0xcd
+	codeUint32   = 0xce
+	codeUint64   = 0xcf
+	codeInt8     = 0xd0
+	codeInt16    = 0xd1
+	codeInt32    = 0xd2
+	codeInt64    = 0xd3
+	codeFixExt1  = 0xd4
+	codeFixExt2  = 0xd5
+	codeFixExt4  = 0xd6
+	codeFixExt8  = 0xd7
+	codeFixExt16 = 0xd8
+	codeStr8     = 0xd9
+	codeStr16    = 0xda
+	codeStr32    = 0xdb
+	codeArray16  = 0xdc
+	codeArray32  = 0xdd
+	codeMap16    = 0xde
+	codeMap32    = 0xdf
+)
+
+func isFixmap(c byte) bool {
+	return c&0xf0 == fixmapPrefix
+}
+
+func isFixarray(c byte) bool {
+	return c&0xf0 == fixarrayPrefix
+}
+
+func isFixstr(c byte) bool {
+	return c&0xe0 == fixstrPrefix
+}
+
+func isString(c byte) bool {
+	return isFixstr(c) || c == codeStr8 || c == codeStr16 || c == codeStr32
+}
+
+func isBinary(c byte) bool {
+	return c == codeBin8 || c == codeBin16 || c == codeBin32
+}
+
+func isExt(c byte) bool {
+	return (c >= codeFixExt1 && c <= codeFixExt16) || c == codeExt8 || c == codeExt16 || c == codeExt32
+}
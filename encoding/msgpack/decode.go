@@ -0,0 +1,643 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+
+	"github.com/foredata/nova/encoding/internal/structs"
+)
+
+var (
+	bytesType = reflect.TypeOf([]byte(nil))
+	anyType   = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+type decoder struct {
+	data []byte
+	off  int
+}
+
+func (d *decoder) peek() (byte, error) {
+	if d.off >= len(d.data) {
+		return 0, ErrShortBuffer
+	}
+
+	return d.data[d.off], nil
+}
+
+func (d *decoder) readByte() (byte, error) {
+	if d.off >= len(d.data) {
+		return 0, ErrShortBuffer
+	}
+	c := d.data[d.off]
+	d.off++
+	return c, nil
+}
+
+func (d *decoder) readN(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.off < n {
+		return nil, ErrShortBuffer
+	}
+	b := d.data[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+func (d *decoder) readUint(size int) (uint64, error) {
+	b, err := d.readN(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// readLen 读取长度,并校验剩余数据是否足够,min为每个元素最少占用的字节数
+func (d *decoder) readLen(size int, min int) (int, error) {
+	n, err := d.readUint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.off)/uint64(min) {
+		return 0, ErrShortBuffer
+	}
+
+	return int(n), nil
+}
+
+// readInt 读取整数,unsigned表示数值超过int64范围
+func (d *decoder) readInt(c byte) (v int64, u uint64, unsigned bool, err error) {
+	switch {
+	case c <= posFixintMax:
+		return int64(c), 0, false, nil
+	case c >= negFixintMin:
+		return int64(int8(c)), 0, false, nil
+	}
+
+	switch c {
+	case codeUint8, codeUint16, codeUint32, codeUint64:
+		u, err = d.readUint(1 << (c - codeUint8))
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if u > math.MaxInt64 {
+			return 0, u, true, nil
+		}
+		return int64(u), 0, false, nil
+	case codeInt8:
+		u, err = d.readUint(1)
+		return int64(int8(u)), 0, false, err
+	case codeInt16:
+		u, err = d.readUint(2)
+		return int64(int16(u)), 0, false, err
+	case codeInt32:
+		u, err = d.readUint(4)
+		return int64(int32(u)), 0, false, err
+	case codeInt64:
+		u, err = d.readUint(8)
+		return int64(u), 0, false, err
+	}
+
+	return 0, 0, false, errNotInt
+}
+
+// readFloat 读取浮点数,同时兼容整数
+func (d *decoder) readFloat(c byte) (float64, error) {
+	switch c {
+	case codeFloat32:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case codeFloat64:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	}
+
+	v, u, unsigned, err := d.readInt(c)
+	if unsigned {
+		return float64(u), err
+	}
+	return float64(v), err
+}
+
+// readBytes 读取str或bin类型数据,返回的切片引用原始数据
+func (d *decoder) readBytes(c byte) ([]byte, error) {
+	var n int
+	var err error
+	switch {
+	case isFixstr(c):
+		n = int(c & fixstrMax)
+	case c == codeStr8 || c == codeBin8:
+		n, err = d.readLen(1, 1)
+	case c == codeStr16 || c == codeBin16:
+		n, err = d.readLen(2, 1)
+	case c == codeStr32 || c == codeBin32:
+		n, err = d.readLen(4, 1)
+	default:
+		return nil, errNotBytes
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d.readN(n)
+}
+
+func (d *decoder) readArrayLen(c byte) (int, error) {
+	switch {
+	case isFixarray(c):
+		return int(c & fixarrayMax), nil
+	case c == codeArray16:
+		return d.readLen(2, 1)
+	case c == codeArray32:
+		return d.readLen(4, 1)
+	}
+
+	return 0, errNotArray
+}
+
+func (d *decoder) readMapLen(c byte) (int, error) {
+	switch {
+	case isFixmap(c):
+		return int(c & fixmapMax), nil
+	case c == codeMap16:
+		return d.readLen(2, 2)
+	case c == codeMap32:
+		return d.readLen(4, 2)
+	}
+
+	return 0, errNotMap
+}
+
+func (d *decoder) readExt(c byte) (int8, []byte, error) {
+	var n int
+	var err error
+	switch c {
+	case codeFixExt1, codeFixExt2, codeFixExt4, codeFixExt8, codeFixExt16:
+		n = 1 << (c - codeFixExt1)
+	case codeExt8:
+		n, err = d.readLen(1, 1)
+	case codeExt16:
+		n, err = d.readLen(2, 1)
+	case codeExt32:
+		n, err = d.readLen(4, 1)
+	default:
+		return 0, nil, errNotExt
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	typ, err := d.readByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := d.readN(n)
+	return int8(typ), data, err
+}
+
+// 内部错误,解码时转换为TypeError
+var (
+	errNotInt   = &TypeError{}
+	errNotBytes = &TypeError{}
+	errNotArray = &TypeError{}
+	errNotMap   = &TypeError{}
+	errNotExt   = &TypeError{}
+)
+
+func isTypeMismatch(err error) bool {
+	return err == errNotInt || err == errNotBytes || err == errNotArray || err == errNotMap || err == errNotExt
+}
+
+func (d *decoder) decode(v reflect.Value, depth int) error {
+	err := d.decodeValue(v, depth)
+	if isTypeMismatch(err) {
+		return &TypeError{Code: d.data[d.off-1], Type: v.Type()}
+	}
+
+	return err
+}
+
+func (d *decoder) decodeValue(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrDepthLimit
+	}
+
+	c, err := d.peek()
+	if err != nil {
+		return err
+	}
+
+	if c == codeNil {
+		d.off++
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	if isExt(c) {
+		if ext, ok := gExtTypes[v.Type()]; ok {
+			d.off++
+			typ, data, err := d.readExt(c)
+			if err != nil {
+				return err
+			}
+			if typ != ext.id {
+				return &TypeError{Code: c, Type: v.Type()}
+			}
+			x, err := ext.unmarshal(data)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(x))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeValue(v.Elem(), depth+1)
+	case reflect.Interface:
+		// 非空interface只能解码到已有的指针中
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr && !v.Elem().IsNil() {
+			return d.decodeValue(v.Elem(), depth+1)
+		}
+		if v.NumMethod() != 0 {
+			d.off++
+			return &TypeError{Code: c, Type: v.Type()}
+		}
+		x, err := d.decodeAny(depth)
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	}
+
+	d.off++
+	switch v.Kind() {
+	case reflect.Bool:
+		switch c {
+		case codeTrue:
+			v.SetBool(true)
+		case codeFalse:
+			v.SetBool(false)
+		default:
+			return &TypeError{Code: c, Type: v.Type()}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, _, unsigned, err := d.readInt(c)
+		if err != nil {
+			return err
+		}
+		if unsigned || v.OverflowInt(x) {
+			return &TypeError{Code: c, Type: v.Type()}
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, u, unsigned, err := d.readInt(c)
+		if err != nil {
+			return err
+		}
+		if !unsigned {
+			if x < 0 {
+				return &TypeError{Code: c, Type: v.Type()}
+			}
+			u = uint64(x)
+		}
+		if v.OverflowUint(u) {
+			return &TypeError{Code: c, Type: v.Type()}
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		x, err := d.readFloat(c)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case reflect.String:
+		b, err := d.readBytes(c)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(b))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.readBytes(c)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		n, err := d.readArrayLen(c)
+		if err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			if err := d.decode(s.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		return d.decodeArray(c, v, depth)
+	case reflect.Map:
+		n, err := d.readMapLen(c)
+		if err != nil {
+			return err
+		}
+		t := v.Type()
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(t, n))
+		}
+		for i := 0; i < n; i++ {
+			key := reflect.New(t.Key()).Elem()
+			if err := d.decode(key, depth+1); err != nil {
+				return err
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if err := d.decode(elem, depth+1); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		return d.decodeStruct(c, v, depth)
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return nil
+}
+
+func (d *decoder) decodeArray(c byte, v reflect.Value, depth int) error {
+	if v.Type().Elem().Kind() == reflect.Uint8 && (isBinary(c) || isString(c)) {
+		b, err := d.readBytes(c)
+		if err != nil {
+			return err
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+		for i := len(b); i < v.Len(); i++ {
+			v.Index(i).SetUint(0)
+		}
+		return nil
+	}
+
+	n, err := d.readArrayLen(c)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if i >= v.Len() {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	for i := n; i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+	}
+
+	return nil
+}
+
+// decodeStruct 从map中解码,未知字段会被忽略
+func (d *decoder) decodeStruct(c byte, v reflect.Value, depth int) error {
+	n, err := d.readMapLen(c)
+	if err != nil {
+		return err
+	}
+
+	info := structs.Get(v.Type(), tagName)
+	for i := 0; i < n; i++ {
+		kc, err := d.readByte()
+		if err != nil {
+			return err
+		}
+		if !isString(kc) && !isBinary(kc) {
+			// 非字符串key,跳过key及value
+			d.off--
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, err := d.readBytes(kc)
+		if err != nil {
+			return err
+		}
+		f := info.Lookup(string(key))
+		if f == nil {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fv, _ := structs.FieldByIndex(v, f.Index, true)
+		if !fv.IsValid() || !fv.CanSet() {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.decode(fv, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeAny 解码到interface{},整数为int64(超出范围时为uint64),浮点数为float64
+//	map的key均为字符串时返回map[string]interface{},否则返回map[interface{}]interface{}
+func (d *decoder) decodeAny(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, ErrDepthLimit
+	}
+
+	c, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c == codeNil:
+		return nil, nil
+	case c == codeTrue:
+		return true, nil
+	case c == codeFalse:
+		return false, nil
+	case c == codeFloat32 || c == codeFloat64:
+		return d.readFloat(c)
+	case isString(c):
+		b, err := d.readBytes(c)
+		return string(b), err
+	case isBinary(c):
+		b, err := d.readBytes(c)
+		return append([]byte{}, b...), err
+	case isFixarray(c) || c == codeArray16 || c == codeArray32:
+		n, err := d.readArrayLen(c)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]interface{}, n)
+		for i := range res {
+			if res[i], err = d.decodeAny(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case isFixmap(c) || c == codeMap16 || c == codeMap32:
+		return d.decodeAnyMap(c, depth)
+	case isExt(c):
+		typ, data, err := d.readExt(c)
+		if err != nil {
+			return nil, err
+		}
+		if ext, ok := gExtIDs[typ]; ok {
+			return ext.unmarshal(data)
+		}
+		return &Ext{Type: typ, Data: append([]byte{}, data...)}, nil
+	}
+
+	v, u, unsigned, err := d.readInt(c)
+	if err != nil {
+		if isTypeMismatch(err) {
+			return nil, &TypeError{Code: c, Type: anyType}
+		}
+		return nil, err
+	}
+	if unsigned {
+		return u, nil
+	}
+	return v, nil
+}
+
+func (d *decoder) decodeAnyMap(c byte, depth int) (interface{}, error) {
+	n, err := d.readMapLen(c)
+	if err != nil {
+		return nil, err
+	}
+
+	strMap := make(map[string]interface{}, n)
+	var anyMap map[interface{}]interface{}
+	for i := 0; i < n; i++ {
+		key, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decodeAny(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if s, ok := key.(string); ok && anyMap == nil {
+			strMap[s] = value
+			continue
+		}
+		if anyMap == nil {
+			anyMap = make(map[interface{}]interface{}, n)
+			for k, v := range strMap {
+				anyMap[k] = v
+			}
+		}
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			return nil, &TypeError{Code: c, Type: reflect.TypeOf(anyMap)}
+		}
+		anyMap[key] = value
+	}
+
+	if anyMap != nil {
+		return anyMap, nil
+	}
+	return strMap, nil
+}
+
+// skip 跳过一个完整的值
+func (d *decoder) skip(depth int) error {
+	if depth > maxDepth {
+		return ErrDepthLimit
+	}
+
+	c, err := d.readByte()
+	if err != nil {
+		return err
+	}
+
+	var n int
+	switch {
+	case c <= posFixintMax || c >= negFixintMin || c == codeNil || c == codeTrue || c == codeFalse:
+		return nil
+	case c == codeUint8 || c == codeInt8:
+		n = 1
+	case c == codeUint16 || c == codeInt16:
+		n = 2
+	case c == codeUint32 || c == codeInt32 || c == codeFloat32:
+		n = 4
+	case c == codeUint64 || c == codeInt64 || c == codeFloat64:
+		n = 8
+	case isString(c) || isBinary(c):
+		_, err := d.readBytes(c)
+		return err
+	case isExt(c):
+		_, _, err := d.readExt(c)
+		return err
+	case isFixarray(c) || c == codeArray16 || c == codeArray32:
+		count, err := d.readArrayLen(c)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case isFixmap(c) || c == codeMap16 || c == codeMap32:
+		count, err := d.readMapLen(c)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < count*2; i++ {
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		// 0xc1未使用
+		return &TypeError{Code: c, Type: anyType}
+	}
+
+	_, err = d.readN(n)
+	return err
+}
@@ -0,0 +1,322 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/foredata/nova/encoding/internal/structs"
+)
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) writeByte(c byte) {
+	e.buf = append(e.buf, c)
+}
+
+func (e *encoder) write1(c byte, v uint8) {
+	e.buf = append(e.buf, c, v)
+}
+
+func (e *encoder) write2(c byte, v uint16) {
+	e.buf = append(e.buf, c, 0, 0)
+	binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], v)
+}
+
+func (e *encoder) write4(c byte, v uint32) {
+	e.buf = append(e.buf, c, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], v)
+}
+
+func (e *encoder) write8(c byte, v uint64) {
+	e.buf = append(e.buf, c, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], v)
+}
+
+func (e *encoder) writeNil() {
+	e.writeByte(codeNil)
+}
+
+func (e *encoder) writeBool(v bool) {
+	if v {
+		e.writeByte(codeTrue)
+	} else {
+		e.writeByte(codeFalse)
+	}
+}
+
+// writeInt 非负数按无符号编码,选择最短格式
+func (e *encoder) writeInt(v int64) {
+	switch {
+	case v >= 0:
+		e.writeUint(uint64(v))
+	case v >= -32:
+		e.writeByte(byte(v))
+	case v >= math.MinInt8:
+		e.write1(codeInt8, uint8(v))
+	case v >= math.MinInt16:
+		e.write2(codeInt16, uint16(v))
+	case v >= math.MinInt32:
+		e.write4(codeInt32, uint32(v))
+	default:
+		e.write8(codeInt64, uint64(v))
+	}
+}
+
+func (e *encoder) writeUint(v uint64) {
+	switch {
+	case v <= posFixintMax:
+		e.writeByte(byte(v))
+	case v <= math.MaxUint8:
+		e.write1(codeUint8, uint8(v))
+	case v <= math.MaxUint16:
+		e.write2(codeUint16, uint16(v))
+	case v <= math.MaxUint32:
+		e.write4(codeUint32, uint32(v))
+	default:
+		e.write8(codeUint64, v)
+	}
+}
+
+func (e *encoder) writeFloat32(v float32) {
+	e.write4(codeFloat32, math.Float32bits(v))
+}
+
+func (e *encoder) writeFloat64(v float64) {
+	e.write8(codeFloat64, math.Float64bits(v))
+}
+
+func (e *encoder) writeString(s string) {
+	n := len(s)
+	switch {
+	case n <= fixstrMax:
+		e.writeByte(fixstrPrefix | byte(n))
+	case n <= math.MaxUint8:
+		e.write1(codeStr8, uint8(n))
+	case n <= math.MaxUint16:
+		e.write2(codeStr16, uint16(n))
+	default:
+		e.write4(codeStr32, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) writeBinary(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.write1(codeBin8, uint8(n))
+	case n <= math.MaxUint16:
+		e.write2(codeBin16, uint16(n))
+	default:
+		e.write4(codeBin32, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) writeArrayLen(n int) {
+	switch {
+	case n <= fixarrayMax:
+		e.writeByte(fixarrayPrefix | byte(n))
+	case n <= math.MaxUint16:
+		e.write2(codeArray16, uint16(n))
+	default:
+		e.write4(codeArray32, uint32(n))
+	}
+}
+
+func (e *encoder) writeMapLen(n int) {
+	switch {
+	case n <= fixmapMax:
+		e.writeByte(fixmapPrefix | byte(n))
+	case n <= math.MaxUint16:
+		e.write2(codeMap16, uint16(n))
+	default:
+		e.write4(codeMap32, uint32(n))
+	}
+}
+
+func (e *encoder) writeExt(typ int8, data []byte) {
+	switch n := len(data); n {
+	case 1:
+		e.writeByte(codeFixExt1)
+	case 2:
+		e.writeByte(codeFixExt2)
+	case 4:
+		e.writeByte(codeFixExt4)
+	case 8:
+		e.writeByte(codeFixExt8)
+	case 16:
+		e.writeByte(codeFixExt16)
+	default:
+		switch {
+		case n <= math.MaxUint8:
+			e.write1(codeExt8, uint8(n))
+		case n <= math.MaxUint16:
+			e.write2(codeExt16, uint16(n))
+		default:
+			e.write4(codeExt32, uint32(n))
+		}
+	}
+	e.writeByte(byte(typ))
+	e.buf = append(e.buf, data...)
+}
+
+func (e *encoder) encode(v reflect.Value, depth int) error {
+	if depth > maxDepth {
+		return ErrDepthLimit
+	}
+
+	if !v.IsValid() {
+		e.writeNil()
+		return nil
+	}
+
+	if ext, ok := gExtTypes[v.Type()]; ok {
+		data, err := ext.marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+		e.writeExt(ext.id, data)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.writeBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeUint(v.Uint())
+	case reflect.Float32:
+		e.writeFloat32(float32(v.Float()))
+	case reflect.Float64:
+		e.writeFloat64(v.Float())
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		return e.encode(v.Elem(), depth+1)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.writeBinary(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			e.writeBinary(b)
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.writeNil()
+			return nil
+		}
+		return e.encodeMap(v, depth)
+	case reflect.Struct:
+		return e.encodeStruct(v, depth)
+	default:
+		return &UnsupportedTypeError{Type: v.Type()}
+	}
+
+	return nil
+}
+
+// encodeMap key按编码后的字节序排序,保证相同内容编码结果一致
+func (e *encoder) encodeMap(v reflect.Value, depth int) error {
+	e.writeMapLen(v.Len())
+	// 先依次编码到buf,记录每个kv的位置,排序后再整体回写
+	type entry struct {
+		key, kv []byte
+	}
+	start := len(e.buf)
+	bounds := make([]int, 0, v.Len()*2)
+	iter := v.MapRange()
+	for iter.Next() {
+		if err := e.encode(iter.Key(), depth+1); err != nil {
+			return err
+		}
+		bounds = append(bounds, len(e.buf))
+		if err := e.encode(iter.Value(), depth+1); err != nil {
+			return err
+		}
+		bounds = append(bounds, len(e.buf))
+	}
+	if len(bounds) <= 2 {
+		return nil
+	}
+
+	entries := make([]entry, 0, len(bounds)/2)
+	data := append([]byte(nil), e.buf[start:]...)
+	off := 0
+	for i := 0; i < len(bounds); i += 2 {
+		keyEnd, end := bounds[i]-start, bounds[i+1]-start
+		entries = append(entries, entry{key: data[off:keyEnd], kv: data[off:end]})
+		off = end
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].key, entries[j].key) < 0
+	})
+
+	e.buf = e.buf[:start]
+	for _, x := range entries {
+		e.buf = append(e.buf, x.kv...)
+	}
+
+	return nil
+}
+
+func (e *encoder) encodeArray(v reflect.Value, depth int) error {
+	n := v.Len()
+	e.writeArrayLen(n)
+	for i := 0; i < n; i++ {
+		if err := e.encode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeStruct 编码为map,key为字段名
+func (e *encoder) encodeStruct(v reflect.Value, depth int) error {
+	info := structs.Get(v.Type(), tagName)
+	fields := make([]reflect.Value, len(info.Fields))
+	count := 0
+	for i, f := range info.Fields {
+		fv, ok := structs.FieldByIndex(v, f.Index, false)
+		if !ok || (f.OmitEmpty && structs.IsEmpty(fv)) {
+			continue
+		}
+		fields[i] = fv
+		count++
+	}
+
+	e.writeMapLen(count)
+	for i, f := range info.Fields {
+		if !fields[i].IsValid() {
+			continue
+		}
+		e.writeString(f.Name)
+		if err := e.encode(fields[i], depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
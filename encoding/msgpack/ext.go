@@ -0,0 +1,106 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/foredata/nova/idgen"
+)
+
+// 内置扩展类型
+const (
+	ExtTime int8 = -1 // 官方timestamp扩展
+	ExtID   int8 = 1  // idgen.ID,8字节大端
+)
+
+var errInvalidExt = errors.New("msgpack: invalid ext data")
+
+// Ext 未注册的扩展类型,解码到interface{}时返回
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// extension 扩展类型编解码
+type extension struct {
+	id        int8
+	typ       reflect.Type
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte) (interface{}, error)
+}
+
+var (
+	gExtTypes = make(map[reflect.Type]*extension)
+	gExtIDs   = make(map[int8]*extension)
+)
+
+// RegisterExt 注册扩展类型,value为该类型的任意值,unmarshal返回值需为该类型
+//	非线程安全,通常仅在init中注册
+func RegisterExt(id int8, value interface{}, marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte) (interface{}, error)) {
+	ext := &extension{id: id, typ: reflect.TypeOf(value), marshal: marshal, unmarshal: unmarshal}
+	gExtTypes[ext.typ] = ext
+	gExtIDs[id] = ext
+}
+
+func init() {
+	RegisterExt(ExtTime, time.Time{}, marshalTime, unmarshalTime)
+	RegisterExt(ExtID, idgen.ID(0), marshalID, unmarshalID)
+}
+
+// marshalTime 根据精度选择32,64,96位格式
+func marshalTime(v interface{}) ([]byte, error) {
+	t := v.(time.Time)
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if sec >= 0 && sec>>34 == 0 {
+		if nsec == 0 && sec>>32 == 0 {
+			b := make([]byte, 4)
+			binary.BigEndian.PutUint32(b, uint32(sec))
+			return b, nil
+		}
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, uint64(nsec)<<34|uint64(sec))
+		return b, nil
+	}
+
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint32(b, uint32(nsec))
+	binary.BigEndian.PutUint64(b[4:], uint64(sec))
+	return b, nil
+}
+
+func unmarshalTime(data []byte) (interface{}, error) {
+	var sec, nsec int64
+	switch len(data) {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		nsec, sec = int64(v>>34), int64(v&(1<<34-1))
+	case 12:
+		nsec = int64(binary.BigEndian.Uint32(data))
+		sec = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return nil, errInvalidExt
+	}
+	if nsec >= int64(time.Second) {
+		return nil, errInvalidExt
+	}
+
+	return time.Unix(sec, nsec), nil
+}
+
+func marshalID(v interface{}) ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v.(idgen.ID)))
+	return b, nil
+}
+
+func unmarshalID(data []byte) (interface{}, error) {
+	if len(data) != 8 {
+		return nil, errInvalidExt
+	}
+
+	return idgen.ID(binary.BigEndian.Uint64(data)), nil
+}
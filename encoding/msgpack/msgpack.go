@@ -0,0 +1,89 @@
+// Package msgpack 无第三方依赖的msgpack编解码,格式见https://github.com/msgpack/msgpack/blob/master/spec.md
+//	结构体编码为map,字段名优先使用msgpack tag,其次使用json tag,支持omitempty
+//	map的key按编码后字节序排序,相同内容编码结果一致
+//	time.Time使用官方timestamp扩展(-1),idgen.ID使用扩展类型ExtID,可通过RegisterExt注册其他扩展类型
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/foredata/nova/encoding"
+)
+
+// some error
+var (
+	ErrShortBuffer  = errors.New("msgpack: short buffer")
+	ErrDepthLimit   = errors.New("msgpack: exceeded max depth")
+	ErrInvalidValue = errors.New("msgpack: Unmarshal(non-pointer or nil)")
+	ErrTrailingData = errors.New("msgpack: trailing data")
+)
+
+// maxDepth 最大嵌套深度,防止恶意数据导致栈溢出
+const maxDepth = 512
+
+const tagName = "msgpack"
+
+// UnsupportedTypeError 不支持编码的类型
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "msgpack: unsupported type " + e.Type.String()
+}
+
+// TypeError 数据类型与目标类型不匹配
+type TypeError struct {
+	Code byte         // 数据类型标识
+	Type reflect.Type // 目标类型
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("msgpack: cannot unmarshal 0x%02x into %s", e.Code, e.Type)
+}
+
+// Marshal 编码
+func Marshal(v interface{}) ([]byte, error) {
+	e := &encoder{}
+	if err := e.encode(reflect.ValueOf(v), 0); err != nil {
+		return nil, err
+	}
+
+	return e.buf, nil
+}
+
+// Unmarshal 解码,v必须为非nil指针
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ErrInvalidValue
+	}
+
+	d := &decoder{data: data}
+	if err := d.decode(rv.Elem(), 0); err != nil {
+		return err
+	}
+	if d.off != len(d.data) {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// NewCodec 创建encoding.Codec
+func NewCodec() encoding.Codec {
+	return &msgpackCodec{}
+}
+
+type msgpackCodec struct {
+}
+
+func (c *msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return Marshal(v)
+}
+
+func (c *msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return Unmarshal(data, v)
+}
@@ -0,0 +1,259 @@
+package msgpack
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/idgen"
+)
+
+type testBase struct {
+	ID    idgen.ID  `msgpack:"id"`
+	Ctime time.Time `json:"ctime"`
+}
+
+type testUser struct {
+	testBase
+	Name    string            `msgpack:"name"`
+	Age     uint8             `msgpack:"age,omitempty"`
+	Score   float64           `msgpack:"score"`
+	Tags    []string          `msgpack:"tags"`
+	Attrs   map[string]int32  `msgpack:"attrs"`
+	Hash    [4]byte           `msgpack:"hash"`
+	Data    []byte            `msgpack:"data"`
+	Next    *testUser         `msgpack:"next"`
+	Any     interface{}       `msgpack:"any"`
+	Ignore  string            `msgpack:"-"`
+	Comment string
+	extra   map[string]string //nolint
+}
+
+func TestRoundTrip(t *testing.T) {
+	u := &testUser{
+		testBase: testBase{ID: 123456789, Ctime: time.Unix(1600000000, 123)},
+		Name:     "nova",
+		Score:    1.5,
+		Tags:     []string{"a", "b"},
+		Attrs:    map[string]int32{"x": -1, "y": 1 << 20},
+		Hash:     [4]byte{1, 2, 3, 4},
+		Data:     []byte("data"),
+		Next:     &testUser{Name: "next"},
+		Any:      []interface{}{int64(-5), "s", true, nil, 2.5},
+		Ignore:   "ignore",
+		Comment:  "comment",
+	}
+
+	data, err := Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &testUser{}
+	if err := Unmarshal(data, out); err != nil {
+		t.Fatal(err)
+	}
+	u.Ignore = ""
+	if !out.Ctime.Equal(u.Ctime) {
+		t.Errorf("bad time, %v", out.Ctime)
+	}
+	if !out.Next.Ctime.Equal(u.Next.Ctime) {
+		t.Errorf("bad zero time, %v", out.Next.Ctime)
+	}
+	out.Ctime = u.Ctime
+	out.Next.Ctime = u.Next.Ctime
+	if !reflect.DeepEqual(u, out) {
+		t.Errorf("not equal, %+v", out)
+	}
+
+	// 解码到interface{}
+	var m interface{}
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	mm := m.(map[string]interface{})
+	if _, ok := mm["age"]; ok {
+		t.Errorf("age should omit")
+	}
+	if mm["id"] != idgen.ID(123456789) || mm["name"] != "nova" || mm["Comment"] != "comment" {
+		t.Errorf("bad map, %+v", mm)
+	}
+}
+
+func TestInteger(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		code []byte
+	}{
+		{int64(0), []byte{0x00}},
+		{int64(127), []byte{0x7f}},
+		{int64(-1), []byte{0xff}},
+		{int64(-32), []byte{0xe0}},
+		{int64(-33), []byte{codeInt8, 0xdf}},
+		{int64(128), []byte{codeUint8, 0x80}},
+		{int64(-129), []byte{codeInt16, 0xff, 0x7f}},
+		{int64(65536), []byte{codeUint32, 0, 1, 0, 0}},
+		{int64(math.MinInt64), []byte{codeInt64, 0x80, 0, 0, 0, 0, 0, 0, 0}},
+		{uint64(math.MaxUint64), []byte{codeUint64, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		data, err := Marshal(tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, tt.code) {
+			t.Errorf("encode %v, got %x, want %x", tt.v, data, tt.code)
+		}
+		var v interface{}
+		if err := Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if v != tt.v {
+			t.Errorf("decode %x, got %v", data, v)
+		}
+	}
+
+	// 溢出
+	data, _ := Marshal(300)
+	var i8 int8
+	if _, ok := Unmarshal(data, &i8).(*TypeError); !ok {
+		t.Errorf("expect overflow error")
+	}
+	data, _ = Marshal(-1)
+	var u32 uint32
+	if _, ok := Unmarshal(data, &u32).(*TypeError); !ok {
+		t.Errorf("expect type error")
+	}
+}
+
+func TestExt(t *testing.T) {
+	times := []time.Time{
+		time.Unix(1600000000, 0),
+		time.Unix(1600000000, 999999999),
+		time.Unix(-100, 5),
+		time.Unix(1<<35, 0),
+	}
+	sizes := []int{6, 10, 15, 15}
+	for i, tm := range times {
+		data, err := Marshal(tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != sizes[i] {
+			t.Errorf("bad size, %v %d", tm, len(data))
+		}
+		var out time.Time
+		if err := Unmarshal(data, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !out.Equal(tm) {
+			t.Errorf("time not equal, %v %v", out, tm)
+		}
+	}
+
+	// 未注册的扩展类型
+	e := &encoder{}
+	e.writeExt(42, []byte{1, 2, 3})
+	var v interface{}
+	if err := Unmarshal(e.buf, &v); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, &Ext{Type: 42, Data: []byte{1, 2, 3}}) {
+		t.Errorf("bad ext, %+v", v)
+	}
+}
+
+func TestSkipUnknown(t *testing.T) {
+	src := map[string]interface{}{
+		"name":    "nova",
+		"unknown": map[string]interface{}{"a": []interface{}{1, "x", 2.5, []byte{1}}},
+		"NAME2":   1,
+	}
+	data, err := Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := struct {
+		Name string `msgpack:"name"`
+	}{}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "nova" {
+		t.Errorf("bad name, %s", out.Name)
+	}
+}
+
+func TestMapOrder(t *testing.T) {
+	m := map[string]int{"e": 5, "a": 1, "d": 4, "b": 2, "c": 3}
+	want := []byte{fixmapPrefix | 5}
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		want = append(want, fixstrPrefix|1, k[0], byte(m[k]))
+	}
+	for i := 0; i < 10; i++ {
+		data, err := Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Fatalf("bad order, %x", data)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	data, _ := Marshal(map[string]interface{}{"a": "hello", "b": []int{1, 2, 3}})
+	var v interface{}
+	for i := 0; i < len(data); i++ {
+		if err := Unmarshal(data[:i], &v); err == nil {
+			t.Errorf("expect error for truncated data, %d", i)
+		}
+	}
+
+	if err := Unmarshal(append(data, 0x01), &v); err != ErrTrailingData {
+		t.Errorf("expect trailing error, %v", err)
+	}
+	if err := Unmarshal(data, v); err != ErrInvalidValue {
+		t.Errorf("expect invalid value, %v", err)
+	}
+
+	// 超大长度
+	if err := Unmarshal([]byte{codeArray32, 0xff, 0xff, 0xff, 0xff}, &v); err != ErrShortBuffer {
+		t.Errorf("expect short buffer, %v", err)
+	}
+
+	// 嵌套过深
+	deep := bytes.Repeat([]byte{fixarrayPrefix | 1}, maxDepth+10)
+	deep = append(deep, codeNil)
+	if err := Unmarshal(deep, &v); err != ErrDepthLimit {
+		t.Errorf("expect depth limit, %v", err)
+	}
+
+	var s string
+	if _, ok := Unmarshal([]byte{0x01}, &s).(*TypeError); !ok {
+		t.Errorf("expect type error")
+	}
+
+	if _, err := Marshal(make(chan int)); err == nil {
+		t.Errorf("expect unsupported type")
+	}
+}
+
+func TestCodec(t *testing.T) {
+	c := NewCodec()
+	data, err := c.Marshal(map[string]string{"k": "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]string
+	if err := c.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["k"] != "v" {
+		t.Errorf("bad value, %+v", out)
+	}
+}
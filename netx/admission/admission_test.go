@@ -0,0 +1,217 @@
+package admission_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/admission"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/transport/gpc"
+)
+
+func listen(t *testing.T, opts ...admission.Option) string {
+	t.Helper()
+	return listenWith(t, admission.NewFilter(opts...))
+}
+
+func listenWith(t *testing.T, filters ...netx.Filter) string {
+	t.Helper()
+	tran := gpc.New()
+	tran.AddFilters(filters...)
+	l, err := tran.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = tran.Close()
+	})
+
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	return c
+}
+
+// closed 服务端是否关闭了连接
+func closed(c net.Conn) bool {
+	_ = c.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var b [1]byte
+	_, err := c.Read(b[:])
+	return !errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+func TestMaxConnsPerIP(t *testing.T) {
+	addr := listen(t, admission.WithMaxConnsPerIP(2))
+	c1 := dial(t, addr)
+	c2 := dial(t, addr)
+	c3 := dial(t, addr)
+	if closed(c1) || closed(c2) || !closed(c3) {
+		t.Fatal("third conn should be rejected")
+	}
+
+	// 释放后可以重新建立连接
+	_ = c1.Close()
+	time.Sleep(50 * time.Millisecond)
+	if c4 := dial(t, addr); closed(c4) {
+		t.Fatal("conn should be admitted after release")
+	}
+}
+
+func TestRule(t *testing.T) {
+	addr := listen(t, admission.WithRule("10.0.0.0/8", 0), admission.WithRule("127.0.0.0/8", 1))
+	c1 := dial(t, addr)
+	c2 := dial(t, addr)
+	if closed(c1) || !closed(c2) {
+		t.Fatal("second conn should be rejected by rule")
+	}
+}
+
+func TestAcceptRate(t *testing.T) {
+	addr := listen(t, admission.WithAcceptRate(1, 2))
+	c1 := dial(t, addr)
+	c2 := dial(t, addr)
+	c3 := dial(t, addr)
+	if closed(c1) || closed(c2) || !closed(c3) {
+		t.Fatal("third conn should be rejected by rate")
+	}
+}
+
+// TestAcceptRateOrder 超过连接数被拒绝的连接不消耗建连令牌
+func TestAcceptRateOrder(t *testing.T) {
+	addr := listen(t, admission.WithMaxConns(1), admission.WithAcceptRate(1, 2))
+	c1 := dial(t, addr)
+	c2 := dial(t, addr)
+	if closed(c1) || !closed(c2) {
+		t.Fatal("second conn should be rejected by max conns")
+	}
+
+	_ = c1.Close()
+	time.Sleep(50 * time.Millisecond)
+	if c3 := dial(t, addr); closed(c3) {
+		t.Fatal("conn should be admitted with remaining token")
+	}
+}
+
+func TestRejectTimeout(t *testing.T) {
+	addr := listen(t, admission.WithMaxConns(1), admission.WithMode(admission.ModeReject), admission.WithRejectTimeout(300*time.Millisecond))
+	c1 := dial(t, addr)
+	c2 := dial(t, addr)
+	if closed(c1) || closed(c2) {
+		t.Fatal("rejected conn should wait for first request")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if closed(c1) || !closed(c2) {
+		t.Fatal("idle rejected conn should be closed")
+	}
+}
+
+// readFilter 统计收到的数据
+type readFilter struct {
+	netx.BaseFilter
+	reads int32
+}
+
+func (f *readFilter) Name() string {
+	return "read"
+}
+
+func (f *readFilter) HandleRead(ctx netx.FilterCtx) error {
+	atomic.AddInt32(&f.reads, 1)
+	return nil
+}
+
+// slowFilter HandleOpen耗时较长,数据先于准入结果到达
+type slowFilter struct {
+	netx.BaseFilter
+}
+
+func (f *slowFilter) Name() string {
+	return "slow"
+}
+
+func (f *slowFilter) HandleOpen(ctx netx.FilterCtx) error {
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}
+
+// TestRejectEarlyWrite 建连后立即发送数据,被拒绝的连接不会把数据传递给后续Filter
+func TestRejectEarlyWrite(t *testing.T) {
+	for _, mode := range []admission.Mode{admission.ModeClose, admission.ModeReject} {
+		rf := &readFilter{}
+		addr := listenWith(t, &slowFilter{}, admission.NewFilter(admission.WithRule("127.0.0.0/8", 0), admission.WithMode(mode)), rf)
+		for i := 0; i < 5; i++ {
+			c := dial(t, addr)
+			if _, err := c.Write([]byte("GET / HTTP/1.1\r\nHost: a\r\n\r\n")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(150 * time.Millisecond)
+		if n := atomic.LoadInt32(&rf.reads); n != 0 {
+			t.Fatalf("rejected conn data passed to next filter, mode=%v, reads=%d", mode, n)
+		}
+	}
+}
+
+func TestReject(t *testing.T) {
+	svr := server.New(
+		server.WithAddr("127.0.0.1:0"),
+		server.WithTranFactory(gpc.New),
+		server.WithAdmission(admission.WithMaxConns(1), admission.WithMode(admission.ModeReject)),
+	)
+	s := svr.(interface {
+		Start() error
+		Stop() error
+		Addr() net.Addr
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	svr.GET("/ping", func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return nil, nil
+	})
+
+	addr := s.Addr().String()
+	get := func(c net.Conn) *http.Response {
+		if _, err := c.Write([]byte("GET /ping HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		_ = c.SetReadDeadline(time.Now().Add(time.Second))
+		rsp, err := http.ReadResponse(bufio.NewReader(c), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp
+	}
+
+	c1 := dial(t, addr)
+	if rsp := get(c1); rsp.StatusCode != http.StatusOK {
+		t.Fatalf("bad status, %d", rsp.StatusCode)
+	}
+	c2 := dial(t, addr)
+	if rsp := get(c2); rsp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503, %d", rsp.StatusCode)
+	}
+	if !closed(c2) {
+		t.Fatal("rejected conn should be closed")
+	}
+}
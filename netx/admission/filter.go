@@ -0,0 +1,334 @@
+// Package admission 连接准入限制,按总连接数,单IP连接数,网段连接数及建连速率限制连接
+//	Filter需要位于FilterChain最前边,在HandleOpen中拒绝连接,后续Filter不会感知被拒绝的连接
+//	server.New(server.WithAdmission(
+//		admission.WithMaxConns(100000),
+//		admission.WithMaxConnsPerIP(100),
+//		admission.WithAcceptRate(1000, 2000),
+//	))
+package admission
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/pkg/ratelimit"
+	"github.com/foredata/nova/pkg/unique"
+	"github.com/foredata/nova/times/timing"
+)
+
+// maxRejectSize ModeReject时等待首个请求的最大字节数,超过后直接关闭
+const maxRejectSize = 64 * 1024
+
+// reject reason
+const (
+	reasonMaxConns     = "max_conns"
+	reasonMaxIPConns   = "max_ip_conns"
+	reasonRule         = "rule"
+	reasonAcceptRate   = "accept_rate"
+	reasonIPAcceptRate = "ip_accept_rate"
+)
+
+var (
+	gConns = metrics.NewGauge(&metrics.GaugeOpts{
+		Namespace: "nova",
+		Subsystem: "admission",
+		Name:      "conns",
+		Help:      "current admitted connections",
+	})
+	gIPs = metrics.NewGauge(&metrics.GaugeOpts{
+		Namespace: "nova",
+		Subsystem: "admission",
+		Name:      "ips",
+		Help:      "current remote ips with admitted connections",
+	})
+	gRejected = metrics.NewCounterSet(&metrics.CounterOpts{
+		Namespace: "nova",
+		Subsystem: "admission",
+		Name:      "rejected",
+		Help:      "rejected connections by reason",
+	}, []string{"reason"})
+)
+
+var kConnKeyAdmission = unique.NewKey(netx.KeyGroupConn, "admission")
+
+// NewFilter 创建连接准入Filter
+func NewFilter(opts ...Option) netx.Filter {
+	o := &Options{RejectTimeout: defaultRejectTimeout}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	f := &filter{opts: o, ips: make(map[string]int), rules: make([]int, len(o.Rules))}
+	if o.AcceptRate > 0 {
+		f.rate = ratelimit.NewTokenBucket(o.AcceptRate, burstOf(o.AcceptRate, o.AcceptBurst))
+	}
+	if o.IPAcceptRate > 0 {
+		f.ipRate = ratelimit.NewTokenBucket(o.IPAcceptRate, burstOf(o.IPAcceptRate, o.IPAcceptBurst))
+	}
+
+	return f
+}
+
+func burstOf(rate float64, burst int) int {
+	if burst > 0 {
+		return burst
+	}
+	if rate < 1 {
+		return 1
+	}
+
+	return int(rate)
+}
+
+type filter struct {
+	netx.BaseFilter
+	opts   *Options
+	rate   ratelimit.Limiter // 建连速率
+	ipRate ratelimit.Limiter // 单IP建连速率
+	mux    sync.Mutex        //
+	total  int               // 当前连接数
+	ips    map[string]int    // 每个IP的连接数
+	rules  []int             // 每个规则的连接数
+}
+
+// connState 连接准入结果
+//	rejected和done在HandleOpen与读协程中都会访问,使用原子操作
+type connState struct {
+	ip       string    // 远程IP
+	rule     int       // 匹配的规则,-1表示没有匹配
+	rejected int32     // 是否被拒绝
+	done     int32     // 已经处理完成,等待关闭
+	timerID  timing.ID // ModeReject时等待首个请求的定时器
+}
+
+func (st *connState) isRejected() bool {
+	return atomic.LoadInt32(&st.rejected) == 1
+}
+
+func (st *connState) isDone() bool {
+	return atomic.LoadInt32(&st.done) == 1
+}
+
+func (st *connState) setDone() {
+	atomic.StoreInt32(&st.done, 1)
+}
+
+func (f *filter) Name() string {
+	return "admission"
+}
+
+func (f *filter) HandleOpen(ctx netx.FilterCtx) error {
+	conn := ctx.Conn()
+	ip := remoteIP(conn.RemoteAddr())
+	st := &connState{ip: ip, rule: -1}
+	reason := f.admit(st)
+	if reason == "" {
+		conn.Attributes().Put(kConnKeyAdmission, st)
+		return nil
+	}
+
+	// 关闭前仍可能读取到数据,需要记录状态用于丢弃
+	gRejected.Values(reason).Inc()
+	ctx.Abort()
+	atomic.StoreInt32(&st.rejected, 1)
+	done := f.opts.Mode != ModeReject
+	if done {
+		st.setDone()
+	} else if f.opts.RejectTimeout > 0 {
+		// 对端不发送请求时,避免连接一直占用资源
+		st.timerID = timing.NewDelayer(f.opts.RejectTimeout, onRejectTimeout, conn)
+	}
+	conn.Attributes().Put(kConnKeyAdmission, st)
+	if done {
+		return conn.Close()
+	}
+
+	return nil
+}
+
+func onRejectTimeout(data interface{}) {
+	_ = data.(netx.Conn).Close()
+}
+
+// HandleRead 被拒绝的连接不再向后传递,ModeReject时解析首个请求并返回503
+func (f *filter) HandleRead(ctx netx.FilterCtx) error {
+	st := getState(ctx.Conn())
+	if st == nil || !st.isRejected() {
+		return nil
+	}
+
+	ctx.Abort()
+	buf, ok := ctx.Data().(bytex.Buffer)
+	if !ok {
+		return nil
+	}
+
+	if st.isDone() {
+		discard(buf)
+		return nil
+	}
+
+	if f.reject(ctx.Conn(), buf) {
+		st.setDone()
+	}
+	return nil
+}
+
+func (f *filter) HandleClose(ctx netx.FilterCtx) error {
+	st := getState(ctx.Conn())
+	if st == nil {
+		return nil
+	}
+	if st.isRejected() {
+		timing.Stop(st.timerID)
+		return nil
+	}
+
+	ctx.Conn().Attributes().Remove(kConnKeyAdmission)
+	f.release(st)
+	return nil
+}
+
+func getState(conn netx.Conn) *connState {
+	st, _ := conn.Attributes().Get(kConnKeyAdmission, nil).(*connState)
+	return st
+}
+
+// admit 检查是否超过限制,通过时增加计数,返回拒绝原因
+//	先检查连接数限制,避免被拒绝的连接消耗建连速率的令牌
+func (f *filter) admit(st *connState) string {
+	o := f.opts
+	rule := f.match(st.ip)
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if o.MaxConns > 0 && f.total >= o.MaxConns {
+		return reasonMaxConns
+	}
+	if o.MaxConnsPerIP > 0 && f.ips[st.ip] >= o.MaxConnsPerIP {
+		return reasonMaxIPConns
+	}
+	if rule >= 0 && f.rules[rule] >= o.Rules[rule].MaxConns {
+		return reasonRule
+	}
+	if f.rate != nil {
+		if res, err := f.rate.Allow(context.Background(), "", 1); err != nil || !res.Allowed {
+			return reasonAcceptRate
+		}
+	}
+	if f.ipRate != nil {
+		if res, err := f.ipRate.Allow(context.Background(), st.ip, 1); err != nil || !res.Allowed {
+			return reasonIPAcceptRate
+		}
+	}
+
+	f.total++
+	f.ips[st.ip]++
+	if f.ips[st.ip] == 1 {
+		gIPs.Inc()
+	}
+	if rule >= 0 {
+		f.rules[rule]++
+	}
+	st.rule = rule
+	gConns.Inc()
+	return ""
+}
+
+func (f *filter) release(st *connState) {
+	f.mux.Lock()
+	f.total--
+	if n := f.ips[st.ip] - 1; n > 0 {
+		f.ips[st.ip] = n
+	} else {
+		delete(f.ips, st.ip)
+		gIPs.Dec()
+	}
+	if st.rule >= 0 {
+		f.rules[st.rule]--
+	}
+	f.mux.Unlock()
+	gConns.Dec()
+}
+
+// match 返回第一个匹配的规则索引
+func (f *filter) match(ip string) int {
+	if len(f.opts.Rules) == 0 {
+		return -1
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return -1
+	}
+	for i, r := range f.opts.Rules {
+		if r.CIDR.Contains(addr) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// reject 按协议返回503后关闭连接,数据不足时返回false,等待下次读取
+func (f *filter) reject(conn netx.Conn, buf bytex.Buffer) bool {
+	proto, _ := conn.Protocol().(netx.Protocol)
+	if proto == nil {
+		if f.opts.Detector != nil {
+			proto = f.opts.Detector.Detect(buf)
+		} else {
+			proto = protocol.Detect(buf)
+		}
+		if proto != nil {
+			conn.SetProtocol(proto)
+		}
+	}
+
+	var frame netx.Frame
+	var err error
+	if proto != nil {
+		frame, err = proto.Decode(conn, buf)
+	}
+	if err == nil && frame == nil && buf.Len() < maxRejectSize {
+		return false
+	}
+
+	if frame != nil {
+		if ident := frame.Identifier(); ident != nil && !ident.IsResponse {
+			rsp := netx.NewResponse()
+			rsp.SetVersion(ident.Version)
+			rsp.SetSeqID(ident.SeqID)
+			rsp.SetCodec(ident.Codec)
+			rsp.SetStatus(http.StatusServiceUnavailable, "too many connections")
+			_ = conn.Send(rsp)
+		}
+		frame.Recycle()
+	}
+
+	discard(buf)
+	_ = conn.Close()
+	return true
+}
+
+func discard(buf bytex.Buffer) {
+	_, _ = buf.Seek(0, io.SeekEnd)
+	buf.Discard()
+}
+
+// remoteIP 地址不包含端口时(例如内存连接)直接使用地址
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
@@ -0,0 +1,104 @@
+package admission
+
+import (
+	"net"
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Mode 超过限制时的处理方式
+type Mode uint8
+
+const (
+	ModeClose  Mode = iota // 直接关闭连接
+	ModeReject             // 读取首个请求后按协议返回503再关闭,协议无法识别或超时未收到请求时直接关闭
+)
+
+// defaultRejectTimeout ModeReject时等待首个请求的默认时间
+const defaultRejectTimeout = 5 * time.Second
+
+// Rule 按网段限制连接总数
+type Rule struct {
+	CIDR     *net.IPNet // 网段
+	MaxConns int        // 网段内所有IP的最大连接数
+}
+
+// Options 可选配置参数,数值为0表示不限制
+type Options struct {
+	MaxConns      int           // 最大连接数
+	MaxConnsPerIP int           // 单个IP最大连接数
+	Rules         []*Rule       // 网段限制,使用第一个匹配的规则
+	AcceptRate    float64       // 每秒最多接受的连接数
+	AcceptBurst   int           // 突发连接数,默认同AcceptRate
+	IPAcceptRate  float64       // 单个IP每秒最多接受的连接数
+	IPAcceptBurst int           // 单个IP突发连接数,默认同IPAcceptRate
+	Mode          Mode          // 超过限制时的处理方式,默认ModeClose
+	Detector      netx.Detector // ModeReject时用于探测协议,默认自动探测
+	RejectTimeout time.Duration // ModeReject时等待首个请求的时间,超时后关闭,默认5s
+}
+
+type Option func(o *Options)
+
+// WithMaxConns 设置最大连接数
+func WithMaxConns(n int) Option {
+	return func(o *Options) {
+		o.MaxConns = n
+	}
+}
+
+// WithMaxConnsPerIP 设置单个IP最大连接数
+func WithMaxConnsPerIP(n int) Option {
+	return func(o *Options) {
+		o.MaxConnsPerIP = n
+	}
+}
+
+// WithRule 设置网段最大连接数,cidr格式如10.0.0.0/8,格式错误时panic
+func WithRule(cidr string, maxConns int) Option {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(o *Options) {
+		o.Rules = append(o.Rules, &Rule{CIDR: ipnet, MaxConns: maxConns})
+	}
+}
+
+// WithAcceptRate 设置每秒最多接受的连接数
+func WithAcceptRate(rate float64, burst int) Option {
+	return func(o *Options) {
+		o.AcceptRate = rate
+		o.AcceptBurst = burst
+	}
+}
+
+// WithIPAcceptRate 设置单个IP每秒最多接受的连接数
+func WithIPAcceptRate(rate float64, burst int) Option {
+	return func(o *Options) {
+		o.IPAcceptRate = rate
+		o.IPAcceptBurst = burst
+	}
+}
+
+// WithMode 设置超过限制时的处理方式
+func WithMode(m Mode) Option {
+	return func(o *Options) {
+		o.Mode = m
+	}
+}
+
+// WithDetector 设置协议探测
+func WithDetector(d netx.Detector) Option {
+	return func(o *Options) {
+		o.Detector = d
+	}
+}
+
+// WithRejectTimeout 设置ModeReject时等待首个请求的时间
+func WithRejectTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.RejectTimeout = d
+	}
+}
@@ -3,6 +3,7 @@ package body
 import (
 	"errors"
 	"io"
+	"os"
 
 	"github.com/foredata/nova/pkg/bytex"
 )
@@ -38,6 +39,12 @@ type Body interface {
 	End() bool
 }
 
+// FileRegion 文件类型body实现,用于sendfile等零拷贝发送
+type FileRegion interface {
+	// 返回文件及尚未读取的区间
+	Region() (file *os.File, offset int64, length int64)
+}
+
 // Writer body数据写入接口,用于streaming模式下,拼接多个frame组成一个完整body
 //	底层需要保证读写线程安全
 type Writer interface {
@@ -8,8 +8,16 @@ import (
 	"github.com/foredata/nova/pkg/bytex"
 )
 
+const defaultChunkSize = 32 * 1024
+
 // NewFileBody 用于传输文件,可以指定文件偏移offset和每次传输chunck大小
 func NewFileBody(filename string, offset int64, maxChunk int) (Body, error) {
+	return NewFileRangeBody(filename, offset, -1, maxChunk)
+}
+
+// NewFileRangeBody 传输文件[offset, offset+length)范围内的数据,length小于0表示直到文件末尾
+//	maxChunk小于等于0时使用默认值32K
+func NewFileRangeBody(filename string, offset int64, length int64, maxChunk int) (Body, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
@@ -17,19 +25,37 @@ func NewFileBody(filename string, offset int64, maxChunk int) (Body, error) {
 
 	fi, err := file.Stat()
 	if err != nil {
+		_ = file.Close()
 		return nil, err
 	}
 
 	if fi.IsDir() {
+		_ = file.Close()
 		return nil, fmt.Errorf("invalid file body")
 	}
 
 	size := fi.Size()
-	if size <= offset {
+	if size <= offset || length == 0 {
+		_ = file.Close()
 		return NewNoBody(), nil
 	}
 
-	b := &fileBody{file: file, leftSize: size - offset, maxChunk: int64(maxChunk)}
+	if length < 0 || length > size-offset {
+		length = size - offset
+	}
+
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+
+	if maxChunk <= 0 {
+		maxChunk = defaultChunkSize
+	}
+
+	b := &fileBody{file: file, offset: offset, leftSize: length, maxChunk: int64(maxChunk)}
 	return b, nil
 }
 
@@ -37,6 +63,7 @@ func NewFileBody(filename string, offset int64, maxChunk int) (Body, error) {
 type fileBody struct {
 	noWriter
 	file     *os.File
+	offset   int64
 	leftSize int64
 	maxChunk int64
 }
@@ -60,39 +87,66 @@ func (b *fileBody) Read(p []byte) (int, error) {
 		return 0, io.EOF
 	}
 
+	if int64(len(p)) > b.leftSize {
+		p = p[:b.leftSize]
+	}
+
 	n, err := b.file.Read(p)
-	if err == io.EOF {
-		_ = b.file.Close()
-		b.file = nil
+	b.offset += int64(n)
+	b.leftSize -= int64(n)
+	if err == nil && b.leftSize == 0 {
+		err = io.EOF
+	}
+	if err != nil {
+		b.finish()
 	}
 	return n, err
 }
 
+// ReadFast 每次最多读取maxChunk,读取到末尾时同时返回数据和io.EOF
 func (b *fileBody) ReadFast(blocking bool) (bytex.Buffer, error) {
 	if b.file == nil {
 		return nil, io.EOF
 	}
 
-	var p []byte
-	if b.leftSize < int64(b.maxChunk) {
-		p = make([]byte, int(b.leftSize))
-	} else {
-		p = make([]byte, int(b.maxChunk))
+	size := b.maxChunk
+	if b.leftSize < size {
+		size = b.leftSize
 	}
-	n, err := b.file.Read(p)
-	if err != nil {
-		if err != io.EOF {
-			return nil, err
-		}
-		_ = b.file.Close()
-		b.file = nil
-		p = p[:n]
+
+	p := make([]byte, int(size))
+	n, err := io.ReadFull(b.file, p)
+	b.offset += int64(n)
+	b.leftSize -= int64(n)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		// 文件被截断,提前结束
+		err = io.EOF
+		b.finish()
+	case err != nil:
+		b.finish()
+		return nil, err
+	case b.leftSize == 0:
+		err = io.EOF
+		b.finish()
 	}
+
 	buff := bytex.NewBuffer()
-	_ = buff.Append(p)
+	_ = buff.Append(p[:n])
+	_, _ = buff.Seek(0, io.SeekStart)
 	return buff, err
 }
 
 func (b *fileBody) Buffer() (bytex.Buffer, error) {
 	return nil, ErrNotSupport
 }
+
+// Region 返回文件及剩余区间,直接发送文件时不会修改读取位置,发送完成后需要调用Close
+func (b *fileBody) Region() (*os.File, int64, int64) {
+	return b.file, b.offset, b.leftSize
+}
+
+func (b *fileBody) finish() {
+	b.leftSize = 0
+	_ = b.Close()
+}
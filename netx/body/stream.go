@@ -12,18 +12,27 @@ func NewStreamBody(first bytex.Buffer) Body {
 	b := &streamBody{}
 	b.cond = sync.NewCond(&b.mux)
 	if first != nil && first.Len() != 0 {
-		b.Write(first)
+		_ = b.Write(first)
 	}
 	return b
 }
 
+// NewLimitedStreamBody 创建限制缓存大小的流式body,缓存数据超过limit字节时Write会阻塞,直到数据被读取
+//	用于流式发送应答,读取方为连接的写协程,因此写入速度受限于网络发送速度
+func NewLimitedStreamBody(limit int) Body {
+	b := &streamBody{limit: limit}
+	b.cond = sync.NewCond(&b.mux)
+	return b
+}
+
 func newStreamNode(data bytex.Buffer) *streamNode {
-	return &streamNode{data: data}
+	return &streamNode{data: data, size: data.Len()}
 }
 
 type streamNode struct {
 	next *streamNode
 	data bytex.Buffer
+	size int
 }
 
 // streamBody 由Buffer单链表组成,用于接收流式消息体,当数据不全时,调用Read方法会阻塞
@@ -32,12 +41,17 @@ type streamBody struct {
 	cond   *sync.Cond  //
 	head   *streamNode //
 	tail   *streamNode //
+	size   int         // 缓存数据大小
+	limit  int         // 缓存上限,0表示不限制
 	ended  bool        // 标记是否传输完成
 	closed bool        // 关闭标识
 }
 
 func (b *streamBody) End() bool {
-	return b.ended
+	b.mux.Lock()
+	ended := b.ended
+	b.mux.Unlock()
+	return ended
 }
 
 func (b *streamBody) Close() error {
@@ -52,13 +66,14 @@ func (b *streamBody) Close() error {
 		needNotify = true
 		b.head = nil
 		b.tail = nil
+		b.size = 0
 		b.ended = true
 		b.closed = true
 	}
 
 	b.mux.Unlock()
 	if needNotify {
-		b.cond.Signal()
+		b.cond.Broadcast()
 	}
 
 	return nil
@@ -93,6 +108,9 @@ func (b *streamBody) Read(p []byte) (int, error) {
 
 	if count > 0 {
 		b.mux.Unlock()
+		if b.limit > 0 {
+			b.cond.Broadcast()
+		}
 		return count, nil
 	}
 
@@ -101,6 +119,7 @@ func (b *streamBody) Read(p []byte) (int, error) {
 		return 0, io.EOF
 	}
 
+	b.mux.Unlock()
 	return 0, nil
 }
 
@@ -121,6 +140,9 @@ func (b *streamBody) ReadFast(blocking bool) (bytex.Buffer, error) {
 	if b.head != nil {
 		data := b.popFront()
 		b.mux.Unlock()
+		if b.limit > 0 {
+			b.cond.Broadcast()
+		}
 		return data, nil
 	}
 
@@ -131,6 +153,7 @@ func (b *streamBody) ReadFast(blocking bool) (bytex.Buffer, error) {
 	}
 
 	// 不阻塞且没有数据
+	b.mux.Unlock()
 	return nil, nil
 }
 
@@ -150,17 +173,25 @@ func (b *streamBody) popFront() bytex.Buffer {
 		b.tail = nil
 	}
 	node.next = nil
+	b.size -= node.size
 	return data
 }
 
-// WriteBuffer 末尾追加数据
-func (b *streamBody) Write(data bytex.Buffer) {
+// Write 末尾追加数据,已经关闭时返回ErrClosed,超过缓存上限时阻塞
+func (b *streamBody) Write(data bytex.Buffer) error {
 	if data == nil {
-		return
+		return nil
 	}
 	b.mux.Lock()
+	for b.limit > 0 && b.size >= b.limit && !b.closed {
+		b.cond.Wait()
+	}
+	if b.closed {
+		b.mux.Unlock()
+		return ErrClosed
+	}
 	notify := false
-	if !b.ended && !b.closed {
+	if !b.ended {
 		node := newStreamNode(data)
 		if b.head == nil {
 			b.head = node
@@ -169,14 +200,16 @@ func (b *streamBody) Write(data bytex.Buffer) {
 			b.tail.next = node
 			b.tail = node
 		}
+		b.size += node.size
 
 		notify = true
 	}
 
 	b.mux.Unlock()
 	if notify {
-		b.cond.Signal()
+		b.cond.Broadcast()
 	}
+	return nil
 }
 
 func (b *streamBody) Flush() {
@@ -188,6 +221,6 @@ func (b *streamBody) Flush() {
 	}
 	b.mux.Unlock()
 	if notify {
-		b.cond.Signal()
+		b.cond.Broadcast()
 	}
 }
@@ -0,0 +1,58 @@
+package body
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+func TestStreamBody(t *testing.T) {
+	b := NewStreamBody(nil)
+	w, ok := b.(Writer)
+	if !ok {
+		t.Fatal("stream body should implement Writer")
+	}
+
+	// 没有数据时非阻塞读取需要释放锁
+	for i := 0; i < 2; i++ {
+		if data, err := b.ReadFast(false); data != nil || err != nil {
+			t.Fatalf("expect empty, %v %v", data, err)
+		}
+	}
+
+	buf := bytex.NewBuffer()
+	_ = buf.Append("hello")
+	_, _ = buf.Seek(0, io.SeekStart)
+	if err := w.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	data, err := b.ReadFast(true)
+	if err != nil || data.String() != "hello" {
+		t.Fatalf("bad read, %v %v", data, err)
+	}
+	if _, err := b.ReadFast(true); err != io.EOF {
+		t.Fatalf("expect EOF, %v", err)
+	}
+
+	_ = b.Close()
+	if err := w.Write(buf); err != ErrClosed {
+		t.Fatalf("expect ErrClosed, %v", err)
+	}
+}
+
+// TestStreamEnd End可能与Write,Flush在不同协程中并发调用
+func TestStreamEnd(t *testing.T) {
+	b := NewStreamBody(nil)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !b.End() {
+		}
+	}()
+	b.(Writer).Flush()
+	wg.Wait()
+}
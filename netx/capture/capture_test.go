@@ -0,0 +1,137 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+func TestRecord(t *testing.T) {
+	out := &bytes.Buffer{}
+	w, err := NewWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := bytex.NewBuffer()
+	_ = payload.Append([]byte("hello"))
+	_, _ = payload.Seek(0, io.SeekStart)
+	header := netx.NewHeader()
+	header.Set("X-Log-Id", "1")
+	ident := &netx.Identifier{SeqID: 10, URI: "echo", Codec: uint32(netx.CodecTypeJson)}
+
+	now := time.Now()
+	records := []*Record{
+		{Kind: KindOpen, ConnID: 1, Time: now, Conn: &ConnInfo{Client: true, Tran: "gpc", Local: "a", Remote: "b"}},
+		{Kind: KindData, Dir: DirOut, ConnID: 1, Time: now, Data: []byte("raw")},
+		{Kind: KindFrame, Dir: DirIn, ConnID: 1, Time: now, Frame: netx.NewFrame(netx.FrameTypeHeader, true, 3, ident, header, payload)},
+		{Kind: KindClose, ConnID: 1, Time: now},
+	}
+	for _, r := range records {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Size() != int64(out.Len()) {
+		t.Fatalf("invalid size, %d != %d", w.Size(), out.Len())
+	}
+
+	r, err := NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*Record
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec)
+	}
+
+	if len(got) != len(records) {
+		t.Fatalf("invalid records, %d", len(got))
+	}
+	if info := got[0].Conn; info == nil || !info.Client || info.Remote != "b" {
+		t.Fatalf("invalid conn info, %+v", info)
+	}
+	if string(got[1].Data) != "raw" || got[1].Dir != DirOut {
+		t.Fatalf("invalid data, %+v", got[1])
+	}
+	f := got[2].Frame
+	if f.StreamID() != 3 || !f.EndFlag() || f.Identifier().SeqID != 10 || f.Identifier().URI != "echo" {
+		t.Fatalf("invalid frame, %+v", f.Identifier())
+	}
+	if f.Header().Get("X-Log-Id") != "1" || f.Payload().String() != "hello" {
+		t.Fatalf("invalid frame header or payload")
+	}
+	if !got[3].Time.Equal(time.Unix(0, now.UnixNano())) {
+		t.Fatalf("invalid time")
+	}
+}
+
+func TestInvalidFile(t *testing.T) {
+	if _, err := NewReader(bytes.NewReader([]byte("bad"))); err != ErrInvalidFile {
+		t.Fatalf("expect invalid file, %+v", err)
+	}
+}
+
+// closeConn 仅用于触发Filter回调
+type closeConn struct {
+	netx.Conn
+	attrs netx.AttributeMap
+}
+
+func (c *closeConn) ID() uint32                    { return 1 }
+func (c *closeConn) Tran() netx.Tran               { return nil }
+func (c *closeConn) IsClient() bool                { return false }
+func (c *closeConn) LocalAddr() string             { return "a" }
+func (c *closeConn) RemoteAddr() string            { return "b" }
+func (c *closeConn) Attributes() netx.AttributeMap { return c.attrs }
+
+// TestClose 连接关闭时记录Close,重复关闭只记录一次
+func TestClose(t *testing.T) {
+	out := &bytes.Buffer{}
+	w, err := NewWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc := netx.NewFilterChain()
+	fc.AddLast(NewFilter(w))
+	conn := &closeConn{attrs: netx.NewAttributeMap()}
+	fc.HandleOpen(conn)
+	fc.HandleClose(conn)
+	fc.HandleClose(conn)
+	_ = w.Close()
+
+	r, err := NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []Kind
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, rec.Kind)
+	}
+	if len(kinds) != 2 || kinds[0] != KindOpen || kinds[1] != KindClose {
+		t.Fatalf("invalid records, %v", kinds)
+	}
+}
@@ -0,0 +1,227 @@
+// Package capture 按连接记录流量到文件,用于离线分析和回放,复现线上协议问题
+//	Filter需要位于FilterChain最前边,这样读取时记录的是解析前的原始数据,写入时记录的是编码后的数据
+//	server.New(server.WithTranFactory(func() netx.Tran {
+//		tran := transport.New()
+//		tran.AddFilters(capture.NewFilter(w, capture.WithRate(0.01)))
+//		return tran
+//	}))
+//	记录的文件可以通过replay包回放到目标服务,并对比应答
+package capture
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/pkg/unique"
+)
+
+var kConnKeyCapture = unique.NewKey(netx.KeyGroupConn, "capture")
+
+// NewFilter 创建记录流量的Filter,所有连接共用同一个Writer
+func NewFilter(w *Writer, opts ...Option) netx.Filter {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Mode == ModeFrame && o.Protocol == nil {
+		o.Protocol = rpc.New()
+	}
+
+	return &filter{w: w, opts: o}
+}
+
+type filter struct {
+	netx.BaseFilter
+	w    *Writer
+	opts *Options
+}
+
+// connState 连接记录状态
+type connState struct {
+	mux       sync.Mutex
+	sampled   bool     // 是否命中采样
+	truncated bool     // 是否超过单连接上限
+	size      int64    // 已记录字节数
+	pending   int      // 读缓冲中已经记录但尚未解析的字节数
+	in        *Decoder // ModeFrame时解析读取的数据
+	out       *Decoder // ModeFrame时解析写入的数据
+}
+
+func (f *filter) Name() string {
+	return "capture"
+}
+
+func (f *filter) HandleOpen(ctx netx.FilterCtx) error {
+	conn := ctx.Conn()
+	st := &connState{}
+	if rate := f.opts.Rate; rate <= 0 || rate >= 1 || rand.Float64() < rate {
+		st.sampled = !f.full()
+	}
+	if st.sampled && f.opts.Mode == ModeFrame {
+		st.in = NewDecoder(f.opts.Protocol)
+		st.out = NewDecoder(f.opts.Protocol)
+	}
+	// 读协程可能已经在运行,需要初始化完成后再保存
+	conn.Attributes().Put(kConnKeyCapture, st)
+	if !st.sampled {
+		return nil
+	}
+
+	tran := ""
+	if t := conn.Tran(); t != nil {
+		tran = t.String()
+	}
+	f.write(&Record{
+		Kind:   KindOpen,
+		ConnID: conn.ID(),
+		Time:   time.Now(),
+		Conn:   &ConnInfo{Client: conn.IsClient(), Tran: tran, Local: conn.LocalAddr(), Remote: conn.RemoteAddr()},
+	})
+
+	return nil
+}
+
+func (f *filter) HandleClose(ctx netx.FilterCtx) error {
+	conn := ctx.Conn()
+	st := f.state(conn)
+	if st == nil {
+		return nil
+	}
+
+	conn.Attributes().Remove(kConnKeyCapture)
+	f.write(&Record{Kind: KindClose, ConnID: conn.ID(), Time: time.Now()})
+	_ = f.w.Flush()
+	return nil
+}
+
+// HandleRead 读缓冲中可能残留上次未解析完的数据,只记录新增部分,后续Filter处理完成后再更新残留大小
+func (f *filter) HandleRead(ctx netx.FilterCtx) error {
+	conn := ctx.Conn()
+	st := f.state(conn)
+	data, ok := ctx.Data().(bytex.Buffer)
+	if st == nil || !ok {
+		return nil
+	}
+
+	if n := data.Len(); n > st.pending {
+		chunk := make([]byte, n-st.pending)
+		_, _ = data.Seek(int64(st.pending), io.SeekStart)
+		_, _ = data.Peek(chunk)
+		_, _ = data.Seek(0, io.SeekStart)
+		f.record(conn, st, DirIn, chunk)
+	}
+
+	err := ctx.Next()
+	st.pending = data.Len()
+	return err
+}
+
+// HandleWrite 数据真正写入socket时才记录,保证顺序和时间与实际一致
+func (f *filter) HandleWrite(ctx netx.FilterCtx) error {
+	conn := ctx.Conn()
+	st := f.state(conn)
+	if st == nil {
+		return nil
+	}
+
+	if wt, ok := ctx.Data().(netx.WriterTo); ok {
+		ctx.SetData(&teeWriter{WriterTo: wt, f: f, conn: conn, st: st})
+	}
+
+	return nil
+}
+
+// state 返回采样命中的连接状态
+func (f *filter) state(conn netx.Conn) *connState {
+	st, _ := conn.Attributes().Get(kConnKeyCapture, nil).(*connState)
+	if st == nil || !st.sampled {
+		return nil
+	}
+
+	return st
+}
+
+func (f *filter) full() bool {
+	return f.opts.MaxBytes > 0 && f.w.Size() >= f.opts.MaxBytes
+}
+
+func (f *filter) write(r *Record) {
+	if f.full() {
+		return
+	}
+
+	_ = f.w.Write(r)
+}
+
+func (f *filter) record(conn netx.Conn, st *connState, dir Direction, data []byte) {
+	st.mux.Lock()
+	defer st.mux.Unlock()
+	if st.truncated || len(data) == 0 {
+		return
+	}
+	if f.opts.Mode == ModeFrame && st.in == nil {
+		return
+	}
+
+	if max := f.opts.MaxConnBytes; max > 0 && st.size+int64(len(data)) > max {
+		st.truncated = true
+		f.write(&Record{Kind: KindTruncate, Dir: dir, ConnID: conn.ID(), Time: time.Now()})
+		return
+	}
+	st.size += int64(len(data))
+
+	now := time.Now()
+	if f.opts.Mode == ModeRaw {
+		f.write(&Record{Kind: KindData, Dir: dir, ConnID: conn.ID(), Time: now, Data: data})
+		return
+	}
+
+	dec := st.in
+	if dir == DirOut {
+		dec = st.out
+	}
+
+	frames, err := dec.Decode(data)
+	for _, frame := range frames {
+		f.write(&Record{Kind: KindFrame, Dir: dir, ConnID: conn.ID(), Time: now, Frame: frame})
+		frame.Recycle()
+	}
+
+	// 无法解析时不再记录该连接
+	if err != nil {
+		st.truncated = true
+		f.write(&Record{Kind: KindTruncate, Dir: dir, ConnID: conn.ID(), Time: now})
+	}
+}
+
+// teeWriter 写入socket的同时记录数据
+type teeWriter struct {
+	netx.WriterTo
+	f    *filter
+	conn netx.Conn
+	st   *connState
+}
+
+func (t *teeWriter) WriteTo(w io.Writer) (int64, error) {
+	return t.WriterTo.WriteTo(&teeOut{w: w, t: t})
+}
+
+type teeOut struct {
+	w io.Writer
+	t *teeWriter
+}
+
+func (o *teeOut) Write(p []byte) (int, error) {
+	n, err := o.w.Write(p)
+	if n > 0 {
+		o.t.f.record(o.t.conn, o.t.st, DirOut, p[:n])
+	}
+
+	return n, err
+}
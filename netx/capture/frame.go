@@ -0,0 +1,201 @@
+package capture
+
+import (
+	"errors"
+	"io"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+const (
+	identResponse = 0x01
+	identOneway   = 0x02
+)
+
+// 帧格式
+//	Type[1Byte] End[1Byte] StreamID[Varint] HasIdent[1Byte] *Identifier Header Payload
+func encodeFrame(w bytex.Buffer, f netx.Frame) {
+	_ = w.WriteByte(byte(f.Type()))
+	_ = bytex.WriteBool(w, f.EndFlag())
+	_ = bytex.WriteUvarint32(w, f.StreamID())
+
+	ident := f.Identifier()
+	_ = bytex.WriteBool(w, ident != nil)
+	if ident != nil {
+		var flags byte
+		if ident.IsResponse {
+			flags |= identResponse
+		}
+		if ident.IsOneway {
+			flags |= identOneway
+		}
+		_ = w.WriteByte(flags)
+		_ = bytex.WriteUvarint64(w, uint64(ident.Version))
+		_ = bytex.WriteUvarint32(w, ident.SeqID)
+		_ = bytex.WriteUvarint32(w, ident.CmdID)
+		_ = w.WriteByte(byte(ident.Method))
+		_ = bytex.WriteString(w, ident.Service)
+		_ = bytex.WriteString(w, ident.URI)
+		_ = bytex.WriteUvarint32(w, ident.Codec)
+		_ = bytex.WriteVarint32(w, ident.StatusCode)
+		_ = bytex.WriteString(w, ident.StatusInfo)
+	}
+
+	header := f.Header()
+	_ = bytex.WriteUvarint32(w, uint32(header.Len()))
+	for _, p := range header {
+		_ = bytex.WriteString(w, p.Key)
+		_ = bytex.WriteUvarint32(w, uint32(len(p.Values)))
+		for _, v := range p.Values {
+			_ = bytex.WriteString(w, v)
+		}
+	}
+
+	if payload := f.Payload(); payload != nil {
+		_ = w.Append(copyBytes(payload))
+	}
+}
+
+func decodeFrame(buf bytex.Buffer) (netx.Frame, error) {
+	ftype, err := buf.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	end, err := bytex.ReadBool(buf)
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	var streamId uint32
+	if err := bytex.ReadUvarint32(buf, &streamId); err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	hasIdent, err := bytex.ReadBool(buf)
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	var ident *netx.Identifier
+	if hasIdent {
+		ident, err = decodeIdent(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var count uint32
+	if err := bytex.ReadUvarint32(buf, &count); err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	var header netx.Header
+	for i := uint32(0); i < count; i++ {
+		var key string
+		var num uint32
+		if bytex.ReadString(buf, &key) != nil || bytex.ReadUvarint32(buf, &num) != nil {
+			return nil, ErrInvalidRecord
+		}
+		values := make([]string, num)
+		for j := range values {
+			if err := bytex.ReadString(buf, &values[j]); err != nil {
+				return nil, ErrInvalidRecord
+			}
+		}
+		header.SetValues(key, values)
+	}
+
+	var payload bytex.Buffer
+	if buf.Available() > 0 {
+		payload = bytex.NewBuffer()
+		_ = payload.Append(copyBytes(buf))
+		_, _ = payload.Seek(0, io.SeekStart)
+	}
+
+	return netx.NewFrame(netx.FrameType(ftype), end, streamId, ident, header, payload), nil
+}
+
+func decodeIdent(buf bytex.Buffer) (*netx.Identifier, error) {
+	ident := netx.NewIdentifier()
+	flags, err := buf.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	ident.IsResponse = flags&identResponse != 0
+	ident.IsOneway = flags&identOneway != 0
+
+	var version uint64
+	if err := bytex.ReadUvarint64(buf, &version); err != nil {
+		return nil, ErrInvalidRecord
+	}
+	ident.Version = uint(version)
+	if bytex.ReadUvarint32(buf, &ident.SeqID) != nil || bytex.ReadUvarint32(buf, &ident.CmdID) != nil {
+		return nil, ErrInvalidRecord
+	}
+	method, err := buf.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	ident.Method = netx.Method(method)
+	if bytex.ReadString(buf, &ident.Service) != nil || bytex.ReadString(buf, &ident.URI) != nil {
+		return nil, ErrInvalidRecord
+	}
+	if bytex.ReadUvarint32(buf, &ident.Codec) != nil || bytex.ReadVarint32(buf, &ident.StatusCode) != nil {
+		return nil, ErrInvalidRecord
+	}
+	if err := bytex.ReadString(buf, &ident.StatusInfo); err != nil {
+		return nil, ErrInvalidRecord
+	}
+
+	return ident, nil
+}
+
+// copyBytes 复制buf中未读取的数据,不改变读取位置
+func copyBytes(buf bytex.Buffer) []byte {
+	n := buf.Available()
+	if n == 0 {
+		return nil
+	}
+
+	data := make([]byte, n)
+	_, _ = buf.Peek(data)
+	return data
+}
+
+// NewDecoder 创建Decoder,proto不能依赖Conn中保存的状态,比如rpc
+func NewDecoder(proto netx.Protocol) *Decoder {
+	return &Decoder{proto: proto, buf: bytex.NewBuffer()}
+}
+
+// Decoder 将单向字节流解析为帧,用于frame模式记录以及回放raw模式的记录
+type Decoder struct {
+	proto netx.Protocol
+	buf   bytex.Buffer
+}
+
+// Decode 追加数据并返回所有完整的帧,不完整的数据会缓存到下次
+func (d *Decoder) Decode(data []byte) ([]netx.Frame, error) {
+	_, _ = d.buf.Seek(0, io.SeekEnd)
+	if _, err := d.buf.Write(data); err != nil {
+		return nil, err
+	}
+
+	var frames []netx.Frame
+	for {
+		_, _ = d.buf.Seek(0, io.SeekStart)
+		if d.buf.Available() == 0 {
+			return frames, nil
+		}
+
+		frame, err := d.proto.Decode(nil, d.buf)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// 长度字段不完整
+			return frames, nil
+		}
+		if err != nil || frame == nil {
+			return frames, err
+		}
+		d.buf.Discard()
+		frames = append(frames, frame)
+	}
+}
@@ -0,0 +1,51 @@
+package capture
+
+import "github.com/foredata/nova/netx"
+
+// Mode 记录方式
+type Mode uint8
+
+const (
+	ModeRaw   Mode = iota // 记录原始字节,适用于所有协议
+	ModeFrame             // 解析后记录帧,要求协议不依赖Conn中的状态,比如rpc
+)
+
+// Options 可选配置参数
+type Options struct {
+	Mode         Mode          // 记录方式,默认ModeRaw
+	Protocol     netx.Protocol // ModeFrame时用于解析帧
+	Rate         float64       // 连接采样比例(0,1],默认全部记录
+	MaxConnBytes int64         // 单连接最多记录字节数,超过后记录KindTruncate并不再记录,0表示不限制
+	MaxBytes     int64         // 文件最大字节数,超过后不再记录,0表示不限制
+}
+
+type Option func(o *Options)
+
+// WithFrame 解析后记录帧
+func WithFrame(proto netx.Protocol) Option {
+	return func(o *Options) {
+		o.Mode = ModeFrame
+		o.Protocol = proto
+	}
+}
+
+// WithRate 设置连接采样比例
+func WithRate(rate float64) Option {
+	return func(o *Options) {
+		o.Rate = rate
+	}
+}
+
+// WithMaxConnBytes 设置单连接最多记录字节数
+func WithMaxConnBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxConnBytes = n
+	}
+}
+
+// WithMaxBytes 设置文件最大字节数
+func WithMaxBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxBytes = n
+	}
+}
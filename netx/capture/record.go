@@ -0,0 +1,317 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// some error
+var (
+	ErrInvalidFile   = errors.New("capture: invalid capture file")
+	ErrInvalidRecord = errors.New("capture: invalid record")
+)
+
+// 文件格式
+//	Magic[4Bytes] Version[1Byte] Record*
+//	Record: Length[Varint] Kind[1Byte] Dir[1Byte] ConnID[Varint] Time[Varint,UnixNano] Body
+var fileMagic = [4]byte{'N', 'C', 'A', 'P'}
+
+const fileVersion = 1
+
+// Kind 记录类型
+type Kind uint8
+
+const (
+	KindOpen     Kind = iota + 1 // 建立连接,Body为ConnInfo
+	KindClose                    // 关闭连接
+	KindData                     // 原始字节
+	KindFrame                    // 解析后的帧
+	KindTruncate                 // 连接记录超过上限,之后的数据不再记录
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindOpen:
+		return "open"
+	case KindClose:
+		return "close"
+	case KindData:
+		return "data"
+	case KindFrame:
+		return "frame"
+	case KindTruncate:
+		return "truncate"
+	default:
+		return "unknown"
+	}
+}
+
+// Direction 数据方向,相对于记录方
+type Direction uint8
+
+const (
+	DirIn  Direction = iota // 读取的数据
+	DirOut                  // 写出的数据
+)
+
+func (d Direction) String() string {
+	if d == DirIn {
+		return "in"
+	}
+	return "out"
+}
+
+// ConnInfo 连接元信息
+type ConnInfo struct {
+	Client bool   // 是否是Dial建立的连接
+	Tran   string // transport名
+	Local  string // 本地地址
+	Remote string // 远程地址
+}
+
+// Record 一条记录
+type Record struct {
+	Kind   Kind
+	Dir    Direction
+	ConnID uint32
+	Time   time.Time
+	Conn   *ConnInfo  // KindOpen
+	Data   []byte     // KindData
+	Frame  netx.Frame // KindFrame
+}
+
+// Create 创建capture文件
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+// NewWriter 创建Writer,并写入文件头,线程安全
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(fileMagic[:]); err != nil {
+		return nil, err
+	}
+	if err := bw.WriteByte(fileVersion); err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: bw, size: int64(len(fileMagic) + 1)}, nil
+}
+
+// Writer 写入记录
+type Writer struct {
+	mux    sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	size   int64
+}
+
+// Size 已写入字节数
+func (w *Writer) Size() int64 {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.size
+}
+
+// Write 写入一条记录
+func (w *Writer) Write(r *Record) error {
+	body := bytex.NewBuffer()
+	defer body.Clear()
+	if err := encodeRecord(body, r); err != nil {
+		return err
+	}
+
+	var head [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(head[:], uint64(body.Len()))
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if _, err := w.w.Write(head[:n]); err != nil {
+		return err
+	}
+	_, _ = body.Seek(0, io.SeekStart)
+	if _, err := body.WriteTo(w.w); err != nil {
+		return err
+	}
+	w.size += int64(n + body.Len())
+	return nil
+}
+
+// Flush 将缓存数据写入底层
+func (w *Writer) Flush() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.w.Flush()
+}
+
+// Close 写入缓存数据,若通过Create创建则同时关闭文件
+func (w *Writer) Close() error {
+	err := w.Flush()
+	if w.closer != nil {
+		if e := w.closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Open 打开capture文件
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewReader 创建Reader,并校验文件头
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	var head [len(fileMagic) + 1]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		return nil, ErrInvalidFile
+	}
+	if [4]byte{head[0], head[1], head[2], head[3]} != fileMagic {
+		return nil, ErrInvalidFile
+	}
+	if head[4] != fileVersion {
+		return nil, fmt.Errorf("capture: unsupported version %d", head[4])
+	}
+
+	return &Reader{r: br}, nil
+}
+
+// Reader 顺序读取记录
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+}
+
+// Next 读取下一条记录,结束时返回io.EOF
+func (r *Reader) Next() (*Record, error) {
+	size, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	buf := bytex.NewBuffer()
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	return decodeRecord(buf, data)
+}
+
+// Close 若通过Open创建则关闭文件
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+
+	return nil
+}
+
+func encodeRecord(w bytex.Buffer, r *Record) error {
+	_ = w.WriteByte(byte(r.Kind))
+	_ = w.WriteByte(byte(r.Dir))
+	_ = bytex.WriteUvarint32(w, r.ConnID)
+	_ = bytex.WriteVarint64(w, r.Time.UnixNano())
+
+	switch r.Kind {
+	case KindOpen:
+		info := r.Conn
+		if info == nil {
+			info = &ConnInfo{}
+		}
+		_ = bytex.WriteBool(w, info.Client)
+		_ = bytex.WriteString(w, info.Tran)
+		_ = bytex.WriteString(w, info.Local)
+		_ = bytex.WriteString(w, info.Remote)
+	case KindData:
+		_, _ = w.Write(r.Data)
+	case KindFrame:
+		if r.Frame == nil {
+			return ErrInvalidRecord
+		}
+		encodeFrame(w, r.Frame)
+	}
+
+	return nil
+}
+
+// decodeRecord data为buf对应的原始数据,用于直接截取Data
+func decodeRecord(buf bytex.Buffer, data []byte) (*Record, error) {
+	r := &Record{}
+	kind, err := buf.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	dir, err := buf.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidRecord
+	}
+	r.Kind = Kind(kind)
+	r.Dir = Direction(dir)
+	if err := bytex.ReadUvarint32(buf, &r.ConnID); err != nil {
+		return nil, ErrInvalidRecord
+	}
+	var nano int64
+	if err := bytex.ReadVarint64(buf, &nano); err != nil {
+		return nil, ErrInvalidRecord
+	}
+	r.Time = time.Unix(0, nano)
+
+	switch r.Kind {
+	case KindOpen:
+		info := &ConnInfo{}
+		if info.Client, err = bytex.ReadBool(buf); err != nil {
+			return nil, ErrInvalidRecord
+		}
+		if bytex.ReadString(buf, &info.Tran) != nil || bytex.ReadString(buf, &info.Local) != nil || bytex.ReadString(buf, &info.Remote) != nil {
+			return nil, ErrInvalidRecord
+		}
+		r.Conn = info
+	case KindData:
+		r.Data = data[buf.Pos():]
+	case KindFrame:
+		if r.Frame, err = decodeFrame(buf); err != nil {
+			return nil, err
+		}
+	case KindClose, KindTruncate:
+	default:
+		return nil, ErrInvalidRecord
+	}
+
+	return r, nil
+}
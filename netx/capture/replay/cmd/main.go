@@ -0,0 +1,70 @@
+// 回放capture文件中的rpc请求,并输出与记录应答不一致的请求
+//	go run ./netx/capture/replay/cmd -file rpc.cap -target 127.0.0.1:8080
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/foredata/nova/netx/capture"
+	"github.com/foredata/nova/netx/capture/replay"
+	"github.com/foredata/nova/netx/protocol/rpc"
+)
+
+func main() {
+	file := flag.String("file", "", "capture file")
+	target := flag.String("target", "", "target address")
+	concurrency := flag.Int("concurrency", 1, "max concurrent requests")
+	speed := flag.Float64("speed", 0, "replay speed relative to capture, 0 means as fast as possible")
+	timeout := flag.Duration("timeout", time.Second*3, "timeout per request")
+	headers := flag.String("headers", "", "comma separated response headers to compare")
+	flag.Parse()
+
+	if *file == "" || *target == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r, err := capture.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open capture fail, %+v\n", err)
+		os.Exit(1)
+	}
+	exchanges, err := replay.Load(r, rpc.New())
+	_ = r.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load capture fail, %+v\n", err)
+		os.Exit(1)
+	}
+
+	opts := []replay.Option{
+		replay.WithTarget(*target),
+		replay.WithConcurrency(*concurrency),
+		replay.WithSpeed(*speed),
+		replay.WithTimeout(*timeout),
+	}
+	if *headers != "" {
+		opts = append(opts, replay.WithHeaders(strings.Split(*headers, ",")...))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	report, err := replay.Run(ctx, exchanges, opts...)
+	if err != nil && report == nil {
+		fmt.Fprintf(os.Stderr, "replay fail, %+v\n", err)
+		os.Exit(1)
+	}
+
+	for _, d := range report.Diffs {
+		fmt.Println(d.String())
+	}
+	fmt.Printf("total=%d matched=%d mismatched=%d failed=%d\n", report.Total, report.Matched, report.Mismatched, report.Failed)
+	if report.Mismatched > 0 || report.Failed > 0 {
+		os.Exit(1)
+	}
+}
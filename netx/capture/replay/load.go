@@ -0,0 +1,173 @@
+package replay
+
+import (
+	"io"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/capture"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// Message 由帧组装成的完整消息
+type Message struct {
+	ConnID  uint32
+	Time    time.Time
+	Ident   netx.Identifier
+	Header  netx.Header
+	Body    []byte
+	Trailer netx.Header
+}
+
+// Exchange 一次请求及记录到的应答,oneway或者应答未被记录时Response为nil
+type Exchange struct {
+	Request  *Message
+	Response *Message
+}
+
+// Load 读取所有记录,按请求顺序返回,应答通过连接和SeqID匹配
+//	proto用于解析raw记录,frame记录不需要
+func Load(r *capture.Reader, proto netx.Protocol) ([]*Exchange, error) {
+	l := &loader{
+		proto:     proto,
+		decoders:  make(map[dirKey]*capture.Decoder),
+		truncated: make(map[dirKey]bool),
+		streams:   make(map[streamKey]*Message),
+		pending:   make(map[seqKey]*Exchange),
+	}
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return l.exchanges, err
+		}
+
+		l.handle(rec)
+	}
+
+	return l.exchanges, nil
+}
+
+type dirKey struct {
+	conn uint32
+	dir  capture.Direction
+}
+
+type streamKey struct {
+	dirKey
+	stream uint32
+}
+
+type seqKey struct {
+	conn uint32
+	seq  uint32
+}
+
+type loader struct {
+	proto     netx.Protocol
+	decoders  map[dirKey]*capture.Decoder
+	truncated map[dirKey]bool
+	streams   map[streamKey]*Message
+	pending   map[seqKey]*Exchange
+	exchanges []*Exchange
+}
+
+func (l *loader) handle(rec *capture.Record) {
+	key := dirKey{conn: rec.ConnID, dir: rec.Dir}
+	switch rec.Kind {
+	case capture.KindData:
+		if l.truncated[key] || l.proto == nil {
+			return
+		}
+		dec := l.decoders[key]
+		if dec == nil {
+			dec = capture.NewDecoder(l.proto)
+			l.decoders[key] = dec
+		}
+		frames, err := dec.Decode(rec.Data)
+		for _, f := range frames {
+			l.onFrame(key, rec.Time, f)
+		}
+		// 无法继续解析,丢弃该方向后续数据
+		if err != nil {
+			l.truncated[key] = true
+		}
+	case capture.KindFrame:
+		if !l.truncated[key] {
+			l.onFrame(key, rec.Time, rec.Frame)
+		}
+	case capture.KindTruncate:
+		l.truncated[key] = true
+	case capture.KindClose:
+		for _, dir := range []capture.Direction{capture.DirIn, capture.DirOut} {
+			k := dirKey{conn: rec.ConnID, dir: dir}
+			delete(l.decoders, k)
+			delete(l.truncated, k)
+		}
+	}
+}
+
+func (l *loader) onFrame(key dirKey, at time.Time, f netx.Frame) {
+	skey := streamKey{dirKey: key, stream: f.StreamID()}
+	switch f.Type() {
+	case netx.FrameTypeHeader:
+		ident := f.Identifier()
+		if ident == nil {
+			return
+		}
+		msg := &Message{ConnID: key.conn, Time: at, Ident: *ident, Header: f.Header(), Body: toBytes(f.Payload())}
+		if f.EndFlag() {
+			l.complete(msg)
+		} else {
+			l.streams[skey] = msg
+		}
+	case netx.FrameTypeData:
+		msg := l.streams[skey]
+		if msg == nil {
+			return
+		}
+		msg.Body = append(msg.Body, toBytes(f.Payload())...)
+		if f.EndFlag() {
+			delete(l.streams, skey)
+			l.complete(msg)
+		}
+	case netx.FrameTypeTrailer:
+		msg := l.streams[skey]
+		if msg == nil {
+			return
+		}
+		msg.Trailer = f.Trailer()
+		delete(l.streams, skey)
+		l.complete(msg)
+	}
+}
+
+func (l *loader) complete(msg *Message) {
+	key := seqKey{conn: msg.ConnID, seq: msg.Ident.SeqID}
+	if !msg.Ident.IsResponse {
+		ex := &Exchange{Request: msg}
+		l.exchanges = append(l.exchanges, ex)
+		if !msg.Ident.IsOneway {
+			l.pending[key] = ex
+		}
+		return
+	}
+
+	if ex := l.pending[key]; ex != nil {
+		ex.Response = msg
+		delete(l.pending, key)
+	}
+}
+
+func toBytes(buf bytex.Buffer) []byte {
+	if buf == nil || buf.Available() == 0 {
+		return nil
+	}
+
+	data := make([]byte, buf.Available())
+	_, _ = buf.Peek(data)
+	return data
+}
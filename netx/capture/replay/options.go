@@ -0,0 +1,69 @@
+package replay
+
+import (
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Options 回放配置
+type Options struct {
+	Target      string        // 目标服务,作为请求的Service,必填
+	Client      netx.Client   // 发送请求的client,默认使用static服务发现直连Target
+	Protocol    netx.Protocol // 解析raw记录及client使用的协议,默认rpc
+	Concurrency int           // 最大并发数,默认1,即按顺序回放
+	Speed       float64       // 回放速度倍数,按记录的时间间隔发送,0表示不等待
+	Timeout     time.Duration // 单个请求超时,默认3s
+	Headers     []string      // 需要对比的应答header,默认只对比状态码和body
+}
+
+type Option func(o *Options)
+
+// WithTarget 设置目标服务
+func WithTarget(target string) Option {
+	return func(o *Options) {
+		o.Target = target
+	}
+}
+
+// WithClient 设置发送请求的client
+func WithClient(c netx.Client) Option {
+	return func(o *Options) {
+		o.Client = c
+	}
+}
+
+// WithProtocol 设置协议
+func WithProtocol(p netx.Protocol) Option {
+	return func(o *Options) {
+		o.Protocol = p
+	}
+}
+
+// WithConcurrency 设置最大并发数
+func WithConcurrency(n int) Option {
+	return func(o *Options) {
+		o.Concurrency = n
+	}
+}
+
+// WithSpeed 设置回放速度倍数
+func WithSpeed(v float64) Option {
+	return func(o *Options) {
+		o.Speed = v
+	}
+}
+
+// WithTimeout 设置单个请求超时
+func WithTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// WithHeaders 设置需要对比的应答header
+func WithHeaders(keys ...string) Option {
+	return func(o *Options) {
+		o.Headers = append(o.Headers, keys...)
+	}
+}
@@ -0,0 +1,237 @@
+// Package replay 将capture记录的请求重新发送到目标服务,并与记录的应答对比
+//	r, _ := capture.Open("rpc.cap")
+//	exchanges, _ := replay.Load(r, rpc.New())
+//	report, _ := replay.Run(ctx, exchanges, replay.WithTarget("127.0.0.1:8080"))
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/discovery/static"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// some error
+var (
+	ErrNoTarget = errors.New("replay: target is required")
+)
+
+// Report 回放结果
+type Report struct {
+	Total      int     // 回放请求数
+	Matched    int     // 应答一致,包括没有记录应答但调用成功的请求
+	Mismatched int     // 应答不一致
+	Failed     int     // 调用失败
+	Diffs      []*Diff // 不一致或者失败的详情,按请求顺序
+}
+
+// Diff 单个请求的对比结果
+type Diff struct {
+	Exchange *Exchange
+	Actual   *Message // 回放得到的应答,调用失败时为nil
+	Reason   string
+	Err      error
+}
+
+func (d *Diff) String() string {
+	ident := &d.Exchange.Request.Ident
+	if d.Err != nil {
+		return fmt.Sprintf("conn=%d seq=%d uri=%s: %s, %v", d.Exchange.Request.ConnID, ident.SeqID, ident.URI, d.Reason, d.Err)
+	}
+
+	return fmt.Sprintf("conn=%d seq=%d uri=%s: %s", d.Exchange.Request.ConnID, ident.SeqID, ident.URI, d.Reason)
+}
+
+// Run 回放请求,ctx取消时停止发送新请求
+func Run(ctx context.Context, exchanges []*Exchange, opts ...Option) (*Report, error) {
+	o := &Options{Concurrency: 1, Timeout: time.Second * 3}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Target == "" {
+		return nil, ErrNoTarget
+	}
+	if o.Protocol == nil {
+		o.Protocol = rpc.New()
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.Client == nil {
+		o.Client = client.New(client.WithResolver(static.New()), client.WithProtocol(o.Protocol))
+		defer o.Client.Close()
+	}
+
+	results := make([]*Diff, len(exchanges))
+	sem := make(chan struct{}, o.Concurrency)
+	wg := sync.WaitGroup{}
+	begin := time.Now()
+	sent := 0
+	var err error
+
+loop:
+	for i, ex := range exchanges {
+		if o.Speed > 0 {
+			delay := time.Duration(float64(ex.Request.Time.Sub(exchanges[0].Request.Time)) / o.Speed)
+			if wait := time.Until(begin.Add(delay)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					err = ctx.Err()
+					break loop
+				}
+			}
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		}
+
+		sent++
+		wg.Add(1)
+		go func(i int, ex *Exchange) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = replay(ctx, o, ex)
+		}(i, ex)
+	}
+	wg.Wait()
+
+	report := &Report{}
+	for _, d := range results[:sent] {
+		report.Total++
+		switch {
+		case d == nil:
+			report.Matched++
+		case d.Actual == nil:
+			report.Failed++
+			report.Diffs = append(report.Diffs, d)
+		default:
+			report.Mismatched++
+			report.Diffs = append(report.Diffs, d)
+		}
+	}
+
+	return report, err
+}
+
+// replay 发送单个请求,一致时返回nil
+func replay(ctx context.Context, o *Options, ex *Exchange) *Diff {
+	req := toRequest(ex.Request, o.Target)
+	if ex.Request.Ident.IsOneway {
+		_, err := o.Client.Call(ctx, req, client.WithCallTimeout(o.Timeout), client.WithCallback(func(netx.Response) error { return nil }))
+		if err != nil {
+			return &Diff{Exchange: ex, Reason: "call fail", Err: err}
+		}
+		return nil
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+	rsp, err := o.Client.Call(cctx, req, client.WithCallTimeout(o.Timeout))
+	if err != nil {
+		return &Diff{Exchange: ex, Reason: "call fail", Err: err}
+	}
+
+	actual := toMessage(rsp)
+	if ex.Response == nil {
+		return nil
+	}
+
+	if reason := compare(ex.Response, actual, o.Headers); reason != "" {
+		return &Diff{Exchange: ex, Actual: actual, Reason: reason}
+	}
+
+	return nil
+}
+
+func toRequest(msg *Message, target string) netx.Request {
+	req := netx.NewRequest()
+	ident := msg.Ident
+	ident.SeqID = 0
+	ident.Service = target
+	req.(netx.Packet).SetIdentifier(&ident)
+
+	header := netx.NewHeader()
+	header.Merge(msg.Header)
+	req.SetHeader(header)
+
+	if len(msg.Body) > 0 {
+		buf := bytex.NewBuffer()
+		_ = buf.Append(append([]byte(nil), msg.Body...))
+		_, _ = buf.Seek(0, io.SeekStart)
+		req.SetBody(body.NewBufferBody(buf))
+	}
+
+	return req
+}
+
+func toMessage(rsp netx.Response) *Message {
+	msg := &Message{Time: time.Now(), Header: rsp.Header()}
+	if p, ok := rsp.(netx.Packet); ok && p.Identifier() != nil {
+		msg.Ident = *p.Identifier()
+	} else {
+		msg.Ident.IsResponse = true
+		msg.Ident.StatusCode = rsp.StatusCode()
+	}
+
+	if bd := rsp.Body(); bd != nil {
+		if buf, err := bd.Buffer(); err == nil {
+			msg.Body = toBytes(buf)
+		}
+	}
+
+	return msg
+}
+
+// compare 对比应答,一致返回空字符串
+func compare(expect, actual *Message, headers []string) string {
+	if expect.Ident.StatusCode != actual.Ident.StatusCode {
+		return fmt.Sprintf("status %d != %d", expect.Ident.StatusCode, actual.Ident.StatusCode)
+	}
+
+	for _, key := range headers {
+		if ev, av := expect.Header.Get(key), actual.Header.Get(key); ev != av {
+			return fmt.Sprintf("header %s %q != %q", key, ev, av)
+		}
+	}
+
+	if !bytes.Equal(expect.Body, actual.Body) {
+		return fmt.Sprintf("body differs at offset %d, size %d != %d", mismatch(expect.Body, actual.Body), len(expect.Body), len(actual.Body))
+	}
+
+	return ""
+}
+
+// mismatch 返回第一个不同字节的位置
+func mismatch(a, b []byte) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+
+	return n
+}
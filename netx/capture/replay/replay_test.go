@@ -0,0 +1,100 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/capture"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/transport/memory"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+)
+
+type echoRequest struct {
+	Text string `json:"text"`
+}
+
+type echoResponse struct {
+	Text string `json:"text"`
+}
+
+func newEchoServer(t *testing.T, name, prefix string, opts ...server.Option) {
+	svr := memtest.NewServer(t, name, opts...)
+	svr.Register(&netx.Route{Name: "echo", Handler: func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		return &echoResponse{Text: prefix + req.Text}, nil
+	}})
+}
+
+func TestReplay(t *testing.T) {
+	t.Run("raw", func(t *testing.T) { testReplay(t, capture.WithRate(1)) })
+	t.Run("frame", func(t *testing.T) { testReplay(t, capture.WithFrame(rpc.New())) })
+}
+
+func testReplay(t *testing.T, mode capture.Option) {
+	out := &bytes.Buffer{}
+	w, err := capture.NewWriter(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	factory := func() netx.Tran {
+		tran := memory.New()
+		tran.AddFilters(capture.NewFilter(w, mode))
+		return tran
+	}
+	newEchoServer(t, "replay.origin", "echo:", server.WithTranFactory(factory))
+	newEchoServer(t, "replay.same", "echo:")
+	newEchoServer(t, "replay.changed", "changed:")
+
+	cli := memtest.NewClient(t, client.WithProtocol(rpc.New()))
+	for _, text := range []string{"a", "b", "c"} {
+		req := netx.NewRequest()
+		req.SetService(memtest.Addr("replay.origin"))
+		req.SetURI("echo")
+		if err := req.Encode(netx.CodecTypeJson, &echoRequest{Text: text}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cli.Call(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = w.Flush()
+
+	r, err := capture.NewReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchanges, err := Load(r, rpc.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exchanges) != 3 {
+		t.Fatalf("invalid exchanges, %d", len(exchanges))
+	}
+	for _, ex := range exchanges {
+		if ex.Response == nil {
+			t.Fatalf("response not captured")
+		}
+	}
+
+	report, err := Run(context.Background(), exchanges, WithClient(cli), WithTarget(memtest.Addr("replay.same")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || report.Matched != 3 {
+		t.Fatalf("expect all matched, %+v", report)
+	}
+
+	report, err = Run(context.Background(), exchanges, WithClient(cli), WithTarget(memtest.Addr("replay.changed")), WithConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Mismatched != 3 || len(report.Diffs) != 3 {
+		t.Fatalf("expect all mismatched, %+v", report)
+	}
+	t.Log(report.Diffs[0].String())
+}
@@ -38,7 +38,10 @@ type callInfo struct {
 }
 
 func (ci *callInfo) Recyle() {
-	ci.Retryer.Recyle()
+	if ci.Retryer != nil {
+		ci.Retryer.Recyle()
+		ci.Retryer = nil
+	}
 	gCallPool.Put(ci)
 }
 
@@ -120,7 +123,7 @@ func (c *caller) onTimeout(data interface{}) {
 
 	delete(c.infos, seqId)
 
-	if !info.Retryer.Allow() {
+	if info.Retryer == nil || !info.Retryer.Allow() {
 		callback := info.Callback
 		info.Recyle()
 		c.mux.Unlock()
@@ -3,8 +3,10 @@ package client
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/netx/loadbalance"
 
 	// 强制注册codec
@@ -30,6 +32,44 @@ type client struct {
 }
 
 func (c *client) Call(ctx context.Context, req netx.Request, opts ...netx.CallOption) (netx.Response, error) {
+	if len(c.opts.Middlewares) == 0 {
+		return c.invoke(ctx, req, opts...)
+	}
+
+	endpoint := func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return c.invoke(ctx, req, opts...)
+	}
+	return netx.Apply(endpoint, c.opts.Middlewares)(ctx, req)
+}
+
+// invoke 执行调用,开启流量镜像时同时发起影子请求
+func (c *client) invoke(ctx context.Context, req netx.Request, opts ...netx.CallOption) (netx.Response, error) {
+	if c.opts.mirror == nil {
+		return c.call(ctx, req, opts...)
+	}
+
+	// 影子请求异步执行,这里只负责采样和通知主调用结果
+	primary := c.opts.mirror.start(c, req)
+	begin := time.Now()
+	rsp, err := c.call(ctx, req, opts...)
+	if primary != nil {
+		primary <- mirrorResult{status: toStatus(rsp, err), latency: time.Since(begin)}
+	}
+
+	return rsp, err
+}
+
+func (c *client) call(ctx context.Context, req netx.Request, opts ...netx.CallOption) (netx.Response, error) {
+	service := req.Service()
+	if c.opts.Proxy != "" {
+		service = c.opts.Proxy
+	}
+
+	return c.callService(ctx, req, service, opts...)
+}
+
+// callService 向指定服务发起调用,不再经过Proxy改写,影子请求需直接发往镜像服务
+func (c *client) callService(ctx context.Context, req netx.Request, service string, opts ...netx.CallOption) (netx.Response, error) {
 	o := newCallOptions(opts...)
 	if o.DialTimeout == 0 {
 		o.DialTimeout = c.opts.Config.GetDialTimeout(ctx, req)
@@ -42,9 +82,19 @@ func (c *client) Call(ctx context.Context, req netx.Request, opts ...netx.CallOp
 		req.SetSeqID(netx.NewSeqID())
 	}
 
-	service := req.Service()
-	if c.opts.Proxy != "" {
-		service = c.opts.Proxy
+	c.setCompress(req, o)
+
+	// 透传超时时间,server可据此丢弃已经超时的请求
+	if timeout := o.CallTimeout; timeout > 0 && netx.GetTimeout(req.Header()) == 0 {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+			timeout = time.Until(deadline)
+		}
+		// header精度为毫秒,不足1ms时不透传,避免server解析为0或负数
+		if timeout >= time.Millisecond {
+			h := req.Header()
+			netx.SetTimeout(&h, timeout)
+			req.SetHeader(h)
+		}
 	}
 
 	picker, err := c.resolve(ctx, service)
@@ -52,6 +102,11 @@ func (c *client) Call(ctx context.Context, req netx.Request, opts ...netx.CallOp
 		return nil, err
 	}
 
+	// oneway请求不需要等待应答
+	if req.IsOneway() {
+		return nil, c.send(ctx, picker, req, o)
+	}
+
 	callback, future := toCallback(o.Callback)
 	if callback == nil {
 		return nil, ErrInvalidCallback
@@ -66,12 +121,7 @@ func (c *client) Call(ctx context.Context, req netx.Request, opts ...netx.CallOp
 		return nil, err
 	}
 
-	var lastErr error
-	// 连接失败,默认会自动重试
-	for i := 0; i < c.opts.Failover+1; i++ {
-		lastErr = c.sendRequest(ctx, picker, req, o)
-	}
-
+	lastErr := c.send(ctx, picker, req, o)
 	if lastErr != nil {
 		c.opts.caller.Unregister(req.SeqID())
 		if future != nil {
@@ -88,6 +138,18 @@ func (c *client) Call(ctx context.Context, req netx.Request, opts ...netx.CallOp
 	return nil, lastErr
 }
 
+// send 发送请求,连接失败时会自动重试
+func (c *client) send(ctx context.Context, picker loadbalance.Picker, req netx.Request, o *CallOptions) error {
+	var err error
+	for i := 0; i < c.opts.Failover+1; i++ {
+		if err = c.sendRequest(ctx, picker, req, o); err == nil {
+			break
+		}
+	}
+
+	return err
+}
+
 // resolve 解析地址
 func (c *client) resolve(ctx context.Context, service string) (loadbalance.Picker, error) {
 	entry, err := c.opts.Resolver.Resolve(ctx, service)
@@ -141,3 +203,29 @@ func (c *client) sendRequest(ctx context.Context, picker loadbalance.Picker, req
 func (c *client) Close() error {
 	return nil
 }
+
+// setCompress 按配置压缩请求,并声明可接受的应答压缩方式
+func (c *client) setCompress(req netx.Request, o *CallOptions) {
+	if o.Compress == compress.TypeNone {
+		o.Compress = c.opts.Compress
+	}
+	if o.CompressThreshold == 0 {
+		o.CompressThreshold = c.opts.Threshold
+	}
+	if o.Compress == compress.TypeNone || compress.Get(o.Compress) == nil {
+		return
+	}
+
+	if req.Header().Get(netx.XAcceptCompress) == "" {
+		h := req.Header()
+		h.Set(netx.XAcceptCompress, compress.Accept())
+		req.SetHeader(h)
+	}
+
+	if req.Compress() != compress.TypeNone || req.Body() == nil {
+		return
+	}
+	if buf, err := req.Body().Buffer(); err == nil && buf != nil && buf.Len() >= o.CompressThreshold {
+		req.SetCompress(o.Compress)
+	}
+}
@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+)
+
+func newRequest(service string, oneway bool) netx.Request {
+	req := netx.NewRequest()
+	req.SetService(memtest.Addr(service))
+	req.SetURI("count")
+	req.SetOneway(oneway)
+	return req
+}
+
+// TestFailover 发送成功后不再重试
+func TestFailover(t *testing.T) {
+	var count int32
+	svr := memtest.NewServer(t, "client.failover")
+	svr.Register(&netx.Route{Name: "count", Handler: func(ctx context.Context) error {
+		atomic.AddInt32(&count, 1)
+		return nil
+	}})
+
+	cli := memtest.NewClient(t, client.WithProtocol(rpc.New()), client.WithFailover(2))
+	if _, err := cli.Call(context.Background(), newRequest("client.failover", false)); err != nil {
+		t.Fatal(err)
+	}
+	// 等待可能重复发送的请求到达
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&count); n != 1 {
+		t.Fatalf("request should be sent once, %d", n)
+	}
+}
+
+// TestOneway oneway请求发送后立即返回,不等待应答
+func TestOneway(t *testing.T) {
+	done := make(chan struct{}, 1)
+	svr := memtest.NewServer(t, "client.oneway")
+	svr.Register(&netx.Route{Name: "count", Handler: func(ctx context.Context) error {
+		done <- struct{}{}
+		return nil
+	}})
+
+	cli := memtest.NewClient(t, client.WithProtocol(rpc.New()))
+	start := time.Now()
+	rsp, err := cli.Call(context.Background(), newRequest("client.oneway", true), client.WithCallTimeout(time.Second))
+	if err != nil || rsp != nil {
+		t.Fatalf("bad oneway call, %v %v", rsp, err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("oneway call should not wait for response")
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("oneway request not received")
+	}
+}
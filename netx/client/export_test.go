@@ -0,0 +1,6 @@
+package client
+
+// MirrorCount 返回影子请求统计,仅用于测试
+func MirrorCount(service string, result string) int64 {
+	return gMirrorRequests.Values(service, result).Value()
+}
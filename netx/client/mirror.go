@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+var (
+	gMirrorRequests = metrics.NewCounterSet(&metrics.CounterOpts{
+		Namespace: "nova",
+		Subsystem: "mirror",
+		Name:      "requests",
+		Help:      "mirrored requests by result, match/mismatch/error/dropped",
+	}, []string{"service", "result"})
+	gMirrorLatency = metrics.NewHistogramSet(&metrics.HistogramOpts{
+		Namespace: "nova",
+		Subsystem: "mirror",
+		Name:      "latency_diff_ms",
+		Help:      "shadow latency minus primary latency in milliseconds",
+	}, []string{"service"})
+)
+
+// MirrorOptions 流量镜像配置
+type MirrorOptions struct {
+	Timeout     time.Duration // 影子请求超时,与主调用无关,默认1s
+	Concurrency int           // 影子请求最大并发,超过时直接丢弃,默认32
+}
+
+type MirrorOption func(o *MirrorOptions)
+
+// WithMirrorTimeout 设置影子请求超时
+func WithMirrorTimeout(d time.Duration) MirrorOption {
+	return func(o *MirrorOptions) {
+		o.Timeout = d
+	}
+}
+
+// WithMirrorConcurrency 设置影子请求最大并发
+func WithMirrorConcurrency(n int) MirrorOption {
+	return func(o *MirrorOptions) {
+		o.Concurrency = n
+	}
+}
+
+func newMirror(service string, percent float64, opts ...MirrorOption) *mirror {
+	o := &MirrorOptions{Timeout: time.Second, Concurrency: 32}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &mirror{
+		service: service,
+		percent: percent,
+		opts:    o,
+		sem:     make(chan struct{}, o.Concurrency),
+	}
+}
+
+// mirror 流量镜像,按比例将请求异步复制到影子服务
+//	影子应答直接丢弃,仅统计与主调用的延迟差和状态码是否一致
+//	影子请求在独立协程中执行,使用独立超时和并发限制,不影响主调用
+type mirror struct {
+	service string
+	percent float64 // 采样百分比[0,100]
+	opts    *MirrorOptions
+	sem     chan struct{}
+}
+
+// mirrorResult 主调用结果
+type mirrorResult struct {
+	status  int
+	latency time.Duration
+}
+
+// start 采样并发起影子请求,未命中采样或超过并发时返回nil
+//	需要在主调用之前复制请求,主调用会修改SeqID,header等信息,
+//	复制时body与主调用共享底层内存,不拷贝数据,因此获取并发许可后再复制
+func (m *mirror) start(cli *client, req netx.Request) chan<- mirrorResult {
+	if m.percent <= 0 || (m.percent < 100 && rand.Float64()*100 >= m.percent) {
+		return nil
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		gMirrorRequests.Values(m.service, "dropped").Inc()
+		return nil
+	}
+
+	shadow := m.clone(req)
+	if shadow == nil {
+		<-m.sem
+		return nil
+	}
+
+	primary := make(chan mirrorResult, 1)
+	go m.run(cli, shadow, primary)
+	return primary
+}
+
+func (m *mirror) run(cli *client, shadow netx.Request, primary <-chan mirrorResult) {
+	defer func() { <-m.sem }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+	defer cancel()
+
+	begin := time.Now()
+	rsp, err := cli.callService(ctx, shadow, m.service, WithCallTimeout(m.opts.Timeout))
+	latency := time.Since(begin)
+	status := toStatus(rsp, err)
+
+	// 主调用迟迟未返回时不再等待
+	var res mirrorResult
+	timer := time.NewTimer(m.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case res = <-primary:
+	case <-timer.C:
+		gMirrorRequests.Values(m.service, "error").Inc()
+		return
+	}
+
+	gMirrorLatency.Values(m.service).Observe(float64(latency-res.latency) / float64(time.Millisecond))
+	switch {
+	case err != nil && status == netx.StatusInternalServerError:
+		gMirrorRequests.Values(m.service, "error").Inc()
+	case status == res.status:
+		gMirrorRequests.Values(m.service, "match").Inc()
+	default:
+		gMirrorRequests.Values(m.service, "mismatch").Inc()
+	}
+}
+
+// clone 复制请求并替换服务名,仅支持Buffer类型body,流式请求不做镜像
+//	body通过Append共享底层引用计数内存,不拷贝数据,主调用和影子请求均只读
+func (m *mirror) clone(req netx.Request) netx.Request {
+	var payload bytex.Buffer
+	if bd := req.Body(); bd != nil {
+		buf, err := bd.Buffer()
+		if err != nil {
+			return nil
+		}
+		if buf != nil && !buf.Empty() {
+			payload = bytex.NewBuffer()
+			if err := payload.Append(buf); err != nil {
+				return nil
+			}
+			_, _ = payload.Seek(0, io.SeekStart)
+		}
+	}
+
+	shadow := netx.NewRequest()
+	if p, ok := req.(netx.Packet); ok {
+		ident := *p.Identifier()
+		shadow.(netx.Packet).SetIdentifier(&ident)
+	} else {
+		shadow.SetURI(req.URI())
+		shadow.SetMethod(req.Method())
+		shadow.SetCmdID(req.CmdID())
+		shadow.SetCodec(req.Codec())
+		shadow.SetOneway(req.IsOneway())
+	}
+	shadow.SetSeqID(0)
+	shadow.SetService(m.service)
+
+	header := netx.NewHeader()
+	header.Merge(req.Header())
+	shadow.SetHeader(header)
+	if payload != nil {
+		shadow.SetBody(body.NewBufferBody(payload))
+	}
+
+	return shadow
+}
+
+// toStatus 统一转换为状态码,便于比较
+func toStatus(rsp netx.Response, err error) int {
+	if err != nil {
+		if nerr, ok := err.(netx.Error); ok {
+			return nerr.Code()
+		}
+		return netx.StatusInternalServerError
+	}
+
+	if rsp == nil || rsp.StatusCode() == 0 {
+		return 200
+	}
+
+	return int(rsp.StatusCode())
+}
@@ -0,0 +1,113 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+)
+
+// newShadow 启动影子服务,返回调用次数
+func newShadow(t *testing.T, name string, handler func(ctx context.Context) error) *int32 {
+	var count int32
+	svr := memtest.NewServer(t, name)
+	svr.Register(&netx.Route{Name: "count", Handler: func(ctx context.Context) error {
+		atomic.AddInt32(&count, 1)
+		return handler(ctx)
+	}})
+	return &count
+}
+
+func newPrimary(t *testing.T, name string) {
+	svr := memtest.NewServer(t, name)
+	svr.Register(&netx.Route{Name: "count", Handler: func(ctx context.Context) error {
+		return nil
+	}})
+}
+
+// waitCount 等待异步的影子请求完成
+func waitCount(fn func() int64, expect int64) int64 {
+	for i := 0; i < 100 && fn() < expect; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fn()
+}
+
+func TestMirrorPercent(t *testing.T) {
+	newPrimary(t, "mirror.percent")
+	for _, tc := range []struct {
+		percent  float64
+		min, max int32
+	}{{0, 0, 0}, {100, 100, 100}, {50, 20, 80}} {
+		name := fmt.Sprintf("mirror.percent.%v", tc.percent)
+		count := newShadow(t, name, func(ctx context.Context) error { return nil })
+		// 首次建立连接期间影子请求会堆积,放开并发限制避免丢弃
+		mirror := client.WithMirror(memtest.Addr(name), tc.percent, client.WithMirrorConcurrency(100))
+		cli := memtest.NewClient(t, client.WithProtocol(rpc.New()), mirror)
+		for i := 0; i < 100; i++ {
+			if _, err := cli.Call(context.Background(), newRequest("mirror.percent", false)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		n := int32(waitCount(func() int64 { return int64(atomic.LoadInt32(count)) }, int64(tc.min)))
+		if n < tc.min || n > tc.max {
+			t.Fatalf("percent %v, shadow calls %d", tc.percent, n)
+		}
+	}
+}
+
+// TestMirrorIsolation 影子服务慢或失败时不影响主调用
+func TestMirrorIsolation(t *testing.T) {
+	newPrimary(t, "mirror.isolation")
+	shadow := memtest.Addr("mirror.isolation.shadow")
+	newShadow(t, "mirror.isolation.shadow", func(ctx context.Context) error {
+		time.Sleep(300 * time.Millisecond)
+		return netx.NewError(http.StatusInternalServerError, "", "shadow down")
+	})
+
+	cli := memtest.NewClient(t, client.WithProtocol(rpc.New()), client.WithMirror(shadow, 100, client.WithMirrorTimeout(50*time.Millisecond)))
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		rsp, err := cli.Call(context.Background(), newRequest("mirror.isolation", false))
+		if err != nil || (rsp.StatusCode() != 0 && rsp.StatusCode() != http.StatusOK) {
+			t.Fatalf("primary should succeed, %v", err)
+		}
+		if time.Since(start) > 200*time.Millisecond {
+			t.Fatal("primary should not wait for shadow")
+		}
+	}
+}
+
+// TestMirrorReport 按状态码统计一致及不一致,使用Proxy时影子请求仍然发往镜像服务
+func TestMirrorReport(t *testing.T) {
+	newPrimary(t, "mirror.report")
+	match, mismatch := memtest.Addr("mirror.report.match"), memtest.Addr("mirror.report.mismatch")
+	newShadow(t, "mirror.report.match", func(ctx context.Context) error { return nil })
+	newShadow(t, "mirror.report.mismatch", func(ctx context.Context) error {
+		return netx.NewError(http.StatusServiceUnavailable, "", "busy")
+	})
+
+	for _, tc := range []struct {
+		shadow string
+		result string
+	}{{match, "match"}, {mismatch, "mismatch"}} {
+		before := client.MirrorCount(tc.shadow, tc.result)
+		cli := memtest.NewClient(t, client.WithProtocol(rpc.New()),
+			client.WithProxy(memtest.Addr("mirror.report")), client.WithMirror(tc.shadow, 100))
+		for i := 0; i < 3; i++ {
+			if _, err := cli.Call(context.Background(), newRequest("unknown", false)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n := waitCount(func() int64 { return client.MirrorCount(tc.shadow, tc.result) }, before+3); n != before+3 {
+			t.Fatalf("expect 3 %s, %d", tc.result, n-before)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/netx/discovery"
 	"github.com/foredata/nova/netx/executor"
 	"github.com/foredata/nova/netx/loadbalance"
@@ -13,21 +14,28 @@ import (
 
 // Options 可选配置信息
 type Options struct {
-	Tran     netx.Tran            //
-	Protocol netx.Protocol        // 默认编码协议
-	Exec     netx.Executor        //
-	Resolver discovery.Resolver   //
-	Balancer loadbalance.Balancer //
-	Filter   loadbalance.Filter   // 用于过滤
-	Config   Configer             // 配置信息
-	Proxy    string               // 代理服务名
-	Failover int                  // 故障转移次数
-	ConnPool ConnPool             //
-	caller   Caller               //
+	Tran        netx.Tran            //
+	TranFactory netx.Factory         // 未指定Tran时用于创建Tran,默认transport.New
+	Protocol    netx.Protocol        // 默认编码协议
+	Exec        netx.Executor        //
+	Resolver    discovery.Resolver   //
+	Balancer    loadbalance.Balancer //
+	Filter      loadbalance.Filter   // 用于过滤
+	Config      Configer             // 配置信息
+	Proxy       string               // 代理服务名
+	Failover    int                  // 故障转移次数
+	ConnPool    ConnPool             //
+	Middlewares []netx.Middleware    // 调用中间件,例如故障注入
+	Compress    compress.Type        // 默认请求压缩方式,同时会声明可接受压缩的应答
+	Threshold   int                  // body不小于该值时才压缩,默认1024
+	caller      Caller               //
+	mirror      *mirror              // 流量镜像
 }
 
 type Option func(*Options)
 
+const defaultCompressThreshold = 1024
+
 func newOptions(opts ...Option) *Options {
 	o := &Options{}
 	for _, fn := range opts {
@@ -48,7 +56,11 @@ func newOptions(opts ...Option) *Options {
 		}
 
 		filter := processor.NewFilter(o.Exec, o.caller, &detector{o.Protocol})
-		tran := transport.New()
+		factory := o.TranFactory
+		if factory == nil {
+			factory = transport.New
+		}
+		tran := factory()
 		tran.AddFilters(filter)
 		o.Tran = tran
 	}
@@ -65,6 +77,10 @@ func newOptions(opts ...Option) *Options {
 		o.Balancer = random.New()
 	}
 
+	if o.Threshold == 0 {
+		o.Threshold = defaultCompressThreshold
+	}
+
 	return o
 }
 
@@ -74,6 +90,13 @@ func WithTran(t netx.Tran) Option {
 	}
 }
 
+// WithTranFactory 指定Tran创建方式,与WithTran不同,会自动添加消息处理filter
+func WithTranFactory(fn netx.Factory) Option {
+	return func(o *Options) {
+		o.TranFactory = fn
+	}
+}
+
 func WithProtocol(p netx.Protocol) Option {
 	return func(o *Options) {
 		o.Protocol = p
@@ -121,3 +144,27 @@ func WithFailover(v int) Option {
 		o.Failover = v
 	}
 }
+
+// WithMiddlewares 设置调用中间件,按添加顺序由外向内执行
+func WithMiddlewares(m ...netx.Middleware) Option {
+	return func(o *Options) {
+		o.Middlewares = append(o.Middlewares, m...)
+	}
+}
+
+// WithMirror 开启流量镜像,按percent百分比将请求异步复制到影子服务service
+//	影子应答会被丢弃,仅记录与主调用的延迟差及状态码差异
+func WithMirror(service string, percent float64, opts ...MirrorOption) Option {
+	return func(o *Options) {
+		o.mirror = newMirror(service, percent, opts...)
+	}
+}
+
+// WithCompress 开启请求压缩,body不小于threshold时使用t压缩,threshold为0时使用默认值
+//	需要服务端也注册了相同的压缩方式
+func WithCompress(t compress.Type, threshold int) Option {
+	return func(o *Options) {
+		o.Compress = t
+		o.Threshold = threshold
+	}
+}
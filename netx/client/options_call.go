@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/compress"
 )
 
 type CallOptions = netx.CallOptions
@@ -44,3 +45,11 @@ func WithRetryPolicy(p netx.RetryPolicy) CallOption {
 		co.RetryPolicy = p
 	}
 }
+
+// WithCallCompress 指定本次请求的压缩方式,覆盖client默认配置
+func WithCallCompress(t compress.Type, threshold int) CallOption {
+	return func(co *CallOptions) {
+		co.Compress = t
+		co.CompressThreshold = threshold
+	}
+}
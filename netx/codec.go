@@ -12,17 +12,19 @@ type CodecType = uint
 
 // 常见已知CodecType枚举
 const (
-	CodecTypeUnknown  CodecType = 0
-	CodecTypeText     CodecType = 1 // text/plain
-	CodecTypeBinary   CodecType = 2 // application/octet-stream
-	CodecTypeForm     CodecType = 3 // application/x-www-form-urlencoded
-	CodecTypeJson     CodecType = 4
-	CodecTypeXml      CodecType = 5
-	CodecTypeProtobuf CodecType = 6
-	CodecTypeThrift   CodecType = 7
-	CodecTypeMsgpack  CodecType = 8
-	CodecTypeAvro     CodecType = 9
-	CodecTypeGob      CodecType = 10
+	CodecTypeUnknown       CodecType = 0
+	CodecTypeText          CodecType = 1 // text/plain
+	CodecTypeBinary        CodecType = 2 // application/octet-stream
+	CodecTypeForm          CodecType = 3 // application/x-www-form-urlencoded
+	CodecTypeJson          CodecType = 4
+	CodecTypeXml           CodecType = 5
+	CodecTypeProtobuf      CodecType = 6
+	CodecTypeThrift        CodecType = 7
+	CodecTypeMsgpack       CodecType = 8
+	CodecTypeAvro          CodecType = 9
+	CodecTypeGob           CodecType = 10
+	CodecTypeThriftCompact CodecType = 11 // thrift compact协议,CodecTypeThrift为binary协议
+	CodecTypeCbor          CodecType = 12
 )
 
 // Codec 用于消息中body的编解码，常见的格式为Json,Xml,Protobuf,Thrift
@@ -42,9 +44,11 @@ func init() {
 	AddContentTypeMap(CodecTypeXml, "application/xml")
 	AddContentTypeMap(CodecTypeProtobuf, "application/protobuf")
 	AddContentTypeMap(CodecTypeThrift, "application/thrift")
+	AddContentTypeMap(CodecTypeThriftCompact, "application/vnd.apache.thrift.compact")
 	AddContentTypeMap(CodecTypeMsgpack, "application/msgpack")
-	AddContentTypeMap(CodecTypeMsgpack, "application/avro")
-	AddContentTypeMap(CodecTypeMsgpack, "application/gob")
+	AddContentTypeMap(CodecTypeAvro, "application/avro")
+	AddContentTypeMap(CodecTypeGob, "application/gob")
+	AddContentTypeMap(CodecTypeCbor, "application/cbor")
 }
 
 // http contentType <-> codecType
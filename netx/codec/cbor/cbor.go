@@ -0,0 +1,41 @@
+package cbor
+
+import (
+	"github.com/foredata/nova/encoding/cbor"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// New .
+func New() netx.Codec {
+	return &cborCodec{}
+}
+
+type cborCodec struct {
+}
+
+func (c *cborCodec) Type() netx.CodecType {
+	return netx.CodecTypeCbor
+}
+
+func (c *cborCodec) Name() string {
+	return "cbor"
+}
+
+func (c *cborCodec) Encode(b bytex.Buffer, msg interface{}) error {
+	data, err := cbor.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return b.Append(data)
+}
+
+func (c *cborCodec) Decode(b bytex.Buffer, msg interface{}) error {
+	var data []byte
+	if b != nil {
+		data = b.Bytes()
+	}
+
+	return cbor.Unmarshal(data, msg)
+}
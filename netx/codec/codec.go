@@ -2,9 +2,12 @@ package codec
 
 import (
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/codec/cbor"
 	"github.com/foredata/nova/netx/codec/gob"
 	"github.com/foredata/nova/netx/codec/json"
+	"github.com/foredata/nova/netx/codec/msgpack"
 	"github.com/foredata/nova/netx/codec/protobuf"
+	"github.com/foredata/nova/netx/codec/thrift"
 	"github.com/foredata/nova/netx/codec/xml"
 )
 
@@ -14,6 +17,10 @@ func init() {
 	Register(xml.New())
 	Register(protobuf.New())
 	Register(gob.New())
+	Register(thrift.New())
+	Register(thrift.NewCompact())
+	Register(msgpack.New())
+	Register(cbor.New())
 }
 
 // Register 添加Codec,非线程安全,通常仅在程序启动时注册
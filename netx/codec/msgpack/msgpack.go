@@ -0,0 +1,41 @@
+package msgpack
+
+import (
+	"github.com/foredata/nova/encoding/msgpack"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// New .
+func New() netx.Codec {
+	return &msgpackCodec{}
+}
+
+type msgpackCodec struct {
+}
+
+func (c *msgpackCodec) Type() netx.CodecType {
+	return netx.CodecTypeMsgpack
+}
+
+func (c *msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (c *msgpackCodec) Encode(b bytex.Buffer, msg interface{}) error {
+	data, err := msgpack.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return b.Append(data)
+}
+
+func (c *msgpackCodec) Decode(b bytex.Buffer, msg interface{}) error {
+	var data []byte
+	if b != nil {
+		data = b.Bytes()
+	}
+
+	return msgpack.Unmarshal(data, msg)
+}
@@ -0,0 +1,15 @@
+// Package novapb nova.proto中自定义选项的字段编号
+//	protoc-gen-nova直接从descriptor中解析这些选项,不需要为nova.proto生成go代码
+package novapb
+
+// ServiceOptions扩展字段
+const (
+	FieldCmdBase = 52001
+)
+
+// MethodOptions扩展字段
+const (
+	FieldCmdID      = 52001
+	FieldTimeout    = 52002
+	FieldIdempotent = 52003
+)
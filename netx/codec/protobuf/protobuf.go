@@ -39,7 +39,11 @@ func (c *protobufCodec) Encode(b bytex.Buffer, msg interface{}) error {
 
 func (c *protobufCodec) Decode(b bytex.Buffer, msg interface{}) error {
 	if pb, ok := msg.(Message); ok {
-		data := b.Bytes()
+		// 字段均为默认值的消息编码后为空,body为空
+		var data []byte
+		if b != nil {
+			data = b.Bytes()
+		}
 		return Unmarshal(data, pb)
 	}
 
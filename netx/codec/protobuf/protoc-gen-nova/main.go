@@ -0,0 +1,52 @@
+// protoc插件,根据proto中的service生成nova的client stub及server注册代码
+//	go install github.com/foredata/nova/netx/codec/protobuf/protoc-gen-nova
+//	protoc -I. -I$NOVA/netx/codec/protobuf/novapb --gogo_out=. --nova_out=paths=source_relative:. calc.proto
+//	消息代码需由protoc-gen-gogo等插件生成,且需实现Marshal/Unmarshal
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/foredata/nova/netx/codec/protobuf/protogen"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		fmt.Fprintf(os.Stderr, "protoc-gen-nova should be invoked by protoc\n")
+		os.Exit(2)
+	}
+
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read request fail, %+v\n", err)
+		os.Exit(1)
+	}
+
+	rsp := generate(data)
+	if _, err := os.Stdout.Write(rsp.Marshal()); err != nil {
+		fmt.Fprintf(os.Stderr, "write response fail, %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+// generate 生成失败时通过Response.Error返回给protoc
+func generate(data []byte) *protogen.Response {
+	req, err := protogen.ParseRequest(data)
+	if err != nil {
+		return &protogen.Response{Error: err.Error()}
+	}
+
+	opts, err := protogen.ParseParameter(req.Parameter)
+	if err != nil {
+		return &protogen.Response{Error: err.Error()}
+	}
+
+	files, err := protogen.Generate(req, opts...)
+	if err != nil {
+		return &protogen.Response{Error: err.Error()}
+	}
+
+	return &protogen.Response{Files: files}
+}
@@ -0,0 +1,329 @@
+package protogen
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/foredata/nova/netx/codec/protobuf/novapb"
+)
+
+// some error
+var (
+	ErrInvalidData = errors.New("protogen: invalid data")
+)
+
+// wire type
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Request protoc插件请求,对应plugin.proto中的CodeGeneratorRequest
+//	仅解析生成service代码所需字段
+type Request struct {
+	FileToGenerate []string // 需要生成代码的文件
+	Parameter      string   // --nova_out中的参数
+	Files          []*File  // 所有文件,依赖在前
+}
+
+// File 对应FileDescriptorProto
+type File struct {
+	Name      string     // proto文件路径
+	Package   string     // proto包名
+	GoPackage string     // go_package选项
+	Messages  []string   // 消息全名,包含嵌套消息,不含前导点
+	Services  []*Service //
+}
+
+// Service 对应ServiceDescriptorProto
+type Service struct {
+	Name    string    //
+	CmdBase uint32    // nova.cmd_base
+	Methods []*Method //
+}
+
+// Method 对应MethodDescriptorProto
+type Method struct {
+	Name            string //
+	Input           string // 请求消息全名,含前导点
+	Output          string // 应答消息全名,含前导点
+	ClientStreaming bool   //
+	ServerStreaming bool   //
+	CmdID           uint32 // nova.cmd_id
+	Timeout         string // nova.timeout
+	Idempotent      bool   // nova.idempotent或idempotency_level
+}
+
+// walk 遍历消息中的字段,varint及fixed类型通过v返回,bytes类型通过b返回
+func walk(data []byte, fn func(num int, typ int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrInvalidData
+		}
+		data = data[n:]
+		num, typ := int(key>>3), int(key&7)
+		var v uint64
+		var b []byte
+		switch typ {
+		case wireVarint:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return ErrInvalidData
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return ErrInvalidData
+			}
+			v = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return ErrInvalidData
+			}
+			v = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return ErrInvalidData
+			}
+			b = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return fmt.Errorf("protogen: wire type %d not support", typ)
+		}
+
+		if err := fn(num, typ, v, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseRequest 解析CodeGeneratorRequest
+func ParseRequest(data []byte) (*Request, error) {
+	req := &Request{}
+	err := walk(data, func(num int, typ int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			req.FileToGenerate = append(req.FileToGenerate, string(b))
+		case 2:
+			req.Parameter = string(b)
+		case 15:
+			f, err := parseFile(b)
+			if err != nil {
+				return err
+			}
+			req.Files = append(req.Files, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func parseFile(data []byte) (*File, error) {
+	f := &File{}
+	var messages [][]byte
+	err := walk(data, func(num int, typ int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			f.Name = string(b)
+		case 2:
+			f.Package = string(b)
+		case 4:
+			messages = append(messages, b)
+		case 6:
+			svc, err := parseService(b)
+			if err != nil {
+				return err
+			}
+			f.Services = append(f.Services, svc)
+		case 8:
+			// FileOptions.go_package
+			return walk(b, func(num int, typ int, v uint64, b []byte) error {
+				if num == 11 {
+					f.GoPackage = string(b)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// package在message之后出现时也能得到正确的全名
+	for _, m := range messages {
+		if err := parseMessage(m, f.Package, &f.Messages); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// parseMessage 解析DescriptorProto,仅记录消息全名
+func parseMessage(data []byte, scope string, out *[]string) error {
+	var name string
+	var nested [][]byte
+	err := walk(data, func(num int, typ int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			name = string(b)
+		case 3:
+			nested = append(nested, b)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if scope != "" {
+		name = scope + "." + name
+	}
+	*out = append(*out, name)
+	for _, m := range nested {
+		if err := parseMessage(m, name, out); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseService(data []byte) (*Service, error) {
+	svc := &Service{}
+	err := walk(data, func(num int, typ int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			svc.Name = string(b)
+		case 2:
+			m, err := parseMethod(b)
+			if err != nil {
+				return err
+			}
+			svc.Methods = append(svc.Methods, m)
+		case 3:
+			return walk(b, func(num int, typ int, v uint64, b []byte) error {
+				if num == novapb.FieldCmdBase && typ == wireVarint {
+					svc.CmdBase = uint32(v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	return svc, err
+}
+
+func parseMethod(data []byte) (*Method, error) {
+	m := &Method{}
+	err := walk(data, func(num int, typ int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			m.Name = string(b)
+		case 2:
+			m.Input = string(b)
+		case 3:
+			m.Output = string(b)
+		case 4:
+			return parseMethodOptions(m, b)
+		case 5:
+			m.ClientStreaming = v != 0
+		case 6:
+			m.ServerStreaming = v != 0
+		}
+		return nil
+	})
+
+	return m, err
+}
+
+// idempotency_level取值
+const (
+	noSideEffects = 1
+	idempotent    = 2
+)
+
+func parseMethodOptions(m *Method, data []byte) error {
+	return walk(data, func(num int, typ int, v uint64, b []byte) error {
+		switch {
+		case num == 34 && typ == wireVarint:
+			m.Idempotent = m.Idempotent || v == noSideEffects || v == idempotent
+		case num == novapb.FieldCmdID && typ == wireVarint:
+			m.CmdID = uint32(v)
+		case num == novapb.FieldTimeout && typ == wireBytes:
+			m.Timeout = string(b)
+		case num == novapb.FieldIdempotent && typ == wireVarint:
+			m.Idempotent = m.Idempotent || v != 0
+		}
+		return nil
+	})
+}
+
+// GeneratedFile 生成的文件
+type GeneratedFile struct {
+	Name    string
+	Content []byte
+}
+
+// Response 对应CodeGeneratorResponse
+type Response struct {
+	Error string
+	Files []*GeneratedFile
+}
+
+// featureProto3Optional 支持proto3 optional,service代码不受影响
+const featureProto3Optional = 1
+
+// Marshal 编码为CodeGeneratorResponse
+func (r *Response) Marshal() []byte {
+	var out []byte
+	if r.Error != "" {
+		out = appendBytes(out, 1, []byte(r.Error))
+	}
+	out = appendUvarint(appendKey(out, 2, wireVarint), featureProto3Optional)
+	for _, f := range r.Files {
+		var file []byte
+		file = appendBytes(file, 1, []byte(f.Name))
+		file = appendBytes(file, 15, f.Content)
+		out = appendBytes(out, 15, file)
+	}
+
+	return out
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+func appendKey(b []byte, num int, typ int) []byte {
+	return appendUvarint(b, uint64(num)<<3|uint64(typ))
+}
+
+func appendBytes(b []byte, num int, data []byte) []byte {
+	b = appendKey(b, num, wireBytes)
+	b = appendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// trimDot 去掉全名中的前导点
+func trimDot(name string) string {
+	return strings.TrimPrefix(name, ".")
+}
@@ -0,0 +1,123 @@
+package example
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+)
+
+type calcServer struct{}
+
+func (calcServer) Add(ctx context.Context, in *AddRequest) (*AddResponse, error) {
+	if in.A < 0 {
+		return nil, netx.BadRequest("negative a")
+	}
+	return &AddResponse{Sum: in.A + in.B}, nil
+}
+
+func (calcServer) List(ctx context.Context, in *ListRequest, stream Calc_ListServer) error {
+	for i := int32(0); i < in.Count; i++ {
+		if err := stream.Send(&ListResponse_Item{Index: i}); err != nil {
+			return err
+		}
+	}
+	if in.Count > 3 {
+		return netx.NewError(http.StatusTooManyRequests, "too many", "count %d", in.Count)
+	}
+	return nil
+}
+
+func (calcServer) Sum(ctx context.Context, stream Calc_SumServer) (*SumResult, error) {
+	res := &SumResult{}
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.Sum += in.A + in.B
+		res.Count++
+	}
+}
+
+func TestCalc(t *testing.T) {
+	svr := memtest.NewServer(t, "protogen.calc")
+	RegisterCalcServer(svr, calcServer{})
+	cli := NewCalcClient(memtest.NewClient(t, client.WithProtocol(rpc.New())), memtest.Addr("protogen.calc"))
+	ctx := context.Background()
+
+	t.Run("unary", func(t *testing.T) {
+		rsp, err := cli.Add(ctx, &AddRequest{A: 1, B: 2})
+		if err != nil || rsp.Sum != 3 {
+			t.Fatalf("add fail, %+v, %+v", rsp, err)
+		}
+
+		_, err = cli.Add(ctx, &AddRequest{A: -1})
+		if nerr, ok := err.(netx.Error); !ok || nerr.Code() != http.StatusBadRequest {
+			t.Fatalf("expect bad request, %+v", err)
+		}
+	})
+
+	t.Run("server_stream", func(t *testing.T) {
+		for _, count := range []int32{0, 3, 5} {
+			stream, err := cli.List(ctx, &ListRequest{Count: count})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var n int32
+			for {
+				item, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					if nerr, ok := err.(netx.Error); !ok || nerr.Code() != http.StatusTooManyRequests || count != 5 {
+						t.Fatalf("recv fail, %+v", err)
+					}
+					break
+				}
+				if item.Index != n {
+					t.Fatalf("bad item, %+v", item)
+				}
+				n++
+			}
+			_ = stream.Close()
+			if n != count {
+				t.Fatalf("bad count, %d, %d", n, count)
+			}
+		}
+	})
+
+	t.Run("client_stream", func(t *testing.T) {
+		stream, err := cli.Sum(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := int64(1); i <= 4; i++ {
+			if err := stream.Send(&AddRequest{A: i, B: i}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := stream.CloseAndRecv()
+		if err != nil || res.Sum != 20 || res.Count != 4 {
+			t.Fatalf("sum fail, %+v, %+v", res, err)
+		}
+	})
+
+	t.Run("endpoints", func(t *testing.T) {
+		eps := CalcServiceDesc.Endpoints()
+		if len(eps) != 3 || eps[0].Name != "/demo.calc.Calc/Add" || eps[0].Metadata["cmd_id"] != "101" ||
+			eps[0].Metadata["timeout"] != "500ms" || eps[0].Metadata["idempotent"] != "true" ||
+			eps[1].Response.Type != "demo.calc.ListResponse.Item" || eps[2].Metadata["client_streaming"] != "true" {
+			t.Fatalf("bad endpoints, %+v", eps)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package example
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// 以下消息按calc.proto手写,用于代替protoc-gen-gogo生成的代码,仅支持varint字段
+
+var errInvalidData = errors.New("example: invalid data")
+
+func appendVarint(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(num)<<3)
+	b = append(b, tmp[:n]...)
+	n = binary.PutUvarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+func parseVarints(data []byte, fn func(num int, v uint64)) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 || key&7 != 0 {
+			return errInvalidData
+		}
+		data = data[n:]
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errInvalidData
+		}
+		data = data[n:]
+		fn(int(key>>3), v)
+	}
+
+	return nil
+}
+
+type AddRequest struct {
+	A int64
+	B int64
+}
+
+func (m *AddRequest) Reset()         { *m = AddRequest{} }
+func (m *AddRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*AddRequest) ProtoMessage()    {}
+
+func (m *AddRequest) Marshal() ([]byte, error) {
+	return appendVarint(appendVarint(nil, 1, uint64(m.A)), 2, uint64(m.B)), nil
+}
+
+func (m *AddRequest) Unmarshal(data []byte) error {
+	return parseVarints(data, func(num int, v uint64) {
+		switch num {
+		case 1:
+			m.A = int64(v)
+		case 2:
+			m.B = int64(v)
+		}
+	})
+}
+
+type AddResponse struct {
+	Sum int64
+}
+
+func (m *AddResponse) Reset()         { *m = AddResponse{} }
+func (m *AddResponse) String() string { return fmt.Sprintf("%+v", *m) }
+func (*AddResponse) ProtoMessage()    {}
+
+func (m *AddResponse) Marshal() ([]byte, error) {
+	return appendVarint(nil, 1, uint64(m.Sum)), nil
+}
+
+func (m *AddResponse) Unmarshal(data []byte) error {
+	return parseVarints(data, func(num int, v uint64) {
+		if num == 1 {
+			m.Sum = int64(v)
+		}
+	})
+}
+
+type ListRequest struct {
+	Count int32
+}
+
+func (m *ListRequest) Reset()         { *m = ListRequest{} }
+func (m *ListRequest) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ListRequest) ProtoMessage()    {}
+
+func (m *ListRequest) Marshal() ([]byte, error) {
+	return appendVarint(nil, 1, uint64(m.Count)), nil
+}
+
+func (m *ListRequest) Unmarshal(data []byte) error {
+	return parseVarints(data, func(num int, v uint64) {
+		if num == 1 {
+			m.Count = int32(v)
+		}
+	})
+}
+
+type ListResponse_Item struct {
+	Index int32
+}
+
+func (m *ListResponse_Item) Reset()         { *m = ListResponse_Item{} }
+func (m *ListResponse_Item) String() string { return fmt.Sprintf("%+v", *m) }
+func (*ListResponse_Item) ProtoMessage()    {}
+
+func (m *ListResponse_Item) Marshal() ([]byte, error) {
+	return appendVarint(nil, 1, uint64(m.Index)), nil
+}
+
+func (m *ListResponse_Item) Unmarshal(data []byte) error {
+	return parseVarints(data, func(num int, v uint64) {
+		if num == 1 {
+			m.Index = int32(v)
+		}
+	})
+}
+
+type SumResult struct {
+	Sum   int64
+	Count int32
+}
+
+func (m *SumResult) Reset()         { *m = SumResult{} }
+func (m *SumResult) String() string { return fmt.Sprintf("%+v", *m) }
+func (*SumResult) ProtoMessage()    {}
+
+func (m *SumResult) Marshal() ([]byte, error) {
+	return appendVarint(appendVarint(nil, 1, uint64(m.Sum)), 2, uint64(m.Count)), nil
+}
+
+func (m *SumResult) Unmarshal(data []byte) error {
+	return parseVarints(data, func(num int, v uint64) {
+		switch num {
+		case 1:
+			m.Sum = int64(v)
+		case 2:
+			m.Count = int32(v)
+		}
+	})
+}
@@ -0,0 +1,588 @@
+package protogen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	netxImport     = "github.com/foredata/nova/netx"
+	protobufImport = "github.com/foredata/nova/netx/codec/protobuf"
+)
+
+// Options 生成选项
+type Options struct {
+	SourceRelative bool              // 生成的文件与proto文件在同一目录,否则按go import path存放
+	ImportMap      map[string]string // proto文件对应的go import path,优先于go_package
+}
+
+type Option func(o *Options)
+
+// WithSourceRelative 生成的文件与proto文件在同一目录
+func WithSourceRelative() Option {
+	return func(o *Options) {
+		o.SourceRelative = true
+	}
+}
+
+// WithImportPath 指定proto文件的go import path
+func WithImportPath(file string, importPath string) Option {
+	return func(o *Options) {
+		if o.ImportMap == nil {
+			o.ImportMap = make(map[string]string)
+		}
+		o.ImportMap[file] = importPath
+	}
+}
+
+// ParseParameter 解析插件参数,与protoc-gen-go保持一致
+//	--nova_out=paths=source_relative,Mfoo.proto=example.com/foo:.
+func ParseParameter(param string) ([]Option, error) {
+	var opts []Option
+	for _, kv := range strings.Split(param, ",") {
+		if kv == "" {
+			continue
+		}
+		idx := strings.IndexByte(kv, '=')
+		if idx == -1 {
+			return nil, fmt.Errorf("protogen: invalid parameter %q", kv)
+		}
+		key, value := kv[:idx], kv[idx+1:]
+		switch {
+		case key == "paths" && value == "source_relative":
+			opts = append(opts, WithSourceRelative())
+		case key == "paths" && value == "import":
+		case strings.HasPrefix(key, "M"):
+			opts = append(opts, WithImportPath(key[1:], value))
+		default:
+			return nil, fmt.Errorf("protogen: unknown parameter %q", kv)
+		}
+	}
+
+	return opts, nil
+}
+
+// goPackage proto文件对应的go包
+type goPackage struct {
+	importPath string
+	name       string
+}
+
+// goType 消息对应的go类型
+type goType struct {
+	pkg   *goPackage
+	ident string
+}
+
+// Generate 为FileToGenerate中定义了service的文件生成代码,文件名为xxx.nova.go
+func Generate(req *Request, opts ...Option) ([]*GeneratedFile, error) {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	types := make(map[string]*goType)
+	packages := make(map[string]*goPackage)
+	for _, f := range req.Files {
+		pkg := &goPackage{}
+		pkg.importPath, pkg.name = splitGoPackage(f.GoPackage)
+		if v, ok := o.ImportMap[f.Name]; ok {
+			pkg.importPath = v
+			if pkg.name == "" || f.GoPackage == "" {
+				pkg.name = cleanPackageName(path.Base(v))
+			}
+		}
+		packages[f.Name] = pkg
+		for _, name := range f.Messages {
+			ident := strings.TrimPrefix(name, f.Package+".")
+			if f.Package == "" {
+				ident = name
+			}
+			types[name] = &goType{pkg: pkg, ident: goCamelCase(ident)}
+		}
+	}
+
+	var files []*GeneratedFile
+	for _, name := range req.FileToGenerate {
+		var file *File
+		for _, f := range req.Files {
+			if f.Name == name {
+				file = f
+				break
+			}
+		}
+		if file == nil {
+			return nil, fmt.Errorf("protogen: not found file %s", name)
+		}
+		if len(file.Services) == 0 {
+			continue
+		}
+
+		pkg := packages[name]
+		if pkg.importPath == "" {
+			return nil, fmt.Errorf("protogen: missing go_package of %s", name)
+		}
+
+		g := &generator{file: file, pkg: pkg, types: types, imports: make(map[string]string)}
+		src, err := g.generate()
+		if err != nil {
+			return nil, err
+		}
+
+		out := strings.TrimSuffix(name, path.Ext(name)) + ".nova.go"
+		if !o.SourceRelative {
+			out = path.Join(pkg.importPath, path.Base(out))
+		}
+		files = append(files, &GeneratedFile{Name: out, Content: src})
+	}
+
+	return files, nil
+}
+
+type generator struct {
+	file    *File
+	pkg     *goPackage
+	types   map[string]*goType
+	imports map[string]string // import path -> 别名
+	buf     bytes.Buffer
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// use 引用包,返回包名,名字冲突时追加序号
+func (g *generator) use(importPath string, name string) string {
+	if alias, ok := g.imports[importPath]; ok {
+		return alias
+	}
+
+	alias := name
+	for i := 1; g.hasAlias(alias) || alias == g.pkg.name; i++ {
+		alias = name + strconv.Itoa(i)
+	}
+	g.imports[importPath] = alias
+	return alias
+}
+
+func (g *generator) hasAlias(alias string) bool {
+	for _, v := range g.imports {
+		if v == alias {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typeName 消息的go类型名,其他包中的消息需要带包名
+func (g *generator) typeName(fullName string) (string, error) {
+	t := g.types[trimDot(fullName)]
+	if t == nil {
+		return "", fmt.Errorf("protogen: unknown message %s", fullName)
+	}
+
+	if t.pkg.importPath == g.pkg.importPath {
+		return t.ident, nil
+	}
+
+	return g.use(t.pkg.importPath, t.pkg.name) + "." + t.ident, nil
+}
+
+func (g *generator) generate() ([]byte, error) {
+	// 标准库及nova固定使用原始包名
+	for _, v := range [][2]string{{"context", "context"}, {netxImport, "netx"}, {protobufImport, "protobuf"}} {
+		g.use(v[0], v[1])
+	}
+
+	var cmdID uint32
+	used := make(map[uint32]string)
+	for _, svc := range g.file.Services {
+		methods, err := g.newMethods(svc, &cmdID, used)
+		if err != nil {
+			return nil, err
+		}
+		g.genService(svc, methods)
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by protoc-gen-nova. DO NOT EDIT.\n")
+	fmt.Fprintf(&out, "// source: %s\n", g.file.Name)
+	fmt.Fprintf(&out, "\npackage %s\n\n", g.pkg.name)
+	imports := make([]string, 0, len(g.imports))
+	for k := range g.imports {
+		imports = append(imports, k)
+	}
+	// 标准库在前,与第三方库之间空一行
+	sort.Slice(imports, func(i, j int) bool {
+		if si, sj := isStd(imports[i]), isStd(imports[j]); si != sj {
+			return si
+		}
+		return imports[i] < imports[j]
+	})
+	out.WriteString("import (\n")
+	for i, k := range imports {
+		if i > 0 && isStd(imports[i-1]) && !isStd(k) {
+			out.WriteString("\n")
+		}
+		if alias := g.imports[k]; alias != path.Base(k) {
+			fmt.Fprintf(&out, "\t%s %q\n", alias, k)
+		} else {
+			fmt.Fprintf(&out, "\t%q\n", k)
+		}
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("protogen: format fail, %w", err)
+	}
+	return src, nil
+}
+
+// isStd 第一段路径不含点的视为标准库
+func isStd(importPath string) bool {
+	if idx := strings.IndexByte(importPath, '/'); idx != -1 {
+		importPath = importPath[:idx]
+	}
+
+	return !strings.Contains(importPath, ".")
+}
+
+// method 生成方法所需信息
+type method struct {
+	*Method
+	name    string        // Go方法名
+	desc    string        // MethodDesc变量名
+	cmdID   uint32        //
+	timeout time.Duration //
+	input   string        // 请求Go类型
+	output  string        // 应答Go类型
+	stream  string        // 流式接口名前缀,如Calc_List
+	impl    string        // 流式接口实现的类型名前缀,如calcList
+}
+
+func (g *generator) newMethods(svc *Service, cmdID *uint32, used map[uint32]string) ([]*method, error) {
+	if svc.CmdBase != 0 {
+		*cmdID = svc.CmdBase
+	}
+
+	svcName := goCamelCase(svc.Name)
+	methods := make([]*method, 0, len(svc.Methods))
+	for _, m := range svc.Methods {
+		if m.ClientStreaming && m.ServerStreaming {
+			return nil, fmt.Errorf("protogen: %s.%s bidirectional streaming not support", svc.Name, m.Name)
+		}
+
+		if m.CmdID != 0 {
+			*cmdID = m.CmdID
+		} else {
+			*cmdID++
+		}
+		fullName := svc.Name + "." + m.Name
+		if prev, ok := used[*cmdID]; ok {
+			return nil, fmt.Errorf("protogen: cmd_id %d of %s conflict with %s", *cmdID, fullName, prev)
+		}
+		used[*cmdID] = fullName
+
+		var timeout time.Duration
+		if m.Timeout != "" {
+			d, err := time.ParseDuration(m.Timeout)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("protogen: invalid timeout of %s, %q", fullName, m.Timeout)
+			}
+			timeout = d
+		}
+
+		input, err := g.typeName(m.Input)
+		if err != nil {
+			return nil, err
+		}
+		output, err := g.typeName(m.Output)
+		if err != nil {
+			return nil, err
+		}
+
+		name := goCamelCase(m.Name)
+		methods = append(methods, &method{
+			Method:  m,
+			name:    name,
+			desc:    lowerFirst(svcName) + name + "Method",
+			cmdID:   *cmdID,
+			timeout: timeout,
+			input:   input,
+			output:  output,
+			stream:  svcName + "_" + name,
+			impl:    lowerFirst(svcName) + name,
+		})
+	}
+
+	return methods, nil
+}
+
+// durationExpr 将超时时间转换为易读的表达式
+func (g *generator) durationExpr(d time.Duration) string {
+	g.use("time", "time")
+	switch {
+	case d%time.Second == 0:
+		return fmt.Sprintf("%d * time.Second", d/time.Second)
+	case d%time.Millisecond == 0:
+		return fmt.Sprintf("%d * time.Millisecond", d/time.Millisecond)
+	default:
+		return fmt.Sprintf("time.Duration(%d)", d)
+	}
+}
+
+func (g *generator) fullName(name string) string {
+	if g.file.Package == "" {
+		return name
+	}
+
+	return g.file.Package + "." + name
+}
+
+func (g *generator) genService(svc *Service, methods []*method) {
+	name := goCamelCase(svc.Name)
+	fullName := g.fullName(svc.Name)
+
+	g.p("// %s CmdID", name)
+	g.p("const (")
+	for _, m := range methods {
+		g.p("%s%sCmdID = %d", name, m.name, m.cmdID)
+	}
+	g.p(")")
+	g.p("")
+
+	g.p("var (")
+	for _, m := range methods {
+		fields := []string{
+			fmt.Sprintf("Name: %q", "/"+fullName+"/"+m.Name),
+			fmt.Sprintf("CmdID: %s%sCmdID", name, m.name),
+			fmt.Sprintf("Input: %q", trimDot(m.Input)),
+			fmt.Sprintf("Output: %q", trimDot(m.Output)),
+		}
+		if m.timeout > 0 {
+			fields = append(fields, "Timeout: "+g.durationExpr(m.timeout))
+		}
+		if m.Idempotent {
+			fields = append(fields, "Idempotent: true")
+		}
+		if m.ClientStreaming {
+			fields = append(fields, "ClientStreaming: true")
+		}
+		if m.ServerStreaming {
+			fields = append(fields, "ServerStreaming: true")
+		}
+		g.p("%s = &protobuf.MethodDesc{%s}", m.desc, strings.Join(fields, ", "))
+	}
+	g.p(")")
+	g.p("")
+
+	descs := make([]string, 0, len(methods))
+	for _, m := range methods {
+		descs = append(descs, m.desc)
+	}
+	g.p("// %sServiceDesc %s服务描述,可用于服务注册", name, fullName)
+	g.p("var %sServiceDesc = &protobuf.ServiceDesc{", name)
+	g.p("Name: %q,", fullName)
+	g.p("Methods: []*protobuf.MethodDesc{%s},", strings.Join(descs, ", "))
+	g.p("}")
+	g.p("")
+
+	g.genClient(name, fullName, methods)
+	g.genServer(name, fullName, methods)
+}
+
+func (g *generator) genClient(name string, fullName string, methods []*method) {
+	impl := lowerFirst(name) + "Client"
+	g.p("// %sClient %s服务客户端", name, fullName)
+	g.p("type %sClient interface {", name)
+	for _, m := range methods {
+		g.p("%s", g.clientSignature(m))
+	}
+	g.p("}")
+	g.p("")
+
+	g.p("type %s struct {", impl)
+	g.p("c *protobuf.Client")
+	g.p("}")
+	g.p("")
+
+	g.p("// New%sClient service为服务发现中的服务名", name)
+	g.p("func New%sClient(cli netx.Client, service string) %sClient {", name, name)
+	g.p("return &%s{c: protobuf.NewClient(cli, service)}", impl)
+	g.p("}")
+	g.p("")
+
+	for _, m := range methods {
+		g.p("func (c *%s) %s {", impl, g.clientSignature(m))
+		switch {
+		case m.ClientStreaming:
+			g.p("s, err := c.c.NewStream(ctx, %s, nil, opts...)", m.desc)
+			g.p("if err != nil {")
+			g.p("return nil, err")
+			g.p("}")
+			g.p("return &%sClient{s}, nil", m.impl)
+		case m.ServerStreaming:
+			g.p("s, err := c.c.NewStream(ctx, %s, in, opts...)", m.desc)
+			g.p("if err != nil {")
+			g.p("return nil, err")
+			g.p("}")
+			g.p("return &%sClient{s}, nil", m.impl)
+		default:
+			g.p("out := &%s{}", m.output)
+			g.p("if err := c.c.Invoke(ctx, %s, in, out, opts...); err != nil {", m.desc)
+			g.p("return nil, err")
+			g.p("}")
+			g.p("return out, nil")
+		}
+		g.p("}")
+		g.p("")
+
+		switch {
+		case m.ClientStreaming:
+			g.p("// %sClient %s请求流,CloseAndRecv结束请求并等待应答", m.stream, m.name)
+			g.p("type %sClient interface {", m.stream)
+			g.p("Send(*%s) error", m.input)
+			g.p("CloseAndRecv() (*%s, error)", m.output)
+			g.p("}")
+			g.p("")
+			g.p("type %sClient struct {", m.impl)
+			g.p("*protobuf.ClientStream")
+			g.p("}")
+			g.p("")
+			g.p("func (x *%sClient) Send(m *%s) error {", m.impl, m.input)
+			g.p("return x.SendMsg(m)")
+			g.p("}")
+			g.p("")
+			g.p("func (x *%sClient) CloseAndRecv() (*%s, error) {", m.impl, m.output)
+			g.p("if err := x.CloseSend(); err != nil {")
+			g.p("return nil, err")
+			g.p("}")
+			g.p("m := &%s{}", m.output)
+			g.p("if err := x.RecvMsg(m); err != nil {")
+			g.p("return nil, err")
+			g.p("}")
+			g.p("return m, nil")
+			g.p("}")
+			g.p("")
+		case m.ServerStreaming:
+			g.p("// %sClient %s应答流,Recv返回io.EOF表示结束", m.stream, m.name)
+			g.p("type %sClient interface {", m.stream)
+			g.p("Recv() (*%s, error)", m.output)
+			g.p("Close() error")
+			g.p("}")
+			g.p("")
+			g.p("type %sClient struct {", m.impl)
+			g.p("*protobuf.ClientStream")
+			g.p("}")
+			g.p("")
+			g.p("func (x *%sClient) Recv() (*%s, error) {", m.impl, m.output)
+			g.p("m := &%s{}", m.output)
+			g.p("if err := x.RecvMsg(m); err != nil {")
+			g.p("return nil, err")
+			g.p("}")
+			g.p("return m, nil")
+			g.p("}")
+			g.p("")
+		}
+	}
+}
+
+func (g *generator) clientSignature(m *method) string {
+	switch {
+	case m.ClientStreaming:
+		return fmt.Sprintf("%s(ctx context.Context, opts ...netx.CallOption) (%sClient, error)", m.name, m.stream)
+	case m.ServerStreaming:
+		return fmt.Sprintf("%s(ctx context.Context, in *%s, opts ...netx.CallOption) (%sClient, error)", m.name, m.input, m.stream)
+	default:
+		return fmt.Sprintf("%s(ctx context.Context, in *%s, opts ...netx.CallOption) (*%s, error)", m.name, m.input, m.output)
+	}
+}
+
+func (g *generator) genServer(name string, fullName string, methods []*method) {
+	g.p("// %sServer %s服务接口", name, fullName)
+	g.p("type %sServer interface {", name)
+	for _, m := range methods {
+		switch {
+		case m.ClientStreaming:
+			g.p("%s(ctx context.Context, stream %sServer) (*%s, error)", m.name, m.stream, m.output)
+		case m.ServerStreaming:
+			g.p("%s(ctx context.Context, in *%s, stream %sServer) error", m.name, m.input, m.stream)
+		default:
+			g.p("%s(ctx context.Context, in *%s) (*%s, error)", m.name, m.input, m.output)
+		}
+	}
+	g.p("}")
+	g.p("")
+
+	for _, m := range methods {
+		switch {
+		case m.ClientStreaming:
+			g.p("// %sServer %s请求流,Recv返回io.EOF表示结束", m.stream, m.name)
+			g.p("type %sServer interface {", m.stream)
+			g.p("Recv() (*%s, error)", m.input)
+			g.p("}")
+			g.p("")
+			g.p("type %sServer struct {", m.impl)
+			g.p("*protobuf.ServerStream")
+			g.p("}")
+			g.p("")
+			g.p("func (x *%sServer) Recv() (*%s, error) {", m.impl, m.input)
+			g.p("m := &%s{}", m.input)
+			g.p("if err := x.RecvMsg(m); err != nil {")
+			g.p("return nil, err")
+			g.p("}")
+			g.p("return m, nil")
+			g.p("}")
+			g.p("")
+		case m.ServerStreaming:
+			g.p("// %sServer %s应答流", m.stream, m.name)
+			g.p("type %sServer interface {", m.stream)
+			g.p("Send(*%s) error", m.output)
+			g.p("}")
+			g.p("")
+			g.p("type %sServer struct {", m.impl)
+			g.p("*protobuf.ServerStream")
+			g.p("}")
+			g.p("")
+			g.p("func (x *%sServer) Send(m *%s) error {", m.impl, m.output)
+			g.p("return x.SendMsg(m)")
+			g.p("}")
+			g.p("")
+		}
+	}
+
+	g.p("// Register%sServer 注册%s服务路由", name, fullName)
+	g.p("func Register%sServer(s netx.Server, h %sServer) {", name, name)
+	for _, m := range methods {
+		switch {
+		case m.ClientStreaming:
+			g.p("s.Register(%s.Route(protobuf.StreamHandler(%s, nil, func(ctx context.Context, _ protobuf.Message, stream *protobuf.ServerStream) (protobuf.Message, error) {", m.desc, m.desc)
+			g.p("return h.%s(ctx, &%sServer{stream})", m.name, m.impl)
+			g.p("})))")
+		case m.ServerStreaming:
+			g.p("s.Register(%s.Route(protobuf.StreamHandler(%s, func() protobuf.Message {", m.desc, m.desc)
+			g.p("return &%s{}", m.input)
+			g.p("}, func(ctx context.Context, in protobuf.Message, stream *protobuf.ServerStream) (protobuf.Message, error) {")
+			g.p("return nil, h.%s(ctx, in.(*%s), &%sServer{stream})", m.name, m.input, m.impl)
+			g.p("})))")
+		default:
+			g.p("s.Register(%s.Route(protobuf.UnaryHandler(%s, func() protobuf.Message {", m.desc, m.desc)
+			g.p("return &%s{}", m.input)
+			g.p("}, func(ctx context.Context, in protobuf.Message) (protobuf.Message, error) {")
+			g.p("return h.%s(ctx, in.(*%s))", m.name, m.input)
+			g.p("})))")
+		}
+	}
+	g.p("}")
+	g.p("")
+}
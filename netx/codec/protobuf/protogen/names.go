@@ -0,0 +1,81 @@
+package protogen
+
+import (
+	"path"
+	"strings"
+	"unicode"
+)
+
+func isLower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// goCamelCase 与protoc-gen-go保持一致,嵌套消息以下划线连接,如Outer_Inner
+func goCamelCase(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && isLower(s[i+1]):
+			// 跳过.后接小写字母中的点
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			// 首字母需要大写
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && isLower(s[i+1]):
+			// 跳过_后接小写字母中的下划线
+		case isDigit(c):
+			b = append(b, c)
+		default:
+			if isLower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && isLower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+
+	return string(b)
+}
+
+// lowerFirst 首字母小写,用于非导出的变量名
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// splitGoPackage 解析go_package,格式为path;name或path
+func splitGoPackage(v string) (importPath string, name string) {
+	if idx := strings.LastIndexByte(v, ';'); idx != -1 {
+		return v[:idx], v[idx+1:]
+	}
+
+	return v, cleanPackageName(path.Base(v))
+}
+
+// cleanPackageName 将路径最后一段转换为合法的包名
+func cleanPackageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+
+	return name
+}
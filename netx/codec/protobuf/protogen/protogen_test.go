@@ -0,0 +1,168 @@
+package protogen
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/foredata/nova/netx/codec/protobuf/novapb"
+)
+
+var update = flag.Bool("update", false, "update example/calc.nova.go")
+
+func appendString(b []byte, num int, s string) []byte {
+	return appendBytes(b, num, []byte(s))
+}
+
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	return appendUvarint(appendKey(b, num, wireVarint), v)
+}
+
+func message(name string, nested ...[]byte) []byte {
+	b := appendString(nil, 1, name)
+	for _, n := range nested {
+		b = appendBytes(b, 3, n)
+	}
+	return b
+}
+
+func rpc(name, input, output string, clientStreaming, serverStreaming bool, options []byte) []byte {
+	b := appendString(nil, 1, name)
+	b = appendString(b, 2, input)
+	b = appendString(b, 3, output)
+	if options != nil {
+		b = appendBytes(b, 4, options)
+	}
+	if clientStreaming {
+		b = appendVarintField(b, 5, 1)
+	}
+	if serverStreaming {
+		b = appendVarintField(b, 6, 1)
+	}
+	return b
+}
+
+// calcRequest 与example/calc.proto对应的CodeGeneratorRequest
+func calcRequest(param string) []byte {
+	nova := appendString(nil, 1, "nova.proto")
+	nova = appendString(nova, 2, "nova")
+	nova = appendBytes(nova, 8, appendString(nil, 11, "github.com/foredata/nova/netx/codec/protobuf/novapb"))
+
+	addOpts := appendString(nil, novapb.FieldTimeout, "500ms")
+	addOpts = appendVarintField(addOpts, novapb.FieldIdempotent, 1)
+	svc := appendString(nil, 1, "Calc")
+	svc = appendBytes(svc, 2, rpc("Add", ".demo.calc.AddRequest", ".demo.calc.AddResponse", false, false, addOpts))
+	svc = appendBytes(svc, 2, rpc("List", ".demo.calc.ListRequest", ".demo.calc.ListResponse.Item", false, true, nil))
+	svc = appendBytes(svc, 2, rpc("Sum", ".demo.calc.AddRequest", ".demo.calc.SumResult", true, false, appendVarintField(nil, novapb.FieldCmdID, 110)))
+	svc = appendBytes(svc, 3, appendVarintField(nil, novapb.FieldCmdBase, 100))
+
+	calc := appendString(nil, 1, "example/calc.proto")
+	calc = appendString(calc, 2, "demo.calc")
+	calc = appendString(calc, 3, "nova.proto")
+	for _, m := range [][]byte{
+		message("AddRequest"),
+		message("AddResponse"),
+		message("ListRequest"),
+		message("ListResponse", message("Item")),
+		message("SumResult"),
+	} {
+		calc = appendBytes(calc, 4, m)
+	}
+	calc = appendBytes(calc, 6, svc)
+	calc = appendBytes(calc, 8, appendString(nil, 11, "github.com/foredata/nova/netx/codec/protobuf/protogen/example"))
+
+	req := appendString(nil, 1, "example/calc.proto")
+	req = appendString(req, 2, param)
+	req = appendBytes(req, 15, nova)
+	req = appendBytes(req, 15, calc)
+	return req
+}
+
+func TestGenerate(t *testing.T) {
+	req, err := ParseRequest(calcRequest("paths=source_relative"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Files) != 2 || len(req.Files[1].Messages) != 6 || req.Files[1].Messages[4] != "demo.calc.ListResponse.Item" {
+		t.Fatalf("bad request, %+v", req.Files)
+	}
+
+	opts, err := ParseParameter(req.Parameter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(req, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "example/calc.nova.go" {
+		t.Fatalf("bad files, %+v", files)
+	}
+
+	golden := filepath.Join("example", "calc.nova.go")
+	if *update {
+		if err := ioutil.WriteFile(golden, files[0].Content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, files[0].Content) {
+		t.Errorf("calc.nova.go is out of date, run go test -update")
+	}
+
+	// 默认按go import path存放
+	req, _ = ParseRequest(calcRequest(""))
+	files, err = Generate(req)
+	if err != nil || files[0].Name != "github.com/foredata/nova/netx/codec/protobuf/protogen/example/calc.nova.go" {
+		t.Errorf("bad import path, %+v, %+v", files, err)
+	}
+}
+
+func TestGenerateError(t *testing.T) {
+	req, _ := ParseRequest(calcRequest(""))
+	req.Files[1].Services[0].Methods[1].CmdID = 101
+	if _, err := Generate(req); err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Errorf("expect cmd_id conflict, %+v", err)
+	}
+
+	req, _ = ParseRequest(calcRequest(""))
+	req.Files[1].Services[0].Methods[1].ClientStreaming = true
+	if _, err := Generate(req); err == nil {
+		t.Errorf("expect bidirectional streaming error")
+	}
+
+	req, _ = ParseRequest(calcRequest(""))
+	req.Files[1].Services[0].Methods[0].Timeout = "1x"
+	if _, err := Generate(req); err == nil {
+		t.Errorf("expect invalid timeout error")
+	}
+
+	if _, err := ParseParameter("plugins=grpc"); err == nil {
+		t.Errorf("expect unknown parameter error")
+	}
+	if _, err := ParseRequest([]byte{0x0a, 0x10}); err == nil {
+		t.Errorf("expect invalid data error")
+	}
+}
+
+func TestGoCamelCase(t *testing.T) {
+	cases := map[string]string{
+		"foo_bar":      "FooBar",
+		"Outer.Inner":  "Outer_Inner",
+		"_foo":         "XFoo",
+		"foo_1bar":     "Foo_1Bar",
+		"HTTPRequest":  "HTTPRequest",
+		"list_v2_item": "ListV2Item",
+	}
+	for in, expect := range cases {
+		if v := goCamelCase(in); v != expect {
+			t.Errorf("goCamelCase(%q) = %q, expect %q", in, v, expect)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package protobuf
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// 流式应答结束时,通过trailer返回handler错误
+const (
+	XStatusCode = "X-Status-Code"
+	XStatusInfo = "X-Status-Info"
+)
+
+// maxStreamMsgSize 流中单个消息最大长度
+const maxStreamMsgSize = 64 << 20
+
+// some error
+var (
+	ErrStreamClosed   = errors.New("protobuf: stream closed")
+	ErrMsgTooLarge    = errors.New("protobuf: stream message too large")
+	ErrBidiNotSupport = errors.New("protobuf: bidirectional streaming not support")
+)
+
+// 流式body中每个消息以uvarint长度作为前缀
+func writeMsg(w body.Writer, msg Message) error {
+	data, err := Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	buf := bytex.NewBuffer()
+	_ = bytex.WriteUvarint64(buf, uint64(len(data)))
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	return w.Write(buf)
+}
+
+func readMsg(r *bufio.Reader, msg Message) error {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if size > maxStreamMsgSize {
+		return ErrMsgTooLarge
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	return Unmarshal(data, msg)
+}
+
+// ClientStream 客户端流,ClientStreaming时通过SendMsg发送请求,CloseSend后通过RecvMsg接收应答
+//	ServerStreaming时RecvMsg返回io.EOF表示应答结束
+type ClientStream struct {
+	m      *MethodDesc
+	w      body.Writer   // 请求流
+	rsp    netx.Response // 应答
+	br     *bufio.Reader //
+	done   chan struct{} // 收到应答后关闭
+	err    error         // 调用错误
+	recved bool          // 非流式应答是否已读取
+	once   sync.Once     //
+	closed chan struct{} // 应答流读取结束后关闭
+	finish sync.Once     //
+}
+
+// NewStream 创建流式调用,in为非流式请求,ClientStreaming时需传nil
+func (c *Client) NewStream(ctx context.Context, m *MethodDesc, in Message, opts ...netx.CallOption) (*ClientStream, error) {
+	if m.ClientStreaming && m.ServerStreaming {
+		return nil, ErrBidiNotSupport
+	}
+
+	req := c.newRequest(m)
+	s := &ClientStream{m: m, done: make(chan struct{}), closed: make(chan struct{})}
+	opts = m.callOptions(opts)
+	if !m.ClientStreaming {
+		if err := req.Encode(netx.CodecTypeProtobuf, in); err != nil {
+			return nil, err
+		}
+		s.call(ctx, c.cli, req, opts)
+		if s.err != nil {
+			return nil, s.err
+		}
+		s.watch(ctx)
+		return s, nil
+	}
+
+	bd := body.NewStreamBody(nil)
+	s.w = bd.(body.Writer)
+	req.SetBody(bd)
+	go s.call(ctx, c.cli, req, opts)
+	return s, nil
+}
+
+func (s *ClientStream) call(ctx context.Context, cli netx.Client, req netx.Request, opts []netx.CallOption) {
+	rsp, err := cli.Call(ctx, req, opts...)
+	if err == nil {
+		err = checkStatus(s.m, rsp)
+	}
+	s.rsp = rsp
+	s.err = err
+	if err == nil && s.m.ServerStreaming {
+		s.br = bufio.NewReader(rsp.Body())
+	}
+	close(s.done)
+}
+
+// watch ctx取消时关闭应答流,防止RecvMsg一直阻塞
+func (s *ClientStream) watch(ctx context.Context) {
+	if !s.m.ServerStreaming || ctx.Done() == nil {
+		return
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.rsp.Body().Close()
+		case <-s.closed:
+		}
+	}()
+}
+
+func (s *ClientStream) close() {
+	s.finish.Do(func() {
+		close(s.closed)
+	})
+}
+
+// SendMsg 发送请求,仅ClientStreaming时可用
+func (s *ClientStream) SendMsg(msg Message) error {
+	if s.w == nil {
+		return ErrStreamClosed
+	}
+
+	select {
+	case <-s.done:
+		// 服务端已经提前返回
+		if s.err != nil {
+			return s.err
+		}
+		return ErrStreamClosed
+	default:
+	}
+
+	return writeMsg(s.w, msg)
+}
+
+// CloseSend 结束请求流
+func (s *ClientStream) CloseSend() error {
+	if s.w != nil {
+		s.once.Do(s.w.Flush)
+	}
+
+	return nil
+}
+
+// RecvMsg 接收应答,ServerStreaming时返回io.EOF表示结束
+func (s *ClientStream) RecvMsg(msg Message) error {
+	<-s.done
+	if s.err != nil {
+		return s.err
+	}
+
+	if !s.m.ServerStreaming {
+		if s.recved {
+			return io.EOF
+		}
+		s.recved = true
+		return s.rsp.Decode(msg)
+	}
+
+	err := readMsg(s.br, msg)
+	if err == io.EOF {
+		err = trailerError(s.m, s.rsp.Trailer())
+	}
+	if err != nil {
+		s.close()
+	}
+	return err
+}
+
+// Close 结束调用,丢弃未读取的应答
+func (s *ClientStream) Close() error {
+	_ = s.CloseSend()
+	select {
+	case <-s.done:
+		s.close()
+		if s.rsp != nil && s.rsp.Body() != nil {
+			return s.rsp.Body().Close()
+		}
+	default:
+	}
+
+	return nil
+}
+
+// trailerError 解析trailer中的错误,无错误时返回io.EOF
+func trailerError(m *MethodDesc, trailer netx.Header) error {
+	v := trailer.Get(XStatusCode)
+	if v == "" {
+		return io.EOF
+	}
+
+	code, err := strconv.Atoi(v)
+	if err != nil || code == 0 || code/100 == 2 {
+		return io.EOF
+	}
+	info := trailer.Get(XStatusInfo)
+	return netx.NewError(code, info, "protobuf: call %s fail, %s", m.Name, info)
+}
+
+// ServerStream 服务端流,ClientStreaming时通过RecvMsg读取请求,ServerStreaming时通过SendMsg发送应答
+type ServerStream struct {
+	br *bufio.Reader // 请求流
+	w  body.Writer   // 应答流
+}
+
+// RecvMsg 读取请求,返回io.EOF表示请求结束
+func (s *ServerStream) RecvMsg(msg Message) error {
+	if s.br == nil {
+		return io.EOF
+	}
+
+	return readMsg(s.br, msg)
+}
+
+// SendMsg 发送应答
+func (s *ServerStream) SendMsg(msg Message) error {
+	if s.w == nil {
+		return ErrStreamClosed
+	}
+
+	return writeMsg(s.w, msg)
+}
+
+// StreamFunc 流式方法实现,in为非流式请求,ClientStreaming时为nil
+//	ServerStreaming时返回的Message会被忽略
+type StreamFunc func(ctx context.Context, in Message, s *ServerStream) (Message, error)
+
+// StreamHandler 供生成的server stub使用,将流式方法转换为netx handler
+//	ServerStreaming时handler返回后应答头即发出,fn在独立协程中执行,错误通过trailer返回
+//	底层在应答流结束前会阻塞连接的写协程,因此不宜用于长时间的推送
+func StreamHandler(m *MethodDesc, newIn func() Message, fn StreamFunc) func(ctx context.Context, req netx.Request) (netx.Response, error) {
+	if m.ClientStreaming && m.ServerStreaming {
+		panic(ErrBidiNotSupport)
+	}
+
+	return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		var in Message
+		s := &ServerStream{}
+		if m.ClientStreaming {
+			s.br = bufio.NewReader(req.Body())
+		} else {
+			in = newIn()
+			if err := req.Decode(in); err != nil {
+				return nil, netx.BadRequest("protobuf: decode %s fail, %+v", m.Name, err)
+			}
+		}
+
+		rsp := netx.NewResponse()
+		if !m.ServerStreaming {
+			out, err := fn(ctx, in, s)
+			if err != nil {
+				return nil, err
+			}
+			if err := rsp.Encode(netx.CodecTypeProtobuf, out); err != nil {
+				return nil, err
+			}
+			return rsp, nil
+		}
+
+		bd := body.NewStreamBody(nil)
+		s.w = bd.(body.Writer)
+		rsp.SetCodec(uint32(netx.CodecTypeProtobuf))
+		rsp.SetBody(bd)
+
+		// handler返回后ctx会被取消,需要重新设置deadline
+		sctx, cancel := detach(ctx)
+		go func() {
+			defer cancel()
+			if _, err := fn(sctx, in, s); err != nil {
+				rsp.SetTrailer(errorTrailer(err))
+			}
+			s.w.Flush()
+		}()
+
+		return rsp, nil
+	}
+}
+
+func errorTrailer(err error) netx.Header {
+	code, info := http.StatusInternalServerError, err.Error()
+	if nerr, ok := err.(netx.Error); ok {
+		code, info = nerr.Code(), nerr.Status()
+		if info == "" {
+			info = nerr.Error()
+		}
+	}
+
+	trailer := netx.NewHeader()
+	trailer.Set(XStatusCode, strconv.Itoa(code))
+	trailer.Set(XStatusInfo, info)
+	return trailer
+}
+
+// detachedContext 保留ctx中的值,但不继承取消信号
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detachedContext{ctx}, deadline)
+	}
+
+	return context.WithCancel(detachedContext{ctx})
+}
@@ -0,0 +1,188 @@
+package protobuf
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/registry"
+)
+
+// DefaultRetries 幂等方法超时后默认的重试次数
+var DefaultRetries = 2
+
+// MethodDesc 方法描述,由protoc-gen-nova生成
+//	Name为/package.Service/Method形式,同时作为请求URI及路由Name
+type MethodDesc struct {
+	Name            string        // 方法全名
+	CmdID           uint32        // 命令ID,同一进程内需唯一
+	Input           string        // 请求消息全名
+	Output          string        // 应答消息全名
+	Timeout         time.Duration // 默认超时时间,0表示使用client配置
+	Idempotent      bool          // 是否幂等,幂等方法超时后允许重试
+	ClientStreaming bool          // 请求是否为流式
+	ServerStreaming bool          // 应答是否为流式
+}
+
+// Metadata 路由元信息,用于服务注册
+func (m *MethodDesc) Metadata() map[string]string {
+	md := map[string]string{
+		"request":  m.Input,
+		"response": m.Output,
+	}
+	if m.Timeout > 0 {
+		md["timeout"] = m.Timeout.String()
+	}
+	if m.Idempotent {
+		md["idempotent"] = "true"
+	}
+	if m.ClientStreaming {
+		md["client_streaming"] = "true"
+	}
+	if m.ServerStreaming {
+		md["server_streaming"] = "true"
+	}
+
+	return md
+}
+
+// Route 创建路由,handler原型见server中toEndpoint定义
+func (m *MethodDesc) Route(handler interface{}) *netx.Route {
+	return &netx.Route{
+		Name:     m.Name,
+		CmdID:    uint(m.CmdID),
+		Metadata: m.Metadata(),
+		Handler:  handler,
+	}
+}
+
+// Endpoint 服务发现中的接口描述
+func (m *MethodDesc) Endpoint() *registry.Endpoint {
+	ep := &registry.Endpoint{
+		Name:     m.Name,
+		Request:  &registry.Value{Name: "request", Type: m.Input},
+		Response: &registry.Value{Name: "response", Type: m.Output},
+		Metadata: m.Metadata(),
+	}
+	if m.CmdID != 0 {
+		ep.Metadata["cmd_id"] = strconv.Itoa(int(m.CmdID))
+	}
+
+	return ep
+}
+
+// callOptions 方法默认调用参数,放在用户参数之前,可被覆盖
+func (m *MethodDesc) callOptions(opts []netx.CallOption) []netx.CallOption {
+	if m.Timeout == 0 && !m.Idempotent {
+		return opts
+	}
+
+	res := make([]netx.CallOption, 0, len(opts)+1)
+	res = append(res, func(o *netx.CallOptions) {
+		if m.Timeout > 0 {
+			o.CallTimeout = m.Timeout
+		}
+		// 流式请求的body只能发送一次,不支持重试
+		if m.Idempotent && !m.ClientStreaming && !m.ServerStreaming && DefaultRetries > 0 {
+			o.RetryPolicy = retryTimes(DefaultRetries)
+		}
+	})
+	return append(res, opts...)
+}
+
+// retryTimes 按次数重试
+type retryTimes int
+
+func (r retryTimes) Allow(ctx context.Context, req netx.Request, retryCount int) bool {
+	return retryCount < int(r) && ctx.Err() == nil
+}
+
+// ServiceDesc 服务描述,由protoc-gen-nova生成
+type ServiceDesc struct {
+	Name    string        // 服务全名,package.Service
+	Methods []*MethodDesc //
+}
+
+// Endpoints 服务发现中的接口描述
+func (d *ServiceDesc) Endpoints() []*registry.Endpoint {
+	eps := make([]*registry.Endpoint, 0, len(d.Methods))
+	for _, m := range d.Methods {
+		eps = append(eps, m.Endpoint())
+	}
+
+	return eps
+}
+
+// Client 供生成的client stub使用
+type Client struct {
+	cli     netx.Client
+	service string
+}
+
+// NewClient service为服务发现中的服务名
+func NewClient(cli netx.Client, service string) *Client {
+	return &Client{cli: cli, service: service}
+}
+
+func (c *Client) newRequest(m *MethodDesc) netx.Request {
+	req := netx.NewRequest()
+	req.SetService(c.service)
+	req.SetURI(m.Name)
+	req.SetCmdID(m.CmdID)
+	req.SetCodec(uint32(netx.CodecTypeProtobuf))
+	return req
+}
+
+// Invoke 一元调用
+func (c *Client) Invoke(ctx context.Context, m *MethodDesc, in, out Message, opts ...netx.CallOption) error {
+	req := c.newRequest(m)
+	if err := req.Encode(netx.CodecTypeProtobuf, in); err != nil {
+		return err
+	}
+
+	rsp, err := c.cli.Call(ctx, req, m.callOptions(opts)...)
+	if err != nil {
+		return err
+	}
+	if err := checkStatus(m, rsp); err != nil {
+		return err
+	}
+
+	return rsp.Decode(out)
+}
+
+// checkStatus 应答状态码非成功时转换为error
+func checkStatus(m *MethodDesc, rsp netx.Response) error {
+	if rsp == nil {
+		return netx.InternalServerError("protobuf: call %s fail, no response", m.Name)
+	}
+
+	if code := rsp.StatusCode(); code != 0 && code/100 != 2 {
+		return netx.NewError(int(code), rsp.StatusInfo(), "protobuf: call %s fail, %s", m.Name, rsp.StatusInfo())
+	}
+
+	return nil
+}
+
+// UnaryHandler 供生成的server stub使用,将一元方法转换为netx handler
+//	相比反射方式,不依赖server中的Binder,请求固定使用protobuf编码
+func UnaryHandler(m *MethodDesc, newIn func() Message, fn func(ctx context.Context, in Message) (Message, error)) func(ctx context.Context, req netx.Request) (netx.Response, error) {
+	return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		in := newIn()
+		if err := req.Decode(in); err != nil {
+			return nil, netx.BadRequest("protobuf: decode %s fail, %+v", m.Name, err)
+		}
+
+		out, err := fn(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+
+		rsp := netx.NewResponse()
+		if err := rsp.Encode(netx.CodecTypeProtobuf, out); err != nil {
+			return nil, err
+		}
+		return rsp, nil
+	}
+}
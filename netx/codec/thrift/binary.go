@@ -0,0 +1,308 @@
+package thrift
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	binaryVersion1    = 0x80010000
+	binaryVersionMask = 0xffff0000
+)
+
+// binaryWriter TBinaryProtocol,整数使用大端编码
+type binaryWriter struct {
+	buf []byte
+}
+
+func (w *binaryWriter) Bytes() []byte {
+	return w.buf
+}
+
+func (w *binaryWriter) WriteMessageBegin(name string, typ MessageType, seqID int32) {
+	w.WriteI32(int32(uint32(binaryVersion1) | uint32(typ)))
+	w.WriteString(name)
+	w.WriteI32(seqID)
+}
+
+func (w *binaryWriter) WriteMessageEnd() {}
+
+func (w *binaryWriter) WriteStructBegin(name string) {}
+
+func (w *binaryWriter) WriteStructEnd() {}
+
+func (w *binaryWriter) WriteFieldBegin(name string, typ Type, id int16) {
+	w.buf = append(w.buf, byte(typ))
+	w.WriteI16(id)
+}
+
+func (w *binaryWriter) WriteFieldEnd() {}
+
+func (w *binaryWriter) WriteFieldStop() {
+	w.buf = append(w.buf, byte(STOP))
+}
+
+func (w *binaryWriter) WriteMapBegin(keyType, valueType Type, size int) {
+	w.buf = append(w.buf, byte(keyType), byte(valueType))
+	w.WriteI32(int32(size))
+}
+
+func (w *binaryWriter) WriteMapEnd() {}
+
+func (w *binaryWriter) WriteListBegin(elemType Type, size int) {
+	w.buf = append(w.buf, byte(elemType))
+	w.WriteI32(int32(size))
+}
+
+func (w *binaryWriter) WriteListEnd() {}
+
+func (w *binaryWriter) WriteSetBegin(elemType Type, size int) {
+	w.WriteListBegin(elemType, size)
+}
+
+func (w *binaryWriter) WriteSetEnd() {}
+
+func (w *binaryWriter) WriteBool(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *binaryWriter) WriteI8(v int8) {
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *binaryWriter) WriteI16(v int16) {
+	w.buf = append(w.buf, byte(v>>8), byte(v))
+}
+
+func (w *binaryWriter) WriteI32(v int32) {
+	w.buf = append(w.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (w *binaryWriter) WriteI64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *binaryWriter) WriteDouble(v float64) {
+	w.WriteI64(int64(math.Float64bits(v)))
+}
+
+func (w *binaryWriter) WriteString(v string) {
+	w.WriteI32(int32(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *binaryWriter) WriteBinary(v []byte) {
+	w.WriteI32(int32(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+type binaryReader struct {
+	data  []byte
+	pos   int
+	depth int // 结构体嵌套深度
+}
+
+func (r *binaryReader) next(n int) ([]byte, error) {
+	if n > len(r.data)-r.pos {
+		return nil, ErrInvalidData
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *binaryReader) readSize() (int, error) {
+	size, err := r.ReadI32()
+	if err != nil {
+		return 0, err
+	}
+
+	return checkSize(int64(size), len(r.data)-r.pos)
+}
+
+// ReadMessageBegin 兼容非strict模式
+func (r *binaryReader) ReadMessageBegin() (string, MessageType, int32, error) {
+	size, err := r.ReadI32()
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	var name string
+	var typ MessageType
+	if size < 0 {
+		if uint32(size)&binaryVersionMask != binaryVersion1 {
+			return "", 0, 0, ErrBadVersion
+		}
+		typ = MessageType(size & 0xFF)
+		if name, err = r.ReadString(); err != nil {
+			return "", 0, 0, err
+		}
+	} else {
+		n, err := checkSize(int64(size), len(r.data)-r.pos)
+		if err != nil {
+			return "", 0, 0, err
+		}
+		b, _ := r.next(n)
+		name = string(b)
+		t, err := r.ReadI8()
+		if err != nil {
+			return "", 0, 0, err
+		}
+		typ = MessageType(t)
+	}
+
+	seqID, err := r.ReadI32()
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	return name, typ, seqID, nil
+}
+
+func (r *binaryReader) ReadMessageEnd() error {
+	return nil
+}
+
+func (r *binaryReader) ReadStructBegin() error {
+	if r.depth >= maxDepth {
+		return ErrDepthLimit
+	}
+	r.depth++
+	return nil
+}
+
+func (r *binaryReader) ReadStructEnd() error {
+	r.depth--
+	return nil
+}
+
+func (r *binaryReader) ReadFieldBegin() (Type, int16, error) {
+	t, err := r.ReadI8()
+	if err != nil {
+		return STOP, 0, err
+	}
+	if Type(t) == STOP {
+		return STOP, 0, nil
+	}
+
+	id, err := r.ReadI16()
+	return Type(t), id, err
+}
+
+func (r *binaryReader) ReadFieldEnd() error {
+	return nil
+}
+
+func (r *binaryReader) ReadMapBegin() (Type, Type, int, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return STOP, STOP, 0, err
+	}
+
+	size, err := r.readSize()
+	return Type(b[0]), Type(b[1]), size, err
+}
+
+func (r *binaryReader) ReadMapEnd() error {
+	return nil
+}
+
+func (r *binaryReader) ReadListBegin() (Type, int, error) {
+	t, err := r.ReadI8()
+	if err != nil {
+		return STOP, 0, err
+	}
+
+	size, err := r.readSize()
+	return Type(t), size, err
+}
+
+func (r *binaryReader) ReadListEnd() error {
+	return nil
+}
+
+func (r *binaryReader) ReadSetBegin() (Type, int, error) {
+	return r.ReadListBegin()
+}
+
+func (r *binaryReader) ReadSetEnd() error {
+	return nil
+}
+
+func (r *binaryReader) ReadBool() (bool, error) {
+	v, err := r.ReadI8()
+	return v != 0, err
+}
+
+func (r *binaryReader) ReadI8() (int8, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return int8(b[0]), nil
+}
+
+func (r *binaryReader) ReadI16() (int16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *binaryReader) ReadI32() (int32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *binaryReader) ReadI64() (int64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(binary.BigEndian.Uint64(b)), nil
+}
+
+func (r *binaryReader) ReadDouble() (float64, error) {
+	v, err := r.ReadI64()
+	return math.Float64frombits(uint64(v)), err
+}
+
+func (r *binaryReader) ReadString() (string, error) {
+	size, err := r.readSize()
+	if err != nil {
+		return "", err
+	}
+
+	b, _ := r.next(size)
+	return string(b), nil
+}
+
+func (r *binaryReader) ReadBinary() ([]byte, error) {
+	size, err := r.readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	b, _ := r.next(size)
+	out := make([]byte, size)
+	copy(out, b)
+	return out, nil
+}
+
+func (r *binaryReader) Skip(typ Type) error {
+	return skip(r, typ, 0)
+}
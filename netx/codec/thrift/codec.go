@@ -0,0 +1,60 @@
+package thrift
+
+import (
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// New 创建binary协议Codec
+func New() netx.Codec {
+	return &thriftCodec{ctype: netx.CodecTypeThrift, name: "thrift", proto: ProtocolBinary}
+}
+
+// NewCompact 创建compact协议Codec
+func NewCompact() netx.Codec {
+	return &thriftCodec{ctype: netx.CodecTypeThriftCompact, name: "thrift_compact", proto: ProtocolCompact}
+}
+
+// ProtocolOf 返回CodecType对应的编码协议
+func ProtocolOf(ctype netx.CodecType) (Protocol, bool) {
+	switch ctype {
+	case netx.CodecTypeThrift:
+		return ProtocolBinary, true
+	case netx.CodecTypeThriftCompact:
+		return ProtocolCompact, true
+	default:
+		return ProtocolBinary, false
+	}
+}
+
+type thriftCodec struct {
+	ctype netx.CodecType
+	name  string
+	proto Protocol
+}
+
+func (c *thriftCodec) Type() netx.CodecType {
+	return c.ctype
+}
+
+func (c *thriftCodec) Name() string {
+	return c.name
+}
+
+func (c *thriftCodec) Encode(b bytex.Buffer, msg interface{}) error {
+	s, ok := msg.(Struct)
+	if !ok {
+		return ErrNotStruct
+	}
+
+	return b.Append(Marshal(c.proto, s))
+}
+
+func (c *thriftCodec) Decode(b bytex.Buffer, msg interface{}) error {
+	s, ok := msg.(Struct)
+	if !ok {
+		return ErrNotStruct
+	}
+
+	return Unmarshal(c.proto, b.Bytes(), s)
+}
@@ -0,0 +1,501 @@
+package thrift
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	compactProtocolID  = 0x82
+	compactVersion     = 1
+	compactVersionMask = 0x1f
+	compactTypeShift   = 5
+)
+
+// compact协议中的类型
+const (
+	ctStop         = 0x00
+	ctBooleanTrue  = 0x01
+	ctBooleanFalse = 0x02
+	ctByte         = 0x03
+	ctI16          = 0x04
+	ctI32          = 0x05
+	ctI64          = 0x06
+	ctDouble       = 0x07
+	ctBinary       = 0x08
+	ctList         = 0x09
+	ctSet          = 0x0A
+	ctMap          = 0x0B
+	ctStruct       = 0x0C
+)
+
+func toCompactType(t Type) byte {
+	switch t {
+	case BOOL:
+		return ctBooleanTrue
+	case BYTE:
+		return ctByte
+	case I16:
+		return ctI16
+	case I32:
+		return ctI32
+	case I64:
+		return ctI64
+	case DOUBLE:
+		return ctDouble
+	case STRING:
+		return ctBinary
+	case LIST:
+		return ctList
+	case SET:
+		return ctSet
+	case MAP:
+		return ctMap
+	case STRUCT:
+		return ctStruct
+	default:
+		return ctStop
+	}
+}
+
+func fromCompactType(t byte) (Type, error) {
+	switch t & 0x0F {
+	case ctStop:
+		return STOP, nil
+	case ctBooleanTrue, ctBooleanFalse:
+		return BOOL, nil
+	case ctByte:
+		return BYTE, nil
+	case ctI16:
+		return I16, nil
+	case ctI32:
+		return I32, nil
+	case ctI64:
+		return I64, nil
+	case ctDouble:
+		return DOUBLE, nil
+	case ctBinary:
+		return STRING, nil
+	case ctList:
+		return LIST, nil
+	case ctSet:
+		return SET, nil
+	case ctMap:
+		return MAP, nil
+	case ctStruct:
+		return STRUCT, nil
+	default:
+		return STOP, ErrInvalidData
+	}
+}
+
+// compactWriter TCompactProtocol,整数使用zigzag varint编码,字段id使用差值编码
+type compactWriter struct {
+	buf       []byte
+	lastField []int16 // 嵌套结构体的上一个字段id
+	lastID    int16   //
+	boolID    int16   // bool字段的值需要写入字段头,延迟到WriteBool
+	boolField bool    // 是否有等待写入的bool字段
+}
+
+func (w *compactWriter) Bytes() []byte {
+	return w.buf
+}
+
+func (w *compactWriter) writeVarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf = append(w.buf, b[:n]...)
+}
+
+func (w *compactWriter) writeZigzag32(v int32) {
+	w.writeVarint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+func (w *compactWriter) writeZigzag64(v int64) {
+	w.writeVarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *compactWriter) writeFieldHeader(ctype byte, id int16) {
+	if id > w.lastID && id-w.lastID <= 15 {
+		w.buf = append(w.buf, byte(id-w.lastID)<<4|ctype)
+	} else {
+		w.buf = append(w.buf, ctype)
+		w.writeZigzag32(int32(id))
+	}
+	w.lastID = id
+}
+
+func (w *compactWriter) WriteMessageBegin(name string, typ MessageType, seqID int32) {
+	w.buf = append(w.buf, compactProtocolID, compactVersion|byte(typ)<<compactTypeShift)
+	w.writeVarint(uint64(uint32(seqID)))
+	w.WriteString(name)
+}
+
+func (w *compactWriter) WriteMessageEnd() {}
+
+func (w *compactWriter) WriteStructBegin(name string) {
+	w.lastField = append(w.lastField, w.lastID)
+	w.lastID = 0
+}
+
+func (w *compactWriter) WriteStructEnd() {
+	if n := len(w.lastField); n > 0 {
+		w.lastID = w.lastField[n-1]
+		w.lastField = w.lastField[:n-1]
+	}
+}
+
+func (w *compactWriter) WriteFieldBegin(name string, typ Type, id int16) {
+	if typ == BOOL {
+		w.boolID = id
+		w.boolField = true
+		return
+	}
+
+	w.writeFieldHeader(toCompactType(typ), id)
+}
+
+func (w *compactWriter) WriteFieldEnd() {}
+
+func (w *compactWriter) WriteFieldStop() {
+	w.buf = append(w.buf, ctStop)
+}
+
+func (w *compactWriter) WriteMapBegin(keyType, valueType Type, size int) {
+	if size == 0 {
+		w.buf = append(w.buf, 0)
+		return
+	}
+
+	w.writeVarint(uint64(size))
+	w.buf = append(w.buf, toCompactType(keyType)<<4|toCompactType(valueType))
+}
+
+func (w *compactWriter) WriteMapEnd() {}
+
+func (w *compactWriter) WriteListBegin(elemType Type, size int) {
+	if size <= 14 {
+		w.buf = append(w.buf, byte(size)<<4|toCompactType(elemType))
+	} else {
+		w.buf = append(w.buf, 0xF0|toCompactType(elemType))
+		w.writeVarint(uint64(size))
+	}
+}
+
+func (w *compactWriter) WriteListEnd() {}
+
+func (w *compactWriter) WriteSetBegin(elemType Type, size int) {
+	w.WriteListBegin(elemType, size)
+}
+
+func (w *compactWriter) WriteSetEnd() {}
+
+func (w *compactWriter) WriteBool(v bool) {
+	ctype := byte(ctBooleanFalse)
+	if v {
+		ctype = ctBooleanTrue
+	}
+
+	if w.boolField {
+		w.boolField = false
+		w.writeFieldHeader(ctype, w.boolID)
+		return
+	}
+
+	w.buf = append(w.buf, ctype)
+}
+
+func (w *compactWriter) WriteI8(v int8) {
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *compactWriter) WriteI16(v int16) {
+	w.writeZigzag32(int32(v))
+}
+
+func (w *compactWriter) WriteI32(v int32) {
+	w.writeZigzag32(v)
+}
+
+func (w *compactWriter) WriteI64(v int64) {
+	w.writeZigzag64(v)
+}
+
+func (w *compactWriter) WriteDouble(v float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *compactWriter) WriteString(v string) {
+	w.writeVarint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *compactWriter) WriteBinary(v []byte) {
+	w.writeVarint(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+type compactReader struct {
+	data      []byte
+	pos       int
+	lastField []int16 //
+	lastID    int16   //
+	boolValue byte    // bool字段的值存储在字段头中
+	hasBool   bool    //
+}
+
+func (r *compactReader) next(n int) ([]byte, error) {
+	if n > len(r.data)-r.pos {
+		return nil, ErrInvalidData
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *compactReader) readByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, ErrInvalidData
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *compactReader) readVarint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, ErrInvalidData
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *compactReader) readZigzag32() (int32, error) {
+	v, err := r.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	u := uint32(v)
+	return int32(u>>1) ^ -int32(u&1), nil
+}
+
+func (r *compactReader) readZigzag64() (int64, error) {
+	v, err := r.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+func (r *compactReader) readSize() (int, error) {
+	v, err := r.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	if v > maxSize {
+		return 0, ErrSizeLimit
+	}
+
+	return checkSize(int64(v), len(r.data)-r.pos)
+}
+
+func (r *compactReader) ReadMessageBegin() (string, MessageType, int32, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if b[0] != compactProtocolID || b[1]&compactVersionMask != compactVersion {
+		return "", 0, 0, ErrBadVersion
+	}
+	typ := MessageType(b[1] >> compactTypeShift)
+	seqID, err := r.readVarint()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	name, err := r.ReadString()
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	return name, typ, int32(uint32(seqID)), nil
+}
+
+func (r *compactReader) ReadMessageEnd() error {
+	return nil
+}
+
+func (r *compactReader) ReadStructBegin() error {
+	if len(r.lastField) >= maxDepth {
+		return ErrDepthLimit
+	}
+	r.lastField = append(r.lastField, r.lastID)
+	r.lastID = 0
+	return nil
+}
+
+func (r *compactReader) ReadStructEnd() error {
+	if n := len(r.lastField); n > 0 {
+		r.lastID = r.lastField[n-1]
+		r.lastField = r.lastField[:n-1]
+	}
+	return nil
+}
+
+func (r *compactReader) ReadFieldBegin() (Type, int16, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return STOP, 0, err
+	}
+	ctype := b & 0x0F
+	if ctype == ctStop {
+		return STOP, 0, nil
+	}
+
+	var id int16
+	if delta := int16(b >> 4); delta != 0 {
+		id = r.lastID + delta
+	} else {
+		v, err := r.readZigzag32()
+		if err != nil {
+			return STOP, 0, err
+		}
+		id = int16(v)
+	}
+
+	typ, err := fromCompactType(ctype)
+	if err != nil {
+		return STOP, 0, err
+	}
+	if typ == BOOL {
+		r.boolValue = ctype
+		r.hasBool = true
+	}
+	r.lastID = id
+	return typ, id, nil
+}
+
+func (r *compactReader) ReadFieldEnd() error {
+	return nil
+}
+
+func (r *compactReader) ReadMapBegin() (Type, Type, int, error) {
+	size, err := r.readSize()
+	if err != nil || size == 0 {
+		return STOP, STOP, 0, err
+	}
+
+	b, err := r.readByte()
+	if err != nil {
+		return STOP, STOP, 0, err
+	}
+	ktype, err := fromCompactType(b >> 4)
+	if err != nil {
+		return STOP, STOP, 0, err
+	}
+	vtype, err := fromCompactType(b)
+	if err != nil {
+		return STOP, STOP, 0, err
+	}
+
+	return ktype, vtype, size, nil
+}
+
+func (r *compactReader) ReadMapEnd() error {
+	return nil
+}
+
+func (r *compactReader) ReadListBegin() (Type, int, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return STOP, 0, err
+	}
+
+	size := int(b >> 4)
+	if size == 15 {
+		if size, err = r.readSize(); err != nil {
+			return STOP, 0, err
+		}
+	}
+
+	etype, err := fromCompactType(b)
+	return etype, size, err
+}
+
+func (r *compactReader) ReadListEnd() error {
+	return nil
+}
+
+func (r *compactReader) ReadSetBegin() (Type, int, error) {
+	return r.ReadListBegin()
+}
+
+func (r *compactReader) ReadSetEnd() error {
+	return nil
+}
+
+func (r *compactReader) ReadBool() (bool, error) {
+	if r.hasBool {
+		r.hasBool = false
+		return r.boolValue == ctBooleanTrue, nil
+	}
+
+	b, err := r.readByte()
+	return b == ctBooleanTrue, err
+}
+
+func (r *compactReader) ReadI8() (int8, error) {
+	b, err := r.readByte()
+	return int8(b), err
+}
+
+func (r *compactReader) ReadI16() (int16, error) {
+	v, err := r.readZigzag32()
+	return int16(v), err
+}
+
+func (r *compactReader) ReadI32() (int32, error) {
+	return r.readZigzag32()
+}
+
+func (r *compactReader) ReadI64() (int64, error) {
+	return r.readZigzag64()
+}
+
+func (r *compactReader) ReadDouble() (float64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (r *compactReader) ReadString() (string, error) {
+	size, err := r.readSize()
+	if err != nil {
+		return "", err
+	}
+
+	b, _ := r.next(size)
+	return string(b), nil
+}
+
+func (r *compactReader) ReadBinary() ([]byte, error) {
+	size, err := r.readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	b, _ := r.next(size)
+	out := make([]byte, size)
+	copy(out, b)
+	return out, nil
+}
+
+func (r *compactReader) Skip(typ Type) error {
+	return skip(r, typ, 0)
+}
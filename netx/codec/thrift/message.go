@@ -0,0 +1,139 @@
+package thrift
+
+import (
+	"fmt"
+)
+
+// Message Thrift消息,包含方法名,类型及序号,用于与原生Thrift服务互通
+//	解码时需要预先设置Body,若应答为异常,Body会被替换为*ApplicationException
+type Message struct {
+	Name  string      // 方法名
+	Type  MessageType // 消息类型
+	SeqID int32       // 序号,原生Thrift服务会原样返回
+	Body  Struct      // 参数或结果
+}
+
+func (m *Message) Write(w Writer) {
+	w.WriteMessageBegin(m.Name, m.Type, m.SeqID)
+	if m.Body != nil {
+		m.Body.Write(w)
+	} else {
+		w.WriteStructBegin("")
+		w.WriteFieldStop()
+		w.WriteStructEnd()
+	}
+	w.WriteMessageEnd()
+}
+
+func (m *Message) Read(r Reader) error {
+	var err error
+	if m.Name, m.Type, m.SeqID, err = r.ReadMessageBegin(); err != nil {
+		return err
+	}
+
+	switch {
+	case m.Type == MessageException:
+		ex := &ApplicationException{}
+		if err := ex.Read(r); err != nil {
+			return err
+		}
+		m.Body = ex
+	case m.Type < MessageCall || m.Type > MessageOneway:
+		return ErrUnknownMessage
+	case m.Body == nil:
+		if err := r.Skip(STRUCT); err != nil {
+			return err
+		}
+	default:
+		if err := m.Body.Read(r); err != nil {
+			return err
+		}
+	}
+
+	return r.ReadMessageEnd()
+}
+
+// ExceptionType ApplicationException类型
+type ExceptionType int32
+
+const (
+	ExceptionUnknown            ExceptionType = 0
+	ExceptionUnknownMethod      ExceptionType = 1
+	ExceptionInvalidMessageType ExceptionType = 2
+	ExceptionWrongMethodName    ExceptionType = 3
+	ExceptionBadSequenceID      ExceptionType = 4
+	ExceptionMissingResult      ExceptionType = 5
+	ExceptionInternalError      ExceptionType = 6
+	ExceptionProtocolError      ExceptionType = 7
+)
+
+// ApplicationException 框架层异常,IDL中未声明的错误均以此返回
+type ApplicationException struct {
+	Message string
+	Type    ExceptionType
+}
+
+// NewApplicationException 创建ApplicationException
+func NewApplicationException(typ ExceptionType, message string) *ApplicationException {
+	return &ApplicationException{Type: typ, Message: message}
+}
+
+func (e *ApplicationException) Error() string {
+	return fmt.Sprintf("thrift: application exception, type=%d, message=%s", e.Type, e.Message)
+}
+
+func (e *ApplicationException) Write(w Writer) {
+	w.WriteStructBegin("TApplicationException")
+	w.WriteFieldBegin("message", STRING, 1)
+	w.WriteString(e.Message)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("type", I32, 2)
+	w.WriteI32(int32(e.Type))
+	w.WriteFieldEnd()
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (e *ApplicationException) Read(r Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == STOP {
+			break
+		}
+
+		switch {
+		case id == 1 && typ == STRING:
+			if e.Message, err = r.ReadString(); err != nil {
+				return err
+			}
+		case id == 2 && typ == I32:
+			v, err := r.ReadI32()
+			if err != nil {
+				return err
+			}
+			e.Type = ExceptionType(v)
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	return r.ReadStructEnd()
+}
+
+// MissingField 缺少required字段时返回的错误,用于生成代码
+func MissingField(structName, fieldName string) error {
+	return fmt.Errorf("thrift: required field %s.%s is not set", structName, fieldName)
+}
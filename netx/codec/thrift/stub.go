@@ -0,0 +1,109 @@
+package thrift
+
+import (
+	"context"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Client 供生成的client stub使用,负责Thrift消息的封装及应答解析
+type Client struct {
+	cli     netx.Client
+	service string
+	codec   netx.CodecType
+}
+
+// NewClient codec为CodecTypeThrift或CodecTypeThriftCompact,其他值使用binary协议
+func NewClient(cli netx.Client, service string, codec netx.CodecType) *Client {
+	if _, ok := ProtocolOf(codec); !ok {
+		codec = netx.CodecTypeThrift
+	}
+
+	return &Client{cli: cli, service: service, codec: codec}
+}
+
+// Invoke 调用method,result为nil表示oneway调用
+//	应答为ApplicationException时以error返回,IDL中声明的异常由生成代码从result中解析
+func (c *Client) Invoke(ctx context.Context, method string, cmdID uint32, args, result Struct, opts ...netx.CallOption) error {
+	req := netx.NewRequest()
+	req.SetService(c.service)
+	req.SetURI(method)
+	req.SetCmdID(cmdID)
+	typ := MessageCall
+	if result == nil {
+		typ = MessageOneway
+		req.SetOneway(true)
+	}
+	if err := req.Encode(c.codec, &Message{Name: method, Type: typ, Body: args}); err != nil {
+		return err
+	}
+
+	rsp, err := c.cli.Call(ctx, req, opts...)
+	if err != nil || result == nil {
+		return err
+	}
+	if rsp == nil {
+		return ErrMissingResult
+	}
+
+	if code := rsp.StatusCode(); code != 0 && code/100 != 2 {
+		// 服务端异常时,应答中可能携带ApplicationException
+		msg := &Message{}
+		if rsp.Decode(msg) == nil {
+			if ex, ok := msg.Body.(*ApplicationException); ok {
+				return ex
+			}
+		}
+		return netx.NewError(int(code), rsp.StatusInfo(), "thrift: call %s fail", method)
+	}
+
+	msg := &Message{Body: result}
+	if err := rsp.Decode(msg); err != nil {
+		return err
+	}
+	if ex, ok := msg.Body.(*ApplicationException); ok {
+		return ex
+	}
+
+	return nil
+}
+
+// HandlerFunc 生成代码中的方法实现,返回nil表示oneway
+type HandlerFunc func(ctx context.Context, args Struct) (Struct, error)
+
+// Handler 供生成的server stub使用,将Thrift方法转换为netx handler
+//	未在IDL中声明的错误会以ApplicationException返回,同时设置应答状态码
+func Handler(method string, newArgs func() Struct, fn HandlerFunc) func(ctx context.Context, req netx.Request) (netx.Response, error) {
+	return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		args := newArgs()
+		msg := &Message{Body: args}
+		if err := req.Decode(msg); err != nil {
+			return nil, netx.BadRequest("thrift: decode %s fail, %+v", method, err)
+		}
+		if msg.Type == MessageOneway {
+			req.SetOneway(true)
+		}
+
+		result, err := fn(ctx, args)
+		if req.IsOneway() {
+			return nil, err
+		}
+
+		reply := &Message{Name: method, Type: MessageReply, SeqID: msg.SeqID, Body: result}
+		if err != nil {
+			reply.Type = MessageException
+			reply.Body = NewApplicationException(ExceptionInternalError, err.Error())
+		}
+
+		codec := netx.CodecType(req.Codec())
+		if _, ok := ProtocolOf(codec); !ok {
+			codec = netx.CodecTypeThrift
+		}
+		rsp := netx.NewResponse()
+		if e := rsp.Encode(codec, reply); e != nil {
+			return nil, e
+		}
+
+		return rsp, err
+	}
+}
@@ -0,0 +1,285 @@
+// Package thrift Thrift binary和compact协议编解码
+//	消息需要实现Struct接口,通常由thriftgen根据IDL生成
+//	Message用于与原生Thrift服务互通,会额外编码方法名,消息类型及序号
+package thrift
+
+import (
+	"errors"
+	"fmt"
+)
+
+// some error
+var (
+	ErrNotStruct      = errors.New("thrift: not struct")
+	ErrInvalidData    = errors.New("thrift: invalid data")
+	ErrBadVersion     = errors.New("thrift: bad version")
+	ErrDepthLimit     = errors.New("thrift: depth limit exceeded")
+	ErrSizeLimit      = errors.New("thrift: size limit exceeded")
+	ErrMissingResult  = errors.New("thrift: missing result")
+	ErrUnknownMessage = errors.New("thrift: unknown message type")
+)
+
+// Type 字段类型
+type Type uint8
+
+const (
+	STOP   Type = 0
+	VOID   Type = 1
+	BOOL   Type = 2
+	BYTE   Type = 3
+	DOUBLE Type = 4
+	I16    Type = 6
+	I32    Type = 8
+	I64    Type = 10
+	STRING Type = 11
+	STRUCT Type = 12
+	MAP    Type = 13
+	SET    Type = 14
+	LIST   Type = 15
+)
+
+func (t Type) String() string {
+	switch t {
+	case STOP:
+		return "STOP"
+	case VOID:
+		return "VOID"
+	case BOOL:
+		return "BOOL"
+	case BYTE:
+		return "BYTE"
+	case DOUBLE:
+		return "DOUBLE"
+	case I16:
+		return "I16"
+	case I32:
+		return "I32"
+	case I64:
+		return "I64"
+	case STRING:
+		return "STRING"
+	case STRUCT:
+		return "STRUCT"
+	case MAP:
+		return "MAP"
+	case SET:
+		return "SET"
+	case LIST:
+		return "LIST"
+	default:
+		return fmt.Sprintf("Type(%d)", t)
+	}
+}
+
+// MessageType 消息类型
+type MessageType uint8
+
+const (
+	MessageCall      MessageType = 1
+	MessageReply     MessageType = 2
+	MessageException MessageType = 3
+	MessageOneway    MessageType = 4
+)
+
+// Protocol 编码协议
+type Protocol uint8
+
+const (
+	ProtocolBinary  Protocol = iota // TBinaryProtocol,strict模式
+	ProtocolCompact                 // TCompactProtocol
+)
+
+const (
+	maxDepth = 64       // 嵌套最大深度
+	maxSize  = 64 << 20 // 字符串及容器最大长度
+)
+
+// Struct 可编解码的Thrift结构体
+//	Write不返回错误,底层写入内存不会失败
+type Struct interface {
+	Write(w Writer)
+	Read(r Reader) error
+}
+
+// Writer 协议写入接口,Begin/End需要成对调用
+type Writer interface {
+	WriteMessageBegin(name string, typ MessageType, seqID int32)
+	WriteMessageEnd()
+	WriteStructBegin(name string)
+	WriteStructEnd()
+	WriteFieldBegin(name string, typ Type, id int16)
+	WriteFieldEnd()
+	WriteFieldStop()
+	WriteMapBegin(keyType, valueType Type, size int)
+	WriteMapEnd()
+	WriteListBegin(elemType Type, size int)
+	WriteListEnd()
+	WriteSetBegin(elemType Type, size int)
+	WriteSetEnd()
+	WriteBool(v bool)
+	WriteI8(v int8)
+	WriteI16(v int16)
+	WriteI32(v int32)
+	WriteI64(v int64)
+	WriteDouble(v float64)
+	WriteString(v string)
+	WriteBinary(v []byte)
+	// Bytes 返回已编码数据
+	Bytes() []byte
+}
+
+// Reader 协议读取接口
+type Reader interface {
+	ReadMessageBegin() (name string, typ MessageType, seqID int32, err error)
+	ReadMessageEnd() error
+	ReadStructBegin() error
+	ReadStructEnd() error
+	ReadFieldBegin() (typ Type, id int16, err error)
+	ReadFieldEnd() error
+	ReadMapBegin() (keyType, valueType Type, size int, err error)
+	ReadMapEnd() error
+	ReadListBegin() (elemType Type, size int, err error)
+	ReadListEnd() error
+	ReadSetBegin() (elemType Type, size int, err error)
+	ReadSetEnd() error
+	ReadBool() (bool, error)
+	ReadI8() (int8, error)
+	ReadI16() (int16, error)
+	ReadI32() (int32, error)
+	ReadI64() (int64, error)
+	ReadDouble() (float64, error)
+	ReadString() (string, error)
+	ReadBinary() ([]byte, error)
+	// Skip 跳过未知字段
+	Skip(typ Type) error
+}
+
+// NewWriter 创建Writer
+func NewWriter(p Protocol) Writer {
+	if p == ProtocolCompact {
+		return &compactWriter{}
+	}
+
+	return &binaryWriter{}
+}
+
+// NewReader 创建Reader,data在读取期间不能修改
+func NewReader(p Protocol, data []byte) Reader {
+	if p == ProtocolCompact {
+		return &compactReader{data: data}
+	}
+
+	return &binaryReader{data: data}
+}
+
+// Marshal 编码Struct
+func Marshal(p Protocol, s Struct) []byte {
+	w := NewWriter(p)
+	s.Write(w)
+	return w.Bytes()
+}
+
+// Unmarshal 解码Struct
+func Unmarshal(p Protocol, data []byte, s Struct) error {
+	return s.Read(NewReader(p, data))
+}
+
+// skip 跳过指定类型数据,用于兼容新增字段
+func skip(r Reader, typ Type, depth int) error {
+	if depth > maxDepth {
+		return ErrDepthLimit
+	}
+
+	var err error
+	switch typ {
+	case BOOL:
+		_, err = r.ReadBool()
+	case BYTE:
+		_, err = r.ReadI8()
+	case I16:
+		_, err = r.ReadI16()
+	case I32:
+		_, err = r.ReadI32()
+	case I64:
+		_, err = r.ReadI64()
+	case DOUBLE:
+		_, err = r.ReadDouble()
+	case STRING:
+		_, err = r.ReadBinary()
+	case STRUCT:
+		if err = r.ReadStructBegin(); err != nil {
+			return err
+		}
+		for {
+			ftype, _, err := r.ReadFieldBegin()
+			if err != nil {
+				return err
+			}
+			if ftype == STOP {
+				break
+			}
+			if err := skip(r, ftype, depth+1); err != nil {
+				return err
+			}
+			if err := r.ReadFieldEnd(); err != nil {
+				return err
+			}
+		}
+		err = r.ReadStructEnd()
+	case MAP:
+		ktype, vtype, size, err := r.ReadMapBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, ktype, depth+1); err != nil {
+				return err
+			}
+			if err := skip(r, vtype, depth+1); err != nil {
+				return err
+			}
+		}
+		return r.ReadMapEnd()
+	case SET:
+		etype, size, err := r.ReadSetBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, etype, depth+1); err != nil {
+				return err
+			}
+		}
+		return r.ReadSetEnd()
+	case LIST:
+		etype, size, err := r.ReadListBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, etype, depth+1); err != nil {
+				return err
+			}
+		}
+		return r.ReadListEnd()
+	default:
+		return fmt.Errorf("thrift: unknown type %d", typ)
+	}
+
+	return err
+}
+
+// checkSize 校验长度,避免异常数据导致分配过大内存
+func checkSize(size int64, remain int) (int, error) {
+	if size < 0 {
+		return 0, ErrInvalidData
+	}
+	if size > maxSize {
+		return 0, ErrSizeLimit
+	}
+	if size > int64(remain) {
+		return 0, ErrInvalidData
+	}
+
+	return int(size), nil
+}
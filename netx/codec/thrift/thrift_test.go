@@ -0,0 +1,114 @@
+package thrift
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testStruct struct {
+	ID    int32
+	Flag  bool
+	Names []string
+}
+
+func (s *testStruct) Write(w Writer) {
+	w.WriteStructBegin("testStruct")
+	w.WriteFieldBegin("id", I32, 1)
+	w.WriteI32(s.ID)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("flag", BOOL, 2)
+	w.WriteBool(s.Flag)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("names", LIST, 20)
+	w.WriteListBegin(STRING, len(s.Names))
+	for _, v := range s.Names {
+		w.WriteString(v)
+	}
+	w.WriteListEnd()
+	w.WriteFieldEnd()
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (s *testStruct) Read(r Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == I32:
+			if s.ID, err = r.ReadI32(); err != nil {
+				return err
+			}
+		case id == 2 && typ == BOOL:
+			if s.Flag, err = r.ReadBool(); err != nil {
+				return err
+			}
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	return r.ReadStructEnd()
+}
+
+func TestStruct(t *testing.T) {
+	for _, p := range []Protocol{ProtocolBinary, ProtocolCompact} {
+		src := &testStruct{ID: -5, Flag: true, Names: []string{"a", "bc"}}
+		data := Marshal(p, src)
+		dst := &testStruct{}
+		if err := Unmarshal(p, data, dst); err != nil {
+			t.Fatal(err)
+		}
+		// names字段未解析,需要被跳过
+		if dst.ID != src.ID || !dst.Flag || dst.Names != nil {
+			t.Errorf("bad struct, %+v", dst)
+		}
+
+		for i := 0; i < len(data)-1; i++ {
+			if err := Unmarshal(p, data[:i], &testStruct{}); err == nil {
+				t.Errorf("expect error on truncated data, protocol=%v, len=%d", p, i)
+			}
+		}
+	}
+}
+
+func TestCompactBytes(t *testing.T) {
+	data := Marshal(ProtocolCompact, &testStruct{ID: 1, Flag: true})
+	// 0x15: delta=1,type=i32; 0x02: zigzag(1); 0x11: delta=1,type=true; 0x09 0x28: type=list,id=20; 0x08: size=0,type=binary
+	expect := []byte{0x15, 0x02, 0x11, 0x09, 0x28, 0x08, 0x00}
+	if !bytes.Equal(data, expect) {
+		t.Errorf("bad compact data, %x", data)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	for _, p := range []Protocol{ProtocolBinary, ProtocolCompact} {
+		src := &Message{Name: "add", Type: MessageException, SeqID: 7, Body: NewApplicationException(ExceptionUnknownMethod, "no method")}
+		dst := &Message{Body: &testStruct{}}
+		if err := Unmarshal(p, Marshal(p, src), dst); err != nil {
+			t.Fatal(err)
+		}
+		ex, ok := dst.Body.(*ApplicationException)
+		if dst.Name != "add" || dst.SeqID != 7 || !ok || ex.Type != ExceptionUnknownMethod || ex.Message != "no method" {
+			t.Errorf("bad message, %+v", dst)
+		}
+
+		src = &Message{Name: "count", Type: MessageReply, SeqID: 1, Body: &testStruct{ID: 3}}
+		dst = &Message{}
+		if err := Unmarshal(p, Marshal(p, src), dst); err != nil || dst.Type != MessageReply {
+			t.Errorf("skip body fail, %+v, %+v", dst, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package thriftgen
+
+// Document 一个.thrift文件
+type Document struct {
+	Path       string            // 文件路径
+	Namespaces map[string]string // scope -> namespace
+	Includes   []*Document       // 依赖文件,类型通过"文件名.类型"引用
+	Typedefs   []*Typedef        //
+	Consts     []*Const          //
+	Enums      []*Enum           //
+	Structs    []*Struct         // 包含struct,union,exception
+	Services   []*Service        //
+}
+
+// Type 字段类型
+type Type struct {
+	Name      string // 基础类型名,容器名(list,set,map)或自定义类型名
+	KeyType   *Type  // map key类型
+	ValueType *Type  // map value类型,list/set元素类型
+}
+
+// Typedef 类型别名
+type Typedef struct {
+	Name string
+	Type *Type
+}
+
+// ConstValue 常量值,Kind为int,double,string,ident,list,map
+type ConstValue struct {
+	Kind  string
+	Value string        // 字面值或标识符
+	List  []*ConstValue // list/set元素
+	Keys  []*ConstValue // map key
+	Vals  []*ConstValue // map value
+}
+
+// Const 常量
+type Const struct {
+	Name  string
+	Type  *Type
+	Value *ConstValue
+}
+
+// EnumValue 枚举值
+type EnumValue struct {
+	Name  string
+	Value int64
+}
+
+// Enum 枚举
+type Enum struct {
+	Name   string
+	Values []*EnumValue
+}
+
+// Requiredness 字段是否必填
+type Requiredness int
+
+const (
+	Default Requiredness = iota
+	Required
+	Optional
+)
+
+// Field 结构体字段,函数参数及异常
+type Field struct {
+	ID       int16
+	Name     string
+	Type     *Type
+	Required Requiredness
+	Default  *ConstValue
+}
+
+// StructKind 结构体类型
+type StructKind string
+
+const (
+	KindStruct    StructKind = "struct"
+	KindUnion     StructKind = "union"
+	KindException StructKind = "exception"
+)
+
+// Struct struct,union或exception
+type Struct struct {
+	Kind   StructKind
+	Name   string
+	Fields []*Field
+}
+
+// Function 服务方法
+type Function struct {
+	Name        string
+	Oneway      bool
+	Return      *Type // nil表示void
+	Args        []*Field
+	Throws      []*Field
+	Annotations map[string]string
+}
+
+// Service 服务定义
+type Service struct {
+	Name        string
+	Extends     string
+	Functions   []*Function
+	Annotations map[string]string
+}
@@ -0,0 +1,51 @@
+// 根据Thrift IDL生成Go结构体,client stub及server注册代码
+//	go run ./netx/codec/thrift/thriftgen/cmd -out ./calc calc.thrift
+//	include的文件需要同时生成到同一个包中
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/foredata/nova/netx/codec/thrift/thriftgen"
+)
+
+func main() {
+	out := flag.String("out", ".", "output directory")
+	pkg := flag.String("package", "", "go package name, default use namespace go or file name")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: thriftgen [-out dir] [-package name] file.thrift...\n")
+		os.Exit(2)
+	}
+
+	parser := thriftgen.NewParser()
+	for _, file := range flag.Args() {
+		doc, err := parser.ParseFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "parse fail, %+v\n", err)
+			os.Exit(1)
+		}
+
+		var opts []thriftgen.Option
+		if *pkg != "" {
+			opts = append(opts, thriftgen.WithPackage(*pkg))
+		}
+		src, err := thriftgen.Generate(doc, opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "generate %s fail, %+v\n", file, err)
+			os.Exit(1)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) + ".go"
+		if err := ioutil.WriteFile(filepath.Join(*out, name), src, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "write %s fail, %+v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+namespace go example
+
+typedef i64 UserID
+
+enum Status {
+    OK = 0,
+    NOT_FOUND = 404,
+    INTERNAL_ERROR = 500,
+}
+
+exception ServiceError {
+    1: required Status status
+    2: optional string message
+}
+
+service Base {
+    string ping(1: string text)
+} (nova.cmd_base = "100")
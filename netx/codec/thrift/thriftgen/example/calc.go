@@ -0,0 +1,1022 @@
+// Code generated by thriftgen. DO NOT EDIT.
+// source: calc.thrift
+
+package example
+
+import (
+	"context"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/codec/thrift"
+)
+
+const MaxItems int32 = 16
+
+const Version string = "1.0"
+
+var DefaultTags = []string{"a", "b"}
+
+var Limits = map[string]int32{"small": 1, "large": 100}
+
+type Item struct {
+	OwnerID UserID             `thrift:"owner_id,1,required" json:"owner_id,omitempty"`
+	Name    string             `thrift:"name,2" json:"name,omitempty"`
+	Count   *int32             `thrift:"count,3,optional" json:"count,omitempty"`
+	Price   *float64           `thrift:"price,4,optional" json:"price,omitempty"`
+	Data    []byte             `thrift:"data,5" json:"data,omitempty"`
+	Tags    []string           `thrift:"tags,6" json:"tags,omitempty"`
+	Ids     []int64            `thrift:"ids,7" json:"ids,omitempty"`
+	Groups  map[string][]int32 `thrift:"groups,8" json:"groups,omitempty"`
+	Status  Status             `thrift:"status,9" json:"status,omitempty"`
+	Enabled *bool              `thrift:"enabled,10,optional" json:"enabled,omitempty"`
+	Flag    int8               `thrift:"flag,11" json:"flag,omitempty"`
+	Level   int16              `thrift:"level,12" json:"level,omitempty"`
+}
+
+func NewItem() *Item {
+	p := &Item{}
+	p.Name = "unnamed"
+	v1 := float64(1.5)
+	p.Price = &v1
+	p.Status = StatusOk
+	return p
+}
+
+func (p *Item) Write(w thrift.Writer) {
+	w.WriteStructBegin("Item")
+	w.WriteFieldBegin("owner_id", thrift.I64, 1)
+	w.WriteI64(p.OwnerID)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("name", thrift.STRING, 2)
+	w.WriteString(p.Name)
+	w.WriteFieldEnd()
+	if p.Count != nil {
+		w.WriteFieldBegin("count", thrift.I32, 3)
+		w.WriteI32(*p.Count)
+		w.WriteFieldEnd()
+	}
+	if p.Price != nil {
+		w.WriteFieldBegin("price", thrift.DOUBLE, 4)
+		w.WriteDouble(*p.Price)
+		w.WriteFieldEnd()
+	}
+	w.WriteFieldBegin("data", thrift.STRING, 5)
+	w.WriteBinary(p.Data)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("tags", thrift.LIST, 6)
+	w.WriteListBegin(thrift.STRING, len(p.Tags))
+	for _, e2 := range p.Tags {
+		w.WriteString(e2)
+	}
+	w.WriteListEnd()
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("ids", thrift.SET, 7)
+	w.WriteSetBegin(thrift.I64, len(p.Ids))
+	for _, e3 := range p.Ids {
+		w.WriteI64(e3)
+	}
+	w.WriteSetEnd()
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("groups", thrift.MAP, 8)
+	w.WriteMapBegin(thrift.STRING, thrift.LIST, len(p.Groups))
+	for k4, v5 := range p.Groups {
+		w.WriteString(k4)
+		w.WriteListBegin(thrift.I32, len(v5))
+		for _, e6 := range v5 {
+			w.WriteI32(e6)
+		}
+		w.WriteListEnd()
+	}
+	w.WriteMapEnd()
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("status", thrift.I32, 9)
+	w.WriteI32(int32(p.Status))
+	w.WriteFieldEnd()
+	if p.Enabled != nil {
+		w.WriteFieldBegin("enabled", thrift.BOOL, 10)
+		w.WriteBool(*p.Enabled)
+		w.WriteFieldEnd()
+	}
+	w.WriteFieldBegin("flag", thrift.BYTE, 11)
+	w.WriteI8(p.Flag)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("level", thrift.I16, 12)
+	w.WriteI16(p.Level)
+	w.WriteFieldEnd()
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *Item) Read(r thrift.Reader) error {
+	issetOwnerID := false
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == thrift.I64:
+			v7, err := r.ReadI64()
+			if err != nil {
+				return err
+			}
+			p.OwnerID = v7
+			issetOwnerID = true
+		case id == 2 && typ == thrift.STRING:
+			v8, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			p.Name = v8
+		case id == 3 && typ == thrift.I32:
+			v9, err := r.ReadI32()
+			if err != nil {
+				return err
+			}
+			p.Count = &v9
+		case id == 4 && typ == thrift.DOUBLE:
+			v10, err := r.ReadDouble()
+			if err != nil {
+				return err
+			}
+			p.Price = &v10
+		case id == 5 && typ == thrift.STRING:
+			v11, err := r.ReadBinary()
+			if err != nil {
+				return err
+			}
+			p.Data = v11
+		case id == 6 && typ == thrift.LIST:
+			_, size12, err := r.ReadListBegin()
+			if err != nil {
+				return err
+			}
+			v13 := make([]string, 0, size12)
+			for i14 := 0; i14 < size12; i14++ {
+				var e15 string
+				v16, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+				e15 = v16
+				v13 = append(v13, e15)
+			}
+			if err := r.ReadListEnd(); err != nil {
+				return err
+			}
+			p.Tags = v13
+		case id == 7 && typ == thrift.SET:
+			_, size17, err := r.ReadSetBegin()
+			if err != nil {
+				return err
+			}
+			v18 := make([]int64, 0, size17)
+			for i19 := 0; i19 < size17; i19++ {
+				var e20 int64
+				v21, err := r.ReadI64()
+				if err != nil {
+					return err
+				}
+				e20 = v21
+				v18 = append(v18, e20)
+			}
+			if err := r.ReadSetEnd(); err != nil {
+				return err
+			}
+			p.Ids = v18
+		case id == 8 && typ == thrift.MAP:
+			_, _, size22, err := r.ReadMapBegin()
+			if err != nil {
+				return err
+			}
+			m23 := make(map[string][]int32, size22)
+			for i24 := 0; i24 < size22; i24++ {
+				var k25 string
+				v27, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+				k25 = v27
+				var v26 []int32
+				_, size28, err := r.ReadListBegin()
+				if err != nil {
+					return err
+				}
+				v29 := make([]int32, 0, size28)
+				for i30 := 0; i30 < size28; i30++ {
+					var e31 int32
+					v32, err := r.ReadI32()
+					if err != nil {
+						return err
+					}
+					e31 = v32
+					v29 = append(v29, e31)
+				}
+				if err := r.ReadListEnd(); err != nil {
+					return err
+				}
+				v26 = v29
+				m23[k25] = v26
+			}
+			if err := r.ReadMapEnd(); err != nil {
+				return err
+			}
+			p.Groups = m23
+		case id == 9 && typ == thrift.I32:
+			v33, err := r.ReadI32()
+			if err != nil {
+				return err
+			}
+			p.Status = Status(v33)
+		case id == 10 && typ == thrift.BOOL:
+			v35, err := r.ReadBool()
+			if err != nil {
+				return err
+			}
+			p.Enabled = &v35
+		case id == 11 && typ == thrift.BYTE:
+			v36, err := r.ReadI8()
+			if err != nil {
+				return err
+			}
+			p.Flag = v36
+		case id == 12 && typ == thrift.I16:
+			v37, err := r.ReadI16()
+			if err != nil {
+				return err
+			}
+			p.Level = v37
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	if !issetOwnerID {
+		return thrift.MissingField("Item", "owner_id")
+	}
+	return nil
+}
+
+type Value struct {
+	IntValue *int64  `thrift:"int_value,1,optional" json:"int_value,omitempty"`
+	StrValue *string `thrift:"str_value,2,optional" json:"str_value,omitempty"`
+	Item     *Item   `thrift:"item,3,optional" json:"item,omitempty"`
+}
+
+func NewValue() *Value {
+	return &Value{}
+}
+
+func (p *Value) Write(w thrift.Writer) {
+	w.WriteStructBegin("Value")
+	if p.IntValue != nil {
+		w.WriteFieldBegin("int_value", thrift.I64, 1)
+		w.WriteI64(*p.IntValue)
+		w.WriteFieldEnd()
+	}
+	if p.StrValue != nil {
+		w.WriteFieldBegin("str_value", thrift.STRING, 2)
+		w.WriteString(*p.StrValue)
+		w.WriteFieldEnd()
+	}
+	if p.Item != nil {
+		w.WriteFieldBegin("item", thrift.STRUCT, 3)
+		p.Item.Write(w)
+		w.WriteFieldEnd()
+	}
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *Value) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == thrift.I64:
+			v38, err := r.ReadI64()
+			if err != nil {
+				return err
+			}
+			p.IntValue = &v38
+		case id == 2 && typ == thrift.STRING:
+			v39, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			p.StrValue = &v39
+		case id == 3 && typ == thrift.STRUCT:
+			v40 := NewItem()
+			if err := v40.Read(r); err != nil {
+				return err
+			}
+			p.Item = v40
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type AddRequest struct {
+	A      int32             `thrift:"a,1" json:"a,omitempty"`
+	B      int32             `thrift:"b,2" json:"b,omitempty"`
+	Items  []*Item           `thrift:"items,3,optional" json:"items,omitempty"`
+	Values map[string]*Value `thrift:"values,4" json:"values,omitempty"`
+}
+
+func NewAddRequest() *AddRequest {
+	return &AddRequest{}
+}
+
+func (p *AddRequest) Write(w thrift.Writer) {
+	w.WriteStructBegin("AddRequest")
+	w.WriteFieldBegin("a", thrift.I32, 1)
+	w.WriteI32(p.A)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("b", thrift.I32, 2)
+	w.WriteI32(p.B)
+	w.WriteFieldEnd()
+	if p.Items != nil {
+		w.WriteFieldBegin("items", thrift.LIST, 3)
+		w.WriteListBegin(thrift.STRUCT, len(p.Items))
+		for _, e41 := range p.Items {
+			e41.Write(w)
+		}
+		w.WriteListEnd()
+		w.WriteFieldEnd()
+	}
+	w.WriteFieldBegin("values", thrift.MAP, 4)
+	w.WriteMapBegin(thrift.STRING, thrift.STRUCT, len(p.Values))
+	for k42, v43 := range p.Values {
+		w.WriteString(k42)
+		v43.Write(w)
+	}
+	w.WriteMapEnd()
+	w.WriteFieldEnd()
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *AddRequest) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == thrift.I32:
+			v44, err := r.ReadI32()
+			if err != nil {
+				return err
+			}
+			p.A = v44
+		case id == 2 && typ == thrift.I32:
+			v45, err := r.ReadI32()
+			if err != nil {
+				return err
+			}
+			p.B = v45
+		case id == 3 && typ == thrift.LIST:
+			_, size46, err := r.ReadListBegin()
+			if err != nil {
+				return err
+			}
+			v47 := make([]*Item, 0, size46)
+			for i48 := 0; i48 < size46; i48++ {
+				var e49 *Item
+				v50 := NewItem()
+				if err := v50.Read(r); err != nil {
+					return err
+				}
+				e49 = v50
+				v47 = append(v47, e49)
+			}
+			if err := r.ReadListEnd(); err != nil {
+				return err
+			}
+			p.Items = v47
+		case id == 4 && typ == thrift.MAP:
+			_, _, size51, err := r.ReadMapBegin()
+			if err != nil {
+				return err
+			}
+			m52 := make(map[string]*Value, size51)
+			for i53 := 0; i53 < size51; i53++ {
+				var k54 string
+				v56, err := r.ReadString()
+				if err != nil {
+					return err
+				}
+				k54 = v56
+				var v55 *Value
+				v57 := NewValue()
+				if err := v57.Read(r); err != nil {
+					return err
+				}
+				v55 = v57
+				m52[k54] = v55
+			}
+			if err := r.ReadMapEnd(); err != nil {
+				return err
+			}
+			p.Values = m52
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type AddResponse struct {
+	Sum int32 `thrift:"sum,1" json:"sum,omitempty"`
+}
+
+func NewAddResponse() *AddResponse {
+	return &AddResponse{}
+}
+
+func (p *AddResponse) Write(w thrift.Writer) {
+	w.WriteStructBegin("AddResponse")
+	w.WriteFieldBegin("sum", thrift.I32, 1)
+	w.WriteI32(p.Sum)
+	w.WriteFieldEnd()
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *AddResponse) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == thrift.I32:
+			v58, err := r.ReadI32()
+			if err != nil {
+				return err
+			}
+			p.Sum = v58
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type CalcAddArgs struct {
+	Req *AddRequest `thrift:"req,1" json:"req,omitempty"`
+}
+
+func NewCalcAddArgs() *CalcAddArgs {
+	return &CalcAddArgs{}
+}
+
+func (p *CalcAddArgs) Write(w thrift.Writer) {
+	w.WriteStructBegin("CalcAddArgs")
+	if p.Req != nil {
+		w.WriteFieldBegin("req", thrift.STRUCT, 1)
+		p.Req.Write(w)
+		w.WriteFieldEnd()
+	}
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *CalcAddArgs) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == thrift.STRUCT:
+			v59 := NewAddRequest()
+			if err := v59.Read(r); err != nil {
+				return err
+			}
+			p.Req = v59
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type CalcAddResult struct {
+	Success *AddResponse  `thrift:"success,0,optional" json:"success,omitempty"`
+	Err     *ServiceError `thrift:"err,1,optional" json:"err,omitempty"`
+}
+
+func NewCalcAddResult() *CalcAddResult {
+	return &CalcAddResult{}
+}
+
+func (p *CalcAddResult) Write(w thrift.Writer) {
+	w.WriteStructBegin("CalcAddResult")
+	if p.Success != nil {
+		w.WriteFieldBegin("success", thrift.STRUCT, 0)
+		p.Success.Write(w)
+		w.WriteFieldEnd()
+	}
+	if p.Err != nil {
+		w.WriteFieldBegin("err", thrift.STRUCT, 1)
+		p.Err.Write(w)
+		w.WriteFieldEnd()
+	}
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *CalcAddResult) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 0 && typ == thrift.STRUCT:
+			v60 := NewAddResponse()
+			if err := v60.Read(r); err != nil {
+				return err
+			}
+			p.Success = v60
+		case id == 1 && typ == thrift.STRUCT:
+			v61 := NewServiceError()
+			if err := v61.Read(r); err != nil {
+				return err
+			}
+			p.Err = v61
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type CalcCountArgs struct {
+	OwnerID UserID  `thrift:"owner_id,1" json:"owner_id,omitempty"`
+	Items   []*Item `thrift:"items,2" json:"items,omitempty"`
+}
+
+func NewCalcCountArgs() *CalcCountArgs {
+	return &CalcCountArgs{}
+}
+
+func (p *CalcCountArgs) Write(w thrift.Writer) {
+	w.WriteStructBegin("CalcCountArgs")
+	w.WriteFieldBegin("owner_id", thrift.I64, 1)
+	w.WriteI64(p.OwnerID)
+	w.WriteFieldEnd()
+	w.WriteFieldBegin("items", thrift.LIST, 2)
+	w.WriteListBegin(thrift.STRUCT, len(p.Items))
+	for _, e62 := range p.Items {
+		e62.Write(w)
+	}
+	w.WriteListEnd()
+	w.WriteFieldEnd()
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *CalcCountArgs) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == thrift.I64:
+			v63, err := r.ReadI64()
+			if err != nil {
+				return err
+			}
+			p.OwnerID = v63
+		case id == 2 && typ == thrift.LIST:
+			_, size64, err := r.ReadListBegin()
+			if err != nil {
+				return err
+			}
+			v65 := make([]*Item, 0, size64)
+			for i66 := 0; i66 < size64; i66++ {
+				var e67 *Item
+				v68 := NewItem()
+				if err := v68.Read(r); err != nil {
+					return err
+				}
+				e67 = v68
+				v65 = append(v65, e67)
+			}
+			if err := r.ReadListEnd(); err != nil {
+				return err
+			}
+			p.Items = v65
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type CalcCountResult struct {
+	Success *int64 `thrift:"success,0,optional" json:"success,omitempty"`
+}
+
+func NewCalcCountResult() *CalcCountResult {
+	return &CalcCountResult{}
+}
+
+func (p *CalcCountResult) Write(w thrift.Writer) {
+	w.WriteStructBegin("CalcCountResult")
+	if p.Success != nil {
+		w.WriteFieldBegin("success", thrift.I64, 0)
+		w.WriteI64(*p.Success)
+		w.WriteFieldEnd()
+	}
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *CalcCountResult) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 0 && typ == thrift.I64:
+			v69, err := r.ReadI64()
+			if err != nil {
+				return err
+			}
+			p.Success = &v69
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type CalcResetArgs struct {
+}
+
+func NewCalcResetArgs() *CalcResetArgs {
+	return &CalcResetArgs{}
+}
+
+func (p *CalcResetArgs) Write(w thrift.Writer) {
+	w.WriteStructBegin("CalcResetArgs")
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *CalcResetArgs) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, _, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type CalcResetResult struct {
+}
+
+func NewCalcResetResult() *CalcResetResult {
+	return &CalcResetResult{}
+}
+
+func (p *CalcResetResult) Write(w thrift.Writer) {
+	w.WriteStructBegin("CalcResetResult")
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *CalcResetResult) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, _, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type CalcNotifyArgs struct {
+	Event string `thrift:"event,1" json:"event,omitempty"`
+}
+
+func NewCalcNotifyArgs() *CalcNotifyArgs {
+	return &CalcNotifyArgs{}
+}
+
+func (p *CalcNotifyArgs) Write(w thrift.Writer) {
+	w.WriteStructBegin("CalcNotifyArgs")
+	w.WriteFieldBegin("event", thrift.STRING, 1)
+	w.WriteString(p.Event)
+	w.WriteFieldEnd()
+	w.WriteFieldStop()
+	w.WriteStructEnd()
+}
+
+func (p *CalcNotifyArgs) Read(r thrift.Reader) error {
+	if err := r.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if typ == thrift.STOP {
+			break
+		}
+		switch {
+		case id == 1 && typ == thrift.STRING:
+			v70, err := r.ReadString()
+			if err != nil {
+				return err
+			}
+			p.Event = v70
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return err
+	}
+	return nil
+}
+
+const (
+	CalcAddCmdID    = 1
+	CalcCountCmdID  = 2
+	CalcResetCmdID  = 10
+	CalcNotifyCmdID = 3
+)
+
+// CalcHandler Calc服务接口,IDL中声明的异常直接作为error返回
+type CalcHandler interface {
+	BaseHandler
+	Add(ctx context.Context, req *AddRequest) (*AddResponse, error)
+	Count(ctx context.Context, ownerID UserID, items []*Item) (int64, error)
+	Reset(ctx context.Context) error
+	Notify(ctx context.Context, event string) error
+}
+
+// CalcClient Calc服务客户端
+type CalcClient struct {
+	*BaseClient
+	c *thrift.Client
+}
+
+// NewCalcClient codec为netx.CodecTypeThrift或netx.CodecTypeThriftCompact
+func NewCalcClient(cli netx.Client, service string, codec netx.CodecType) *CalcClient {
+	return &CalcClient{BaseClient: NewBaseClient(cli, service, codec), c: thrift.NewClient(cli, service, codec)}
+}
+
+func (c *CalcClient) Add(ctx context.Context, req *AddRequest, opts ...netx.CallOption) (*AddResponse, error) {
+	args := &CalcAddArgs{Req: req}
+	result := &CalcAddResult{}
+	if err := c.c.Invoke(ctx, "add", CalcAddCmdID, args, result, opts...); err != nil {
+		return nil, err
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	if result.Success == nil {
+		return nil, thrift.ErrMissingResult
+	}
+	return result.Success, nil
+}
+
+func (c *CalcClient) Count(ctx context.Context, ownerID UserID, items []*Item, opts ...netx.CallOption) (int64, error) {
+	args := &CalcCountArgs{OwnerID: ownerID, Items: items}
+	result := &CalcCountResult{}
+	if err := c.c.Invoke(ctx, "count", CalcCountCmdID, args, result, opts...); err != nil {
+		return 0, err
+	}
+	if result.Success == nil {
+		return 0, thrift.ErrMissingResult
+	}
+	return *result.Success, nil
+}
+
+func (c *CalcClient) Reset(ctx context.Context, opts ...netx.CallOption) error {
+	args := &CalcResetArgs{}
+	result := &CalcResetResult{}
+	if err := c.c.Invoke(ctx, "reset", CalcResetCmdID, args, result, opts...); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *CalcClient) Notify(ctx context.Context, event string, opts ...netx.CallOption) error {
+	args := &CalcNotifyArgs{Event: event}
+	return c.c.Invoke(ctx, "notify", CalcNotifyCmdID, args, nil, opts...)
+}
+
+// RegisterCalcServer 注册Calc服务路由,Name为IDL中的方法名
+func RegisterCalcServer(s netx.Server, h CalcHandler) {
+	RegisterBaseServer(s, h)
+	s.Register(&netx.Route{Name: "add", CmdID: CalcAddCmdID, Handler: thrift.Handler("add", func() thrift.Struct {
+		return NewCalcAddArgs()
+	}, func(ctx context.Context, args thrift.Struct) (thrift.Struct, error) {
+		a := args.(*CalcAddArgs)
+		result := &CalcAddResult{}
+		v, err := h.Add(ctx, a.Req)
+		if err != nil {
+			if e, ok := err.(*ServiceError); ok {
+				result.Err = e
+				return result, nil
+			}
+			return nil, err
+		}
+		result.Success = v
+		return result, nil
+	})})
+	s.Register(&netx.Route{Name: "count", CmdID: CalcCountCmdID, Handler: thrift.Handler("count", func() thrift.Struct {
+		return NewCalcCountArgs()
+	}, func(ctx context.Context, args thrift.Struct) (thrift.Struct, error) {
+		a := args.(*CalcCountArgs)
+		result := &CalcCountResult{}
+		v, err := h.Count(ctx, a.OwnerID, a.Items)
+		if err != nil {
+			return nil, err
+		}
+		result.Success = &v
+		return result, nil
+	})})
+	s.Register(&netx.Route{Name: "reset", CmdID: CalcResetCmdID, Handler: thrift.Handler("reset", func() thrift.Struct {
+		return NewCalcResetArgs()
+	}, func(ctx context.Context, args thrift.Struct) (thrift.Struct, error) {
+		result := &CalcResetResult{}
+		err := h.Reset(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	})})
+	s.Register(&netx.Route{Name: "notify", CmdID: CalcNotifyCmdID, Handler: thrift.Handler("notify", func() thrift.Struct {
+		return NewCalcNotifyArgs()
+	}, func(ctx context.Context, args thrift.Struct) (thrift.Struct, error) {
+		a := args.(*CalcNotifyArgs)
+		return nil, h.Notify(ctx, a.Event)
+	})})
+}
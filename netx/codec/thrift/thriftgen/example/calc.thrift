@@ -0,0 +1,47 @@
+namespace go example
+
+include "base.thrift"
+
+const i32 MAX_ITEMS = 16
+const string VERSION = "1.0"
+const list<string> DEFAULT_TAGS = ["a", "b"]
+const map<string, i32> LIMITS = {"small": 1, "large": 100}
+
+struct Item {
+    1: required base.UserID owner_id
+    2: string name = "unnamed"
+    3: optional i32 count
+    4: optional double price = 1.5
+    5: binary data
+    6: list<string> tags
+    7: set<i64> ids
+    8: map<string, list<i32>> groups
+    9: base.Status status = base.Status.OK
+    10: optional bool enabled
+    11: i8 flag
+    12: i16 level
+}
+
+union Value {
+    1: i64 int_value
+    2: string str_value
+    3: Item item
+}
+
+struct AddRequest {
+    1: i32 a
+    2: i32 b
+    3: optional list<Item> items
+    4: map<string, Value> values
+}
+
+struct AddResponse {
+    1: i32 sum
+}
+
+service Calc extends base.Base {
+    AddResponse add(1: AddRequest req) throws (1: base.ServiceError err)
+    i64 count(1: base.UserID owner_id, 2: list<Item> items)
+    void reset() (nova.cmd_id = "10")
+    oneway void notify(1: string event)
+}
@@ -0,0 +1,155 @@
+package example
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/codec/thrift"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/protocol/theader"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+)
+
+type calcHandler struct {
+	notified chan string
+	resets   int32
+}
+
+func (h *calcHandler) Ping(ctx context.Context, text string) (string, error) {
+	return "pong:" + text, nil
+}
+
+func (h *calcHandler) Add(ctx context.Context, req *AddRequest) (*AddResponse, error) {
+	if req.A < 0 {
+		msg := "negative"
+		return nil, &ServiceError{Status: StatusInternalError, Message: &msg}
+	}
+	if req.B < 0 {
+		return nil, errors.New("unexpected")
+	}
+
+	return &AddResponse{Sum: req.A + req.B}, nil
+}
+
+func (h *calcHandler) Count(ctx context.Context, ownerID UserID, items []*Item) (int64, error) {
+	var n int64
+	for _, item := range items {
+		if item.OwnerID == ownerID && item.Count != nil {
+			n += int64(*item.Count)
+		}
+	}
+
+	return n, nil
+}
+
+func (h *calcHandler) Reset(ctx context.Context) error {
+	atomic.AddInt32(&h.resets, 1)
+	return nil
+}
+
+func (h *calcHandler) Notify(ctx context.Context, event string) error {
+	h.notified <- event
+	return nil
+}
+
+func TestItem(t *testing.T) {
+	count := int32(3)
+	item := NewItem()
+	item.OwnerID = 1
+	item.Count = &count
+	item.Data = []byte{0, 1, 2}
+	item.Tags = DefaultTags
+	item.Ids = []int64{-1, 1 << 40}
+	item.Groups = map[string][]int32{"g": {1, 2, 3}}
+	item.Status = StatusNotFound
+	enabled := false
+	item.Enabled = &enabled
+	item.Flag = -1
+	item.Level = 300
+
+	for _, p := range []thrift.Protocol{thrift.ProtocolBinary, thrift.ProtocolCompact} {
+		out := &Item{}
+		if err := thrift.Unmarshal(p, thrift.Marshal(p, item), out); err != nil {
+			t.Fatal(err)
+		}
+		if out.OwnerID != 1 || *out.Count != 3 || *out.Price != 1.5 || out.Name != "unnamed" || string(out.Data) != "\x00\x01\x02" ||
+			len(out.Tags) != 2 || out.Ids[1] != 1<<40 || out.Groups["g"][2] != 3 || out.Status != StatusNotFound ||
+			*out.Enabled || out.Flag != -1 || out.Level != 300 {
+			t.Errorf("invalid item, %+v", out)
+		}
+	}
+
+	// 缺少required字段
+	if err := thrift.Unmarshal(thrift.ProtocolBinary, thrift.Marshal(thrift.ProtocolBinary, &AddResponse{}), &Item{}); err == nil {
+		t.Errorf("expect missing field error")
+	}
+}
+
+func TestCalc(t *testing.T) {
+	protos := []netx.Protocol{rpc.New(), theader.New()}
+	codecs := []netx.CodecType{netx.CodecTypeThrift, netx.CodecTypeThriftCompact}
+	for _, proto := range protos {
+		for _, codec := range codecs {
+			proto, codec := proto, codec
+			t.Run(proto.Name()+"/"+netx.GetContentType(codec), func(t *testing.T) {
+				testCalc(t, proto, codec)
+			})
+		}
+	}
+}
+
+func testCalc(t *testing.T, proto netx.Protocol, codec netx.CodecType) {
+	name := "thrift.calc." + proto.Name() + "." + netx.GetContentType(codec)
+	svr := memtest.NewServer(t, name)
+	h := &calcHandler{notified: make(chan string, 1)}
+	RegisterCalcServer(svr, h)
+
+	cli := NewCalcClient(memtest.NewClient(t, client.WithProtocol(proto)), memtest.Addr(name), codec)
+	ctx := context.Background()
+
+	if v, err := cli.Ping(ctx, "hi"); err != nil || v != "pong:hi" {
+		t.Fatalf("ping fail, %v, %+v", v, err)
+	}
+
+	rsp, err := cli.Add(ctx, &AddRequest{A: 1, B: 2, Values: map[string]*Value{"x": {Item: NewItem()}}})
+	if err != nil || rsp.Sum != 3 {
+		t.Fatalf("add fail, %+v, %+v", rsp, err)
+	}
+
+	var serr *ServiceError
+	if _, err := cli.Add(ctx, &AddRequest{A: -1}); !errors.As(err, &serr) || serr.Status != StatusInternalError || *serr.Message != "negative" {
+		t.Errorf("expect service error, %+v", err)
+	}
+
+	var aerr *thrift.ApplicationException
+	if _, err := cli.Add(ctx, &AddRequest{B: -1}); !errors.As(err, &aerr) || aerr.Message != "unexpected" {
+		t.Errorf("expect application exception, %+v", err)
+	}
+
+	count := int32(2)
+	items := []*Item{{OwnerID: 1, Count: &count}, {OwnerID: 2, Count: &count}, {OwnerID: 1, Count: &count}}
+	if n, err := cli.Count(ctx, 1, items); err != nil || n != 4 {
+		t.Errorf("count fail, %d, %+v", n, err)
+	}
+
+	if err := cli.Reset(ctx); err != nil || atomic.LoadInt32(&h.resets) != 1 {
+		t.Errorf("reset fail, %+v", err)
+	}
+
+	if err := cli.Notify(ctx, "event"); err != nil {
+		t.Fatalf("notify fail, %+v", err)
+	}
+	select {
+	case ev := <-h.notified:
+		if ev != "event" {
+			t.Errorf("invalid event, %s", ev)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("notify timeout")
+	}
+}
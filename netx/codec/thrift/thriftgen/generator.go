@@ -0,0 +1,826 @@
+package thriftgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	thriftImport  = "github.com/foredata/nova/netx/codec/thrift"
+	netxImport    = "github.com/foredata/nova/netx"
+	annoCmdID     = "nova.cmd_id"   // 方法注解,指定CmdID
+	annoCmdIDBase = "nova.cmd_base" // 服务注解,未指定cmd_id的方法从base+1开始顺序分配
+)
+
+// Options 生成选项
+type Options struct {
+	Package string // Go包名,默认使用namespace go或文件名
+}
+
+type Option func(o *Options)
+
+// WithPackage 指定Go包名,include的文件需要生成到同一个包中
+func WithPackage(name string) Option {
+	return func(o *Options) {
+		o.Package = name
+	}
+}
+
+// Generate 生成doc中定义的类型,client stub及server注册代码,不包含include文件中的定义
+func Generate(doc *Document, opts ...Option) ([]byte, error) {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+	if o.Package == "" {
+		o.Package = packageName(doc)
+	}
+
+	g := &generator{doc: doc, imports: make(map[string]bool)}
+	if err := g.generate(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by thriftgen. DO NOT EDIT.\n")
+	if doc.Path != "" {
+		fmt.Fprintf(&out, "// source: %s\n", docName(doc.Path)+".thrift")
+	}
+	fmt.Fprintf(&out, "\npackage %s\n\n", o.Package)
+	if len(g.imports) > 0 {
+		imports := make([]string, 0, len(g.imports))
+		for k := range g.imports {
+			imports = append(imports, k)
+		}
+		sort.Strings(imports)
+		out.WriteString("import (\n")
+		std := true
+		for _, k := range imports {
+			// 标准库与第三方库之间空一行
+			if std && strings.Contains(k, ".") {
+				std = false
+				if k != imports[0] {
+					out.WriteString("\n")
+				}
+			}
+			fmt.Fprintf(&out, "\t%q\n", k)
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("thriftgen: format fail, %w", err)
+	}
+	return src, nil
+}
+
+// 类型分类
+type kind int
+
+const (
+	kindBase kind = iota
+	kindEnum
+	kindStruct
+	kindList
+	kindSet
+	kindMap
+)
+
+// 基础类型 -> Go类型,读写方法名,Thrift类型
+var gBaseTypes = map[string][3]string{
+	"bool":   {"bool", "Bool", "BOOL"},
+	"byte":   {"int8", "I8", "BYTE"},
+	"i8":     {"int8", "I8", "BYTE"},
+	"i16":    {"int16", "I16", "I16"},
+	"i32":    {"int32", "I32", "I32"},
+	"i64":    {"int64", "I64", "I64"},
+	"double": {"float64", "Double", "DOUBLE"},
+	"string": {"string", "String", "STRING"},
+	"binary": {"[]byte", "Binary", "STRING"},
+}
+
+// resolved typedef展开后的类型
+type resolved struct {
+	kind kind
+	typ  *Type    // 展开后的类型
+	doc  *Document // typ所在文件,用于继续解析元素类型
+	name string    // enum,struct的Go名
+}
+
+type generator struct {
+	doc     *Document
+	buf     bytes.Buffer
+	imports map[string]bool
+	tmp     int
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+func (g *generator) newVar(prefix string) string {
+	g.tmp++
+	return prefix + strconv.Itoa(g.tmp)
+}
+
+// lookup 查找自定义类型定义,支持include前缀
+func (g *generator) lookup(doc *Document, name string) (interface{}, *Document) {
+	if idx := strings.IndexByte(name, '.'); idx != -1 {
+		prefix := name[:idx]
+		for _, inc := range doc.Includes {
+			if docName(inc.Path) == prefix {
+				return g.lookup(inc, name[idx+1:])
+			}
+		}
+		return nil, nil
+	}
+
+	for _, t := range doc.Typedefs {
+		if t.Name == name {
+			return t, doc
+		}
+	}
+	for _, e := range doc.Enums {
+		if e.Name == name {
+			return e, doc
+		}
+	}
+	for _, s := range doc.Structs {
+		if s.Name == name {
+			return s, doc
+		}
+	}
+	for _, c := range doc.Consts {
+		if c.Name == name {
+			return c, doc
+		}
+	}
+	for _, s := range doc.Services {
+		if s.Name == name {
+			return s, doc
+		}
+	}
+
+	return nil, nil
+}
+
+func (g *generator) resolve(t *Type, doc *Document) (*resolved, error) {
+	switch t.Name {
+	case "list":
+		return &resolved{kind: kindList, typ: t, doc: doc}, nil
+	case "set":
+		return &resolved{kind: kindSet, typ: t, doc: doc}, nil
+	case "map":
+		return &resolved{kind: kindMap, typ: t, doc: doc}, nil
+	}
+	if _, ok := gBaseTypes[t.Name]; ok {
+		return &resolved{kind: kindBase, typ: t, doc: doc}, nil
+	}
+
+	def, ddoc := g.lookup(doc, t.Name)
+	switch x := def.(type) {
+	case *Typedef:
+		return g.resolve(x.Type, ddoc)
+	case *Enum:
+		return &resolved{kind: kindEnum, typ: t, doc: ddoc, name: goName(x.Name)}, nil
+	case *Struct:
+		return &resolved{kind: kindStruct, typ: t, doc: ddoc, name: goName(x.Name)}, nil
+	default:
+		return nil, fmt.Errorf("thriftgen: unknown type %s", t.Name)
+	}
+}
+
+// goType 字段的Go类型,typedef保留别名
+func (g *generator) goType(t *Type, doc *Document) (string, error) {
+	r, err := g.resolve(t, doc)
+	if err != nil {
+		return "", err
+	}
+
+	switch r.kind {
+	case kindList, kindSet:
+		elem, err := g.goType(t.ValueType, doc)
+		return "[]" + elem, err
+	case kindMap:
+		key, err := g.goType(t.KeyType, doc)
+		if err != nil {
+			return "", err
+		}
+		val, err := g.goType(t.ValueType, doc)
+		return "map[" + key + "]" + val, err
+	}
+
+	if def, _ := g.lookup(doc, t.Name); def != nil {
+		if r.kind == kindStruct {
+			return "*" + goName(t.Name), nil
+		}
+		return goName(t.Name), nil
+	}
+
+	return gBaseTypes[t.Name][0], nil
+}
+
+func (g *generator) wireType(t *Type, doc *Document) (string, error) {
+	r, err := g.resolve(t, doc)
+	if err != nil {
+		return "", err
+	}
+
+	switch r.kind {
+	case kindBase:
+		return "thrift." + gBaseTypes[r.typ.Name][2], nil
+	case kindEnum:
+		return "thrift.I32", nil
+	case kindStruct:
+		return "thrift.STRUCT", nil
+	case kindList:
+		return "thrift.LIST", nil
+	case kindSet:
+		return "thrift.SET", nil
+	default:
+		return "thrift.MAP", nil
+	}
+}
+
+// zeroValue Go零值
+func (g *generator) zeroValue(t *Type, doc *Document) (string, error) {
+	r, err := g.resolve(t, doc)
+	if err != nil {
+		return "", err
+	}
+
+	switch r.kind {
+	case kindBase:
+		switch r.typ.Name {
+		case "bool":
+			return "false", nil
+		case "string":
+			return `""`, nil
+		case "binary":
+			return "nil", nil
+		default:
+			return "0", nil
+		}
+	case kindEnum:
+		return "0", nil
+	default:
+		return "nil", nil
+	}
+}
+
+// isScalar 非nil类型,optional时需要使用指针
+func (g *generator) isScalar(t *Type, doc *Document) bool {
+	r, err := g.resolve(t, doc)
+	if err != nil {
+		return false
+	}
+
+	return (r.kind == kindBase && r.typ.Name != "binary") || r.kind == kindEnum
+}
+
+func (g *generator) generate() error {
+	g.imports[thriftImport] = true
+
+	for _, t := range g.doc.Typedefs {
+		if err := g.genTypedef(t); err != nil {
+			return err
+		}
+	}
+	for _, e := range g.doc.Enums {
+		g.genEnum(e)
+	}
+	if err := g.genConsts(); err != nil {
+		return err
+	}
+	for _, s := range g.doc.Structs {
+		if err := g.genStruct(s, g.doc); err != nil {
+			return err
+		}
+	}
+
+	cmdID := 0
+	for _, s := range g.doc.Services {
+		if err := g.genService(s, &cmdID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) genTypedef(t *Typedef) error {
+	typ, err := g.goType(t.Type, g.doc)
+	if err != nil {
+		return err
+	}
+	// 结构体别名需要去掉指针
+	typ = strings.TrimPrefix(typ, "*")
+	g.p("type %s = %s\n", goName(t.Name), typ)
+	return nil
+}
+
+func (g *generator) genEnum(e *Enum) {
+	name := goName(e.Name)
+	g.imports["fmt"] = true
+	g.p("type %s int32\n", name)
+	g.p("const (")
+	for _, v := range e.Values {
+		g.p("%s%s %s = %d", name, camelCase(v.Name), name, v.Value)
+	}
+	g.p(")\n")
+
+	g.p("func (p %s) String() string {", name)
+	g.p("switch p {")
+	seen := make(map[int64]bool)
+	for _, v := range e.Values {
+		if seen[v.Value] {
+			continue
+		}
+		seen[v.Value] = true
+		g.p("case %s%s:", name, camelCase(v.Name))
+		g.p("return %q", v.Name)
+	}
+	g.p("}")
+	g.p("return fmt.Sprintf(\"%s(%%d)\", int32(p))", name)
+	g.p("}\n")
+}
+
+func (g *generator) genConsts() error {
+	for _, c := range g.doc.Consts {
+		typ, err := g.goType(c.Type, g.doc)
+		if err != nil {
+			return err
+		}
+		value, err := g.constValue(c.Type, g.doc, c.Value)
+		if err != nil {
+			return fmt.Errorf("thriftgen: const %s, %w", c.Name, err)
+		}
+
+		if g.isScalar(c.Type, g.doc) {
+			g.p("const %s %s = %s\n", goName(c.Name), typ, value)
+		} else {
+			g.p("var %s = %s\n", goName(c.Name), value)
+		}
+	}
+
+	return nil
+}
+
+// constValue 常量值的Go表达式,基础类型返回无类型字面值
+func (g *generator) constValue(t *Type, doc *Document, v *ConstValue) (string, error) {
+	r, err := g.resolve(t, doc)
+	if err != nil {
+		return "", err
+	}
+
+	// 引用其他常量
+	if v.Kind == "ident" && v.Value != "true" && v.Value != "false" {
+		if def, _ := g.lookup(doc, v.Value); def != nil {
+			if _, ok := def.(*Const); ok {
+				return goName(v.Value), nil
+			}
+		}
+	}
+
+	switch r.kind {
+	case kindBase:
+		switch r.typ.Name {
+		case "bool":
+			switch v.Value {
+			case "true", "1":
+				return "true", nil
+			case "false", "0":
+				return "false", nil
+			}
+		case "string":
+			if v.Kind == "string" {
+				return strconv.Quote(v.Value), nil
+			}
+		case "binary":
+			if v.Kind == "string" {
+				return "[]byte(" + strconv.Quote(v.Value) + ")", nil
+			}
+		case "double":
+			if v.Kind == "int" || v.Kind == "double" {
+				return v.Value, nil
+			}
+		default:
+			if v.Kind == "int" {
+				return v.Value, nil
+			}
+		}
+	case kindEnum:
+		if v.Kind == "int" {
+			return r.name + "(" + v.Value + ")", nil
+		}
+		if v.Kind == "ident" {
+			name := v.Value
+			if idx := strings.LastIndexByte(name, '.'); idx != -1 {
+				name = name[idx+1:]
+			}
+			return r.name + camelCase(name), nil
+		}
+	case kindList, kindSet:
+		if v.Kind == "list" {
+			typ, err := g.goType(t, doc)
+			if err != nil {
+				return "", err
+			}
+			elems := make([]string, 0, len(v.List))
+			for _, e := range v.List {
+				s, err := g.constValue(r.typ.ValueType, r.doc, e)
+				if err != nil {
+					return "", err
+				}
+				elems = append(elems, s)
+			}
+			return typ + "{" + strings.Join(elems, ", ") + "}", nil
+		}
+	case kindMap:
+		if v.Kind == "map" {
+			typ, err := g.goType(t, doc)
+			if err != nil {
+				return "", err
+			}
+			elems := make([]string, 0, len(v.Keys))
+			for i := range v.Keys {
+				k, err := g.constValue(r.typ.KeyType, r.doc, v.Keys[i])
+				if err != nil {
+					return "", err
+				}
+				val, err := g.constValue(r.typ.ValueType, r.doc, v.Vals[i])
+				if err != nil {
+					return "", err
+				}
+				elems = append(elems, k+": "+val)
+			}
+			return typ + "{" + strings.Join(elems, ", ") + "}", nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported value %q for type %s", v.Value, t.Name)
+}
+
+// structField 生成结构体字段所需信息
+type structField struct {
+	*Field
+	name     string // Go字段名
+	typ      string // Go类型
+	wire     string // Thrift类型
+	optional bool   //
+	pointer  bool   // optional基础类型使用指针
+}
+
+func (g *generator) structFields(s *Struct, doc *Document) ([]*structField, error) {
+	fields := make([]*structField, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		typ, err := g.goType(f.Type, doc)
+		if err != nil {
+			return nil, fmt.Errorf("thriftgen: %s.%s, %w", s.Name, f.Name, err)
+		}
+		wire, err := g.wireType(f.Type, doc)
+		if err != nil {
+			return nil, err
+		}
+
+		sf := &structField{Field: f, name: fieldName(f.Name), typ: typ, wire: wire}
+		sf.optional = f.Required == Optional || s.Kind == KindUnion
+		if sf.optional && g.isScalar(f.Type, doc) {
+			sf.pointer = true
+			sf.typ = "*" + typ
+		}
+		fields = append(fields, sf)
+	}
+
+	return fields, nil
+}
+
+func (g *generator) genStruct(s *Struct, doc *Document) error {
+	fields, err := g.structFields(s, doc)
+	if err != nil {
+		return err
+	}
+
+	name := goName(s.Name)
+	g.p("type %s struct {", name)
+	for _, f := range fields {
+		tag := f.Field.Name + "," + strconv.Itoa(int(f.ID))
+		switch {
+		case f.Required == Required:
+			tag += ",required"
+		case f.optional:
+			tag += ",optional"
+		}
+		g.p("%s %s `thrift:\"%s\" json:\"%s,omitempty\"`", f.name, f.typ, tag, f.Field.Name)
+	}
+	g.p("}\n")
+
+	if err := g.genNew(name, fields, doc); err != nil {
+		return err
+	}
+
+	if s.Kind == KindException {
+		g.imports["fmt"] = true
+		g.p("func (p *%s) Error() string {", name)
+		g.p("return fmt.Sprintf(\"%s(%%+v)\", *p)", name)
+		g.p("}\n")
+	}
+
+	if err := g.genWrite(s, name, fields, doc); err != nil {
+		return err
+	}
+	return g.genRead(s, name, fields, doc)
+}
+
+func (g *generator) genNew(name string, fields []*structField, doc *Document) error {
+	g.p("func New%s() *%s {", name, name)
+	hasDefault := false
+	for _, f := range fields {
+		hasDefault = hasDefault || f.Default != nil
+	}
+	if !hasDefault {
+		g.p("return &%s{}", name)
+		g.p("}\n")
+		return nil
+	}
+
+	g.p("p := &%s{}", name)
+	for _, f := range fields {
+		if f.Default == nil {
+			continue
+		}
+		value, err := g.constValue(f.Type, doc, f.Default)
+		if err != nil {
+			return fmt.Errorf("thriftgen: default of %s.%s, %w", name, f.Field.Name, err)
+		}
+		if f.pointer {
+			v := g.newVar("v")
+			g.p("%s := %s(%s)", v, strings.TrimPrefix(f.typ, "*"), value)
+			g.p("p.%s = &%s", f.name, v)
+		} else {
+			g.p("p.%s = %s", f.name, value)
+		}
+	}
+	g.p("return p")
+	g.p("}\n")
+	return nil
+}
+
+func (g *generator) genWrite(s *Struct, name string, fields []*structField, doc *Document) error {
+	g.p("func (p *%s) Write(w thrift.Writer) {", name)
+	g.p("w.WriteStructBegin(%q)", s.Name)
+	for _, f := range fields {
+		r, err := g.resolve(f.Type, doc)
+		if err != nil {
+			return err
+		}
+
+		value := "p." + f.name
+		check := f.optional || r.kind == kindStruct
+		if f.pointer {
+			value = "*" + value
+		}
+		if check {
+			g.p("if p.%s != nil {", f.name)
+		}
+		g.p("w.WriteFieldBegin(%q, %s, %d)", f.Field.Name, f.wire, f.ID)
+		if err := g.writeValue(f.Type, doc, value); err != nil {
+			return err
+		}
+		g.p("w.WriteFieldEnd()")
+		if check {
+			g.p("}")
+		}
+	}
+	g.p("w.WriteFieldStop()")
+	g.p("w.WriteStructEnd()")
+	g.p("}\n")
+	return nil
+}
+
+func (g *generator) writeValue(t *Type, doc *Document, value string) error {
+	r, err := g.resolve(t, doc)
+	if err != nil {
+		return err
+	}
+
+	switch r.kind {
+	case kindBase:
+		g.p("w.Write%s(%s)", gBaseTypes[r.typ.Name][1], value)
+	case kindEnum:
+		g.p("w.WriteI32(int32(%s))", value)
+	case kindStruct:
+		g.p("%s.Write(w)", value)
+	case kindList, kindSet:
+		name := "List"
+		if r.kind == kindSet {
+			name = "Set"
+		}
+		wire, err := g.wireType(r.typ.ValueType, r.doc)
+		if err != nil {
+			return err
+		}
+		e := g.newVar("e")
+		g.p("w.Write%sBegin(%s, len(%s))", name, wire, value)
+		g.p("for _, %s := range %s {", e, value)
+		if err := g.writeValue(r.typ.ValueType, r.doc, e); err != nil {
+			return err
+		}
+		g.p("}")
+		g.p("w.Write%sEnd()", name)
+	case kindMap:
+		kwire, err := g.wireType(r.typ.KeyType, r.doc)
+		if err != nil {
+			return err
+		}
+		vwire, err := g.wireType(r.typ.ValueType, r.doc)
+		if err != nil {
+			return err
+		}
+		k, v := g.newVar("k"), g.newVar("v")
+		g.p("w.WriteMapBegin(%s, %s, len(%s))", kwire, vwire, value)
+		g.p("for %s, %s := range %s {", k, v, value)
+		if err := g.writeValue(r.typ.KeyType, r.doc, k); err != nil {
+			return err
+		}
+		if err := g.writeValue(r.typ.ValueType, r.doc, v); err != nil {
+			return err
+		}
+		g.p("}")
+		g.p("w.WriteMapEnd()")
+	}
+
+	return nil
+}
+
+func (g *generator) genRead(s *Struct, name string, fields []*structField, doc *Document) error {
+	g.p("func (p *%s) Read(r thrift.Reader) error {", name)
+	for _, f := range fields {
+		if f.Required == Required {
+			g.p("isset%s := false", f.name)
+		}
+	}
+	g.p("if err := r.ReadStructBegin(); err != nil {")
+	g.p("return err")
+	g.p("}")
+	g.p("for {")
+	if len(fields) > 0 {
+		g.p("typ, id, err := r.ReadFieldBegin()")
+	} else {
+		g.p("typ, _, err := r.ReadFieldBegin()")
+	}
+	g.p("if err != nil {")
+	g.p("return err")
+	g.p("}")
+	g.p("if typ == thrift.STOP {")
+	g.p("break")
+	g.p("}")
+	g.p("switch {")
+	for _, f := range fields {
+		g.p("case id == %d && typ == %s:", f.ID, f.wire)
+		if err := g.readValue(f.Type, doc, "p."+f.name, f.pointer); err != nil {
+			return err
+		}
+		if f.Required == Required {
+			g.p("isset%s = true", f.name)
+		}
+	}
+	g.p("default:")
+	g.p("if err := r.Skip(typ); err != nil {")
+	g.p("return err")
+	g.p("}")
+	g.p("}")
+	g.p("if err := r.ReadFieldEnd(); err != nil {")
+	g.p("return err")
+	g.p("}")
+	g.p("}")
+
+	g.p("if err := r.ReadStructEnd(); err != nil {")
+	g.p("return err")
+	g.p("}")
+	for _, f := range fields {
+		if f.Required == Required {
+			g.p("if !isset%s {", f.name)
+			g.p("return thrift.MissingField(%q, %q)", s.Name, f.Field.Name)
+			g.p("}")
+		}
+	}
+	g.p("return nil")
+	g.p("}\n")
+	return nil
+}
+
+// readValue 读取t类型的值并赋给target,ref为true时target为指针
+//	临时变量名均唯一,无需额外作用域
+func (g *generator) readValue(t *Type, doc *Document, target string, ref bool) error {
+	r, err := g.resolve(t, doc)
+	if err != nil {
+		return err
+	}
+	typ, err := g.goType(t, doc)
+	if err != nil {
+		return err
+	}
+
+	assign := func(v string) {
+		if ref {
+			g.p("%s = &%s", target, v)
+		} else {
+			g.p("%s = %s", target, v)
+		}
+	}
+
+	switch r.kind {
+	case kindBase:
+		v := g.newVar("v")
+		g.p("%s, err := r.Read%s()", v, gBaseTypes[r.typ.Name][1])
+		g.p("if err != nil {")
+		g.p("return err")
+		g.p("}")
+		assign(v)
+	case kindEnum:
+		v, e := g.newVar("v"), g.newVar("e")
+		g.p("%s, err := r.ReadI32()", v)
+		g.p("if err != nil {")
+		g.p("return err")
+		g.p("}")
+		if ref {
+			g.p("%s := %s(%s)", e, typ, v)
+			assign(e)
+		} else {
+			assign(typ + "(" + v + ")")
+		}
+	case kindStruct:
+		v := g.newVar("v")
+		g.p("%s := New%s()", v, r.name)
+		g.p("if err := %s.Read(r); err != nil {", v)
+		g.p("return err")
+		g.p("}")
+		assign(v)
+	case kindList, kindSet:
+		name := "List"
+		if r.kind == kindSet {
+			name = "Set"
+		}
+		elem, err := g.goType(r.typ.ValueType, r.doc)
+		if err != nil {
+			return err
+		}
+		size, v, i, e := g.newVar("size"), g.newVar("v"), g.newVar("i"), g.newVar("e")
+		g.p("_, %s, err := r.Read%sBegin()", size, name)
+		g.p("if err != nil {")
+		g.p("return err")
+		g.p("}")
+		g.p("%s := make(%s, 0, %s)", v, typ, size)
+		g.p("for %s := 0; %s < %s; %s++ {", i, i, size, i)
+		g.p("var %s %s", e, elem)
+		if err := g.readValue(r.typ.ValueType, r.doc, e, false); err != nil {
+			return err
+		}
+		g.p("%s = append(%s, %s)", v, v, e)
+		g.p("}")
+		g.p("if err := r.Read%sEnd(); err != nil {", name)
+		g.p("return err")
+		g.p("}")
+		assign(v)
+	case kindMap:
+		ktype, err := g.goType(r.typ.KeyType, r.doc)
+		if err != nil {
+			return err
+		}
+		vtype, err := g.goType(r.typ.ValueType, r.doc)
+		if err != nil {
+			return err
+		}
+		size, m, i, k, v := g.newVar("size"), g.newVar("m"), g.newVar("i"), g.newVar("k"), g.newVar("v")
+		g.p("_, _, %s, err := r.ReadMapBegin()", size)
+		g.p("if err != nil {")
+		g.p("return err")
+		g.p("}")
+		g.p("%s := make(%s, %s)", m, typ, size)
+		g.p("for %s := 0; %s < %s; %s++ {", i, i, size, i)
+		g.p("var %s %s", k, ktype)
+		if err := g.readValue(r.typ.KeyType, r.doc, k, false); err != nil {
+			return err
+		}
+		g.p("var %s %s", v, vtype)
+		if err := g.readValue(r.typ.ValueType, r.doc, v, false); err != nil {
+			return err
+		}
+		g.p("%s[%s] = %s", m, k, v)
+		g.p("}")
+		g.p("if err := r.ReadMapEnd(); err != nil {")
+		g.p("return err")
+		g.p("}")
+		assign(m)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package thriftgen
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// 常见缩写,转换为Go命名时保持全大写
+var gInitialisms = map[string]string{
+	"id":   "ID",
+	"ip":   "IP",
+	"uri":  "URI",
+	"url":  "URL",
+	"api":  "API",
+	"rpc":  "RPC",
+	"http": "HTTP",
+	"json": "JSON",
+}
+
+// 生成的结构体已使用的方法名,字段名冲突时追加下划线
+var gReserved = map[string]bool{
+	"Read":  true,
+	"Write": true,
+	"Error": true,
+}
+
+// 生成代码中使用的局部变量,参数名冲突时追加下划线
+var gLocals = map[string]bool{
+	"ctx":    true,
+	"opts":   true,
+	"args":   true,
+	"result": true,
+	"err":    true,
+	"c":      true,
+	"h":      true,
+	"s":      true,
+	"a":      true,
+}
+
+var gKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true,
+	"goto": true, "if": true, "import": true, "interface": true, "map": true, "package": true,
+	"range": true, "return": true, "select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// camelCase 将下划线风格转换为驼峰,全大写的单词仅保留首字母大写
+func camelCase(s string) string {
+	var sb strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part == "" {
+			continue
+		}
+		if v, ok := gInitialisms[strings.ToLower(part)]; ok {
+			sb.WriteString(v)
+			continue
+		}
+		if strings.ToUpper(part) == part {
+			part = strings.ToLower(part)
+		}
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+	}
+
+	return sb.String()
+}
+
+// goName 导出的Go类型名,忽略include前缀
+func goName(s string) string {
+	if idx := strings.LastIndexByte(s, '.'); idx != -1 {
+		s = s[idx+1:]
+	}
+
+	return camelCase(s)
+}
+
+// fieldName 结构体字段名
+func fieldName(s string) string {
+	name := camelCase(s)
+	if gReserved[name] {
+		name += "_"
+	}
+
+	return name
+}
+
+// paramName 函数参数名,首字母小写
+func paramName(s string) string {
+	name := camelCase(s)
+	if name == "" {
+		return "_"
+	}
+	if v, ok := gInitialisms[strings.ToLower(name)]; ok && v == name {
+		name = strings.ToLower(name)
+	} else {
+		r := []rune(name)
+		r[0] = unicode.ToLower(r[0])
+		name = string(r)
+	}
+	if gLocals[name] || gKeywords[name] {
+		name += "_"
+	}
+
+	return name
+}
+
+// docName 文件名去掉扩展名,用于include引用
+func docName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// packageName 优先使用namespace go,否则使用文件名
+func packageName(doc *Document) string {
+	name := doc.Namespaces["go"]
+	if name == "" {
+		name = doc.Namespaces["*"]
+	}
+	if name == "" {
+		name = docName(doc.Path)
+	}
+	if idx := strings.LastIndexByte(name, '.'); idx != -1 {
+		name = name[idx+1:]
+	}
+
+	name = strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, name))
+	if name == "" {
+		return "thrift"
+	}
+	return name
+}
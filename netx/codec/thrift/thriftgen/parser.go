@@ -0,0 +1,713 @@
+package thriftgen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokDouble
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// lexer 词法分析,忽略//,#,/**/注释
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#' || strings.HasPrefix(l.src[l.pos:], "//"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end == -1 {
+				return fmt.Errorf("line %d: unterminated comment", l.line)
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c == '.' || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: l.line}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], line: l.line}, nil
+	case isDigit(c) || ((c == '-' || c == '+') && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		l.pos++
+		kind := tokInt
+		for l.pos < len(l.src) {
+			c := l.src[l.pos]
+			if c == '.' || c == 'e' || c == 'E' {
+				kind = tokDouble
+			} else if !isDigit(c) && c != 'x' && c != 'X' && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') &&
+				!((c == '-' || c == '+') && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E')) {
+				break
+			}
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		if strings.HasPrefix(strings.TrimLeft(text, "+-"), "0x") {
+			kind = tokInt
+		}
+		return token{kind: kind, text: text, line: l.line}, nil
+	case c == '"' || c == '\'':
+		l.pos++
+		var sb strings.Builder
+		for l.pos < len(l.src) && l.src[l.pos] != c {
+			if l.src[l.pos] == '\\' && l.pos+1 < len(l.src) {
+				l.pos++
+				switch l.src[l.pos] {
+				case 'n':
+					sb.WriteByte('\n')
+				case 't':
+					sb.WriteByte('\t')
+				case 'r':
+					sb.WriteByte('\r')
+				default:
+					sb.WriteByte(l.src[l.pos])
+				}
+			} else {
+				if l.src[l.pos] == '\n' {
+					l.line++
+				}
+				sb.WriteByte(l.src[l.pos])
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return token{}, fmt.Errorf("line %d: unterminated string", l.line)
+		}
+		l.pos++
+		return token{kind: tokString, text: sb.String(), line: l.line}, nil
+	default:
+		l.pos++
+		return token{kind: tokSymbol, text: string(c), line: l.line}, nil
+	}
+}
+
+// Parser 解析.thrift文件
+type Parser struct {
+	docs map[string]*Document // 已解析的文件,避免重复解析
+}
+
+// NewParser 创建Parser
+func NewParser() *Parser {
+	return &Parser{docs: make(map[string]*Document)}
+}
+
+// ParseFile 解析文件及其include的文件
+func (p *Parser) ParseFile(path string) (*Document, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if doc, ok := p.docs[abs]; ok {
+		return doc, nil
+	}
+
+	data, err := ioutil.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := p.parse(abs, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// Parse 解析IDL内容,不支持include
+func Parse(src string) (*Document, error) {
+	return NewParser().parse("", src)
+}
+
+func (p *Parser) parse(path string, src string) (*Document, error) {
+	doc := &Document{Path: path, Namespaces: make(map[string]string)}
+	if path != "" {
+		p.docs[path] = doc
+	}
+
+	s := &scanner{lex: &lexer{src: src, line: 1}}
+	if err := s.advance(); err != nil {
+		return nil, err
+	}
+
+	for s.tok.kind != tokEOF {
+		if s.tok.kind != tokIdent {
+			return nil, s.errorf("unexpected %q", s.tok.text)
+		}
+
+		var err error
+		switch s.tok.text {
+		case "include":
+			err = p.parseInclude(s, doc)
+		case "cpp_include":
+			_ = s.advance()
+			_, err = s.expectString()
+		case "namespace":
+			err = s.parseNamespace(doc)
+		case "typedef":
+			err = s.parseTypedef(doc)
+		case "const":
+			err = s.parseConst(doc)
+		case "enum":
+			err = s.parseEnum(doc)
+		case "struct", "union", "exception":
+			err = s.parseStruct(doc)
+		case "service":
+			err = s.parseService(doc)
+		default:
+			err = s.errorf("unexpected %q", s.tok.text)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func (p *Parser) parseInclude(s *scanner, doc *Document) error {
+	if err := s.advance(); err != nil {
+		return err
+	}
+	name, err := s.expectString()
+	if err != nil {
+		return err
+	}
+	if doc.Path == "" {
+		return s.errorf("include not supported without file path")
+	}
+
+	inc, err := p.ParseFile(filepath.Join(filepath.Dir(doc.Path), name))
+	if err != nil {
+		return err
+	}
+	doc.Includes = append(doc.Includes, inc)
+	return nil
+}
+
+type scanner struct {
+	lex *lexer
+	tok token
+}
+
+func (s *scanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", s.tok.line, fmt.Sprintf(format, args...))
+}
+
+func (s *scanner) advance() error {
+	tok, err := s.lex.next()
+	if err != nil {
+		return err
+	}
+	s.tok = tok
+	return nil
+}
+
+func (s *scanner) is(sym string) bool {
+	return (s.tok.kind == tokSymbol || s.tok.kind == tokIdent) && s.tok.text == sym
+}
+
+// accept 当前token为sym时跳过
+func (s *scanner) accept(sym string) (bool, error) {
+	if !s.is(sym) {
+		return false, nil
+	}
+
+	return true, s.advance()
+}
+
+func (s *scanner) expect(sym string) error {
+	if !s.is(sym) {
+		return s.errorf("expect %q, got %q", sym, s.tok.text)
+	}
+
+	return s.advance()
+}
+
+func (s *scanner) expectIdent() (string, error) {
+	if s.tok.kind != tokIdent {
+		return "", s.errorf("expect identifier, got %q", s.tok.text)
+	}
+	text := s.tok.text
+	return text, s.advance()
+}
+
+func (s *scanner) expectString() (string, error) {
+	if s.tok.kind != tokString {
+		return "", s.errorf("expect string, got %q", s.tok.text)
+	}
+	text := s.tok.text
+	return text, s.advance()
+}
+
+func (s *scanner) expectInt() (int64, error) {
+	if s.tok.kind != tokInt {
+		return 0, s.errorf("expect integer, got %q", s.tok.text)
+	}
+	v, err := strconv.ParseInt(s.tok.text, 0, 64)
+	if err != nil {
+		return 0, s.errorf("invalid integer %q", s.tok.text)
+	}
+	return v, s.advance()
+}
+
+// skipSeparator 跳过可选的,或;
+func (s *scanner) skipSeparator() error {
+	if s.is(",") || s.is(";") {
+		return s.advance()
+	}
+
+	return nil
+}
+
+// parseAnnotations 解析可选的(key = "value", ...)
+func (s *scanner) parseAnnotations() (map[string]string, error) {
+	if !s.is("(") {
+		return nil, nil
+	}
+	if err := s.advance(); err != nil {
+		return nil, err
+	}
+
+	annotations := make(map[string]string)
+	for !s.is(")") {
+		key, err := s.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		value := ""
+		if ok, err := s.accept("="); err != nil {
+			return nil, err
+		} else if ok {
+			if value, err = s.expectString(); err != nil {
+				return nil, err
+			}
+		}
+		annotations[key] = value
+		if err := s.skipSeparator(); err != nil {
+			return nil, err
+		}
+	}
+
+	return annotations, s.advance()
+}
+
+func (s *scanner) parseNamespace(doc *Document) error {
+	if err := s.advance(); err != nil {
+		return err
+	}
+	scope := s.tok.text
+	if err := s.advance(); err != nil {
+		return err
+	}
+	name, err := s.expectIdent()
+	if err != nil {
+		return err
+	}
+	doc.Namespaces[scope] = name
+	_, err = s.parseAnnotations()
+	return err
+}
+
+func (s *scanner) parseType() (*Type, error) {
+	name, err := s.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Type{Name: name}
+	switch name {
+	case "list", "set":
+		if err := s.expect("<"); err != nil {
+			return nil, err
+		}
+		if t.ValueType, err = s.parseType(); err != nil {
+			return nil, err
+		}
+		if err := s.expect(">"); err != nil {
+			return nil, err
+		}
+	case "map":
+		if err := s.expect("<"); err != nil {
+			return nil, err
+		}
+		if t.KeyType, err = s.parseType(); err != nil {
+			return nil, err
+		}
+		if err := s.expect(","); err != nil {
+			return nil, err
+		}
+		if t.ValueType, err = s.parseType(); err != nil {
+			return nil, err
+		}
+		if err := s.expect(">"); err != nil {
+			return nil, err
+		}
+	}
+
+	// 类型上的annotation不影响生成代码
+	if _, err := s.parseAnnotations(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *scanner) parseConstValue() (*ConstValue, error) {
+	tok := s.tok
+	switch {
+	case tok.kind == tokInt:
+		return &ConstValue{Kind: "int", Value: tok.text}, s.advance()
+	case tok.kind == tokDouble:
+		return &ConstValue{Kind: "double", Value: tok.text}, s.advance()
+	case tok.kind == tokString:
+		return &ConstValue{Kind: "string", Value: tok.text}, s.advance()
+	case tok.kind == tokIdent:
+		return &ConstValue{Kind: "ident", Value: tok.text}, s.advance()
+	case s.is("["):
+		if err := s.advance(); err != nil {
+			return nil, err
+		}
+		v := &ConstValue{Kind: "list"}
+		for !s.is("]") {
+			elem, err := s.parseConstValue()
+			if err != nil {
+				return nil, err
+			}
+			v.List = append(v.List, elem)
+			if err := s.skipSeparator(); err != nil {
+				return nil, err
+			}
+		}
+		return v, s.advance()
+	case s.is("{"):
+		if err := s.advance(); err != nil {
+			return nil, err
+		}
+		v := &ConstValue{Kind: "map"}
+		for !s.is("}") {
+			key, err := s.parseConstValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := s.expect(":"); err != nil {
+				return nil, err
+			}
+			val, err := s.parseConstValue()
+			if err != nil {
+				return nil, err
+			}
+			v.Keys = append(v.Keys, key)
+			v.Vals = append(v.Vals, val)
+			if err := s.skipSeparator(); err != nil {
+				return nil, err
+			}
+		}
+		return v, s.advance()
+	default:
+		return nil, s.errorf("invalid const value %q", tok.text)
+	}
+}
+
+func (s *scanner) parseTypedef(doc *Document) error {
+	if err := s.advance(); err != nil {
+		return err
+	}
+	t, err := s.parseType()
+	if err != nil {
+		return err
+	}
+	name, err := s.expectIdent()
+	if err != nil {
+		return err
+	}
+	doc.Typedefs = append(doc.Typedefs, &Typedef{Name: name, Type: t})
+	if _, err := s.parseAnnotations(); err != nil {
+		return err
+	}
+	return s.skipSeparator()
+}
+
+func (s *scanner) parseConst(doc *Document) error {
+	if err := s.advance(); err != nil {
+		return err
+	}
+	t, err := s.parseType()
+	if err != nil {
+		return err
+	}
+	name, err := s.expectIdent()
+	if err != nil {
+		return err
+	}
+	if err := s.expect("="); err != nil {
+		return err
+	}
+	value, err := s.parseConstValue()
+	if err != nil {
+		return err
+	}
+	doc.Consts = append(doc.Consts, &Const{Name: name, Type: t, Value: value})
+	return s.skipSeparator()
+}
+
+func (s *scanner) parseEnum(doc *Document) error {
+	if err := s.advance(); err != nil {
+		return err
+	}
+	name, err := s.expectIdent()
+	if err != nil {
+		return err
+	}
+	if err := s.expect("{"); err != nil {
+		return err
+	}
+
+	e := &Enum{Name: name}
+	next := int64(0)
+	for !s.is("}") {
+		vname, err := s.expectIdent()
+		if err != nil {
+			return err
+		}
+		if ok, err := s.accept("="); err != nil {
+			return err
+		} else if ok {
+			if next, err = s.expectInt(); err != nil {
+				return err
+			}
+		}
+		e.Values = append(e.Values, &EnumValue{Name: vname, Value: next})
+		next++
+		if _, err := s.parseAnnotations(); err != nil {
+			return err
+		}
+		if err := s.skipSeparator(); err != nil {
+			return err
+		}
+	}
+	if err := s.advance(); err != nil {
+		return err
+	}
+
+	doc.Enums = append(doc.Enums, e)
+	_, err = s.parseAnnotations()
+	return err
+}
+
+// parseFields 解析字段列表,直到end,未指定id时从-1开始递减
+func (s *scanner) parseFields(end string) ([]*Field, error) {
+	var fields []*Field
+	autoID := int16(-1)
+	for !s.is(end) {
+		f := &Field{}
+		if s.tok.kind == tokInt {
+			id, err := s.expectInt()
+			if err != nil {
+				return nil, err
+			}
+			if id < -32768 || id > 32767 {
+				return nil, s.errorf("field id out of range, %d", id)
+			}
+			f.ID = int16(id)
+			if err := s.expect(":"); err != nil {
+				return nil, err
+			}
+		} else {
+			f.ID = autoID
+			autoID--
+		}
+
+		if ok, err := s.accept("required"); err != nil {
+			return nil, err
+		} else if ok {
+			f.Required = Required
+		} else if ok, err := s.accept("optional"); err != nil {
+			return nil, err
+		} else if ok {
+			f.Required = Optional
+		}
+
+		var err error
+		if f.Type, err = s.parseType(); err != nil {
+			return nil, err
+		}
+		if f.Name, err = s.expectIdent(); err != nil {
+			return nil, err
+		}
+		if ok, err := s.accept("="); err != nil {
+			return nil, err
+		} else if ok {
+			if f.Default, err = s.parseConstValue(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := s.parseAnnotations(); err != nil {
+			return nil, err
+		}
+		if err := s.skipSeparator(); err != nil {
+			return nil, err
+		}
+
+		for _, x := range fields {
+			if x.ID == f.ID {
+				return nil, s.errorf("duplicate field id %d", f.ID)
+			}
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, s.advance()
+}
+
+func (s *scanner) parseStruct(doc *Document) error {
+	kind := StructKind(s.tok.text)
+	if err := s.advance(); err != nil {
+		return err
+	}
+	name, err := s.expectIdent()
+	if err != nil {
+		return err
+	}
+	if err := s.expect("{"); err != nil {
+		return err
+	}
+	fields, err := s.parseFields("}")
+	if err != nil {
+		return err
+	}
+	doc.Structs = append(doc.Structs, &Struct{Kind: kind, Name: name, Fields: fields})
+	_, err = s.parseAnnotations()
+	return err
+}
+
+func (s *scanner) parseService(doc *Document) error {
+	if err := s.advance(); err != nil {
+		return err
+	}
+	name, err := s.expectIdent()
+	if err != nil {
+		return err
+	}
+
+	svc := &Service{Name: name}
+	if ok, err := s.accept("extends"); err != nil {
+		return err
+	} else if ok {
+		if svc.Extends, err = s.expectIdent(); err != nil {
+			return err
+		}
+	}
+	if err := s.expect("{"); err != nil {
+		return err
+	}
+
+	for !s.is("}") {
+		fn := &Function{}
+		if ok, err := s.accept("oneway"); err != nil {
+			return err
+		} else if ok {
+			fn.Oneway = true
+		}
+		if ok, err := s.accept("void"); err != nil {
+			return err
+		} else if !ok {
+			if fn.Return, err = s.parseType(); err != nil {
+				return err
+			}
+		}
+		if fn.Name, err = s.expectIdent(); err != nil {
+			return err
+		}
+		if err := s.expect("("); err != nil {
+			return err
+		}
+		if fn.Args, err = s.parseFields(")"); err != nil {
+			return err
+		}
+		if ok, err := s.accept("throws"); err != nil {
+			return err
+		} else if ok {
+			if err := s.expect("("); err != nil {
+				return err
+			}
+			if fn.Throws, err = s.parseFields(")"); err != nil {
+				return err
+			}
+		}
+		if fn.Annotations, err = s.parseAnnotations(); err != nil {
+			return err
+		}
+		if err := s.skipSeparator(); err != nil {
+			return err
+		}
+		svc.Functions = append(svc.Functions, fn)
+	}
+	if err := s.advance(); err != nil {
+		return err
+	}
+
+	if svc.Annotations, err = s.parseAnnotations(); err != nil {
+		return err
+	}
+	doc.Services = append(doc.Services, svc)
+	return nil
+}
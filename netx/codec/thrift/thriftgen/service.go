@@ -0,0 +1,289 @@
+package thriftgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// method 生成服务方法所需信息
+type method struct {
+	*Function
+	name    string         // Go方法名
+	cmdID   int            //
+	args    *Struct        // 参数结构体
+	result  *Struct        // 结果结构体,oneway时为nil
+	params  []string       // 参数名
+	fields  []*structField // 参数字段
+	retType string         // 返回值类型,void时为空
+	retZero string         // 返回值零值
+}
+
+func (g *generator) genService(svc *Service, cmdID *int) error {
+	if v, ok := svc.Annotations[annoCmdIDBase]; ok {
+		base, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("thriftgen: invalid %s of %s, %q", annoCmdIDBase, svc.Name, v)
+		}
+		*cmdID = base
+	}
+
+	name := goName(svc.Name)
+	var base string
+	if svc.Extends != "" {
+		def, _ := g.lookup(g.doc, svc.Extends)
+		if _, ok := def.(*Service); !ok {
+			return fmt.Errorf("thriftgen: unknown service %s", svc.Extends)
+		}
+		base = goName(svc.Extends)
+	}
+
+	methods := make([]*method, 0, len(svc.Functions))
+	for _, fn := range svc.Functions {
+		m, err := g.newMethod(name, fn, cmdID)
+		if err != nil {
+			return err
+		}
+		methods = append(methods, m)
+	}
+
+	for _, m := range methods {
+		if err := g.genStruct(m.args, g.doc); err != nil {
+			return err
+		}
+		if m.result != nil {
+			if err := g.genStruct(m.result, g.doc); err != nil {
+				return err
+			}
+		}
+	}
+
+	g.p("const (")
+	for _, m := range methods {
+		g.p("%s%sCmdID = %d", name, m.name, m.cmdID)
+	}
+	g.p(")\n")
+
+	g.imports["context"] = true
+	g.imports[netxImport] = true
+	g.genHandler(name, base, methods)
+	g.genClient(name, base, methods)
+	return g.genRegister(name, base, methods)
+}
+
+func (g *generator) newMethod(service string, fn *Function, cmdID *int) (*method, error) {
+	m := &method{Function: fn, name: camelCase(fn.Name)}
+	if v, ok := fn.Annotations[annoCmdID]; ok {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("thriftgen: invalid %s of %s, %q", annoCmdID, fn.Name, v)
+		}
+		m.cmdID = id
+	} else {
+		*cmdID++
+		m.cmdID = *cmdID
+	}
+
+	m.args = &Struct{Kind: KindStruct, Name: service + m.name + "Args", Fields: fn.Args}
+	fields, err := g.structFields(m.args, g.doc)
+	if err != nil {
+		return nil, err
+	}
+	m.fields = fields
+	for _, f := range fn.Args {
+		m.params = append(m.params, paramName(f.Name))
+	}
+
+	if fn.Oneway {
+		if fn.Return != nil || len(fn.Throws) > 0 {
+			return nil, fmt.Errorf("thriftgen: oneway method %s can not return value", fn.Name)
+		}
+		return m, nil
+	}
+
+	m.result = &Struct{Kind: KindStruct, Name: service + m.name + "Result"}
+	if fn.Return != nil {
+		m.result.Fields = append(m.result.Fields, &Field{ID: 0, Name: "success", Type: fn.Return, Required: Optional})
+		if m.retType, err = g.goType(fn.Return, g.doc); err != nil {
+			return nil, err
+		}
+		if m.retZero, err = g.zeroValue(fn.Return, g.doc); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range fn.Throws {
+		r, err := g.resolve(f.Type, g.doc)
+		if err != nil {
+			return nil, err
+		}
+		if r.kind != kindStruct {
+			return nil, fmt.Errorf("thriftgen: %s throws non-exception type %s", fn.Name, f.Type.Name)
+		}
+		m.result.Fields = append(m.result.Fields, &Field{ID: f.ID, Name: f.Name, Type: f.Type, Required: Optional})
+	}
+
+	return m, nil
+}
+
+// signature 方法参数列表,不包含ctx
+func (g *generator) signature(m *method) string {
+	parts := make([]string, 0, len(m.params))
+	for i, f := range m.fields {
+		parts = append(parts, m.params[i]+" "+f.typ)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func (g *generator) genHandler(name, base string, methods []*method) {
+	g.p("// %sHandler %s服务接口,IDL中声明的异常直接作为error返回", name, name)
+	g.p("type %sHandler interface {", name)
+	if base != "" {
+		g.p("%sHandler", base)
+	}
+	for _, m := range methods {
+		params := g.signature(m)
+		if params != "" {
+			params = ", " + params
+		}
+		if m.retType != "" {
+			g.p("%s(ctx context.Context%s) (%s, error)", m.name, params, m.retType)
+		} else {
+			g.p("%s(ctx context.Context%s) error", m.name, params)
+		}
+	}
+	g.p("}\n")
+}
+
+func (g *generator) genClient(name, base string, methods []*method) {
+	g.p("// %sClient %s服务客户端", name, name)
+	g.p("type %sClient struct {", name)
+	if base != "" {
+		g.p("*%sClient", base)
+	}
+	g.p("c *thrift.Client")
+	g.p("}\n")
+
+	g.p("// New%sClient codec为netx.CodecTypeThrift或netx.CodecTypeThriftCompact", name)
+	g.p("func New%sClient(cli netx.Client, service string, codec netx.CodecType) *%sClient {", name, name)
+	if base != "" {
+		g.p("return &%sClient{%sClient: New%sClient(cli, service, codec), c: thrift.NewClient(cli, service, codec)}", name, base, base)
+	} else {
+		g.p("return &%sClient{c: thrift.NewClient(cli, service, codec)}", name)
+	}
+	g.p("}\n")
+
+	for _, m := range methods {
+		params := g.signature(m)
+		if params != "" {
+			params = ", " + params
+		}
+		ret, fail := "error", "return err"
+		if m.retType != "" {
+			ret = "(" + m.retType + ", error)"
+			fail = "return " + m.retZero + ", err"
+		}
+
+		g.p("func (c *%sClient) %s(ctx context.Context%s, opts ...netx.CallOption) %s {", name, m.name, params, ret)
+		inits := make([]string, 0, len(m.fields))
+		for i, f := range m.fields {
+			inits = append(inits, f.name+": "+m.params[i])
+		}
+		g.p("args := &%s{%s}", goName(m.args.Name), strings.Join(inits, ", "))
+		if m.result == nil {
+			g.p("return c.c.Invoke(ctx, %q, %s%sCmdID, args, nil, opts...)", m.Function.Name, name, m.name)
+			g.p("}\n")
+			continue
+		}
+
+		g.p("result := &%s{}", goName(m.result.Name))
+		g.p("if err := c.c.Invoke(ctx, %q, %s%sCmdID, args, result, opts...); err != nil {", m.Function.Name, name, m.name)
+		g.p(fail)
+		g.p("}")
+		for _, f := range m.Throws {
+			g.p("if result.%s != nil {", fieldName(f.Name))
+			if m.retType != "" {
+				g.p("return %s, result.%s", m.retZero, fieldName(f.Name))
+			} else {
+				g.p("return result.%s", fieldName(f.Name))
+			}
+			g.p("}")
+		}
+		if m.retType == "" {
+			g.p("return nil")
+		} else if r, _ := g.resolve(m.Return, g.doc); r.kind == kindStruct || g.isScalar(m.Return, g.doc) {
+			g.p("if result.Success == nil {")
+			g.p("return %s, thrift.ErrMissingResult", m.retZero)
+			g.p("}")
+			if g.isScalar(m.Return, g.doc) {
+				g.p("return *result.Success, nil")
+			} else {
+				g.p("return result.Success, nil")
+			}
+		} else {
+			g.p("return result.Success, nil")
+		}
+		g.p("}\n")
+	}
+}
+
+func (g *generator) genRegister(name, base string, methods []*method) error {
+	g.p("// Register%sServer 注册%s服务路由,Name为IDL中的方法名", name, name)
+	g.p("func Register%sServer(s netx.Server, h %sHandler) {", name, name)
+	if base != "" {
+		g.p("Register%sServer(s, h)", base)
+	}
+	for _, m := range methods {
+		argsName := goName(m.args.Name)
+		g.p("s.Register(&netx.Route{Name: %q, CmdID: %s%sCmdID, Handler: thrift.Handler(%q, func() thrift.Struct {", m.Function.Name, name, m.name, m.Function.Name)
+		g.p("return New%s()", argsName)
+		g.p("}, func(ctx context.Context, args thrift.Struct) (thrift.Struct, error) {")
+
+		params := make([]string, 0, len(m.fields)+1)
+		params = append(params, "ctx")
+		if len(m.fields) > 0 {
+			g.p("a := args.(*%s)", argsName)
+		}
+		for _, f := range m.fields {
+			params = append(params, "a."+f.name)
+		}
+		call := "h." + m.name + "(" + strings.Join(params, ", ") + ")"
+
+		if m.result == nil {
+			g.p("return nil, %s", call)
+			g.p("})})")
+			continue
+		}
+
+		g.p("result := &%s{}", goName(m.result.Name))
+		if m.retType != "" {
+			g.p("v, err := %s", call)
+		} else {
+			g.p("err := %s", call)
+		}
+		g.p("if err != nil {")
+		for _, f := range m.Throws {
+			typ, err := g.goType(f.Type, g.doc)
+			if err != nil {
+				return err
+			}
+			g.p("if e, ok := err.(%s); ok {", typ)
+			g.p("result.%s = e", fieldName(f.Name))
+			g.p("return result, nil")
+			g.p("}")
+		}
+		g.p("return nil, err")
+		g.p("}")
+		if m.retType != "" {
+			if g.isScalar(m.Return, g.doc) {
+				g.p("result.Success = &v")
+			} else {
+				g.p("result.Success = v")
+			}
+		}
+		g.p("return result, nil")
+		g.p("})})")
+	}
+	g.p("}\n")
+	return nil
+}
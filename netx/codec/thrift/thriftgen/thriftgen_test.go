@@ -0,0 +1,72 @@
+package thriftgen
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	parser := NewParser()
+	for _, name := range []string{"base", "calc"} {
+		doc, err := parser.ParseFile(filepath.Join("example", name+".thrift"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		src, err := Generate(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		golden, err := ioutil.ReadFile(filepath.Join("example", name+".go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(src, golden) {
+			t.Errorf("%s.go is out of date, please regenerate", name)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	cases := []string{
+		"struct A { 1: i32 }",
+		"struct A { 1: i32 a; 1: i32 b }",
+		"enum E { A = 1, ",
+		"service S { void f(1: i32 a) throws }",
+		"/* unterminated",
+	}
+	for _, src := range cases {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("expect error, %q", src)
+		}
+	}
+
+	doc, err := Parse(`
+namespace go demo
+// comment
+# comment
+typedef i64 ID
+struct A {
+	1: required ID id
+	2: optional list<string> names = ["a", "b"]
+} (go.type = "A")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Structs) != 1 || len(doc.Structs[0].Fields) != 2 || doc.Typedefs[0].Name != "ID" {
+		t.Errorf("bad document, %+v", doc)
+	}
+
+	src, err := Generate(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "package demo") {
+		t.Errorf("bad package, %s", src)
+	}
+}
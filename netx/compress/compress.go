@@ -0,0 +1,155 @@
+// Package compress payload压缩,供rpc,theader等协议按帧压缩使用
+//	Type与THeader中的TransformID保持一致,rpc协议中占用codec字节的高4位
+//	默认注册zlib和snappy,zstd需要使用者自行实现并注册:
+//	compress.Register(myZstd{})
+//	小消息压缩率较低,可以通过字典提升,通信双方需要使用相同的字典:
+//	compress.Register(compress.NewZlib(zlib.BestSpeed, dict))
+//	解压后大小默认不超过MaxSize,防止伪造长度或压缩炸弹耗尽内存
+package compress
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// some error
+var (
+	ErrNotFound = errors.New("compress: not found compressor")
+	ErrCorrupt  = errors.New("compress: corrupt input")
+	ErrTooLarge = errors.New("compress: decompressed size too large")
+)
+
+// MaxSize 默认解压后最大字节数,与协议最大帧长度一致
+const MaxSize = 0x3FFFFFFF
+
+// Type 压缩类型,取值[0,15]
+type Type uint8
+
+const (
+	TypeNone   Type = 0
+	TypeZlib   Type = 1
+	TypeSnappy Type = 3
+	TypeZstd   Type = 5
+	TypeMax    Type = 15
+)
+
+// Compressor 压缩算法,需要线程安全
+type Compressor interface {
+	Type() Type
+	Name() string
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+// Limiter 可选接口,解压时限制输出大小,超过max返回ErrTooLarge
+//	未实现时,Decompress在解压完成后再校验大小
+type Limiter interface {
+	DecompressLimit(src []byte, max int) ([]byte, error)
+}
+
+func init() {
+	Register(NewZlib(-1, nil))
+	Register(NewSnappy())
+}
+
+var (
+	gMux     sync.RWMutex
+	gTypeMap = make(map[Type]Compressor)
+	gNameMap = make(map[string]Compressor)
+	gNames   []string // 按注册顺序,用于协商时表示优先级
+)
+
+// Register 注册Compressor,相同类型会覆盖,可用于替换默认实现
+func Register(c Compressor) {
+	if c.Type() == TypeNone || c.Type() > TypeMax {
+		panic("compress: invalid type")
+	}
+
+	gMux.Lock()
+	defer gMux.Unlock()
+	if old := gTypeMap[c.Type()]; old != nil {
+		delete(gNameMap, old.Name())
+		for i, name := range gNames {
+			if name == old.Name() {
+				gNames = append(gNames[:i:i], gNames[i+1:]...)
+				break
+			}
+		}
+	}
+	gTypeMap[c.Type()] = c
+	gNameMap[c.Name()] = c
+	gNames = append(gNames, c.Name())
+}
+
+// Get 通过类型查询
+func Get(t Type) Compressor {
+	gMux.RLock()
+	defer gMux.RUnlock()
+	return gTypeMap[t]
+}
+
+// GetByName 通过名字查询
+func GetByName(name string) Compressor {
+	gMux.RLock()
+	defer gMux.RUnlock()
+	return gNameMap[name]
+}
+
+// Accept 返回所有支持的压缩名,逗号分隔,用于告知对端
+func Accept() string {
+	gMux.RLock()
+	defer gMux.RUnlock()
+	return strings.Join(gNames, ",")
+}
+
+// Negotiate 按对端声明的顺序,返回第一个本地也支持的压缩类型
+func Negotiate(accept string) Type {
+	for accept != "" {
+		var name string
+		if idx := strings.IndexByte(accept, ','); idx != -1 {
+			name, accept = accept[:idx], accept[idx+1:]
+		} else {
+			name, accept = accept, ""
+		}
+
+		if c := GetByName(strings.TrimSpace(name)); c != nil {
+			return c.Type()
+		}
+	}
+
+	return TypeNone
+}
+
+// Compress 使用指定类型压缩
+func Compress(t Type, src []byte) ([]byte, error) {
+	c := Get(t)
+	if c == nil {
+		return nil, ErrNotFound
+	}
+
+	return c.Compress(src)
+}
+
+// Decompress 使用指定类型解压,输出不超过MaxSize
+func Decompress(t Type, src []byte) ([]byte, error) {
+	return DecompressLimit(t, src, MaxSize)
+}
+
+// DecompressLimit 使用指定类型解压,输出超过max时返回ErrTooLarge
+func DecompressLimit(t Type, src []byte, max int) ([]byte, error) {
+	c := Get(t)
+	if c == nil {
+		return nil, ErrNotFound
+	}
+
+	if l, ok := c.(Limiter); ok {
+		return l.DecompressLimit(src, max)
+	}
+
+	dst, err := c.Decompress(src)
+	if err == nil && len(dst) > max {
+		return nil, ErrTooLarge
+	}
+	return dst, err
+}
@@ -0,0 +1,92 @@
+package compress
+
+import (
+	"bytes"
+	"compress/zlib"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testData() [][]byte {
+	random := make([]byte, 200000)
+	rand.Read(random)
+	return [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("hello world"),
+		[]byte(strings.Repeat("abcdefgh", 30000)),
+		[]byte(strings.Repeat(`{"id":1,"name":"nova","tags":["a","b"]}`, 100)),
+		random,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, c := range []Compressor{Get(TypeZlib), Get(TypeSnappy), NewZlib(zlib.BestSpeed, []byte(`{"id":,"name":"tags"}`))} {
+		for _, src := range testData() {
+			data, err := c.Compress(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := c.Decompress(data)
+			if err != nil {
+				t.Fatalf("%s decompress fail, %+v", c.Name(), err)
+			}
+			if !bytes.Equal(src, out) {
+				t.Fatalf("%s round trip mismatch, size=%d", c.Name(), len(src))
+			}
+		}
+	}
+}
+
+func TestSnappyRatio(t *testing.T) {
+	src := []byte(strings.Repeat("abcdefgh", 30000))
+	data, _ := NewSnappy().Compress(src)
+	if len(data) > len(src)/10 {
+		t.Fatalf("bad compress ratio, %d/%d", len(data), len(src))
+	}
+
+	if _, err := NewSnappy().Decompress([]byte{10, 0xFF}); err != ErrCorrupt {
+		t.Fatalf("expect corrupt, %+v", err)
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	// 伪造长度头,声明4GB但没有数据
+	forged := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x0F, 0x00}
+	if _, err := NewSnappy().Decompress(forged); err != ErrTooLarge {
+		t.Fatalf("expect too large, %+v", err)
+	}
+	forged = []byte{0x80, 0x80, 0x40, 0x00}
+	if _, err := NewSnappy().Decompress(forged); err != ErrCorrupt {
+		t.Fatalf("expect corrupt, %+v", err)
+	}
+
+	// 压缩炸弹,少量数据解压出大量0
+	bomb := make([]byte, 1<<20)
+	for _, typ := range []Type{TypeZlib, TypeSnappy} {
+		data, err := Compress(typ, bomb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecompressLimit(typ, data, 1<<16); err != ErrTooLarge {
+			t.Fatalf("%d expect too large, %+v", typ, err)
+		}
+		out, err := DecompressLimit(typ, data, len(bomb))
+		if err != nil || len(out) != len(bomb) {
+			t.Fatalf("%d decompress fail, %+v", typ, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	if Negotiate("zstd, snappy,zlib") != TypeSnappy {
+		t.Fatal("expect snappy")
+	}
+	if Negotiate("lz4") != TypeNone || Negotiate("") != TypeNone {
+		t.Fatal("expect none")
+	}
+	if !strings.Contains(Accept(), "zlib") {
+		t.Fatal("expect zlib registered")
+	}
+}
@@ -0,0 +1,219 @@
+package compress
+
+import (
+	"encoding/binary"
+)
+
+// snappy block格式,不包含framing格式
+// https://github.com/google/snappy/blob/main/format_description.txt
+const (
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+	tagCopy4   = 0x03
+
+	snappyBlockSize = 1 << 16 // 按块查找匹配,保证offset不超过2字节
+	snappyMinMatch  = 4
+	snappyHashBits  = 14
+	snappyMaxRatio  = 22 // 最大压缩比上界
+)
+
+// NewSnappy 创建snappy压缩,压缩率低于zlib,但速度快很多
+func NewSnappy() Compressor {
+	return snappyCompressor{}
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Type() Type {
+	return TypeSnappy
+}
+
+func (snappyCompressor) Name() string {
+	return "snappy"
+}
+
+func (snappyCompressor) Compress(src []byte) ([]byte, error) {
+	dst := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(src)+len(src)/6+32)
+	n := binary.PutUvarint(dst, uint64(len(src)))
+	dst = dst[:n]
+
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyBlockSize {
+			block = block[:snappyBlockSize]
+		}
+		src = src[len(block):]
+		dst = snappyEncodeBlock(dst, block)
+	}
+
+	return dst, nil
+}
+
+func (s snappyCompressor) Decompress(src []byte) ([]byte, error) {
+	return s.DecompressLimit(src, MaxSize)
+}
+
+func (snappyCompressor) DecompressLimit(src []byte, max int) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 || size > 0xFFFFFFFF {
+		return nil, ErrCorrupt
+	}
+	// 分配前校验头部声明的长度,避免伪造长度导致大内存分配
+	if size > uint64(max) {
+		return nil, ErrTooLarge
+	}
+	src = src[n:]
+	// 单个copy最多3字节还原64字节,超过该比例的长度必然是伪造的
+	if size > uint64(len(src))*snappyMaxRatio {
+		return nil, ErrCorrupt
+	}
+
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 0x03 {
+		case tagLiteral:
+			x := int(tag >> 2)
+			src = src[1:]
+			if x >= 60 {
+				bytes := x - 59
+				if len(src) < bytes {
+					return nil, ErrCorrupt
+				}
+				x = 0
+				for i := bytes - 1; i >= 0; i-- {
+					x = x<<8 | int(src[i])
+				}
+				src = src[bytes:]
+			}
+			length = x + 1
+			if length > len(src) {
+				return nil, ErrCorrupt
+			}
+			if uint64(len(dst)+length) > size {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case tagCopy1:
+			if len(src) < 2 {
+				return nil, ErrCorrupt
+			}
+			length = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xE0)<<3 | int(src[1])
+			src = src[2:]
+		case tagCopy2:
+			if len(src) < 3 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case tagCopy4:
+			if len(src) < 5 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > size {
+			return nil, ErrCorrupt
+		}
+		// 允许重叠复制,需要逐字节
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if uint64(len(dst)) != size {
+		return nil, ErrCorrupt
+	}
+
+	return dst, nil
+}
+
+func snappyHash(v uint32) uint32 {
+	return (v * 0x1e35a7bd) >> (32 - snappyHashBits)
+}
+
+// snappyEncodeBlock 贪心匹配,所有复制均使用2字节offset
+func snappyEncodeBlock(dst, src []byte) []byte {
+	if len(src) < snappyMinMatch+4 {
+		return snappyEmitLiteral(dst, src)
+	}
+
+	var table [1 << snappyHashBits]int32
+	for i := range table {
+		table[i] = -1
+	}
+
+	lit := 0 // 尚未输出的literal起始位置
+	i := 0
+	limit := len(src) - snappyMinMatch
+	for i <= limit {
+		v := binary.LittleEndian.Uint32(src[i:])
+		h := snappyHash(v)
+		cand := int(table[h])
+		table[h] = int32(i)
+		if cand < 0 || binary.LittleEndian.Uint32(src[cand:]) != v {
+			i++
+			continue
+		}
+
+		dst = snappyEmitLiteral(dst, src[lit:i])
+		length := snappyMinMatch
+		for i+length < len(src) && src[cand+length] == src[i+length] {
+			length++
+		}
+		dst = snappyEmitCopy(dst, i-cand, length)
+		i += length
+		lit = i
+	}
+
+	return snappyEmitLiteral(dst, src[lit:])
+}
+
+func snappyEmitLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|tagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+
+	return append(dst, lit...)
+}
+
+func snappyEmitCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > 64 {
+			n = 64
+			// 剩余部分不足以单独复制时,保留至少4字节
+			if length-n < snappyMinMatch {
+				n = length - snappyMinMatch
+			}
+		}
+		dst = append(dst, byte(n-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+
+	return dst
+}
@@ -0,0 +1,86 @@
+package compress
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// NewZlib 创建zlib压缩,level同zlib包,dict不为空时使用预置字典,适用于大量结构相似的小消息
+func NewZlib(level int, dict []byte) Compressor {
+	z := &zlibCompressor{level: level, dict: dict}
+	z.pool.New = func() interface{} {
+		w, err := zlib.NewWriterLevelDict(nil, z.level, z.dict)
+		if err != nil {
+			return err
+		}
+		return w
+	}
+	return z
+}
+
+type zlibCompressor struct {
+	level int
+	dict  []byte
+	pool  sync.Pool
+}
+
+func (z *zlibCompressor) Type() Type {
+	return TypeZlib
+}
+
+func (z *zlibCompressor) Name() string {
+	return "zlib"
+}
+
+func (z *zlibCompressor) Compress(src []byte) ([]byte, error) {
+	var w *zlib.Writer
+	switch x := z.pool.Get().(type) {
+	case *zlib.Writer:
+		w = x
+	case error:
+		return nil, x
+	}
+	defer z.pool.Put(w)
+
+	out := bytes.NewBuffer(make([]byte, 0, len(src)/2+16))
+	w.Reset(out)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func (z *zlibCompressor) Decompress(src []byte) ([]byte, error) {
+	return z.DecompressLimit(src, MaxSize)
+}
+
+func (z *zlibCompressor) DecompressLimit(src []byte, max int) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	if len(z.dict) > 0 {
+		r, err = zlib.NewReaderDict(bytes.NewReader(src), z.dict)
+	} else {
+		r, err = zlib.NewReader(bytes.NewReader(src))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// 多读1字节用于判断是否超限
+	dst, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(dst) > max {
+		return nil, ErrTooLarge
+	}
+	return dst, nil
+}
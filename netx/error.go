@@ -119,6 +119,11 @@ func Conflict(format string, args ...interface{}) error {
 	return NewError(http.StatusConflict, "", format, args...)
 }
 
+// TooManyRequests generates a 429 error.
+func TooManyRequests(format string, args ...interface{}) error {
+	return NewError(http.StatusTooManyRequests, "", format, args...)
+}
+
 // InternalServerError generates a 500 error.
 func InternalServerError(format string, args ...interface{}) error {
 	return NewError(http.StatusInternalServerError, "", format, args...)
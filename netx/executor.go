@@ -7,6 +7,8 @@ package netx
 //	3:异步线程池,无序但线程数不超过最大值,pooled.New()
 //	4:异步hash线程,不同功能指定不同线程,hashing.New()
 //	5:异步go routine,gorunner.New
+//	6:异步有界线程池,任务窃取,支持拒绝策略及排队超时,stealing.New()
+//	7:异步按key有序执行,相同key串行,不同key并行,ordered.New()
 type Executor interface {
 	Name() string
 	Close() error
@@ -18,6 +20,13 @@ type Runnable interface {
 	Run() error
 }
 
+// Dropper 可选接口,任务在执行前被丢弃时(例如排队超时,队列已满)由Executor调用
+//	用于通知调用方,例如processor中的请求会返回503,避免调用方一直等待
+//	Post返回错误时任务没有被接收,Executor不会调用Drop,由Post的调用方处理
+type Dropper interface {
+	Drop(err error) error
+}
+
 // RunFunc 实现Task接口,外部可直接把函数转换成RunFunc
 type RunFunc func() error
 
@@ -63,8 +63,14 @@ func (q *Queue) Pop() netx.Runnable {
 		q.head = q.head.next
 		if q.head == nil {
 			q.tail = nil
+		} else {
+			q.head.prev = nil
 		}
+		q.size--
 		n.next = nil
+		n.prev = nil
+		n.task = nil
+		gNodePool.Put(n)
 		return t
 	}
 
@@ -0,0 +1,34 @@
+package base
+
+import (
+	"testing"
+)
+
+type task int
+
+func (t task) Run() error {
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	q := &Queue{}
+	for i := 0; i < 3; i++ {
+		q.Push(task(i))
+	}
+	for i := 0; i < 3; i++ {
+		if v := q.Pop(); v != task(i) {
+			t.Fatalf("expect %d, %v", i, v)
+		}
+		if q.Len() != 2-i {
+			t.Fatalf("bad len after pop, %d", q.Len())
+		}
+	}
+	if !q.Empty() || q.Pop() != nil {
+		t.Fatal("queue should be empty")
+	}
+
+	q.Push(task(3))
+	if q.Len() != 1 || q.Pop() != task(3) {
+		t.Fatal("bad queue after reuse")
+	}
+}
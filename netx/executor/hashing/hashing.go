@@ -16,7 +16,12 @@ func New(num int) netx.Executor {
 	e := &hashingExecutor{}
 	for i := 0; i < num; i++ {
 		w := base.NewWorker()
-		go w.RunWithWaitGroup(&e.wg)
+		e.workers = append(e.workers, w)
+		e.wg.Add(1)
+		go func() {
+			w.Run()
+			e.wg.Done()
+		}()
 	}
 
 	return e
@@ -0,0 +1,51 @@
+package hashing
+
+import (
+	"sync"
+	"testing"
+)
+
+type indexedTask struct {
+	index int
+	fn    func()
+}
+
+func (t *indexedTask) Index() int {
+	return t.index
+}
+
+func (t *indexedTask) Run() error {
+	t.fn()
+	return nil
+}
+
+// TestHashing 相同index的任务按顺序执行,Close等待所有任务执行完成
+func TestHashing(t *testing.T) {
+	ex := New(4)
+	var mux sync.Mutex
+	results := make(map[int][]int)
+	for i := 0; i < 100; i++ {
+		i := i
+		_ = ex.Post(&indexedTask{index: i % 3, fn: func() {
+			mux.Lock()
+			results[i%3] = append(results[i%3], i)
+			mux.Unlock()
+		}})
+	}
+	if err := ex.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for key, list := range results {
+		for j := 1; j < len(list); j++ {
+			if list[j] < list[j-1] {
+				t.Fatalf("index %d out of order, %v", key, list)
+			}
+		}
+		total += len(list)
+	}
+	if total != 100 {
+		t.Fatalf("expect 100 tasks, %d", total)
+	}
+}
@@ -0,0 +1,59 @@
+package ordered
+
+import (
+	"runtime"
+	"time"
+)
+
+// Options 可选配置参数
+type Options struct {
+	Workers     int           // 协程数,默认runtime.NumCPU()
+	MailboxSize int           // 每个key最多排队的任务数,默认1024
+	Throughput  int           // 每次调度单个key最多连续执行的任务数,避免热点key独占协程,默认16
+	IdleTimeout time.Duration // key空闲超过该时间后回收,默认1分钟
+}
+
+type Option func(o *Options)
+
+func (o *Options) init() {
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.MailboxSize <= 0 {
+		o.MailboxSize = 1024
+	}
+	if o.Throughput <= 0 {
+		o.Throughput = 16
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = time.Minute
+	}
+}
+
+// WithWorkers 设置协程数
+func WithWorkers(n int) Option {
+	return func(o *Options) {
+		o.Workers = n
+	}
+}
+
+// WithMailboxSize 设置每个key最多排队的任务数
+func WithMailboxSize(n int) Option {
+	return func(o *Options) {
+		o.MailboxSize = n
+	}
+}
+
+// WithThroughput 设置每次调度单个key最多连续执行的任务数
+func WithThroughput(n int) Option {
+	return func(o *Options) {
+		o.Throughput = n
+	}
+}
+
+// WithIdleTimeout 设置key空闲回收时间
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.IdleTimeout = d
+	}
+}
@@ -0,0 +1,287 @@
+// Package ordered 按key有序执行的协程池
+//	1:相同key的任务严格按投递顺序串行执行,不同key之间并行执行
+//	2:每个key对应一个有界的mailbox,已满时Post返回ErrMailboxFull
+//	3:每次调度最多连续执行Throughput个任务后让出协程,热点key不会阻塞其他key
+//	4:空闲超过IdleTimeout的mailbox自动回收,HotKeys可查询热点key统计
+//	任务需要实现Keyed接口,未实现时不保证顺序,可以使用NewTask包装
+//	server中的请求默认以连接ID为key,同一连接上的请求按顺序执行,可通过server.WithTaskKey修改
+//	ex := ordered.New(ordered.WithWorkers(8))
+//	ex.Post(ordered.NewTask(userID, fn))
+package ordered
+
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/executor/base"
+)
+
+// some error
+var (
+	ErrMailboxFull = errors.New("executor mailbox full")
+	ErrClosed      = errors.New("executor closed")
+)
+
+var (
+	gMailboxes = metrics.NewGauge(&metrics.GaugeOpts{
+		Namespace: "nova",
+		Subsystem: "executor",
+		Name:      "mailboxes",
+		Help:      "current keys with mailbox in ordered executor",
+	})
+	gMailboxRejected = metrics.NewCounter(&metrics.CounterOpts{
+		Namespace: "nova",
+		Subsystem: "executor",
+		Name:      "mailbox_rejected",
+		Help:      "tasks rejected because mailbox is full",
+	})
+)
+
+// Keyed 用于指定任务的key,key需要可以作为map的key
+type Keyed interface {
+	Key() interface{}
+}
+
+// NewTask 创建指定key的任务
+func NewTask(key interface{}, fn func() error) netx.Runnable {
+	return &keyedTask{key: key, fn: fn}
+}
+
+type keyedTask struct {
+	key interface{}
+	fn  func() error
+}
+
+func (t *keyedTask) Key() interface{} {
+	return t.key
+}
+
+func (t *keyedTask) Run() error {
+	return t.fn()
+}
+
+// KeyStat key统计信息
+type KeyStat struct {
+	Key        interface{} // key
+	Pending    int         // 当前排队的任务数
+	MaxPending int         // 最大排队任务数
+	Total      uint64      // 投递的任务总数
+	Rejected   uint64      // mailbox已满被拒绝的任务数
+}
+
+// Executor 按key有序执行
+type Executor interface {
+	netx.Executor
+	// HotKeys 按投递任务总数降序返回前n个key的统计,仅包含未被回收的key
+	HotKeys(n int) []KeyStat
+}
+
+// New 创建按key有序执行的协程池
+func New(opts ...Option) Executor {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+	o.init()
+
+	e := &orderedExecutor{opts: o, boxes: make(map[interface{}]*mailbox), done: make(chan struct{})}
+	e.cnd = sync.NewCond(&e.mux)
+	e.wg.Add(o.Workers)
+	for i := 0; i < o.Workers; i++ {
+		go e.loop()
+	}
+	go e.reclaim()
+
+	return e
+}
+
+// mailbox 单个key的任务队列,同一时刻最多被一个协程调度
+type mailbox struct {
+	e         *orderedExecutor
+	tasks     base.Queue // 待执行任务
+	scheduled bool       // 是否已经在就绪队列中或正在执行
+	active    time.Time  // 最后活跃时间
+	stat      KeyStat    // 统计信息
+}
+
+// Run 最多连续执行Throughput个任务,仍有任务时重新放入就绪队列
+func (m *mailbox) Run() error {
+	e := m.e
+	for i := 0; i < e.opts.Throughput; i++ {
+		e.mux.Lock()
+		task := m.tasks.Pop()
+		e.mux.Unlock()
+		if task == nil {
+			break
+		}
+		e.exec(task)
+	}
+
+	e.mux.Lock()
+	m.active = time.Now()
+	if m.tasks.Empty() {
+		m.scheduled = false
+	} else {
+		e.ready.Push(m)
+		e.cnd.Signal()
+	}
+	e.mux.Unlock()
+	return nil
+}
+
+type orderedExecutor struct {
+	opts   *Options                 //
+	mux    sync.Mutex               // 用于保护boxes,ready及所有mailbox
+	cnd    *sync.Cond               // 用于唤醒等待的协程
+	boxes  map[interface{}]*mailbox // 所有key对应的mailbox
+	ready  base.Queue               // 就绪的mailbox
+	closed bool                     // 已关闭
+	done   chan struct{}            // 用于退出回收协程
+	wg     sync.WaitGroup           // 用于等待所有协程退出
+}
+
+func (e *orderedExecutor) Name() string {
+	return "ordered"
+}
+
+// Close 不再接收新任务,等待已投递的任务执行完成
+func (e *orderedExecutor) Close() error {
+	e.mux.Lock()
+	if e.closed {
+		e.mux.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.cnd.Broadcast()
+	e.mux.Unlock()
+
+	close(e.done)
+	e.wg.Wait()
+
+	e.mux.Lock()
+	gMailboxes.Sub(int64(len(e.boxes)))
+	e.boxes = make(map[interface{}]*mailbox)
+	e.mux.Unlock()
+	return nil
+}
+
+func (e *orderedExecutor) Post(task netx.Runnable) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.closed {
+		return ErrClosed
+	}
+
+	var m *mailbox
+	if t, ok := task.(Keyed); ok {
+		key := t.Key()
+		m = e.boxes[key]
+		if m == nil {
+			m = &mailbox{e: e, stat: KeyStat{Key: key}}
+			e.boxes[key] = m
+			gMailboxes.Inc()
+		}
+	} else {
+		// 没有key的任务独立调度,不需要保证顺序
+		m = &mailbox{e: e}
+	}
+
+	if m.tasks.Len() >= e.opts.MailboxSize {
+		m.stat.Rejected++
+		gMailboxRejected.Inc()
+		return ErrMailboxFull
+	}
+
+	m.tasks.Push(task)
+	m.stat.Total++
+	if n := m.tasks.Len(); n > m.stat.MaxPending {
+		m.stat.MaxPending = n
+	}
+	if !m.scheduled {
+		m.scheduled = true
+		e.ready.Push(m)
+		e.cnd.Signal()
+	}
+
+	return nil
+}
+
+func (e *orderedExecutor) HotKeys(n int) []KeyStat {
+	e.mux.Lock()
+	stats := make([]KeyStat, 0, len(e.boxes))
+	for _, m := range e.boxes {
+		st := m.stat
+		st.Pending = m.tasks.Len()
+		stats = append(stats, st)
+	}
+	e.mux.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Total > stats[j].Total
+	})
+	if n > 0 && n < len(stats) {
+		stats = stats[:n]
+	}
+
+	return stats
+}
+
+// loop 从就绪队列中获取mailbox执行,关闭后等待就绪队列为空时退出
+func (e *orderedExecutor) loop() {
+	defer e.wg.Done()
+	for {
+		e.mux.Lock()
+		for e.ready.Empty() && !e.closed {
+			e.cnd.Wait()
+		}
+		m := e.ready.Pop()
+		e.mux.Unlock()
+		if m == nil {
+			break
+		}
+
+		_ = m.Run()
+	}
+}
+
+// exec 执行任务,panic不影响后续任务
+func (e *orderedExecutor) exec(task netx.Runnable) {
+	defer func() {
+		if x := recover(); x != nil {
+			log.Printf("executor task panic, err=%+v, stack=%s", x, debug.Stack())
+		}
+	}()
+
+	_ = task.Run()
+}
+
+// reclaim 定时回收空闲的mailbox
+func (e *orderedExecutor) reclaim() {
+	interval := e.opts.IdleTimeout / 2
+	if interval <= 0 {
+		interval = e.opts.IdleTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C:
+			e.mux.Lock()
+			for key, m := range e.boxes {
+				if !m.scheduled && m.tasks.Empty() && now.Sub(m.active) >= e.opts.IdleTimeout {
+					delete(e.boxes, key)
+					gMailboxes.Dec()
+				}
+			}
+			e.mux.Unlock()
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ordered
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+func TestOrder(t *testing.T) {
+	ex := New(WithWorkers(4), WithThroughput(2))
+	const keys, num = 8, 200
+
+	var mux sync.Mutex
+	results := make([][]int, keys)
+	for i := 0; i < num; i++ {
+		for k := 0; k < keys; k++ {
+			i, k := i, k
+			if err := ex.Post(NewTask(k, func() error {
+				mux.Lock()
+				results[k] = append(results[k], i)
+				mux.Unlock()
+				return nil
+			})); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	_ = ex.Close()
+
+	for k, res := range results {
+		if len(res) != num {
+			t.Fatalf("key %d lost tasks, %d", k, len(res))
+		}
+		for i, v := range res {
+			if v != i {
+				t.Fatalf("key %d out of order at %d, %d", k, i, v)
+			}
+		}
+	}
+}
+
+func TestHotKey(t *testing.T) {
+	ex := New(WithWorkers(2), WithThroughput(1))
+	defer ex.Close()
+
+	// 热点key的任务执行缓慢,其他key不受影响
+	ch := make(chan struct{})
+	defer close(ch)
+	for i := 0; i < 10; i++ {
+		_ = ex.Post(NewTask("hot", func() error {
+			<-ch
+			return nil
+		}))
+	}
+
+	done := make(chan struct{})
+	_ = ex.Post(NewTask("cold", func() error {
+		close(done)
+		return nil
+	}))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cold key blocked by hot key")
+	}
+
+	stats := ex.HotKeys(1)
+	if len(stats) != 1 || stats[0].Key != "hot" || stats[0].Total != 10 || stats[0].Pending != 9 {
+		t.Fatalf("bad hot keys, %+v", stats)
+	}
+}
+
+func TestMailboxFull(t *testing.T) {
+	ex := New(WithWorkers(1), WithMailboxSize(2))
+	ch := make(chan struct{})
+	started := make(chan struct{})
+	_ = ex.Post(NewTask(1, func() error {
+		close(started)
+		<-ch
+		return nil
+	}))
+	<-started
+	noop := NewTask(1, func() error { return nil })
+	if ex.Post(noop) != nil || ex.Post(noop) != nil {
+		t.Fatal("mailbox should have room")
+	}
+	if err := ex.Post(noop); err != ErrMailboxFull {
+		t.Fatalf("expect ErrMailboxFull, %v", err)
+	}
+	// 其他key不受影响
+	if err := ex.Post(NewTask(2, func() error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+	_ = ex.Close()
+	if err := ex.Post(noop); err != ErrClosed {
+		t.Fatalf("expect ErrClosed, %v", err)
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	ex := New(WithWorkers(1), WithIdleTimeout(20*time.Millisecond))
+	defer ex.Close()
+	_ = ex.Post(NewTask("idle", func() error { return nil }))
+	if len(ex.HotKeys(0)) != 1 {
+		t.Fatal("mailbox should exist")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if stats := ex.HotKeys(0); len(stats) != 0 {
+		t.Fatalf("idle mailbox should be reclaimed, %+v", stats)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	ex := New(WithWorkers(1))
+	var ran int32
+	_ = ex.Post(NewTask(1, func() error {
+		panic("boom")
+	}))
+	_ = ex.Post(NewTask(1, func() error {
+		atomic.StoreInt32(&ran, 1)
+		return nil
+	}))
+	// 未实现Keyed接口的任务同样可以执行
+	_ = ex.Post(netx.RunFunc(func() error {
+		atomic.AddInt32(&ran, 1)
+		return nil
+	}))
+	_ = ex.Close()
+	if ran != 2 {
+		t.Fatalf("tasks should run after panic, %d", ran)
+	}
+}
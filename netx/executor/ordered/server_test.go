@@ -0,0 +1,79 @@
+package ordered_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/executor/ordered"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/transport/gpc"
+)
+
+// TestServerOrder 同一连接上pipeline的请求按发送顺序执行
+func TestServerOrder(t *testing.T) {
+	ex := ordered.New(ordered.WithWorkers(4))
+	svr := server.New(
+		server.WithAddr("127.0.0.1:0"),
+		server.WithTranFactory(gpc.New),
+		server.WithExec(ex),
+	)
+	s := svr.(interface {
+		Start() error
+		Stop() error
+		Addr() net.Addr
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	const count = 50
+	var mux sync.Mutex
+	var seqs []int
+	done := make(chan struct{})
+	svr.GET("/seq", func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+		seq, _ := strconv.Atoi(netx.GetHeader(req.Header(), "X-Seq"))
+		mux.Lock()
+		seqs = append(seqs, seq)
+		if len(seqs) == count {
+			close(done)
+		}
+		mux.Unlock()
+		return nil, nil
+	})
+
+	c, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var data []byte
+	for i := 0; i < count; i++ {
+		data = append(data, fmt.Sprintf("GET /seq HTTP/1.1\r\nHost: test\r\nX-Seq: %d\r\n\r\n", i)...)
+	}
+	if _, err := c.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait requests timeout")
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	for i, seq := range seqs {
+		if seq != i {
+			t.Fatalf("bad order, %v", seqs)
+		}
+	}
+}
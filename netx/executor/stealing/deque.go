@@ -0,0 +1,96 @@
+package stealing
+
+import (
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// item 待执行任务,记录入队时间用于计算排队时长
+type item struct {
+	task netx.Runnable
+	time time.Time
+}
+
+// deque 固定容量的环形双端队列,非线程安全
+type deque struct {
+	items []item
+	head  int
+	size  int
+}
+
+func newDeque(n int) deque {
+	return deque{items: make([]item, n)}
+}
+
+func (d *deque) Len() int {
+	return d.size
+}
+
+func (d *deque) Empty() bool {
+	return d.size == 0
+}
+
+func (d *deque) Full() bool {
+	return d.size == len(d.items)
+}
+
+// Free 剩余容量
+func (d *deque) Free() int {
+	return len(d.items) - d.size
+}
+
+// PushBack 末尾追加,队列已满时返回false
+func (d *deque) PushBack(it item) bool {
+	if d.Full() {
+		return false
+	}
+
+	d.items[(d.head+d.size)%len(d.items)] = it
+	d.size++
+	return true
+}
+
+// PopFront 弹出最早的任务
+func (d *deque) PopFront() (item, bool) {
+	if d.size == 0 {
+		return item{}, false
+	}
+
+	it := d.items[d.head]
+	d.items[d.head] = item{}
+	d.head = (d.head + 1) % len(d.items)
+	d.size--
+	return it, true
+}
+
+// PushFront 头部插入,队列已满时返回false
+func (d *deque) PushFront(it item) bool {
+	if d.Full() {
+		return false
+	}
+
+	d.head = (d.head + len(d.items) - 1) % len(d.items)
+	d.items[d.head] = it
+	d.size++
+	return true
+}
+
+// MoveTo 把最早的n个任务按原顺序移动到dst头部,返回实际移动的数量
+func (d *deque) MoveTo(dst *deque, n int) int {
+	if n > d.size {
+		n = d.size
+	}
+	if free := dst.Free(); n > free {
+		n = free
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		idx := (d.head + i) % len(d.items)
+		dst.PushFront(d.items[idx])
+		d.items[idx] = item{}
+	}
+	d.head = (d.head + n) % len(d.items)
+	d.size -= n
+	return n
+}
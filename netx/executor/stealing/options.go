@@ -0,0 +1,86 @@
+package stealing
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Policy 全局队列已满时的处理策略
+type Policy uint8
+
+const (
+	PolicyBlock      Policy = iota // 阻塞等待队列空闲,不能在任务中Post,否则可能死锁
+	PolicyDropOldest               // 丢弃全局队列中最早的任务
+	PolicyReject                   // 返回ErrQueueFull
+)
+
+// DropHandler 任务被丢弃时回调,err为ErrExpired或ErrDropped
+type DropHandler func(task netx.Runnable, err error)
+
+// Options 可选配置参数
+type Options struct {
+	Workers   int           // 协程数,默认runtime.NumCPU()
+	LocalSize int           // 每个协程本地队列大小,默认256
+	QueueSize int           // 全局队列大小,默认1024
+	Policy    Policy        // 全局队列已满时的处理策略,默认PolicyBlock
+	MaxWait   time.Duration // 任务最大排队时间,超过后丢弃不再执行,0表示不限制
+	OnDrop    DropHandler   // 任务被丢弃时回调,默认调用netx.Dropper
+}
+
+type Option func(o *Options)
+
+func (o *Options) init() {
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.LocalSize <= 0 {
+		o.LocalSize = 256
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1024
+	}
+}
+
+// WithWorkers 设置协程数
+func WithWorkers(n int) Option {
+	return func(o *Options) {
+		o.Workers = n
+	}
+}
+
+// WithLocalSize 设置每个协程本地队列大小
+func WithLocalSize(n int) Option {
+	return func(o *Options) {
+		o.LocalSize = n
+	}
+}
+
+// WithQueueSize 设置全局队列大小
+func WithQueueSize(n int) Option {
+	return func(o *Options) {
+		o.QueueSize = n
+	}
+}
+
+// WithPolicy 设置全局队列已满时的处理策略
+func WithPolicy(p Policy) Option {
+	return func(o *Options) {
+		o.Policy = p
+	}
+}
+
+// WithMaxWait 设置任务最大排队时间
+func WithMaxWait(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxWait = d
+	}
+}
+
+// WithOnDrop 设置任务被丢弃时回调,设置后不再调用任务的netx.Dropper,需要自行通知调用方
+func WithOnDrop(fn DropHandler) Option {
+	return func(o *Options) {
+		o.OnDrop = fn
+	}
+}
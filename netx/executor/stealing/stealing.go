@@ -0,0 +1,342 @@
+// Package stealing 基于任务窃取的有界协程池
+//	1:每个协程拥有固定大小的本地队列,Post轮询投递到本地队列,已满时投递到有界的全局队列
+//	2:协程优先执行本地队列,其次全局队列,均为空时从其他协程窃取一半最早的任务
+//	3:全局队列已满时按Policy阻塞,丢弃最早的任务或者返回ErrQueueFull
+//	4:排队超过MaxWait的任务在执行前丢弃,任务panic不会影响协程
+//	5:丢弃任务时回调OnDrop,未设置时任务实现netx.Dropper则调用Drop,processor中的请求会返回503
+//	stealing.New(stealing.WithWorkers(8), stealing.WithPolicy(stealing.PolicyReject), stealing.WithMaxWait(time.Second))
+package stealing
+
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/netx"
+)
+
+// some error
+var (
+	ErrQueueFull = errors.New("executor queue full")
+	ErrClosed    = errors.New("executor closed")
+	ErrExpired   = errors.New("executor task expired")
+	ErrDropped   = errors.New("executor task dropped")
+)
+
+// drop reason
+const (
+	reasonRejected = "rejected"
+	reasonOldest   = "oldest"
+	reasonExpired  = "expired"
+)
+
+var (
+	gDepth = metrics.NewGauge(&metrics.GaugeOpts{
+		Namespace: "nova",
+		Subsystem: "executor",
+		Name:      "queue_depth",
+		Help:      "tasks waiting in stealing executor queues",
+	})
+	gWait = metrics.NewHistogram(&metrics.HistogramOpts{
+		Namespace: "nova",
+		Subsystem: "executor",
+		Name:      "wait_seconds",
+		Help:      "time tasks spent waiting in queue",
+	})
+	gRun = metrics.NewHistogram(&metrics.HistogramOpts{
+		Namespace: "nova",
+		Subsystem: "executor",
+		Name:      "run_seconds",
+		Help:      "time tasks spent running",
+	})
+	gDropped = metrics.NewCounterSet(&metrics.CounterOpts{
+		Namespace: "nova",
+		Subsystem: "executor",
+		Name:      "dropped",
+		Help:      "dropped tasks by reason",
+	}, []string{"reason"})
+	gPanics = metrics.NewCounter(&metrics.CounterOpts{
+		Namespace: "nova",
+		Subsystem: "executor",
+		Name:      "panics",
+		Help:      "tasks panicked while running",
+	})
+)
+
+// New 创建任务窃取协程池
+func New(opts ...Option) netx.Executor {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+	o.init()
+
+	e := &stealingExecutor{opts: o, global: newDeque(o.QueueSize)}
+	e.notEmpty = sync.NewCond(&e.mux)
+	e.notFull = sync.NewCond(&e.mux)
+	e.workers = make([]*worker, o.Workers)
+	for i := range e.workers {
+		e.workers[i] = &worker{id: i, local: newDeque(o.LocalSize)}
+	}
+	e.wg.Add(len(e.workers))
+	for _, w := range e.workers {
+		go e.loop(w)
+	}
+
+	return e
+}
+
+// worker 执行协程及其本地队列
+type worker struct {
+	id    int
+	mux   sync.Mutex // 用于保护local
+	local deque      // 本地队列
+}
+
+type stealingExecutor struct {
+	opts     *Options       //
+	workers  []*worker      //
+	next     uint32         // 轮询投递的协程索引
+	idle     int32          // 休眠中的协程数
+	closed   int32          // 不再接收新任务
+	mux      sync.Mutex     // 用于保护global,quit
+	notEmpty *sync.Cond     // 用于唤醒休眠的协程
+	notFull  *sync.Cond     // 用于唤醒PolicyBlock时阻塞的Post
+	global   deque          // 全局队列
+	quit     bool           // 已关闭且不会再有新任务,队列为空时协程退出
+	wg       sync.WaitGroup // 用于等待所有协程退出
+}
+
+func (e *stealingExecutor) Name() string {
+	return "stealing"
+}
+
+// Close 不再接收新任务,等待已投递的任务执行完成
+func (e *stealingExecutor) Close() error {
+	if !atomic.CompareAndSwapInt32(&e.closed, 0, 1) {
+		return nil
+	}
+
+	// 等待已经通过closed检查的Post完成投递
+	for _, w := range e.workers {
+		w.mux.Lock()
+		w.mux.Unlock()
+	}
+
+	e.mux.Lock()
+	e.quit = true
+	e.notEmpty.Broadcast()
+	e.notFull.Broadcast()
+	e.mux.Unlock()
+
+	e.wg.Wait()
+	return nil
+}
+
+func (e *stealingExecutor) Post(task netx.Runnable) error {
+	it := item{task: task, time: time.Now()}
+	n := atomic.AddUint32(&e.next, 1)
+	w := e.workers[n%uint32(len(e.workers))]
+
+	w.mux.Lock()
+	if atomic.LoadInt32(&e.closed) != 0 {
+		w.mux.Unlock()
+		return ErrClosed
+	}
+	ok := w.local.PushBack(it)
+	w.mux.Unlock()
+
+	if ok {
+		gDepth.Inc()
+		e.wakeup()
+		return nil
+	}
+
+	return e.postGlobal(it)
+}
+
+// postGlobal 本地队列已满时投递到全局队列
+func (e *stealingExecutor) postGlobal(it item) error {
+	var dropped item
+
+	e.mux.Lock()
+	for e.global.Full() && atomic.LoadInt32(&e.closed) == 0 {
+		if e.opts.Policy == PolicyBlock {
+			e.notFull.Wait()
+			continue
+		}
+		if e.opts.Policy == PolicyDropOldest {
+			dropped, _ = e.global.PopFront()
+			break
+		}
+
+		e.mux.Unlock()
+		gDropped.Values(reasonRejected).Inc()
+		return ErrQueueFull
+	}
+
+	if atomic.LoadInt32(&e.closed) != 0 {
+		e.mux.Unlock()
+		return ErrClosed
+	}
+
+	e.global.PushBack(it)
+	gDepth.Inc()
+	if atomic.LoadInt32(&e.idle) > 0 {
+		e.notEmpty.Signal()
+	}
+	e.mux.Unlock()
+
+	if dropped.task != nil {
+		e.drop(dropped, reasonOldest, ErrDropped)
+	}
+
+	return nil
+}
+
+// wakeup 存在休眠的协程时唤醒一个
+func (e *stealingExecutor) wakeup() {
+	if atomic.LoadInt32(&e.idle) > 0 {
+		e.mux.Lock()
+		e.notEmpty.Signal()
+		e.mux.Unlock()
+	}
+}
+
+func (e *stealingExecutor) loop(w *worker) {
+	defer e.wg.Done()
+	for {
+		it, ok := e.take(w)
+		if !ok {
+			break
+		}
+
+		e.exec(it)
+	}
+}
+
+// take 依次从本地队列,全局队列,其他协程获取任务,均为空时休眠,返回false表示退出
+func (e *stealingExecutor) take(w *worker) (item, bool) {
+	for {
+		w.mux.Lock()
+		it, ok := w.local.PopFront()
+		w.mux.Unlock()
+		if ok {
+			return it, true
+		}
+
+		e.mux.Lock()
+		it, ok = e.global.PopFront()
+		if ok {
+			e.notFull.Signal()
+			e.mux.Unlock()
+			return it, true
+		}
+		e.mux.Unlock()
+
+		if it, ok = e.steal(w); ok {
+			return it, true
+		}
+
+		// 先增加idle再检查队列,保证Post能够感知到需要唤醒
+		e.mux.Lock()
+		atomic.AddInt32(&e.idle, 1)
+		for e.global.Empty() && !e.hasLocal() {
+			if e.quit {
+				atomic.AddInt32(&e.idle, -1)
+				e.mux.Unlock()
+				return item{}, false
+			}
+			e.notEmpty.Wait()
+		}
+		atomic.AddInt32(&e.idle, -1)
+		e.mux.Unlock()
+	}
+}
+
+// hasLocal 是否有协程的本地队列不为空
+func (e *stealingExecutor) hasLocal() bool {
+	for _, w := range e.workers {
+		w.mux.Lock()
+		empty := w.local.Empty()
+		w.mux.Unlock()
+		if !empty {
+			return true
+		}
+	}
+
+	return false
+}
+
+// steal 从其他协程窃取一半最早的任务,返回第一个,其余放入本地队列
+func (e *stealingExecutor) steal(w *worker) (item, bool) {
+	num := len(e.workers)
+	for i := 1; i < num; i++ {
+		v := e.workers[(w.id+i)%num]
+		// 按id顺序加锁,避免相互窃取时死锁
+		first, second := w, v
+		if v.id < w.id {
+			first, second = v, w
+		}
+
+		first.mux.Lock()
+		second.mux.Lock()
+		it, ok := v.local.PopFront()
+		if ok {
+			v.local.MoveTo(&w.local, v.local.Len()/2)
+		}
+		second.mux.Unlock()
+		first.mux.Unlock()
+
+		if ok {
+			return it, true
+		}
+	}
+
+	return item{}, false
+}
+
+// exec 执行任务,排队超时的任务直接丢弃
+func (e *stealingExecutor) exec(it item) {
+	gDepth.Dec()
+	wait := time.Since(it.time)
+	if e.opts.MaxWait > 0 && wait > e.opts.MaxWait {
+		e.drop(it, reasonExpired, ErrExpired)
+		return
+	}
+	gWait.Observe(wait.Seconds())
+
+	start := time.Now()
+	defer func() {
+		if x := recover(); x != nil {
+			gPanics.Inc()
+			log.Printf("executor task panic, err=%+v, stack=%s", x, debug.Stack())
+		}
+		gRun.Observe(time.Since(start).Seconds())
+	}()
+
+	_ = it.task.Run()
+}
+
+// drop 丢弃任务并回调OnDrop,未设置OnDrop时,任务实现了netx.Dropper则调用Drop通知调用方
+func (e *stealingExecutor) drop(it item, reason string, err error) {
+	if reason == reasonOldest {
+		gDepth.Dec()
+	}
+	gDropped.Values(reason).Inc()
+
+	defer func() {
+		if x := recover(); x != nil {
+			log.Printf("executor drop handler panic, err=%+v", x)
+		}
+	}()
+	if e.opts.OnDrop != nil {
+		e.opts.OnDrop(it.task, err)
+	} else if d, ok := it.task.(netx.Dropper); ok {
+		_ = d.Drop(err)
+	}
+}
@@ -0,0 +1,240 @@
+package stealing
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// blocker 阻塞所有协程直到release
+type blocker struct {
+	started sync.WaitGroup
+	ch      chan struct{}
+}
+
+func block(t *testing.T, ex netx.Executor, n int) *blocker {
+	t.Helper()
+	b := &blocker{ch: make(chan struct{})}
+	b.started.Add(n)
+	for i := 0; i < n; i++ {
+		if err := ex.Post(netx.RunFunc(func() error {
+			b.started.Done()
+			<-b.ch
+			return nil
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.started.Wait()
+	return b
+}
+
+func (b *blocker) release() {
+	close(b.ch)
+}
+
+func TestRunAll(t *testing.T) {
+	ex := New(WithWorkers(4), WithLocalSize(8), WithQueueSize(16), WithPolicy(PolicyBlock))
+	var num int32
+	for i := 0; i < 1000; i++ {
+		if err := ex.Post(netx.RunFunc(func() error {
+			atomic.AddInt32(&num, 1)
+			return nil
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = ex.Close()
+	if num != 1000 {
+		t.Fatalf("bad num, %d", num)
+	}
+	if err := ex.Post(netx.RunFunc(func() error { return nil })); err != ErrClosed {
+		t.Fatalf("expect ErrClosed, %v", err)
+	}
+}
+
+func TestSteal(t *testing.T) {
+	ex := New(WithWorkers(2))
+	defer ex.Close()
+
+	// 阻塞其中一个协程,投递到其本地队列的任务由另一个协程窃取执行
+	ch := make(chan struct{})
+	_ = ex.Post(netx.RunFunc(func() error {
+		<-ch
+		return nil
+	}))
+	defer close(ch)
+
+	var wg sync.WaitGroup
+	wg.Add(10)
+	for i := 0; i < 10; i++ {
+		_ = ex.Post(netx.RunFunc(func() error {
+			wg.Done()
+			return nil
+		}))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("tasks not stolen")
+	}
+}
+
+func TestReject(t *testing.T) {
+	ex := New(WithWorkers(1), WithLocalSize(1), WithQueueSize(1), WithPolicy(PolicyReject))
+	b := block(t, ex, 1)
+	noop := netx.RunFunc(func() error { return nil })
+	if ex.Post(noop) != nil || ex.Post(noop) != nil {
+		t.Fatal("queue should have room")
+	}
+	if err := ex.Post(noop); err != ErrQueueFull {
+		t.Fatalf("expect ErrQueueFull, %v", err)
+	}
+	b.release()
+	_ = ex.Close()
+}
+
+// record 记录任务执行顺序
+type record struct {
+	id   int
+	mux  *sync.Mutex
+	list *[]int
+}
+
+func (r *record) Run() error {
+	r.mux.Lock()
+	*r.list = append(*r.list, r.id)
+	r.mux.Unlock()
+	return nil
+}
+
+func TestDropOldest(t *testing.T) {
+	var mux sync.Mutex
+	var ran, dropped []int
+	ex := New(WithWorkers(1), WithLocalSize(1), WithQueueSize(2), WithPolicy(PolicyDropOldest),
+		WithOnDrop(func(task netx.Runnable, err error) {
+			if err == ErrDropped {
+				r := task.(*record)
+				mux.Lock()
+				dropped = append(dropped, r.id)
+				mux.Unlock()
+			}
+		}))
+	b := block(t, ex, 1)
+	for i := 0; i < 5; i++ {
+		if err := ex.Post(&record{id: i, mux: &mux, list: &ran}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.release()
+	_ = ex.Close()
+
+	// 0在本地队列,全局队列容量为2,投递3和4时丢弃1和2
+	if fmt.Sprint(ran) != "[0 3 4]" || fmt.Sprint(dropped) != "[1 2]" {
+		t.Fatalf("bad result, ran=%v dropped=%v", ran, dropped)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	ex := New(WithWorkers(1), WithLocalSize(1), WithQueueSize(1), WithPolicy(PolicyBlock))
+	b := block(t, ex, 1)
+	noop := netx.RunFunc(func() error { return nil })
+	_ = ex.Post(noop)
+	_ = ex.Post(noop)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ex.Post(noop)
+	}()
+	select {
+	case <-done:
+		t.Fatal("post should block when queue full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	b.release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	_ = ex.Close()
+}
+
+func TestMaxWait(t *testing.T) {
+	var expired, ran int32
+	ex := New(WithWorkers(1), WithMaxWait(20*time.Millisecond), WithOnDrop(func(task netx.Runnable, err error) {
+		if err == ErrExpired {
+			atomic.AddInt32(&expired, 1)
+		}
+	}))
+	b := block(t, ex, 1)
+	for i := 0; i < 3; i++ {
+		_ = ex.Post(netx.RunFunc(func() error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		}))
+	}
+	time.Sleep(50 * time.Millisecond)
+	b.release()
+	_ = ex.Close()
+	if expired != 3 || ran != 0 {
+		t.Fatalf("expired tasks should be dropped, %d %d", expired, ran)
+	}
+}
+
+// dropTask 实现netx.Dropper
+type dropTask struct {
+	ran     int32
+	dropped int32
+}
+
+func (t *dropTask) Run() error {
+	atomic.AddInt32(&t.ran, 1)
+	return nil
+}
+
+func (t *dropTask) Drop(err error) error {
+	if err == ErrExpired {
+		atomic.AddInt32(&t.dropped, 1)
+	}
+	return nil
+}
+
+// TestDropper 未设置OnDrop时调用任务的Drop
+func TestDropper(t *testing.T) {
+	ex := New(WithWorkers(1), WithMaxWait(20*time.Millisecond))
+	b := block(t, ex, 1)
+	task := &dropTask{}
+	_ = ex.Post(task)
+	time.Sleep(50 * time.Millisecond)
+	b.release()
+	_ = ex.Close()
+	if task.dropped != 1 || task.ran != 0 {
+		t.Fatalf("expired task should be dropped, %d %d", task.dropped, task.ran)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	ex := New(WithWorkers(1))
+	_ = ex.Post(netx.RunFunc(func() error {
+		panic("boom")
+	}))
+	var ran int32
+	_ = ex.Post(netx.RunFunc(func() error {
+		atomic.StoreInt32(&ran, 1)
+		return nil
+	}))
+	_ = ex.Close()
+	if ran != 1 {
+		t.Fatal("worker should survive panic")
+	}
+}
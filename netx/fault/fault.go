@@ -0,0 +1,190 @@
+// Package fault 故障注入,用于演练超时,重试,熔断等容错逻辑
+//	同时提供server和client中间件,支持延迟,中断,断连,篡改应答四种故障
+//	规则按服务名,路径,header匹配,通过feature.Client在运行时开启和调整比例,默认全部关闭
+//	server: svr.Use(fault.Server(fault.WithRules(rules...)))
+//	client: client.New(client.WithMiddlewares(fault.Client(fault.WithRules(rules...))))
+package fault
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/foredata/nova/feature"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+var (
+	ErrReset = errors.New("fault: connection reset by peer")
+)
+
+const (
+	sideServer = "server"
+	sideClient = "client"
+)
+
+// Options 故障注入配置
+type Options struct {
+	Feature feature.Client // 规则开关,默认使用feature包全局client
+	Rules   []*Rule        // 按顺序匹配,命中第一个开启的规则后不再继续
+}
+
+type Option func(o *Options)
+
+// WithFeature 设置规则开关client
+func WithFeature(c feature.Client) Option {
+	return func(o *Options) {
+		o.Feature = c
+	}
+}
+
+// WithRules 添加规则
+func WithRules(rules ...*Rule) Option {
+	return func(o *Options) {
+		o.Rules = append(o.Rules, rules...)
+	}
+}
+
+// Server 创建服务端故障注入中间件,Reset会接管并直接关闭请求所在连接,不再发送应答
+func Server(opts ...Option) netx.Middleware {
+	return newInjector(sideServer, opts...).middleware
+}
+
+// Client 创建客户端故障注入中间件,Reset返回ErrReset,不会真正调用下游
+func Client(opts ...Option) netx.Middleware {
+	return newInjector(sideClient, opts...).middleware
+}
+
+func newInjector(side string, opts ...Option) *injector {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &injector{side: side, opts: o}
+}
+
+type injector struct {
+	side string
+	opts *Options
+}
+
+func (in *injector) feature() feature.Client {
+	if in.opts.Feature != nil {
+		return in.opts.Feature
+	}
+
+	return gFeature
+}
+
+// find 返回第一个命中且开启的规则
+func (in *injector) find(ctx context.Context, req netx.Request) *Rule {
+	var attrs feature.Attributes
+	for _, r := range in.opts.Rules {
+		if r.Action == ActionNone || !r.match(req) {
+			continue
+		}
+
+		if attrs == nil {
+			attrs = feature.Attributes{
+				"side":    in.side,
+				"service": req.Service(),
+				"uri":     req.URI(),
+				"method":  req.Method().String(),
+			}
+		}
+
+		if r.enabled(ctx, in.feature(), attrs) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (in *injector) middleware(next netx.Endpoint) netx.Endpoint {
+	return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		rule := in.find(ctx, req)
+		if rule == nil {
+			return next(ctx, req)
+		}
+
+		switch rule.Action {
+		case ActionDelay:
+			timer := time.NewTimer(rule.delay())
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+			return next(ctx, req)
+		case ActionAbort:
+			status := rule.Status
+			if status == 0 {
+				status = http.StatusServiceUnavailable
+			}
+			return nil, netx.NewError(status, "", "fault: injected abort by %s", rule.Name)
+		case ActionReset:
+			if in.side == sideServer {
+				// 接管连接,server不再向已关闭的连接发送应答
+				if conn := server.Hijack(ctx); conn != nil {
+					_ = conn.Close()
+				}
+			}
+			return nil, ErrReset
+		case ActionCorrupt:
+			rsp, err := next(ctx, req)
+			if err == nil && rsp != nil {
+				corrupt(rsp)
+			}
+			return rsp, err
+		default:
+			return next(ctx, req)
+		}
+	}
+}
+
+// corrupt 随机翻转应答body中的部分字节,仅支持Buffer类型body
+func corrupt(rsp netx.Response) {
+	bd := rsp.Body()
+	if bd == nil {
+		return
+	}
+
+	buf, err := bd.Buffer()
+	if err != nil || buf == nil || buf.Empty() {
+		return
+	}
+
+	data := append([]byte(nil), buf.Bytes()...)
+	n := len(data)/16 + 1
+	for i := 0; i < n; i++ {
+		idx := rand.Intn(len(data))
+		data[idx] ^= 0xFF
+	}
+
+	out := bytex.NewBuffer()
+	_ = out.Append(data)
+	_, _ = out.Seek(0, io.SeekStart)
+	rsp.SetBody(body.NewBufferBody(out))
+}
+
+// gFeature 转发到feature包全局client,feature.SetDefault后自动生效
+var gFeature feature.Client = defaultFeature{}
+
+type defaultFeature struct{}
+
+func (defaultFeature) IsToggled(ctx context.Context, key string, attributes feature.Attributes, defaultVal bool) (bool, error) {
+	return feature.IsToggled(ctx, key, attributes, defaultVal)
+}
+
+func (defaultFeature) Evaluate(ctx context.Context, key string, attributes feature.Attributes, defaultVal string) (string, error) {
+	return feature.Evaluate(ctx, key, attributes, defaultVal)
+}
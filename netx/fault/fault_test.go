@@ -0,0 +1,126 @@
+package fault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/feature"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+type flags map[string]string
+
+func (f flags) IsToggled(ctx context.Context, key string, attributes feature.Attributes, defaultVal bool) (bool, error) {
+	if v, ok := f[key]; ok {
+		return v == feature.ToggleOn, nil
+	}
+	return defaultVal, nil
+}
+
+func (f flags) Evaluate(ctx context.Context, key string, attributes feature.Attributes, defaultVal string) (string, error) {
+	if v, ok := f[key]; ok {
+		return v, nil
+	}
+	return defaultVal, nil
+}
+
+func echo(ctx context.Context, req netx.Request) (netx.Response, error) {
+	rsp := netx.NewResponse()
+	buf := bytex.NewBuffer()
+	_ = buf.Append("hello world")
+	rsp.SetBody(body.NewBufferBody(buf))
+	return rsp, nil
+}
+
+func newRequest(uri string) netx.Request {
+	req := netx.NewRequest()
+	req.SetService("user")
+	req.SetURI(uri)
+	h := netx.NewHeader()
+	h.Set("X-Chaos", "1")
+	req.SetHeader(h)
+	return req
+}
+
+func TestFault(t *testing.T) {
+	f := flags{}
+	rules := []*Rule{
+		{Name: "abort", Action: ActionAbort, Path: "/users/*", Status: 500, Headers: map[string]string{"X-Chaos": "1"}},
+		{Name: "delay", Action: ActionDelay, Service: "user", Delay: 20 * time.Millisecond},
+		{Name: "reset", Action: ActionReset, Service: "user"},
+		{Name: "corrupt", Action: ActionCorrupt},
+	}
+	ep := Client(WithFeature(f), WithRules(rules...))(echo)
+
+	// 默认关闭
+	if _, err := ep(context.Background(), newRequest("/users/1")); err != nil {
+		t.Fatal(err)
+	}
+
+	f["fault.abort"] = feature.ToggleOn
+	_, err := ep(context.Background(), newRequest("/users/1"))
+	if nerr, ok := err.(netx.Error); !ok || nerr.Code() != 500 {
+		t.Fatalf("expect abort, %v", err)
+	}
+
+	// 比例为0时不注入
+	f["fault.abort.percent"] = "0"
+	if _, err := ep(context.Background(), newRequest("/users/1")); err != nil {
+		t.Fatal(err)
+	}
+	delete(f, "fault.abort")
+
+	f["fault.delay"] = feature.ToggleOn
+	begin := time.Now()
+	if _, err := ep(context.Background(), newRequest("/orders")); err != nil || time.Since(begin) < 20*time.Millisecond {
+		t.Fatalf("expect delay, %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := ep(ctx, newRequest("/orders")); err != context.DeadlineExceeded {
+		t.Fatalf("expect deadline exceeded, %v", err)
+	}
+	delete(f, "fault.delay")
+
+	f["fault.reset"] = feature.ToggleOn
+	if _, err := ep(context.Background(), newRequest("/orders")); err != ErrReset {
+		t.Fatalf("expect reset, %v", err)
+	}
+	delete(f, "fault.reset")
+
+	f["fault.corrupt"] = feature.ToggleOn
+	rsp, err := ep(context.Background(), newRequest("/orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _ := rsp.Body().Buffer()
+	if buf.String() == "hello world" || buf.Len() != len("hello world") {
+		t.Fatalf("expect corrupted body, %q", buf.String())
+	}
+}
+
+// TestPercent 静态比例与动态比例语义一致,nil表示100,0表示不注入
+func TestPercent(t *testing.T) {
+	f := flags{"fault.abort": feature.ToggleOn}
+	for _, tc := range []struct {
+		percent *float64
+		dynamic string
+		abort   bool
+	}{
+		{nil, "", true},
+		{Percent(0), "", false},
+		{Percent(100), "", true},
+		{nil, "0", false},
+		{Percent(0), "100", true},
+	} {
+		f["fault.abort.percent"] = tc.dynamic
+		rule := &Rule{Name: "abort", Action: ActionAbort, Percent: tc.percent}
+		_, err := Client(WithFeature(f), WithRules(rule))(echo)(context.Background(), newRequest("/users/1"))
+		if (err != nil) != tc.abort {
+			t.Fatalf("percent %v dynamic %q, expect abort %v, %v", tc.percent, tc.dynamic, tc.abort, err)
+		}
+	}
+}
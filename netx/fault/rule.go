@@ -0,0 +1,112 @@
+package fault
+
+import (
+	"context"
+	"math/rand"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/foredata/nova/feature"
+	"github.com/foredata/nova/netx"
+)
+
+// Action 故障类型
+type Action uint8
+
+const (
+	ActionNone    Action = iota
+	ActionDelay          // 延迟,固定Delay或[Delay,Delay+Jitter)随机
+	ActionAbort          // 直接返回Status错误,不调用下游
+	ActionReset          // 断开连接,客户端表现为连接被重置
+	ActionCorrupt        // 正常调用,但篡改应答body
+)
+
+// Rule 故障注入规则
+//	规则是否生效由feature flag控制,key为"fault."+Name,默认关闭,可在运行时开启
+//	同时支持通过"fault."+Name+".percent"动态调整注入比例
+type Rule struct {
+	Name    string            // 规则名,用于生成feature key,必填
+	Action  Action            // 故障类型
+	Service string            // 匹配服务名,支持path.Match通配,为空不限制
+	Path    string            // 匹配URI路径,支持path.Match通配,为空不限制
+	Headers map[string]string // 匹配header,全部相等才命中
+	Percent *float64          // 命中后注入比例[0,100],nil表示100,0表示不注入,与动态配置一致
+	Delay   time.Duration     // 延迟时间
+	Jitter  time.Duration     // 随机延迟上限
+	Status  int               // Abort返回的状态码,默认503
+}
+
+// Percent 返回注入比例指针,用于设置Rule.Percent
+func Percent(p float64) *float64 {
+	return &p
+}
+
+// Key 返回规则对应的feature key
+func (r *Rule) Key() string {
+	return "fault." + r.Name
+}
+
+// match 判断请求是否命中规则
+func (r *Rule) match(req netx.Request) bool {
+	if r.Service != "" {
+		if ok, _ := path.Match(r.Service, req.Service()); !ok {
+			return false
+		}
+	}
+
+	if r.Path != "" {
+		p := req.URI()
+		if u := req.URL(); u != nil {
+			p = u.Path
+		}
+		if ok, _ := path.Match(r.Path, p); !ok {
+			return false
+		}
+	}
+
+	header := req.Header()
+	for k, v := range r.Headers {
+		if header.Get(k) != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// enabled 通过feature flag判断规则是否开启,并按比例采样
+func (r *Rule) enabled(ctx context.Context, client feature.Client, attrs feature.Attributes) bool {
+	on, err := client.IsToggled(ctx, r.Key(), attrs, false)
+	if err != nil || !on {
+		return false
+	}
+
+	percent := float64(100)
+	if r.Percent != nil {
+		percent = *r.Percent
+	}
+	if v, err := client.Evaluate(ctx, r.Key()+".percent", attrs, ""); err == nil && v != "" {
+		if p, err := strconv.ParseFloat(v, 64); err == nil {
+			percent = p
+		}
+	}
+
+	if percent <= 0 {
+		return false
+	} else if percent >= 100 {
+		return true
+	}
+
+	return rand.Float64()*100 < percent
+}
+
+// delay 计算延迟时间
+func (r *Rule) delay() time.Duration {
+	d := r.Delay
+	if r.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(r.Jitter)))
+	}
+
+	return d
+}
@@ -80,12 +80,16 @@ func (fc *filterChain) HandleRead(conn Conn, msg interface{}) {
 func (fc *filterChain) HandleWrite(conn Conn, msg interface{}) error {
 	fctx := newFilterCtx(fc.filters, conn, false, doWrite)
 	fctx.SetData(msg)
-	err := fctx.Call()
+	// Call会回收fctx,因此需要先取出结果
+	err := fctx.Next()
+	abort := fctx.IsAbort()
+	data := fctx.Data()
+	fctx.Recycle()
 	if err != nil {
 		fc.HandleError(conn, err)
-	} else if !fctx.IsAbort() {
-		if p, ok := fctx.Data().(WriterTo); ok {
-			return fctx.Conn().Write(p)
+	} else if !abort {
+		if p, ok := data.(WriterTo); ok {
+			return conn.Write(p)
 		}
 	}
 
@@ -0,0 +1,67 @@
+package netx
+
+import (
+	"io"
+	"sync"
+	"testing"
+)
+
+// msgWriter 编码后的消息
+type msgWriter struct {
+	msg int
+}
+
+func (w *msgWriter) WriteTo(io.Writer) (int64, error) { return 0, nil }
+func (w *msgWriter) Close() error                     { return nil }
+
+// writeConn 记录Write的数据
+type writeConn struct {
+	Conn
+	mux     sync.Mutex
+	written map[int]int
+}
+
+func (c *writeConn) Write(p WriterTo) error {
+	c.mux.Lock()
+	c.written[p.(*msgWriter).msg]++
+	c.mux.Unlock()
+	return nil
+}
+
+// encodeFilter 将消息转换为WriterTo
+type encodeFilter struct {
+	BaseFilter
+}
+
+func (f *encodeFilter) Name() string {
+	return "encode"
+}
+
+func (f *encodeFilter) HandleWrite(ctx FilterCtx) error {
+	ctx.SetData(&msgWriter{msg: ctx.Data().(int)})
+	return nil
+}
+
+// TestHandleWrite 并发写入时,FilterCtx回收后可能被其他协程复用,不能再读取其中的结果
+func TestHandleWrite(t *testing.T) {
+	fc := NewFilterChain()
+	fc.AddLast(&encodeFilter{})
+	conn := &writeConn{written: make(map[int]int)}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := fc.HandleWrite(conn, i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 100; i++ {
+		if conn.written[i] != 1 {
+			t.Fatalf("msg %d written %d times", i, conn.written[i])
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package gateway http网关,将http1请求按规则转发到rpc或theader协议的后端服务
+//	1: 规则可以通过json声明(ParseRules),也可以通过路由注解生成(FromRoute)
+//	2: 指定消息类型时,支持json与protobuf之间互相转码,path参数与query会绑定到请求消息同名字段
+//	3: http header与context中的metadata会透传到后端,后端应答header会透传回http客户端
+//	4: 规则注册为普通server路由,因此可以直接使用auth,ratelimit,tracing等中间件及路由Metadata
+//	5: 支持运行时通过Reload原子替换全部规则,需要server实现netx.RouteUpdater
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/foredata/nova/debug/tracing"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/discovery/static"
+	"github.com/foredata/nova/netx/protocol"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/protocol/theader"
+)
+
+// Gateway http网关
+type Gateway struct {
+	opts    *Options
+	server  netx.Server
+	mux     sync.Mutex
+	clients map[string]netx.Client
+	routes  []*netx.Route // 已注册的路由
+}
+
+// New 创建网关,规则会注册到srv中
+func New(srv netx.Server, opts ...Option) *Gateway {
+	o := newOptions(opts...)
+	g := &Gateway{opts: o, server: srv, clients: make(map[string]netx.Client)}
+	for k, v := range o.Clients {
+		g.clients[k] = v
+	}
+
+	return g
+}
+
+// Add 添加规则,全部规则编译成功后才会注册
+func (g *Gateway) Add(rules ...*Rule) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	routes, err := g.build(rules)
+	if err != nil {
+		return err
+	}
+
+	if err := g.update(nil, routes); err != nil {
+		return err
+	}
+
+	g.routes = append(g.routes, routes...)
+	return nil
+}
+
+// AddRoutes 通过路由注解添加规则,不含gateway.service注解的路由会被忽略
+func (g *Gateway) AddRoutes(routes ...*netx.Route) error {
+	rules := make([]*Rule, 0, len(routes))
+	for _, r := range routes {
+		if rule, ok := FromRoute(r); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return g.Add(rules...)
+}
+
+// Reload 使用新规则原子替换已注册的全部规则,失败时保持原有规则不变
+func (g *Gateway) Reload(rules []*Rule) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	routes, err := g.build(rules)
+	if err != nil {
+		return err
+	}
+
+	if err := g.update(g.routes, routes); err != nil {
+		return err
+	}
+
+	g.routes = routes
+	return nil
+}
+
+// update 原子替换路由,server不支持运行时更新时返回ErrNotSupport
+func (g *Gateway) update(remove, add []*netx.Route) error {
+	u, ok := g.server.(netx.RouteUpdater)
+	if !ok {
+		return netx.ErrNotSupport
+	}
+
+	return u.Update(remove, add)
+}
+
+// Routes 返回已注册的路由
+func (g *Gateway) Routes() []*netx.Route {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	return append([]*netx.Route(nil), g.routes...)
+}
+
+// Endpoint 将规则转换为Endpoint,可用于自定义注册方式
+func (g *Gateway) Endpoint(rule *Rule) (netx.Endpoint, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	b, err := g.compile(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.serve, nil
+}
+
+func (g *Gateway) build(rules []*Rule) ([]*netx.Route, error) {
+	routes := make([]*netx.Route, 0, len(rules))
+	for _, rule := range rules {
+		b, err := g.compile(rule)
+		if err != nil {
+			return nil, err
+		}
+
+		mws := make([]netx.Middleware, 0, len(g.opts.Middlewares)+len(rule.Middlewares))
+		mws = append(mws, g.opts.Middlewares...)
+		mws = append(mws, rule.Middlewares...)
+		routes = append(routes, &netx.Route{
+			Name:        fmt.Sprintf("gateway:%s %s", b.method.String(), rule.Path),
+			Method:      b.method,
+			Path:        rule.Path,
+			Metadata:    rule.Metadata,
+			Handler:     netx.Endpoint(b.serve),
+			Middlewares: mws,
+		})
+	}
+
+	return routes, nil
+}
+
+// getClient 获取后端client,需要持有锁
+func (g *Gateway) getClient(name string) (netx.Client, error) {
+	if cli, ok := g.clients[name]; ok {
+		return cli, nil
+	}
+
+	var p netx.Protocol
+	switch name {
+	case ProtocolRPC:
+		p = rpc.New()
+	case ProtocolTHeader:
+		p = theader.New()
+	default:
+		p = protocol.Get(name)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("gateway: not support protocol, %s", name)
+	}
+
+	resolver := g.opts.Resolver
+	if resolver == nil {
+		resolver = static.New()
+	}
+
+	cli := client.New(client.WithProtocol(p), client.WithResolver(resolver))
+	g.clients[name] = cli
+	return cli, nil
+}
+
+func (b *binding) serve(ctx context.Context, req netx.Request) (netx.Response, error) {
+	g := b.gateway
+	rule := b.rule
+	out := netx.NewRequest()
+	out.SetService(rule.Service)
+	out.SetURI(rule.URI)
+	out.SetCmdID(rule.CmdID)
+	if err := b.encodeRequest(req, out); err != nil {
+		return nil, err
+	}
+
+	span, ctx := tracing.StartSpanFromContext(ctx, rule.Service+"/"+rule.URI,
+		tracing.WithTag(tracing.SpanType, tracing.SpanTypeHTTP),
+		tracing.WithTag(tracing.PeerService, rule.Service))
+	defer span.Finish()
+
+	h := g.requestHeader(ctx, req)
+	_ = tracing.Inject(span.Context(), &h)
+	out.SetHeader(h)
+
+	var opts []netx.CallOption
+	if b.timeout > 0 {
+		opts = append(opts, client.WithCallTimeout(b.timeout))
+	}
+
+	rsp, err := b.client.Call(ctx, out, opts...)
+	if err != nil {
+		span.SetTag(tracing.Error, true)
+		span.SetTag(tracing.ErrorMsg, err.Error())
+		return nil, toError(err)
+	}
+
+	code := rsp.StatusCode()
+	span.SetTag(tracing.HTTPCode, code)
+	switch {
+	case code == netx.StatusTimeout:
+		return nil, netx.GatewayTimeout("gateway: %s/%s timeout", rule.Service, rule.URI)
+	case code != 0 && (code < 100 || code > 599):
+		return nil, netx.BadGateway("gateway: invalid status %d, %s", code, rsp.StatusInfo())
+	}
+
+	result := rsp
+	if code == 0 || code < http.StatusMultipleChoices {
+		if result, err = b.decodeResponse(rsp, g.responseCodec(req)); err != nil {
+			return nil, err
+		}
+	}
+
+	result.SetHeader(g.responseHeader(rsp))
+	if code != 0 {
+		result.SetStatus(code, rsp.StatusInfo())
+	}
+
+	return result, nil
+}
+
+// responseCodec 优先使用Accept中可识别的编码,否则使用默认编码
+func (g *Gateway) responseCodec(req netx.Request) netx.CodecType {
+	for _, accept := range strings.Split(req.Header().Get("Accept"), ",") {
+		if idx := strings.IndexByte(accept, ';'); idx != -1 {
+			accept = accept[:idx]
+		}
+		if c := netx.GetCodecType(strings.TrimSpace(accept)); c != netx.CodecTypeUnknown && netx.GetByType(c) != nil {
+			return c
+		}
+	}
+
+	return g.opts.Codec
+}
+
+// toError 将调用错误转换为http错误
+func toError(err error) error {
+	var nerr netx.Error
+	switch {
+	case errors.As(err, &nerr):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return netx.GatewayTimeout("gateway: %s", err.Error())
+	case errors.Is(err, client.ErrNoInstances):
+		return netx.ServiceUnavailable("gateway: %s", err.Error())
+	default:
+		return netx.BadGateway("gateway: %s", err.Error())
+	}
+}
@@ -0,0 +1,169 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server"
+)
+
+type echoRequest struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Limit *int32   `json:"limit,omitempty"`
+}
+
+type echoResponse struct {
+	ID   int64  `json:"id"`
+	Text string `json:"text"`
+}
+
+type mockClient struct {
+	req netx.Request
+	rsp func(req netx.Request) netx.Response
+}
+
+func (c *mockClient) Call(ctx context.Context, req netx.Request, opts ...netx.CallOption) (netx.Response, error) {
+	c.req = req
+	return c.rsp(req), nil
+}
+
+func (c *mockClient) Close() error {
+	return nil
+}
+
+func init() {
+	RegisterType("", &echoRequest{})
+	RegisterType("", &echoResponse{})
+}
+
+func TestGatewayTranscode(t *testing.T) {
+	cli := &mockClient{}
+	in := &echoRequest{}
+	cli.rsp = func(req netx.Request) netx.Response {
+		if err := req.Decode(in); err != nil {
+			t.Fatal(err)
+		}
+		rsp := netx.NewResponse()
+		h := netx.NewHeader()
+		h.Set("X-Backend", "echo")
+		h.Set("Connection", "close")
+		rsp.SetHeader(h)
+		_ = rsp.Encode(netx.CodecTypeJson, &echoResponse{ID: in.ID, Text: in.Name})
+		return rsp
+	}
+
+	g := New(server.New(), WithClient(ProtocolRPC, cli))
+	ep, err := g.Endpoint(&Rule{
+		Method:   "post",
+		Path:     "/echo/:id",
+		Service:  "echo",
+		URI:      "Echo",
+		Codec:    "json",
+		Request:  "gateway.echoRequest",
+		Response: "gateway.echoResponse",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := netx.NewRequest()
+	req.SetMethod(netx.MethodPost)
+	req.SetURI("/echo/42?tags=a&tags=b&limit=10")
+	req.(netx.Packet).Identifier().Params.Reset([]string{"id"}, []string{"42"})
+	h := netx.NewHeader()
+	h.Set("X-Request-Id", "rid")
+	h.Set("Transfer-Encoding", "chunked")
+	req.SetHeader(h)
+	if err := req.Encode(netx.CodecTypeJson, map[string]interface{}{"id": 1, "name": "nova"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rsp, err := ep(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cli.req.Service() != "echo" || cli.req.URI() != "Echo" {
+		t.Fatalf("invalid backend request, %s %s", cli.req.Service(), cli.req.URI())
+	}
+	if cli.req.Header().Get("X-Request-Id") != "rid" || cli.req.Header().Get("Transfer-Encoding") != "" {
+		t.Fatalf("invalid forward header, %+v", cli.req.Header())
+	}
+
+	if in.ID != 42 || in.Name != "nova" || len(in.Tags) != 2 || in.Limit == nil || *in.Limit != 10 {
+		t.Fatalf("invalid bind, %+v", in)
+	}
+
+	out := &echoResponse{}
+	if err := rsp.Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != 42 || out.Text != "nova" || rsp.Codec() != uint32(netx.CodecTypeJson) {
+		t.Fatalf("invalid response, %+v", out)
+	}
+	if rsp.Header().Get("X-Backend") != "echo" || rsp.Header().Get("Connection") != "" {
+		t.Fatalf("invalid response header, %+v", rsp.Header())
+	}
+}
+
+func TestGatewayStatus(t *testing.T) {
+	cli := &mockClient{rsp: func(req netx.Request) netx.Response {
+		rsp := netx.NewResponse()
+		rsp.SetStatus(netx.StatusTimeout, "")
+		return rsp
+	}}
+
+	g := New(server.New(), WithClient(ProtocolRPC, cli))
+	ep, err := g.Endpoint(&Rule{Path: "/ping", Service: "echo", URI: "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := netx.NewRequest()
+	req.SetURI("/ping")
+	_, err = ep(context.Background(), req)
+	if nerr, ok := err.(netx.Error); !ok || nerr.Code() != 504 {
+		t.Fatalf("expect gateway timeout, %v", err)
+	}
+}
+
+func TestGatewayReload(t *testing.T) {
+	cli := &mockClient{}
+	g := New(server.New(), WithClient(ProtocolRPC, cli))
+	if err := g.Add(&Rule{Method: "GET", Path: "/a", Service: "s", URI: "A"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Add(&Rule{Method: "GET", Path: "/b", Service: "s", URI: "B", Request: "unknown"}); err == nil {
+		t.Fatal("expect unknown type error")
+	}
+
+	err := g.AddRoutes(&netx.Route{
+		Method:   netx.MethodGet,
+		Path:     "/users/:id",
+		Name:     "GetUser",
+		Metadata: map[string]string{MetaService: "user"},
+	}, &netx.Route{Path: "/local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Routes()) != 2 {
+		t.Fatalf("expect 2 routes, %d", len(g.Routes()))
+	}
+
+	if err := g.Reload([]*Rule{{Path: "/c", Service: "s", URI: "C"}}); err != nil {
+		t.Fatal(err)
+	}
+	routes := g.Routes()
+	if len(routes) != 1 || routes[0].Path != "/c" || routes[0].Method != netx.MethodAny {
+		t.Fatalf("reload fail, %+v", routes)
+	}
+
+	rule, ok := FromRoute(&netx.Route{Name: "GetUser", Metadata: map[string]string{MetaService: "user", MetaCmdID: "7"}})
+	if !ok || rule.URI != "GetUser" || rule.CmdID != 7 {
+		t.Fatalf("invalid rule from route, %+v", rule)
+	}
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/metadata"
+	"github.com/foredata/nova/netx/server"
+)
+
+// 不透传的header,hop-by-hop及由协议自身维护的header
+var gSkipHeaders = map[string]bool{
+	"connection":          true,
+	"keep-alive":          true,
+	"proxy-connection":    true,
+	"proxy-authenticate":  true,
+	"proxy-authorization": true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"upgrade":             true,
+	"host":                true,
+	"content-length":      true,
+	"content-type":        true,
+	"accept-encoding":     true,
+	"content-encoding":    true,
+}
+
+const headerForwardedFor = "X-Forwarded-For"
+
+func defaultHeaderFilter(key string) bool {
+	return !gSkipHeaders[strings.ToLower(key)]
+}
+
+// requestHeader 生成后端请求header
+//	1: 按HeaderFilter透传http header
+//	2: 透传context中RPC_开头的metadata
+//	3: 追加X-Forwarded-For
+func (g *Gateway) requestHeader(ctx context.Context, req netx.Request) netx.Header {
+	h := netx.NewHeader()
+	for _, kv := range req.Header() {
+		if g.opts.Header(kv.Key) {
+			h.SetValues(kv.Key, kv.Values)
+		}
+	}
+
+	h = metadata.Forward(ctx, h)
+
+	if conn := server.GetConn(ctx); conn != nil {
+		ip := conn.RemoteAddr()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		if prior := h.Get(headerForwardedFor); prior != "" {
+			ip = prior + ", " + ip
+		}
+		h.Set(headerForwardedFor, ip)
+	}
+
+	return h
+}
+
+// responseHeader 按HeaderFilter过滤后端应答header
+func (g *Gateway) responseHeader(rsp netx.Response) netx.Header {
+	h := netx.NewHeader()
+	for _, kv := range rsp.Header() {
+		if g.opts.Header(kv.Key) && !strings.EqualFold(kv.Key, netx.XTimeout) {
+			h.SetValues(kv.Key, kv.Values)
+		}
+	}
+
+	return h
+}
@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/discovery"
+)
+
+// 后端协议名
+const (
+	ProtocolRPC     = "rpc"
+	ProtocolTHeader = "theader"
+)
+
+// HeaderFilter 判断header是否需要在http与后端之间透传
+type HeaderFilter func(key string) bool
+
+// Options 网关配置
+type Options struct {
+	Resolver    discovery.Resolver     // 后端服务发现,默认static,即Service为后端地址
+	Clients     map[string]netx.Client // 按协议名指定client,未指定时使用Resolver自动创建
+	Protocol    string                 // 默认后端协议,默认rpc
+	Timeout     time.Duration          // 默认调用超时,0表示使用client配置
+	Middlewares []netx.Middleware      // 所有规则公共的中间件,例如auth,ratelimit,tracing
+	Header      HeaderFilter           // header透传过滤,默认透传除hop-by-hop及编码相关外的全部header
+	Codec       netx.CodecType         // 返回给http客户端的编码,默认json
+}
+
+type Option func(o *Options)
+
+func newOptions(opts ...Option) *Options {
+	o := &Options{
+		Protocol: ProtocolRPC,
+		Codec:    netx.CodecTypeJson,
+		Header:   defaultHeaderFilter,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return o
+}
+
+// WithResolver 设置后端服务发现
+func WithResolver(r discovery.Resolver) Option {
+	return func(o *Options) {
+		o.Resolver = r
+	}
+}
+
+// WithClient 为指定协议设置client
+func WithClient(protocol string, cli netx.Client) Option {
+	return func(o *Options) {
+		if o.Clients == nil {
+			o.Clients = make(map[string]netx.Client)
+		}
+		o.Clients[protocol] = cli
+	}
+}
+
+// WithProtocol 设置默认后端协议
+func WithProtocol(protocol string) Option {
+	return func(o *Options) {
+		o.Protocol = protocol
+	}
+}
+
+// WithTimeout 设置默认调用超时
+func WithTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// WithMiddlewares 设置公共中间件,在规则中间件之前执行
+func WithMiddlewares(m ...netx.Middleware) Option {
+	return func(o *Options) {
+		o.Middlewares = append(o.Middlewares, m...)
+	}
+}
+
+// WithHeaderFilter 设置header透传过滤
+func WithHeaderFilter(fn HeaderFilter) Option {
+	return func(o *Options) {
+		o.Header = fn
+	}
+}
+
+// WithCodec 设置返回给http客户端的编码
+func WithCodec(c netx.CodecType) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
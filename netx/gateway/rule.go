@@ -0,0 +1,193 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Rule 转发规则,将http请求映射到后端服务的某个方法
+//	可以通过json/yaml配置声明,也可以通过Route.Metadata注解生成,见FromRoute
+type Rule struct {
+	Method      string            `json:"method"`   // http method,空表示任意method
+	Path        string            `json:"path"`     // http path,支持:id,{id},*path通配符
+	Service     string            `json:"service"`  // 后端服务名,通过Resolver解析
+	URI         string            `json:"uri"`      // 后端方法名
+	CmdID       uint32            `json:"cmd_id"`   // 后端CmdID,非零时后端优先使用CmdID路由
+	Protocol    string            `json:"protocol"` // 后端协议,rpc或theader,空则使用Options.Protocol
+	Codec       string            `json:"codec"`    // 后端编码,json或protobuf,空时:指定了消息类型则为protobuf,否则透传原始body
+	Request     string            `json:"request"`  // 请求消息类型名,见RegisterType,用于json转码
+	Response    string            `json:"response"` // 应答消息类型名,见RegisterType,用于json转码
+	Timeout     string            `json:"timeout"`  // 调用超时,例如3s,空则使用Options.Timeout
+	Metadata    map[string]string `json:"metadata"` // 路由元数据,供auth,shedding等中间件使用
+	Middlewares []netx.Middleware `json:"-"`        // 规则私有中间件
+}
+
+// ParseRules 解析json格式的规则列表
+func ParseRules(data []byte) ([]*Rule, error) {
+	var rules []*Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Route.Metadata中的注解key
+const (
+	MetaService  = "gateway.service"
+	MetaURI      = "gateway.uri"
+	MetaCmdID    = "gateway.cmd_id"
+	MetaProtocol = "gateway.protocol"
+	MetaCodec    = "gateway.codec"
+	MetaRequest  = "gateway.request"
+	MetaResponse = "gateway.response"
+	MetaTimeout  = "gateway.timeout"
+)
+
+// FromRoute 通过路由注解生成规则,不存在gateway.service注解时返回false
+//	未指定gateway.uri时,使用Route.Name作为后端方法名
+func FromRoute(route *netx.Route) (*Rule, bool) {
+	service := route.Metadata[MetaService]
+	if service == "" {
+		return nil, false
+	}
+
+	rule := &Rule{
+		Path:        route.Path,
+		Service:     service,
+		URI:         route.Metadata[MetaURI],
+		Protocol:    route.Metadata[MetaProtocol],
+		Codec:       route.Metadata[MetaCodec],
+		Request:     route.Metadata[MetaRequest],
+		Response:    route.Metadata[MetaResponse],
+		Timeout:     route.Metadata[MetaTimeout],
+		Metadata:    route.Metadata,
+		Middlewares: route.Middlewares,
+	}
+
+	if route.Method != netx.MethodAny && route.Method != netx.MethodUnknown {
+		rule.Method = route.Method.String()
+	}
+
+	if rule.URI == "" {
+		rule.URI = route.Name
+	}
+
+	if v := route.Metadata[MetaCmdID]; v != "" {
+		if id, err := strconv.ParseUint(v, 10, 32); err == nil {
+			rule.CmdID = uint32(id)
+		}
+	}
+
+	return rule, true
+}
+
+// binding 规则编译后的结果
+type binding struct {
+	gateway *Gateway
+	rule    *Rule
+	method  netx.Method
+	client  netx.Client
+	codec   netx.CodecType // 后端编码,0表示透传
+	reqType reflect.Type
+	rspType reflect.Type
+	timeout time.Duration
+}
+
+func (g *Gateway) compile(rule *Rule) (*binding, error) {
+	if rule.Path == "" {
+		return nil, fmt.Errorf("gateway: empty path")
+	}
+	if rule.Service == "" {
+		return nil, fmt.Errorf("gateway: empty service, path=%s", rule.Path)
+	}
+	if rule.URI == "" && rule.CmdID == 0 {
+		return nil, fmt.Errorf("gateway: empty uri and cmd_id, path=%s", rule.Path)
+	}
+
+	b := &binding{gateway: g, rule: rule, method: netx.MethodAny, timeout: g.opts.Timeout}
+	if rule.Method != "" {
+		b.method = netx.ParseMethod(strings.ToUpper(rule.Method))
+		if b.method == netx.MethodUnknown {
+			return nil, fmt.Errorf("gateway: invalid method, %s", rule.Method)
+		}
+	}
+
+	protocol := rule.Protocol
+	if protocol == "" {
+		protocol = g.opts.Protocol
+	}
+	cli, err := g.getClient(protocol)
+	if err != nil {
+		return nil, err
+	}
+	b.client = cli
+
+	if rule.Request != "" {
+		if b.reqType = lookupType(rule.Request); b.reqType == nil {
+			return nil, fmt.Errorf("gateway: not found request type, %s", rule.Request)
+		}
+	}
+	if rule.Response != "" {
+		if b.rspType = lookupType(rule.Response); b.rspType == nil {
+			return nil, fmt.Errorf("gateway: not found response type, %s", rule.Response)
+		}
+	}
+
+	switch {
+	case rule.Codec != "":
+		c := netx.GetByName(rule.Codec)
+		if c == nil {
+			return nil, fmt.Errorf("gateway: not found codec, %s", rule.Codec)
+		}
+		b.codec = c.Type()
+	case b.reqType != nil || b.rspType != nil:
+		b.codec = netx.CodecTypeProtobuf
+	}
+
+	if rule.Timeout != "" {
+		d, err := time.ParseDuration(rule.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("gateway: invalid timeout, %s", rule.Timeout)
+		}
+		b.timeout = d
+	}
+
+	return b, nil
+}
+
+var (
+	gTypeMux sync.RWMutex
+	gTypes   = make(map[string]reflect.Type)
+)
+
+// RegisterType 注册消息类型,用于规则中通过名字引用,msg需要为结构体指针
+//	name为空时使用类型名,例如pb.PingRequest
+func RegisterType(name string, msg interface{}) {
+	t := reflect.TypeOf(msg)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("gateway: message must be struct pointer, %T", msg))
+	}
+
+	if name == "" {
+		name = t.Elem().String()
+	}
+
+	gTypeMux.Lock()
+	gTypes[name] = t.Elem()
+	gTypeMux.Unlock()
+}
+
+func lookupType(name string) reflect.Type {
+	gTypeMux.RLock()
+	t := gTypes[name]
+	gTypeMux.RUnlock()
+	return t
+}
@@ -0,0 +1,165 @@
+package gateway
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/pkg/strx"
+)
+
+// encodeRequest 将http请求转码为后端请求body
+//	指定了请求类型时:先按http编码(默认json)解析body,再使用path参数和query覆盖同名字段,最后按后端编码序列化
+//	未指定请求类型时:透传原始body及编码
+func (b *binding) encodeRequest(req netx.Request, out netx.Request) error {
+	if b.reqType == nil {
+		out.SetCodec(req.Codec())
+		if b.codec != 0 && req.Codec() != 0 && uint32(b.codec) != req.Codec() {
+			return netx.NewError(http.StatusUnsupportedMediaType, "", "gateway: request type required for transcoding")
+		}
+		if req.Body() != nil {
+			out.SetBody(req.Body())
+		}
+		return nil
+	}
+
+	msg := reflect.New(b.reqType)
+	data, err := readBody(req.Body())
+	if err != nil {
+		return netx.BadRequest("gateway: read body fail, %s", err.Error())
+	}
+
+	if len(data) > 0 {
+		codec := netx.CodecType(req.Codec())
+		if codec == netx.CodecTypeUnknown {
+			codec = netx.CodecTypeJson
+		}
+		if err := netx.Decode(toBuffer(data), codec, msg.Interface()); err != nil {
+			return netx.BadRequest("gateway: decode body fail, %s", err.Error())
+		}
+	}
+
+	if url := req.URL(); url != nil {
+		for key, values := range url.Query() {
+			if err := bindField(msg.Elem(), key, values); err != nil {
+				return netx.BadRequest("gateway: bind query %s fail, %s", key, err.Error())
+			}
+		}
+	}
+
+	params := req.Params()
+	for i, key := range params.Keys() {
+		if err := bindField(msg.Elem(), key, []string{params.Values()[i]}); err != nil {
+			return netx.BadRequest("gateway: bind param %s fail, %s", key, err.Error())
+		}
+	}
+
+	return out.Encode(b.codec, msg.Interface())
+}
+
+// decodeResponse 将后端应答转码为http应答
+func (b *binding) decodeResponse(rsp netx.Response, codec netx.CodecType) (netx.Response, error) {
+	if b.rspType == nil || rsp.Body() == nil {
+		return rsp, nil
+	}
+
+	buf, err := rsp.Body().Buffer()
+	if err != nil {
+		return nil, netx.BadGateway("gateway: read response fail, %s", err.Error())
+	}
+
+	msg := reflect.New(b.rspType).Interface()
+	if buf != nil && !buf.Empty() {
+		if err := netx.Decode(buf, uint(rsp.Codec()), msg); err != nil {
+			return nil, netx.BadGateway("gateway: decode response fail, %s", err.Error())
+		}
+	}
+
+	out := netx.NewResponse()
+	out.SetHeader(rsp.Header())
+	if err := out.Encode(codec, msg); err != nil {
+		return nil, netx.InternalServerError("gateway: encode response fail, %s", err.Error())
+	}
+
+	return out, nil
+}
+
+// bindField 通过json名字或字段名绑定结构体字段,不存在的字段直接忽略
+func bindField(val reflect.Value, name string, values []string) error {
+	field, ok := findField(val, name)
+	if !ok {
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr && field.IsNil() {
+		field.Set(reflect.New(field.Type().Elem()))
+	}
+
+	return strx.BindSlicev(values, field)
+}
+
+func findField(val reflect.Value, name string) (reflect.Value, bool) {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		tf := typ.Field(i)
+		if tf.PkgPath != "" {
+			continue
+		}
+
+		if matchName(tf, name) {
+			return val.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// matchName 依次匹配json tag,protobuf tag中的json名字,字段名(大小写不敏感)
+func matchName(tf reflect.StructField, name string) bool {
+	tag := tf.Tag.Get("json")
+	if idx := strings.IndexByte(tag, ','); idx != -1 {
+		tag = tag[:idx]
+	}
+	if tag == name {
+		return true
+	}
+
+	for _, s := range strings.Split(tf.Tag.Get("protobuf"), ",") {
+		if s == "json="+name {
+			return true
+		}
+	}
+
+	return strings.EqualFold(tf.Name, name)
+}
+
+func readBody(b netx.Body) ([]byte, error) {
+	if b == nil {
+		return nil, nil
+	}
+
+	buf, err := b.Buffer()
+	if err == nil {
+		if buf == nil {
+			return nil, nil
+		}
+		return buf.Bytes(), nil
+	}
+
+	if err != body.ErrNotSupport {
+		return nil, err
+	}
+
+	return io.ReadAll(b)
+}
+
+func toBuffer(data []byte) bytex.Buffer {
+	buf := bytex.NewBuffer()
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	return buf
+}
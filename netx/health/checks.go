@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Pinger 支持Ping的对象,例如sqlx.Conn,以及sqlx.DB,store.Store的部分实现
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PingCheck 通过Ping检查,sqlx.DB,store.Store接口不包含Ping,需先断言为Pinger,
+// 例如health.PingCheck(db.(health.Pinger)),未实现时在注册阶段即可发现
+func PingCheck(p Pinger) CheckFunc {
+	return p.Ping
+}
+
+// DialCheck 检查下游地址是否可以建立连接,network为空时使用tcp
+func DialCheck(network, addr string) CheckFunc {
+	if network == "" {
+		network = "tcp"
+	}
+
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+}
+
+// HTTPCheck 检查下游http服务,应答状态码小于500时认为可用
+func HTTPCheck(url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = rsp.Body.Close()
+		if rsp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("bad status, %s", rsp.Status)
+		}
+
+		return nil
+	}
+}
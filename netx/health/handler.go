@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+)
+
+// 默认路由
+const (
+	PathLive  = "/healthz"
+	PathReady = "/readyz"
+	PathCheck = "/grpc.health.v1.Health/Check" // 标准grpc健康检查
+)
+
+// CheckRequest 对应grpc.health.v1.HealthCheckRequest
+type CheckRequest struct {
+	Service string `json:"service"`
+}
+
+// CheckResponse 对应grpc.health.v1.HealthCheckResponse
+type CheckResponse struct {
+	Status Status `json:"status"`
+}
+
+// Routes 返回/healthz,/readyz及grpc Check路由
+func Routes(h Checker) []*netx.Route {
+	return append(ProbeRoutes(h), CheckRoute(h))
+}
+
+// ProbeRoutes 返回/healthz,/readyz路由,用于kubernetes探针
+func ProbeRoutes(h Checker) []*netx.Route {
+	return []*netx.Route{
+		{Name: "health.live", Method: netx.MethodGet, Path: PathLive, Handler: LiveHandler(h)},
+		{Name: "health.ready", Method: netx.MethodGet, Path: PathReady, Handler: ReadyHandler(h)},
+	}
+}
+
+// CheckRoute 返回grpc Check路由,rpc按Name路由,因此Name与Path相同
+func CheckRoute(h Checker) *netx.Route {
+	return &netx.Route{Name: PathCheck, Method: netx.MethodAny, Path: PathCheck, Handler: CheckHandler(h)}
+}
+
+// LiveHandler liveness检查,失败时返回503,body为json格式的Report
+func LiveHandler(h Checker) func(ctx context.Context, req netx.Request) (netx.Response, error) {
+	return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return toResponse(h.Live(ctx))
+	}
+}
+
+// ReadyHandler readiness检查,失败时返回503,body为json格式的Report
+func ReadyHandler(h Checker) func(ctx context.Context, req netx.Request) (netx.Response, error) {
+	return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return toResponse(h.Ready(ctx))
+	}
+}
+
+// CheckHandler grpc.health.v1.Health/Check,service不存在时返回404
+func CheckHandler(h Checker) func(ctx context.Context, req *CheckRequest) (*CheckResponse, error) {
+	return func(ctx context.Context, req *CheckRequest) (*CheckResponse, error) {
+		status, err := h.Check(ctx, req.Service)
+		if err == ErrNotFound {
+			return nil, netx.NewError(http.StatusNotFound, "", "unknown service %s", req.Service)
+		}
+
+		return &CheckResponse{Status: status}, nil
+	}
+}
+
+func toResponse(rpt *Report) (netx.Response, error) {
+	buf, err := netx.Encode(netx.CodecTypeJson, rpt)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := netx.NewResponse()
+	rsp.SetCodec(uint32(netx.CodecTypeJson))
+	rsp.SetBody(body.NewBufferBody(buf))
+	if rpt.Status != StatusServing {
+		rsp.SetStatus(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+	}
+
+	return rsp, nil
+}
@@ -0,0 +1,358 @@
+// Package health 健康检查
+//	各模块通过Register注册命名检查项,支持超时,结果缓存及是否关键
+//	Live: 所有liveness检查项通过时为SERVING,对应/healthz,失败时通常需要重启进程
+//	Ready: 所有关键检查项通过时为SERVING,对应/readyz,失败时应摘除流量
+//	后台定期检查readiness,变化时回调Watch,server据此自动注销和恢复服务注册
+//	同时实现了标准grpc.health.v1.Health/Check协议,见Routes
+//	h := health.New()
+//	h.Register("db", health.PingCheck(db.(health.Pinger)))
+//	h.Register("cache", health.DialCheck("tcp", "127.0.0.1:6379"), health.WithCritical(false))
+//	server.New(server.WithHealth(h, ":8081"))
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/singleflight"
+)
+
+// some error
+var (
+	ErrNotFound = errors.New("health check not found")
+)
+
+var gCheckUp = metrics.NewGaugeSet(&metrics.GaugeOpts{
+	Namespace: "nova",
+	Subsystem: "health",
+	Name:      "check_up",
+	Help:      "health check result, 1 means passing",
+}, []string{"check"})
+
+// CheckFunc 检查函数,返回nil表示健康,需要响应ctx超时
+type CheckFunc func(ctx context.Context) error
+
+// Status 健康状态,取值与grpc.health.v1.HealthCheckResponse.ServingStatus一致
+type Status int32
+
+const (
+	StatusUnknown        Status = 0
+	StatusServing        Status = 1
+	StatusNotServing     Status = 2
+	StatusServiceUnknown Status = 3
+)
+
+var statusNames = [...]string{"UNKNOWN", "SERVING", "NOT_SERVING", "SERVICE_UNKNOWN"}
+
+func (s Status) String() string {
+	if s >= 0 && int(s) < len(statusNames) {
+		return statusNames[s]
+	}
+
+	return statusNames[0]
+}
+
+// MarshalText 与proto3 json一致,使用枚举名序列化
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 解析枚举名
+func (s *Status) UnmarshalText(text []byte) error {
+	for i, name := range statusNames {
+		if name == string(text) {
+			*s = Status(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("health: unknown status %s", text)
+}
+
+// Result 单项检查结果
+type Result struct {
+	Name      string    `json:"name"`            // 检查项名
+	Status    Status    `json:"status"`          // 检查结果
+	Error     string    `json:"error,omitempty"` // 失败原因
+	Critical  bool      `json:"critical"`        // 是否关键检查项
+	Latency   string    `json:"latency"`         // 检查耗时
+	CheckedAt time.Time `json:"checked_at"`      // 检查时间
+}
+
+// Report 检查报告
+type Report struct {
+	Status Status    `json:"status"` // 整体状态
+	Checks []*Result `json:"checks"` // 各检查项结果,按名字排序
+}
+
+// Checker 健康检查,作为netx.Module启动后台检查
+type Checker interface {
+	netx.Module
+	// Register 注册检查项,同名覆盖
+	Register(name string, fn CheckFunc, opts ...CheckOption)
+	// Unregister 删除检查项
+	Unregister(name string)
+	// Live liveness检查
+	Live(ctx context.Context) *Report
+	// Ready readiness检查
+	Ready(ctx context.Context) *Report
+	// Check 查询单个检查项状态,name为空表示整体readiness,不存在时返回ErrNotFound
+	Check(ctx context.Context, name string) (Status, error)
+	// Watch 监听readiness变化,仅在状态变化时回调,回调串行执行
+	Watch(fn func(ready bool))
+	// Shutdown 标记readiness失败,用于优雅退出前摘除流量,不可恢复
+	Shutdown()
+}
+
+// New 创建Checker
+func New(opts ...Option) Checker {
+	h := &checker{
+		opts:   newOptions(opts...),
+		checks: make(map[string]*check),
+		ready:  -1,
+		done:   make(chan struct{}),
+	}
+	return h
+}
+
+type check struct {
+	name   string
+	fn     CheckFunc
+	opts   CheckOptions
+	mux    sync.Mutex // 保护last,expire
+	last   *Result    // 最近一次检查结果
+	expire time.Time  // 缓存过期时间
+}
+
+type checker struct {
+	opts     *Options           //
+	mux      sync.RWMutex       // 保护checks
+	checks   map[string]*check  //
+	group    singleflight.Group // 合并并发的相同检查
+	wmux     sync.Mutex         // 保护watchers,ready,串行回调
+	watchers []func(ready bool) //
+	ready    int32              // 上次readiness,-1表示未知
+	shutdown int32              // 是否已经Shutdown
+	once     sync.Once          //
+	done     chan struct{}      // 用于退出后台检查
+	wg       sync.WaitGroup     //
+}
+
+func (h *checker) Name() string {
+	return "health"
+}
+
+// Start 同步执行一次检查后启动后台检查
+func (h *checker) Start() error {
+	h.refresh()
+	h.wg.Add(1)
+	go h.loop()
+	return nil
+}
+
+func (h *checker) Stop() error {
+	h.once.Do(func() {
+		close(h.done)
+	})
+	h.wg.Wait()
+	return nil
+}
+
+func (h *checker) Register(name string, fn CheckFunc, opts ...CheckOption) {
+	c := &check{name: name, fn: fn, opts: CheckOptions{Critical: true}}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+	if c.opts.Timeout <= 0 {
+		c.opts.Timeout = h.opts.Timeout
+	}
+	if c.opts.CacheTTL <= 0 {
+		c.opts.CacheTTL = h.opts.CacheTTL
+	}
+
+	h.mux.Lock()
+	h.checks[name] = c
+	h.mux.Unlock()
+}
+
+func (h *checker) Unregister(name string) {
+	h.mux.Lock()
+	delete(h.checks, name)
+	h.mux.Unlock()
+}
+
+func (h *checker) Live(ctx context.Context) *Report {
+	return h.report(func(c *check) bool { return c.opts.Liveness }, false, false)
+}
+
+func (h *checker) Ready(ctx context.Context) *Report {
+	rpt := h.report(func(c *check) bool { return c.opts.Critical }, true, false)
+	if atomic.LoadInt32(&h.shutdown) != 0 {
+		rpt.Status = StatusNotServing
+	}
+
+	return rpt
+}
+
+func (h *checker) Check(ctx context.Context, name string) (Status, error) {
+	if name == "" {
+		return h.Ready(ctx).Status, nil
+	}
+
+	h.mux.RLock()
+	c := h.checks[name]
+	h.mux.RUnlock()
+	if c == nil {
+		return StatusServiceUnknown, ErrNotFound
+	}
+
+	return h.run(c, false).Status, nil
+}
+
+func (h *checker) Watch(fn func(ready bool)) {
+	h.wmux.Lock()
+	h.watchers = append(h.watchers, fn)
+	h.wmux.Unlock()
+}
+
+func (h *checker) Shutdown() {
+	atomic.StoreInt32(&h.shutdown, 1)
+	h.notify(false)
+}
+
+func (h *checker) loop() {
+	defer h.wg.Done()
+	ticker := time.NewTicker(h.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+			h.refresh()
+		}
+	}
+}
+
+// refresh 忽略缓存重新检查readiness
+func (h *checker) refresh() {
+	rpt := h.report(func(c *check) bool { return c.opts.Critical }, true, true)
+	h.notify(rpt.Status == StatusServing && atomic.LoadInt32(&h.shutdown) == 0)
+}
+
+// notify readiness变化时回调
+func (h *checker) notify(ready bool) {
+	v := int32(0)
+	if ready {
+		v = 1
+	}
+
+	h.wmux.Lock()
+	defer h.wmux.Unlock()
+	if h.ready == v {
+		return
+	}
+	h.ready = v
+	for _, fn := range h.watchers {
+		fn(ready)
+	}
+}
+
+// report 并发执行检查项,match用于判断失败时是否影响整体状态,all为false时仅检查匹配的检查项
+func (h *checker) report(match func(c *check) bool, all bool, force bool) *Report {
+	h.mux.RLock()
+	checks := make([]*check, 0, len(h.checks))
+	for _, c := range h.checks {
+		if all || match(c) {
+			checks = append(checks, c)
+		}
+	}
+	h.mux.RUnlock()
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].name < checks[j].name
+	})
+
+	rpt := &Report{Status: StatusServing, Checks: make([]*Result, len(checks))}
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i, c := range checks {
+		go func(i int, c *check) {
+			rpt.Checks[i] = h.run(c, force)
+			wg.Done()
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, c := range checks {
+		if rpt.Checks[i].Status != StatusServing && match(c) {
+			rpt.Status = StatusNotServing
+		}
+	}
+
+	return rpt
+}
+
+// run 执行检查,缓存未过期时直接返回缓存结果,并发的相同检查只执行一次
+func (h *checker) run(c *check, force bool) *Result {
+	if !force {
+		c.mux.Lock()
+		last, expire := c.last, c.expire
+		c.mux.Unlock()
+		if last != nil && time.Now().Before(expire) {
+			return last
+		}
+	}
+
+	v, _ := h.group.Do(c.name, func() (interface{}, error) {
+		res := exec(c)
+		c.mux.Lock()
+		c.last = res
+		c.expire = time.Now().Add(c.opts.CacheTTL)
+		c.mux.Unlock()
+		return res, nil
+	})
+
+	return v.(*Result)
+}
+
+// exec 执行检查函数,使用独立的context避免调用方取消后缓存失败结果,检查函数未响应超时也会按超时处理
+func exec(c *check) *Result {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		defer func() {
+			if x := recover(); x != nil {
+				errc <- fmt.Errorf("panic: %v", x)
+			}
+		}()
+		errc <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := &Result{Name: c.name, Status: StatusServing, Critical: c.opts.Critical, Latency: time.Since(start).String(), CheckedAt: start}
+	if err != nil {
+		res.Status = StatusNotServing
+		res.Error = err.Error()
+		gCheckUp.Values(c.name).Set(0)
+	} else {
+		gCheckUp.Values(c.name).Set(1)
+	}
+
+	return res
+}
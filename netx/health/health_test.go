@@ -0,0 +1,153 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/store/memory"
+)
+
+// flag 可切换结果的检查项
+type flag struct {
+	fail  int32
+	calls int32
+}
+
+func (f *flag) check(ctx context.Context) error {
+	atomic.AddInt32(&f.calls, 1)
+	if atomic.LoadInt32(&f.fail) != 0 {
+		return errors.New("fail")
+	}
+	return nil
+}
+
+func (f *flag) set(fail bool) {
+	v := int32(0)
+	if fail {
+		v = 1
+	}
+	atomic.StoreInt32(&f.fail, v)
+}
+
+func TestCritical(t *testing.T) {
+	h := New(WithCacheTTL(time.Nanosecond))
+	db, cache := &flag{}, &flag{}
+	h.Register("db", db.check)
+	h.Register("cache", cache.check, WithCritical(false))
+
+	ctx := context.Background()
+	if rpt := h.Ready(ctx); rpt.Status != StatusServing || len(rpt.Checks) != 2 || rpt.Checks[0].Name != "cache" {
+		t.Fatalf("bad report, %+v", rpt)
+	}
+
+	cache.set(true)
+	if rpt := h.Ready(ctx); rpt.Status != StatusServing || rpt.Checks[0].Status != StatusNotServing {
+		t.Fatalf("non-critical check should not fail readiness, %+v", rpt)
+	}
+
+	db.set(true)
+	if rpt := h.Ready(ctx); rpt.Status != StatusNotServing {
+		t.Fatalf("critical check should fail readiness, %+v", rpt)
+	}
+
+	// liveness仅包含liveness检查项
+	if rpt := h.Live(ctx); rpt.Status != StatusServing || len(rpt.Checks) != 0 {
+		t.Fatalf("bad liveness, %+v", rpt)
+	}
+
+	if st, err := h.Check(ctx, "db"); err != nil || st != StatusNotServing {
+		t.Fatalf("bad check, %v %v", st, err)
+	}
+	if _, err := h.Check(ctx, "none"); err != ErrNotFound {
+		t.Fatalf("expect ErrNotFound, %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	h := New(WithCacheTTL(time.Hour))
+	f := &flag{}
+	h.Register("db", f.check)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			h.Ready(context.Background())
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&f.calls); n != 1 {
+		t.Fatalf("check should be cached, %d", n)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := New()
+	h.Register("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}, WithCheckTimeout(20*time.Millisecond), WithLiveness())
+
+	start := time.Now()
+	rpt := h.Live(context.Background())
+	if rpt.Status != StatusNotServing || rpt.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("bad report, %+v", rpt.Checks[0])
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("check should timeout")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	h := New(WithInterval(10*time.Millisecond), WithCacheTTL(time.Nanosecond))
+	f := &flag{}
+	h.Register("db", f.check)
+
+	ch := make(chan bool, 8)
+	h.Watch(func(ready bool) {
+		ch <- ready
+	})
+	if err := h.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Stop()
+
+	expect := func(ready bool) {
+		t.Helper()
+		select {
+		case v := <-ch:
+			if v != ready {
+				t.Fatalf("expect %v", ready)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no readiness change")
+		}
+	}
+
+	expect(true)
+	f.set(true)
+	expect(false)
+	f.set(false)
+	expect(true)
+	h.Shutdown()
+	expect(false)
+	if st, _ := h.Check(context.Background(), ""); st != StatusNotServing {
+		t.Fatalf("shutdown should fail readiness, %v", st)
+	}
+}
+
+func TestPingCheck(t *testing.T) {
+	ctx := context.Background()
+	p, ok := memory.New().(Pinger)
+	if !ok {
+		t.Fatal("memory store should implement Pinger")
+	}
+	if err := PingCheck(p)(ctx); err != nil {
+		t.Fatalf("ping fail, %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package health
+
+import "time"
+
+const (
+	defaultInterval = time.Second * 5
+	defaultTimeout  = time.Second
+	defaultCacheTTL = time.Second
+)
+
+// Options 可选配置参数
+type Options struct {
+	Interval time.Duration // 后台检查周期,用于感知readiness变化,默认5s
+	Timeout  time.Duration // 单项检查默认超时时间,默认1s
+	CacheTTL time.Duration // 检查结果默认缓存时间,缓存期内的请求不会重复检查,默认1s
+}
+
+type Option func(o *Options)
+
+func newOptions(opts ...Option) *Options {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Interval <= 0 {
+		o.Interval = defaultInterval
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = defaultCacheTTL
+	}
+
+	return o
+}
+
+// WithInterval 设置后台检查周期
+func WithInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// WithTimeout 设置单项检查默认超时时间
+func WithTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+// WithCacheTTL 设置检查结果默认缓存时间
+func WithCacheTTL(d time.Duration) Option {
+	return func(o *Options) {
+		o.CacheTTL = d
+	}
+}
+
+// CheckOptions 检查项配置
+type CheckOptions struct {
+	Timeout  time.Duration // 超时时间,默认使用Options.Timeout
+	CacheTTL time.Duration // 结果缓存时间,默认使用Options.CacheTTL
+	Critical bool          // 关键检查项,失败时readiness失败,否则仅在报告中体现,默认true
+	Liveness bool          // 同时作为liveness检查项,失败时liveness失败,通常用于检测死锁等无法自愈的问题
+}
+
+type CheckOption func(o *CheckOptions)
+
+// WithCheckTimeout 设置检查超时时间
+func WithCheckTimeout(d time.Duration) CheckOption {
+	return func(o *CheckOptions) {
+		o.Timeout = d
+	}
+}
+
+// WithCheckCacheTTL 设置检查结果缓存时间
+func WithCheckCacheTTL(d time.Duration) CheckOption {
+	return func(o *CheckOptions) {
+		o.CacheTTL = d
+	}
+}
+
+// WithCritical 设置是否为关键检查项
+func WithCritical(critical bool) CheckOption {
+	return func(o *CheckOptions) {
+		o.Critical = critical
+	}
+}
+
+// WithLiveness 设置同时作为liveness检查项
+func WithLiveness() CheckOption {
+	return func(o *CheckOptions) {
+		o.Liveness = true
+	}
+}
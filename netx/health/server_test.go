@@ -0,0 +1,154 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/health"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/registry"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/transport/gpc"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+)
+
+// fakeRegistry 记录当前是否已注册
+type fakeRegistry struct {
+	registry.Registry
+	mux        sync.Mutex
+	registered bool
+}
+
+func (r *fakeRegistry) Register(ctx context.Context, service *registry.Service, ttl time.Duration) error {
+	r.mux.Lock()
+	r.registered = true
+	r.mux.Unlock()
+	return nil
+}
+
+func (r *fakeRegistry) Deregister(ctx context.Context, service *registry.Service) error {
+	r.mux.Lock()
+	r.registered = false
+	r.mux.Unlock()
+	return nil
+}
+
+func (r *fakeRegistry) isRegistered() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.registered
+}
+
+func TestAdmin(t *testing.T) {
+	var fail int32
+	h := health.New(health.WithInterval(10*time.Millisecond), health.WithCacheTTL(time.Nanosecond))
+	h.Register("db", func(ctx context.Context) error {
+		if atomic.LoadInt32(&fail) != 0 {
+			return errors.New("db down")
+		}
+		return nil
+	})
+
+	reg := &fakeRegistry{}
+	svr := server.New(
+		server.WithAddr("127.0.0.1:0"),
+		server.WithTranFactory(gpc.New),
+		server.WithRegistry(reg),
+		server.WithHealth(h, "127.0.0.1:0"),
+	)
+	s := svr.(interface {
+		Start() error
+		Stop() error
+		Addr() net.Addr
+		AdminAddr() net.Addr
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if !reg.isRegistered() {
+		t.Fatal("ready server should be registered")
+	}
+
+	get := func(addr net.Addr, path string) int {
+		t.Helper()
+		rsp, err := http.Get("http://" + addr.String() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = rsp.Body.Close()
+		return rsp.StatusCode
+	}
+	wait := func(registered bool) {
+		t.Helper()
+		for i := 0; i < 100 && reg.isRegistered() != registered; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if reg.isRegistered() != registered {
+			t.Fatalf("expect registered %v", registered)
+		}
+	}
+
+	if code := get(s.AdminAddr(), health.PathReady); code != http.StatusOK {
+		t.Fatalf("bad readyz, %d", code)
+	}
+
+	atomic.StoreInt32(&fail, 1)
+	wait(false)
+	if code := get(s.AdminAddr(), health.PathReady); code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503, %d", code)
+	}
+	if code := get(s.AdminAddr(), health.PathLive); code != http.StatusOK {
+		t.Fatalf("liveness should pass, %d", code)
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	wait(true)
+}
+
+func TestCheckRPC(t *testing.T) {
+	h := health.New()
+	h.Register("db", func(ctx context.Context) error { return nil })
+	memtest.NewServer(t, "health.rpc", server.WithHealth(h, ""))
+	cli := memtest.NewClient(t, client.WithProtocol(rpc.New()))
+
+	call := func(service string) (*health.CheckResponse, error) {
+		req := netx.NewRequest()
+		req.SetService(memtest.Addr("health.rpc"))
+		req.SetURI(health.PathCheck)
+		if err := req.Encode(netx.CodecTypeJson, &health.CheckRequest{Service: service}); err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := cli.Call(context.Background(), req)
+		if err != nil {
+			return nil, err
+		}
+		if rsp.StatusCode() != 0 && rsp.StatusCode() != http.StatusOK {
+			return nil, fmt.Errorf("status %d, %s", rsp.StatusCode(), rsp.StatusInfo())
+		}
+		out := &health.CheckResponse{}
+		if err := rsp.Decode(out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	for _, service := range []string{"", "db"} {
+		out, err := call(service)
+		if err != nil || out.Status != health.StatusServing {
+			t.Fatalf("bad check %q, %+v %v", service, out, err)
+		}
+	}
+	if _, err := call("unknown"); err == nil {
+		t.Fatal("unknown service should fail")
+	}
+}
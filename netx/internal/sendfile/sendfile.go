@@ -0,0 +1,89 @@
+// Package sendfile 零拷贝发送文件,Linux下使用sendfile(2),不支持时使用splice(2)
+//	仅支持未加密的socket,TLS等连接返回ErrNotSupport,由调用方使用普通方式发送
+package sendfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// some error
+var (
+	ErrNotSupport = errors.New("sendfile: not support")
+)
+
+// maxSendSize 单次系统调用最大发送字节数
+const maxSendSize = 1 << 30
+
+// fder 非阻塞socket,例如nio中的连接
+type fder interface {
+	Fd() int
+}
+
+// Send 将文件[offset, offset+count)发送到dst,返回已发送字节数,不修改文件读取位置
+//	dst实现syscall.Conn时(例如*net.TCPConn),阻塞直到全部发送或出错
+//	dst实现Fd() int时(例如nio的非阻塞socket),缓冲区已满时返回已发送字节数及syscall.EAGAIN
+//	平台或dst不支持时返回ErrNotSupport,文件被截断时返回io.ErrUnexpectedEOF
+func Send(dst io.Writer, file *os.File, offset int64, count int64) (int64, error) {
+	if count <= 0 {
+		return 0, nil
+	}
+
+	switch x := dst.(type) {
+	case syscall.Conn:
+		rc, err := x.SyscallConn()
+		if err != nil {
+			return 0, ErrNotSupport
+		}
+
+		var written int64
+		var werr error
+		err = rc.Write(func(fd uintptr) bool {
+			n, err := sendLoop(int(fd), file, offset+written, count-written)
+			written += n
+			if err == syscall.EAGAIN {
+				// 等待socket可写
+				return false
+			}
+			werr = err
+			return true
+		})
+		if werr == nil {
+			werr = err
+		}
+		return written, werr
+	case fder:
+		return sendLoop(x.Fd(), file, offset, count)
+	default:
+		return 0, ErrNotSupport
+	}
+}
+
+// sendLoop 循环发送直到完成或出错
+func sendLoop(fd int, file *os.File, offset int64, count int64) (int64, error) {
+	var written int64
+	for written < count {
+		size := count - written
+		if size > maxSendSize {
+			size = maxSendSize
+		}
+
+		n, err := send(fd, file, offset+written, int(size))
+		if n > 0 {
+			written += n
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			return written, io.ErrUnexpectedEOF
+		}
+	}
+
+	return written, nil
+}
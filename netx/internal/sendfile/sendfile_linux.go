@@ -0,0 +1,66 @@
+// +build linux
+
+package sendfile
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	spliceMove     = 0x1 // SPLICE_F_MOVE
+	spliceNonblock = 0x2 // SPLICE_F_NONBLOCK
+	maxSpliceSize  = 1 << 16
+)
+
+// send 优先使用sendfile,文件系统不支持时使用splice
+func send(fd int, file *os.File, offset int64, size int) (int64, error) {
+	off := offset
+	n, err := syscall.Sendfile(fd, int(file.Fd()), &off, size)
+	if err == syscall.EINVAL || err == syscall.ENOSYS {
+		return splice(fd, file, offset, size)
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	return int64(n), err
+}
+
+// splice 通过管道将文件数据转移到socket,管道中未发送的数据直接丢弃,下次从已发送位置重新读取
+func splice(fd int, file *os.File, offset int64, size int) (int64, error) {
+	var p [2]int
+	if err := syscall.Pipe2(p[:], syscall.O_CLOEXEC|syscall.O_NONBLOCK); err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = syscall.Close(p[0])
+		_ = syscall.Close(p[1])
+	}()
+
+	if size > maxSpliceSize {
+		size = maxSpliceSize
+	}
+
+	off := offset
+	n, err := syscall.Splice(int(file.Fd()), &off, p[1], nil, size, spliceMove|spliceNonblock)
+	if err != nil || n <= 0 {
+		return 0, err
+	}
+
+	var written int64
+	for written < n {
+		m, err := syscall.Splice(p[0], nil, fd, nil, int(n-written), spliceMove|spliceNonblock)
+		if m > 0 {
+			written += m
+		}
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
@@ -0,0 +1,203 @@
+// +build linux
+
+package sendfile
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func tempFile(t *testing.T, size int) (*os.File, []byte) {
+	data := make([]byte, size)
+	rand.Read(data)
+	f, err := ioutil.TempFile("", "sendfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	})
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	return f, data
+}
+
+func TestSendTCP(t *testing.T) {
+	f, data := tempFile(t, 1<<20)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	result := make(chan []byte, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			result <- nil
+			return
+		}
+		defer c.Close()
+		b, _ := ioutil.ReadAll(c)
+		result <- b
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	offset, count := int64(100), int64(len(data)-200)
+	n, err := Send(c, f, offset, count)
+	_ = c.Close()
+	if err != nil || n != count {
+		t.Fatalf("send fail, n=%d, err=%v", n, err)
+	}
+	if got := <-result; !bytes.Equal(got, data[offset:offset+count]) {
+		t.Fatalf("data mismatch, got %d bytes", len(got))
+	}
+
+	// 文件读取位置不变
+	if pos, _ := f.Seek(0, io.SeekCurrent); pos != int64(len(data)) {
+		t.Fatalf("file position changed, %d", pos)
+	}
+}
+
+func TestSendTruncated(t *testing.T) {
+	f, data := tempFile(t, 1024)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			_, _ = io.Copy(ioutil.Discard, c)
+			_ = c.Close()
+		}
+	}()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	n, err := Send(c, f, 0, int64(len(data)+10))
+	if err != io.ErrUnexpectedEOF || n != int64(len(data)) {
+		t.Fatalf("expect unexpected eof, n=%d, err=%v", n, err)
+	}
+}
+
+func TestSendNotSupport(t *testing.T) {
+	f, _ := tempFile(t, 16)
+	if _, err := Send(&bytes.Buffer{}, f, 0, 16); err != ErrNotSupport {
+		t.Fatalf("expect not support, %v", err)
+	}
+}
+
+type fdConn int
+
+func (c fdConn) Fd() int                     { return int(c) }
+func (c fdConn) Write(p []byte) (int, error) { return syscall.Write(int(c), p) }
+
+// TestSendNonblock 非阻塞socket缓冲区满时返回EAGAIN,再次调用从中断处继续
+func TestSendNonblock(t *testing.T) {
+	f, data := tempFile(t, 1<<20)
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+	_ = syscall.SetsockoptInt(fds[0], syscall.SOL_SOCKET, syscall.SO_SNDBUF, 4096)
+	if err := syscall.SetNonblock(fds[0], true); err != nil {
+		t.Fatal(err)
+	}
+
+	var offset int64
+	var eagain int
+	var got []byte
+	buf := make([]byte, 64*1024)
+	for offset < int64(len(data)) {
+		n, err := Send(fdConn(fds[0]), f, offset, int64(len(data))-offset)
+		offset += n
+		if err == syscall.EAGAIN {
+			eagain++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		// 读取部分数据,腾出发送缓冲区
+		m, err := syscall.Read(fds[1], buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:m]...)
+	}
+	_ = syscall.Shutdown(fds[0], syscall.SHUT_WR)
+	for {
+		m, err := syscall.Read(fds[1], buf)
+		if err != nil || m <= 0 {
+			break
+		}
+		got = append(got, buf[:m]...)
+	}
+
+	if eagain == 0 {
+		t.Fatal("expect EAGAIN")
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("data mismatch, got %d bytes", len(got))
+	}
+}
+
+func TestSplice(t *testing.T) {
+	f, data := tempFile(t, 100*1024)
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+
+	done := make(chan []byte, 1)
+	go func() {
+		var got []byte
+		buf := make([]byte, 64*1024)
+		for {
+			m, err := syscall.Read(fds[1], buf)
+			if err != nil || m <= 0 {
+				break
+			}
+			got = append(got, buf[:m]...)
+		}
+		done <- got
+	}()
+
+	var offset int64
+	for offset < int64(len(data)) {
+		n, err := splice(fds[0], f, offset, len(data)-int(offset))
+		offset += n
+		if err != nil && err != syscall.EAGAIN {
+			t.Fatal(err)
+		}
+	}
+	_ = syscall.Shutdown(fds[0], syscall.SHUT_WR)
+
+	if got := <-done; !bytes.Equal(got, data) {
+		t.Fatalf("data mismatch, got %d bytes", len(got))
+	}
+}
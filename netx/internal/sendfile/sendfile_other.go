@@ -0,0 +1,11 @@
+// +build !linux
+
+package sendfile
+
+import (
+	"os"
+)
+
+func send(fd int, file *os.File, offset int64, size int) (int64, error) {
+	return 0, ErrNotSupport
+}
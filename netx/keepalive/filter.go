@@ -0,0 +1,267 @@
+// Package keepalive 连接保活及空闲检测,用于清理NAT超时等原因产生的半开连接
+//	按连接记录最后读写时间,通过timing定时器在最近的超时时间检测,读写时只更新时间戳
+//	写空闲时通过协议实现的netx.Pinger发送心跳(rpc需使用rpc.WithPing开启,websocket,http2),对端应答或发送任何数据即视为存活
+//	tran.AddFilters(keepalive.NewFilter(
+//		keepalive.WithWriteIdle(30*time.Second),
+//		keepalive.WithReadIdle(3*time.Minute),
+//		keepalive.WithMaxAge(time.Hour),
+//	))
+package keepalive
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/unique"
+	"github.com/foredata/nova/times/timing"
+)
+
+var kConnKeyKeepalive = unique.NewKey(netx.KeyGroupConn, "keepalive")
+
+// NewFilter 创建空闲检测Filter,所有连接共用同一配置
+func NewFilter(opts ...Option) netx.Filter {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.PingTimeout == 0 {
+		o.PingTimeout = o.WriteIdle
+	}
+
+	return &filter{opts: o}
+}
+
+type filter struct {
+	netx.BaseFilter
+	opts *Options
+}
+
+func (f *filter) Name() string {
+	return "keepalive"
+}
+
+func (f *filter) HandleOpen(ctx netx.FilterCtx) error {
+	conn := ctx.Conn()
+	now := nowMillis()
+	st := &connState{conn: conn, opts: f.opts, created: now, lastRead: now, lastWrite: now}
+	conn.Attributes().Put(kConnKeyKeepalive, st)
+	st.mux.Lock()
+	st.schedule(now)
+	st.mux.Unlock()
+	return nil
+}
+
+func (f *filter) HandleRead(ctx netx.FilterCtx) error {
+	if st := getState(ctx.Conn()); st != nil {
+		atomic.StoreInt64(&st.lastRead, nowMillis())
+	}
+
+	return nil
+}
+
+func (f *filter) HandleWrite(ctx netx.FilterCtx) error {
+	if st := getState(ctx.Conn()); st != nil {
+		atomic.StoreInt64(&st.lastWrite, nowMillis())
+	}
+
+	return nil
+}
+
+func (f *filter) HandleClose(ctx netx.FilterCtx) error {
+	if st := getState(ctx.Conn()); st != nil {
+		st.stop()
+	}
+
+	return nil
+}
+
+func getState(conn netx.Conn) *connState {
+	st, _ := conn.Attributes().Get(kConnKeyKeepalive, nil).(*connState)
+	return st
+}
+
+// connState 连接状态,时间单位为毫秒
+//	每个连接同时只有一个定时器,超时后检测所有事件,再按最近的超时时间重新注册
+type connState struct {
+	mux       sync.Mutex
+	conn      netx.Conn
+	opts      *Options
+	created   int64     // 建立时间
+	lastRead  int64     // 最后读取时间,原子操作
+	lastWrite int64     // 最后写入时间,原子操作
+	readEvt   int64     // 上次触发EventReadIdle时间,空闲持续时每个周期触发一次
+	writeEvt  int64     // 上次触发EventWriteIdle时间
+	allEvt    int64     // 上次触发EventAllIdle时间
+	pingAt    int64     // 发送心跳时间,0表示没有等待应答的心跳
+	timerID   timing.ID // 当前定时器
+	running   bool      // 正在执行回调
+	closed    bool      // 连接已关闭
+}
+
+func (s *connState) onTimer(data interface{}) {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return
+	}
+	s.timerID = 0
+	s.running = true
+	events := s.check(nowMillis())
+	s.mux.Unlock()
+
+	// 回调中可能关闭连接,不能持有锁
+	for _, evt := range events {
+		s.fire(evt)
+	}
+
+	s.mux.Lock()
+	s.running = false
+	if !s.closed {
+		s.schedule(nowMillis())
+	}
+	s.mux.Unlock()
+}
+
+// check 返回已经超时的事件,连接将被关闭时只返回一个事件
+func (s *connState) check(now int64) []Event {
+	o := s.opts
+	lastRead := atomic.LoadInt64(&s.lastRead)
+	lastWrite := atomic.LoadInt64(&s.lastWrite)
+
+	if o.MaxAge > 0 && now >= s.created+o.MaxAge.Milliseconds() {
+		return []Event{EventMaxAge}
+	}
+
+	if s.pingAt != 0 {
+		if lastRead >= s.pingAt {
+			s.pingAt = 0
+		} else if now >= s.pingAt+o.PingTimeout.Milliseconds() {
+			s.pingAt = 0
+			return []Event{EventPingTimeout}
+		}
+	}
+
+	var events []Event
+	if o.ReadIdle > 0 && now >= maxInt64(lastRead, s.readEvt)+o.ReadIdle.Milliseconds() {
+		s.readEvt = now
+		events = append(events, EventReadIdle)
+	}
+	if o.WriteIdle > 0 && now >= maxInt64(lastWrite, s.writeEvt)+o.WriteIdle.Milliseconds() {
+		s.writeEvt = now
+		events = append(events, EventWriteIdle)
+	}
+	if o.AllIdle > 0 && now >= maxInt64(lastRead, lastWrite, s.allEvt)+o.AllIdle.Milliseconds() {
+		s.allEvt = now
+		events = append(events, EventAllIdle)
+	}
+
+	return events
+}
+
+// schedule 按最近的超时时间注册定时器,需要持有锁
+func (s *connState) schedule(now int64) {
+	o := s.opts
+	lastRead := atomic.LoadInt64(&s.lastRead)
+	lastWrite := atomic.LoadInt64(&s.lastWrite)
+
+	next := int64(math.MaxInt64)
+	if o.MaxAge > 0 {
+		next = minInt64(next, s.created+o.MaxAge.Milliseconds())
+	}
+	if s.pingAt != 0 {
+		next = minInt64(next, s.pingAt+o.PingTimeout.Milliseconds())
+	}
+	if o.ReadIdle > 0 {
+		next = minInt64(next, maxInt64(lastRead, s.readEvt)+o.ReadIdle.Milliseconds())
+	}
+	if o.WriteIdle > 0 {
+		next = minInt64(next, maxInt64(lastWrite, s.writeEvt)+o.WriteIdle.Milliseconds())
+	}
+	if o.AllIdle > 0 {
+		next = minInt64(next, maxInt64(lastRead, lastWrite, s.allEvt)+o.AllIdle.Milliseconds())
+	}
+	if next == math.MaxInt64 {
+		return
+	}
+
+	delay := next - now
+	if delay < 1 {
+		delay = 1
+	}
+	s.timerID = timing.NewDelayer(time.Duration(delay)*time.Millisecond, s.onTimer, nil)
+}
+
+// fire 执行回调及默认行为
+func (s *connState) fire(evt Event) {
+	if h := s.opts.Handler; h != nil && h(s.conn, evt) {
+		return
+	}
+
+	switch evt {
+	case EventWriteIdle:
+		s.ping()
+	default:
+		_ = s.conn.Close()
+	}
+}
+
+// ping 协议不支持心跳时忽略,已有等待应答的心跳时不更新发送时间
+func (s *connState) ping() {
+	p, ok := s.conn.Protocol().(netx.Pinger)
+	if !ok {
+		return
+	}
+
+	s.mux.Lock()
+	pending := s.pingAt != 0
+	if !pending {
+		s.pingAt = nowMillis()
+	}
+	s.mux.Unlock()
+
+	if err := p.Ping(s.conn); err != nil && !pending {
+		s.mux.Lock()
+		s.pingAt = 0
+		s.mux.Unlock()
+	}
+}
+
+// stop 关闭定时器,回调执行中不能调用timing.Stop,回调结束后不会再注册
+func (s *connState) stop() {
+	s.mux.Lock()
+	s.closed = true
+	id := s.timerID
+	s.timerID = 0
+	if s.running {
+		id = 0
+	}
+	s.mux.Unlock()
+
+	timing.Stop(id)
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt64(a int64, others ...int64) int64 {
+	for _, v := range others {
+		if v > a {
+			a = v
+		}
+	}
+
+	return a
+}
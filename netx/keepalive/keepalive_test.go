@@ -0,0 +1,169 @@
+package keepalive
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/transport/memory"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// peerFilter 使用rpc协议,answer为true时解析数据并自动应答心跳,否则丢弃收到的数据
+type peerFilter struct {
+	netx.BaseFilter
+	answer bool
+	closed chan struct{}
+}
+
+func (f *peerFilter) Name() string {
+	return "peer"
+}
+
+func (f *peerFilter) HandleOpen(ctx netx.FilterCtx) error {
+	ctx.Conn().SetProtocol(rpc.New(rpc.WithPing()))
+	return nil
+}
+
+func (f *peerFilter) HandleRead(ctx netx.FilterCtx) error {
+	buf, ok := ctx.Data().(bytex.Buffer)
+	if !ok {
+		return nil
+	}
+	if f.answer {
+		for {
+			frame, err := rpc.New().Decode(ctx.Conn(), buf)
+			if err != nil || frame == nil {
+				break
+			}
+			frame.Recycle()
+			buf.Discard()
+		}
+	}
+	_, _ = buf.Seek(0, io.SeekEnd)
+	buf.Discard()
+	return nil
+}
+
+func (f *peerFilter) HandleClose(ctx netx.FilterCtx) error {
+	close(f.closed)
+	return nil
+}
+
+// recorder 记录服务端触发的事件
+type recorder struct {
+	mux     sync.Mutex
+	events  []Event
+	handled bool
+}
+
+func (r *recorder) handle(conn netx.Conn, evt Event) bool {
+	r.mux.Lock()
+	r.events = append(r.events, evt)
+	r.mux.Unlock()
+	return r.handled
+}
+
+func (r *recorder) count(evt Event) int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e == evt {
+			n++
+		}
+	}
+	return n
+}
+
+// setup 服务端使用keepalive,返回客户端关闭通知
+func setup(t *testing.T, name string, answer bool, opts ...Option) *peerFilter {
+	t.Helper()
+	svr := memory.New()
+	svr.AddFilters(&peerFilter{answer: true, closed: make(chan struct{})}, NewFilter(opts...))
+	if _, err := svr.Listen(memory.Scheme + name); err != nil {
+		t.Fatal(err)
+	}
+
+	peer := &peerFilter{answer: answer, closed: make(chan struct{})}
+	cli := memory.New()
+	cli.AddFilters(peer)
+	conn, err := cli.Dial(memory.Scheme + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = svr.Close()
+	})
+
+	return peer
+}
+
+func waitClosed(peer *peerFilter, d time.Duration) bool {
+	select {
+	case <-peer.closed:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+func TestReadIdle(t *testing.T) {
+	r := &recorder{}
+	peer := setup(t, "keepalive.read", true, WithReadIdle(50*time.Millisecond), WithHandler(r.handle))
+	if !waitClosed(peer, time.Second) {
+		t.Fatal("idle conn not closed")
+	}
+	if r.count(EventReadIdle) != 1 {
+		t.Fatalf("bad events, %v", r.events)
+	}
+}
+
+func TestHandled(t *testing.T) {
+	r := &recorder{handled: true}
+	peer := setup(t, "keepalive.handled", true, WithAllIdle(30*time.Millisecond), WithHandler(r.handle))
+	if waitClosed(peer, 200*time.Millisecond) {
+		t.Fatal("handled conn closed")
+	}
+	if n := r.count(EventAllIdle); n < 3 {
+		t.Fatalf("idle event should fire every period, %d", n)
+	}
+}
+
+func TestPing(t *testing.T) {
+	r := &recorder{}
+	peer := setup(t, "keepalive.ping", true, WithWriteIdle(30*time.Millisecond), WithHandler(r.handle))
+	if waitClosed(peer, 300*time.Millisecond) {
+		t.Fatalf("alive conn closed, %v", r.events)
+	}
+	if r.count(EventWriteIdle) < 3 || r.count(EventPingTimeout) != 0 {
+		t.Fatalf("bad events, %v", r.events)
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	r := &recorder{}
+	peer := setup(t, "keepalive.dead", false, WithWriteIdle(30*time.Millisecond), WithPingTimeout(50*time.Millisecond), WithHandler(r.handle))
+	if !waitClosed(peer, time.Second) {
+		t.Fatal("dead conn not closed")
+	}
+	if r.count(EventPingTimeout) != 1 {
+		t.Fatalf("bad events, %v", r.events)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	r := &recorder{}
+	peer := setup(t, "keepalive.age", true, WithWriteIdle(10*time.Millisecond), WithMaxAge(100*time.Millisecond), WithHandler(r.handle))
+	start := time.Now()
+	if !waitClosed(peer, time.Second) {
+		t.Fatal("conn not closed")
+	}
+	if time.Since(start) < 90*time.Millisecond || r.count(EventMaxAge) != 1 {
+		t.Fatalf("bad events, %v", r.events)
+	}
+}
@@ -0,0 +1,85 @@
+package keepalive
+
+import (
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Event 连接空闲事件
+type Event uint8
+
+const (
+	EventReadIdle    Event = iota + 1 // 读空闲,默认关闭连接
+	EventWriteIdle                    // 写空闲,默认发送协议心跳
+	EventAllIdle                      // 读写均空闲,默认关闭连接
+	EventPingTimeout                  // 心跳超时未收到任何数据,认为对端已失效,默认关闭连接
+	EventMaxAge                       // 超过最大存活时间,默认关闭连接
+)
+
+var eventNames = [...]string{"", "read_idle", "write_idle", "all_idle", "ping_timeout", "max_age"}
+
+func (e Event) String() string {
+	if int(e) < len(eventNames) {
+		return eventNames[e]
+	}
+
+	return "unknown"
+}
+
+// Handler 事件回调,在定时器协程中执行,不能阻塞,返回true时不再执行默认行为
+type Handler func(conn netx.Conn, evt Event) bool
+
+// Options 可选配置参数,时间为0表示不检测
+type Options struct {
+	ReadIdle    time.Duration // 超过该时间未收到数据触发EventReadIdle
+	WriteIdle   time.Duration // 超过该时间未发送数据触发EventWriteIdle
+	AllIdle     time.Duration // 超过该时间未收发数据触发EventAllIdle
+	PingTimeout time.Duration // 发送心跳后超过该时间未收到任何数据触发EventPingTimeout,默认同WriteIdle
+	MaxAge      time.Duration // 连接建立超过该时间触发EventMaxAge,等待已发送数据完成后关闭
+	Handler     Handler       // 事件回调
+}
+
+type Option func(o *Options)
+
+// WithReadIdle 设置读空闲时间
+func WithReadIdle(d time.Duration) Option {
+	return func(o *Options) {
+		o.ReadIdle = d
+	}
+}
+
+// WithWriteIdle 设置写空闲时间,写空闲时发送心跳
+func WithWriteIdle(d time.Duration) Option {
+	return func(o *Options) {
+		o.WriteIdle = d
+	}
+}
+
+// WithAllIdle 设置读写空闲时间
+func WithAllIdle(d time.Duration) Option {
+	return func(o *Options) {
+		o.AllIdle = d
+	}
+}
+
+// WithPingTimeout 设置心跳超时时间
+func WithPingTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.PingTimeout = d
+	}
+}
+
+// WithMaxAge 设置连接最大存活时间
+func WithMaxAge(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxAge = d
+	}
+}
+
+// WithHandler 设置事件回调
+func WithHandler(h Handler) Option {
+	return func(o *Options) {
+		o.Handler = h
+	}
+}
@@ -24,7 +24,12 @@ type BaseConn struct {
 	status     uint32       // 当前状态
 	client     bool         // 是否dial产生的连接
 	attrs      AttributeMap // kv数据
-	protocol   interface{}  // 解析协议
+	protocol   atomic.Value // 解析协议,读写协程都会访问,保存protocolValue
+}
+
+// protocolValue atomic.Value要求类型一致,统一包装后保存
+type protocolValue struct {
+	p interface{}
 }
 
 // Init ...
@@ -130,9 +135,10 @@ func (c *BaseConn) Write(buff WriterTo) error {
 
 // Protocol 解析协议
 func (c *BaseConn) Protocol() interface{} {
-	return c.protocol
+	v, _ := c.protocol.Load().(protocolValue)
+	return v.p
 }
 
 func (c *BaseConn) SetProtocol(p interface{}) {
-	c.protocol = p
+	c.protocol.Store(protocolValue{p: p})
 }
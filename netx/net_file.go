@@ -0,0 +1,104 @@
+package netx
+
+import (
+	"io"
+	"os"
+
+	"github.com/foredata/nova/netx/internal/sendfile"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+const fileCopySize = 32 * 1024
+
+// NewFileWriter 创建发送文件的WriterTo,先发送head(通常为编码后的消息头),再发送文件[offset, offset+length)
+//	目标为未加密的socket时使用sendfile零拷贝发送,否则读取文件后发送
+//	发送中断后(例如非阻塞socket返回EAGAIN)再次调用WriteTo会从中断处继续,Close时调用closer释放文件
+func NewFileWriter(head bytex.Buffer, file *os.File, offset, length int64, closer io.Closer) WriterTo {
+	return &fileWriter{head: head, file: file, offset: offset, length: length, closer: closer}
+}
+
+type fileWriter struct {
+	head   bytex.Buffer // 未发送的消息头
+	file   *os.File     //
+	offset int64        // 下次发送的文件偏移
+	length int64        // 剩余长度
+	closer io.Closer    //
+	copy   bool         // 目标不支持零拷贝,读取后发送
+}
+
+func (w *fileWriter) WriteTo(dst io.Writer) (int64, error) {
+	var total int64
+	if w.head != nil {
+		n, err := w.head.WriteTo(dst)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if w.head.Pos() < w.head.Len() {
+			return total, io.ErrShortWrite
+		}
+		_ = w.head.Close()
+		w.head = nil
+	}
+
+	if !w.copy {
+		n, err := sendfile.Send(dst, w.file, w.offset, w.length)
+		w.offset += n
+		w.length -= n
+		total += n
+		if err != sendfile.ErrNotSupport {
+			return total, err
+		}
+		w.copy = true
+	}
+
+	n, err := w.copyTo(dst)
+	return total + n, err
+}
+
+// copyTo 读取文件后发送,使用ReadAt不依赖文件读取位置
+func (w *fileWriter) copyTo(dst io.Writer) (int64, error) {
+	var total int64
+	buf := make([]byte, fileCopySize)
+	for w.length > 0 {
+		size := int64(len(buf))
+		if size > w.length {
+			size = w.length
+		}
+
+		n, err := w.file.ReadAt(buf[:size], w.offset)
+		if n == 0 {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return total, err
+		}
+
+		m, err := dst.Write(buf[:n])
+		if m > 0 {
+			w.offset += int64(m)
+			w.length -= int64(m)
+			total += int64(m)
+		}
+		if err != nil {
+			return total, err
+		}
+		if m < n {
+			return total, io.ErrShortWrite
+		}
+	}
+
+	return total, nil
+}
+
+func (w *fileWriter) Close() error {
+	if w.head != nil {
+		_ = w.head.Close()
+		w.head = nil
+	}
+	if w.closer != nil {
+		return w.closer.Close()
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/compress"
 )
 
 func NewRequest() Request {
@@ -110,6 +111,15 @@ func (p *packet) SetCodec(v uint32) {
 	p.ident.Codec = v
 }
 
+func (p *packet) Compress() compress.Type {
+	return p.ident.Compress
+}
+
+func (p *packet) SetCompress(v compress.Type) {
+	p.ensure()
+	p.ident.Compress = v
+}
+
 func (p *packet) IsOneway() bool {
 	return p.ident.IsOneway
 }
@@ -144,10 +154,11 @@ func (p *packet) URI() string {
 func (p *packet) SetURI(v string) {
 	p.ensure()
 	p.ident.URI = v
+	p.ident.url = nil
 }
 
 func (p *packet) URL() *url.URL {
-	return p.ident.url
+	return p.ident.URL()
 }
 
 func (p *packet) Params() Params {
@@ -4,13 +4,14 @@ import (
 	"io"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
 // NewFilter .
-func NewFilter(executor netx.Executor, provider Provider, detector netx.Detector) netx.Filter {
+func NewFilter(executor netx.Executor, provider Provider, detector netx.Detector, opts ...Option) netx.Filter {
 	return &filter{
-		processor: New(executor, provider),
+		processor: New(executor, provider, opts...),
 		detector:  detector,
 	}
 }
@@ -32,27 +33,41 @@ func (f *filter) HandleRead(ctx netx.FilterCtx) error {
 		return nil
 	}
 
-	// detect protocol
-	proto, _ := conn.Protocol().(netx.Protocol)
-	if proto == nil {
-		proto = f.detector.Detect(data)
+	// 一次读取可能包含多帧,需要循环解析,处理过程中协议可能被替换,比如websocket升级后
+	for {
+		// detect protocol
+		proto, _ := conn.Protocol().(netx.Protocol)
 		if proto == nil {
-			return nil
+			proto = f.detector.Detect(data)
+			if proto == nil {
+				return nil
+			}
+			conn.SetProtocol(proto)
 		}
-		conn.SetProtocol(proto)
-	}
 
-	// decode protocol
-	frame, err := proto.Decode(conn, data)
-	if err != nil || frame == nil {
-		return err
+		// decode protocol
+		frame, err := proto.Decode(conn, data)
+		if err != nil || frame == nil {
+			return err
+		}
+
+		// 丢弃已经解析过的数据
+		data.Discard()
+
+		// process message
+		if err := f.processor.Process(conn, frame); err != nil {
+			return err
+		}
 	}
+}
 
-	// 丢弃已经解析过的数据
-	data.Discard()
+// HandleClose 协议实现io.Closer时,连接关闭时通知协议释放资源
+func (f *filter) HandleClose(ctx netx.FilterCtx) error {
+	if c, ok := ctx.Conn().Protocol().(io.Closer); ok {
+		_ = c.Close()
+	}
 
-	// process message
-	return f.processor.Process(conn, frame)
+	return nil
 }
 
 func (f *filter) HandleWrite(ctx netx.FilterCtx) error {
@@ -92,35 +107,67 @@ func (f *filter) HandleWrite(ctx netx.FilterCtx) error {
 }
 
 func encodePacket(proto netx.Protocol, conn netx.Conn, packet netx.Packet) (netx.WriterTo, error) {
-	body := packet.Body()
 	var payload bytex.Buffer
-	var chunked bool
-	if body != nil {
-		payload, _ = body.ReadFast(false)
-		chunked = !body.End()
-	}
-
-	if !chunked {
-		// 不需要分块传输
-		header := packet.Header()
-		if header == nil {
-			header = netx.NewHeader()
+	if bd := packet.Body(); bd != nil {
+		if wt, err := encodeFile(proto, conn, packet); wt != nil || err != nil {
+			return wt, err
 		}
-		header.Merge(packet.Trailer())
 
-		if payload != nil {
-			_, _ = payload.Seek(0, io.SeekStart)
+		buf, err := bd.Buffer()
+		if err != nil {
+			// 非Buffer类型的body,按分块传输,由写协程按数据产生的顺序依次写入
+			return newStreamWriter(proto, conn, packet), nil
 		}
+		payload = buf
+	}
 
-		frame := netx.NewFrame(netx.FrameTypeHeader, true, 0, packet.Identifier(), header, payload)
-		buf, err := proto.Encode(conn, frame)
-		if buf != nil {
-			_, _ = buf.Seek(0, io.SeekStart)
-		}
-		return buf, err
+	// 不需要分块传输
+	header := packet.Header()
+	if header == nil {
+		header = netx.NewHeader()
+	}
+	header.Merge(packet.Trailer())
+
+	if payload != nil {
+		_, _ = payload.Seek(0, io.SeekStart)
+	}
+
+	frame := netx.NewFrame(netx.FrameTypeHeader, true, 0, packet.Identifier(), header, payload)
+	buf, err := proto.Encode(conn, frame)
+	if buf != nil {
+		_, _ = buf.Seek(0, io.SeekStart)
+	}
+	return buf, err
+}
+
+// encodeFile 文件类型body且协议支持原样发送消息体时,只编码header,文件由transport零拷贝发送
+//	trailer合并到header中发送,不支持时返回nil,按普通方式分块发送
+func encodeFile(proto netx.Protocol, conn netx.Conn, packet netx.Packet) (netx.WriterTo, error) {
+	fr, ok := packet.Body().(body.FileRegion)
+	if !ok {
+		return nil, nil
+	}
+	re, ok := proto.(netx.RawEncoder)
+	if !ok {
+		return nil, nil
 	}
+	file, offset, length := fr.Region()
+	if file == nil {
+		return nil, nil
+	}
+
+	header := packet.Header()
+	if header == nil {
+		header = netx.NewHeader()
+	}
+	header.Merge(packet.Trailer())
 
-	// TODO: chunked encode
+	frame := netx.NewFrame(netx.FrameTypeHeader, false, 0, packet.Identifier(), header, nil)
+	buf, ok, err := re.EncodeHeader(conn, frame, length)
+	if err != nil || !ok {
+		return nil, err
+	}
 
-	return nil, netx.ErrNotSupport
+	_, _ = buf.Seek(0, io.SeekStart)
+	return netx.NewFileWriter(buf, file, offset, length, packet.Body()), nil
 }
@@ -0,0 +1,32 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// KeyFunc 计算任务的key,用于ordered等按key有序执行的Executor
+type KeyFunc func(conn netx.Conn, packet netx.Packet) interface{}
+
+// Options 可选配置
+type Options struct {
+	MaxQueueTime time.Duration // 请求在Executor中最长排队时间,超过则直接丢弃,0表示不限制
+	KeyFunc      KeyFunc       // 任务的key,默认使用连接ID,同一连接上的请求按顺序执行
+}
+
+type Option func(o *Options)
+
+// WithMaxQueueTime 设置最长排队时间,请求header中携带超时时间时,取两者中较小值
+func WithMaxQueueTime(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxQueueTime = d
+	}
+}
+
+// WithKeyFunc 设置任务的key,例如按用户ID有序执行
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(o *Options) {
+		o.KeyFunc = fn
+	}
+}
@@ -3,7 +3,9 @@ package processor
 import (
 	"errors"
 	"sync"
+	"time"
 
+	"github.com/foredata/nova/debug/metrics"
 	"github.com/foredata/nova/netx"
 	"github.com/foredata/nova/netx/body"
 )
@@ -12,9 +14,22 @@ var (
 	ErrNotFoundHandler = errors.New("not found handler")
 )
 
+var gDropped = metrics.NewCounter(&metrics.CounterOpts{
+	Namespace: "nova",
+	Subsystem: "processor",
+	Name:      "dropped",
+	Help:      "requests dropped because deadline exceeded while queued",
+})
+
 // New .
-func New(executor netx.Executor, provider Provider) netx.Processor {
+func New(executor netx.Executor, provider Provider, opts ...Option) netx.Processor {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
 	p := &processor{
+		opts:     o,
 		executor: executor,
 		provider: provider,
 		tasks:    make(map[uint64]*streamTask),
@@ -28,6 +43,7 @@ type Provider interface {
 }
 
 type processor struct {
+	opts     *Options
 	provider Provider
 	executor netx.Executor
 	tasks    map[uint64]*streamTask
@@ -43,26 +59,63 @@ func (p *processor) Process(conn netx.Conn, frame netx.Frame) error {
 			return ErrNotFoundHandler
 		}
 
+		// Executor未接收任务时(例如队列已满),直接返回503
+		key := p.key(conn, packet)
 		if frame.EndFlag() {
-			t := newSimpleTask(conn, packet, callback)
-			return p.executor.Post(t)
+			t := newSimpleTask(conn, packet, callback, p.deadline(packet), key)
+			if err := p.executor.Post(t); err != nil {
+				return t.Drop(err)
+			}
 		} else {
-			t := newStreamTask(taskId, p, conn, packet, callback)
+			t := newStreamTask(taskId, conn, packet, callback, p.deadline(packet), key)
 			p.addTask(t)
-			return p.executor.Post(t)
-		}
-	} else {
-		p.mux.RLock()
-		task := p.tasks[taskId]
-		p.mux.RUnlock()
-		if frame.EndFlag() {
-			p.deleteTask(taskId)
-		}
-		if task != nil {
-			return task.TryPost(frame.EndFlag())
+			if err := p.executor.Post(t); err != nil {
+				p.deleteTask(taskId)
+				return t.Drop(err)
+			}
 		}
+
+		return nil
+	}
+
+	// 后续帧写入body,由handler读取
+	p.mux.RLock()
+	task := p.tasks[taskId]
+	p.mux.RUnlock()
+	if task == nil {
+		return nil
+	}
+	if frame.EndFlag() {
+		p.deleteTask(taskId)
+	}
+
+	return task.Write(frame)
+}
+
+// key 计算任务的key,默认使用连接ID
+func (p *processor) key(conn netx.Conn, packet netx.Packet) interface{} {
+	if p.opts.KeyFunc != nil {
+		return p.opts.KeyFunc(conn, packet)
 	}
-	return nil
+
+	return conn.ID()
+}
+
+// deadline 计算请求最晚开始执行时间,零值表示不限制
+func (p *processor) deadline(packet netx.Packet) time.Time {
+	if ident := packet.Identifier(); ident.IsResponse || ident.IsOneway {
+		return time.Time{}
+	}
+
+	timeout := netx.GetTimeout(packet.Header())
+	if max := p.opts.MaxQueueTime; max > 0 && (timeout == 0 || max < timeout) {
+		timeout = max
+	}
+	if timeout == 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(timeout)
 }
 
 func (p *processor) addTask(t *streamTask) {
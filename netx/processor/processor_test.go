@@ -0,0 +1,214 @@
+package processor
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// queueExecutor 仅保存任务,由测试控制执行时机
+type queueExecutor struct {
+	tasks []netx.Runnable
+}
+
+func (e *queueExecutor) Name() string { return "queue" }
+func (e *queueExecutor) Close() error { return nil }
+func (e *queueExecutor) Post(task netx.Runnable) error {
+	e.tasks = append(e.tasks, task)
+	return nil
+}
+
+type providerFunc func(pkt netx.Packet) netx.Callback
+
+func (f providerFunc) Find(pkt netx.Packet) netx.Callback { return f(pkt) }
+
+// sendConn 记录发送的消息
+type sendConn struct {
+	netx.Conn
+	sent []interface{}
+}
+
+func (c *sendConn) ID() uint32 { return 1 }
+func (c *sendConn) Send(msg interface{}) error {
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+func TestQueueDeadline(t *testing.T) {
+	exec := &queueExecutor{}
+	called := 0
+	p := New(exec, providerFunc(func(pkt netx.Packet) netx.Callback {
+		return func(conn netx.Conn, packet netx.Packet) error {
+			called++
+			return nil
+		}
+	}), WithMaxQueueTime(10*time.Millisecond))
+
+	conn := &sendConn{}
+	post := func(header netx.Header) {
+		ident := &netx.Identifier{SeqID: 7}
+		if err := p.Process(conn, netx.NewFrame(netx.FrameTypeHeader, true, 1, ident, header, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 使用MaxQueueTime
+	post(nil)
+	// header中的超时时间更短
+	h := netx.Header{}
+	netx.SetTimeout(&h, time.Millisecond)
+	post(h)
+	time.Sleep(20 * time.Millisecond)
+	// 未超时的请求正常执行
+	post(nil)
+
+	for _, task := range exec.tasks {
+		if err := task.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if called != 1 || len(conn.sent) != 2 {
+		t.Fatalf("expect 2 dropped, called=%d sent=%d", called, len(conn.sent))
+	}
+	for _, msg := range conn.sent {
+		rsp := msg.(netx.Response)
+		if rsp.StatusCode() != http.StatusServiceUnavailable || rsp.SeqID() != 7 {
+			t.Fatalf("bad drop response, %d %d", rsp.StatusCode(), rsp.SeqID())
+		}
+	}
+}
+
+// TestExecutorDrop 任务被Executor丢弃时返回503
+func TestExecutorDrop(t *testing.T) {
+	exec := &queueExecutor{}
+	called := 0
+	p := New(exec, providerFunc(func(pkt netx.Packet) netx.Callback {
+		return func(conn netx.Conn, packet netx.Packet) error {
+			called++
+			return nil
+		}
+	}))
+
+	conn := &sendConn{}
+	ident := &netx.Identifier{SeqID: 9}
+	if err := p.Process(conn, netx.NewFrame(netx.FrameTypeHeader, true, 1, ident, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	d, ok := exec.tasks[0].(netx.Dropper)
+	if !ok {
+		t.Fatal("task should implement Dropper")
+	}
+	if err := d.Drop(errors.New("queue full")); err != nil {
+		t.Fatal(err)
+	}
+	if called != 0 || len(conn.sent) != 1 {
+		t.Fatalf("expect dropped, called=%d sent=%d", called, len(conn.sent))
+	}
+	rsp := conn.sent[0].(netx.Response)
+	if rsp.StatusCode() != http.StatusServiceUnavailable || rsp.SeqID() != 9 {
+		t.Fatalf("bad drop response, %d %d", rsp.StatusCode(), rsp.SeqID())
+	}
+}
+
+// TestDropIgnored 应答和oneway请求被丢弃时不返回503
+func TestDropIgnored(t *testing.T) {
+	exec := &queueExecutor{}
+	p := New(exec, providerFunc(func(pkt netx.Packet) netx.Callback {
+		return func(conn netx.Conn, packet netx.Packet) error {
+			return nil
+		}
+	}))
+
+	conn := &sendConn{}
+	idents := []*netx.Identifier{{IsResponse: true}, {IsOneway: true}}
+	for i, ident := range idents {
+		// 分别生成simpleTask和streamTask
+		for _, end := range []bool{true, false} {
+			if err := p.Process(conn, netx.NewFrame(netx.FrameTypeHeader, end, uint32(i+1), ident, nil, nil)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, task := range exec.tasks {
+		if err := task.(netx.Dropper).Drop(errors.New("queue full")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(exec.tasks) != 4 || len(conn.sent) != 0 {
+		t.Fatalf("expect no drop response, tasks=%d sent=%d", len(exec.tasks), len(conn.sent))
+	}
+}
+
+// rejectExecutor 总是拒绝任务
+type rejectExecutor struct{}
+
+func (e *rejectExecutor) Name() string                  { return "reject" }
+func (e *rejectExecutor) Close() error                  { return nil }
+func (e *rejectExecutor) Post(task netx.Runnable) error { return errors.New("queue full") }
+
+// TestPostFail Executor拒绝任务时返回503,流式任务不再保留
+func TestPostFail(t *testing.T) {
+	p := New(&rejectExecutor{}, providerFunc(func(pkt netx.Packet) netx.Callback {
+		return func(conn netx.Conn, packet netx.Packet) error {
+			return nil
+		}
+	}))
+
+	conn := &sendConn{}
+	for i, end := range []bool{true, false} {
+		ident := &netx.Identifier{SeqID: uint32(i + 1)}
+		if err := p.Process(conn, netx.NewFrame(netx.FrameTypeHeader, end, uint32(i+1), ident, nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(p.(*processor).tasks); n != 0 || len(conn.sent) != 2 {
+		t.Fatalf("expect rejected, tasks=%d sent=%d", n, len(conn.sent))
+	}
+	for _, msg := range conn.sent {
+		if rsp := msg.(netx.Response); rsp.StatusCode() != http.StatusServiceUnavailable {
+			t.Fatalf("bad drop response, %d", rsp.StatusCode())
+		}
+	}
+}
+
+// TestStreamDeadline 流式请求排队超时后不执行handler,后续帧被丢弃
+func TestStreamDeadline(t *testing.T) {
+	exec := &queueExecutor{}
+	called := 0
+	p := New(exec, providerFunc(func(pkt netx.Packet) netx.Callback {
+		return func(conn netx.Conn, packet netx.Packet) error {
+			called++
+			return nil
+		}
+	}), WithMaxQueueTime(time.Millisecond))
+
+	conn := &sendConn{}
+	ident := &netx.Identifier{SeqID: 3}
+	if err := p.Process(conn, netx.NewFrame(netx.FrameTypeHeader, false, 1, ident, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := exec.tasks[0].Run(); err != nil {
+		t.Fatal(err)
+	}
+	buf := bytex.NewBuffer()
+	_ = buf.Append("data")
+	if err := p.Process(conn, netx.NewFrame(netx.FrameTypeData, true, 1, nil, nil, buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	if called != 0 || len(conn.sent) != 1 || len(p.(*processor).tasks) != 0 {
+		t.Fatalf("expect dropped, called=%d sent=%d", called, len(conn.sent))
+	}
+	if rsp := conn.sent[0].(netx.Response); rsp.StatusCode() != http.StatusServiceUnavailable || rsp.SeqID() != 3 {
+		t.Fatalf("bad drop response, %d %d", rsp.StatusCode(), rsp.SeqID())
+	}
+}
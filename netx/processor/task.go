@@ -1,10 +1,12 @@
 package processor
 
 import (
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
 )
 
 var gSimpleTaskPool = sync.Pool{
@@ -13,95 +15,139 @@ var gSimpleTaskPool = sync.Pool{
 	},
 }
 
-func newSimpleTask(conn netx.Conn, packet netx.Packet, callback netx.Callback) *simpleTask {
+func newSimpleTask(conn netx.Conn, packet netx.Packet, callback netx.Callback, deadline time.Time, key interface{}) *simpleTask {
 	t := gSimpleTaskPool.Get().(*simpleTask)
+	t.key = key
 	t.conn = conn
 	t.packet = packet
 	t.callback = callback
+	t.deadline = deadline
 	return t
 }
 
 type simpleTask struct {
+	key      interface{} // 用于按key有序执行
 	conn     netx.Conn
 	packet   netx.Packet
 	callback netx.Callback
+	deadline time.Time // 最晚开始执行时间,超过后直接丢弃
+}
+
+// Key 实现ordered.Keyed
+func (t *simpleTask) Key() interface{} {
+	return t.key
 }
 
 func (t *simpleTask) Run() error {
-	err := t.callback(t.conn, t.packet)
+	var err error
+	if !t.deadline.IsZero() && time.Now().After(t.deadline) {
+		err = t.drop("deadline exceeded")
+	} else {
+		err = t.callback(t.conn, t.packet)
+	}
 	gSimpleTaskPool.Put(t)
 	return err
 }
 
-var gStreamTaskPool = sync.Pool{
-	New: func() interface{} {
-		return &streamTask{}
-	},
+// Drop 实现netx.Dropper,被Executor丢弃时返回503
+func (t *simpleTask) Drop(err error) error {
+	err = t.drop(err.Error())
+	gSimpleTaskPool.Put(t)
+	return err
 }
 
-func newStreamTask(taskId uint64, owner *processor, conn netx.Conn, packet netx.Packet, callback netx.Callback) *streamTask {
-	t := gStreamTaskPool.Get().(*streamTask)
-	t.taskId = taskId
-	t.owner = owner
-	t.conn = conn
-	t.packet = packet
-	t.callback = callback
-	t.complete = false
-	t.running = false
-	t.lastTime = time.Now()
-	return t
+// drop 排队期间已经超时,调用方已经放弃等待,不再执行handler,直接返回503
+func (t *simpleTask) drop(info string) error {
+	gDropped.Inc()
+	return sendDrop(t.conn, t.packet, info)
+}
+
+// sendDrop 返回503,应答和oneway请求对端不会等待应答,直接忽略
+func sendDrop(conn netx.Conn, packet netx.Packet, info string) error {
+	ident := packet.Identifier()
+	if ident.IsResponse || ident.IsOneway {
+		return nil
+	}
+
+	rsp := netx.NewResponse()
+	rsp.SetSeqID(ident.SeqID)
+	rsp.SetCodec(ident.Codec)
+	rsp.SetStatus(http.StatusServiceUnavailable, info)
+	return conn.Send(rsp)
 }
 
+func newStreamTask(taskId uint64, conn netx.Conn, packet netx.Packet, callback netx.Callback, deadline time.Time, key interface{}) *streamTask {
+	w, _ := packet.Body().(body.Writer)
+	return &streamTask{taskId: taskId, key: key, conn: conn, packet: packet, writer: w, callback: callback, deadline: deadline}
+}
+
+// streamTask 流式请求,收到header后即执行handler,后续帧通过Write写入body
+//	handler中读取body会阻塞直到数据到达,因此handler只会执行一次
 type streamTask struct {
 	taskId   uint64
-	owner    *processor
+	key      interface{} // 用于按key有序执行
 	conn     netx.Conn
 	packet   netx.Packet
+	writer   body.Writer   // 创建时保存,handler中替换packet的body(例如缓存数据后放回)不影响后续帧写入
 	callback netx.Callback // 消息回调
-	complete bool          // 是否处理完成
-	running  bool          // 任务是否执行中
-	lastTime time.Time     // 上次触发时间
-	mux      sync.Mutex    //
+	deadline time.Time     // 最晚开始执行时间,超过后直接丢弃
 }
 
-func (t *streamTask) TryPost(complete bool) error {
-	t.mux.Lock()
-	running := t.running
-	t.running = true
-	t.complete = complete
-	t.lastTime = time.Now()
-	t.mux.Unlock()
-	if !running {
-		return t.owner.executor.Post(t)
+// Write 写入后续帧,handler已结束并关闭body时,丢弃剩余数据
+func (t *streamTask) Write(frame netx.Frame) error {
+	w := t.writer
+	if w == nil {
+		return nil
+	}
+
+	if payload := frame.Payload(); payload != nil && !payload.Empty() {
+		if err := w.Write(payload); err != nil && err != body.ErrClosed {
+			return err
+		}
+	}
+
+	if frame.Type() == netx.FrameTypeTrailer {
+		t.packet.SetTrailer(frame.Trailer())
+	}
+
+	if frame.EndFlag() {
+		w.Flush()
 	}
 
 	return nil
 }
 
+// Key 实现ordered.Keyed
+func (t *streamTask) Key() interface{} {
+	return t.key
+}
+
+// Drop 实现netx.Dropper,被Executor丢弃时关闭body并返回503
+func (t *streamTask) Drop(err error) error {
+	return t.drop(err.Error())
+}
+
+// drop 关闭body后返回503,后续帧写入时直接丢弃
+func (t *streamTask) drop(info string) error {
+	gDropped.Inc()
+	if bd := t.packet.Body(); bd != nil {
+		_ = bd.Close()
+	}
+
+	return sendDrop(t.conn, t.packet, info)
+}
+
 func (t *streamTask) Run() error {
-	// 有并发,需要保证一个消息同时只能在一个线程中执行,并且不能丢失消息
-	// 限制要求:handler允许多次执行
-	for {
-		err := t.callback(t.conn, t.packet)
-		t.mux.Lock()
-		if err != nil {
-			t.running = false
-			if !t.complete {
-				t.owner.deleteTask(t.taskId)
-			}
-			gStreamTaskPool.Put(t)
-			t.mux.Unlock()
-			return err
-		} else if t.packet.Body().End() {
-			t.running = false
-			if t.complete {
-				gStreamTaskPool.Put(t)
-			}
-			t.mux.Unlock()
-			break
-		} else {
-			t.mux.Unlock()
-		}
+	if !t.deadline.IsZero() && time.Now().After(t.deadline) {
+		return t.drop("deadline exceeded")
 	}
-	return nil
+
+	err := t.callback(t.conn, t.packet)
+	// handler未读完body,关闭后丢弃剩余数据,防止写入方阻塞
+	//	应答由调用方在回调返回后继续读取,需由调用方关闭
+	if bd := t.packet.Body(); bd != nil && !bd.End() && !t.packet.Identifier().IsResponse {
+		_ = bd.Close()
+	}
+
+	return err
 }
@@ -0,0 +1,43 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// TestStreamTask handler返回后,未读完的请求body被关闭,应答body由调用方继续读取
+func TestStreamTask(t *testing.T) {
+	newPayload := func() netx.Frame {
+		buf := bytex.NewBuffer()
+		_ = buf.Append("data")
+		return netx.NewFrame(netx.FrameTypeData, false, 1, nil, nil, buf)
+	}
+
+	for _, isResponse := range []bool{false, true} {
+		packet := netx.NewPacket()
+		packet.SetIdentifier(&netx.Identifier{IsResponse: isResponse})
+		packet.SetBody(body.NewStreamBody(nil))
+		task := newStreamTask(1, nil, packet, func(conn netx.Conn, packet netx.Packet) error {
+			return nil
+		}, time.Time{}, nil)
+		if err := task.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if err := task.Write(newPayload()); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := packet.Body().ReadFast(false)
+		if isResponse {
+			if err != nil || data.String() != "data" {
+				t.Fatalf("response body should be readable, %v %v", data, err)
+			}
+		} else if err != body.ErrClosed {
+			t.Fatalf("request body should be closed, %v", err)
+		}
+	}
+}
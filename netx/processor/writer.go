@@ -6,14 +6,94 @@ import (
 	"github.com/foredata/nova/netx"
 )
 
-type packetWriter struct {
-	proto netx.Protocol
+func newStreamWriter(proto netx.Protocol, conn netx.Conn, packet netx.Packet) *streamWriter {
+	return &streamWriter{proto: proto, conn: conn, packet: packet}
 }
 
-func (pw *packetWriter) Close() error {
+// streamWriter 分块发送消息,在写协程中执行,按body数据产生的顺序依次编码发送
+//	首帧为header并携带首个数据块,后续为data帧,最后以trailer帧或结束data帧结尾
+//	body未结束前会阻塞写协程,因此仅适用于应答有序的协议,例如http1
+type streamWriter struct {
+	proto  netx.Protocol
+	conn   netx.Conn
+	packet netx.Packet
+}
+
+func (sw *streamWriter) Close() error {
+	if bd := sw.packet.Body(); bd != nil {
+		return bd.Close()
+	}
+
 	return nil
 }
 
-func (pw *packetWriter) WriteTo(w io.Writer) (n int64, err error) {
-	return 0, nil
+func (sw *streamWriter) WriteTo(w io.Writer) (int64, error) {
+	bd := sw.packet.Body()
+	header := sw.packet.Header()
+	if header == nil {
+		header = netx.NewHeader()
+	}
+
+	first, err := bd.ReadFast(true)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	if err == io.EOF {
+		// 数据已经全部产生,不需要分块
+		header.Merge(sw.packet.Trailer())
+		return sw.write(w, netx.NewFrame(netx.FrameTypeHeader, true, 0, sw.packet.Identifier(), header, first))
+	}
+
+	total, err := sw.write(w, netx.NewFrame(netx.FrameTypeHeader, false, 0, sw.packet.Identifier(), header, first))
+	if err != nil {
+		return total, err
+	}
+
+	for {
+		data, err := bd.ReadFast(true)
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+		end := err == io.EOF
+		if data != nil && !data.Empty() {
+			// 数据帧携带Identifier,用于协议按需压缩
+			n, err := sw.write(w, netx.NewFrame(netx.FrameTypeData, false, 0, sw.packet.Identifier(), nil, data))
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+
+		if end {
+			break
+		}
+	}
+
+	var last netx.Frame
+	if trailer := sw.packet.Trailer(); len(trailer) > 0 {
+		last = netx.NewFrame(netx.FrameTypeTrailer, true, 0, nil, nil, nil)
+		last.SetTrailer(trailer)
+	} else {
+		last = netx.NewFrame(netx.FrameTypeData, true, 0, nil, nil, nil)
+	}
+
+	n, err := sw.write(w, last)
+	return total + n, err
+}
+
+func (sw *streamWriter) write(w io.Writer, frame netx.Frame) (int64, error) {
+	if payload := frame.Payload(); payload != nil {
+		_, _ = payload.Seek(0, io.SeekStart)
+	}
+
+	buf, err := sw.proto.Encode(sw.conn, frame)
+	if err != nil {
+		return 0, err
+	}
+
+	_, _ = buf.Seek(0, io.SeekStart)
+	n, err := buf.WriteTo(w)
+	_ = buf.Close()
+	return n, err
 }
@@ -21,11 +21,13 @@ var (
 	errInvaidHttpHeader   = errors.New("invalid http header")
 	errLineTooLong        = errors.New("header line too long")
 	errNoContentLength    = errors.New("no content length")
+	errInvalidChunk       = errors.New("invalid chunk")
 )
 
 const (
 	headerSize    = 8
-	maxLineLength = 4096 // assumed <= bufio.defaultBufSize
+	maxLineLength = 4096    // assumed <= bufio.defaultBufSize
+	maxBufferBody = 1 << 20 // Content-Length超过该值时按流式传输,避免缓存完整body
 )
 
 type state uint8
@@ -36,6 +38,7 @@ const (
 	stateBody                 // 读取普通消息体
 	stateChunk                // 读取分块数据
 	stateTrailer              // 读取trailer
+	stateStream               // 按Content-Length流式读取消息体
 )
 
 func newDecoder() *decoder {
@@ -99,6 +102,8 @@ func (d *decoder) Decode(buf bytex.Buffer) (netx.Frame, error) {
 			return d.parseChunk(buf)
 		case stateTrailer:
 			return d.parseTrailer(buf)
+		case stateStream:
+			return d.parseStream(buf)
 		default:
 			panic("invalid state")
 		}
@@ -142,7 +147,7 @@ func (d *decoder) parseIdent(buf bytex.Buffer) error {
 	} else {
 		// parse response identify
 		// HTTP/1.1 200 OK
-		major, minor, ok := http.ParseHTTPVersion(s3)
+		major, minor, ok := http.ParseHTTPVersion(s1)
 		if !ok {
 			return errInvalidHttpVersion
 		}
@@ -161,7 +166,7 @@ func (d *decoder) parseIdent(buf bytex.Buffer) error {
 	}
 
 	d.state = stateHeader
-	d.header = make(netx.Header, headerSize)
+	d.header = make(netx.Header, 0, headerSize)
 	return nil
 }
 
@@ -181,18 +186,14 @@ func (d *decoder) parseHeader(buf bytex.Buffer) error {
 		}
 
 		kv := line.Bytes()
-		if err := setHeader(d.header, kv); err != nil {
+		if err := setHeader(&d.header, kv); err != nil {
 			return err
 		}
 	}
 }
 
+// parseTrailer 读取trailer直到空行,未声明Trailer时也需要读取结尾的空行
 func (d *decoder) parseTrailer(buf bytex.Buffer) (netx.Frame, error) {
-	if len(d.trailer) == 0 {
-		return d.newEndFrame(netx.FrameTypeData, nil, nil, nil), nil
-	}
-
-	//
 	for {
 		line, err := d.readContinuedLine(buf)
 		if err != nil {
@@ -202,11 +203,17 @@ func (d *decoder) parseTrailer(buf bytex.Buffer) (netx.Frame, error) {
 			return nil, io.EOF
 		}
 		if line.Len() == 0 {
-			return d.newEndFrame(netx.FrameTypeTrailer, nil, d.trailer, nil), nil
+			if len(d.trailer) == 0 {
+				return d.newEndFrame(netx.FrameTypeData, nil, nil, nil), nil
+			}
+			trailer := d.trailer
+			f := d.newEndFrame(netx.FrameTypeTrailer, nil, nil, nil)
+			f.SetTrailer(trailer)
+			return f, nil
 		}
 
 		kv := line.Bytes()
-		if err := setHeader(d.trailer, kv); err != nil {
+		if err := setHeader(&d.trailer, kv); err != nil {
 			return nil, err
 		}
 	}
@@ -221,6 +228,9 @@ func (d *decoder) parseBody(buf bytex.Buffer) (netx.Frame, error) {
 		return netx.NewFrame(netx.FrameTypeHeader, false, d.streamId, d.ident, d.header, nil), nil
 	case d.length == 0:
 		return d.newEndFrame(netx.FrameTypeHeader, d.ident, d.header, nil), nil
+	case d.length > maxBufferBody:
+		d.state = stateStream
+		return netx.NewFrame(netx.FrameTypeHeader, false, d.streamId, d.ident, d.header, nil), nil
 	case d.length > 0:
 		payload := buf.ReadN(int(d.length))
 		if payload == nil {
@@ -262,12 +272,36 @@ func (d *decoder) parseChunk(buf bytex.Buffer) (netx.Frame, error) {
 		}
 	}
 
+	// chunk数据及结尾的CRLF
+	if buf.Available() < int(d.length)+2 {
+		return nil, io.EOF
+	}
+
 	chunk := buf.ReadN(int(d.length))
-	if chunk != nil {
-		return netx.NewFrame(netx.FrameTypeData, false, d.streamId, nil, nil, chunk), nil
+	if crlf := buf.ReadN(2); crlf == nil || crlf.String() != "\r\n" {
+		return nil, errInvalidChunk
+	}
+	d.length = -1
+	return netx.NewFrame(netx.FrameTypeData, false, d.streamId, nil, nil, chunk), nil
+}
+
+// parseStream 按Content-Length流式读取,每次返回当前已接收的数据
+func (d *decoder) parseStream(buf bytex.Buffer) (netx.Frame, error) {
+	n := buf.Available()
+	if n == 0 {
+		return nil, io.EOF
+	}
+	if int64(n) > d.length {
+		n = int(d.length)
+	}
+
+	payload := buf.ReadN(n)
+	d.length -= int64(n)
+	if d.length == 0 {
+		return d.newEndFrame(netx.FrameTypeData, nil, nil, payload), nil
 	}
 
-	return nil, io.EOF
+	return netx.NewFrame(netx.FrameTypeData, false, d.streamId, nil, nil, payload), nil
 }
 
 // readContinuedLine 读取多行value
@@ -630,7 +664,7 @@ func parseHexUint(v []byte) (n uint64, err error) {
 	return
 }
 
-func setHeader(header netx.Header, kv []byte) error {
+func setHeader(header *netx.Header, kv []byte) error {
 	// Key ends at first colon.
 	i := bytes.IndexByte(kv, ':')
 	if i < 0 {
@@ -103,3 +103,124 @@ func TestEncode(t *testing.T) {
 		fmt.Printf("%s\n", b.String())
 	}
 }
+
+func TestChunked(t *testing.T) {
+	ident := &netx.Identifier{IsResponse: true}
+	enc := encoder{}
+	out := bytex.NewBuffer()
+	frames := []netx.Frame{
+		netx.NewFrame(netx.FrameTypeHeader, false, 0, ident, netx.NewHeader(), newBuffer("hello ")),
+		netx.NewFrame(netx.FrameTypeData, false, 0, nil, nil, newBuffer("world")),
+		netx.NewFrame(netx.FrameTypeData, true, 0, nil, nil, nil),
+	}
+	for _, f := range frames {
+		buf, err := enc.Encode(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = out.Append(buf.String())
+	}
+
+	text := out.String()
+	if !strings.Contains(text, "Transfer-Encoding: chunked") || strings.Contains(text, "Content-Length") || !strings.HasSuffix(text, "6\r\nhello \r\n5\r\nworld\r\n0\r\n\r\n") {
+		t.Fatalf("invalid chunked encode, %q", text)
+	}
+
+	_, _ = out.Seek(0, io.SeekStart)
+	dec := newDecoder()
+	var data string
+	for i := 0; i < 10; i++ {
+		f, err := dec.Decode(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := f.Payload(); p != nil {
+			data += p.String()
+		}
+		if f.EndFlag() {
+			break
+		}
+	}
+	if data != "hello world" {
+		t.Fatalf("invalid chunked decode, %q", data)
+	}
+}
+
+func TestEncodeReset(t *testing.T) {
+	ident := &netx.Identifier{IsResponse: true}
+	enc := encoder{}
+
+	// 已知长度的分块应答异常中断,未发送结束帧
+	header := netx.NewHeader()
+	header.Set(HeaderContentLength, "10")
+	if _, err := enc.Encode(netx.NewFrame(netx.FrameTypeHeader, false, 0, ident, header, newBuffer("hello"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新的chunked应答不能沿用identity状态
+	out := bytex.NewBuffer()
+	frames := []netx.Frame{
+		netx.NewFrame(netx.FrameTypeHeader, false, 0, ident, netx.NewHeader(), nil),
+		netx.NewFrame(netx.FrameTypeData, true, 0, nil, nil, newBuffer("world")),
+	}
+	for _, f := range frames {
+		buf, err := enc.Encode(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = out.Append(buf.String())
+	}
+	if text := out.String(); !strings.HasSuffix(text, "5\r\nworld\r\n0\r\n\r\n") {
+		t.Fatalf("invalid chunked encode, %q", text)
+	}
+}
+
+// attrConn 仅支持Attributes,用于保存decoder状态
+type attrConn struct {
+	netx.Conn
+	attrs netx.AttributeMap
+}
+
+func (c *attrConn) Attributes() netx.AttributeMap {
+	return c.attrs
+}
+
+// TestDecodeSplit pipeline请求在任意位置被拆分到多次读取
+func TestDecodeSplit(t *testing.T) {
+	var data []byte
+	for i := 0; i < 3; i++ {
+		data = append(data, fmt.Sprintf("POST /seq HTTP/1.1\r\nHost: test\r\nX-Seq: %d\r\nContent-Length: 3\r\n\r\nabc", i)...)
+	}
+
+	p := New()
+	for a := 1; a < len(data); a++ {
+		for b := a; b < len(data); b += 7 {
+			conn := &attrConn{attrs: netx.NewAttributeMap()}
+			buf := bytex.NewBuffer()
+			var seqs []string
+			// 与transport一致,追加数据后从头开始解析
+			feed := func(d []byte) {
+				_, _ = buf.Seek(0, io.SeekEnd)
+				_ = buf.Append(d)
+				_, _ = buf.Seek(0, io.SeekStart)
+				for {
+					f, err := p.Decode(conn, buf)
+					if err != nil {
+						t.Fatalf("split %d,%d decode fail, %+v", a, b, err)
+					}
+					if f == nil {
+						return
+					}
+					buf.Discard()
+					seqs = append(seqs, f.Header().Get("X-Seq"))
+				}
+			}
+			feed(data[:a])
+			feed(data[a:b])
+			feed(data[b:])
+			if strings.Join(seqs, ",") != "0,1,2" {
+				t.Fatalf("split %d,%d bad frames, %v", a, b, seqs)
+			}
+		}
+	}
+}
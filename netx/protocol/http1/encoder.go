@@ -1,15 +1,20 @@
 package http1
 
 import (
+	"io"
 	"strconv"
 
 	"github.com/foredata/nova/netx"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
+// encoder http1编码,分块发送且已指定Content-Length时(例如文件),后续数据帧不使用chunked编码
+//	分块发送只会在连接的写协程中执行,状态不需要加锁
 // https://cloud.google.com/apigee/docs/api-platform/antipatterns/multi-value-http-headers
 // https://stackoverflow.com/questions/3096888/standard-for-adding-multiple-values-of-a-single-http-header-to-a-request-or-resp/38406581
 type encoder struct {
+	identity bool // 当前分块发送的消息不使用chunked编码
+	raw      bool // 只编码header,消息体由调用方原样发送,用于零拷贝发送文件
 }
 
 func (e *encoder) Encode(frame netx.Frame) (bytex.Buffer, error) {
@@ -31,6 +36,8 @@ func (e *encoder) writeHeader(frame netx.Frame) (bytex.Buffer, error) {
 	if ident == nil {
 		return nil, netx.ErrInvalidFrame
 	}
+	// 新的消息,重置上一个分块消息的状态,避免异常中断后影响后续消息
+	e.identity = false
 	version := toHttpVersion(ident.Version)
 	header := frame.Header()
 	payload := frame.Payload()
@@ -41,9 +48,22 @@ func (e *encoder) writeHeader(frame netx.Frame) (bytex.Buffer, error) {
 		header.Set(HeaderContentType, contentType)
 	}
 
-	if !frame.EndFlag() {
+	chunked := false
+	switch {
+	case !bodyAllowed(ident):
+		// 1xx,204,304不允许携带body
+		payload = nil
+	case e.raw:
+		// Content-Length已由调用方设置
+		payload = nil
+	case !frame.EndFlag() && header.Get(HeaderContentLength) != "" && header.Get(HeaderTransferEncoding) == "":
+		// 已知长度,直接发送原始数据
+		e.identity = true
+	case !frame.EndFlag():
+		chunked = true
+		header.Del(HeaderContentLength)
 		header.Set(HeaderTransferEncoding, transferEncodingChunked)
-	} else {
+	default:
 		contentLen := 0
 		if payload != nil && !payload.Empty() {
 			contentLen = payload.Len()
@@ -70,39 +90,74 @@ func (e *encoder) writeHeader(frame netx.Frame) (bytex.Buffer, error) {
 	_ = bytex.Write(buf, kCRLF)
 
 	// write payload
-	if payload != nil {
+	switch {
+	case payload == nil || payload.Empty():
+	case chunked:
+		_ = bytex.Writef(buf, "%x\r\n", payload.Len())
+		_ = buf.Append(payload)
+		_ = bytex.Write(buf, kCRLF)
+	default:
 		_ = buf.Append(payload)
 	}
 
 	return buf, nil
 }
 
+// writeTrailer 结束分块传输,并写入trailer
 func (e *encoder) writeTrailer(frame netx.Frame) (bytex.Buffer, error) {
 	buf := bytex.NewBuffer()
-	trailer := frame.Trailer()
-	if len(trailer) == 0 {
-		return nil, nil
+	if e.identity {
+		// 非chunked编码无法携带trailer
+		e.identity = false
+		return buf, nil
 	}
-
-	writeHeader(buf, trailer)
+	_ = bytex.Writef(buf, "0\r\n")
+	writeHeader(buf, frame.Trailer())
 	_ = bytex.Write(buf, kCRLF)
 
 	return buf, nil
 }
 
+// writeData 写入一个分块,EndFlag为true时同时写入结束块,非chunked编码时直接写入数据
 func (e *encoder) writeData(frame netx.Frame) (bytex.Buffer, error) {
 	payload := frame.Payload()
 
 	buf := bytex.NewBuffer()
+	if e.identity {
+		if payload != nil && payload.Len() > 0 {
+			_, _ = payload.Seek(0, io.SeekStart)
+			_ = buf.Append(payload)
+		}
+		if frame.EndFlag() {
+			e.identity = false
+		}
+		return buf, nil
+	}
+
 	if payload != nil && payload.Len() > 0 {
-		_ = bytex.Writef(buf, "")
+		_, _ = payload.Seek(0, io.SeekStart)
+		_ = bytex.Writef(buf, "%x\r\n", payload.Len())
 		_ = buf.Append(payload)
+		_ = bytex.Write(buf, kCRLF)
+	}
+
+	if frame.EndFlag() {
+		_ = bytex.Writef(buf, "0\r\n\r\n")
 	}
-	_ = bytex.Write(buf, kCRLF)
 
 	return buf, nil
 }
 
+// bodyAllowed 应答状态码为1xx,204,304时不允许携带body
+func bodyAllowed(ident *netx.Identifier) bool {
+	if !ident.IsResponse {
+		return true
+	}
+
+	code := ident.StatusCode
+	return !(code >= 100 && code < 200) && code != 204 && code != 304
+}
+
 func writeHeader(buf bytex.Buffer, header netx.Header) {
 	header.Walk(func(key string, values []string) bool {
 		for _, v := range values {
@@ -0,0 +1,145 @@
+package http1
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+)
+
+// FileChunkSize 发送文件时每次读取的大小
+var FileChunkSize = 64 * 1024
+
+// NewFileResponse 创建发送文件的应答,支持单个Range及If-Range,多个Range时返回完整文件
+//	文件内容由连接的写协程按FileChunkSize分块读取发送,并携带Content-Length
+//	文件不存在或为目录时返回404错误
+func NewFileResponse(req netx.Request, filename string) (netx.Response, error) {
+	fi, err := os.Stat(filename)
+	if err != nil || fi.IsDir() {
+		return nil, netx.NewError(http.StatusNotFound, "", "file not found, %s", filename)
+	}
+
+	size := fi.Size()
+	modtime := fi.ModTime().UTC().Truncate(time.Second)
+	etag := fmt.Sprintf(`"%x-%x"`, modtime.Unix(), size)
+
+	rsp := netx.NewResponse()
+	rsp.SetVersion(req.Version())
+	header := netx.NewHeader()
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Last-Modified", modtime.Format(http.TimeFormat))
+	header.Set("ETag", etag)
+	ctype := mime.TypeByExtension(filepath.Ext(filename))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	header.Set(HeaderContentType, ctype)
+
+	offset, length := int64(0), size
+	rangeHeader := getHeader(req.Header(), "Range")
+	if rangeHeader != "" && checkIfRange(getHeader(req.Header(), "If-Range"), etag, modtime) {
+		start, end, ok := parseRange(rangeHeader, size)
+		switch {
+		case !ok:
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			rsp.SetStatus(http.StatusRequestedRangeNotSatisfiable, "")
+			rsp.SetHeader(header)
+			return rsp, nil
+		case start >= 0:
+			offset, length = start, end-start+1
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+			rsp.SetStatus(http.StatusPartialContent, "")
+		}
+	}
+
+	header.Set(HeaderContentLength, strconv.FormatInt(length, 10))
+	rsp.SetHeader(header)
+	if req.Method() == netx.MethodHead || length == 0 {
+		return rsp, nil
+	}
+
+	bd, err := body.NewFileRangeBody(filename, offset, length, FileChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	rsp.SetBody(bd)
+	return rsp, nil
+}
+
+// checkIfRange If-Range匹配时Range才有效,否则返回完整文件
+func checkIfRange(ifRange string, etag string, modtime time.Time) bool {
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, `W/"`) {
+		// 只允许强校验
+		return ifRange == etag
+	}
+
+	t, err := http.ParseTime(ifRange)
+	return err == nil && t.Equal(modtime)
+}
+
+// parseRange 解析Range头,返回闭区间[start, end],start为-1表示忽略Range,返回完整文件
+//	ok为false表示范围无法满足
+func parseRange(s string, size int64) (start int64, end int64, ok bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return -1, -1, true
+	}
+
+	spec := strings.TrimSpace(s[len(prefix):])
+	if strings.Contains(spec, ",") {
+		// 不支持multipart/byteranges
+		return -1, -1, true
+	}
+
+	idx := strings.IndexByte(spec, '-')
+	if idx == -1 {
+		return -1, -1, true
+	}
+
+	first, last := strings.TrimSpace(spec[:idx]), strings.TrimSpace(spec[idx+1:])
+	if first == "" {
+		// bytes=-N 表示最后N个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return -1, -1, true
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return -1, -1, true
+	}
+	if start >= size {
+		return 0, 0, false
+	}
+
+	end = size - 1
+	if last != "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < start {
+			return -1, -1, true
+		}
+		if n < end {
+			end = n
+		}
+	}
+
+	return start, end, true
+}
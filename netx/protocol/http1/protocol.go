@@ -2,6 +2,7 @@ package http1
 
 import (
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/foredata/nova/netx"
@@ -19,6 +20,7 @@ func New() netx.Protocol {
 var (
 	// kConnKeyHttpDecoder conn中unique key
 	kConnKeyHttpDecoder = unique.NewKey(netx.KeyGroupConn, "http1-decoder")
+	kConnKeyHttpEncoder = unique.NewKey(netx.KeyGroupConn, "http1-encoder")
 )
 
 // 实现protocol.x,h1不支持多路复用,解析有状态
@@ -69,11 +71,39 @@ func (*http1Protocol) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, erro
 	if err == io.EOF {
 		err = nil
 	}
+	if frame == nil && err == nil {
+		// 已解析的行保存在decoder中,需要丢弃,否则下次读取时会从头重新解析
+		buf.Discard()
+	}
 
 	return frame, err
 }
 
+// Encode 分块发送时需要记录编码状态,每个Conn一个encoder,conn为nil时只能使用chunked编码
 func (*http1Protocol) Encode(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
-	enc := encoder{}
+	if conn == nil {
+		enc := encoder{}
+		return enc.Encode(frame)
+	}
+
+	enc := conn.Attributes().Get(kConnKeyHttpEncoder, func() interface{} {
+		return &encoder{}
+	}).(*encoder)
 	return enc.Encode(frame)
 }
+
+// EncodeHeader 实现netx.RawEncoder,设置Content-Length后只编码header,消息体由调用方原样发送
+func (*http1Protocol) EncodeHeader(conn netx.Conn, frame netx.Frame, size int64) (bytex.Buffer, bool, error) {
+	ident := frame.Identifier()
+	if ident == nil || !bodyAllowed(ident) {
+		return nil, false, nil
+	}
+
+	header := frame.Header()
+	header.Del(HeaderTransferEncoding)
+	header.Set(HeaderContentLength, strconv.FormatInt(size, 10))
+	frame.SetHeader(header)
+	enc := encoder{raw: true}
+	buf, err := enc.writeHeader(frame)
+	return buf, err == nil, err
+}
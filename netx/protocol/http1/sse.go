@@ -0,0 +1,232 @@
+package http1
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Event Server-Sent Events事件
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+type Event struct {
+	ID    string        // 事件ID,客户端重连时通过Last-Event-ID携带
+	Event string        // 事件类型,为空时客户端触发message事件
+	Data  string        // 数据,包含多行时拆分为多个data字段
+	Retry time.Duration // 客户端重连间隔,0表示不设置
+}
+
+func (e *Event) encode(b *strings.Builder) {
+	if e.ID != "" {
+		writeField(b, "id", e.ID)
+	}
+	if e.Event != "" {
+		writeField(b, "event", e.Event)
+	}
+	if e.Retry > 0 {
+		writeField(b, "retry", strconv.FormatInt(int64(e.Retry/time.Millisecond), 10))
+	}
+	data := strings.ReplaceAll(e.Data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		writeField(b, "data", line)
+	}
+	b.WriteByte('\n')
+}
+
+// gFieldReplacer 字段值中不允许出现换行
+var gFieldReplacer = strings.NewReplacer("\r", "", "\n", "")
+
+func writeField(b *strings.Builder, name, value string) {
+	b.WriteString(name)
+	b.WriteString(": ")
+	b.WriteString(gFieldReplacer.Replace(value))
+	b.WriteByte('\n')
+}
+
+// SSEOptions EventStream配置
+type SSEOptions struct {
+	Heartbeat time.Duration // 心跳间隔,用于保活及探测客户端断开,0表示不发送
+	Retry     time.Duration // 客户端重连间隔,0表示使用客户端默认值
+	History   *EventLog     // 历史事件,客户端携带Last-Event-ID重连时补发
+}
+
+type SSEOption func(o *SSEOptions)
+
+// WithHeartbeat 设置心跳间隔
+func WithHeartbeat(d time.Duration) SSEOption {
+	return func(o *SSEOptions) {
+		o.Heartbeat = d
+	}
+}
+
+// WithRetry 设置客户端重连间隔
+func WithRetry(d time.Duration) SSEOption {
+	return func(o *SSEOptions) {
+		o.Retry = d
+	}
+}
+
+// WithHistory 设置历史事件,用于断线续传
+func WithHistory(l *EventLog) SSEOption {
+	return func(o *SSEOptions) {
+		o.History = l
+	}
+}
+
+// NewEventStream 创建SSE应答并立即发送应答头,conn通常在handler中通过server.Hijack获取
+//	设置History且请求携带Last-Event-ID时,会先补发之后的历史事件
+//	发送受限于连接发送速度,客户端接收过慢时Send会阻塞
+func NewEventStream(conn netx.Conn, req netx.Request, opts ...SSEOption) (*EventStream, error) {
+	o := &SSEOptions{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	w := NewResponseWriter(conn, req)
+	w.SetHeader(HeaderContentType, "text/event-stream")
+	w.SetHeader("Cache-Control", "no-cache")
+	w.SetHeader("X-Accel-Buffering", "no")
+
+	s := &EventStream{w: w, lastID: getHeader(req.Header(), "Last-Event-ID"), quit: make(chan struct{})}
+	if o.Retry > 0 {
+		_, _ = w.WriteString("retry: " + strconv.FormatInt(int64(o.Retry/time.Millisecond), 10) + "\n\n")
+	}
+	if o.History != nil && s.lastID != "" {
+		for _, ev := range o.History.Since(s.lastID) {
+			s.write(ev)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		s.shutdown()
+		return nil, err
+	}
+
+	if o.Heartbeat > 0 {
+		go s.heartbeat(o.Heartbeat)
+	}
+
+	return s, nil
+}
+
+// EventStream Server-Sent Events应答,线程安全
+type EventStream struct {
+	w      *ResponseWriter
+	lastID string
+	quit   chan struct{}
+	once   sync.Once
+}
+
+// LastEventID 客户端重连时携带的最后接收的事件ID
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Done 应答结束或客户端断开时关闭
+func (s *EventStream) Done() <-chan struct{} {
+	return s.quit
+}
+
+// Send 发送事件,客户端断开时返回错误
+func (s *EventStream) Send(ev *Event) error {
+	s.write(ev)
+	return s.flush()
+}
+
+// Comment 发送注释,客户端会忽略
+func (s *EventStream) Comment(text string) error {
+	_, _ = s.w.WriteString(": " + gFieldReplacer.Replace(text) + "\n\n")
+	return s.flush()
+}
+
+// Close 结束应答
+func (s *EventStream) Close() error {
+	s.shutdown()
+	return s.w.Close()
+}
+
+func (s *EventStream) write(ev *Event) {
+	var b strings.Builder
+	ev.encode(&b)
+	_, _ = s.w.WriteString(b.String())
+}
+
+func (s *EventStream) flush() error {
+	err := s.w.Flush()
+	if err != nil {
+		s.shutdown()
+	}
+
+	return err
+}
+
+func (s *EventStream) shutdown() {
+	s.once.Do(func() {
+		close(s.quit)
+	})
+}
+
+func (s *EventStream) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+			if s.Comment("ping") != nil {
+				return
+			}
+		}
+	}
+}
+
+// NewEventLog 创建EventLog,最多保存size个事件
+func NewEventLog(size int) *EventLog {
+	return &EventLog{size: size}
+}
+
+// EventLog 保存最近的事件,用于客户端断线重连后补发,线程安全
+//	通常多个EventStream共享一个EventLog,发送前先调用Append记录
+type EventLog struct {
+	mux    sync.Mutex
+	size   int
+	events []*Event
+	lastID uint64
+}
+
+// Append 记录事件,ID为空时自动分配递增ID
+func (l *EventLog) Append(ev *Event) *Event {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if ev.ID == "" {
+		l.lastID++
+		ev.ID = strconv.FormatUint(l.lastID, 10)
+	}
+	l.events = append(l.events, ev)
+	if len(l.events) > l.size {
+		l.events[0] = nil
+		l.events = l.events[1:]
+	}
+
+	return ev
+}
+
+// Since 返回id之后的事件,id已经不在记录中时返回全部事件
+func (l *EventLog) Since(id string) []*Event {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	idx := 0
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if l.events[i].ID == id {
+			idx = i + 1
+			break
+		}
+	}
+
+	res := make([]*Event, len(l.events)-idx)
+	copy(res, l.events[idx:])
+	return res
+}
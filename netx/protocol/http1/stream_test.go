@@ -0,0 +1,306 @@
+package http1_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/http1"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/transport/gpc"
+	"github.com/foredata/nova/netx/transport/memory"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// pipeFilter 将收到的原始数据写入pipe,使用net/http解析应答
+type pipeFilter struct {
+	netx.BaseFilter
+	w *io.PipeWriter
+}
+
+func (f *pipeFilter) Name() string {
+	return "pipe"
+}
+
+func (f *pipeFilter) HandleRead(ctx netx.FilterCtx) error {
+	buf, ok := ctx.Data().(bytex.Buffer)
+	if !ok {
+		return nil
+	}
+	data := make([]byte, buf.Len())
+	n, _ := buf.Read(data)
+	buf.Discard()
+	_, _ = f.w.Write(data[:n])
+	return nil
+}
+
+func (f *pipeFilter) HandleClose(ctx netx.FilterCtx) error {
+	_ = f.w.Close()
+	return nil
+}
+
+type rawClient struct {
+	t    *testing.T
+	conn netx.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, name string) *rawClient {
+	pr, pw := io.Pipe()
+	tran := memory.New()
+	tran.AddFilters(&pipeFilter{w: pw})
+	conn, err := tran.Dial(memtest.Addr(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = pr.Close()
+	})
+
+	return &rawClient{t: t, conn: conn, r: bufio.NewReader(pr)}
+}
+
+func (c *rawClient) do(req string) *http.Response {
+	c.t.Helper()
+	buf := bytex.NewBuffer()
+	_ = buf.Append(strings.ReplaceAll(strings.TrimPrefix(req, "\n"), "\n", "\r\n"))
+	_, _ = buf.Seek(0, io.SeekStart)
+	if err := c.conn.Send(buf); err != nil {
+		c.t.Fatal(err)
+	}
+
+	rsp, err := http.ReadResponse(c.r, nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return rsp
+}
+
+func readBody(t *testing.T, rsp *http.Response) string {
+	t.Helper()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestResponseWriter(t *testing.T) {
+	svr := memtest.NewServer(t, "http1.writer")
+	svr.GET("/stream", func(ctx context.Context, req netx.Request) error {
+		w := http1.NewResponseWriter(server.Hijack(ctx), req)
+		w.SetHeader("X-Stream", "1")
+		w.SetHeader("Trailer", "X-Sum")
+		for _, s := range []string{"hello", " ", "world"} {
+			_, _ = w.WriteString(s)
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		w.SetTrailer("X-Sum", "3")
+		return w.Close()
+	})
+	svr.GET("/small", func(ctx context.Context, req netx.Request) error {
+		w := http1.NewResponseWriter(server.Hijack(ctx), req)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.WriteString("small")
+		return w.Close()
+	})
+
+	c := dial(t, "http1.writer")
+	rsp := c.do("GET /stream HTTP/1.1\nHost: test\n\n")
+	if len(rsp.TransferEncoding) != 1 || rsp.TransferEncoding[0] != "chunked" || rsp.Header.Get("X-Stream") != "1" {
+		t.Fatalf("bad stream response, %+v", rsp)
+	}
+	if body := readBody(t, rsp); body != "hello world" {
+		t.Errorf("bad stream body, %s", body)
+	}
+	if rsp.Trailer.Get("X-Sum") != "3" {
+		t.Errorf("bad trailer, %+v", rsp.Trailer)
+	}
+
+	// 同一连接上继续请求
+	rsp = c.do("GET /small HTTP/1.1\nHost: test\n\n")
+	if rsp.StatusCode != http.StatusCreated || rsp.ContentLength != 5 || len(rsp.TransferEncoding) != 0 {
+		t.Fatalf("bad small response, %+v", rsp)
+	}
+	if body := readBody(t, rsp); body != "small" {
+		t.Errorf("bad small body, %s", body)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	log := http1.NewEventLog(2)
+	for _, data := range []string{"a", "b", "c"} {
+		log.Append(&http1.Event{Data: data})
+	}
+
+	done := make(chan struct{})
+	svr := memtest.NewServer(t, "http1.sse")
+	svr.GET("/events", func(ctx context.Context, req netx.Request) error {
+		s, err := http1.NewEventStream(server.Hijack(ctx), req, http1.WithRetry(time.Second), http1.WithHeartbeat(10*time.Millisecond), http1.WithHistory(log))
+		if err != nil {
+			return err
+		}
+		if s.LastEventID() != "2" {
+			t.Errorf("bad last event id, %s", s.LastEventID())
+		}
+		_ = s.Send(&http1.Event{ID: "4", Event: "update", Data: "x\ny"})
+		<-s.Done()
+		close(done)
+		return s.Close()
+	})
+
+	c := dial(t, "http1.sse")
+	rsp := c.do("GET /events HTTP/1.1\nHost: test\nlast-event-id: 2\n\n")
+	if rsp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("bad content type, %+v", rsp.Header)
+	}
+
+	r := bufio.NewReader(rsp.Body)
+	var lines []string
+	for len(lines) < 9 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	expect := []string{"retry: 1000", "", "id: 3", "data: c", "", "id: 4", "event: update", "data: x", "data: y"}
+	if strings.Join(lines, "|") != strings.Join(expect, "|") {
+		t.Fatalf("bad events, %q", lines)
+	}
+
+	// 心跳
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == ": ping\n" {
+			break
+		}
+	}
+
+	// 客户端断开后,心跳发送失败,结束应答
+	_ = c.conn.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed")
+	}
+}
+
+func TestFileResponse(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 20000)
+	filename := filepath.Join(t.TempDir(), "data.txt")
+	if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	svr := memtest.NewServer(t, "http1.file")
+	svr.GET("/file", func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return http1.NewFileResponse(req, filename)
+	})
+	svr.GET("/missing", func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return http1.NewFileResponse(req, filename+".none")
+	})
+
+	c := dial(t, "http1.file")
+	rsp := c.do("GET /file HTTP/1.1\nHost: test\n\n")
+	if rsp.StatusCode != http.StatusOK || rsp.ContentLength != int64(len(content)) || len(rsp.TransferEncoding) != 0 {
+		t.Fatalf("bad file response, %+v", rsp)
+	}
+	if body := readBody(t, rsp); body != string(content) {
+		t.Fatalf("bad file body, %d", len(body))
+	}
+	etag := rsp.Header.Get("ETag")
+	if etag == "" || rsp.Header.Get("Accept-Ranges") != "bytes" || rsp.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Fatalf("bad file header, %+v", rsp.Header)
+	}
+
+	for _, tc := range []struct {
+		header string
+		status int
+		body   string
+		crange string
+	}{
+		{"Range: bytes=2-5", http.StatusPartialContent, "2345", "bytes 2-5/200000"},
+		{"Range: bytes=-3", http.StatusPartialContent, "789", "bytes 199997-199999/200000"},
+		{"Range: bytes=199998-300000", http.StatusPartialContent, "89", "bytes 199998-199999/200000"},
+		{"Range: bytes=200000-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */200000"},
+		{"Range: bytes=0-1,4-5", http.StatusOK, string(content), ""},
+		{"Range: bytes=1-2\nIf-Range: " + etag, http.StatusPartialContent, "12", "bytes 1-2/200000"},
+		{"Range: bytes=1-2\nIf-Range: \"other\"", http.StatusOK, string(content), ""},
+		{"Range: bytes=1-2\nIf-Range: " + rsp.Header.Get("Last-Modified"), http.StatusPartialContent, "12", "bytes 1-2/200000"},
+	} {
+		rsp := c.do("GET /file HTTP/1.1\nHost: test\n" + tc.header + "\n\n")
+		body := readBody(t, rsp)
+		if rsp.StatusCode != tc.status || body != tc.body || rsp.Header.Get("Content-Range") != tc.crange {
+			t.Errorf("bad range response, %s, %d, %d, %s", tc.header, rsp.StatusCode, len(body), rsp.Header.Get("Content-Range"))
+		}
+	}
+
+	rsp = c.do("GET /missing HTTP/1.1\nHost: test\n\n")
+	if rsp.StatusCode != http.StatusNotFound {
+		t.Errorf("bad missing response, %d", rsp.StatusCode)
+	}
+	readBody(t, rsp)
+}
+
+// TestFileResponseTCP 通过tcp连接发送文件,使用sendfile零拷贝
+func TestFileResponseTCP(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100000)
+	filename := filepath.Join(t.TempDir(), "data.bin")
+	if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	svr := server.New(server.WithAddr("127.0.0.1:0"), server.WithTranFactory(gpc.New))
+	s := svr.(interface {
+		Start() error
+		Stop() error
+		Addr() net.Addr
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	svr.GET("/file", func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return http1.NewFileResponse(req, filename)
+	})
+	addr := s.Addr().String()
+
+	for _, tc := range []struct {
+		rng  string
+		body []byte
+	}{
+		{"", content},
+		{"bytes=1000-599999", content[1000:600000]},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/file", nil)
+		if tc.rng != "" {
+			req.Header.Set("Range", tc.rng)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(rsp.Body)
+		_ = rsp.Body.Close()
+		if err != nil || rsp.ContentLength != int64(len(tc.body)) || !bytes.Equal(data, tc.body) {
+			t.Fatalf("bad file response, range=%s, status=%d, len=%d, err=%v", tc.rng, rsp.StatusCode, len(data), err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package http1
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// MaxPending 流式应答中等待发送数据的上限,超过后Write会阻塞直到数据发送到网络
+var MaxPending = 64 * 1024
+
+// NewResponseWriter 创建流式应答,conn为请求所在连接,通常在handler中通过server.Hijack获取
+//	首次Flush时发送应答头,使用chunked编码,之后每次Flush发送一个chunk,Close时结束应答
+//	未Flush直接Close时,作为普通应答发送,会携带Content-Length
+//	发送由连接的写协程完成,同一连接上后续请求的应答会等待本应答结束后才发送
+func NewResponseWriter(conn netx.Conn, req netx.Request) *ResponseWriter {
+	w := &ResponseWriter{conn: conn, req: req, header: netx.NewHeader(), status: http.StatusOK}
+	return w
+}
+
+// ResponseWriter 流式应答,线程安全
+//	mux保护状态,wmux保证Flush/Close按顺序写入body,写入可能阻塞,不能持有mux
+type ResponseWriter struct {
+	mux     sync.Mutex
+	wmux    sync.Mutex
+	conn    netx.Conn
+	req     netx.Request
+	header  netx.Header  // 应答头,发送后修改无效
+	trailer netx.Header  // 结束时发送
+	status  int          // 状态码
+	buf     bytex.Buffer // 尚未Flush的数据
+	rsp     netx.Response
+	body    netx.Body // 应答头发送后有效
+	closed  bool
+}
+
+// SetHeader 设置应答头,应答头发送后调用无效
+func (w *ResponseWriter) SetHeader(key, value string) {
+	w.mux.Lock()
+	w.header.Set(key, value)
+	w.mux.Unlock()
+}
+
+// SetTrailer 设置trailer,Close时发送,需要在应答头发送前通过Trailer头声明
+func (w *ResponseWriter) SetTrailer(key, value string) {
+	w.mux.Lock()
+	if w.trailer == nil {
+		w.trailer = netx.NewHeader()
+	}
+	w.trailer.Set(key, value)
+	w.mux.Unlock()
+}
+
+// WriteHeader 设置状态码,应答头发送后调用无效
+func (w *ResponseWriter) WriteHeader(code int) {
+	w.mux.Lock()
+	w.status = code
+	w.mux.Unlock()
+}
+
+// Written 应答头是否已经发送
+func (w *ResponseWriter) Written() bool {
+	w.mux.Lock()
+	written := w.rsp != nil
+	w.mux.Unlock()
+	return written
+}
+
+// Write 写入数据,Flush前只缓存在本地
+func (w *ResponseWriter) Write(p []byte) (int, error) {
+	return w.WriteString(string(p))
+}
+
+// WriteString 写入字符串,同Write
+func (w *ResponseWriter) WriteString(s string) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if err := w.check(); err != nil {
+		return 0, err
+	}
+	if w.buf == nil {
+		w.buf = bytex.NewBuffer()
+	}
+	if err := w.buf.Append(s); err != nil {
+		return 0, err
+	}
+
+	return len(s), nil
+}
+
+// Flush 发送应答头及已缓存的数据,连接断开时返回netx.ErrConnClosed
+//	等待发送的数据超过MaxPending时会阻塞,阻塞期间不影响Write等其他调用
+func (w *ResponseWriter) Flush() error {
+	w.wmux.Lock()
+	defer w.wmux.Unlock()
+
+	w.mux.Lock()
+	if err := w.check(); err != nil {
+		w.mux.Unlock()
+		return err
+	}
+
+	if w.rsp == nil {
+		if err := w.writeHeader(); err != nil {
+			w.mux.Unlock()
+			return err
+		}
+	}
+	buf := w.buf
+	w.buf = nil
+	w.mux.Unlock()
+
+	return w.flush(buf)
+}
+
+// Close 结束应答,未发送应答头时作为普通应答发送
+func (w *ResponseWriter) Close() error {
+	w.wmux.Lock()
+	defer w.wmux.Unlock()
+
+	w.mux.Lock()
+	if w.closed {
+		w.mux.Unlock()
+		return nil
+	}
+	w.closed = true
+
+	if w.rsp == nil {
+		rsp := w.newResponse()
+		if w.buf != nil && !w.buf.Empty() && w.allowBody() {
+			_, _ = w.buf.Seek(0, io.SeekStart)
+			rsp.SetBody(body.NewBufferBody(w.buf))
+		}
+		w.buf = nil
+		rsp.SetTrailer(w.trailer)
+		w.rsp = rsp
+		w.mux.Unlock()
+		return w.conn.Send(rsp)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(w.trailer) > 0 {
+		w.rsp.SetTrailer(w.trailer)
+	}
+	w.mux.Unlock()
+
+	err := w.flush(buf)
+	if bw, ok := w.body.(body.Writer); ok {
+		bw.Flush()
+	}
+
+	return err
+}
+
+func (w *ResponseWriter) check() error {
+	if w.closed {
+		return netx.ErrClosed
+	}
+	if w.conn.Status() == netx.CLOSED {
+		// 释放阻塞在body上的写协程
+		if w.body != nil {
+			_ = w.body.Close()
+		}
+		return netx.ErrConnClosed
+	}
+
+	return nil
+}
+
+func (w *ResponseWriter) newResponse() netx.Response {
+	rsp := netx.NewResponse()
+	rsp.SetVersion(w.req.Version())
+	rsp.SetSeqID(w.req.SeqID())
+	rsp.SetStatus(int32(w.status), "")
+	rsp.SetHeader(w.header)
+	return rsp
+}
+
+// writeHeader 发送应答头,body由写协程按Flush的顺序依次发送
+func (w *ResponseWriter) writeHeader() error {
+	rsp := w.newResponse()
+	if w.allowBody() {
+		w.body = body.NewLimitedStreamBody(MaxPending)
+		rsp.SetBody(w.body)
+	}
+	w.rsp = rsp
+	return w.conn.Send(rsp)
+}
+
+// flush 写入body,需要持有wmux,不能持有mux
+func (w *ResponseWriter) flush(buf bytex.Buffer) error {
+	if w.body == nil || buf == nil || buf.Empty() {
+		return nil
+	}
+
+	_, _ = buf.Seek(0, io.SeekStart)
+	if err := w.body.(body.Writer).Write(buf); err != nil {
+		// 写协程发送失败后会关闭body
+		return netx.ErrConnClosed
+	}
+
+	return nil
+}
+
+// allowBody HEAD请求及1xx,204,304应答不允许携带body
+func (w *ResponseWriter) allowBody() bool {
+	if w.req.Method() == netx.MethodHead {
+		return false
+	}
+
+	return !(w.status >= 100 && w.status < 200) && w.status != http.StatusNoContent && w.status != http.StatusNotModified
+}
+
+// getHeader 请求头未做规范化,需要忽略大小写查找
+func getHeader(h netx.Header, key string) string {
+	for _, kv := range h {
+		if strings.EqualFold(kv.Key, key) && len(kv.Values) > 0 {
+			return kv.Values[0]
+		}
+	}
+
+	return ""
+}
@@ -11,6 +11,8 @@ func New() netx.Protocol {
 
 var gHttp2Protocol = &http2Protocol{}
 
+const framePing = 0x6
+
 type http2Protocol struct {
 }
 
@@ -29,3 +31,11 @@ func (http2Protocol) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error
 func (http2Protocol) Encode(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
 	return nil, nil
 }
+
+// Ping 实现netx.Pinger,发送PING帧,https://httpwg.org/specs/rfc7540.html#PING
+//	帧头9字节:Length(24)=8,Type(8)=0x6,Flags(8)=0,StreamID(32)=0,之后为8字节数据
+func (http2Protocol) Ping(conn netx.Conn) error {
+	buf := bytex.NewBuffer()
+	_ = buf.Append([]byte{0, 0, 8, framePing, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	return conn.Send(buf)
+}
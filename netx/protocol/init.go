@@ -3,14 +3,19 @@ package protocol
 import (
 	"github.com/foredata/nova/netx"
 	"github.com/foredata/nova/netx/protocol/http1"
+	"github.com/foredata/nova/netx/protocol/resp"
 	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/protocol/theader"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
 func init() {
-	// 注册已知协议
+	// 注册已知协议,按注册顺序探测
+	// theader需要完整的8字节头部且magic在第二个字中,放在最后,不会影响rpc和http1的探测
 	Register(rpc.New())
 	Register(http1.New())
+	Register(theader.New())
+	Register(resp.New())
 
 	SetDefault(http1.New())
 }
@@ -0,0 +1,217 @@
+// Package websocket 服务端websocket帧解析,供基于websocket升级的协议使用
+//	仅实现服务端,不支持扩展(permessage-deflate等)
+//	https://datatracker.ietf.org/doc/html/rfc6455
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// some error
+var (
+	ErrProtocol        = errors.New("websocket: protocol error")
+	ErrUnmasked        = errors.New("websocket: client frame not masked")
+	ErrMessageTooLarge = errors.New("websocket: message too large")
+	ErrNotUpgrade      = errors.New("websocket: not websocket upgrade request")
+	ErrVersion         = errors.New("websocket: unsupported version")
+	ErrNoKey           = errors.New("websocket: no websocket key")
+)
+
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// opcode
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xa
+)
+
+// CheckUpgrade 校验升级请求,返回Sec-WebSocket-Key
+func CheckUpgrade(h netx.Header) (string, error) {
+	if !strings.EqualFold(GetHeader(h, "Upgrade"), "websocket") || !strings.Contains(strings.ToLower(GetHeader(h, "Connection")), "upgrade") {
+		return "", ErrNotUpgrade
+	}
+	if GetHeader(h, "Sec-WebSocket-Version") != "13" {
+		return "", ErrVersion
+	}
+	key := GetHeader(h, "Sec-WebSocket-Key")
+	if key == "" {
+		return "", ErrNoKey
+	}
+
+	return key, nil
+}
+
+// NewUpgradeResponse 创建101应答,subprotocol为空时不返回Sec-WebSocket-Protocol
+func NewUpgradeResponse(seqID uint32, key string, subprotocol string) netx.Response {
+	rsp := netx.NewResponse()
+	rsp.SetSeqID(seqID)
+	rsp.SetStatus(http.StatusSwitchingProtocols, "")
+	h := netx.NewHeader()
+	h.Set("Upgrade", "websocket")
+	h.Set("Connection", "Upgrade")
+	h.Set("Sec-WebSocket-Accept", AcceptKey(key))
+	if subprotocol != "" {
+		h.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	rsp.SetHeader(h)
+	return rsp
+}
+
+// AcceptKey 计算Sec-WebSocket-Accept
+func AcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + guid))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// GetHeader 忽略大小写查询header
+func GetHeader(h netx.Header, key string) string {
+	for _, kv := range h {
+		if strings.EqualFold(kv.Key, key) && len(kv.Values) > 0 {
+			return kv.Values[0]
+		}
+	}
+
+	return ""
+}
+
+// Reader 读取完整消息,自动应答ping和close,支持分片消息
+type Reader struct {
+	MaxSize int    // 消息最大长度
+	frag    []byte // 未完成的分片消息
+	inFrag  bool   //
+}
+
+// Read 读取一个完整的文本或二进制消息,数据不足时返回nil,conn为nil时不应答控制帧
+func (r *Reader) Read(conn netx.Conn, buf bytex.Buffer) ([]byte, error) {
+	for {
+		var head [14]byte
+		n := buf.Available()
+		if n > len(head) {
+			n = len(head)
+		}
+		if n < 2 {
+			return nil, nil
+		}
+
+		_, _ = buf.Peek(head[:n])
+		fin := head[0]&0x80 != 0
+		opcode := head[0] & 0x0f
+		if head[0]&0x70 != 0 {
+			return nil, ErrProtocol
+		}
+		if head[1]&0x80 == 0 {
+			return nil, ErrUnmasked
+		}
+
+		size := uint64(head[1] & 0x7f)
+		hsize := 2
+		switch size {
+		case 126:
+			hsize = 4
+		case 127:
+			hsize = 10
+		}
+		if n < hsize+4 {
+			return nil, nil
+		}
+		switch size {
+		case 126:
+			size = uint64(binary.BigEndian.Uint16(head[2:]))
+		case 127:
+			size = binary.BigEndian.Uint64(head[2:])
+		}
+		if opcode >= OpClose && (size > 125 || !fin) {
+			return nil, ErrProtocol
+		}
+		if size > uint64(r.MaxSize) {
+			return nil, ErrMessageTooLarge
+		}
+
+		total := hsize + 4 + int(size)
+		if buf.Available() < total {
+			return nil, nil
+		}
+		frame := buf.ReadN(total).Bytes()
+		mask := frame[hsize : hsize+4]
+		payload := make([]byte, size)
+		for i, c := range frame[hsize+4:] {
+			payload[i] = c ^ mask[i%4]
+		}
+
+		switch opcode {
+		case OpClose:
+			// 回复close后关闭连接
+			if conn != nil {
+				_ = conn.Send(NewFrame(OpClose, payload))
+				_ = conn.Close()
+			}
+			return nil, nil
+		case OpPing:
+			if conn != nil {
+				_ = conn.Send(NewFrame(OpPong, payload))
+			}
+		case OpPong:
+		case OpText, OpBinary:
+			if r.inFrag {
+				return nil, ErrProtocol
+			}
+			if fin {
+				return payload, nil
+			}
+			r.frag, r.inFrag = payload, true
+		case OpContinuation:
+			if !r.inFrag {
+				return nil, ErrProtocol
+			}
+			if len(r.frag)+len(payload) > r.MaxSize {
+				return nil, ErrMessageTooLarge
+			}
+			r.frag = append(r.frag, payload...)
+			if fin {
+				data := r.frag
+				r.frag, r.inFrag = nil, false
+				return data, nil
+			}
+		default:
+			return nil, ErrProtocol
+		}
+	}
+}
+
+// NewFrame 创建服务端帧,服务端发送的帧不需要mask
+func NewFrame(opcode byte, data []byte) bytex.Buffer {
+	head := make([]byte, 2, 10)
+	head[0] = 0x80 | opcode
+	switch n := len(data); {
+	case n <= 125:
+		head[1] = byte(n)
+	case n <= 0xffff:
+		head[1] = 126
+		head = append(head, 0, 0)
+		binary.BigEndian.PutUint16(head[2:], uint16(n))
+	default:
+		head[1] = 127
+		head = append(head, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(head[2:], uint64(n))
+	}
+
+	buf := bytex.NewBuffer()
+	_ = buf.Append(head)
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	return buf
+}
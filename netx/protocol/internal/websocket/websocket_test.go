@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"io"
+	"testing"
+
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+func appendFrame(buf bytex.Buffer, head byte, data []byte) {
+	mask := []byte{1, 2, 3, 4}
+	_ = buf.Append([]byte{head, 0x80 | byte(len(data))})
+	_ = buf.Append(mask)
+	masked := make([]byte, len(data))
+	for i, c := range data {
+		masked[i] = c ^ mask[i%4]
+	}
+	_ = buf.Append(masked)
+}
+
+func TestReader(t *testing.T) {
+	payload := []byte(`{"jsonrpc":"2.0","method":"add","id":1}`)
+	buf := bytex.NewBuffer()
+	// 分为两个分片发送,中间插入ping
+	appendFrame(buf, OpText, payload[:10])
+	appendFrame(buf, 0x80|OpPing, nil)
+	appendFrame(buf, 0x80|OpContinuation, payload[10:])
+	// 小于14字节的帧
+	appendFrame(buf, 0x80|OpBinary, []byte("hi"))
+	_, _ = buf.Seek(0, io.SeekStart)
+
+	r := &Reader{MaxSize: 1024}
+	data, err := r.Read(nil, buf)
+	if err != nil || string(data) != string(payload) {
+		t.Errorf("bad message, %s, %+v", data, err)
+	}
+	data, err = r.Read(nil, buf)
+	if err != nil || string(data) != "hi" {
+		t.Errorf("bad message, %s, %+v", data, err)
+	}
+	if data, err := r.Read(nil, buf); data != nil || err != nil {
+		t.Errorf("should be incomplete")
+	}
+
+	unmasked := bytex.NewBuffer()
+	_ = unmasked.Append([]byte{0x80 | OpText, 1, 'a'})
+	_, _ = unmasked.Seek(0, io.SeekStart)
+	if _, err := r.Read(nil, unmasked); err != ErrUnmasked {
+		t.Errorf("should be unmasked, %+v", err)
+	}
+}
+
+func TestFrame(t *testing.T) {
+	for _, size := range []int{10, 200, 70000} {
+		out := NewFrame(OpBinary, make([]byte, size)).Bytes()
+		if out[0] != 0x80|OpBinary {
+			t.Errorf("bad opcode")
+		}
+		switch {
+		case size <= 125 && int(out[1]) != size:
+			t.Errorf("bad size, %d", out[1])
+		case size > 125 && size <= 0xffff && (out[1] != 126 || len(out) != size+4):
+			t.Errorf("bad size, %d", len(out))
+		case size > 0xffff && (out[1] != 127 || len(out) != size+10):
+			t.Errorf("bad size, %d", len(out))
+		}
+	}
+
+	if AcceptKey("dGhlIHNhbXBsZSBub25jZQ==") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("bad accept key")
+	}
+}
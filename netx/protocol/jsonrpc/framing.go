@@ -0,0 +1,177 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/internal/websocket"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// Framing TCP上的消息分隔方式
+type Framing uint8
+
+const (
+	FramingAuto      Framing = iota // 服务端根据首个消息自动识别,客户端使用FramingLine
+	FramingLine                     // 每行一个消息,消息需为紧凑格式,不能包含换行
+	FramingHeader                   // 消息前使用Content-Length头标识长度,同LSP
+	framingWebSocket                // websocket升级后使用,每个websocket消息为一个JSON-RPC消息
+)
+
+const (
+	headerContentLength = "Content-Length"
+	maxHeaderSize       = 4096
+)
+
+var headerEnd = []byte("\r\n\r\n")
+
+// framer 从字节流中拆分出完整消息,以及将消息封装成字节流
+type framer interface {
+	// read 读取一个完整消息,数据不足时返回nil
+	read(conn netx.Conn, buf bytex.Buffer) ([]byte, error)
+	// write 封装一个完整消息
+	write(data []byte) bytex.Buffer
+}
+
+func newFramer(f Framing, maxSize int) framer {
+	switch f {
+	case FramingHeader:
+		return &headerFramer{maxSize: maxSize}
+	case framingWebSocket:
+		return &wsFramer{reader: websocket.Reader{MaxSize: maxSize}}
+	default:
+		return &lineFramer{maxSize: maxSize}
+	}
+}
+
+// detectFraming 根据首个非空白字符识别分隔方式
+func detectFraming(buf bytex.Buffer) (Framing, bool) {
+	text := bytes.TrimLeft(peekN(buf, 16), " \t\r\n")
+	if len(text) == 0 {
+		return FramingAuto, false
+	}
+
+	switch text[0] {
+	case '{', '[':
+		return FramingLine, true
+	case 'C', 'c':
+		return FramingHeader, true
+	}
+
+	return FramingAuto, false
+}
+
+// peekN 预读最多n个字节,数据不足n时返回全部数据
+func peekN(peeker bytex.Peeker, n int) []byte {
+	if b, ok := peeker.(interface{ Available() int }); ok && b.Available() < n {
+		n = b.Available()
+	}
+	data := make([]byte, n)
+	if m, _ := peeker.Peek(data); m < n {
+		return nil
+	}
+	return data
+}
+
+func newPayload(data []byte) bytex.Buffer {
+	buf := bytex.NewBuffer()
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	return buf
+}
+
+// lineFramer 以换行分隔消息,忽略空行
+type lineFramer struct {
+	maxSize int
+}
+
+func (f *lineFramer) read(conn netx.Conn, buf bytex.Buffer) ([]byte, error) {
+	for {
+		idx := buf.IndexByte('\n', 0)
+		if idx == -1 {
+			if buf.Available() > f.maxSize {
+				return nil, ErrMessageTooLarge
+			}
+			return nil, nil
+		}
+		if idx > f.maxSize {
+			return nil, ErrMessageTooLarge
+		}
+
+		// 拷贝一份,buffer中的数据discard后会被复用
+		line := bytes.TrimSpace(buf.ReadN(idx + 1).Bytes())
+		if len(line) > 0 {
+			return append([]byte(nil), line...), nil
+		}
+	}
+}
+
+func (f *lineFramer) write(data []byte) bytex.Buffer {
+	buf := bytex.NewBuffer()
+	_ = buf.Append(data)
+	_ = buf.Append([]byte{'\n'})
+	return buf
+}
+
+// headerFramer 使用Content-Length头分隔消息,忽略其他头
+//	Content-Length: 42\r\n
+//	\r\n
+//	{"jsonrpc":"2.0",...}
+type headerFramer struct {
+	maxSize int
+}
+
+func (f *headerFramer) read(conn netx.Conn, buf bytex.Buffer) ([]byte, error) {
+	size := buf.Available()
+	if size > maxHeaderSize {
+		size = maxHeaderSize
+	}
+	peek := make([]byte, size)
+	n, _ := buf.Peek(peek)
+	idx := bytes.Index(peek[:n], headerEnd)
+	if idx == -1 {
+		if n >= maxHeaderSize {
+			return nil, ErrInvalidHeader
+		}
+		return nil, nil
+	}
+
+	length := -1
+	for _, line := range strings.Split(string(peek[:idx]), "\r\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidHeader
+		}
+		if strings.EqualFold(strings.TrimSpace(kv[0]), headerContentLength) {
+			v, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil || v < 0 {
+				return nil, ErrInvalidHeader
+			}
+			length = v
+		}
+	}
+	if length == -1 {
+		return nil, ErrInvalidHeader
+	}
+	if length > f.maxSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	total := idx + len(headerEnd) + length
+	if buf.Available() < total {
+		return nil, nil
+	}
+
+	data := buf.ReadN(total).Bytes()
+	return append([]byte(nil), data[idx+len(headerEnd):]...), nil
+}
+
+func (f *headerFramer) write(data []byte) bytex.Buffer {
+	buf := bytex.NewBuffer()
+	_ = bytex.Writef(buf, "%s: %d\r\n\r\n", headerContentLength, len(data))
+	_ = buf.Append(data)
+	return buf
+}
@@ -0,0 +1,150 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/server"
+)
+
+// ServeHTTP 处理HTTP POST请求,body为单个或批量JSON-RPC请求,按method查找路由并并发执行
+//	全部为通知时返回204
+//	s.POST("/rpc", jsonrpc.ServeHTTP)
+func ServeHTTP(ctx context.Context, req netx.Request) (netx.Response, error) {
+	router := server.GetRouter(ctx)
+	if router == nil {
+		return nil, netx.NewError(http.StatusInternalServerError, "", "jsonrpc: no router in context")
+	}
+
+	data, err := readBody(req.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	var result []byte
+	msgs, isBatch, err := parse(data)
+	switch {
+	case err != nil:
+		result = newErrorReply(nil, CodeParseError, "")
+	case isBatch && len(msgs) == 0:
+		result = newErrorReply(nil, CodeInvalidRequest, "")
+	default:
+		replies := make([][]byte, len(msgs))
+		wg := sync.WaitGroup{}
+		for i, m := range msgs {
+			wg.Add(1)
+			go func(i int, m *message) {
+				defer wg.Done()
+				replies[i] = dispatch(server.GetConn(ctx), router, req.Header(), m)
+			}(i, m)
+		}
+		wg.Wait()
+
+		// 通知没有应答
+		items := replies[:0]
+		for _, r := range replies {
+			if r != nil {
+				items = append(items, r)
+			}
+		}
+		switch {
+		case len(items) == 0:
+			rsp := netx.NewResponse()
+			rsp.SetStatus(http.StatusNoContent, "")
+			return rsp, nil
+		case isBatch:
+			result = joinBatch(items)
+		default:
+			result = items[0]
+		}
+	}
+
+	rsp := netx.NewResponse()
+	rsp.SetCodec(uint32(netx.CodecTypeJson))
+	rsp.SetBody(body.NewBufferBody(newPayload(result)))
+	return rsp, nil
+}
+
+func readBody(bod netx.Body) ([]byte, error) {
+	if bod == nil {
+		return nil, nil
+	}
+
+	if buf, err := bod.Buffer(); err == nil {
+		if buf == nil {
+			return nil, nil
+		}
+		return buf.Bytes(), nil
+	}
+
+	return ioutil.ReadAll(bod)
+}
+
+// dispatch 执行单个请求,通知返回nil
+func dispatch(conn netx.Conn, router netx.Router, header netx.Header, m *message) []byte {
+	if m == nil || !m.isRequest() || !m.validate() {
+		var id json.RawMessage
+		if m != nil {
+			id = m.ID
+		}
+		return newErrorReply(id, CodeInvalidRequest, "")
+	}
+
+	notify := m.isNotify()
+	req := netx.NewRequest()
+	req.SetURI(m.Method)
+	req.SetCodec(uint32(netx.CodecTypeJson))
+	req.SetOneway(notify)
+	req.SetHeader(header)
+	if len(m.Params) > 0 {
+		req.SetBody(body.NewBufferBody(newPayload(m.Params)))
+	}
+
+	cb := router.Find(req.(netx.Packet))
+	if cb == nil {
+		if notify {
+			return nil
+		}
+		return newErrorReply(m.ID, CodeMethodNotFound, "")
+	}
+
+	rc := &replyConn{Conn: conn}
+	err := cb(rc, req.(netx.Packet))
+	if notify {
+		return nil
+	}
+
+	rsp := rc.rsp
+	if rsp == nil {
+		if err != nil {
+			return newErrorReply(m.ID, CodeInternalError, err.Error())
+		}
+		return marshalReply(m.ID, &message{Result: nullID})
+	}
+
+	payload, err := readBody(rsp.Body())
+	if err != nil {
+		return newErrorReply(m.ID, CodeInternalError, err.Error())
+	}
+	return newReply(m.ID, rsp.(netx.Packet).Identifier(), payload)
+}
+
+// replyConn 截获handler发送的应答,其他操作转发给原始连接
+type replyConn struct {
+	netx.Conn
+	rsp netx.Response
+}
+
+func (c *replyConn) Send(msg interface{}) error {
+	if rsp, ok := msg.(netx.Response); ok {
+		c.rsp = rsp
+		return nil
+	}
+
+	return c.Conn.Send(msg)
+}
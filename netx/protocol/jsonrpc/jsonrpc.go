@@ -0,0 +1,209 @@
+// Package jsonrpc JSON-RPC 2.0协议,见https://www.jsonrpc.org/specification
+//	支持以下几种传输方式,均通过method查找Route.Name相同的路由:
+//	1: TCP,每行一个消息(FramingLine),或使用Content-Length头分隔(FramingHeader,同LSP),服务端自动探测
+//	2: HTTP POST,使用ServeHTTP作为handler
+//	3: WebSocket,使用ServeWebSocket处理升级请求,之后每个websocket消息为一个JSON-RPC消息
+//	params作为请求body,result作为应答body,均使用json编码
+//	不含id的通知映射为MsgTypeOneway,批量请求会在所有应答完成后合并返回
+//	TCP和WebSocket方式下未找到路由时由server处理,可注册NoRoute并返回netx.NotFound映射为-32601
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/foredata/nova/netx"
+)
+
+// Version 协议版本
+const Version = "2.0"
+
+// 标准错误码,-32000到-32099为服务端自定义错误
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// some error
+var (
+	ErrMessageTooLarge = errors.New("jsonrpc: message too large")
+	ErrInvalidHeader   = errors.New("jsonrpc: invalid header")
+	ErrInvalidID       = errors.New("jsonrpc: invalid response id")
+)
+
+// NewError 创建JSON-RPC错误,handler返回后code和message会原样填入应答的error对象
+func NewError(code int, message string) netx.Error {
+	return netx.NewError(code, message, "%s", message)
+}
+
+// message JSON-RPC消息,请求和应答共用
+//	id不存在时为nil,为null时为"null",用于区分通知与id为null的请求
+type message struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *errorObject    `json:"error,omitempty"`
+}
+
+// errorObject 应答中的error对象
+type errorObject struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+var nullID = json.RawMessage("null")
+
+// isRequest 不含result和error的消息均按请求处理,缺少method时为非法请求
+func (m *message) isRequest() bool {
+	return m.Method != "" || (m.Result == nil && m.Error == nil)
+}
+
+func (m *message) isNotify() bool {
+	return m.ID == nil
+}
+
+// validate 校验请求格式,params只能为object或array,id只能为string,number或null
+func (m *message) validate() bool {
+	if m.Version != Version || m.Method == "" {
+		return false
+	}
+	if len(m.Params) > 0 && m.Params[0] != '{' && m.Params[0] != '[' {
+		return false
+	}
+	if len(m.ID) > 0 && m.ID[0] != '"' && m.ID[0] != '-' && (m.ID[0] < '0' || m.ID[0] > '9') && !bytes.Equal(m.ID, nullID) {
+		return false
+	}
+
+	return true
+}
+
+// parse 解析单个或批量消息,批量消息中格式错误的元素为nil
+//	整体无法解析时返回error,空数组为非法请求
+func parse(data []byte) (msgs []*message, batch bool, err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		m := &message{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, false, err
+		}
+		return []*message{m}, false, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, true, err
+	}
+	msgs = make([]*message, len(items))
+	for i, item := range items {
+		m := &message{}
+		if json.Unmarshal(item, m) == nil {
+			msgs[i] = m
+		}
+	}
+
+	return msgs, true, nil
+}
+
+// toErrorObject 将应答状态转换为error对象,非JSON-RPC错误码按http状态码映射
+func toErrorObject(code int32, info string, data []byte) *errorObject {
+	e := &errorObject{Code: int(code), Message: info}
+	if code > 0 {
+		switch code {
+		case http.StatusBadRequest:
+			e.Code = CodeInvalidParams
+		case http.StatusNotFound:
+			e.Code = CodeMethodNotFound
+		default:
+			e.Code = CodeInternalError
+		}
+	}
+	if e.Message == "" {
+		e.Message = errorText(e.Code)
+	}
+	if len(data) > 0 && json.Valid(data) {
+		e.Data = data
+	}
+
+	return e
+}
+
+func errorText(code int) string {
+	switch code {
+	case CodeParseError:
+		return "Parse error"
+	case CodeInvalidRequest:
+		return "Invalid Request"
+	case CodeMethodNotFound:
+		return "Method not found"
+	case CodeInvalidParams:
+		return "Invalid params"
+	case CodeInternalError:
+		return "Internal error"
+	}
+
+	return "Server error"
+}
+
+// newErrorReply 创建错误应答,id为nil时使用null
+func newErrorReply(id json.RawMessage, code int, info string) []byte {
+	if info == "" {
+		info = errorText(code)
+	}
+
+	return marshalReply(id, &message{Error: &errorObject{Code: code, Message: info}})
+}
+
+// newReply 根据应答状态创建应答,成功时payload为result,失败时payload为error.data
+func newReply(id json.RawMessage, ident *netx.Identifier, payload []byte) []byte {
+	if ident.MsgType() == netx.MsgTypeException {
+		return marshalReply(id, &message{Error: toErrorObject(ident.StatusCode, ident.StatusInfo, payload)})
+	}
+
+	if len(bytes.TrimSpace(payload)) == 0 {
+		payload = nullID
+	} else if !json.Valid(payload) {
+		return newErrorReply(id, CodeInternalError, "result is not json")
+	}
+
+	return marshalReply(id, &message{Result: payload})
+}
+
+func marshalReply(id json.RawMessage, m *message) []byte {
+	m.Version = Version
+	m.ID = id
+	if m.ID == nil {
+		m.ID = nullID
+	}
+	data, _ := json.Marshal(m)
+	return data
+}
+
+// joinBatch 合并批量应答
+func joinBatch(items [][]byte) []byte {
+	data := []byte{'['}
+	data = append(data, bytes.Join(items, []byte{','})...)
+	return append(data, ']')
+}
+
+func strconvU32(v uint32) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// toSeqID 客户端使用SeqID作为请求id,应答中的id需为非负整数
+func toSeqID(id json.RawMessage) (uint32, error) {
+	v, err := strconv.ParseUint(string(id), 10, 32)
+	if err != nil {
+		return 0, ErrInvalidID
+	}
+
+	return uint32(v), nil
+}
@@ -0,0 +1,191 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/protocol/internal/websocket"
+	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+type addRequest struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type addResponse struct {
+	Sum int `json:"sum"`
+}
+
+func newAddServer(t *testing.T, name string) {
+	svr := memtest.NewServer(t, name)
+	svr.Register(&netx.Route{Name: "add", Handler: func(ctx context.Context, req *addRequest) (*addResponse, error) {
+		return &addResponse{Sum: req.A + req.B}, nil
+	}})
+	svr.Register(&netx.Route{Name: "fail", Handler: func(ctx context.Context) error {
+		return NewError(-32001, "custom")
+	}})
+	svr.Register(&netx.Route{Name: "batch", Handler: ServeHTTP})
+}
+
+func TestClientServer(t *testing.T) {
+	newAddServer(t, "jsonrpc.tcp")
+	cli := memtest.NewClient(t, client.WithProtocol(New()))
+	for i := 0; i < 3; i++ {
+		req := netx.NewRequest()
+		req.SetService(memtest.Addr("jsonrpc.tcp"))
+		req.SetURI("add")
+		if err := req.Encode(netx.CodecTypeJson, &addRequest{A: i, B: 10}); err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := cli.Call(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &addResponse{}
+		if err := rsp.Decode(out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Sum != i+10 {
+			t.Errorf("bad sum, %d", out.Sum)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	newAddServer(t, "jsonrpc.http")
+	cli := memtest.NewClient(t, client.WithProtocol(rpc.New()))
+	req := netx.NewRequest()
+	req.SetService(memtest.Addr("jsonrpc.http"))
+	req.SetURI("batch")
+	req.SetCodec(uint32(netx.CodecTypeJson))
+	req.SetBody(body.NewBufferBody(newPayload([]byte(`[
+		{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":2},"id":"x"},
+		{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":2}},
+		{"jsonrpc":"2.0","method":"fail","id":2},
+		{"jsonrpc":"2.0","method":"none","id":3},
+		1
+	]`))))
+	rsp, err := cli.Call(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []message
+	if err := rsp.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 4 {
+		t.Fatalf("bad batch reply, %+v", out)
+	}
+	if string(out[0].ID) != `"x"` || string(out[0].Result) != `{"sum":3}` {
+		t.Errorf("bad add reply, %+v", out[0])
+	}
+	if out[1].Error == nil || out[1].Error.Code != -32001 || out[1].Error.Message != "custom" {
+		t.Errorf("bad fail reply, %+v", out[1].Error)
+	}
+	if out[2].Error == nil || out[2].Error.Code != CodeMethodNotFound {
+		t.Errorf("bad not found reply, %+v", out[2].Error)
+	}
+	if out[3].Error == nil || out[3].Error.Code != CodeInvalidRequest {
+		t.Errorf("bad invalid reply, %+v", out[3].Error)
+	}
+}
+
+func TestSession(t *testing.T) {
+	s := newSession()
+	reply := s.decode([]byte(`[{"jsonrpc":"2.0","method":"a","id":1},{"jsonrpc":"2.0","method":"b"},{"jsonrpc":"2.0","method":"c","id":"c"},{"foo":1}]`))
+	if reply != nil || len(s.frames) != 3 {
+		t.Fatalf("bad decode, %s, %d", reply, len(s.frames))
+	}
+	if !s.frames[1].Identifier().IsOneway {
+		t.Errorf("notification should be oneway")
+	}
+
+	rsp := netx.NewIdentifier()
+	rsp.IsResponse = true
+	rsp.SeqID = s.frames[2].Identifier().SeqID
+	if data := s.reply(rsp, []byte(`"ok"`)); data != nil {
+		t.Fatalf("batch should wait for all replies")
+	}
+	rsp.SeqID = s.frames[0].Identifier().SeqID
+	rsp.StatusCode = 404
+	data := s.reply(rsp, nil)
+	var out []message
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 || out[0].Error.Code != CodeInvalidRequest || string(out[1].ID) != `"c"` || out[2].Error.Code != CodeMethodNotFound {
+		t.Errorf("bad batch reply, %s", data)
+	}
+
+	if reply := s.decode([]byte(`{"jsonrpc":`)); reply == nil {
+		t.Errorf("parse error should reply")
+	}
+	if reply := s.decode([]byte(`[]`)); reply == nil {
+		t.Errorf("empty batch should reply")
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	for _, f := range []Framing{FramingLine, FramingHeader} {
+		p := New(WithFraming(f))
+		ident := &netx.Identifier{URI: "add", SeqID: 7}
+		frame := netx.NewFrame(netx.FrameTypeHeader, true, 0, ident, nil, newPayload([]byte(`[1,2]`)))
+		buf, err := p.Encode(nil, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = buf.Seek(0, 0)
+		if !p.Detect(buf) {
+			t.Fatalf("detect fail")
+		}
+		got, err := p.Decode(nil, buf)
+		if err != nil || got == nil {
+			t.Fatalf("decode fail, %+v", err)
+		}
+		if got.Identifier().URI != "add" || got.Identifier().SeqID == 0 || string(got.Payload().Bytes()) != `[1,2]` {
+			t.Errorf("bad frame, %+v", got.Identifier())
+		}
+	}
+}
+
+func TestWebSocketFrame(t *testing.T) {
+	payload := []byte(`{"jsonrpc":"2.0","method":"add","id":1}`)
+	mask := []byte{1, 2, 3, 4}
+	buf := bytex.NewBuffer()
+	// 分为两个分片发送
+	for i, part := range [][]byte{payload[:10], payload[10:]} {
+		head := []byte{websocket.OpText, 0x80 | byte(len(part))}
+		if i == 1 {
+			head[0] = 0x80 | websocket.OpContinuation
+		}
+		_ = buf.Append(head)
+		_ = buf.Append(mask)
+		masked := make([]byte, len(part))
+		for j, c := range part {
+			masked[j] = c ^ mask[j%4]
+		}
+		_ = buf.Append(masked)
+	}
+	_, _ = buf.Seek(0, 0)
+
+	f := newFramer(framingWebSocket, defaultMaxSize)
+	data, err := f.read(nil, buf)
+	if err != nil || string(data) != string(payload) {
+		t.Errorf("bad websocket message, %s, %+v", data, err)
+	}
+
+	out := f.write(payload).Bytes()
+	if out[0] != 0x80|websocket.OpText || int(out[1]) != len(payload) {
+		t.Errorf("bad websocket frame")
+	}
+	if websocket.AcceptKey("dGhlIHNhbXBsZSBub25jZQ==") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("bad accept key")
+	}
+}
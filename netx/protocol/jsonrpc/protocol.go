@@ -0,0 +1,346 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/pkg/unique"
+)
+
+// defaultMaxSize 单个消息默认最大长度
+const defaultMaxSize = 4 << 20
+
+var (
+	// kConnKeySession conn中unique key
+	kConnKeySession = unique.NewKey(netx.KeyGroupConn, "jsonrpc-session")
+)
+
+func init() {
+	protocol.Register(New())
+}
+
+// Options 可选参数
+type Options struct {
+	Framing Framing // 消息分隔方式
+	MaxSize int     // 单个消息最大长度
+}
+
+// Option .
+type Option func(o *Options)
+
+// WithFraming 设置TCP上的消息分隔方式,默认服务端自动识别,客户端按行分隔
+func WithFraming(f Framing) Option {
+	return func(o *Options) {
+		o.Framing = f
+	}
+}
+
+// WithMaxSize 设置单个消息最大长度
+func WithMaxSize(size int) Option {
+	return func(o *Options) {
+		o.MaxSize = size
+	}
+}
+
+func withFraming(f Framing) Option {
+	return WithFraming(f)
+}
+
+// New 创建JSON-RPC协议,通过protocol.Register注册后服务端可自动探测
+func New(opts ...Option) netx.Protocol {
+	o := &Options{MaxSize: defaultMaxSize}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &jsonrpcProtocol{opts: o}
+}
+
+// jsonrpcProtocol 消息与Frame一一对应,请求method映射为URI,params和result映射为payload
+//	服务端会为每个请求分配SeqID,应答时再还原为请求中的id,客户端直接使用SeqID作为id
+//	协议有状态,每个Conn一个session,用于记录id映射及批量请求
+type jsonrpcProtocol struct {
+	opts *Options
+}
+
+func (p *jsonrpcProtocol) Name() string {
+	return "jsonrpc"
+}
+
+// Detect 首个非空白字符为'{'或'['时按行分隔,以Content-Length开头时按头分隔
+func (p *jsonrpcProtocol) Detect(peeker bytex.Peeker) bool {
+	text := bytes.TrimLeft(peekN(peeker, 32), " \t\r\n")
+	if len(text) == 0 {
+		return false
+	}
+
+	switch text[0] {
+	case '{', '[':
+		return true
+	}
+
+	return len(text) >= len(headerContentLength) && bytes.EqualFold(text[:len(headerContentLength)], []byte(headerContentLength))
+}
+
+func (p *jsonrpcProtocol) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error) {
+	s := p.session(conn)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.framer == nil {
+		f := p.opts.Framing
+		if f == FramingAuto {
+			var ok bool
+			if f, ok = detectFraming(buf); !ok {
+				return nil, nil
+			}
+		}
+		s.framer = newFramer(f, p.opts.MaxSize)
+	}
+
+	for {
+		if len(s.frames) > 0 {
+			frame := s.frames[0]
+			s.frames = s.frames[1:]
+			return frame, nil
+		}
+
+		data, err := s.framer.read(conn, buf)
+		// 已读取的数据需要丢弃,消息可能没有产生frame,比如通知或非法请求
+		buf.Discard()
+		if err != nil || data == nil {
+			return nil, err
+		}
+
+		if reply := s.decode(data); reply != nil && conn != nil {
+			if err := conn.Send(s.framer.write(reply)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (p *jsonrpcProtocol) Encode(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
+	ident := frame.Identifier()
+	if frame.Type() != netx.FrameTypeHeader || !frame.EndFlag() {
+		return nil, netx.ErrNotSupport
+	}
+	if ident == nil {
+		return nil, netx.ErrInvalidIdentifier
+	}
+
+	var payload []byte
+	if buf := frame.Payload(); buf != nil && !buf.Empty() {
+		payload = buf.Bytes()
+	}
+
+	s := p.session(conn)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.framer == nil {
+		s.framer = newFramer(p.opts.Framing, p.opts.MaxSize)
+	}
+
+	var data []byte
+	if ident.IsResponse {
+		if p.opts.Framing == framingWebSocket && ident.StatusCode == 101 {
+			// 升级应答仍使用http协议
+			return encodeUpgrade(conn, frame)
+		}
+		data = s.reply(ident, payload)
+		if data == nil {
+			// 批量请求尚未全部完成
+			return nil, nil
+		}
+	} else {
+		if ident.URI == "" {
+			return nil, netx.ErrInvalidIdentifier
+		}
+		m := &message{Version: Version, Method: ident.URI}
+		if len(bytes.TrimSpace(payload)) > 0 {
+			m.Params = payload
+		}
+		if !ident.IsOneway {
+			m.ID = json.RawMessage(strconvU32(ident.SeqID))
+		}
+		var err error
+		if data, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.framer.write(data), nil
+}
+
+// session 获取conn对应的session,conn为nil时(通常为测试)每次创建新的session
+func (p *jsonrpcProtocol) session(conn netx.Conn) *session {
+	if conn == nil {
+		return newSession()
+	}
+
+	return conn.Attributes().Get(kConnKeySession, func() interface{} {
+		return newSession()
+	}).(*session)
+}
+
+// session 连接状态,读写可能在不同协程中,需要加锁
+type session struct {
+	mux     sync.Mutex
+	framer  framer
+	frames  []netx.Frame     // 已解析但尚未返回的frame,批量请求会产生多个
+	seq     uint32           // 服务端分配的SeqID
+	pending map[uint32]*call // 服务端等待应答的请求
+}
+
+// call 等待应答的请求
+type call struct {
+	id    json.RawMessage
+	batch *batch
+}
+
+// batch 批量请求,所有请求应答后合并发送
+type batch struct {
+	pending int
+	replies [][]byte
+}
+
+func newSession() *session {
+	return &session{pending: make(map[uint32]*call)}
+}
+
+// decode 解析消息并生成frame,返回需要立即发送的应答,比如解析失败或批量请求中的非法请求
+func (s *session) decode(data []byte) []byte {
+	msgs, isBatch, err := parse(data)
+	if err != nil {
+		return newErrorReply(nil, CodeParseError, "")
+	}
+	if isBatch && len(msgs) == 0 {
+		return newErrorReply(nil, CodeInvalidRequest, "")
+	}
+
+	var b *batch
+	if isBatch {
+		b = &batch{}
+	}
+
+	var replies [][]byte
+	for _, m := range msgs {
+		frame, reply := s.toFrame(m, b)
+		if frame != nil {
+			s.frames = append(s.frames, frame)
+		}
+		if reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+
+	if !isBatch {
+		if len(replies) > 0 {
+			return replies[0]
+		}
+		return nil
+	}
+
+	if b.pending == 0 {
+		// 没有需要等待应答的请求
+		if len(replies) > 0 {
+			return joinBatch(replies)
+		}
+		return nil
+	}
+	b.replies = replies
+	return nil
+}
+
+// toFrame 将消息转换为frame,非法请求返回错误应答
+func (s *session) toFrame(m *message, b *batch) (netx.Frame, []byte) {
+	if m == nil {
+		return nil, newErrorReply(nil, CodeInvalidRequest, "")
+	}
+
+	if !m.isRequest() {
+		return s.toResponse(m), nil
+	}
+
+	if !m.validate() {
+		return nil, newErrorReply(m.ID, CodeInvalidRequest, "")
+	}
+
+	ident := netx.NewIdentifier()
+	ident.URI = m.Method
+	ident.Codec = uint32(netx.CodecTypeJson)
+	ident.IsOneway = m.isNotify()
+	if !ident.IsOneway {
+		s.seq++
+		ident.SeqID = s.seq
+		s.pending[ident.SeqID] = &call{id: m.ID, batch: b}
+		if b != nil {
+			b.pending++
+		}
+	}
+
+	var payload bytex.Buffer
+	if len(m.Params) > 0 {
+		payload = newPayload(m.Params)
+	}
+
+	return netx.NewFrame(netx.FrameTypeHeader, true, 0, ident, nil, payload), nil
+}
+
+// toResponse 客户端收到的应答,id需为请求时使用的SeqID
+func (s *session) toResponse(m *message) netx.Frame {
+	seqID, err := toSeqID(m.ID)
+	if err != nil {
+		return nil
+	}
+
+	ident := netx.NewIdentifier()
+	ident.IsResponse = true
+	ident.SeqID = seqID
+	ident.Codec = uint32(netx.CodecTypeJson)
+
+	var data []byte
+	if m.Error != nil {
+		ident.StatusCode = int32(m.Error.Code)
+		ident.StatusInfo = m.Error.Message
+		data = m.Error.Data
+	} else if !bytes.Equal(m.Result, nullID) {
+		data = m.Result
+	}
+
+	var payload bytex.Buffer
+	if len(data) > 0 {
+		payload = newPayload(data)
+	}
+
+	return netx.NewFrame(netx.FrameTypeHeader, true, 0, ident, nil, payload)
+}
+
+// reply 生成应答,批量请求未全部完成时返回nil
+func (s *session) reply(ident *netx.Identifier, payload []byte) []byte {
+	c := s.pending[ident.SeqID]
+	if c == nil {
+		// 不是通过本session解析的请求,直接使用SeqID
+		return newReply(json.RawMessage(strconvU32(ident.SeqID)), ident, payload)
+	}
+	delete(s.pending, ident.SeqID)
+
+	data := newReply(c.id, ident, payload)
+	b := c.batch
+	if b == nil {
+		return data
+	}
+
+	b.replies = append(b.replies, data)
+	b.pending--
+	if b.pending > 0 {
+		return nil
+	}
+
+	return joinBatch(b.replies)
+}
@@ -0,0 +1,66 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/http1"
+	"github.com/foredata/nova/netx/protocol/internal/websocket"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// gWebSocket websocket升级后连接使用的协议
+var gWebSocket = New(withFraming(framingWebSocket))
+
+// ServeWebSocket 处理websocket升级请求,升级后连接上的每个文本或二进制消息作为一个JSON-RPC消息
+//	仅实现服务端,不支持扩展(permessage-deflate等)
+//	s.GET("/ws", jsonrpc.ServeWebSocket)
+func ServeWebSocket(ctx context.Context, req netx.Request) (netx.Response, error) {
+	key, err := websocket.CheckUpgrade(req.Header())
+	if err != nil {
+		return nil, netx.BadRequest("jsonrpc: %s", err)
+	}
+
+	conn := server.Hijack(ctx)
+	if conn == nil {
+		return nil, netx.NewError(http.StatusInternalServerError, "", "jsonrpc: connection can not be hijacked")
+	}
+
+	// 必须在发送101之前替换协议,客户端收到101后即开始发送websocket数据
+	conn.SetProtocol(gWebSocket)
+	return nil, conn.Send(websocket.NewUpgradeResponse(req.SeqID(), key, ""))
+}
+
+// Ping 实现netx.Pinger,websocket升级后发送ping帧,其他分隔方式没有心跳
+func (p *jsonrpcProtocol) Ping(conn netx.Conn) error {
+	if p.opts.Framing != framingWebSocket {
+		return netx.ErrNotSupport
+	}
+
+	return conn.Send(websocket.NewFrame(websocket.OpPing, nil))
+}
+
+// encodeUpgrade 协议在发送101前已替换,101应答仍需按http编码
+func encodeUpgrade(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
+	return http1.New().Encode(conn, frame)
+}
+
+// wsFramer 每个websocket消息为一个JSON-RPC消息
+type wsFramer struct {
+	reader websocket.Reader
+}
+
+func (f *wsFramer) read(conn netx.Conn, buf bytex.Buffer) ([]byte, error) {
+	data, err := f.reader.Read(conn, buf)
+	if err == websocket.ErrMessageTooLarge {
+		err = ErrMessageTooLarge
+	}
+
+	return data, err
+}
+
+func (f *wsFramer) write(data []byte) bytex.Buffer {
+	return websocket.NewFrame(websocket.OpText, data)
+}
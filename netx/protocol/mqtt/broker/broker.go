@@ -0,0 +1,558 @@
+// Package broker MQTT 3.1.1/5.0 broker
+//	broker以Filter的方式挂载到Transport上,每个连接的报文在读协程中按顺序处理,不经过processor
+//	同一端口同时支持tcp和websocket,首字节为CONNECT时按tcp处理,为GET时按websocket升级处理
+//	b := broker.New(broker.WithAddr(":1883"), broker.WithStore(memory.New()))
+//	if err := b.Start(); err != nil {
+//		return err
+//	}
+//	defer b.Stop()
+//	支持QoS 0/1/2,保留消息,遗嘱消息(含5.0延迟遗嘱),通配符订阅,会话过期及持久化,5.0主题别名及订阅标识
+//	不支持共享订阅及5.0扩展认证
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/mqtt"
+	"github.com/foredata/nova/netx/transport"
+	"github.com/foredata/nova/store"
+)
+
+// 持久化key前缀
+const (
+	sessionPrefix = "mqtt/session/"
+	retainPrefix  = "mqtt/retain/"
+)
+
+// some error
+var (
+	ErrStarted = errors.New("mqtt: broker has started")
+)
+
+// Message 发布的消息
+type Message struct {
+	Topic   string          // 主题
+	Payload []byte          // 内容
+	QoS     byte            // 服务质量
+	Retain  bool            // 是否保留
+	Props   mqtt.Properties // 5.0属性,原样转发给订阅者,不包含主题别名和订阅标识
+	Expire  time.Time       // 过期时间,零值表示不过期
+	From    string          // 发布者ClientID,通过Broker.Publish发布时为空
+}
+
+func (m *Message) expired(now time.Time) bool {
+	return !m.Expire.IsZero() && !now.Before(m.Expire)
+}
+
+// New 创建broker
+func New(opts ...Option) *Broker {
+	b := &Broker{
+		opts:     newOptions(opts...),
+		sessions: make(map[string]*session),
+		trie:     newTrie(),
+		retained: make(map[string]*Message),
+	}
+	b.filter = &filter{broker: b}
+	return b
+}
+
+// Broker 管理会话,订阅及保留消息
+//	锁顺序为先Broker后session,持有session锁时不能再获取Broker锁
+type Broker struct {
+	opts     *Options
+	filter   netx.Filter
+	mux      sync.RWMutex        // 保护sessions,trie,retained
+	sessions map[string]*session // ClientID对应的会话
+	trie     *trie               // 订阅树
+	retained map[string]*Message // 保留消息
+	tran     netx.Tran           // Start时创建
+	listener netx.Listener       //
+	quit     chan struct{}       //
+}
+
+// Filter 返回处理MQTT连接的Filter,可用于自定义Transport,此时无需调用Start
+//	自定义Transport时仍需调用Load加载持久化数据
+func (b *Broker) Filter() netx.Filter {
+	return b.filter
+}
+
+// Start 加载持久化数据,监听端口并启动心跳检查
+func (b *Broker) Start() error {
+	if b.tran != nil {
+		return ErrStarted
+	}
+
+	if err := b.Load(context.Background()); err != nil {
+		return err
+	}
+
+	factory := b.opts.TranFactory
+	if factory == nil {
+		factory = transport.New
+	}
+	tran := factory()
+	tran.AddFilters(b.filter)
+	l, err := tran.Listen(b.opts.Addr)
+	if err != nil {
+		_ = tran.Close()
+		return err
+	}
+
+	b.tran = tran
+	b.listener = l
+	b.quit = make(chan struct{})
+	go b.loop()
+	return nil
+}
+
+// Stop 停止监听,并保存所有会话
+func (b *Broker) Stop() error {
+	if b.tran == nil {
+		return nil
+	}
+
+	close(b.quit)
+	_ = b.listener.Close()
+	err := b.tran.Close()
+	b.tran = nil
+	b.Save(context.Background())
+	return err
+}
+
+// Publish 向订阅者发布消息
+func (b *Broker) Publish(msg *Message) {
+	b.route(msg)
+}
+
+// Retained 查询保留消息
+func (b *Broker) Retained(topic string) *Message {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	return b.retained[topic]
+}
+
+// Load 从Store加载保留消息及离线会话
+func (b *Broker) Load(ctx context.Context) error {
+	st := b.opts.Store
+	if st == nil {
+		return nil
+	}
+
+	retained, err := st.List(ctx, retainPrefix+"*")
+	if err != nil {
+		return err
+	}
+	sessions, err := st.List(ctx, sessionPrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for _, kv := range retained {
+		msg := &Message{}
+		if err := json.Unmarshal(kv.Value, msg); err != nil || msg.expired(now) {
+			continue
+		}
+		b.retained[msg.Topic] = msg
+	}
+
+	for _, kv := range sessions {
+		rec := &sessionRecord{}
+		if err := json.Unmarshal(kv.Value, rec); err != nil {
+			continue
+		}
+		if !rec.ExpireAt.IsZero() && !now.Before(rec.ExpireAt) {
+			_ = st.Delete(ctx, kv.Key)
+			continue
+		}
+
+		s := newSession(b, rec.ID)
+		s.restore(rec, now)
+		b.sessions[s.id] = s
+		for _, sub := range s.subs {
+			b.trie.add(s, sub)
+		}
+	}
+
+	return nil
+}
+
+// Save 保存所有会话,在线会话按此刻断开计算过期时间
+func (b *Broker) Save(ctx context.Context) {
+	if b.opts.Store == nil {
+		return
+	}
+
+	b.mux.RLock()
+	sessions := make([]*session, 0, len(b.sessions))
+	for _, s := range b.sessions {
+		sessions = append(sessions, s)
+	}
+	b.mux.RUnlock()
+
+	now := time.Now()
+	for _, s := range sessions {
+		s.mux.Lock()
+		rec := s.record(now)
+		s.mux.Unlock()
+		if rec != nil {
+			b.saveSession(ctx, rec)
+		}
+	}
+}
+
+func (b *Broker) saveSession(ctx context.Context, rec *sessionRecord) {
+	if b.opts.Store == nil {
+		return
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+
+	var ttl time.Duration
+	if !rec.ExpireAt.IsZero() {
+		ttl = time.Until(rec.ExpireAt)
+		if ttl <= 0 {
+			return
+		}
+	}
+	_, _, _ = b.opts.Store.Put(ctx, sessionPrefix+url.PathEscape(rec.ID), data, store.WithTTL(ttl))
+}
+
+func (b *Broker) deleteSession(id string) {
+	if b.opts.Store != nil {
+		_ = b.opts.Store.Delete(context.Background(), sessionPrefix+url.PathEscape(id))
+	}
+}
+
+// route 投递消息,同一会话的多个订阅匹配时只投递一次,QoS取最大值,订阅标识合并
+func (b *Broker) route(msg *Message) {
+	if msg.Retain {
+		b.retain(msg)
+	}
+
+	targets := make(map[*session]*delivery)
+	b.mux.RLock()
+	b.trie.match(msg.Topic, func(s *session, sub *subscription) {
+		if sub.NoLocal && s.id == msg.From {
+			return
+		}
+		d := targets[s]
+		if d == nil {
+			d = &delivery{Msg: msg}
+			targets[s] = d
+		}
+		if qos := minQoS(sub.QoS, msg.QoS); qos > d.QoS {
+			d.QoS = qos
+		}
+		if sub.RetainAsPublished && msg.Retain {
+			d.Retain = true
+		}
+		if sub.SubID != 0 {
+			d.SubIDs = append(d.SubIDs, sub.SubID)
+		}
+	})
+	b.mux.RUnlock()
+
+	for s, d := range targets {
+		s.mux.Lock()
+		s.deliver(d)
+		s.mux.Unlock()
+	}
+}
+
+// retain 更新保留消息,payload为空时删除
+func (b *Broker) retain(msg *Message) {
+	b.mux.Lock()
+	if len(msg.Payload) == 0 {
+		delete(b.retained, msg.Topic)
+	} else {
+		b.retained[msg.Topic] = msg
+	}
+	b.mux.Unlock()
+
+	st := b.opts.Store
+	if st == nil {
+		return
+	}
+
+	ctx := context.Background()
+	key := retainPrefix + url.PathEscape(msg.Topic)
+	if len(msg.Payload) == 0 {
+		_ = st.Delete(ctx, key)
+		return
+	}
+
+	var ttl time.Duration
+	if !msg.Expire.IsZero() {
+		ttl = time.Until(msg.Expire)
+	}
+	if data, err := json.Marshal(msg); err == nil {
+		_, _, _ = st.Put(ctx, key, data, store.WithTTL(ttl))
+	}
+}
+
+// matchRetained 查询匹配过滤器的保留消息
+func (b *Broker) matchRetained(filter string) []*Message {
+	now := time.Now()
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+
+	var msgs []*Message
+	for topic, msg := range b.retained {
+		if !msg.expired(now) && matchTopic(filter, topic) {
+			msgs = append(msgs, msg)
+		}
+	}
+
+	return msgs
+}
+
+// attach 连接成功后绑定会话,cleanStart时丢弃原会话,返回会话及是否存在原会话
+//	同一ClientID已经在线时,断开原连接,原连接的遗嘱仅在没有设置延迟时发送
+func (b *Broker) attach(c *client, cleanStart bool, expiry uint32, will *Message, willDelay uint32) (*session, bool) {
+	var old *client
+	var oldWill *Message
+
+	b.mux.Lock()
+	s := b.sessions[c.id]
+	if s != nil {
+		s.mux.Lock()
+		if s.client != nil {
+			old = s.client
+			if s.willDelay == 0 {
+				oldWill = s.will
+			}
+		}
+		s.detach()
+		// 新连接建立后不再发送延迟遗嘱
+		s.will = nil
+		s.mux.Unlock()
+
+		if cleanStart {
+			b.removeLocked(s)
+			s = nil
+		}
+	}
+
+	present := s != nil
+	if s == nil {
+		s = newSession(b, c.id)
+		b.sessions[c.id] = s
+	}
+
+	c.session = s
+	s.mux.Lock()
+	s.client = c
+	s.expiry = expiry
+	s.expireAt = time.Time{}
+	s.will = will
+	s.willDelay = willDelay
+	s.mux.Unlock()
+	b.mux.Unlock()
+
+	if old != nil {
+		old.disconnect(mqtt.ReasonSessionTakenOver)
+	}
+	if oldWill != nil {
+		b.route(oldWill)
+	}
+	if !present {
+		b.deleteSession(c.id)
+	}
+
+	return s, present
+}
+
+// detach 连接断开,会话过期间隔为0时删除会话,否则保存会话
+func (b *Broker) detach(c *client) {
+	now := time.Now()
+
+	b.mux.Lock()
+	s := c.session
+	if s == nil {
+		// 尚未绑定会话
+		b.mux.Unlock()
+		return
+	}
+	s.mux.Lock()
+	if s.client != c {
+		// 已被新连接接管
+		s.mux.Unlock()
+		b.mux.Unlock()
+		b.onDisconnect(c)
+		return
+	}
+
+	s.detach()
+	var will *Message
+	if s.will != nil && (s.willDelay == 0 || s.expiry == 0) {
+		will, s.will = s.will, nil
+	} else if s.will != nil {
+		s.willAt = now.Add(time.Duration(s.willDelay) * time.Second)
+	}
+
+	var rec *sessionRecord
+	if s.expiry == 0 {
+		s.mux.Unlock()
+		b.removeLocked(s)
+	} else {
+		if s.expiry != math.MaxUint32 {
+			s.expireAt = now.Add(time.Duration(s.expiry) * time.Second)
+		}
+		rec = s.record(now)
+		s.mux.Unlock()
+	}
+	b.mux.Unlock()
+
+	if rec != nil {
+		b.saveSession(context.Background(), rec)
+	} else {
+		b.deleteSession(s.id)
+	}
+	if will != nil {
+		b.route(will)
+	}
+	b.onDisconnect(c)
+}
+
+func (b *Broker) onDisconnect(c *client) {
+	if b.opts.OnDisconnect != nil {
+		b.opts.OnDisconnect(c.id)
+	}
+}
+
+// removeLocked 删除会话及订阅,需持有Broker锁
+func (b *Broker) removeLocked(s *session) {
+	if b.sessions[s.id] == s {
+		delete(b.sessions, s.id)
+	}
+
+	s.mux.Lock()
+	subs := s.subs
+	s.subs = make(map[string]*subscription)
+	s.mux.Unlock()
+
+	for filter := range subs {
+		b.trie.remove(s, filter)
+	}
+}
+
+// subscribe 添加订阅,返回是否已存在
+func (b *Broker) subscribe(s *session, sub *subscription) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	s.mux.Lock()
+	s.subs[sub.Filter] = sub
+	s.mux.Unlock()
+	return b.trie.add(s, sub)
+}
+
+// unsubscribe 删除订阅,返回是否存在
+func (b *Broker) unsubscribe(s *session, filter string) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	s.mux.Lock()
+	delete(s.subs, filter)
+	s.mux.Unlock()
+	return b.trie.remove(s, filter)
+}
+
+// loop 定时检查心跳超时,延迟遗嘱及会话过期
+func (b *Broker) loop() {
+	ticker := time.NewTicker(b.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.quit:
+			return
+		case now := <-ticker.C:
+			b.check(now)
+		}
+	}
+}
+
+func (b *Broker) check(now time.Time) {
+	b.mux.RLock()
+	sessions := make([]*session, 0, len(b.sessions))
+	for _, s := range b.sessions {
+		sessions = append(sessions, s)
+	}
+	b.mux.RUnlock()
+
+	var idle []*client
+	var wills []*Message
+	var expired []*session
+	for _, s := range sessions {
+		s.mux.Lock()
+		if c := s.client; c != nil {
+			if c.timeout(now) {
+				idle = append(idle, c)
+			}
+		} else {
+			if s.will != nil && !now.Before(s.willAt) {
+				wills = append(wills, s.will)
+				s.will = nil
+			}
+			if !s.expireAt.IsZero() && !now.Before(s.expireAt) {
+				expired = append(expired, s)
+			}
+		}
+		s.mux.Unlock()
+	}
+
+	for _, c := range idle {
+		c.disconnect(mqtt.ReasonKeepAliveTimeout)
+	}
+	for _, will := range wills {
+		b.route(will)
+	}
+	for _, s := range expired {
+		b.expire(s, now)
+	}
+}
+
+// expire 删除过期会话,会话过期时未发送的延迟遗嘱需立即发送
+func (b *Broker) expire(s *session, now time.Time) {
+	b.mux.Lock()
+	s.mux.Lock()
+	if s.client != nil || s.expireAt.IsZero() || now.Before(s.expireAt) {
+		s.mux.Unlock()
+		b.mux.Unlock()
+		return
+	}
+	will := s.will
+	s.will = nil
+	s.mux.Unlock()
+	b.removeLocked(s)
+	b.mux.Unlock()
+
+	b.deleteSession(s.id)
+	if will != nil {
+		b.route(will)
+	}
+}
+
+func minQoS(a, b byte) byte {
+	if a < b {
+		return a
+	}
+
+	return b
+}
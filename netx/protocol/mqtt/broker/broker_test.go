@@ -0,0 +1,479 @@
+package broker
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/mqtt"
+	"github.com/foredata/nova/netx/transport/memory"
+	"github.com/foredata/nova/pkg/bytex"
+	memstore "github.com/foredata/nova/store/memory"
+)
+
+// recvFilter 客户端解析收到的报文,ws为true时先跳过升级应答再解析websocket帧
+type recvFilter struct {
+	netx.BaseFilter
+	version  byte
+	ws       bool
+	upgraded bool
+	stream   []byte
+	packets  chan mqtt.Packet
+}
+
+func (f *recvFilter) Name() string {
+	return "recv"
+}
+
+func (f *recvFilter) HandleRead(ctx netx.FilterCtx) error {
+	buf := ctx.Data().(bytex.Buffer)
+	data := make([]byte, buf.Available())
+	_, _ = buf.Peek(data)
+	_ = buf.ReadN(len(data))
+	buf.Discard()
+
+	f.stream = append(f.stream, data...)
+	if f.ws && !f.upgraded {
+		idx := bytes.Index(f.stream, []byte("\r\n\r\n"))
+		if idx == -1 {
+			return nil
+		}
+		if !bytes.HasPrefix(f.stream, []byte("HTTP/1.1 101")) {
+			return nil
+		}
+		f.stream = f.stream[idx+4:]
+		f.upgraded = true
+	}
+
+	for {
+		data := f.stream
+		if f.ws {
+			// 服务端帧不带mask,测试中的消息长度均小于126
+			if len(data) < 2 || len(data) < 2+int(data[1]) {
+				return nil
+			}
+			f.stream = data[2+int(data[1]):]
+			data = data[2 : 2+int(data[1])]
+		}
+		pkt, n, err := mqtt.ReadPacket(data, f.version, mqtt.MaxRemainingLength)
+		if err != nil || n == 0 {
+			return nil
+		}
+		if !f.ws {
+			f.stream = data[n:]
+		}
+		f.packets <- pkt
+	}
+}
+
+type testClient struct {
+	tb      testing.TB
+	conn    netx.Conn
+	version byte
+	ws      bool
+	packets chan mqtt.Packet
+}
+
+func dial(tb testing.TB, addr string, version byte, ws bool) *testClient {
+	tb.Helper()
+	f := &recvFilter{version: version, ws: ws, packets: make(chan mqtt.Packet, 100)}
+	tran := memory.New()
+	tran.AddFilters(f)
+	conn, err := tran.Dial(addr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return &testClient{tb: tb, conn: conn, version: version, ws: ws, packets: f.packets}
+}
+
+func (c *testClient) write(data []byte) {
+	buf := bytex.NewBuffer()
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	if err := c.conn.Send(buf); err != nil {
+		c.tb.Fatal(err)
+	}
+}
+
+func (c *testClient) send(pkt mqtt.Packet) {
+	data := mqtt.Encode(pkt, c.version)
+	if c.ws {
+		data = maskFrame(data)
+	}
+	c.write(data)
+}
+
+func (c *testClient) recv() mqtt.Packet {
+	c.tb.Helper()
+	select {
+	case pkt := <-c.packets:
+		return pkt
+	case <-time.After(5 * time.Second):
+		c.tb.Fatalf("recv timeout")
+		return nil
+	}
+}
+
+// none 确认一段时间内没有收到报文
+func (c *testClient) none() {
+	c.tb.Helper()
+	select {
+	case pkt := <-c.packets:
+		c.tb.Fatalf("unexpected packet, %s", pkt.Type())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// closed 等待连接被服务端关闭
+func (c *testClient) closed() {
+	c.tb.Helper()
+	deadline := time.Now().Add(time.Second)
+	for c.conn.IsActive() {
+		if time.Now().After(deadline) {
+			c.tb.Fatalf("connection should be closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (c *testClient) connect(pkt *mqtt.Connect) *mqtt.Connack {
+	c.tb.Helper()
+	pkt.Version = c.version
+	c.send(pkt)
+	ack, ok := c.recv().(*mqtt.Connack)
+	if !ok {
+		c.tb.Fatalf("should be connack")
+	}
+
+	return ack
+}
+
+func (c *testClient) subscribe(topic string, qos byte) {
+	c.tb.Helper()
+	c.send(&mqtt.Subscribe{PacketID: 1, Subscriptions: []mqtt.Subscription{{Topic: topic, QoS: qos}}})
+	if ack, ok := c.recv().(*mqtt.Suback); !ok || ack.ReasonCodes[0] != mqtt.ReasonCode(qos) {
+		c.tb.Fatalf("bad suback, %+v", ack)
+	}
+}
+
+func (c *testClient) publish() *mqtt.Publish {
+	c.tb.Helper()
+	pub, ok := c.recv().(*mqtt.Publish)
+	if !ok {
+		c.tb.Fatalf("should be publish")
+	}
+
+	return pub
+}
+
+// waitAcked 等待会话中所有已发送消息被确认
+func waitAcked(tb testing.TB, b *Broker, id string) {
+	tb.Helper()
+	for i := 0; i < 100; i++ {
+		b.mux.RLock()
+		s := b.sessions[id]
+		b.mux.RUnlock()
+		if s != nil {
+			s.mux.Lock()
+			n := len(s.inflight)
+			s.mux.Unlock()
+			if n == 0 {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	tb.Fatalf("wait ack timeout, %s", id)
+}
+
+func maskFrame(data []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	out := []byte{0x82, 0x80 | byte(len(data))}
+	out = append(out, mask...)
+	for i, c := range data {
+		out = append(out, c^mask[i%4])
+	}
+
+	return out
+}
+
+func startBroker(tb testing.TB, name string, opts ...Option) *Broker {
+	tb.Helper()
+	opts = append([]Option{WithAddr(memory.Scheme + name), WithTranFactory(memory.Factory())}, opts...)
+	b := New(opts...)
+	if err := b.Start(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_ = b.Stop()
+	})
+
+	return b
+}
+
+func TestPublish(t *testing.T) {
+	addr := memory.Scheme + "mqtt.publish"
+	startBroker(t, "mqtt.publish")
+
+	sub := dial(t, addr, mqtt.Version311, false)
+	if ack := sub.connect(&mqtt.Connect{ClientID: "sub", CleanStart: true}); ack.ReasonCode != mqtt.ReasonSuccess || ack.SessionPresent {
+		t.Fatalf("bad connack, %+v", ack)
+	}
+	sub.subscribe("a/+", 1)
+
+	pub := dial(t, addr, mqtt.Version311, false)
+	pub.connect(&mqtt.Connect{ClientID: "pub", CleanStart: true})
+	pub.send(&mqtt.Publish{QoS: 1, PacketID: 10, Topic: "a/b", Payload: []byte("hello")})
+	if ack, ok := pub.recv().(*mqtt.Ack); !ok || ack.PacketType != mqtt.PUBACK || ack.PacketID != 10 {
+		t.Fatalf("bad puback, %+v", ack)
+	}
+
+	msg := sub.publish()
+	if msg.Topic != "a/b" || string(msg.Payload) != "hello" || msg.QoS != 1 || msg.PacketID == 0 {
+		t.Fatalf("bad publish, %+v", msg)
+	}
+	sub.send(&mqtt.Ack{PacketType: mqtt.PUBACK, PacketID: msg.PacketID})
+
+	// 不匹配的主题
+	pub.send(&mqtt.Publish{Topic: "a/b/c", Payload: []byte("x")})
+	sub.none()
+
+	// 保留消息
+	pub.send(&mqtt.Publish{Topic: "r/1", Retain: true, Payload: []byte("retained")})
+	pub.send(&mqtt.Pingreq{})
+	if _, ok := pub.recv().(*mqtt.Pingresp); !ok {
+		t.Fatalf("should be pingresp")
+	}
+	sub.send(&mqtt.Subscribe{PacketID: 2, Subscriptions: []mqtt.Subscription{{Topic: "r/#", QoS: 2}}})
+	if _, ok := sub.recv().(*mqtt.Suback); !ok {
+		t.Fatalf("should be suback")
+	}
+	if msg := sub.publish(); !msg.Retain || string(msg.Payload) != "retained" || msg.QoS != 0 {
+		t.Fatalf("bad retained, %+v", msg)
+	}
+
+	// 发布主题不能包含通配符
+	pub.send(&mqtt.Publish{Topic: "a/#", Payload: []byte("x")})
+	pub.closed()
+	sub.none()
+}
+
+func TestQoS2(t *testing.T) {
+	addr := memory.Scheme + "mqtt.qos2"
+	startBroker(t, "mqtt.qos2")
+
+	sub := dial(t, addr, mqtt.Version311, false)
+	sub.connect(&mqtt.Connect{ClientID: "sub", CleanStart: true})
+	sub.subscribe("q", 2)
+
+	pub := dial(t, addr, mqtt.Version311, false)
+	pub.connect(&mqtt.Connect{ClientID: "pub", CleanStart: true})
+	for i := 0; i < 2; i++ {
+		// 重发的消息不会重复投递
+		pub.send(&mqtt.Publish{QoS: 2, PacketID: 5, Dup: i > 0, Topic: "q", Payload: []byte("once")})
+		if ack, ok := pub.recv().(*mqtt.Ack); !ok || ack.PacketType != mqtt.PUBREC {
+			t.Fatalf("bad pubrec, %+v", ack)
+		}
+	}
+	pub.send(&mqtt.Ack{PacketType: mqtt.PUBREL, PacketID: 5})
+	if ack, ok := pub.recv().(*mqtt.Ack); !ok || ack.PacketType != mqtt.PUBCOMP {
+		t.Fatalf("bad pubcomp, %+v", ack)
+	}
+
+	msg := sub.publish()
+	if msg.QoS != 2 || string(msg.Payload) != "once" {
+		t.Fatalf("bad publish, %+v", msg)
+	}
+	sub.none()
+	sub.send(&mqtt.Ack{PacketType: mqtt.PUBREC, PacketID: msg.PacketID})
+	if ack, ok := sub.recv().(*mqtt.Ack); !ok || ack.PacketType != mqtt.PUBREL || ack.PacketID != msg.PacketID {
+		t.Fatalf("bad pubrel, %+v", ack)
+	}
+	sub.send(&mqtt.Ack{PacketType: mqtt.PUBCOMP, PacketID: msg.PacketID})
+}
+
+func TestInflight(t *testing.T) {
+	addr := memory.Scheme + "mqtt.inflight"
+	b := startBroker(t, "mqtt.inflight", WithMaxInflight(2))
+
+	sub := dial(t, addr, mqtt.Version311, false)
+	sub.connect(&mqtt.Connect{ClientID: "sub", CleanStart: true})
+	sub.subscribe("w", 1)
+
+	for i := 0; i < 3; i++ {
+		b.Publish(&Message{Topic: "w", QoS: 1, Payload: []byte{byte(i)}})
+	}
+	first := sub.publish()
+	sub.publish()
+	// 窗口已满
+	sub.none()
+	sub.send(&mqtt.Ack{PacketType: mqtt.PUBACK, PacketID: first.PacketID})
+	if msg := sub.publish(); msg.Payload[0] != 2 {
+		t.Fatalf("bad publish, %+v", msg)
+	}
+}
+
+func TestWill(t *testing.T) {
+	addr := memory.Scheme + "mqtt.will"
+	startBroker(t, "mqtt.will")
+
+	sub := dial(t, addr, mqtt.Version311, false)
+	sub.connect(&mqtt.Connect{ClientID: "sub", CleanStart: true})
+	sub.subscribe("will/#", 0)
+
+	// 正常断开不发送遗嘱
+	c1 := dial(t, addr, mqtt.Version311, false)
+	c1.connect(&mqtt.Connect{ClientID: "c1", CleanStart: true, WillFlag: true, WillTopic: "will/c1", WillPayload: []byte("bye")})
+	c1.send(&mqtt.Disconnect{})
+	sub.none()
+
+	// 异常断开发送遗嘱
+	c2 := dial(t, addr, mqtt.Version311, false)
+	c2.connect(&mqtt.Connect{ClientID: "c2", CleanStart: true, WillFlag: true, WillTopic: "will/c2", WillPayload: []byte("bye")})
+	_ = c2.conn.Close()
+	if msg := sub.publish(); msg.Topic != "will/c2" {
+		t.Fatalf("bad will, %+v", msg)
+	}
+}
+
+func TestSession(t *testing.T) {
+	addr := memory.Scheme + "mqtt.session"
+	st := memstore.New()
+	b := New(WithAddr(addr), WithTranFactory(memory.Factory()), WithStore(st))
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := dial(t, addr, mqtt.Version311, false)
+	c.connect(&mqtt.Connect{ClientID: "persist"})
+	c.subscribe("s/+", 1)
+	c.send(&mqtt.Disconnect{})
+	time.Sleep(50 * time.Millisecond)
+
+	// 离线时排队
+	b.Publish(&Message{Topic: "s/1", QoS: 1, Payload: []byte("offline")})
+	b.Publish(&Message{Topic: "s/2", QoS: 0, Payload: []byte("dropped")})
+	_ = b.Stop()
+
+	// 重启后从store恢复
+	b = startBroker(t, "mqtt.session", WithStore(st))
+	c = dial(t, addr, mqtt.Version311, false)
+	if ack := c.connect(&mqtt.Connect{ClientID: "persist"}); !ack.SessionPresent {
+		t.Fatalf("session should be present")
+	}
+	msg := c.publish()
+	if string(msg.Payload) != "offline" {
+		t.Fatalf("bad publish, %+v", msg)
+	}
+	c.none()
+	c.send(&mqtt.Ack{PacketType: mqtt.PUBACK, PacketID: msg.PacketID})
+	// 等待PUBACK处理完成,否则接管时会重发未确认消息
+	waitAcked(t, b, "persist")
+
+	// 接管原连接
+	c2 := dial(t, addr, mqtt.Version311, false)
+	c2.connect(&mqtt.Connect{ClientID: "persist"})
+	c.closed()
+	b.Publish(&Message{Topic: "s/3", Payload: []byte("new")})
+	if msg := c2.publish(); string(msg.Payload) != "new" {
+		t.Fatalf("bad publish, %+v", msg)
+	}
+
+	// clean start丢弃原会话
+	c3 := dial(t, addr, mqtt.Version311, false)
+	if ack := c3.connect(&mqtt.Connect{ClientID: "persist", CleanStart: true}); ack.SessionPresent {
+		t.Fatalf("session should not be present")
+	}
+	b.Publish(&Message{Topic: "s/4", Payload: []byte("none")})
+	c3.none()
+}
+
+func TestVersion5(t *testing.T) {
+	addr := memory.Scheme + "mqtt.v5"
+	startBroker(t, "mqtt.v5", WithMaxTopicAlias(10), WithMaxKeepAlive(time.Minute), WithInterval(10*time.Millisecond))
+
+	c := dial(t, addr, mqtt.Version5, false)
+	ack := c.connect(&mqtt.Connect{CleanStart: true})
+	if ack.Props.String(mqtt.PropAssignedClientID) == "" || ack.Props.Int(mqtt.PropTopicAliasMaximum, 0) != 10 ||
+		ack.Props.Int(mqtt.PropServerKeepAlive, 0) != 60 {
+		t.Fatalf("bad connack, %+v", ack)
+	}
+
+	c.send(&mqtt.Subscribe{PacketID: 1, Props: mqtt.Properties{{ID: mqtt.PropSubscriptionID, Int: 7}},
+		Subscriptions: []mqtt.Subscription{{Topic: "v/#", QoS: 1}, {Topic: "$share/g/v"}}})
+	suback, _ := c.recv().(*mqtt.Suback)
+	if suback == nil || suback.ReasonCodes[1] != mqtt.ReasonSharedSubNotSupported {
+		t.Fatalf("bad suback, %+v", suback)
+	}
+
+	// 主题别名
+	props := mqtt.Properties{{ID: mqtt.PropTopicAlias, Int: 1}, {ID: mqtt.PropUserProperty, Key: "k", Str: "v"}}
+	c.send(&mqtt.Publish{Topic: "v/alias", Props: props, Payload: []byte("1")})
+	c.send(&mqtt.Publish{Props: props[:1], Payload: []byte("2")})
+	for _, payload := range []string{"1", "2"} {
+		msg := c.publish()
+		if msg.Topic != "v/alias" || string(msg.Payload) != payload || msg.Props.Int(mqtt.PropSubscriptionID, 0) != 7 {
+			t.Fatalf("bad publish, %+v", msg)
+		}
+		if payload == "1" && msg.Props.Get(mqtt.PropUserProperty) == nil {
+			t.Fatalf("user property should be forwarded")
+		}
+	}
+
+	// 无效别名
+	c.send(&mqtt.Publish{Topic: "v/x", Props: mqtt.Properties{{ID: mqtt.PropTopicAlias, Int: 11}}})
+	if dis, ok := c.recv().(*mqtt.Disconnect); !ok || dis.ReasonCode != mqtt.ReasonTopicAliasInvalid {
+		t.Fatalf("bad disconnect, %+v", dis)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	addr := memory.Scheme + "mqtt.keepalive"
+	b := startBroker(t, "mqtt.keepalive", WithInterval(10*time.Millisecond))
+
+	c := dial(t, addr, mqtt.Version5, false)
+	c.connect(&mqtt.Connect{ClientID: "idle", CleanStart: true, KeepAlive: 1})
+	// 连接上的客户端超时后被断开
+	if dis, ok := c.recv().(*mqtt.Disconnect); !ok || dis.ReasonCode != mqtt.ReasonKeepAliveTimeout {
+		t.Fatalf("bad disconnect, %+v", dis)
+	}
+
+	// 会话过期
+	c = dial(t, addr, mqtt.Version5, false)
+	c.connect(&mqtt.Connect{ClientID: "expire", Props: mqtt.Properties{{ID: mqtt.PropSessionExpiry, Int: 1}}})
+	c.send(&mqtt.Disconnect{})
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		b.mux.RLock()
+		n := len(b.sessions)
+		b.mux.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("session should expire")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	addr := memory.Scheme + "mqtt.ws"
+	startBroker(t, "mqtt.ws")
+
+	c := dial(t, addr, mqtt.Version311, true)
+	c.write([]byte("GET /mqtt HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Protocol: mqtt\r\n\r\n"))
+	c.connect(&mqtt.Connect{ClientID: "ws", CleanStart: true})
+	c.subscribe("ws/+", 0)
+	c.send(&mqtt.Publish{Topic: "ws/echo", Payload: []byte("hi")})
+	if msg := c.publish(); string(msg.Payload) != "hi" {
+		t.Fatalf("bad publish, %+v", msg)
+	}
+}
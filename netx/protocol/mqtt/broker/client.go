@@ -0,0 +1,671 @@
+package broker
+
+import (
+	"context"
+	"io"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/http1"
+	"github.com/foredata/nova/netx/protocol/internal/websocket"
+	"github.com/foredata/nova/netx/protocol/mqtt"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/pkg/unique"
+	"github.com/foredata/nova/pkg/xid"
+)
+
+var (
+	// kConnKeyClient conn中unique key
+	kConnKeyClient = unique.NewKey(netx.KeyGroupConn, "mqtt-client")
+)
+
+// 连接类型,由首字节确定
+const (
+	modeUnknown = iota
+	modeTCP
+	modeUpgrade
+	modeWebSocket
+)
+
+// filter 解析MQTT报文并交由client处理
+type filter struct {
+	netx.BaseFilter
+	broker *Broker
+}
+
+func (f *filter) Name() string {
+	return "mqtt-broker"
+}
+
+func (f *filter) HandleRead(ctx netx.FilterCtx) error {
+	buf, ok := ctx.Data().(bytex.Buffer)
+	if !ok {
+		return nil
+	}
+
+	conn := ctx.Conn()
+	c := conn.Attributes().Get(kConnKeyClient, func() interface{} {
+		return newClient(f.broker, conn)
+	}).(*client)
+	c.read(buf)
+	return nil
+}
+
+func (f *filter) HandleClose(ctx netx.FilterCtx) error {
+	if c, ok := ctx.Conn().Attributes().Get(kConnKeyClient, nil).(*client); ok {
+		atomic.StoreInt32(&c.closed, 1)
+		f.broker.detach(c)
+	}
+
+	return nil
+}
+
+// client 连接状态,报文均在读协程中处理
+//	连接确认后version,mode等字段不再变化,可在其他协程中发送消息
+type client struct {
+	broker    *Broker
+	conn      netx.Conn
+	mode      int               // 连接类型
+	ws        websocket.Reader  // websocket帧解析
+	stream    []byte            // websocket中未完成的报文,报文可以跨越多个websocket消息
+	version   byte              // 协议版本
+	id        string            // ClientID
+	session   *session          // 绑定的会话,由Broker在锁内设置
+	connected bool              // 是否已经收到CONNECT
+	closed    int32             // 是否已经关闭
+	active    int64             // 最后收到数据的时间
+	keepAlive time.Duration     // 心跳间隔,0表示不检查
+	aliases   map[uint16]string // 5.0客户端设置的主题别名
+	quota     int               // 未确认消息上限
+	maxSize   int               // 客户端可接收报文最大长度,0表示不限制
+}
+
+func newClient(b *Broker, conn netx.Conn) *client {
+	return &client{
+		broker:  b,
+		conn:    conn,
+		version: mqtt.Version311,
+		ws:      websocket.Reader{MaxSize: b.opts.MaxPacketSize},
+	}
+}
+
+func (c *client) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) != 0
+}
+
+func (c *client) read(buf bytex.Buffer) {
+	if c.isClosed() {
+		_ = buf.ReadN(buf.Available())
+		buf.Discard()
+		return
+	}
+
+	atomic.StoreInt64(&c.active, time.Now().UnixNano())
+	err := c.parse(buf)
+	buf.Discard()
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// timeout 超过1.5倍心跳间隔未收到数据
+func (c *client) timeout(now time.Time) bool {
+	if c.keepAlive == 0 {
+		return false
+	}
+
+	last := time.Unix(0, atomic.LoadInt64(&c.active))
+	return now.Sub(last) > c.keepAlive*3/2
+}
+
+func (c *client) parse(buf bytex.Buffer) error {
+	if c.mode == modeUnknown {
+		var head [1]byte
+		if n, _ := buf.Peek(head[:]); n == 0 {
+			return nil
+		}
+		switch head[0] {
+		case byte(mqtt.CONNECT) << 4:
+			c.mode = modeTCP
+		case 'G':
+			c.mode = modeUpgrade
+		default:
+			return mqtt.ErrProtocol
+		}
+	}
+
+	if c.mode == modeUpgrade {
+		if ok, err := c.upgrade(buf); !ok || err != nil {
+			return err
+		}
+	}
+
+	if c.mode == modeWebSocket {
+		return c.parseWebSocket(buf)
+	}
+
+	maxSize := c.broker.opts.MaxPacketSize
+	for !c.isClosed() {
+		var head [5]byte
+		n := buf.Available()
+		if n > len(head) {
+			n = len(head)
+		}
+		_, _ = buf.Peek(head[:n])
+		size, _, err := mqtt.PacketSize(head[:n], maxSize)
+		if err != nil {
+			return err
+		}
+		if size == 0 || buf.Available() < size {
+			return nil
+		}
+
+		if err := c.handleData(buf.ReadN(size).Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *client) parseWebSocket(buf bytex.Buffer) error {
+	maxSize := c.broker.opts.MaxPacketSize
+	for !c.isClosed() {
+		data, err := c.ws.Read(c.conn, buf)
+		if err != nil || data == nil {
+			return err
+		}
+
+		c.stream = append(c.stream, data...)
+		for !c.isClosed() {
+			size, _, err := mqtt.PacketSize(c.stream, maxSize)
+			if err != nil {
+				return err
+			}
+			if size == 0 || len(c.stream) < size {
+				break
+			}
+			if err := c.handleData(c.stream[:size]); err != nil {
+				return err
+			}
+			c.stream = c.stream[size:]
+		}
+		if len(c.stream) == 0 {
+			c.stream = nil
+		}
+	}
+
+	return nil
+}
+
+// upgrade 处理websocket升级请求,子协议为mqtt
+func (c *client) upgrade(buf bytex.Buffer) (bool, error) {
+	proto := http1.New()
+	frame, err := proto.Decode(c.conn, buf)
+	if err != nil || frame == nil {
+		return false, err
+	}
+
+	key, err := websocket.CheckUpgrade(frame.Header())
+	if err != nil {
+		rsp := netx.NewResponse()
+		rsp.SetSeqID(frame.Identifier().SeqID)
+		rsp.SetStatus(400, "")
+		c.writeHTTP(rsp)
+		return false, err
+	}
+
+	subprotocol := ""
+	for _, p := range strings.Split(websocket.GetHeader(frame.Header(), "Sec-WebSocket-Protocol"), ",") {
+		if strings.TrimSpace(p) == "mqtt" {
+			subprotocol = "mqtt"
+		}
+	}
+
+	c.writeHTTP(websocket.NewUpgradeResponse(frame.Identifier().SeqID, key, subprotocol))
+	c.mode = modeWebSocket
+	return true, nil
+}
+
+func (c *client) writeHTTP(rsp netx.Response) {
+	pkt, ok := rsp.(netx.Packet)
+	if !ok {
+		return
+	}
+
+	frame := netx.NewFrame(netx.FrameTypeHeader, true, 0, pkt.Identifier(), pkt.Header(), nil)
+	out, err := http1.New().Encode(c.conn, frame)
+	if err != nil {
+		return
+	}
+	_, _ = out.Seek(0, io.SeekStart)
+	_ = c.conn.Send(out)
+}
+
+func (c *client) handleData(data []byte) error {
+	pkt, _, err := mqtt.ReadPacket(data, c.version, c.broker.opts.MaxPacketSize)
+	if err != nil {
+		return err
+	}
+	if !c.connected {
+		p, ok := pkt.(*mqtt.Connect)
+		if !ok {
+			return mqtt.ErrProtocol
+		}
+		return c.onConnect(p)
+	}
+
+	switch p := pkt.(type) {
+	case *mqtt.Publish:
+		c.onPublish(p)
+	case *mqtt.Ack:
+		c.onAck(p)
+	case *mqtt.Subscribe:
+		c.onSubscribe(p)
+	case *mqtt.Unsubscribe:
+		c.onUnsubscribe(p)
+	case *mqtt.Pingreq:
+		c.send(&mqtt.Pingresp{})
+	case *mqtt.Disconnect:
+		c.onDisconnect(p)
+	default:
+		// 重复的CONNECT,AUTH及服务端报文
+		return mqtt.ErrProtocol
+	}
+
+	return nil
+}
+
+// fail 解析或处理失败,5.0中发送DISCONNECT告知原因后关闭连接
+func (c *client) fail(err error) {
+	code := mqtt.ReasonProtocolError
+	switch err {
+	case mqtt.ErrMalformed:
+		code = mqtt.ReasonMalformedPacket
+	case mqtt.ErrPacketTooLarge:
+		code = mqtt.ReasonPacketTooLarge
+	}
+
+	c.disconnect(code)
+}
+
+// disconnect 关闭连接,5.0且已确认连接时先发送DISCONNECT
+func (c *client) disconnect(code mqtt.ReasonCode) {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return
+	}
+
+	if c.connected && c.version >= mqtt.Version5 {
+		c.send(&mqtt.Disconnect{ReasonCode: code})
+	}
+	_ = c.conn.Close()
+}
+
+func (c *client) close() {
+	atomic.StoreInt32(&c.closed, 1)
+	_ = c.conn.Close()
+}
+
+func (c *client) onConnect(p *mqtt.Connect) error {
+	opts := c.broker.opts
+	c.version = p.Version
+	v5 := c.version >= mqtt.Version5
+
+	ack := &mqtt.Connack{}
+	assigned := false
+	if p.ClientID == "" {
+		if !v5 && !p.CleanStart {
+			return c.reject(ack, mqtt.ReasonClientIDNotValid)
+		}
+		p.ClientID = "auto-" + xid.New().String()
+		assigned = true
+	}
+
+	if p.Props.Get(mqtt.PropAuthMethod) != nil {
+		return c.reject(ack, mqtt.ReasonBadAuthMethod)
+	}
+	if opts.Authenticator != nil {
+		if code := opts.Authenticator(context.Background(), p); code != mqtt.ReasonSuccess {
+			return c.reject(ack, code)
+		}
+	}
+	if p.WillFlag {
+		if !validTopicName(p.WillTopic) {
+			return c.reject(ack, mqtt.ReasonTopicNameInvalid)
+		}
+		if opts.ACL != nil && !opts.ACL(p.ClientID, p.WillTopic, true) {
+			return c.reject(ack, mqtt.ReasonNotAuthorized)
+		}
+	}
+
+	c.id = p.ClientID
+	c.quota = opts.MaxInflight
+	c.keepAlive = time.Duration(p.KeepAlive) * time.Second
+
+	// 3.1.1中clean session为0时会话永不过期
+	var expiry uint32
+	if v5 {
+		expiry = p.Props.Int(mqtt.PropSessionExpiry, 0)
+		// 5.0中服务端可以覆盖心跳间隔
+		if max := opts.MaxKeepAlive; max > 0 && (c.keepAlive == 0 || c.keepAlive > max) {
+			c.keepAlive = max
+			ack.Props.Set(mqtt.Property{ID: mqtt.PropServerKeepAlive, Int: uint32(max / time.Second)})
+		}
+		if n := int(p.Props.Int(mqtt.PropReceiveMaximum, 0)); n > 0 && n < c.quota {
+			c.quota = n
+		}
+		c.maxSize = int(p.Props.Int(mqtt.PropMaximumPacketSize, 0))
+	} else if !p.CleanStart {
+		expiry = math.MaxUint32
+	}
+
+	var will *Message
+	var willDelay uint32
+	if p.WillFlag {
+		will = &Message{Topic: p.WillTopic, Payload: p.WillPayload, QoS: p.WillQoS, Retain: p.WillRetain, From: c.id}
+		if v5 {
+			will.Props, will.Expire = messageProps(p.WillProps)
+			willDelay = p.WillProps.Int(mqtt.PropWillDelay, 0)
+		}
+	}
+
+	c.connected = true
+	s, present := c.broker.attach(c, p.CleanStart, expiry, will, willDelay)
+	if c.isClosed() {
+		// 绑定过程中连接已断开
+		c.broker.detach(c)
+		return nil
+	}
+
+	ack.SessionPresent = present
+	if v5 {
+		if assigned {
+			ack.Props.Set(mqtt.Property{ID: mqtt.PropAssignedClientID, Str: c.id})
+		}
+		if opts.MaxTopicAlias > 0 {
+			ack.Props.Set(mqtt.Property{ID: mqtt.PropTopicAliasMaximum, Int: uint32(opts.MaxTopicAlias)})
+		}
+		ack.Props.Set(mqtt.Property{ID: mqtt.PropMaximumPacketSize, Int: uint32(opts.MaxPacketSize)})
+		ack.Props.Set(mqtt.Property{ID: mqtt.PropSharedSubAvailable, Int: 0})
+	}
+	c.send(ack)
+
+	s.mux.Lock()
+	s.activate()
+	s.mux.Unlock()
+
+	if opts.OnConnect != nil {
+		opts.OnConnect(c.id)
+	}
+
+	return nil
+}
+
+// reject 拒绝连接,发送CONNACK后关闭
+func (c *client) reject(ack *mqtt.Connack, code mqtt.ReasonCode) error {
+	ack.ReasonCode = code
+	c.send(ack)
+	c.close()
+	return nil
+}
+
+func (c *client) onPublish(p *mqtt.Publish) {
+	opts := c.broker.opts
+	v5 := c.version >= mqtt.Version5
+
+	topic := p.Topic
+	if prop := p.Props.Get(mqtt.PropTopicAlias); v5 && prop != nil {
+		alias := uint16(prop.Int)
+		if alias == 0 || int(alias) > opts.MaxTopicAlias {
+			c.disconnect(mqtt.ReasonTopicAliasInvalid)
+			return
+		}
+		if topic == "" {
+			topic = c.aliases[alias]
+		} else {
+			if c.aliases == nil {
+				c.aliases = make(map[uint16]string)
+			}
+			c.aliases[alias] = topic
+		}
+	}
+	if !validTopicName(topic) {
+		c.disconnect(mqtt.ReasonTopicNameInvalid)
+		return
+	}
+
+	code := mqtt.ReasonSuccess
+	if opts.ACL != nil && !opts.ACL(c.id, topic, true) {
+		code = mqtt.ReasonNotAuthorized
+	}
+
+	msg := &Message{Topic: topic, Payload: p.Payload, QoS: p.QoS, Retain: p.Retain, From: c.id}
+	if v5 {
+		msg.Props, msg.Expire = messageProps(p.Props)
+	}
+
+	switch p.QoS {
+	case 0:
+		if code == mqtt.ReasonSuccess {
+			c.broker.route(msg)
+		}
+	case 1:
+		if code == mqtt.ReasonSuccess {
+			c.broker.route(msg)
+		}
+		c.send(&mqtt.Ack{PacketType: mqtt.PUBACK, PacketID: p.PacketID, ReasonCode: code})
+	case 2:
+		// 重发的QoS2消息不再投递
+		s := c.session
+		s.mux.Lock()
+		dup := s.received[p.PacketID]
+		if !dup && code == mqtt.ReasonSuccess {
+			s.received[p.PacketID] = true
+		}
+		s.mux.Unlock()
+		if !dup && code == mqtt.ReasonSuccess {
+			c.broker.route(msg)
+		}
+		c.send(&mqtt.Ack{PacketType: mqtt.PUBREC, PacketID: p.PacketID, ReasonCode: code})
+	}
+}
+
+func (c *client) onAck(p *mqtt.Ack) {
+	s := c.session
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	switch p.PacketType {
+	case mqtt.PUBACK:
+		s.ack(p.PacketID, 1)
+	case mqtt.PUBREC:
+		switch {
+		case p.ReasonCode.IsError():
+			s.ack(p.PacketID, 2)
+		case s.release(p.PacketID):
+			c.send(&mqtt.Ack{PacketType: mqtt.PUBREL, PacketID: p.PacketID})
+		default:
+			c.send(&mqtt.Ack{PacketType: mqtt.PUBREL, PacketID: p.PacketID, ReasonCode: mqtt.ReasonPacketIDNotFound})
+		}
+	case mqtt.PUBREL:
+		code := mqtt.ReasonSuccess
+		if s.received[p.PacketID] {
+			delete(s.received, p.PacketID)
+		} else {
+			code = mqtt.ReasonPacketIDNotFound
+		}
+		c.send(&mqtt.Ack{PacketType: mqtt.PUBCOMP, PacketID: p.PacketID, ReasonCode: code})
+	case mqtt.PUBCOMP:
+		s.ack(p.PacketID, 2)
+	}
+}
+
+func (c *client) onSubscribe(p *mqtt.Subscribe) {
+	opts := c.broker.opts
+	var subID uint32
+	if c.version >= mqtt.Version5 {
+		subID = p.Props.Int(mqtt.PropSubscriptionID, 0)
+	}
+
+	ack := &mqtt.Suback{PacketID: p.PacketID}
+	var retained []*delivery
+	for _, item := range p.Subscriptions {
+		code := mqtt.ReasonCode(item.QoS)
+		switch {
+		case !validTopicFilter(item.Topic):
+			code = mqtt.ReasonTopicFilterInvalid
+		case strings.HasPrefix(item.Topic, "$share/"):
+			code = mqtt.ReasonSharedSubNotSupported
+		case opts.ACL != nil && !opts.ACL(c.id, item.Topic, false):
+			code = mqtt.ReasonNotAuthorized
+		}
+		ack.ReasonCodes = append(ack.ReasonCodes, code)
+		if code.IsError() {
+			continue
+		}
+
+		sub := &subscription{
+			Filter:            item.Topic,
+			QoS:               item.QoS,
+			NoLocal:           item.NoLocal,
+			RetainAsPublished: item.RetainAsPublished,
+			RetainHandling:    item.RetainHandling,
+			SubID:             subID,
+		}
+		exists := c.broker.subscribe(c.session, sub)
+		if item.RetainHandling == 2 || (item.RetainHandling == 1 && exists) {
+			continue
+		}
+		for _, msg := range c.broker.matchRetained(item.Topic) {
+			d := &delivery{Msg: msg, QoS: minQoS(item.QoS, msg.QoS), Retain: true}
+			if subID != 0 {
+				d.SubIDs = []uint32{subID}
+			}
+			retained = append(retained, d)
+		}
+	}
+
+	// 保留消息需在SUBACK之后发送
+	c.send(ack)
+	if len(retained) > 0 {
+		s := c.session
+		s.mux.Lock()
+		for _, d := range retained {
+			s.deliver(d)
+		}
+		s.mux.Unlock()
+	}
+}
+
+func (c *client) onUnsubscribe(p *mqtt.Unsubscribe) {
+	ack := &mqtt.Unsuback{PacketID: p.PacketID}
+	for _, topic := range p.Topics {
+		code := mqtt.ReasonSuccess
+		if !c.broker.unsubscribe(c.session, topic) {
+			code = mqtt.ReasonNoSubscriptionExisted
+		}
+		ack.ReasonCodes = append(ack.ReasonCodes, code)
+	}
+
+	c.send(ack)
+}
+
+// onDisconnect 正常断开时不发送遗嘱,5.0中可以修改会话过期间隔
+func (c *client) onDisconnect(p *mqtt.Disconnect) {
+	s := c.session
+	s.mux.Lock()
+	if p.ReasonCode != mqtt.ReasonDisconnectWithWill {
+		s.will = nil
+	}
+	invalid := false
+	if prop := p.Props.Get(mqtt.PropSessionExpiry); prop != nil {
+		// CONNECT中过期间隔为0时不能再设置为非0
+		if s.expiry == 0 && prop.Int != 0 {
+			invalid = true
+		} else {
+			s.expiry = prop.Int
+		}
+	}
+	s.mux.Unlock()
+
+	if invalid {
+		c.disconnect(mqtt.ReasonProtocolError)
+		return
+	}
+	c.close()
+}
+
+// publish 发送PUBLISH,超过客户端报文长度限制时丢弃并返回false
+func (c *client) publish(d *delivery, dup bool) bool {
+	msg := d.Msg
+	pkt := &mqtt.Publish{
+		Dup:      dup && d.QoS > 0,
+		QoS:      d.QoS,
+		Retain:   d.Retain,
+		Topic:    msg.Topic,
+		PacketID: d.PacketID,
+		Payload:  msg.Payload,
+	}
+	if d.QoS == 0 {
+		pkt.PacketID = 0
+	}
+
+	if c.version >= mqtt.Version5 {
+		pkt.Props = append(mqtt.Properties(nil), msg.Props...)
+		if !msg.Expire.IsZero() {
+			left := time.Until(msg.Expire)
+			if left <= 0 {
+				return false
+			}
+			pkt.Props.Set(mqtt.Property{ID: mqtt.PropMessageExpiry, Int: uint32((left + time.Second - 1) / time.Second)})
+		}
+		for _, id := range d.SubIDs {
+			pkt.Props = append(pkt.Props, mqtt.Property{ID: mqtt.PropSubscriptionID, Int: id})
+		}
+	}
+
+	data := mqtt.Encode(pkt, c.version)
+	if c.maxSize > 0 && len(data) > c.maxSize {
+		return false
+	}
+
+	c.write(data)
+	return true
+}
+
+func (c *client) send(pkt mqtt.Packet) {
+	c.write(mqtt.Encode(pkt, c.version))
+}
+
+func (c *client) write(data []byte) {
+	var buf bytex.Buffer
+	if c.mode == modeWebSocket {
+		buf = websocket.NewFrame(websocket.OpBinary, data)
+	} else {
+		buf = bytex.NewBuffer()
+		_ = buf.Append(data)
+		_, _ = buf.Seek(0, io.SeekStart)
+	}
+
+	_ = c.conn.Send(buf)
+}
+
+// messageProps 转发的属性,去除主题别名及订阅标识,消息过期间隔转为过期时间
+func messageProps(props mqtt.Properties) (mqtt.Properties, time.Time) {
+	var out mqtt.Properties
+	var expire time.Time
+	for _, prop := range props {
+		switch prop.ID {
+		case mqtt.PropTopicAlias, mqtt.PropSubscriptionID, mqtt.PropWillDelay:
+		case mqtt.PropMessageExpiry:
+			expire = time.Now().Add(time.Duration(prop.Int) * time.Second)
+		default:
+			out = append(out, prop)
+		}
+	}
+
+	return out, expire
+}
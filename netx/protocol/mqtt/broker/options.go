@@ -0,0 +1,159 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/protocol/mqtt"
+	"github.com/foredata/nova/store"
+)
+
+const (
+	defaultAddr        = ":1883"
+	defaultMaxInflight = 32
+	defaultMaxQueued   = 1000
+	defaultMaxSize     = 1 << 20
+)
+
+// Authenticator 校验CONNECT,返回非成功原因码时拒绝连接
+type Authenticator func(ctx context.Context, pkt *mqtt.Connect) mqtt.ReasonCode
+
+// ACL 校验客户端是否可以发布(write为true)或订阅主题,订阅时topic为主题过滤器
+type ACL func(clientID string, topic string, write bool) bool
+
+// Options 可选参数
+type Options struct {
+	Addr          string                // 监听地址,默认:1883
+	TranFactory   netx.Factory          // 创建Transport,默认transport.New
+	Authenticator Authenticator         // 认证,默认不校验
+	ACL           ACL                   // 权限,默认不校验
+	OnConnect     func(clientID string) // 连接成功回调
+	OnDisconnect  func(clientID string) // 连接断开回调
+	MaxInflight   int                   // 每个客户端未确认的QoS1/2消息上限,默认32
+	MaxQueued     int                   // 每个会话排队消息上限,超过则丢弃,默认1000
+	MaxPacketSize int                   // 接收报文最大长度,默认1M
+	MaxKeepAlive  time.Duration         // 最大心跳间隔,0表示不限制
+	MaxTopicAlias int                   // 5.0主题别名上限,0表示不支持
+	Store         store.Store           // 会话及保留消息持久化,默认仅保存在内存中
+	Interval      time.Duration         // 心跳及会话过期检查间隔,默认1s
+}
+
+// Option .
+type Option func(o *Options)
+
+func newOptions(opts ...Option) *Options {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Addr == "" {
+		o.Addr = defaultAddr
+	}
+	if o.MaxInflight <= 0 {
+		o.MaxInflight = defaultMaxInflight
+	}
+	if o.MaxQueued <= 0 {
+		o.MaxQueued = defaultMaxQueued
+	}
+	if o.MaxPacketSize <= 0 {
+		o.MaxPacketSize = defaultMaxSize
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+
+	return o
+}
+
+// WithAddr 设置监听地址
+func WithAddr(addr string) Option {
+	return func(o *Options) {
+		o.Addr = addr
+	}
+}
+
+// WithTranFactory 设置Transport,websocket与tcp共用同一个端口,通过首字节区分
+func WithTranFactory(fn netx.Factory) Option {
+	return func(o *Options) {
+		o.TranFactory = fn
+	}
+}
+
+// WithAuthenticator 设置认证函数
+func WithAuthenticator(fn Authenticator) Option {
+	return func(o *Options) {
+		o.Authenticator = fn
+	}
+}
+
+// WithACL 设置权限校验函数
+func WithACL(fn ACL) Option {
+	return func(o *Options) {
+		o.ACL = fn
+	}
+}
+
+// WithOnConnect 设置连接成功回调
+func WithOnConnect(fn func(clientID string)) Option {
+	return func(o *Options) {
+		o.OnConnect = fn
+	}
+}
+
+// WithOnDisconnect 设置连接断开回调
+func WithOnDisconnect(fn func(clientID string)) Option {
+	return func(o *Options) {
+		o.OnDisconnect = fn
+	}
+}
+
+// WithMaxInflight 设置未确认消息上限
+func WithMaxInflight(n int) Option {
+	return func(o *Options) {
+		o.MaxInflight = n
+	}
+}
+
+// WithMaxQueued 设置排队消息上限
+func WithMaxQueued(n int) Option {
+	return func(o *Options) {
+		o.MaxQueued = n
+	}
+}
+
+// WithMaxPacketSize 设置接收报文最大长度
+func WithMaxPacketSize(size int) Option {
+	return func(o *Options) {
+		o.MaxPacketSize = size
+	}
+}
+
+// WithMaxKeepAlive 设置最大心跳间隔
+func WithMaxKeepAlive(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxKeepAlive = d
+	}
+}
+
+// WithMaxTopicAlias 设置主题别名上限
+func WithMaxTopicAlias(n int) Option {
+	return func(o *Options) {
+		o.MaxTopicAlias = n
+	}
+}
+
+// WithStore 设置持久化存储
+func WithStore(s store.Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// WithInterval 设置心跳及会话过期检查间隔
+func WithInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
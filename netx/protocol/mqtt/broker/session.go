@@ -0,0 +1,214 @@
+package broker
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/netx/protocol/mqtt"
+)
+
+// delivery 投递给会话的消息
+type delivery struct {
+	Msg      *Message // 消息
+	QoS      byte     // 订阅QoS与消息QoS的较小值
+	Retain   bool     // 转发时的retain标识
+	SubIDs   []uint32 // 匹配的订阅标识
+	PacketID uint16   // 已发送未确认时有效
+	Released bool     // QoS2已收到PUBREC并发送PUBREL
+}
+
+// sessionRecord 持久化的会话
+type sessionRecord struct {
+	ID       string          // ClientID
+	Expiry   uint32          // 会话过期间隔,秒
+	ExpireAt time.Time       // 过期时间,零值表示不过期
+	Subs     []*subscription // 订阅
+	Pending  []*delivery     // 未确认及排队消息
+	Will     *Message        // 尚未发送的延迟遗嘱
+	WillAt   time.Time       // 延迟遗嘱发送时间
+}
+
+// session 会话,连接断开后根据过期间隔保留
+//	QoS1/2消息按发送窗口发送,窗口满或离线时排队,QoS0消息离线时丢弃
+type session struct {
+	mux       sync.Mutex
+	broker    *Broker
+	id        string                   // ClientID
+	client    *client                  // 在线时的连接
+	active    bool                     // CONNACK已发送,可以投递消息
+	subs      map[string]*subscription // 过滤器对应的订阅
+	inflight  []*delivery              // 已发送未确认的消息,按发送顺序
+	queue     []*delivery              // 等待发送的消息
+	received  map[uint16]bool          // 已收到但未释放的QoS2报文标识
+	lastID    uint16                   // 最后分配的报文标识
+	expiry    uint32                   // 会话过期间隔,秒,math.MaxUint32表示不过期
+	expireAt  time.Time                // 离线后的过期时间
+	will      *Message                 // 当前连接的遗嘱,或离线后尚未发送的延迟遗嘱
+	willDelay uint32                   // 遗嘱延迟,秒
+	willAt    time.Time                // 延迟遗嘱发送时间
+}
+
+func newSession(b *Broker, id string) *session {
+	return &session{
+		broker:   b,
+		id:       id,
+		subs:     make(map[string]*subscription),
+		received: make(map[uint16]bool),
+	}
+}
+
+// detach 解除连接,以下函数均需在锁内调用
+func (s *session) detach() {
+	s.client = nil
+	s.active = false
+}
+
+// activate CONNACK发送后调用,重发未确认消息并发送排队消息
+func (s *session) activate() {
+	if s.client == nil {
+		return
+	}
+
+	s.active = true
+	for _, d := range s.inflight {
+		if d.Released {
+			s.client.send(&mqtt.Ack{PacketType: mqtt.PUBREL, PacketID: d.PacketID})
+		} else {
+			s.client.publish(d, true)
+		}
+	}
+	s.flush()
+}
+
+// deliver 投递消息,排队超过上限时丢弃
+func (s *session) deliver(d *delivery) {
+	if d.QoS == 0 {
+		if s.active {
+			s.client.publish(d, false)
+		}
+		return
+	}
+
+	if len(s.queue) >= s.broker.opts.MaxQueued {
+		return
+	}
+	s.queue = append(s.queue, d)
+	s.flush()
+}
+
+// flush 在发送窗口内发送排队消息,客户端无法接收的消息直接丢弃
+func (s *session) flush() {
+	if !s.active {
+		return
+	}
+
+	now := time.Now()
+	for len(s.queue) > 0 && len(s.inflight) < s.client.quota {
+		d := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		if d.Msg.expired(now) {
+			continue
+		}
+
+		d.PacketID = s.nextID()
+		if s.client.publish(d, false) {
+			s.inflight = append(s.inflight, d)
+		}
+	}
+
+	if len(s.queue) == 0 {
+		s.queue = nil
+	}
+}
+
+func (s *session) nextID() uint16 {
+	for {
+		s.lastID++
+		if s.lastID == 0 {
+			s.lastID = 1
+		}
+		if s.find(s.lastID) == -1 {
+			return s.lastID
+		}
+	}
+}
+
+func (s *session) find(id uint16) int {
+	for i, d := range s.inflight {
+		if d.PacketID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ack 收到PUBACK或PUBCOMP,删除未确认消息并继续发送
+func (s *session) ack(id uint16, qos byte) bool {
+	idx := s.find(id)
+	if idx == -1 || s.inflight[idx].QoS != qos {
+		return false
+	}
+
+	s.inflight = append(s.inflight[:idx], s.inflight[idx+1:]...)
+	s.flush()
+	return true
+}
+
+// release 收到PUBREC
+func (s *session) release(id uint16) bool {
+	idx := s.find(id)
+	if idx == -1 || s.inflight[idx].QoS != 2 {
+		return false
+	}
+
+	s.inflight[idx].Released = true
+	return true
+}
+
+// record 生成持久化数据,过期间隔为0的会话不需要持久化
+func (s *session) record(now time.Time) *sessionRecord {
+	if s.expiry == 0 {
+		return nil
+	}
+
+	rec := &sessionRecord{ID: s.id, Expiry: s.expiry, ExpireAt: s.expireAt, Will: s.will, WillAt: s.willAt}
+	if s.client != nil {
+		rec.Will = nil
+		if s.expiry != math.MaxUint32 {
+			rec.ExpireAt = now.Add(time.Duration(s.expiry) * time.Second)
+		}
+	}
+	for _, sub := range s.subs {
+		rec.Subs = append(rec.Subs, sub)
+	}
+	rec.Pending = append(rec.Pending, s.inflight...)
+	rec.Pending = append(rec.Pending, s.queue...)
+	return rec
+}
+
+// restore 从持久化数据恢复
+func (s *session) restore(rec *sessionRecord, now time.Time) {
+	s.expiry = rec.Expiry
+	s.expireAt = rec.ExpireAt
+	s.will = rec.Will
+	s.willAt = rec.WillAt
+	for _, sub := range rec.Subs {
+		s.subs[sub.Filter] = sub
+	}
+	for _, d := range rec.Pending {
+		if d.Msg == nil || d.Msg.expired(now) {
+			continue
+		}
+		if d.PacketID == 0 {
+			s.queue = append(s.queue, d)
+			continue
+		}
+		s.inflight = append(s.inflight, d)
+		if d.PacketID > s.lastID {
+			s.lastID = d.PacketID
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package broker
+
+import (
+	"strings"
+)
+
+// validTopicName 发布主题不能为空,不能包含通配符
+func validTopicName(topic string) bool {
+	return topic != "" && !strings.ContainsAny(topic, "+#")
+}
+
+// validTopicFilter +需占据整个层级,#需位于最后且占据整个层级
+func validTopicFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#":
+			if i != len(levels)-1 {
+				return false
+			}
+		case level == "+":
+		case strings.ContainsAny(level, "+#"):
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchTopic 判断主题是否匹配过滤器,以$开头的主题不匹配以通配符开头的过滤器
+func matchTopic(filter string, topic string) bool {
+	if topic != "" && topic[0] == '$' && filter != "" && (filter[0] == '+' || filter[0] == '#') {
+		return false
+	}
+
+	fl := strings.Split(filter, "/")
+	tl := strings.Split(topic, "/")
+	for i, level := range fl {
+		if level == "#" {
+			return true
+		}
+		if i >= len(tl) {
+			return false
+		}
+		if level != "+" && level != tl[i] {
+			return false
+		}
+	}
+
+	return len(fl) == len(tl)
+}
+
+// subscription 会话中的一个订阅
+type subscription struct {
+	Filter            string // 主题过滤器
+	QoS               byte   // 最大QoS
+	NoLocal           bool   // 不接收自己发布的消息
+	RetainAsPublished bool   // 转发时保持retain标识
+	RetainHandling    byte   // 保留消息发送方式
+	SubID             uint32 // 订阅标识,0表示没有
+}
+
+// node 主题树节点,按层级拆分
+type node struct {
+	children map[string]*node
+	subs     map[*session]*subscription
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node), subs: make(map[*session]*subscription)}
+}
+
+// trie 订阅树,非线程安全
+type trie struct {
+	root *node
+}
+
+func newTrie() *trie {
+	return &trie{root: newNode()}
+}
+
+// add 添加订阅,返回是否已存在
+func (t *trie) add(s *session, sub *subscription) bool {
+	n := t.root
+	for _, level := range strings.Split(sub.Filter, "/") {
+		child := n.children[level]
+		if child == nil {
+			child = newNode()
+			n.children[level] = child
+		}
+		n = child
+	}
+
+	_, ok := n.subs[s]
+	n.subs[s] = sub
+	return ok
+}
+
+// remove 删除订阅,并清理空节点,返回是否存在
+func (t *trie) remove(s *session, filter string) bool {
+	return t.removeLevel(t.root, s, strings.Split(filter, "/"))
+}
+
+func (t *trie) removeLevel(n *node, s *session, levels []string) bool {
+	if len(levels) == 0 {
+		_, ok := n.subs[s]
+		delete(n.subs, s)
+		return ok
+	}
+
+	child := n.children[levels[0]]
+	if child == nil {
+		return false
+	}
+	ok := t.removeLevel(child, s, levels[1:])
+	if len(child.subs) == 0 && len(child.children) == 0 {
+		delete(n.children, levels[0])
+	}
+
+	return ok
+}
+
+// match 遍历匹配主题的所有订阅,同一会话可能有多个订阅匹配
+func (t *trie) match(topic string, fn func(s *session, sub *subscription)) {
+	levels := strings.Split(topic, "/")
+	t.matchLevel(t.root, levels, 0, topic[0] == '$', fn)
+}
+
+func (t *trie) matchLevel(n *node, levels []string, i int, sys bool, fn func(s *session, sub *subscription)) {
+	wildcard := !(sys && i == 0)
+	if i == len(levels) {
+		visit(n, fn)
+		// a/#同时匹配a
+		if child := n.children["#"]; child != nil {
+			visit(child, fn)
+		}
+		return
+	}
+
+	if child := n.children[levels[i]]; child != nil {
+		t.matchLevel(child, levels, i+1, sys, fn)
+	}
+	if !wildcard {
+		return
+	}
+	if child := n.children["+"]; child != nil {
+		t.matchLevel(child, levels, i+1, sys, fn)
+	}
+	if child := n.children["#"]; child != nil {
+		visit(child, fn)
+	}
+}
+
+func visit(n *node, fn func(s *session, sub *subscription)) {
+	for s, sub := range n.subs {
+		fn(s, sub)
+	}
+}
@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTopicFilter(t *testing.T) {
+	for filter, valid := range map[string]bool{
+		"a/b": true, "a/+/c": true, "#": true, "a/#": true, "+": true, "/": true,
+		"": false, "a/#/c": false, "a+": false, "a/b#": false,
+	} {
+		if validTopicFilter(filter) != valid {
+			t.Errorf("bad filter, %s", filter)
+		}
+	}
+
+	for _, c := range []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"+/+", "/a", true},
+		{"#", "$SYS/a", false},
+		{"+/a", "$SYS/a", false},
+		{"$SYS/#", "$SYS/a", true},
+	} {
+		if matchTopic(c.filter, c.topic) != c.match {
+			t.Errorf("bad match, %s, %s", c.filter, c.topic)
+		}
+	}
+}
+
+func TestTrie(t *testing.T) {
+	tr := newTrie()
+	s1 := newSession(nil, "s1")
+	s2 := newSession(nil, "s2")
+	filters := []string{"a/b", "a/+", "a/#", "#", "+/b", "$SYS/#"}
+	for _, filter := range filters {
+		tr.add(s1, &subscription{Filter: filter})
+	}
+	if !tr.add(s1, &subscription{Filter: "a/b"}) {
+		t.Errorf("subscription should exist")
+	}
+	tr.add(s2, &subscription{Filter: "a/b"})
+
+	match := func(topic string) string {
+		var res []string
+		tr.match(topic, func(s *session, sub *subscription) {
+			res = append(res, s.id+":"+sub.Filter)
+		})
+		sort.Strings(res)
+		return strings.Join(res, ",")
+	}
+
+	if got := match("a/b"); got != "s1:#,s1:+/b,s1:a/#,s1:a/+,s1:a/b,s2:a/b" {
+		t.Errorf("bad match, %s", got)
+	}
+	if got := match("a"); got != "s1:#,s1:a/#" {
+		t.Errorf("bad match, %s", got)
+	}
+	if got := match("$SYS/x"); got != "s1:$SYS/#" {
+		t.Errorf("bad match, %s", got)
+	}
+
+	for _, filter := range filters {
+		tr.remove(s1, filter)
+	}
+	if tr.remove(s1, "a/b") {
+		t.Errorf("subscription should not exist")
+	}
+	tr.remove(s2, "a/b")
+	if len(tr.root.children) != 0 {
+		t.Errorf("empty node should be removed")
+	}
+}
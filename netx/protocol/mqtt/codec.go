@@ -0,0 +1,152 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+// reader 顺序读取报文内容,出错后后续读取均返回零值,由调用方统一检查err
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *reader) fail() {
+	if r.err == nil {
+		r.err = ErrMalformed
+	}
+	r.pos = len(r.data)
+}
+
+func (r *reader) byte() byte {
+	if r.remaining() < 1 {
+		r.fail()
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) uint16() uint16 {
+	if r.remaining() < 2 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *reader) uint32() uint32 {
+	if r.remaining() < 4 {
+		r.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v
+}
+
+func (r *reader) varint() int {
+	v, n, err := readVarint(r.data[r.pos:])
+	if err != nil {
+		r.fail()
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+// binary 读取2字节长度前缀的二进制数据,返回拷贝
+func (r *reader) binary() []byte {
+	n := int(r.uint16())
+	if r.remaining() < n {
+		r.fail()
+		return nil
+	}
+	data := append([]byte{}, r.data[r.pos:r.pos+n]...)
+	r.pos += n
+	return data
+}
+
+// string 读取UTF-8字符串,不允许包含U+0000
+func (r *reader) string() string {
+	data := r.binary()
+	if r.err != nil {
+		return ""
+	}
+	if !utf8.Valid(data) {
+		r.fail()
+		return ""
+	}
+	for _, c := range data {
+		if c == 0 {
+			r.fail()
+			return ""
+		}
+	}
+
+	return string(data)
+}
+
+// rest 读取剩余全部数据,返回拷贝
+func (r *reader) rest() []byte {
+	data := append([]byte{}, r.data[r.pos:]...)
+	r.pos = len(r.data)
+	return data
+}
+
+// readVarint 读取变长整数,最多4字节
+func readVarint(data []byte) (int, int, error) {
+	v := 0
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, errIncomplete
+		}
+		c := data[i]
+		v |= int(c&0x7f) << (7 * i)
+		if c&0x80 == 0 {
+			return v, i + 1, nil
+		}
+	}
+
+	return 0, 0, ErrMalformed
+}
+
+func appendVarint(w []byte, v int) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v > 0 {
+			c |= 0x80
+		}
+		w = append(w, c)
+		if v == 0 {
+			return w
+		}
+	}
+}
+
+func appendUint16(w []byte, v uint16) []byte {
+	return append(w, byte(v>>8), byte(v))
+}
+
+func appendUint32(w []byte, v uint32) []byte {
+	return append(w, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendBinary(w []byte, data []byte) []byte {
+	w = appendUint16(w, uint16(len(data)))
+	return append(w, data...)
+}
+
+func appendString(w []byte, s string) []byte {
+	w = appendUint16(w, uint16(len(s)))
+	return append(w, s...)
+}
@@ -0,0 +1,243 @@
+// Package mqtt MQTT 3.1.1及5.0协议编解码
+//	http://docs.oasis-open.org/mqtt/mqtt/v3.1.1/os/mqtt-v3.1.1-os.html
+//	https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html
+//	除CONNECT外,报文格式依赖连接使用的协议版本,由CONNECT中的协议级别决定
+//	broker实现见子包broker
+package mqtt
+
+import (
+	"errors"
+)
+
+// 协议级别
+const (
+	Version31  byte = 3 // 3.1,仅用于识别,按3.1.1解析
+	Version311 byte = 4
+	Version5   byte = 5
+)
+
+// MaxRemainingLength 剩余长度最大值
+const MaxRemainingLength = 268435455
+
+// some error
+var (
+	ErrMalformed      = errors.New("mqtt: malformed packet")
+	ErrPacketTooLarge = errors.New("mqtt: packet too large")
+	ErrProtocol       = errors.New("mqtt: protocol error")
+	errIncomplete     = errors.New("mqtt: incomplete")
+)
+
+// PacketType 控制报文类型
+type PacketType byte
+
+// 控制报文类型
+const (
+	CONNECT     PacketType = 1
+	CONNACK     PacketType = 2
+	PUBLISH     PacketType = 3
+	PUBACK      PacketType = 4
+	PUBREC      PacketType = 5
+	PUBREL      PacketType = 6
+	PUBCOMP     PacketType = 7
+	SUBSCRIBE   PacketType = 8
+	SUBACK      PacketType = 9
+	UNSUBSCRIBE PacketType = 10
+	UNSUBACK    PacketType = 11
+	PINGREQ     PacketType = 12
+	PINGRESP    PacketType = 13
+	DISCONNECT  PacketType = 14
+	AUTH        PacketType = 15
+)
+
+var gTypeNames = [...]string{
+	"reserved", "connect", "connack", "publish", "puback", "pubrec", "pubrel", "pubcomp",
+	"subscribe", "suback", "unsubscribe", "unsuback", "pingreq", "pingresp", "disconnect", "auth",
+}
+
+func (t PacketType) String() string {
+	if int(t) < len(gTypeNames) {
+		return gTypeNames[t]
+	}
+
+	return "unknown"
+}
+
+// ReasonCode 5.0中的原因码,3.1.1中CONNACK返回码及SUBACK结果也使用此类型
+type ReasonCode byte
+
+// 5.0原因码
+const (
+	ReasonSuccess                 ReasonCode = 0x00
+	ReasonGrantedQoS1             ReasonCode = 0x01
+	ReasonGrantedQoS2             ReasonCode = 0x02
+	ReasonDisconnectWithWill      ReasonCode = 0x04
+	ReasonNoMatchingSubscribers   ReasonCode = 0x10
+	ReasonNoSubscriptionExisted   ReasonCode = 0x11
+	ReasonContinueAuth            ReasonCode = 0x18
+	ReasonReAuthenticate          ReasonCode = 0x19
+	ReasonUnspecifiedError        ReasonCode = 0x80
+	ReasonMalformedPacket         ReasonCode = 0x81
+	ReasonProtocolError           ReasonCode = 0x82
+	ReasonImplementationError     ReasonCode = 0x83
+	ReasonUnsupportedVersion      ReasonCode = 0x84
+	ReasonClientIDNotValid        ReasonCode = 0x85
+	ReasonBadUsernameOrPassword   ReasonCode = 0x86
+	ReasonNotAuthorized           ReasonCode = 0x87
+	ReasonServerUnavailable       ReasonCode = 0x88
+	ReasonServerBusy              ReasonCode = 0x89
+	ReasonBanned                  ReasonCode = 0x8A
+	ReasonServerShuttingDown      ReasonCode = 0x8B
+	ReasonBadAuthMethod           ReasonCode = 0x8C
+	ReasonKeepAliveTimeout        ReasonCode = 0x8D
+	ReasonSessionTakenOver        ReasonCode = 0x8E
+	ReasonTopicFilterInvalid      ReasonCode = 0x8F
+	ReasonTopicNameInvalid        ReasonCode = 0x90
+	ReasonPacketIDInUse           ReasonCode = 0x91
+	ReasonPacketIDNotFound        ReasonCode = 0x92
+	ReasonReceiveMaximumExceeded  ReasonCode = 0x93
+	ReasonTopicAliasInvalid       ReasonCode = 0x94
+	ReasonPacketTooLarge          ReasonCode = 0x95
+	ReasonQuotaExceeded           ReasonCode = 0x97
+	ReasonPayloadFormatInvalid    ReasonCode = 0x99
+	ReasonRetainNotSupported      ReasonCode = 0x9A
+	ReasonQoSNotSupported         ReasonCode = 0x9B
+	ReasonSharedSubNotSupported   ReasonCode = 0x9E
+	ReasonSubIDNotSupported       ReasonCode = 0xA1
+	ReasonWildcardSubNotSupported ReasonCode = 0xA2
+	ReasonNormalDisconnection                = ReasonSuccess
+	ReasonGrantedQoS0                        = ReasonSuccess
+)
+
+// IsError 大于等于0x80表示失败
+func (c ReasonCode) IsError() bool {
+	return c >= 0x80
+}
+
+// ConnackCode 将5.0原因码转换为3.1.1的CONNACK返回码
+func ConnackCode(c ReasonCode, version byte) ReasonCode {
+	if version >= Version5 || c == ReasonSuccess {
+		return c
+	}
+
+	switch c {
+	case ReasonUnsupportedVersion:
+		return 0x01
+	case ReasonClientIDNotValid:
+		return 0x02
+	case ReasonBadUsernameOrPassword:
+		return 0x04
+	case ReasonNotAuthorized, ReasonBanned:
+		return 0x05
+	}
+
+	return 0x03
+}
+
+// Packet 控制报文
+type Packet interface {
+	Type() PacketType
+	// decode 解析可变头和载荷,flags为固定头低4位
+	decode(r *reader, flags byte, version byte) error
+	// encode 编码可变头和载荷,返回固定头低4位
+	encode(w []byte, version byte) (byte, []byte)
+}
+
+// Encode 按指定协议版本编码报文
+func Encode(pkt Packet, version byte) []byte {
+	flags, body := pkt.encode(nil, version)
+	head := make([]byte, 0, 5+len(body))
+	head = append(head, byte(pkt.Type())<<4|flags)
+	head = appendVarint(head, len(body))
+	return append(head, body...)
+}
+
+// Decode 解析一个完整报文
+func Decode(data []byte, version byte) (Packet, error) {
+	pkt, _, err := ReadPacket(data, version, MaxRemainingLength)
+	if pkt == nil && err == nil {
+		err = ErrMalformed
+	}
+
+	return pkt, err
+}
+
+// ReadPacket 从data中解析一个报文,返回消耗的字节数,数据不足时返回0, nil
+//	maxSize限制剩余长度
+func ReadPacket(data []byte, version byte, maxSize int) (Packet, int, error) {
+	size, hsize, err := PacketSize(data, maxSize)
+	if err != nil || size == 0 || len(data) < size {
+		return nil, 0, err
+	}
+
+	pkt := newPacket(PacketType(data[0] >> 4))
+	if pkt == nil {
+		return nil, 0, ErrMalformed
+	}
+
+	if version == Version31 {
+		version = Version311
+	}
+
+	r := &reader{data: data[hsize:size]}
+	if err := pkt.decode(r, data[0]&0x0f, version); err != nil {
+		return nil, 0, err
+	}
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+
+	return pkt, size, nil
+}
+
+// PacketSize 根据固定头计算报文总长度及固定头长度,固定头不完整时返回0
+//	仅需预读最多5字节即可确定报文长度
+func PacketSize(data []byte, maxSize int) (int, int, error) {
+	if len(data) < 2 {
+		return 0, 0, nil
+	}
+
+	length, n, err := readVarint(data[1:])
+	if err == errIncomplete {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if length > maxSize {
+		return 0, 0, ErrPacketTooLarge
+	}
+
+	hsize := 1 + n
+	return hsize + length, hsize, nil
+}
+
+func newPacket(t PacketType) Packet {
+	switch t {
+	case CONNECT:
+		return &Connect{}
+	case CONNACK:
+		return &Connack{}
+	case PUBLISH:
+		return &Publish{}
+	case PUBACK, PUBREC, PUBREL, PUBCOMP:
+		return &Ack{PacketType: t}
+	case SUBSCRIBE:
+		return &Subscribe{}
+	case SUBACK:
+		return &Suback{}
+	case UNSUBSCRIBE:
+		return &Unsubscribe{}
+	case UNSUBACK:
+		return &Unsuback{}
+	case PINGREQ:
+		return &Pingreq{}
+	case PINGRESP:
+		return &Pingresp{}
+	case DISCONNECT:
+		return &Disconnect{}
+	case AUTH:
+		return &Auth{}
+	}
+
+	return nil
+}
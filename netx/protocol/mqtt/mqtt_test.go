@@ -0,0 +1,143 @@
+package mqtt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	for _, v := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, MaxRemainingLength} {
+		data := appendVarint(nil, v)
+		got, n, err := readVarint(data)
+		if err != nil || got != v || n != len(data) {
+			t.Errorf("bad varint, %d, %d, %+v", v, got, err)
+		}
+	}
+
+	if _, _, err := readVarint([]byte{0x80, 0x80}); err != errIncomplete {
+		t.Errorf("should be incomplete, %+v", err)
+	}
+	if _, _, err := readVarint([]byte{0xff, 0xff, 0xff, 0xff, 0x7f}); err != ErrMalformed {
+		t.Errorf("should be malformed, %+v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	username := "user"
+	packets := []Packet{
+		&Connect{ProtocolName: "MQTT", Version: Version5, CleanStart: true, KeepAlive: 30, ClientID: "c1",
+			Props:    Properties{{ID: PropSessionExpiry, Int: 60}, {ID: PropUserProperty, Key: "k", Str: "v"}},
+			WillFlag: true, WillQoS: 1, WillRetain: true, WillTopic: "will", WillPayload: []byte("bye"),
+			WillProps: Properties{{ID: PropWillDelay, Int: 5}},
+			Username:  &username, Password: []byte("pass")},
+		&Connack{SessionPresent: true, ReasonCode: ReasonSuccess, Props: Properties{{ID: PropAssignedClientID, Str: "auto"}}},
+		&Publish{QoS: 2, Retain: true, Dup: true, Topic: "a/b", PacketID: 7, Payload: []byte("hello"),
+			Props: Properties{{ID: PropCorrelationData, Data: []byte{1, 2}}, {ID: PropSubscriptionID, Int: 300}}},
+		&Ack{PacketType: PUBACK, PacketID: 1},
+		&Ack{PacketType: PUBREL, PacketID: 2, ReasonCode: ReasonPacketIDNotFound},
+		&Subscribe{PacketID: 3, Props: Properties{{ID: PropSubscriptionID, Int: 1}},
+			Subscriptions: []Subscription{{Topic: "a/+", QoS: 1, NoLocal: true, RetainHandling: 2}, {Topic: "#", QoS: 2, RetainAsPublished: true}}},
+		&Suback{PacketID: 3, ReasonCodes: []ReasonCode{ReasonGrantedQoS1, ReasonNotAuthorized}},
+		&Unsubscribe{PacketID: 4, Topics: []string{"a/+", "#"}},
+		&Unsuback{PacketID: 4, ReasonCodes: []ReasonCode{ReasonSuccess, ReasonNoSubscriptionExisted}},
+		&Pingreq{},
+		&Pingresp{},
+		&Disconnect{ReasonCode: ReasonDisconnectWithWill},
+		&Auth{ReasonCode: ReasonContinueAuth, Props: Properties{{ID: PropAuthMethod, Str: "SCRAM"}}},
+	}
+
+	for _, pkt := range packets {
+		data := Encode(pkt, Version5)
+		got, err := Decode(data, Version5)
+		if err != nil {
+			t.Errorf("decode %s fail, %+v", pkt.Type(), err)
+			continue
+		}
+		if !reflect.DeepEqual(got, pkt) {
+			t.Errorf("bad %s, %+v, %+v", pkt.Type(), got, pkt)
+		}
+	}
+}
+
+func TestVersion311(t *testing.T) {
+	// 3.1.1中没有属性及原因码
+	pub := &Publish{QoS: 1, Topic: "t", PacketID: 1, Payload: []byte("x"), Props: Properties{{ID: PropContentType, Str: "text"}}}
+	data := Encode(pub, Version311)
+	if want := []byte{0x32, 6, 0, 1, 't', 0, 1, 'x'}; !reflect.DeepEqual(data, want) {
+		t.Errorf("bad publish, %v", data)
+	}
+
+	ack := Encode(&Connack{ReasonCode: ReasonNotAuthorized}, Version311)
+	if want := []byte{0x20, 2, 0, 5}; !reflect.DeepEqual(ack, want) {
+		t.Errorf("bad connack, %v", ack)
+	}
+
+	suback := Encode(&Suback{PacketID: 1, ReasonCodes: []ReasonCode{ReasonGrantedQoS2, ReasonNotAuthorized}}, Version311)
+	if want := []byte{0x90, 4, 0, 1, 2, 0x80}; !reflect.DeepEqual(suback, want) {
+		t.Errorf("bad suback, %v", suback)
+	}
+
+	// 3.1协议名
+	conn, err := Decode(Encode(&Connect{Version: Version31, ClientID: "old"}, Version31), Version311)
+	if err != nil || conn.(*Connect).ProtocolName != "MQIsdp" {
+		t.Errorf("bad connect, %+v, %+v", conn, err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	cases := [][]byte{
+		{0x30 | 0x06, 3, 0, 1, 't'},         // QoS 3
+		{0x80, 5, 0, 1, 0, 1, 'a', 0},       // SUBSCRIBE flags错误
+		{0x82, 6, 0, 1, 0, 1, 'a', 0x04},    // 3.1.1中保留位非0
+		{0x10, 6, 0, 4, 'M', 'Q', 'T', 'T'}, // CONNECT不完整
+		{0x30, 5, 0, 3, 'a', 0x00, 'b'},     // topic包含U+0000
+		{0xc0, 1, 0},                        // PINGREQ包含数据
+	}
+	for i, data := range cases {
+		if _, err := Decode(data, Version311); err == nil {
+			t.Errorf("case %d should fail", i)
+		}
+	}
+
+	if _, err := Decode([]byte{0x10, 10, 0, 4, 'M', 'Q', 'T', 'T', 9, 0, 0, 0}, Version311); err != ErrProtocol {
+		t.Errorf("should be protocol error, %+v", err)
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	data := Encode(&Publish{Topic: "a", Payload: make([]byte, 200)}, Version311)
+	for i := 0; i < len(data); i++ {
+		if pkt, n, err := ReadPacket(data[:i], Version311, MaxRemainingLength); pkt != nil || n != 0 || err != nil {
+			t.Fatalf("should be incomplete at %d", i)
+		}
+	}
+
+	data = append(data, Encode(&Pingreq{}, Version311)...)
+	pkt, n, err := ReadPacket(data, Version311, MaxRemainingLength)
+	if err != nil || pkt.Type() != PUBLISH || n != len(data)-2 {
+		t.Errorf("bad packet, %+v, %d, %+v", pkt, n, err)
+	}
+
+	if _, _, err := ReadPacket(data, Version311, 100); err != ErrPacketTooLarge {
+		t.Errorf("should be too large, %+v", err)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	var props Properties
+	props.Set(Property{ID: PropTopicAlias, Int: 1})
+	props.Set(Property{ID: PropTopicAlias, Int: 2})
+	props = append(props, Property{ID: PropUserProperty, Key: "a", Str: "1"}, Property{ID: PropUserProperty, Key: "b", Str: "2"})
+	if len(props) != 3 || props.Int(PropTopicAlias, 0) != 2 {
+		t.Errorf("bad props, %+v", props)
+	}
+	props.Del(PropUserProperty)
+	if len(props) != 1 || props.Get(PropUserProperty) != nil {
+		t.Errorf("bad props, %+v", props)
+	}
+
+	// 未知属性
+	if _, err := Decode([]byte{0xe0, 3, 0, 1, 0x7f}, Version5); err == nil {
+		t.Errorf("unknown property should fail")
+	}
+}
@@ -0,0 +1,581 @@
+package mqtt
+
+// Connect 连接请求,协议版本由报文自身决定
+type Connect struct {
+	ProtocolName string     // MQTT,3.1中为MQIsdp
+	Version      byte       // 协议级别
+	CleanStart   bool       // 3.1.1中为Clean Session
+	KeepAlive    uint16     // 秒
+	Props        Properties // 5.0属性
+	ClientID     string     // 客户端标识
+	WillFlag     bool       // 是否包含遗嘱
+	WillQoS      byte       // 遗嘱QoS
+	WillRetain   bool       // 遗嘱是否保留
+	WillProps    Properties // 遗嘱属性
+	WillTopic    string     // 遗嘱主题
+	WillPayload  []byte     // 遗嘱内容
+	Username     *string    // 用户名,nil表示不存在
+	Password     []byte     // 密码,nil表示不存在
+}
+
+// Type 报文类型
+func (p *Connect) Type() PacketType {
+	return CONNECT
+}
+
+func (p *Connect) decode(r *reader, flags byte, version byte) error {
+	if flags != 0 {
+		return ErrMalformed
+	}
+
+	p.ProtocolName = r.string()
+	p.Version = r.byte()
+	if r.err != nil {
+		return r.err
+	}
+	switch {
+	case p.ProtocolName == "MQTT" && (p.Version == Version311 || p.Version == Version5):
+	case p.ProtocolName == "MQIsdp" && p.Version == Version31:
+	default:
+		return ErrProtocol
+	}
+
+	cflags := r.byte()
+	if cflags&0x01 != 0 {
+		return ErrMalformed
+	}
+	p.CleanStart = cflags&0x02 != 0
+	p.WillFlag = cflags&0x04 != 0
+	p.WillQoS = (cflags >> 3) & 0x03
+	p.WillRetain = cflags&0x20 != 0
+	if p.WillQoS > 2 || (!p.WillFlag && (p.WillQoS != 0 || p.WillRetain)) {
+		return ErrMalformed
+	}
+
+	version = p.Version
+	if version == Version31 {
+		version = Version311
+	}
+
+	p.KeepAlive = r.uint16()
+	p.Props = readProperties(r, version)
+	p.ClientID = r.string()
+	if p.WillFlag {
+		p.WillProps = readProperties(r, version)
+		p.WillTopic = r.string()
+		p.WillPayload = r.binary()
+	}
+	if cflags&0x80 != 0 {
+		name := r.string()
+		p.Username = &name
+	}
+	if cflags&0x40 != 0 {
+		p.Password = r.binary()
+	}
+
+	return r.err
+}
+
+func (p *Connect) encode(w []byte, version byte) (byte, []byte) {
+	name, level := p.ProtocolName, p.Version
+	if level == 0 {
+		level = version
+	}
+	if name == "" {
+		name = "MQTT"
+		if level == Version31 {
+			name = "MQIsdp"
+		}
+	}
+
+	var cflags byte
+	if p.CleanStart {
+		cflags |= 0x02
+	}
+	if p.WillFlag {
+		cflags |= 0x04 | (p.WillQoS&0x03)<<3
+		if p.WillRetain {
+			cflags |= 0x20
+		}
+	}
+	if p.Password != nil {
+		cflags |= 0x40
+	}
+	if p.Username != nil {
+		cflags |= 0x80
+	}
+
+	w = appendString(w, name)
+	w = append(w, level, cflags)
+	w = appendUint16(w, p.KeepAlive)
+	w = appendProperties(w, p.Props, level)
+	w = appendString(w, p.ClientID)
+	if p.WillFlag {
+		w = appendProperties(w, p.WillProps, level)
+		w = appendString(w, p.WillTopic)
+		w = appendBinary(w, p.WillPayload)
+	}
+	if p.Username != nil {
+		w = appendString(w, *p.Username)
+	}
+	if p.Password != nil {
+		w = appendBinary(w, p.Password)
+	}
+
+	return 0, w
+}
+
+// Connack 连接应答
+type Connack struct {
+	SessionPresent bool       // 是否存在会话
+	ReasonCode     ReasonCode // 3.1.1中为返回码,见ConnackCode
+	Props          Properties // 5.0属性
+}
+
+// Type 报文类型
+func (p *Connack) Type() PacketType {
+	return CONNACK
+}
+
+func (p *Connack) decode(r *reader, flags byte, version byte) error {
+	if flags != 0 {
+		return ErrMalformed
+	}
+
+	ack := r.byte()
+	if ack&0xfe != 0 {
+		return ErrMalformed
+	}
+	p.SessionPresent = ack&0x01 != 0
+	p.ReasonCode = ReasonCode(r.byte())
+	p.Props = readProperties(r, version)
+	return r.err
+}
+
+func (p *Connack) encode(w []byte, version byte) (byte, []byte) {
+	var ack byte
+	if p.SessionPresent {
+		ack = 1
+	}
+	w = append(w, ack, byte(ConnackCode(p.ReasonCode, version)))
+	w = appendProperties(w, p.Props, version)
+	return 0, w
+}
+
+// Publish 发布消息
+type Publish struct {
+	Dup      bool       // 是否为重发
+	QoS      byte       // 服务质量
+	Retain   bool       // 是否保留
+	Topic    string     // 主题,5.0中使用主题别名时可以为空
+	PacketID uint16     // QoS大于0时有效
+	Props    Properties // 5.0属性
+	Payload  []byte     // 消息内容
+}
+
+// Type 报文类型
+func (p *Publish) Type() PacketType {
+	return PUBLISH
+}
+
+func (p *Publish) decode(r *reader, flags byte, version byte) error {
+	p.Dup = flags&0x08 != 0
+	p.QoS = (flags >> 1) & 0x03
+	p.Retain = flags&0x01 != 0
+	if p.QoS > 2 || (p.QoS == 0 && p.Dup) {
+		return ErrMalformed
+	}
+
+	p.Topic = r.string()
+	if p.QoS > 0 {
+		p.PacketID = r.uint16()
+		if r.err == nil && p.PacketID == 0 {
+			return ErrMalformed
+		}
+	}
+	p.Props = readProperties(r, version)
+	p.Payload = r.rest()
+	return r.err
+}
+
+func (p *Publish) encode(w []byte, version byte) (byte, []byte) {
+	flags := (p.QoS & 0x03) << 1
+	if p.Dup {
+		flags |= 0x08
+	}
+	if p.Retain {
+		flags |= 0x01
+	}
+
+	w = appendString(w, p.Topic)
+	if p.QoS > 0 {
+		w = appendUint16(w, p.PacketID)
+	}
+	w = appendProperties(w, p.Props, version)
+	w = append(w, p.Payload...)
+	return flags, w
+}
+
+// Ack PUBACK,PUBREC,PUBREL,PUBCOMP
+type Ack struct {
+	PacketType PacketType // 报文类型
+	PacketID   uint16     // 报文标识
+	ReasonCode ReasonCode // 5.0原因码
+	Props      Properties // 5.0属性
+}
+
+// Type 报文类型
+func (p *Ack) Type() PacketType {
+	return p.PacketType
+}
+
+func (p *Ack) decode(r *reader, flags byte, version byte) error {
+	if flags != p.fixedFlags() {
+		return ErrMalformed
+	}
+
+	p.PacketID = r.uint16()
+	if version >= Version5 {
+		// 原因码为0且没有属性时可以省略
+		if r.remaining() > 0 {
+			p.ReasonCode = ReasonCode(r.byte())
+		}
+		if r.remaining() > 0 {
+			p.Props = readProperties(r, version)
+		}
+	}
+
+	return r.err
+}
+
+func (p *Ack) encode(w []byte, version byte) (byte, []byte) {
+	w = appendUint16(w, p.PacketID)
+	if version >= Version5 && (p.ReasonCode != ReasonSuccess || len(p.Props) > 0) {
+		w = append(w, byte(p.ReasonCode))
+		if len(p.Props) > 0 {
+			w = appendProperties(w, p.Props, version)
+		}
+	}
+
+	return p.fixedFlags(), w
+}
+
+func (p *Ack) fixedFlags() byte {
+	if p.PacketType == PUBREL {
+		return 0x02
+	}
+
+	return 0
+}
+
+// Subscription 订阅项
+type Subscription struct {
+	Topic             string // 主题过滤器
+	QoS               byte   // 最大QoS
+	NoLocal           bool   // 5.0,不接收自己发布的消息
+	RetainAsPublished bool   // 5.0,转发时保持retain标识
+	RetainHandling    byte   // 5.0,0:总是发送保留消息,1:新订阅时发送,2:不发送
+}
+
+// Subscribe 订阅请求
+type Subscribe struct {
+	PacketID      uint16         // 报文标识
+	Props         Properties     // 5.0属性
+	Subscriptions []Subscription // 订阅列表,至少一项
+}
+
+// Type 报文类型
+func (p *Subscribe) Type() PacketType {
+	return SUBSCRIBE
+}
+
+func (p *Subscribe) decode(r *reader, flags byte, version byte) error {
+	if flags != 0x02 {
+		return ErrMalformed
+	}
+
+	p.PacketID = r.uint16()
+	p.Props = readProperties(r, version)
+	for r.remaining() > 0 && r.err == nil {
+		sub := Subscription{Topic: r.string()}
+		opts := r.byte()
+		sub.QoS = opts & 0x03
+		if version >= Version5 {
+			sub.NoLocal = opts&0x04 != 0
+			sub.RetainAsPublished = opts&0x08 != 0
+			sub.RetainHandling = (opts >> 4) & 0x03
+			if opts&0xc0 != 0 || sub.RetainHandling > 2 {
+				return ErrMalformed
+			}
+		} else if opts&0xfc != 0 {
+			return ErrMalformed
+		}
+		if sub.QoS > 2 {
+			return ErrMalformed
+		}
+		p.Subscriptions = append(p.Subscriptions, sub)
+	}
+
+	if r.err == nil && len(p.Subscriptions) == 0 {
+		return ErrProtocol
+	}
+
+	return r.err
+}
+
+func (p *Subscribe) encode(w []byte, version byte) (byte, []byte) {
+	w = appendUint16(w, p.PacketID)
+	w = appendProperties(w, p.Props, version)
+	for _, sub := range p.Subscriptions {
+		w = appendString(w, sub.Topic)
+		opts := sub.QoS & 0x03
+		if version >= Version5 {
+			if sub.NoLocal {
+				opts |= 0x04
+			}
+			if sub.RetainAsPublished {
+				opts |= 0x08
+			}
+			opts |= (sub.RetainHandling & 0x03) << 4
+		}
+		w = append(w, opts)
+	}
+
+	return 0x02, w
+}
+
+// Suback 订阅应答
+type Suback struct {
+	PacketID    uint16       // 报文标识
+	Props       Properties   // 5.0属性
+	ReasonCodes []ReasonCode // 与订阅项一一对应,3.1.1中失败为0x80
+}
+
+// Type 报文类型
+func (p *Suback) Type() PacketType {
+	return SUBACK
+}
+
+func (p *Suback) decode(r *reader, flags byte, version byte) error {
+	if flags != 0 {
+		return ErrMalformed
+	}
+
+	p.PacketID = r.uint16()
+	p.Props = readProperties(r, version)
+	for _, c := range r.rest() {
+		p.ReasonCodes = append(p.ReasonCodes, ReasonCode(c))
+	}
+
+	return r.err
+}
+
+func (p *Suback) encode(w []byte, version byte) (byte, []byte) {
+	w = appendUint16(w, p.PacketID)
+	w = appendProperties(w, p.Props, version)
+	for _, c := range p.ReasonCodes {
+		if version < Version5 && c.IsError() {
+			c = ReasonUnspecifiedError
+		}
+		w = append(w, byte(c))
+	}
+
+	return 0, w
+}
+
+// Unsubscribe 取消订阅
+type Unsubscribe struct {
+	PacketID uint16     // 报文标识
+	Props    Properties // 5.0属性
+	Topics   []string   // 主题过滤器,至少一项
+}
+
+// Type 报文类型
+func (p *Unsubscribe) Type() PacketType {
+	return UNSUBSCRIBE
+}
+
+func (p *Unsubscribe) decode(r *reader, flags byte, version byte) error {
+	if flags != 0x02 {
+		return ErrMalformed
+	}
+
+	p.PacketID = r.uint16()
+	p.Props = readProperties(r, version)
+	for r.remaining() > 0 && r.err == nil {
+		p.Topics = append(p.Topics, r.string())
+	}
+
+	if r.err == nil && len(p.Topics) == 0 {
+		return ErrProtocol
+	}
+
+	return r.err
+}
+
+func (p *Unsubscribe) encode(w []byte, version byte) (byte, []byte) {
+	w = appendUint16(w, p.PacketID)
+	w = appendProperties(w, p.Props, version)
+	for _, topic := range p.Topics {
+		w = appendString(w, topic)
+	}
+
+	return 0x02, w
+}
+
+// Unsuback 取消订阅应答
+type Unsuback struct {
+	PacketID    uint16       // 报文标识
+	Props       Properties   // 5.0属性
+	ReasonCodes []ReasonCode // 5.0,与主题一一对应
+}
+
+// Type 报文类型
+func (p *Unsuback) Type() PacketType {
+	return UNSUBACK
+}
+
+func (p *Unsuback) decode(r *reader, flags byte, version byte) error {
+	if flags != 0 {
+		return ErrMalformed
+	}
+
+	p.PacketID = r.uint16()
+	if version >= Version5 {
+		p.Props = readProperties(r, version)
+		for _, c := range r.rest() {
+			p.ReasonCodes = append(p.ReasonCodes, ReasonCode(c))
+		}
+	}
+
+	return r.err
+}
+
+func (p *Unsuback) encode(w []byte, version byte) (byte, []byte) {
+	w = appendUint16(w, p.PacketID)
+	if version >= Version5 {
+		w = appendProperties(w, p.Props, version)
+		for _, c := range p.ReasonCodes {
+			w = append(w, byte(c))
+		}
+	}
+
+	return 0, w
+}
+
+// Pingreq 心跳请求
+type Pingreq struct {
+}
+
+// Type 报文类型
+func (p *Pingreq) Type() PacketType {
+	return PINGREQ
+}
+
+func (p *Pingreq) decode(r *reader, flags byte, version byte) error {
+	if flags != 0 || r.remaining() != 0 {
+		return ErrMalformed
+	}
+
+	return nil
+}
+
+func (p *Pingreq) encode(w []byte, version byte) (byte, []byte) {
+	return 0, w
+}
+
+// Pingresp 心跳应答
+type Pingresp struct {
+}
+
+// Type 报文类型
+func (p *Pingresp) Type() PacketType {
+	return PINGRESP
+}
+
+func (p *Pingresp) decode(r *reader, flags byte, version byte) error {
+	if flags != 0 || r.remaining() != 0 {
+		return ErrMalformed
+	}
+
+	return nil
+}
+
+func (p *Pingresp) encode(w []byte, version byte) (byte, []byte) {
+	return 0, w
+}
+
+// Disconnect 断开连接,5.0中服务端也可以发送
+type Disconnect struct {
+	ReasonCode ReasonCode // 5.0原因码
+	Props      Properties // 5.0属性
+}
+
+// Type 报文类型
+func (p *Disconnect) Type() PacketType {
+	return DISCONNECT
+}
+
+func (p *Disconnect) decode(r *reader, flags byte, version byte) error {
+	if flags != 0 {
+		return ErrMalformed
+	}
+
+	if version >= Version5 {
+		if r.remaining() > 0 {
+			p.ReasonCode = ReasonCode(r.byte())
+		}
+		if r.remaining() > 0 {
+			p.Props = readProperties(r, version)
+		}
+	}
+
+	return r.err
+}
+
+func (p *Disconnect) encode(w []byte, version byte) (byte, []byte) {
+	if version >= Version5 && (p.ReasonCode != ReasonSuccess || len(p.Props) > 0) {
+		w = append(w, byte(p.ReasonCode))
+		if len(p.Props) > 0 {
+			w = appendProperties(w, p.Props, version)
+		}
+	}
+
+	return 0, w
+}
+
+// Auth 5.0扩展认证
+type Auth struct {
+	ReasonCode ReasonCode // 原因码
+	Props      Properties // 属性
+}
+
+// Type 报文类型
+func (p *Auth) Type() PacketType {
+	return AUTH
+}
+
+func (p *Auth) decode(r *reader, flags byte, version byte) error {
+	if version < Version5 {
+		return ErrProtocol
+	}
+	if flags != 0 {
+		return ErrMalformed
+	}
+
+	if r.remaining() > 0 {
+		p.ReasonCode = ReasonCode(r.byte())
+		p.Props = readProperties(r, version)
+	}
+
+	return r.err
+}
+
+func (p *Auth) encode(w []byte, version byte) (byte, []byte) {
+	if p.ReasonCode != ReasonSuccess || len(p.Props) > 0 {
+		w = append(w, byte(p.ReasonCode))
+		w = appendProperties(w, p.Props, version)
+	}
+
+	return 0, w
+}
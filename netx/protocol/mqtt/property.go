@@ -0,0 +1,225 @@
+package mqtt
+
+// PropertyID 5.0属性标识
+type PropertyID byte
+
+// 5.0属性
+const (
+	PropPayloadFormat        PropertyID = 0x01
+	PropMessageExpiry        PropertyID = 0x02
+	PropContentType          PropertyID = 0x03
+	PropResponseTopic        PropertyID = 0x08
+	PropCorrelationData      PropertyID = 0x09
+	PropSubscriptionID       PropertyID = 0x0B
+	PropSessionExpiry        PropertyID = 0x11
+	PropAssignedClientID     PropertyID = 0x12
+	PropServerKeepAlive      PropertyID = 0x13
+	PropAuthMethod           PropertyID = 0x15
+	PropAuthData             PropertyID = 0x16
+	PropRequestProblemInfo   PropertyID = 0x17
+	PropWillDelay            PropertyID = 0x18
+	PropRequestResponseInfo  PropertyID = 0x19
+	PropResponseInfo         PropertyID = 0x1A
+	PropServerReference      PropertyID = 0x1C
+	PropReasonString         PropertyID = 0x1F
+	PropReceiveMaximum       PropertyID = 0x21
+	PropTopicAliasMaximum    PropertyID = 0x22
+	PropTopicAlias           PropertyID = 0x23
+	PropMaximumQoS           PropertyID = 0x24
+	PropRetainAvailable      PropertyID = 0x25
+	PropUserProperty         PropertyID = 0x26
+	PropMaximumPacketSize    PropertyID = 0x27
+	PropWildcardSubAvailable PropertyID = 0x28
+	PropSubIDAvailable       PropertyID = 0x29
+	PropSharedSubAvailable   PropertyID = 0x2A
+)
+
+// 属性值编码类型
+const (
+	kindByte = iota + 1
+	kindUint16
+	kindUint32
+	kindVarint
+	kindString
+	kindBinary
+	kindPair
+)
+
+var gPropKinds = map[PropertyID]int{
+	PropPayloadFormat:        kindByte,
+	PropMessageExpiry:        kindUint32,
+	PropContentType:          kindString,
+	PropResponseTopic:        kindString,
+	PropCorrelationData:      kindBinary,
+	PropSubscriptionID:       kindVarint,
+	PropSessionExpiry:        kindUint32,
+	PropAssignedClientID:     kindString,
+	PropServerKeepAlive:      kindUint16,
+	PropAuthMethod:           kindString,
+	PropAuthData:             kindBinary,
+	PropRequestProblemInfo:   kindByte,
+	PropWillDelay:            kindUint32,
+	PropRequestResponseInfo:  kindByte,
+	PropResponseInfo:         kindString,
+	PropServerReference:      kindString,
+	PropReasonString:         kindString,
+	PropReceiveMaximum:       kindUint16,
+	PropTopicAliasMaximum:    kindUint16,
+	PropTopicAlias:           kindUint16,
+	PropMaximumQoS:           kindByte,
+	PropRetainAvailable:      kindByte,
+	PropUserProperty:         kindPair,
+	PropMaximumPacketSize:    kindUint32,
+	PropWildcardSubAvailable: kindByte,
+	PropSubIDAvailable:       kindByte,
+	PropSharedSubAvailable:   kindByte,
+}
+
+// Property 属性,根据类型使用不同字段
+type Property struct {
+	ID   PropertyID // 属性标识
+	Int  uint32     // 整数类型
+	Str  string     // 字符串类型,user property中为value
+	Key  string     // user property中的key
+	Data []byte     // 二进制类型
+}
+
+// Properties 属性列表,user property和subscription id可以出现多次
+type Properties []Property
+
+// Get 查找第一个指定属性
+func (p Properties) Get(id PropertyID) *Property {
+	for i := range p {
+		if p[i].ID == id {
+			return &p[i]
+		}
+	}
+
+	return nil
+}
+
+// Int 返回整数属性,不存在时返回def
+func (p Properties) Int(id PropertyID, def uint32) uint32 {
+	if prop := p.Get(id); prop != nil {
+		return prop.Int
+	}
+
+	return def
+}
+
+// String 返回字符串属性
+func (p Properties) String(id PropertyID) string {
+	if prop := p.Get(id); prop != nil {
+		return prop.Str
+	}
+
+	return ""
+}
+
+// Set 设置属性,已存在时覆盖
+func (p *Properties) Set(prop Property) {
+	if old := p.Get(prop.ID); old != nil {
+		*old = prop
+		return
+	}
+
+	*p = append(*p, prop)
+}
+
+// Del 删除所有指定属性
+func (p *Properties) Del(id PropertyID) {
+	props := (*p)[:0]
+	for _, prop := range *p {
+		if prop.ID != id {
+			props = append(props, prop)
+		}
+	}
+	*p = props
+}
+
+// readProperties 读取5.0属性,3.1.1中不存在属性
+func readProperties(r *reader, version byte) Properties {
+	if version < Version5 {
+		return nil
+	}
+
+	size := r.varint()
+	if r.err != nil {
+		return nil
+	}
+	if r.remaining() < size {
+		r.fail()
+		return nil
+	}
+
+	sub := &reader{data: r.data[r.pos : r.pos+size]}
+	r.pos += size
+
+	var props Properties
+	for sub.remaining() > 0 && sub.err == nil {
+		id := PropertyID(sub.varint())
+		prop := Property{ID: id}
+		switch gPropKinds[id] {
+		case kindByte:
+			prop.Int = uint32(sub.byte())
+		case kindUint16:
+			prop.Int = uint32(sub.uint16())
+		case kindUint32:
+			prop.Int = sub.uint32()
+		case kindVarint:
+			prop.Int = uint32(sub.varint())
+		case kindString:
+			prop.Str = sub.string()
+		case kindBinary:
+			prop.Data = sub.binary()
+		case kindPair:
+			prop.Key = sub.string()
+			prop.Str = sub.string()
+		default:
+			sub.fail()
+		}
+		props = append(props, prop)
+	}
+
+	if sub.err != nil {
+		r.fail()
+		return nil
+	}
+
+	return props
+}
+
+func appendProperties(w []byte, props Properties, version byte) []byte {
+	if version < Version5 {
+		return w
+	}
+
+	var body []byte
+	for _, prop := range props {
+		kind := gPropKinds[prop.ID]
+		if kind == 0 {
+			continue
+		}
+		body = appendVarint(body, int(prop.ID))
+		switch kind {
+		case kindByte:
+			body = append(body, byte(prop.Int))
+		case kindUint16:
+			body = appendUint16(body, uint16(prop.Int))
+		case kindUint32:
+			body = appendUint32(body, prop.Int)
+		case kindVarint:
+			body = appendVarint(body, int(prop.Int))
+		case kindString:
+			body = appendString(body, prop.Str)
+		case kindBinary:
+			body = appendBinary(body, prop.Data)
+		case kindPair:
+			body = appendString(body, prop.Key)
+			body = appendString(body, prop.Str)
+		}
+	}
+
+	w = appendVarint(w, len(body))
+	return append(w, body...)
+}
@@ -2,10 +2,14 @@ package protocol
 
 import (
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/compress"
+	"github.com/foredata/nova/netx/protocol/http1"
 	"github.com/foredata/nova/netx/protocol/rpc"
+	"github.com/foredata/nova/netx/protocol/theader"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
@@ -53,3 +57,130 @@ func TestProtocol(t *testing.T) {
 	f, _ := p.Decode(nil, buf)
 	t.Log(f)
 }
+
+func TestCompress(t *testing.T) {
+	data := strings.Repeat("nova compress payload ", 64)
+	protos := []netx.Protocol{rpc.New(), theader.New()}
+	for _, p := range protos {
+		for _, ctype := range []compress.Type{compress.TypeZlib, compress.TypeSnappy} {
+			ident := netx.NewIdentifier()
+			ident.SeqID = 1
+			ident.URI = "test"
+			ident.Compress = ctype
+			header := netx.NewHeader()
+			header.Add("a", "a")
+			payload := bytex.NewBuffer()
+			_ = payload.Append(data)
+			frame := netx.NewFrame(netx.FrameTypeHeader, true, 1, ident, header, payload)
+			buf, err := p.Encode(nil, frame)
+			if err != nil {
+				t.Fatalf("%s encode fail, %+v", p.Name(), err)
+			}
+			if buf.Len() >= len(data) {
+				t.Errorf("%s not compressed, %d", p.Name(), buf.Len())
+			}
+			_, _ = buf.Seek(0, io.SeekStart)
+			if !p.Detect(buf) {
+				t.Fatalf("%s detect fail", p.Name())
+			}
+			f, err := p.Decode(nil, buf)
+			if err != nil || f == nil {
+				t.Fatalf("%s decode fail, %+v", p.Name(), err)
+			}
+			if f.Identifier().Compress != ctype {
+				t.Errorf("%s bad compress type, %d", p.Name(), f.Identifier().Compress)
+			}
+			if got := string(f.Payload().Bytes()); got != data {
+				t.Errorf("%s bad payload, %d", p.Name(), len(got))
+			}
+			if f.Header().Get("a") != "a" {
+				t.Errorf("%s bad header", p.Name())
+			}
+		}
+	}
+}
+
+// TestDetectOrder 注册theader后rpc,http1仍能正确识别,且互不冲突
+func TestDetectOrder(t *testing.T) {
+	ident := netx.NewIdentifier()
+	ident.SeqID = 1
+	ident.URI = "test"
+	protos := []netx.Protocol{rpc.New(), theader.New(), http1.New()}
+	data := make([]bytex.Buffer, len(protos))
+	for i, p := range protos[:2] {
+		payload := bytex.NewBuffer()
+		_ = payload.Append("test")
+		buf, err := p.Encode(nil, netx.NewFrame(netx.FrameTypeHeader, true, 1, ident, netx.NewHeader(), payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[i] = buf
+	}
+	data[2] = bytex.NewBuffer()
+	_ = data[2].Append("POST /test HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\n\r\ntest")
+
+	for i, buf := range data {
+		_, _ = buf.Seek(0, io.SeekStart)
+		if d := Detect(buf); d == nil || d.Name() != protos[i].Name() {
+			t.Errorf("detect %s fail, %v", protos[i].Name(), d)
+		}
+		for j, p := range protos {
+			if j != i && p.Detect(buf) {
+				t.Errorf("%s detected as %s", protos[i].Name(), p.Name())
+			}
+		}
+	}
+}
+
+// sendConn 记录Send的数据
+type sendConn struct {
+	netx.Conn
+	sent []bytex.Buffer
+}
+
+func (c *sendConn) Send(msg interface{}) error {
+	c.sent = append(c.sent, msg.(bytex.Buffer))
+	return nil
+}
+
+func TestPing(t *testing.T) {
+	// 默认不发送心跳帧,兼容不支持心跳帧的旧版本
+	if _, ok := rpc.New().(netx.Pinger); ok {
+		t.Fatal("ping should be disabled by default")
+	}
+
+	p := rpc.New(rpc.WithPing())
+	conn := &sendConn{}
+	if err := p.(netx.Pinger).Ping(conn); err != nil || len(conn.sent) != 1 {
+		t.Fatalf("ping fail, %v", err)
+	}
+
+	// 心跳帧后跟普通消息,心跳自动应答,只返回普通消息
+	ident := netx.NewIdentifier()
+	ident.SeqID = 1
+	ident.URI = "test"
+	frame := netx.NewFrame(netx.FrameTypeHeader, true, 1, ident, netx.NewHeader(), nil)
+	msg, _ := p.Encode(nil, frame)
+	buf := conn.sent[0]
+	_, _ = buf.Seek(0, io.SeekStart)
+	if !p.Detect(buf) {
+		t.Fatal("detect ping fail")
+	}
+	_ = buf.Append(msg.Bytes())
+	_, _ = buf.Seek(0, io.SeekStart)
+
+	f, err := p.Decode(conn, buf)
+	if err != nil || f == nil || f.Identifier().URI != "test" {
+		t.Fatalf("decode fail, %v, %v", f, err)
+	}
+	if len(conn.sent) != 2 {
+		t.Fatal("no pong")
+	}
+
+	// 应答不会再次应答
+	pong := conn.sent[1]
+	_, _ = pong.Seek(0, io.SeekStart)
+	if f, err := p.Decode(conn, pong); f != nil || err != nil || len(conn.sent) != 2 {
+		t.Fatalf("bad pong, %v, %v", f, err)
+	}
+}
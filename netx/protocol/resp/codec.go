@@ -0,0 +1,256 @@
+package resp
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+)
+
+var crlf = []byte("\r\n")
+
+// maxDepth 聚合类型最大嵌套层数,避免恶意数据导致栈溢出
+const maxDepth = 64
+
+// parseValue 从data中解析一个完整数据,返回消耗的字节数,数据不足时返回errIncomplete
+//	maxSize限制bulk长度及聚合类型元素个数
+//	返回errIncomplete时,n为已知的至少需要的总长度,调用方可据此等待数据,避免重复解析
+func parseValue(data []byte, maxSize int) (Value, int, error) {
+	return parseDepth(data, maxSize, 0)
+}
+
+func parseDepth(data []byte, maxSize int, depth int) (Value, int, error) {
+	if depth > maxDepth {
+		return Value{}, 0, ErrDepthLimit
+	}
+
+	line, n, err := readLine(data)
+	if err != nil {
+		if err == errIncomplete {
+			return Value{}, len(data) + 1, err
+		}
+		return Value{}, 0, err
+	}
+	if len(line) == 0 {
+		return Value{}, 0, ErrProtocol
+	}
+
+	v := Value{Type: Type(line[0])}
+	text := line[1:]
+	switch v.Type {
+	case TypeSimpleString, TypeError, TypeBigNumber:
+		v.Str = append([]byte(nil), text...)
+	case TypeInteger:
+		if v.Int, err = strconv.ParseInt(string(text), 10, 64); err != nil {
+			return v, 0, ErrProtocol
+		}
+	case TypeNull:
+		if len(text) != 0 {
+			return v, 0, ErrProtocol
+		}
+	case TypeBoolean:
+		switch string(text) {
+		case "t":
+			v.Int = 1
+		case "f":
+		default:
+			return v, 0, ErrProtocol
+		}
+	case TypeDouble:
+		if v.Float, err = parseDouble(string(text)); err != nil {
+			return v, 0, ErrProtocol
+		}
+	case TypeBulkString, TypeBulkError, TypeVerbatim:
+		size, err := parseSize(text, maxSize)
+		if err != nil {
+			return v, 0, err
+		}
+		if size < 0 {
+			// RESP2 null bulk string
+			return Value{Type: TypeNull}, n, nil
+		}
+		if len(data) < n+size+2 {
+			return v, n + size + 2, errIncomplete
+		}
+		if !bytes.Equal(data[n+size:n+size+2], crlf) {
+			return v, 0, ErrProtocol
+		}
+		v.Str = append([]byte(nil), data[n:n+size]...)
+		n += size + 2
+	case TypeArray, TypeSet, TypePush, TypeMap, TypeAttribute:
+		count, err := parseSize(text, maxSize)
+		if err != nil {
+			return v, 0, err
+		}
+		if count < 0 {
+			// RESP2 null array
+			return Value{Type: TypeNull}, n, nil
+		}
+		if v.Type == TypeMap || v.Type == TypeAttribute {
+			count *= 2
+		}
+		// 每个元素至少3字节,避免恶意长度导致预分配过大内存
+		prealloc := count
+		if limit := (len(data) - n) / 3; prealloc > limit {
+			prealloc = limit
+		}
+		v.Elems = make([]Value, 0, prealloc)
+		for i := 0; i < count; i++ {
+			elem, m, err := parseDepth(data[n:], maxSize, depth+1)
+			if err != nil {
+				if err == errIncomplete {
+					return v, n + m, err
+				}
+				return v, 0, err
+			}
+			v.Elems = append(v.Elems, elem)
+			n += m
+		}
+	default:
+		return v, 0, ErrProtocol
+	}
+
+	return v, n, nil
+}
+
+// readLine 读取以\r\n结尾的一行,返回不含\r\n的内容
+func readLine(data []byte) ([]byte, int, error) {
+	idx := bytes.IndexByte(data, '\n')
+	if idx == -1 {
+		return nil, 0, errIncomplete
+	}
+	if idx == 0 || data[idx-1] != '\r' {
+		return nil, 0, ErrProtocol
+	}
+
+	return data[:idx-1], idx + 1, nil
+}
+
+func parseSize(text []byte, maxSize int) (int, error) {
+	size, err := strconv.Atoi(string(text))
+	if err != nil || size < -1 {
+		return 0, ErrProtocol
+	}
+	if size > maxSize {
+		return 0, ErrMessageTooLarge
+	}
+
+	return size, nil
+}
+
+func parseDouble(s string) (float64, error) {
+	switch s {
+	case "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan":
+		return math.NaN(), nil
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// appendValue 按指定版本编码,RESP2下RESP3类型会降级为相近类型,attribute会被忽略
+func appendValue(dst []byte, v Value, version int) []byte {
+	resp2 := version < Version3
+	switch v.Type {
+	case TypeSimpleString, TypeError, TypeBigNumber:
+		if resp2 && v.Type == TypeBigNumber {
+			return appendBulk(dst, TypeBulkString, v.Str)
+		}
+		dst = append(dst, byte(v.Type))
+		dst = append(dst, v.Str...)
+		return append(dst, crlf...)
+	case TypeInteger:
+		return appendHead(dst, TypeInteger, v.Int)
+	case TypeBulkString:
+		return appendBulk(dst, TypeBulkString, v.Str)
+	case TypeBulkError:
+		if resp2 {
+			dst = append(dst, byte(TypeError))
+			dst = append(dst, bytes.ReplaceAll(v.Str, crlf, []byte(" "))...)
+			return append(dst, crlf...)
+		}
+		return appendBulk(dst, TypeBulkError, v.Str)
+	case TypeVerbatim:
+		if resp2 {
+			str := v.Str
+			if len(str) >= 4 {
+				str = str[4:]
+			}
+			return appendBulk(dst, TypeBulkString, str)
+		}
+		return appendBulk(dst, TypeVerbatim, v.Str)
+	case TypeNull:
+		if resp2 {
+			return append(dst, "$-1\r\n"...)
+		}
+		return append(dst, "_\r\n"...)
+	case TypeBoolean:
+		if resp2 {
+			return appendHead(dst, TypeInteger, v.Int)
+		}
+		if v.Int != 0 {
+			return append(dst, "#t\r\n"...)
+		}
+		return append(dst, "#f\r\n"...)
+	case TypeDouble:
+		if resp2 {
+			return appendBulk(dst, TypeBulkString, []byte(formatDouble(v.Float)))
+		}
+		dst = append(dst, byte(TypeDouble))
+		dst = append(dst, formatDouble(v.Float)...)
+		return append(dst, crlf...)
+	case TypeArray, TypeSet, TypePush:
+		typ := v.Type
+		if resp2 {
+			typ = TypeArray
+		}
+		dst = appendHead(dst, typ, int64(len(v.Elems)))
+	case TypeMap:
+		if resp2 {
+			dst = appendHead(dst, TypeArray, int64(len(v.Elems)))
+		} else {
+			dst = appendHead(dst, TypeMap, int64(len(v.Elems)/2))
+		}
+	case TypeAttribute:
+		if resp2 {
+			return dst
+		}
+		dst = appendHead(dst, TypeAttribute, int64(len(v.Elems)/2))
+	default:
+		// 未知类型按simple string处理
+		return appendValue(dst, Value{Type: TypeSimpleString, Str: v.Str}, version)
+	}
+
+	for _, elem := range v.Elems {
+		dst = appendValue(dst, elem, version)
+	}
+
+	return dst
+}
+
+func appendHead(dst []byte, t Type, n int64) []byte {
+	dst = append(dst, byte(t))
+	dst = strconv.AppendInt(dst, n, 10)
+	return append(dst, crlf...)
+}
+
+func appendBulk(dst []byte, t Type, data []byte) []byte {
+	dst = appendHead(dst, t, int64(len(data)))
+	dst = append(dst, data...)
+	return append(dst, crlf...)
+}
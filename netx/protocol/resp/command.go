@@ -0,0 +1,110 @@
+package resp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// Args 解析请求中的命令,第一个参数为命令名,保持原始大小写
+func Args(req netx.Request) ([][]byte, error) {
+	v, err := bodyValue(req.Body())
+	if err != nil {
+		return nil, err
+	}
+	if v.Type != TypeArray || len(v.Elems) == 0 {
+		return nil, ErrInvalidCommand
+	}
+
+	args := make([][]byte, len(v.Elems))
+	for i, elem := range v.Elems {
+		args[i] = elem.Str
+	}
+
+	return args, nil
+}
+
+// NewResponse 创建应答,body按RESP3编码,发送时会按连接使用的版本转换
+func NewResponse(v Value) netx.Response {
+	rsp := netx.NewResponse()
+	rsp.SetBody(body.NewBufferBody(newPayload(Marshal(v))))
+	return rsp
+}
+
+// ResponseValue 客户端解析应答
+func ResponseValue(rsp netx.Response) (Value, error) {
+	return bodyValue(rsp.Body())
+}
+
+// NewRequest 客户端创建命令请求,参数支持[]byte,string,整数,浮点数,其他类型使用fmt.Sprint转换
+func NewRequest(args ...interface{}) netx.Request {
+	elems := make([]Value, len(args))
+	for i, arg := range args {
+		elems[i] = Bulk(toBytes(arg))
+	}
+
+	req := netx.NewRequest()
+	if len(elems) > 0 {
+		req.SetURI(strings.ToLower(string(elems[0].Str)))
+	}
+	req.SetBody(body.NewBufferBody(newPayload(appendValue(nil, Array(elems...), Version2))))
+	return req
+}
+
+// SendPush 向连接推送消息,例如pubsub中的message,RESP2连接上会以数组形式发送
+func SendPush(conn netx.Conn, kind string, elems ...Value) error {
+	return conn.Send(NewResponse(Push(kind, elems...)))
+}
+
+// UnknownCommand 用于NoRoute,对未注册的命令返回错误
+func UnknownCommand(ctx context.Context, req netx.Request) (netx.Response, error) {
+	return NewResponse(Error(fmt.Sprintf("ERR unknown command '%s'", req.URI()))), nil
+}
+
+func toBytes(arg interface{}) []byte {
+	switch v := arg.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case uint64:
+		return strconv.AppendUint(nil, v, 10)
+	case float64:
+		return []byte(formatDouble(v))
+	}
+
+	return []byte(fmt.Sprint(arg))
+}
+
+func bodyValue(bod netx.Body) (Value, error) {
+	if bod == nil {
+		return Value{}, ErrProtocol
+	}
+
+	var data []byte
+	if buf, err := bod.Buffer(); err == nil && buf != nil {
+		data = buf.Bytes()
+	} else if data, err = ioutil.ReadAll(bod); err != nil {
+		return Value{}, err
+	}
+
+	return Unmarshal(data)
+}
+
+func newPayload(data []byte) bytex.Buffer {
+	buf := bytex.NewBuffer()
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	return buf
+}
@@ -0,0 +1,327 @@
+package resp
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/pkg/unique"
+)
+
+const (
+	defaultMaxSize = 64 << 20 // 默认单个命令最大长度
+	peekSize       = 4096     // 首次预读长度,不足时翻倍
+)
+
+var (
+	// kConnKeySession conn中unique key
+	kConnKeySession = unique.NewKey(netx.KeyGroupConn, "resp-session")
+)
+
+// Options 可选参数
+type Options struct {
+	MaxSize int // 单个命令最大长度,同时限制bulk长度和数组元素个数
+}
+
+// Option .
+type Option func(o *Options)
+
+// WithMaxSize 设置单个命令最大长度
+func WithMaxSize(size int) Option {
+	return func(o *Options) {
+		o.MaxSize = size
+	}
+}
+
+// New 创建RESP协议
+func New(opts ...Option) netx.Protocol {
+	o := &Options{MaxSize: defaultMaxSize}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &respProtocol{opts: o}
+}
+
+// respProtocol 请求与应答一一对应,协议本身没有序号,有状态,每个Conn一个session
+//	服务端为每个命令分配SeqID,应答按SeqID顺序发送,SeqID为0的应答为推送消息,立即发送
+//	客户端按发送顺序匹配应答
+type respProtocol struct {
+	opts *Options
+}
+
+func (p *respProtocol) Name() string {
+	return "resp"
+}
+
+// Detect 命令以数组或bulk string开头
+func (p *respProtocol) Detect(peeker bytex.Peeker) bool {
+	var data [1]byte
+	if n, _ := peeker.Peek(data[:]); n != 1 {
+		return false
+	}
+
+	return data[0] == byte(TypeArray) || data[0] == byte(TypeBulkString)
+}
+
+func (p *respProtocol) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error) {
+	avail := buf.Available()
+	if avail == 0 {
+		return nil, nil
+	}
+
+	// 上次解析已知所需长度,数据不足时直接返回,避免大数据包每次到达都重新解析
+	s := p.session(conn)
+	if avail < s.need {
+		return nil, nil
+	}
+
+	// 从较小长度开始预读,避免pipeline中每个命令都拷贝全部数据
+	size := peekSize
+	var v Value
+	var n int
+	for {
+		if size > avail {
+			size = avail
+		}
+		data := make([]byte, size)
+		_, _ = buf.Peek(data)
+		var err error
+		v, n, err = parseValue(data, p.opts.MaxSize)
+		if err == nil {
+			break
+		}
+		if err != errIncomplete {
+			return nil, err
+		}
+		if n > p.opts.MaxSize {
+			return nil, ErrMessageTooLarge
+		}
+		if size == avail {
+			s.need = n
+			return nil, nil
+		}
+		size *= 2
+		if size < n {
+			size = n
+		}
+	}
+
+	s.need = 0
+	raw := buf.ReadN(n).Bytes()
+	raw = append([]byte(nil), raw...)
+
+	if conn != nil && conn.IsClient() {
+		return s.toResponse(v, raw), nil
+	}
+
+	return s.toRequest(v, raw)
+}
+
+func (p *respProtocol) Encode(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
+	ident := frame.Identifier()
+	if frame.Type() != netx.FrameTypeHeader || !frame.EndFlag() {
+		return nil, netx.ErrNotSupport
+	}
+	if ident == nil {
+		return nil, netx.ErrInvalidIdentifier
+	}
+
+	var payload []byte
+	if buf := frame.Payload(); buf != nil && !buf.Empty() {
+		payload = buf.Bytes()
+	}
+
+	s := p.session(conn)
+	var data []byte
+	if ident.IsResponse {
+		data = s.reply(ident, payload)
+	} else {
+		var err error
+		if data, err = s.request(ident, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	out := bytex.NewBuffer()
+	_ = out.Append(data)
+	return out, nil
+}
+
+// session 获取conn对应的session,conn为nil时(通常为测试)每次创建新的session
+func (p *respProtocol) session(conn netx.Conn) *session {
+	if conn == nil {
+		return newSession()
+	}
+
+	return conn.Attributes().Get(kConnKeySession, func() interface{} {
+		return newSession()
+	}).(*session)
+}
+
+// session 连接状态,读写可能在不同协程中,需要加锁
+type session struct {
+	mux     sync.Mutex
+	version int               // 协议版本,HELLO命令切换
+	seq     uint32            // 服务端最后分配的SeqID
+	next    uint32            // 服务端下一个待发送应答的SeqID
+	pending map[uint32]*reply // 服务端等待发送的应答
+	sent    []uint32          // 客户端已发送且等待应答的SeqID
+	need    int               // 解码所需的最小长度,仅在读协程中访问
+}
+
+// reply 服务端等待发送的应答
+type reply struct {
+	name    string // 命令名
+	version int    // 命令解析时的协议版本
+	data    []byte // 编码后的应答,nil表示尚未完成
+}
+
+func newSession() *session {
+	return &session{version: Version2, next: 1, pending: make(map[uint32]*reply)}
+}
+
+// toRequest 服务端将命令转换为请求frame,命令必须为非空bulk string数组
+func (s *session) toRequest(v Value, raw []byte) (netx.Frame, error) {
+	if v.Type != TypeArray || len(v.Elems) == 0 {
+		return nil, ErrInvalidCommand
+	}
+	for _, elem := range v.Elems {
+		if elem.Type != TypeBulkString {
+			return nil, ErrInvalidCommand
+		}
+	}
+
+	name := strings.ToLower(string(v.Elems[0].Str))
+
+	s.mux.Lock()
+	if name == "hello" && len(v.Elems) > 1 {
+		switch string(v.Elems[1].Str) {
+		case "2":
+			s.version = Version2
+		case "3":
+			s.version = Version3
+		}
+	}
+	s.seq++
+	if s.seq == 0 {
+		// 0用于推送消息
+		s.seq++
+	}
+	seqID := s.seq
+	s.pending[seqID] = &reply{name: name, version: s.version}
+	s.mux.Unlock()
+
+	ident := netx.NewIdentifier()
+	ident.URI = name
+	ident.SeqID = seqID
+	return netx.NewFrame(netx.FrameTypeHeader, true, 0, ident, nil, newPayload(raw)), nil
+}
+
+// reply 服务端编码应答,返回按顺序可发送的所有应答
+func (s *session) reply(ident *netx.Identifier, payload []byte) []byte {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	r := s.pending[ident.SeqID]
+	if r == nil {
+		// 推送消息或未知请求,立即发送
+		return encodeReply(ident, payload, "", s.version)
+	}
+	r.data = encodeReply(ident, payload, r.name, r.version)
+
+	var data []byte
+	for {
+		r := s.pending[s.next]
+		if r == nil || r.data == nil {
+			break
+		}
+		delete(s.pending, s.next)
+		data = append(data, r.data...)
+		s.next++
+		if s.next == 0 {
+			s.next++
+		}
+	}
+
+	return data
+}
+
+// encodeReply payload按RESP3编码,RESP2连接需要降级,异常且无payload时按错误应答
+func encodeReply(ident *netx.Identifier, payload []byte, name string, version int) []byte {
+	if len(payload) == 0 {
+		if ident.MsgType() == netx.MsgTypeException {
+			return appendValue(nil, Error(errorMessage(ident, name)), version)
+		}
+		return appendValue(nil, SimpleString("OK"), version)
+	}
+
+	if version >= Version3 {
+		return payload
+	}
+
+	v, err := Unmarshal(payload)
+	if err != nil {
+		return appendValue(nil, Error("ERR invalid reply"), version)
+	}
+	return appendValue(nil, v, version)
+}
+
+// errorMessage 错误信息需以错误类型开头,没有则补充ERR
+func errorMessage(ident *netx.Identifier, name string) string {
+	info := ident.StatusInfo
+	if info == "" {
+		if ident.StatusCode == 404 && name != "" {
+			return "ERR unknown command '" + name + "'"
+		}
+		info = "internal error"
+	}
+	if idx := strings.IndexByte(info, ' '); idx > 0 && strings.ToUpper(info[:idx]) == info[:idx] {
+		return info
+	}
+
+	return "ERR " + info
+}
+
+// request 客户端编码请求,payload为空时仅发送命令名
+func (s *session) request(ident *netx.Identifier, payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		if ident.URI == "" {
+			return nil, netx.ErrInvalidIdentifier
+		}
+		payload = appendValue(nil, Array(BulkString(ident.URI)), Version2)
+	}
+
+	if !ident.IsOneway {
+		s.mux.Lock()
+		s.sent = append(s.sent, ident.SeqID)
+		s.mux.Unlock()
+	}
+
+	return payload, nil
+}
+
+// toResponse 客户端按发送顺序匹配应答,推送消息的SeqID为0,错误应答设置状态
+func (s *session) toResponse(v Value, raw []byte) netx.Frame {
+	ident := netx.NewIdentifier()
+	ident.IsResponse = true
+	if v.Type != TypePush {
+		s.mux.Lock()
+		if len(s.sent) > 0 {
+			ident.SeqID = s.sent[0]
+			s.sent = s.sent[1:]
+		}
+		s.mux.Unlock()
+	}
+	if v.IsError() {
+		ident.StatusCode = 500
+		ident.StatusInfo = string(v.Str)
+	}
+
+	return netx.NewFrame(netx.FrameTypeHeader, true, 0, ident, nil, newPayload(raw))
+}
@@ -0,0 +1,170 @@
+// Package resp Redis序列化协议,支持RESP2和RESP3,见https://redis.io/docs/reference/protocol-spec/
+//	服务端将每个命令解析为一个请求,命令名转为小写后作为URI,通过Route.Name查找路由,payload为原始命令
+//	连接默认使用RESP2,收到HELLO 3后切换为RESP3,RESP3类型在RESP2连接上会自动降级
+//	pipeline中的命令可能被并发处理,应答会按命令顺序发送,因此每个命令都必须应答,
+//	未注册的命令需要通过NoRoute处理,否则后续应答会被阻塞
+//	s := server.New()
+//	s.NoRoute(resp.UnknownCommand)
+//	s.Register(&netx.Route{Name: "ping", Handler: onPing})
+package resp
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Type 数据类型,即首字节
+type Type byte
+
+// RESP2类型
+const (
+	TypeSimpleString Type = '+'
+	TypeError        Type = '-'
+	TypeInteger      Type = ':'
+	TypeBulkString   Type = '$'
+	TypeArray        Type = '*'
+)
+
+// RESP3类型
+const (
+	TypeNull      Type = '_'
+	TypeBoolean   Type = '#'
+	TypeDouble    Type = ','
+	TypeBigNumber Type = '('
+	TypeBulkError Type = '!'
+	TypeVerbatim  Type = '='
+	TypeMap       Type = '%'
+	TypeSet       Type = '~'
+	TypeAttribute Type = '|'
+	TypePush      Type = '>'
+)
+
+// 协议版本
+const (
+	Version2 = 2
+	Version3 = 3
+)
+
+// some error
+var (
+	ErrProtocol        = errors.New("resp: protocol error")
+	ErrMessageTooLarge = errors.New("resp: message too large")
+	ErrInvalidCommand  = errors.New("resp: invalid command")
+	ErrDepthLimit      = errors.New("resp: exceeded max depth")
+	errIncomplete      = errors.New("resp: incomplete")
+)
+
+// Value RESP数据
+type Value struct {
+	Type  Type    // 类型
+	Str   []byte  // simple string,bulk string,error,big number,verbatim(含3字节格式及':')
+	Int   int64   // integer,boolean为0或1
+	Float float64 // double
+	Elems []Value // array,set,push;map和attribute按key,value交替存储
+}
+
+// SimpleString 创建simple string
+func SimpleString(s string) Value {
+	return Value{Type: TypeSimpleString, Str: []byte(s)}
+}
+
+// Error 创建错误,通常以错误类型开头,例如"ERR unknown command"
+func Error(msg string) Value {
+	return Value{Type: TypeError, Str: []byte(msg)}
+}
+
+// Integer 创建整数
+func Integer(v int64) Value {
+	return Value{Type: TypeInteger, Int: v}
+}
+
+// Bulk 创建bulk string
+func Bulk(data []byte) Value {
+	return Value{Type: TypeBulkString, Str: data}
+}
+
+// BulkString 创建bulk string
+func BulkString(s string) Value {
+	return Value{Type: TypeBulkString, Str: []byte(s)}
+}
+
+// Array 创建数组
+func Array(elems ...Value) Value {
+	return Value{Type: TypeArray, Elems: elems}
+}
+
+// Null 创建空值,RESP2中为null bulk string
+func Null() Value {
+	return Value{Type: TypeNull}
+}
+
+// Boolean 创建布尔值,RESP2中为整数
+func Boolean(v bool) Value {
+	if v {
+		return Value{Type: TypeBoolean, Int: 1}
+	}
+	return Value{Type: TypeBoolean}
+}
+
+// Double 创建浮点数,RESP2中为bulk string
+func Double(v float64) Value {
+	return Value{Type: TypeDouble, Float: v}
+}
+
+// Map 创建map,kvs按key,value交替存储,RESP2中为数组
+func Map(kvs ...Value) Value {
+	return Value{Type: TypeMap, Elems: kvs}
+}
+
+// Set 创建集合,RESP2中为数组
+func Set(elems ...Value) Value {
+	return Value{Type: TypeSet, Elems: elems}
+}
+
+// Push 创建推送消息,第一个元素为消息类型,例如"message",RESP2中为数组
+func Push(kind string, elems ...Value) Value {
+	return Value{Type: TypePush, Elems: append([]Value{BulkString(kind)}, elems...)}
+}
+
+// IsNull 是否为空值
+func (v Value) IsNull() bool {
+	return v.Type == TypeNull
+}
+
+// IsError 是否为错误
+func (v Value) IsError() bool {
+	return v.Type == TypeError || v.Type == TypeBulkError
+}
+
+// String 返回字符串形式,verbatim会去除格式前缀
+func (v Value) String() string {
+	switch v.Type {
+	case TypeInteger:
+		return strconv.FormatInt(v.Int, 10)
+	case TypeBoolean:
+		return strconv.FormatBool(v.Int != 0)
+	case TypeDouble:
+		return formatDouble(v.Float)
+	case TypeVerbatim:
+		if len(v.Str) >= 4 {
+			return string(v.Str[4:])
+		}
+	}
+
+	return string(v.Str)
+}
+
+// Marshal 按RESP3编码
+func Marshal(v Value) []byte {
+	return appendValue(nil, v, Version3)
+}
+
+// Unmarshal 解析一个完整数据,数据不完整时返回ErrProtocol
+func Unmarshal(data []byte) (Value, error) {
+	v, _, err := parseValue(data, len(data))
+	if err == errIncomplete {
+		err = ErrProtocol
+	}
+
+	return v, err
+}
@@ -0,0 +1,145 @@
+package resp
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+func TestCodec(t *testing.T) {
+	values := []Value{
+		SimpleString("OK"),
+		Error("ERR bad"),
+		Integer(-42),
+		BulkString("hello\r\nworld"),
+		Bulk([]byte{}),
+		Null(),
+		Boolean(true),
+		Double(1.5),
+		Double(math.Inf(-1)),
+		Array(BulkString("a"), Integer(1), Array()),
+		Map(BulkString("k"), Integer(1)),
+		Set(BulkString("x")),
+		Push("message", BulkString("ch"), BulkString("hi")),
+		{Type: TypeVerbatim, Str: []byte("txt:some text")},
+		{Type: TypeBigNumber, Str: []byte("3492890328409238509324850943850943825024385")},
+		{Type: TypeBulkError, Str: []byte("SYNTAX invalid")},
+	}
+	for _, v := range values {
+		data := Marshal(v)
+		got, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unmarshal %q fail, %+v", data, err)
+		}
+		if !reflect.DeepEqual(Marshal(got), data) {
+			t.Errorf("round trip fail, %q, %q", data, Marshal(got))
+		}
+		// 截断的数据需要返回errIncomplete
+		if _, _, err := parseValue(data[:len(data)-1], len(data)); err != errIncomplete {
+			t.Errorf("%q should be incomplete, %+v", data, err)
+		}
+	}
+}
+
+func TestDowngrade(t *testing.T) {
+	tests := []struct {
+		v    Value
+		want string
+	}{
+		{Null(), "$-1\r\n"},
+		{Boolean(true), ":1\r\n"},
+		{Double(2.5), "$3\r\n2.5\r\n"},
+		{Map(BulkString("k"), Integer(1)), "*2\r\n$1\r\nk\r\n:1\r\n"},
+		{Push("message", BulkString("hi")), "*2\r\n$7\r\nmessage\r\n$2\r\nhi\r\n"},
+		{Value{Type: TypeVerbatim, Str: []byte("txt:abc")}, "$3\r\nabc\r\n"},
+		{Value{Type: TypeBulkError, Str: []byte("ERR a\r\nb")}, "-ERR a b\r\n"},
+	}
+	for _, tt := range tests {
+		if got := string(appendValue(nil, tt.v, Version2)); got != tt.want {
+			t.Errorf("bad resp2 encoding, %q, want %q", got, tt.want)
+		}
+	}
+
+	if _, err := Unmarshal([]byte("*-1\r\n")); err != nil {
+		t.Errorf("null array fail, %+v", err)
+	}
+	if _, err := Unmarshal([]byte("+OK\n")); err != ErrProtocol {
+		t.Errorf("should be protocol error, %+v", err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	p := New()
+	buf := bytex.NewBuffer()
+	_ = buf.Append("*1\r\n$4\r\nPING\r\n*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n$5\r\nhello\r\n$1\r\n3\r\n*1\r\n$3\r\nGET")
+	_, _ = buf.Seek(0, io.SeekStart)
+	if !p.Detect(buf) {
+		t.Fatal("detect fail")
+	}
+
+	s := newSession()
+	var frames []netx.Frame
+	for {
+		data := make([]byte, buf.Available())
+		_, _ = buf.Peek(data)
+		v, n, err := parseValue(data, defaultMaxSize)
+		if err == errIncomplete {
+			break
+		}
+		buf.ReadN(n)
+		frame, err := s.toRequest(v, data[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+	if len(frames) != 3 || frames[1].Identifier().URI != "set" {
+		t.Fatalf("bad frames, %d", len(frames))
+	}
+
+	// 乱序完成,需要按命令顺序发送
+	send := func(i int, v Value) []byte {
+		ident := &netx.Identifier{IsResponse: true, SeqID: frames[i].Identifier().SeqID}
+		return s.reply(ident, Marshal(v))
+	}
+	if data := send(2, Map(BulkString("proto"), Integer(3))); data != nil {
+		t.Errorf("should wait for previous replies, %q", data)
+	}
+	if data := send(1, SimpleString("OK")); data != nil {
+		t.Errorf("should wait for previous replies, %q", data)
+	}
+	want := "+PONG\r\n+OK\r\n%1\r\n$5\r\nproto\r\n:3\r\n"
+	if data := send(0, SimpleString("PONG")); string(data) != want {
+		t.Errorf("bad pipeline reply, %q", data)
+	}
+
+	ident := &netx.Identifier{IsResponse: true, StatusCode: 404}
+	if data := encodeReply(ident, nil, "foo", Version2); string(data) != "-ERR unknown command 'foo'\r\n" {
+		t.Errorf("bad error reply, %q", data)
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	// bulk头部已知时返回完整长度,嵌套时累加前缀长度
+	data := []byte("*2\r\n$1\r\na\r\n$100\r\nabc")
+	if _, n, err := parseValue(data, defaultMaxSize); err != errIncomplete || n != 11+6+100+2 {
+		t.Errorf("bad need, %d, %+v", n, err)
+	}
+	if _, n, err := parseValue([]byte("*2\r"), defaultMaxSize); err != errIncomplete || n != 4 {
+		t.Errorf("bad need, %d, %+v", n, err)
+	}
+
+	deep := append(bytes.Repeat([]byte("*1\r\n"), maxDepth+10), ":1\r\n"...)
+	if _, err := Unmarshal(deep); err != ErrDepthLimit {
+		t.Errorf("expect depth limit, %+v", err)
+	}
+	deep = append(bytes.Repeat([]byte("*1\r\n"), maxDepth), ":1\r\n"...)
+	if _, err := Unmarshal(deep); err != nil {
+		t.Errorf("unmarshal fail, %+v", err)
+	}
+}
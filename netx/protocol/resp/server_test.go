@@ -0,0 +1,78 @@
+package resp_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/protocol/resp"
+	"github.com/foredata/nova/netx/transport/memory/memtest"
+)
+
+func TestClientServer(t *testing.T) {
+	svr := memtest.NewServer(t, "resp")
+	svr.NoRoute(resp.UnknownCommand)
+	var mux sync.Mutex
+	store := map[string][]byte{}
+	svr.Register(&netx.Route{Name: "set", Handler: func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		args, err := resp.Args(req)
+		if err != nil || len(args) != 3 {
+			return resp.NewResponse(resp.Error("ERR wrong number of arguments for 'set' command")), nil
+		}
+		mux.Lock()
+		store[string(args[1])] = args[2]
+		mux.Unlock()
+		return resp.NewResponse(resp.SimpleString("OK")), nil
+	}})
+	svr.Register(&netx.Route{Name: "get", Handler: func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		args, _ := resp.Args(req)
+		// 先到的请求晚完成,验证应答顺序
+		if string(args[1]) == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		mux.Lock()
+		v, ok := store[string(args[1])]
+		mux.Unlock()
+		if !ok {
+			return resp.NewResponse(resp.Null()), nil
+		}
+		return resp.NewResponse(resp.Bulk(v)), nil
+	}})
+
+	cli := memtest.NewClient(t, client.WithProtocol(resp.New()))
+	call := func(args ...interface{}) (resp.Value, error) {
+		req := resp.NewRequest(args...)
+		req.SetService(memtest.Addr("resp"))
+		rsp, err := cli.Call(context.Background(), req)
+		if err != nil {
+			return resp.Value{}, err
+		}
+		return resp.ResponseValue(rsp)
+	}
+
+	if v, err := call("SET", "k", 1); err != nil || v.String() != "OK" {
+		t.Fatalf("set fail, %+v, %+v", v, err)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]resp.Value, 2)
+	for i, key := range []string{"slow", "k"} {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			results[i], _ = call("GET", key)
+		}(i, key)
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+	if !results[0].IsNull() || results[1].String() != "1" {
+		t.Errorf("bad get result, %+v", results)
+	}
+
+	if v, err := call("NOPE"); err != nil || !v.IsError() || v.String() != "ERR unknown command 'nope'" {
+		t.Errorf("bad unknown command reply, %+v, %+v", v, err)
+	}
+}
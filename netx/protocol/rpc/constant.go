@@ -9,13 +9,18 @@ const (
 	versionMask  = 0x4000 // 标记是否有version字段
 	frameEndMask = 0x2000 // 标记是否最后一帧
 	cmdIdMask    = 0x1000 // 标记是否使用cmdId,否则使用URI
+	compressMask = 0x0080 // 标记Data帧payload已压缩,StreamId后跟1字节压缩类型
 
+	// 心跳帧,占用frame type最后一个取值,仅在协议内部处理,EndFlag标记为应答
+	framePing = 3
 	// 偏移
 	frameTypeShift = 10
 	msgTypeShift   = 8
 	//
 	maxLengthBytes = binary.MaxVarintLen32
 	maxHeaderNum   = 65535
+	//
+	minCompressSize = 64 // 小于该值时压缩没有收益
 )
 
 var nullStr = string([]byte{0})
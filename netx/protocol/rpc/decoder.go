@@ -2,9 +2,11 @@ package rpc
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
@@ -56,6 +58,8 @@ func (dec *decoder) Decode(buf bytex.Buffer) (netx.Frame, error) {
 		err = dec.readDataFrame(frame, realBuf, flags)
 	case netx.FrameTypeTrailer:
 		err = dec.readTrailerFrame(frame, realBuf, flags)
+	case framePing:
+		// 心跳帧没有内容,由rpcProtocol处理
 	default:
 		err = netx.ErrNotSupport
 	}
@@ -86,6 +90,7 @@ func (dec *decoder) readHeaderFrame(frame netx.Frame, buf bytex.Buffer, flags ui
 		return fmt.Errorf("read codec fail, %+v", err)
 	}
 	ident.Codec = uint32(codecAndCompress & 0x0F)
+	ident.Compress = compress.Type(codecAndCompress >> 4)
 	if err := bytex.ReadUvarint32(buf, &ident.SeqID); err != nil {
 		return fmt.Errorf("read seqid fail, %+v", err)
 	}
@@ -120,14 +125,31 @@ func (dec *decoder) readHeaderFrame(frame netx.Frame, buf bytex.Buffer, flags ui
 	if err != nil {
 		return err
 	}
+	payload, err := decompressPayload(ident.Compress, getPayload(buf))
+	if err != nil {
+		return err
+	}
 	frame.SetIdentifier(ident)
 	frame.SetHeader(header)
-	frame.SetPayload(getPayload(buf))
+	frame.SetPayload(payload)
 	return nil
 }
 
 func (dec *decoder) readDataFrame(frame netx.Frame, buf bytex.Buffer, flags uint16) error {
-	frame.SetPayload(getPayload(buf))
+	if !hasFlag(flags, compressMask) {
+		frame.SetPayload(getPayload(buf))
+		return nil
+	}
+
+	ctype, err := buf.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read compress type fail, %+v", err)
+	}
+	payload, err := decompressPayload(compress.Type(ctype), getPayload(buf))
+	if err != nil {
+		return err
+	}
+	frame.SetPayload(payload)
 	return nil
 }
 
@@ -167,6 +189,22 @@ func (dec *decoder) decodeHeader(buf bytex.Buffer) (netx.Header, error) {
 	return header, nil
 }
 
+func decompressPayload(t compress.Type, payload bytex.Buffer) (bytex.Buffer, error) {
+	if t == compress.TypeNone || payload == nil {
+		return payload, nil
+	}
+
+	data, err := compress.Decompress(t, payload.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("decompress payload fail, %+v", err)
+	}
+
+	out := bytex.NewBuffer()
+	_ = out.Append(data)
+	_, _ = out.Seek(0, io.SeekStart)
+	return out, nil
+}
+
 func getPayload(buf bytex.Buffer) bytex.Buffer {
 	buf.Discard()
 	if buf.Available() == 0 {
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
@@ -64,9 +65,12 @@ func (enc *encoder) writeHeaderFrame(buf bytex.Buffer, frame netx.Frame, flags u
 		_ = bytex.WriteUvarint32(buf, uint32(ident.Version))
 	}
 
-	// codec+compressType
-	// 尚未定义CompressType,仅定义Codec,不超过16
-	codecAndCompress := ident.Codec & 0x0F
+	// codec+compressType,各占4位
+	ctype, payload, err := compressPayload(ident.Compress, frame.Payload())
+	if err != nil {
+		return err
+	}
+	codecAndCompress := ident.Codec&0x0F | uint32(ctype)<<4
 	_ = bytex.WriteByte(buf, uint8(codecAndCompress))
 	_ = bytex.WriteUvarint32(buf, ident.SeqID)
 
@@ -92,7 +96,6 @@ func (enc *encoder) writeHeaderFrame(buf bytex.Buffer, frame netx.Frame, flags u
 		return err
 	}
 
-	payload := frame.Payload()
 	if payload != nil && !payload.Empty() {
 		_ = buf.Append(payload)
 	}
@@ -101,9 +104,37 @@ func (enc *encoder) writeHeaderFrame(buf bytex.Buffer, frame netx.Frame, flags u
 	return nil
 }
 
-// 数据帧,没有额外header,追加数据即可
+// encodePing 心跳帧,只包含flags,magic及streamID,ack为true时为应答
+func (enc *encoder) encodePing(ack bool) bytex.Buffer {
+	buf := bytex.NewBuffer()
+	buf.WriteN(maxLengthBytes + 2)
+	_ = bytex.WriteUint16BE(buf, magicWord)
+	flags := uint16(0)
+	setFlag(&flags, magicMask)
+	if ack {
+		setFlag(&flags, frameEndMask)
+	}
+	set2Bits(&flags, framePing, frameTypeShift)
+	_ = bytex.WriteUvarint64(buf, 0)
+	enc.fixLengthFlag(buf, flags)
+	return buf
+}
+
+// 数据帧,没有额外header,追加数据即可,压缩时需要额外记录压缩类型
 func (enc *encoder) writeDataFrame(buf bytex.Buffer, frame netx.Frame, flags uint16) error {
 	payload := frame.Payload()
+	if ident := frame.Identifier(); ident != nil && ident.Compress != compress.TypeNone {
+		ctype, data, err := compressPayload(ident.Compress, payload)
+		if err != nil {
+			return err
+		}
+		if ctype != compress.TypeNone {
+			setFlag(&flags, compressMask)
+			_ = bytex.WriteByte(buf, uint8(ctype))
+		}
+		payload = data
+	}
+
 	if payload != nil && !payload.Empty() {
 		_ = buf.Append(payload)
 	}
@@ -141,6 +172,26 @@ func (enc *encoder) encodeHeader(buf bytex.Buffer, header netx.Header) error {
 	return nil
 }
 
+// compressPayload 压缩payload,数据过小或压缩后没有变小时不压缩
+func compressPayload(t compress.Type, payload bytex.Buffer) (compress.Type, bytex.Buffer, error) {
+	if t == compress.TypeNone || payload == nil || payload.Len() < minCompressSize {
+		return compress.TypeNone, payload, nil
+	}
+
+	data, err := compress.Compress(t, payload.Bytes())
+	if err != nil {
+		return compress.TypeNone, nil, err
+	}
+	if len(data) >= payload.Len() {
+		return compress.TypeNone, payload, nil
+	}
+
+	out := bytex.NewBuffer()
+	_ = out.Append(data)
+	_, _ = out.Seek(0, io.SeekStart)
+	return t, out, nil
+}
+
 func (enc *encoder) fixLengthFlag(buf bytex.Buffer, flags uint16) {
 	length := uint32(buf.Len() - maxLengthBytes)
 	var lengthBytes [5]byte
@@ -7,9 +7,38 @@ import (
 	"github.com/foredata/nova/pkg/bytex"
 )
 
-var gProtocol = &rpcProtocol{}
+var (
+	gProtocol     = &rpcProtocol{}
+	gPingProtocol = &pingProtocol{}
+)
+
+// Options 可选参数
+type Options struct {
+	Ping bool // 是否实现netx.Pinger发送心跳帧
+}
+
+// Option .
+type Option func(o *Options)
+
+// WithPing 开启心跳帧,用于keepalive探测对端是否存活
+//	兼容性: 心跳帧(type 3)需要对端支持,旧版本收到后会返回ErrNotSupport并断开连接,
+//	需要确认对端均已升级后再开启,未开启时仍然会应答对端发送的心跳帧
+func WithPing() Option {
+	return func(o *Options) {
+		o.Ping = true
+	}
+}
+
+// New 创建rpc协议,默认不发送心跳帧
+func New(opts ...Option) netx.Protocol {
+	o := Options{}
+	for _, fn := range opts {
+		fn(&o)
+	}
 
-func New() netx.Protocol {
+	if o.Ping {
+		return gPingProtocol
+	}
 	return gProtocol
 }
 
@@ -39,6 +68,10 @@ func (rp *rpcProtocol) Detect(peeker bytex.Peeker) bool {
 	// length+flags+magic
 	var data [9]byte
 	n, _ := peeker.Peek(data[:])
+	if n == 0 {
+		// 不足9字节的帧(例如心跳帧)长度只占1字节
+		n, _ = peeker.Peek(data[:5])
+	}
 	_, lenSize := binary.Uvarint(data[:n])
 	if lenSize <= 0 || lenSize > binary.MaxVarintLen32 {
 		return false
@@ -53,12 +86,39 @@ func (rp *rpcProtocol) Detect(peeker bytex.Peeker) bool {
 	return false
 }
 
+// Decode 心跳帧在协议内部应答并丢弃,conn为nil时不应答
 func (rp *rpcProtocol) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error) {
 	dec := &decoder{}
-	return dec.Decode(buf)
+	for {
+		frame, err := dec.Decode(buf)
+		if err != nil || frame == nil || frame.Type() != framePing {
+			return frame, err
+		}
+
+		if !frame.EndFlag() && conn != nil {
+			enc := encoder{}
+			_ = conn.Send(enc.encodePing(true))
+		}
+		frame.Recycle()
+		buf.Discard()
+		if buf.Available() == 0 {
+			return nil, nil
+		}
+	}
 }
 
 func (rp *rpcProtocol) Encode(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
 	enc := encoder{}
 	return enc.Encode(frame, true)
 }
+
+// pingProtocol 开启心跳帧的rpc协议
+type pingProtocol struct {
+	rpcProtocol
+}
+
+// Ping 实现netx.Pinger,发送心跳帧
+func (rp *pingProtocol) Ping(conn netx.Conn) error {
+	enc := encoder{}
+	return conn.Send(enc.encodePing(false))
+}
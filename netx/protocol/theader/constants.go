@@ -9,6 +9,8 @@ const (
 	// flagsMask        = 0x0000FFFF
 	maxFrameSize  = 0x3FFFFFFF
 	maxHeaderSize = 131071
+
+	minCompressSize = 64 // 小于该值时压缩没有收益
 )
 
 const (
@@ -23,6 +25,7 @@ const (
 	// TransformNone Default null transform
 	TransformNone TransformID = 0
 	// TransformZlib Apply zlib compression
+	//	压缩类型的TransformID与compress.Type取值一致
 	TransformZlib TransformID = 1
 	// TransformHMAC Deprecated and no longer supported
 	TransformHMAC TransformID = 2
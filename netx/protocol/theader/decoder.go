@@ -3,8 +3,11 @@ package theader
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/codec/thrift"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
@@ -15,14 +18,17 @@ func (dec *decoder) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error)
 	var length, secondword, seqId uint32
 	var headerLen uint16
 
+	if buf.Available() < 4 {
+		return nil, nil
+	}
 	if err := bytex.ReadUint32BE(buf, &length); err != nil {
 		return nil, err
 	}
 	if length > maxFrameSize {
 		return nil, fmt.Errorf("BigFrames not supported: got size %d", length)
 	}
-	// 数据不足,等待下次解析
-	if buf.Available() < int(length+commonHeaderSize) {
+	// 数据不足,等待下次解析,length包含除自身以外的所有数据
+	if buf.Available() < int(length) {
 		return nil, nil
 	}
 	if err := bytex.ReadUint32BE(buf, &secondword); err != nil {
@@ -40,11 +46,11 @@ func (dec *decoder) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error)
 	if err := bytex.ReadUint16BE(buf, &headerLen); err != nil {
 		return nil, err
 	}
-	if uint32(headerLen*4) > maxHeaderSize {
-		return nil, fmt.Errorf("invalid header length: %d", int64(headerLen*4))
+	if uint32(headerLen)*4 > maxHeaderSize || uint32(headerLen)*4+commonHeaderSize > length {
+		return nil, fmt.Errorf("invalid header length: %d", int64(headerLen)*4)
 	}
 	// Limit the reader for the header so we can't overrun
-	headerBuf := buf.ReadN(int(headerLen))
+	headerBuf := buf.ReadN(int(headerLen) * 4)
 
 	// read header
 	var protoID uint32
@@ -55,7 +61,7 @@ func (dec *decoder) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error)
 		return nil, fmt.Errorf("hHeader: invalid protoID, %+v", protoID)
 	}
 
-	_, err := dec.readTransforms(headerBuf)
+	transforms, err := dec.readTransforms(headerBuf)
 	if err != nil {
 		return nil, err
 	}
@@ -68,15 +74,53 @@ func (dec *decoder) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error)
 	payload := buf.ReadN(int(payloadLen))
 	ident := netx.NewIdentifier()
 	ident.SeqID = seqId
+	if len(transforms) > 0 {
+		ident.Compress = compress.Type(transforms[0])
+		if payload, err = dec.untransform(transforms, payload); err != nil {
+			return nil, err
+		}
+	}
 	strHeader, err = dec.fixIntHeader(intHeader, ident, strHeader)
 	if err != nil {
 		return nil, err
 	}
+	dec.fixMessage(conn, protoID, ident, payload)
 
 	frame := netx.NewFrame(netx.FrameTypeHeader, true, 0, ident, strHeader, payload)
 	return frame, nil
 }
 
+// fixMessage payload为Thrift消息,使用消息中的方法名作为URI用于路由,并根据消息类型区分请求和应答
+//	无法解析消息时,客户端连接上收到的均视为应答
+func (dec *decoder) fixMessage(conn netx.Conn, protoID uint32, ident *netx.Identifier, payload bytex.Buffer) {
+	proto := thrift.ProtocolBinary
+	ident.Codec = uint32(netx.CodecTypeThrift)
+	if protoID == protoIDCompact {
+		proto = thrift.ProtocolCompact
+		ident.Codec = uint32(netx.CodecTypeThriftCompact)
+	}
+
+	ident.IsResponse = conn != nil && conn.IsClient()
+	if payload == nil {
+		return
+	}
+
+	name, typ, _, err := thrift.NewReader(proto, payload.Bytes()).ReadMessageBegin()
+	if err != nil {
+		return
+	}
+	ident.URI = name
+	switch typ {
+	case thrift.MessageReply, thrift.MessageException:
+		ident.IsResponse = true
+	case thrift.MessageCall:
+		ident.IsResponse = false
+	case thrift.MessageOneway:
+		ident.IsResponse = false
+		ident.IsOneway = true
+	}
+}
+
 func (dec *decoder) readTransforms(buf bytex.Buffer) ([]TransformID, error) {
 	transforms := []TransformID{}
 	nums, err := binary.ReadUvarint(buf)
@@ -89,13 +133,36 @@ func (dec *decoder) readTransforms(buf bytex.Buffer) ([]TransformID, error) {
 			return nil, fmt.Errorf("thheader: error reading transformid: %s", err.Error())
 		}
 		tid := TransformID(transformID)
-		// TODO: check supportedTransforms
+		if tid == TransformHMAC || tid == TransformQLZ || compress.Get(compress.Type(tid)) == nil {
+			return nil, fmt.Errorf("theader: unsupported transform, %d", tid)
+		}
 		transforms = append(transforms, tid)
 	}
 
 	return transforms, nil
 }
 
+// untransform 按相反顺序还原payload
+func (dec *decoder) untransform(transforms []TransformID, payload bytex.Buffer) (bytex.Buffer, error) {
+	var data []byte
+	if payload != nil {
+		data = payload.Bytes()
+	}
+
+	for i := len(transforms) - 1; i >= 0; i-- {
+		var err error
+		data, err = compress.DecompressLimit(compress.Type(transforms[i]), data, maxFrameSize)
+		if err != nil {
+			return nil, fmt.Errorf("theader: transform %d fail, %+v", transforms[i], err)
+		}
+	}
+
+	out := bytex.NewBuffer()
+	_ = out.Append(data)
+	_, _ = out.Seek(0, io.SeekStart)
+	return out, nil
+}
+
 // readInfoHeaders Read the K/V headers at the end of the header
 // This will keep consuming bytes until the buffer returns EOF
 func (dec *decoder) readInfoHeaders(buf bytex.Buffer) (netx.Header, IntMap, error) {
@@ -116,7 +183,8 @@ func (dec *decoder) readInfoHeaders(buf bytex.Buffer) (netx.Header, IntMap, erro
 		}
 		switch infoIDType(infoID) {
 		case infoIDPadding:
-			continue
+			// 剩余均为对齐填充
+			return strHeader, intHeader, nil
 		case infoIDKeyValue:
 			h, err := dec.readStringKeyValue(buf)
 			if err != nil {
@@ -189,5 +257,5 @@ func (dec *decoder) readIntKeyValue(buf bytex.Buffer) (IntMap, error) {
 }
 
 func (dec *decoder) fixIntHeader(intHeader IntMap, ident *netx.Identifier, strHeader netx.Header) (netx.Header, error) {
-	return nil, nil
+	return strHeader, nil
 }
@@ -1,14 +1,101 @@
 package theader
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
 type encoder struct {
 }
 
+// Encode THeader不支持分帧,只能编码完整消息
 func (encoder) Encode(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
+	if frame.Type() != netx.FrameTypeHeader || !frame.EndFlag() {
+		return nil, netx.ErrNotSupport
+	}
+
+	ident := frame.Identifier()
+	if ident == nil {
+		return nil, netx.ErrInvalidIdentifier
+	}
+
+	var payload []byte
+	if p := frame.Payload(); p != nil {
+		payload = p.Bytes()
+	}
+
+	// 压缩后没有变小则不压缩
+	var transforms []TransformID
+	if ident.Compress != compress.TypeNone && len(payload) >= minCompressSize {
+		data, err := compress.Compress(ident.Compress, payload)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < len(payload) {
+			transforms = append(transforms, TransformID(ident.Compress))
+			payload = data
+		}
+	}
+
+	protoID := uint32(protoIDBinary)
+	if ident.Codec == uint32(netx.CodecTypeThriftCompact) {
+		protoID = protoIDCompact
+	}
+
+	header := bytex.NewBuffer()
+	_ = bytex.WriteUvarint32(header, protoID)
+	_ = bytex.WriteUvarint32(header, uint32(len(transforms)))
+	for _, t := range transforms {
+		_ = bytex.WriteUvarint32(header, uint32(t))
+	}
+
+	if h := frame.Header(); h.Len() > 0 {
+		count := 0
+		h.Walk(func(key string, values []string) bool {
+			count += len(values)
+			return true
+		})
+		_ = bytex.WriteUvarint32(header, uint32(infoIDKeyValue))
+		_ = bytex.WriteUvarint32(header, uint32(count))
+		h.Walk(func(key string, values []string) bool {
+			for _, v := range values {
+				_ = bytex.WriteString(header, key)
+				_ = bytex.WriteString(header, v)
+			}
+			return true
+		})
+	}
+
+	// header按4字节对齐
+	if pad := header.Len() % 4; pad != 0 {
+		_, _ = header.Write(make([]byte, 4-pad))
+	}
+	if header.Len() > maxHeaderSize {
+		return nil, fmt.Errorf("theader: header too large, %d", header.Len())
+	}
+
+	length := commonHeaderSize + header.Len() + len(payload)
+	if length > maxFrameSize {
+		return nil, fmt.Errorf("theader: frame too large, %d", length)
+	}
+
+	buf := bytex.NewBuffer()
+	var head [14]byte
+	binary.BigEndian.PutUint32(head[0:], uint32(length))
+	binary.BigEndian.PutUint32(head[4:], headerMagic)
+	binary.BigEndian.PutUint32(head[8:], ident.SeqID)
+	binary.BigEndian.PutUint16(head[12:], uint16(header.Len()/4))
+	_, _ = buf.Write(head[:])
+	_, _ = header.Seek(0, io.SeekStart)
+	_, _ = header.WriteTo(buf)
+	if len(payload) > 0 {
+		_, _ = buf.Write(payload)
+	}
 
-	return nil, nil
+	return buf, nil
 }
@@ -55,7 +55,7 @@ func (*theaderProtocol) Name() string {
 
 func (*theaderProtocol) Detect(p bytex.Peeker) bool {
 	var data [8]byte
-	if n, err := p.Peek(data[:]); err == nil || n != 8 {
+	if n, err := p.Peek(data[:]); err != nil || n != 8 {
 		return false
 	}
 
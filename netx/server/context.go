@@ -9,8 +9,12 @@ import (
 type ctxKey struct{}
 
 type scontext struct {
+	conn      netx.Conn
 	req       netx.Request
+	route     *netx.Route
+	router    netx.Router
 	rspHeader netx.Header
+	hijacked  bool // 连接已被handler接管,不再自动发送应答
 }
 
 func newContext(parent context.Context, sctx *scontext) context.Context {
@@ -32,6 +36,36 @@ func GetRequest(ctx context.Context) netx.Request {
 	return nil
 }
 
+// GetConn 从Context中获取请求所在连接
+func GetConn(ctx context.Context) netx.Conn {
+	sctx := getCtx(ctx)
+	if sctx != nil {
+		return sctx.conn
+	}
+
+	return nil
+}
+
+// GetRoute 从Context中获取当前命中的路由,NoRoute时返回nil
+func GetRoute(ctx context.Context) *netx.Route {
+	sctx := getCtx(ctx)
+	if sctx != nil {
+		return sctx.route
+	}
+
+	return nil
+}
+
+// GetRouter 从Context中获取server的路由表,可用于在handler中再次分发请求
+func GetRouter(ctx context.Context) netx.Router {
+	sctx := getCtx(ctx)
+	if sctx != nil {
+		return sctx.router
+	}
+
+	return nil
+}
+
 // GetResponseHeader 从Context中获取response header
 func GetResponseHeader(ctx context.Context) netx.Header {
 	sctx := getCtx(ctx)
@@ -49,3 +83,15 @@ func SetResponseHeader(ctx context.Context, header netx.Header) {
 		sctx.rspHeader = header
 	}
 }
+
+// Hijack 接管请求所在连接,调用后server不再自动发送应答,由调用方负责后续读写
+//	通常用于协议升级,例如websocket代理
+func Hijack(ctx context.Context) netx.Conn {
+	sctx := getCtx(ctx)
+	if sctx != nil {
+		sctx.hijacked = true
+		return sctx.conn
+	}
+
+	return nil
+}
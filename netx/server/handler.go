@@ -10,6 +10,7 @@ import (
 	"github.com/foredata/nova/netx"
 	"github.com/foredata/nova/netx/body"
 	_ "github.com/foredata/nova/netx/codec"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/netx/metadata"
 )
 
@@ -19,18 +20,23 @@ var (
 	ErrNoBinder      = errors.New("no binder")
 )
 
-// toCallback 将endpoint转换成Callback
-func toCallback(endpoint netx.Endpoint) netx.Callback {
+// toCallback 将endpoint转换成Callback,route可以为nil,比如NoRoute
+func toCallback(route *netx.Route, endpoint netx.Endpoint, opts *Options) netx.Callback {
 	return func(conn netx.Conn, packet netx.Packet) error {
 		req, _ := packet.(netx.Request)
-		sctx := &scontext{req: req}
+		sctx := &scontext{conn: conn, req: req, route: route, router: opts.Router}
 		ctx := newContext(context.Background(), sctx)
 		if len(req.Header()) > 0 {
 			ctx = metadata.NewContext(ctx, req.Header())
 		}
+		if timeout := netx.GetTimeout(req.Header()); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
 
 		rsp, err := endpoint(ctx, req)
-		if req.IsOneway() {
+		if req.IsOneway() || sctx.hijacked {
 			return err
 		}
 
@@ -48,6 +54,10 @@ func toCallback(endpoint netx.Endpoint) netx.Callback {
 			rsp.SetCodec(req.Codec())
 		}
 
+		if rsp.Compress() == compress.TypeNone && opts.Threshold > 0 {
+			setCompress(req, rsp, opts.Threshold)
+		}
+
 		if err != nil {
 			if nerr, ok := err.(netx.Error); ok {
 				rsp.SetStatus(int32(nerr.Code()), nerr.Status())
@@ -60,6 +70,21 @@ func toCallback(endpoint netx.Endpoint) netx.Callback {
 	}
 }
 
+// setCompress 优先使用与请求相同的压缩方式,否则根据请求声明的X-Accept-Compress协商
+func setCompress(req netx.Request, rsp netx.Response, threshold int) {
+	ctype := req.Compress()
+	if ctype == compress.TypeNone {
+		ctype = compress.Negotiate(req.Header().Get(netx.XAcceptCompress))
+	}
+	if ctype == compress.TypeNone || rsp.Body() == nil {
+		return
+	}
+
+	if buf, err := rsp.Body().Buffer(); err == nil && buf != nil && buf.Len() >= threshold {
+		rsp.SetCompress(ctype)
+	}
+}
+
 // toEndpoint 将interface转换成Endpoint
 // 支持以下函数签名:
 //	1: netx.Endpoint
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+
+	"github.com/foredata/nova/netx"
+)
+
+// KeyStore 通过api key查询Principal,不存在时返回nil
+type KeyStore interface {
+	Lookup(ctx context.Context, key string) (*Principal, error)
+}
+
+// KeyStoreFunc 函数形式的KeyStore
+type KeyStoreFunc func(ctx context.Context, key string) (*Principal, error)
+
+func (f KeyStoreFunc) Lookup(ctx context.Context, key string) (*Principal, error) {
+	return f(ctx, key)
+}
+
+// NewStaticKeyStore 使用固定的key列表,内部保存key的sha256,避免按明文比较带来的时序攻击
+func NewStaticKeyStore(keys map[string]*Principal) KeyStore {
+	s := &staticKeyStore{keys: make(map[[sha256.Size]byte]*Principal, len(keys))}
+	for k, p := range keys {
+		s.keys[sha256.Sum256([]byte(k))] = p
+	}
+	return s
+}
+
+type staticKeyStore struct {
+	keys map[[sha256.Size]byte]*Principal
+}
+
+func (s *staticKeyStore) Lookup(ctx context.Context, key string) (*Principal, error) {
+	return s.keys[sha256.Sum256([]byte(key))], nil
+}
+
+// APIKeyOptions api key配置
+type APIKeyOptions struct {
+	Header string // 读取key的header,默认X-Api-Key
+	Query  string // 非空时header不存在则从url query中读取
+}
+
+type APIKeyOption func(o *APIKeyOptions)
+
+// WithKeyHeader 设置读取key的header
+func WithKeyHeader(header string) APIKeyOption {
+	return func(o *APIKeyOptions) {
+		o.Header = header
+	}
+}
+
+// WithKeyQuery 设置读取key的query参数
+func WithKeyQuery(query string) APIKeyOption {
+	return func(o *APIKeyOptions) {
+		o.Query = query
+	}
+}
+
+// NewAPIKey 创建api key认证
+func NewAPIKey(store KeyStore, opts ...APIKeyOption) Authenticator {
+	o := &APIKeyOptions{Header: "X-Api-Key"}
+	for _, fn := range opts {
+		fn(o)
+	}
+	return &apiKeyAuth{store: store, opts: o}
+}
+
+type apiKeyAuth struct {
+	store KeyStore
+	opts  *APIKeyOptions
+}
+
+func (a *apiKeyAuth) Name() string {
+	return "apikey"
+}
+
+func (a *apiKeyAuth) Authenticate(ctx context.Context, req netx.Request) (*Principal, error) {
+	key := netx.GetHeader(req.Header(), a.opts.Header)
+	if key == "" && a.opts.Query != "" {
+		if u := req.URL(); u != nil {
+			key = u.Query().Get(a.opts.Query)
+		}
+	}
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	p, err := a.store.Lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, netx.Unauthorized("invalid api key")
+	}
+
+	// 返回副本,避免修改store中的数据
+	res := *p
+	res.Scheme = a.Name()
+	return &res, nil
+}
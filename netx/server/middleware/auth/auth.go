@@ -0,0 +1,184 @@
+// Package auth 提供服务端认证与鉴权中间件
+//	认证: JWT(HS/RS/PS/ES),API Key,HMAC请求签名
+//	鉴权: 通过Route.Metadata声明式配置RBAC/ABAC策略
+//	凭证统一从Request.Header中读取,http和rpc协议均适用
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server"
+)
+
+// Route.Metadata中用于声明鉴权策略的key
+const (
+	MetaAnonymous = "auth.anonymous" // true表示允许匿名访问
+	MetaRoles     = "auth.roles"     // 逗号分隔,满足任意一个角色即可
+	MetaScopes    = "auth.scopes"    // 逗号分隔,需要满足全部scope
+	MetaAttrs     = "auth.attrs"     // 逗号分隔的key=value,要求Principal.Attrs满足,value支持$param.x,$query.x,$header.x
+	MetaPolicy    = "auth.policy"    // 逗号分隔的规则名,需全部通过,规则通过Engine.AddRule注册
+)
+
+var (
+	// ErrNoCredentials 请求中不存在对应凭证,会继续尝试下一个Authenticator
+	ErrNoCredentials = errors.New("auth: no credentials")
+	// ErrUnauthenticated Authenticator未返回错误也未返回Principal,按认证失败处理
+	ErrUnauthenticated = errors.New("auth: unauthenticated")
+)
+
+// Principal 认证后的主体信息,会放入handler的ctx中
+type Principal struct {
+	ID     string                 // 唯一标识,如UserID,AppID
+	Scheme string                 // 认证方式,如jwt,apikey,hmac
+	Roles  []string               // 角色,用于RBAC
+	Scopes []string               // 授权范围
+	Attrs  map[string]interface{} // 扩展属性,例如jwt claims,用于ABAC
+}
+
+// HasRole 判断是否拥有角色
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasScope 判断是否拥有scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext 将Principal保存到ctx中
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 从ctx中获取Principal
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Authenticator 认证接口,请求中不存在凭证时需返回ErrNoCredentials,成功时必须返回非nil的Principal
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, req netx.Request) (*Principal, error)
+}
+
+// Options 中间件配置
+type Options struct {
+	Authenticators []Authenticator // 按顺序尝试
+	Engine         *Engine         // 鉴权引擎,为nil则只做认证
+}
+
+type Option func(o *Options)
+
+// WithAuthenticator 添加认证方式
+func WithAuthenticator(a ...Authenticator) Option {
+	return func(o *Options) {
+		o.Authenticators = append(o.Authenticators, a...)
+	}
+}
+
+// WithEngine 设置鉴权引擎
+func WithEngine(e *Engine) Option {
+	return func(o *Options) {
+		o.Engine = e
+	}
+}
+
+// New 创建认证鉴权中间件
+//	1: 依次尝试Authenticators,返回ErrNoCredentials则尝试下一个,其他错误直接返回401
+//	2: 全部没有凭证时,若路由声明了auth.anonymous=true则放行,否则返回401
+//	3: 认证成功后使用Engine按路由声明的策略鉴权,失败返回403
+func New(opts ...Option) netx.Middleware {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return func(next netx.Endpoint) netx.Endpoint {
+		return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+			route := server.GetRoute(ctx)
+			p, err := authenticate(ctx, req, o.Authenticators)
+			if err == ErrNoCredentials {
+				if isAnonymous(route) {
+					return next(ctx, req)
+				}
+				return nil, netx.Unauthorized("missing credentials")
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			ctx = NewContext(ctx, p)
+			if o.Engine != nil {
+				if err := o.Engine.Evaluate(ctx, p, req, route); err != nil {
+					return nil, err
+				}
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+func authenticate(ctx context.Context, req netx.Request, authenticators []Authenticator) (*Principal, error) {
+	for _, a := range authenticators {
+		p, err := a.Authenticate(ctx, req)
+		if err == nil && p == nil {
+			err = ErrUnauthenticated
+		}
+		if err == ErrNoCredentials {
+			continue
+		}
+		if err != nil {
+			if _, ok := err.(netx.Error); ok {
+				return nil, err
+			}
+			return nil, netx.Unauthorized("%s: %s", a.Name(), err.Error())
+		}
+		if p.Scheme == "" {
+			p.Scheme = a.Name()
+		}
+		return p, nil
+	}
+
+	return nil, ErrNoCredentials
+}
+
+func isAnonymous(route *netx.Route) bool {
+	return route != nil && route.Metadata[MetaAnonymous] == "true"
+}
+
+// splitList 解析逗号分隔的列表,忽略空白项
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	parts := strings.Split(s, ",")
+	res := parts[:0]
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
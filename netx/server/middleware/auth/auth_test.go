@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	claims := Claims{"sub": "u1", "roles": []string{"admin"}, "scope": "read write", "exp": time.Now().Add(time.Minute).Unix()}
+	token, err := SignJWT("HS256", "", secret, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewJWT(NewStaticKey(secret))
+	req := netx.NewRequest()
+	setHeader(req, "authorization", "Bearer "+token)
+	p, err := a.Authenticate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "u1" || !p.HasRole("admin") || !p.HasScope("write") {
+		t.Fatalf("bad principal, %+v", p)
+	}
+
+	// 使用错误的key类型,避免算法混淆
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := ParseJWT(context.Background(), token, NewStaticKey(&ecKey.PublicKey), nil); err != ErrKeyType {
+		t.Fatalf("expect key type error, %+v", err)
+	}
+
+	expired, _ := SignJWT("HS256", "", secret, Claims{"exp": time.Now().Add(-time.Minute).Unix()})
+	setHeader(req, "Authorization", "Bearer "+expired)
+	if _, err := a.Authenticate(context.Background(), req); err != ErrTokenExpired {
+		t.Fatalf("expect expired, %+v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"EC","kid":"k1","alg":"ES256","crv":"P-256","x":"%s","y":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.X.Bytes()), base64.RawURLEncoding.EncodeToString(key.Y.Bytes()))
+	loads := 0
+	keys := NewJWKS(func(ctx context.Context) ([]byte, error) {
+		loads++
+		return []byte(jwks), nil
+	}, time.Minute)
+
+	token, err := SignJWT("ES256", "k1", key, Claims{"sub": "u2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		claims, err := ParseJWT(context.Background(), token, keys, []string{"ES256"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.String("sub") != "u2" {
+			t.Fatal("bad sub")
+		}
+	}
+
+	if loads != 1 {
+		t.Fatalf("expect cached, loads=%d", loads)
+	}
+}
+
+func TestJWKSStale(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"EC","kid":"k1","alg":"ES256","crv":"P-256","x":"%s","y":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.X.Bytes()), base64.RawURLEncoding.EncodeToString(key.Y.Bytes()))
+	block := make(chan struct{})
+	var loads int32
+	keys := NewJWKS(func(ctx context.Context) ([]byte, error) {
+		if atomic.AddInt32(&loads, 1) > 1 {
+			<-block
+		}
+		return []byte(jwks), nil
+	}, time.Minute)
+	defer close(block)
+
+	if _, err := keys.Lookup(context.Background(), "k1", "ES256"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 过期后刷新阻塞,Lookup仍然使用旧数据立即返回
+	s := keys.(*jwksKeySet)
+	s.mux.Lock()
+	s.loadTime = time.Now().Add(-time.Hour)
+	s.mux.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		for i := 0; i < 3; i++ {
+			if _, err := keys.Lookup(context.Background(), "k1", "ES256"); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lookup blocked by refresh")
+	}
+}
+
+// TestJWKSLoadFail 首次加载失败时按最小间隔重试,取消的请求不影响加载
+func TestJWKSLoadFail(t *testing.T) {
+	var loads int32
+	keys := NewJWKS(func(ctx context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.ErrUnexpectedEOF
+	}, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 3; i++ {
+		if _, err := keys.Lookup(ctx, "k1", "ES256"); err != io.ErrUnexpectedEOF {
+			t.Fatalf("expect load error, %+v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("expect rate limited, loads=%d", n)
+	}
+
+	// 超过最小间隔后重新加载
+	s := keys.(*jwksKeySet)
+	s.mux.Lock()
+	s.loadTime = time.Now().Add(-time.Minute)
+	s.mux.Unlock()
+	_, _ = keys.Lookup(context.Background(), "k1", "ES256")
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Fatalf("expect reload, loads=%d", n)
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	secret := []byte("s3cr3t")
+	a := NewHMAC(SecretStoreFunc(func(ctx context.Context, keyID string) ([]byte, *Principal, error) {
+		if keyID == "app" {
+			return secret, &Principal{ID: "app", Roles: []string{"svc"}}, nil
+		}
+		return nil, nil, nil
+	}))
+
+	ts := time.Now().Unix()
+	req := netx.NewRequest()
+	req.SetMethod(netx.MethodGet)
+	req.SetURI("/orders/1")
+	setHeader(req, "X-Auth-Key", "app")
+	setHeader(req, "X-Auth-Timestamp", strconv.FormatInt(ts, 10))
+	setHeader(req, "X-Auth-Signature", Sign(secret, "GET", "/orders/1", ts, nil))
+	p, err := a.Authenticate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "app" || p.Scheme != "hmac" {
+		t.Fatalf("bad principal, %+v", p)
+	}
+
+	req.SetURI("/orders/2")
+	if _, err := a.Authenticate(context.Background(), req); err == nil {
+		t.Fatal("expect signature mismatch")
+	}
+}
+
+// TestHMACStream 大body以流的方式接收,签名校验后handler仍然可以读取完整body
+func TestHMACStream(t *testing.T) {
+	secret := []byte("s3cr3t")
+	a := NewHMAC(SecretStoreFunc(func(ctx context.Context, keyID string) ([]byte, *Principal, error) {
+		return secret, nil, nil
+	}), WithMaxBodySize(1024))
+
+	newRequest := func(data []byte) netx.Request {
+		ts := time.Now().Unix()
+		req := netx.NewRequest()
+		req.SetMethod(netx.MethodPost)
+		req.SetURI("/upload")
+		setHeader(req, "X-Auth-Key", "app")
+		setHeader(req, "X-Auth-Timestamp", strconv.FormatInt(ts, 10))
+		setHeader(req, "X-Auth-Signature", Sign(secret, "POST", "/upload", ts, data))
+		buf := bytex.NewBuffer()
+		_ = buf.Append(data)
+		_, _ = buf.Seek(0, io.SeekStart)
+		bd := body.NewStreamBody(buf)
+		bd.(body.Writer).Flush()
+		req.SetBody(bd)
+		return req
+	}
+
+	data := bytes.Repeat([]byte("x"), 1000)
+	req := newRequest(data)
+	if _, err := a.Authenticate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ioutil.ReadAll(req.Body()); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("body should be readable after auth, %d %v", len(got), err)
+	}
+
+	_, err := a.Authenticate(context.Background(), newRequest(bytes.Repeat([]byte("x"), 2000)))
+	if nerr, ok := err.(netx.Error); !ok || nerr.Code() != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413, %v", err)
+	}
+}
+
+// nilAuthenticator 既不返回错误也不返回Principal
+type nilAuthenticator struct{}
+
+func (nilAuthenticator) Name() string { return "nil" }
+func (nilAuthenticator) Authenticate(ctx context.Context, req netx.Request) (*Principal, error) {
+	return nil, nil
+}
+
+func TestNilPrincipal(t *testing.T) {
+	_, err := authenticate(context.Background(), netx.NewRequest(), []Authenticator{nilAuthenticator{}})
+	if nerr, ok := err.(netx.Error); !ok || nerr.Code() != http.StatusUnauthorized {
+		t.Fatalf("expect unauthorized, %+v", err)
+	}
+}
+
+func TestEngine(t *testing.T) {
+	e := NewEngine()
+	e.AddRule("weekday", func(ctx context.Context, p *Principal, req netx.Request, route *netx.Route) (bool, error) {
+		return p.Attrs["weekday"] == true, nil
+	})
+
+	route := &netx.Route{Metadata: map[string]string{
+		MetaRoles:  "admin,ops",
+		MetaScopes: "write",
+		MetaAttrs:  "tenant=$header.X-Tenant",
+		MetaPolicy: "weekday",
+	}}
+
+	req := netx.NewRequest()
+	setHeader(req, "X-Tenant", "acme")
+	p := &Principal{Roles: []string{"ops"}, Scopes: []string{"write"}, Attrs: map[string]interface{}{"tenant": "acme", "weekday": true}}
+	if err := e.Evaluate(context.Background(), p, req, route); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Attrs["tenant"] = "other"
+	if err := e.Evaluate(context.Background(), p, req, route); err == nil {
+		t.Fatal("expect forbidden")
+	}
+}
+
+func setHeader(req netx.Request, key, value string) {
+	h := req.Header()
+	h.Set(key, value)
+	req.SetHeader(h)
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// SecretStore 通过keyID查询签名密钥及对应的Principal,不存在时返回nil
+type SecretStore interface {
+	Secret(ctx context.Context, keyID string) ([]byte, *Principal, error)
+}
+
+// SecretStoreFunc 函数形式的SecretStore
+type SecretStoreFunc func(ctx context.Context, keyID string) ([]byte, *Principal, error)
+
+func (f SecretStoreFunc) Secret(ctx context.Context, keyID string) ([]byte, *Principal, error) {
+	return f(ctx, keyID)
+}
+
+// HMACOptions 请求签名配置
+type HMACOptions struct {
+	KeyHeader       string        // keyID所在header,默认X-Auth-Key
+	TimestampHeader string        // unix秒时间戳所在header,默认X-Auth-Timestamp
+	SignatureHeader string        // 签名所在header,默认X-Auth-Signature
+	MaxSkew         time.Duration // 允许的时钟误差,默认5分钟,用于防重放
+	MaxBodySize     int64         // 流式body签名时最多缓存的字节数,超过返回413,默认32M
+}
+
+type HMACOption func(o *HMACOptions)
+
+// WithSignHeaders 设置签名相关header
+func WithSignHeaders(key, timestamp, signature string) HMACOption {
+	return func(o *HMACOptions) {
+		o.KeyHeader = key
+		o.TimestampHeader = timestamp
+		o.SignatureHeader = signature
+	}
+}
+
+// WithMaxBodySize 设置流式body签名时最多缓存的字节数
+func WithMaxBodySize(n int64) HMACOption {
+	return func(o *HMACOptions) {
+		o.MaxBodySize = n
+	}
+}
+
+// WithMaxSkew 设置允许的时钟误差
+func WithMaxSkew(d time.Duration) HMACOption {
+	return func(o *HMACOptions) {
+		o.MaxSkew = d
+	}
+}
+
+// Sign 计算请求签名,客户端可直接使用
+//	StringToSign = Method + "\n" + URI + "\n" + Timestamp + "\n" + Hex(SHA256(Body))
+//	Signature = Base64(HMAC-SHA256(Secret, StringToSign))
+//	rpc请求中Method为Unknown,URI为空时使用CmdID
+func Sign(secret []byte, method string, uri string, timestamp int64, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(uri))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(hex.EncodeToString(sum[:])))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewHMAC 创建hmac请求签名认证
+func NewHMAC(store SecretStore, opts ...HMACOption) Authenticator {
+	o := &HMACOptions{
+		KeyHeader:       "X-Auth-Key",
+		TimestampHeader: "X-Auth-Timestamp",
+		SignatureHeader: "X-Auth-Signature",
+		MaxSkew:         5 * time.Minute,
+		MaxBodySize:     32 << 20,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &hmacAuth{store: store, opts: o}
+}
+
+type hmacAuth struct {
+	store SecretStore
+	opts  *HMACOptions
+}
+
+func (a *hmacAuth) Name() string {
+	return "hmac"
+}
+
+func (a *hmacAuth) Authenticate(ctx context.Context, req netx.Request) (*Principal, error) {
+	header := req.Header()
+	keyID := netx.GetHeader(header, a.opts.KeyHeader)
+	signature := netx.GetHeader(header, a.opts.SignatureHeader)
+	if keyID == "" || signature == "" {
+		return nil, ErrNoCredentials
+	}
+
+	ts, err := strconv.ParseInt(netx.GetHeader(header, a.opts.TimestampHeader), 10, 64)
+	if err != nil {
+		return nil, netx.Unauthorized("hmac: invalid timestamp")
+	}
+	skew := time.Since(time.Unix(ts, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if a.opts.MaxSkew > 0 && skew > a.opts.MaxSkew {
+		return nil, netx.Unauthorized("hmac: timestamp expired")
+	}
+
+	secret, p, err := a.store.Secret(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, netx.Unauthorized("hmac: unknown key")
+	}
+
+	data, err := readBody(req, a.opts.MaxBodySize)
+	if err == errBodyTooLarge {
+		return nil, netx.NewError(http.StatusRequestEntityTooLarge, "", "hmac: body too large")
+	}
+	if err != nil {
+		return nil, netx.Unauthorized("hmac: %s", err.Error())
+	}
+
+	uri := req.URI()
+	if uri == "" {
+		uri = strconv.FormatUint(uint64(req.CmdID()), 10)
+	}
+
+	expect := Sign(secret, req.Method().String(), uri, ts, data)
+	if !hmac.Equal([]byte(expect), []byte(signature)) {
+		return nil, netx.Unauthorized("hmac: signature mismatch")
+	}
+
+	res := &Principal{ID: keyID}
+	if p != nil {
+		cp := *p
+		res = &cp
+	}
+	res.Scheme = a.Name()
+	return res, nil
+}
+
+var errBodyTooLarge = errors.New("body too large")
+
+// readBody 读取完整body,大body会以流的方式接收,读取后替换为BufferBody,handler可以继续读取
+func readBody(req netx.Request, max int64) ([]byte, error) {
+	b := req.Body()
+	if b == nil {
+		return nil, nil
+	}
+
+	buf, err := b.Buffer()
+	if err == nil {
+		if buf == nil {
+			return nil, nil
+		}
+		return buf.Bytes(), nil
+	}
+	if err != body.ErrNotSupport {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(b, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		_ = b.Close()
+		return nil, errBodyTooLarge
+	}
+
+	nb := bytex.NewBuffer()
+	_ = nb.Append(data)
+	_, _ = nb.Seek(0, io.SeekStart)
+	req.SetBody(body.NewBufferBody(nb))
+	return data, nil
+}
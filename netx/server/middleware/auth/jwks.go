@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/foredata/nova/pkg/singleflight"
+)
+
+var (
+	ErrKeyNotFound = errors.New("jwt: key not found")
+)
+
+// KeySet 用于查询校验签名的key
+//	HS算法返回[]byte,RS/PS返回*rsa.PublicKey,ES返回*ecdsa.PublicKey
+type KeySet interface {
+	Lookup(ctx context.Context, kid string, alg string) (interface{}, error)
+}
+
+// NewStaticKey 使用固定key,忽略kid
+func NewStaticKey(key interface{}) KeySet {
+	return &staticKeySet{key: key}
+}
+
+type staticKeySet struct {
+	key interface{}
+}
+
+func (s *staticKeySet) Lookup(ctx context.Context, kid string, alg string) (interface{}, error) {
+	return s.key, nil
+}
+
+// JWK json web key,see RFC7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC curve
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"k,omitempty"` // oct key
+}
+
+// Key 转换成校验使用的key
+func (k *JWK) Key() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwks: unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, fmt.Errorf("jwks: unsupported kty %s", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// JWKS key集合
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// parseJWKS 解析jwks,忽略不支持的key
+func parseJWKS(data []byte) (map[string]*jwkEntry, error) {
+	jwks := JWKS{}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*jwkEntry, len(jwks.Keys))
+	for i := range jwks.Keys {
+		k := &jwks.Keys[i]
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.Key()
+		if err != nil {
+			continue
+		}
+		res[k.Kid] = &jwkEntry{alg: k.Alg, key: key}
+	}
+
+	return res, nil
+}
+
+type jwkEntry struct {
+	alg string
+	key interface{}
+}
+
+// LoadFunc 加载jwks原始数据
+type LoadFunc func(ctx context.Context) ([]byte, error)
+
+// NewJWKSFile 从文件中加载jwks,ttl后重新加载
+func NewJWKSFile(path string, ttl time.Duration) KeySet {
+	return NewJWKS(func(ctx context.Context) ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}, ttl)
+}
+
+// NewJWKSURL 从url中加载jwks,ttl后重新加载
+func NewJWKSURL(url string, ttl time.Duration) KeySet {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return NewJWKS(func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks: fetch %s fail, status %d", url, rsp.StatusCode)
+		}
+		return ioutil.ReadAll(rsp.Body)
+	}, ttl)
+}
+
+// NewJWKS 通过LoadFunc加载jwks并缓存,ttl过期或kid不存在时重新加载,
+// 加载失败时继续使用旧数据
+//	ttl过期时后台刷新,刷新期间继续使用旧数据,仅首次加载及kid不存在时需要等待刷新完成
+//	首次加载失败时,间隔minRefresh后才会重试,期间直接返回上次的错误
+func NewJWKS(load LoadFunc, ttl time.Duration) KeySet {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &jwksKeySet{load: load, ttl: ttl, minRefresh: 10 * time.Second, timeout: 10 * time.Second}
+}
+
+type jwksKeySet struct {
+	mux        sync.RWMutex       // 保护keys,loadTime
+	group      singleflight.Group // 合并并发的刷新
+	load       LoadFunc           //
+	ttl        time.Duration      //
+	minRefresh time.Duration      // 因kid不存在或首次加载失败触发刷新的最小间隔,避免被恶意kid打满或加剧IdP故障
+	timeout    time.Duration      // 加载超时时间,不使用请求的ctx,避免单个请求取消导致所有等待者失败
+	refreshing int32              // 是否正在后台刷新
+	keys       map[string]*jwkEntry
+	loadTime   time.Time // 最近一次加载时间,包括失败
+	loadErr    error     // 最近一次加载的错误
+}
+
+func (s *jwksKeySet) Lookup(ctx context.Context, kid string, alg string) (interface{}, error) {
+	s.mux.RLock()
+	keys, loadTime, loadErr := s.keys, s.loadTime, s.loadErr
+	s.mux.RUnlock()
+
+	now := time.Now()
+	e, ok := keys[kid]
+	switch {
+	case keys == nil && !loadTime.IsZero() && now.Sub(loadTime) <= s.minRefresh:
+		// 首次加载失败,间隔内直接返回上次的错误
+		return nil, loadErr
+	case keys == nil:
+		// 首次加载
+		if err := s.refresh(); err != nil {
+			return nil, err
+		}
+		e, ok = s.get(kid)
+	case !ok && now.Sub(loadTime) > s.minRefresh:
+		// key可能已经轮换,等待刷新完成
+		_ = s.refresh()
+		e, ok = s.get(kid)
+	case now.Sub(loadTime) > s.ttl && atomic.CompareAndSwapInt32(&s.refreshing, 0, 1):
+		// 过期后后台刷新,继续使用旧数据
+		go func() {
+			defer atomic.StoreInt32(&s.refreshing, 0)
+			_ = s.refresh()
+		}()
+	}
+
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	if e.alg != "" && e.alg != alg {
+		return nil, ErrAlgNotAllowed
+	}
+
+	return e.key, nil
+}
+
+func (s *jwksKeySet) get(kid string) (*jwkEntry, bool) {
+	s.mux.RLock()
+	e, ok := s.keys[kid]
+	s.mux.RUnlock()
+	return e, ok
+}
+
+// refresh 重新加载,并发调用只会加载一次,加载期间不持有锁,失败时保留旧数据
+func (s *jwksKeySet) refresh() error {
+	_, err := s.group.Do("", func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		data, err := s.load(ctx)
+		if err == nil {
+			var keys map[string]*jwkEntry
+			if keys, err = parseJWKS(data); err == nil {
+				s.mux.Lock()
+				s.keys = keys
+				s.mux.Unlock()
+			}
+		}
+
+		s.mux.Lock()
+		s.loadTime = time.Now()
+		s.loadErr = err
+		s.mux.Unlock()
+		return nil, err
+	})
+
+	return err
+}
@@ -0,0 +1,429 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	// 注册hash算法
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/foredata/nova/netx"
+)
+
+var randReader = rand.Reader
+
+var (
+	ErrTokenMalformed   = errors.New("jwt: token malformed")
+	ErrTokenExpired     = errors.New("jwt: token expired")
+	ErrTokenNotValidYet = errors.New("jwt: token not valid yet")
+	ErrTokenSignature   = errors.New("jwt: signature invalid")
+	ErrTokenIssuer      = errors.New("jwt: issuer invalid")
+	ErrTokenAudience    = errors.New("jwt: audience invalid")
+	ErrAlgNotAllowed    = errors.New("jwt: algorithm not allowed")
+	ErrKeyType          = errors.New("jwt: key type mismatch")
+)
+
+// Claims jwt payload
+type Claims map[string]interface{}
+
+// String 获取字符串类型的claim
+func (c Claims) String(key string) string {
+	s, _ := c[key].(string)
+	return s
+}
+
+// Strings 获取字符串数组类型的claim,兼容空格分隔的字符串,例如scope
+func (c Claims) Strings(key string) []string {
+	switch v := c[key].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+
+	return nil
+}
+
+// Time 获取NumericDate类型的claim
+func (c Claims) Time(key string) (time.Time, bool) {
+	switch v := c[key].(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), err == nil
+	}
+
+	return time.Time{}, false
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// ParseJWT 解析token并校验签名,key通过KeySet查询,algs为允许的算法,为空表示不限制(none除外)
+//	注意:并不校验exp,nbf等时间信息
+func ParseJWT(ctx context.Context, token string, keys KeySet, algs []string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	hdata, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	hdr := jwtHeader{}
+	if err := json.Unmarshal(hdata, &hdr); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	if hdr.Alg == "" || strings.EqualFold(hdr.Alg, "none") || !allowAlg(algs, hdr.Alg) {
+		return nil, ErrAlgNotAllowed
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	key, err := keys.Lookup(ctx, hdr.Kid, hdr.Alg)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := token[:len(parts[0])+1+len(parts[1])]
+	if err := verifySignature(hdr.Alg, key, []byte(signed), sig); err != nil {
+		return nil, err
+	}
+
+	pdata, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	claims := Claims{}
+	if err := json.Unmarshal(pdata, &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	return claims, nil
+}
+
+// SignJWT 生成token,key类型需要与alg匹配,HS为[]byte,RS/PS为*rsa.PrivateKey,ES为*ecdsa.PrivateKey
+func SignJWT(alg string, kid string, key interface{}, claims Claims) (string, error) {
+	hdata, err := json.Marshal(&jwtHeader{Alg: alg, Kid: kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	pdata, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(hdata) + "." + base64.RawURLEncoding.EncodeToString(pdata)
+	sig, err := sign(alg, key, []byte(signed))
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func allowAlg(algs []string, alg string) bool {
+	if len(algs) == 0 {
+		return true
+	}
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+
+	return false
+}
+
+func algHash(alg string) (crypto.Hash, error) {
+	if len(alg) != 5 {
+		return 0, ErrAlgNotAllowed
+	}
+
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, nil
+	case "384":
+		return crypto.SHA384, nil
+	case "512":
+		return crypto.SHA512, nil
+	default:
+		return 0, ErrAlgNotAllowed
+	}
+}
+
+func digest(h crypto.Hash, data []byte) []byte {
+	hh := h.New()
+	hh.Write(data)
+	return hh.Sum(nil)
+}
+
+// verifySignature 校验签名,key类型必须与算法匹配,避免算法混淆攻击
+func verifySignature(alg string, key interface{}, signed, sig []byte) error {
+	h, err := algHash(alg)
+	if err != nil {
+		return err
+	}
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrKeyType
+		}
+		mac := hmac.New(h.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrTokenSignature
+		}
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrKeyType
+		}
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, h, digest(h, signed), sig)
+		} else {
+			err = rsa.VerifyPSS(pub, h, digest(h, signed), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: h})
+		}
+		if err != nil {
+			return ErrTokenSignature
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrKeyType
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest(h, signed), r, s) {
+			return ErrTokenSignature
+		}
+	default:
+		return ErrAlgNotAllowed
+	}
+
+	return nil
+}
+
+func sign(alg string, key interface{}, signed []byte) ([]byte, error) {
+	h, err := algHash(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, ErrKeyType
+		}
+		mac := hmac.New(h.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil), nil
+	case "RS":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrKeyType
+		}
+		return rsa.SignPKCS1v15(nil, priv, h, digest(h, signed))
+	case "PS":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrKeyType
+		}
+		return rsa.SignPSS(randReader, priv, h, digest(h, signed), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: h})
+	case "ES":
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, ErrKeyType
+		}
+		r, s, err := ecdsa.Sign(randReader, priv, digest(h, signed))
+		if err != nil {
+			return nil, err
+		}
+		size := (priv.Curve.Params().BitSize + 7) / 8
+		out := make([]byte, 2*size)
+		r.FillBytes(out[:size])
+		s.FillBytes(out[size:])
+		return out, nil
+	default:
+		return nil, ErrAlgNotAllowed
+	}
+}
+
+// JWTOptions jwt认证配置
+type JWTOptions struct {
+	Header     string        // 读取token的header,默认Authorization
+	Scheme     string        // token前缀,默认Bearer,为空表示header中直接是token
+	Issuer     string        // 非空则校验iss
+	Audience   string        // 非空则校验aud
+	Leeway     time.Duration // 时间校验允许的误差
+	Algorithms []string      // 允许的算法,为空表示不限制
+	RolesClaim string        // 角色claim,默认roles
+	ScopeClaim string        // scope claim,默认scope
+}
+
+type JWTOption func(o *JWTOptions)
+
+// WithJWTHeader 设置读取token的header及前缀
+func WithJWTHeader(header, scheme string) JWTOption {
+	return func(o *JWTOptions) {
+		o.Header = header
+		o.Scheme = scheme
+	}
+}
+
+// WithIssuer 校验iss
+func WithIssuer(iss string) JWTOption {
+	return func(o *JWTOptions) {
+		o.Issuer = iss
+	}
+}
+
+// WithAudience 校验aud
+func WithAudience(aud string) JWTOption {
+	return func(o *JWTOptions) {
+		o.Audience = aud
+	}
+}
+
+// WithLeeway 设置时间误差
+func WithLeeway(d time.Duration) JWTOption {
+	return func(o *JWTOptions) {
+		o.Leeway = d
+	}
+}
+
+// WithAlgorithms 限制允许的签名算法
+func WithAlgorithms(algs ...string) JWTOption {
+	return func(o *JWTOptions) {
+		o.Algorithms = algs
+	}
+}
+
+// WithClaimNames 设置角色和scope对应的claim名
+func WithClaimNames(roles, scope string) JWTOption {
+	return func(o *JWTOptions) {
+		o.RolesClaim = roles
+		o.ScopeClaim = scope
+	}
+}
+
+// NewJWT 创建jwt认证
+func NewJWT(keys KeySet, opts ...JWTOption) Authenticator {
+	o := &JWTOptions{
+		Header:     "Authorization",
+		Scheme:     "Bearer",
+		RolesClaim: "roles",
+		ScopeClaim: "scope",
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &jwtAuth{keys: keys, opts: o}
+}
+
+type jwtAuth struct {
+	keys KeySet
+	opts *JWTOptions
+}
+
+func (a *jwtAuth) Name() string {
+	return "jwt"
+}
+
+func (a *jwtAuth) Authenticate(ctx context.Context, req netx.Request) (*Principal, error) {
+	token := netx.GetHeader(req.Header(), a.opts.Header)
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	if a.opts.Scheme != "" {
+		n := len(a.opts.Scheme)
+		if len(token) <= n || !strings.EqualFold(token[:n], a.opts.Scheme) || token[n] != ' ' {
+			return nil, ErrNoCredentials
+		}
+		token = strings.TrimSpace(token[n+1:])
+	}
+
+	claims, err := ParseJWT(ctx, token, a.keys, a.opts.Algorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.validate(claims); err != nil {
+		return nil, err
+	}
+
+	p := &Principal{
+		ID:     claims.String("sub"),
+		Scheme: a.Name(),
+		Roles:  claims.Strings(a.opts.RolesClaim),
+		Scopes: claims.Strings(a.opts.ScopeClaim),
+		Attrs:  claims,
+	}
+
+	return p, nil
+}
+
+func (a *jwtAuth) validate(claims Claims) error {
+	now := time.Now()
+	if exp, ok := claims.Time("exp"); ok && now.After(exp.Add(a.opts.Leeway)) {
+		return ErrTokenExpired
+	}
+
+	if nbf, ok := claims.Time("nbf"); ok && now.Add(a.opts.Leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+
+	if a.opts.Issuer != "" && claims.String("iss") != a.opts.Issuer {
+		return ErrTokenIssuer
+	}
+
+	if a.opts.Audience != "" {
+		found := false
+		for _, aud := range claims.Strings("aud") {
+			if aud == a.opts.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrTokenAudience
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server"
+)
+
+// Rule ABAC规则,返回是否允许访问
+type Rule func(ctx context.Context, p *Principal, req netx.Request, route *netx.Route) (bool, error)
+
+// NewEngine 创建鉴权引擎
+func NewEngine() *Engine {
+	return &Engine{rules: make(map[string]Rule)}
+}
+
+// Engine 根据Route.Metadata中声明的策略进行鉴权,执行顺序:
+//	auth.roles -> auth.scopes -> auth.attrs -> auth.policy
+//	未声明任何策略的路由默认放行
+type Engine struct {
+	mux   sync.RWMutex
+	rules map[string]Rule
+}
+
+// AddRule 注册ABAC规则,路由中通过auth.policy引用
+func (e *Engine) AddRule(name string, rule Rule) {
+	e.mux.Lock()
+	e.rules[name] = rule
+	e.mux.Unlock()
+}
+
+func (e *Engine) getRule(name string) Rule {
+	e.mux.RLock()
+	r := e.rules[name]
+	e.mux.RUnlock()
+	return r
+}
+
+// Evaluate 鉴权,失败时返回403
+func (e *Engine) Evaluate(ctx context.Context, p *Principal, req netx.Request, route *netx.Route) error {
+	if route == nil || len(route.Metadata) == 0 {
+		return nil
+	}
+
+	md := route.Metadata
+	if roles := splitList(md[MetaRoles]); len(roles) > 0 {
+		ok := false
+		for _, r := range roles {
+			if p.HasRole(r) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return netx.Forbidden("role required: %s", md[MetaRoles])
+		}
+	}
+
+	for _, s := range splitList(md[MetaScopes]) {
+		if !p.HasScope(s) {
+			return netx.Forbidden("scope required: %s", s)
+		}
+	}
+
+	for _, kv := range splitList(md[MetaAttrs]) {
+		idx := strings.IndexByte(kv, '=')
+		if idx == -1 {
+			return netx.InternalServerError("invalid auth attrs: %s", kv)
+		}
+		key := strings.TrimSpace(kv[:idx])
+		expect := resolveValue(req, strings.TrimSpace(kv[idx+1:]))
+		if expect == "" || fmt.Sprint(p.Attrs[key]) != expect {
+			return netx.Forbidden("attribute mismatch: %s", key)
+		}
+	}
+
+	for _, name := range splitList(md[MetaPolicy]) {
+		rule := e.getRule(name)
+		if rule == nil {
+			return netx.InternalServerError("auth rule not found: %s", name)
+		}
+		ok, err := rule(ctx, p, req, route)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return netx.Forbidden("denied by policy: %s", name)
+		}
+	}
+
+	return nil
+}
+
+// resolveValue 解析引用,支持$param.x,$query.x,$header.x,其他视为字面值
+func resolveValue(req netx.Request, v string) string {
+	if !strings.HasPrefix(v, "$") {
+		return v
+	}
+
+	idx := strings.IndexByte(v, '.')
+	if idx == -1 {
+		return ""
+	}
+
+	name := v[idx+1:]
+	switch v[1:idx] {
+	case "param":
+		params := req.Params()
+		return params.Get(name)
+	case "query":
+		if u := req.URL(); u != nil {
+			return u.Query().Get(name)
+		}
+	case "header":
+		return netx.GetHeader(req.Header(), name)
+	}
+
+	return ""
+}
+
+// Authorize 仅鉴权的中间件,需要在认证中间件之后执行,未认证时返回401
+func Authorize(engine *Engine) netx.Middleware {
+	return func(next netx.Endpoint) netx.Endpoint {
+		return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+			route := server.GetRoute(ctx)
+			p, ok := FromContext(ctx)
+			if !ok {
+				if isAnonymous(route) {
+					return next(ctx, req)
+				}
+				return nil, netx.Unauthorized("missing principal")
+			}
+
+			if err := engine.Evaluate(ctx, p, req, route); err != nil {
+				return nil, err
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
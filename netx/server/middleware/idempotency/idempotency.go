@@ -0,0 +1,469 @@
+// Package idempotency 基于Idempotency-Key的幂等中间件
+//	1: 使用Put(WithNX,WithTTL)原子占用key,占用成功则执行handler并保存应答
+//	2: 重复请求直接返回已保存的应答,并设置Idempotent-Replayed: true
+//	3: 并发的重复请求等待首个请求完成,超时返回409
+//	4: 同一个key但请求内容不同时返回422,流式body只使用前MaxBodySize字节计算指纹
+//	LockTTL必须大于handler最长执行时间,否则执行期间key过期,重复请求会再次执行handler
+//	应答保存使用独立的context,不受请求取消影响,保存失败时记录日志及指标
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/errorx"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/server/middleware/auth"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/store"
+)
+
+const (
+	// HeaderKey 请求中携带幂等key的header
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed 重放应答时设置的header
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+const (
+	statePending = "pending"
+	stateDone    = "done"
+)
+
+var gSaveFailed = metrics.NewCounter(&metrics.CounterOpts{
+	Namespace: "nova",
+	Subsystem: "idempotency",
+	Name:      "save_failed",
+	Help:      "responses failed to save",
+})
+
+// Options 中间件配置
+type Options struct {
+	Prefix       string        // store中key前缀
+	TTL          time.Duration // 应答保存时间,默认24小时
+	LockTTL      time.Duration // 执行中状态的过期时间,防止进程异常退出后key永远无法使用,需大于handler最长执行时间,默认1分钟
+	SaveTimeout  time.Duration // 保存应答超时时间,默认5s
+	Wait         time.Duration // 并发重复请求最长等待时间,0表示直接返回409
+	PollInterval time.Duration // 等待时轮询间隔,默认50ms
+	Required     bool          // 是否必须携带Idempotency-Key,否则返回400
+	MaxBodySize  int64         // 流式body计算指纹时最多读取的字节数,超过部分不参与计算,默认1M
+}
+
+type Option func(o *Options)
+
+// WithPrefix 设置key前缀
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithTTL 设置应答保存时间
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithLockTTL 设置执行中状态过期时间,需要大于handler最长执行时间
+func WithLockTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.LockTTL = ttl
+	}
+}
+
+// WithSaveTimeout 设置保存应答超时时间
+func WithSaveTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.SaveTimeout = d
+	}
+}
+
+// WithWait 设置并发重复请求等待时间
+func WithWait(d time.Duration) Option {
+	return func(o *Options) {
+		o.Wait = d
+	}
+}
+
+// WithMaxBodySize 设置流式body计算指纹时最多读取的字节数
+func WithMaxBodySize(n int64) Option {
+	return func(o *Options) {
+		o.MaxBodySize = n
+	}
+}
+
+// WithRequired 必须携带Idempotency-Key
+func WithRequired() Option {
+	return func(o *Options) {
+		o.Required = true
+	}
+}
+
+// record 保存在store中的数据
+type record struct {
+	State       string     `json:"state"`
+	Fingerprint string     `json:"fp"`
+	Code        int32      `json:"code,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	Codec       uint32     `json:"codec,omitempty"`
+	Header      []metaPair `json:"header,omitempty"`
+	Body        []byte     `json:"body,omitempty"`
+	Err         bool       `json:"err,omitempty"` // handler返回的是netx.Error
+}
+
+type metaPair struct {
+	Key    string   `json:"k"`
+	Values []string `json:"v"`
+}
+
+// New 创建幂等中间件
+func New(s store.Store, opts ...Option) netx.Middleware {
+	o := &Options{
+		Prefix:       "idempotency:",
+		TTL:          24 * time.Hour,
+		LockTTL:      time.Minute,
+		SaveTimeout:  5 * time.Second,
+		PollInterval: 50 * time.Millisecond,
+		MaxBodySize:  1 << 20,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	m := &middleware{store: s, opts: o}
+	return m.wrap
+}
+
+type middleware struct {
+	store store.Store
+	opts  *Options
+}
+
+func (m *middleware) wrap(next netx.Endpoint) netx.Endpoint {
+	return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		ikey := netx.GetHeader(req.Header(), HeaderKey)
+		if ikey == "" {
+			if m.opts.Required {
+				return nil, netx.BadRequest("missing %s", HeaderKey)
+			}
+			return next(ctx, req)
+		}
+
+		fp, err := fingerprint(req, m.opts.MaxBodySize)
+		if err != nil {
+			return nil, netx.BadRequest("idempotency: %s", err.Error())
+		}
+
+		key := m.storeKey(ctx, req, ikey)
+		pending, _ := json.Marshal(&record{State: statePending, Fingerprint: fp})
+		ok, _, err := m.store.Put(ctx, key, pending, store.WithNX(), store.WithTTL(m.opts.LockTTL))
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return m.replay(ctx, key, fp)
+		}
+
+		rsp, err := next(ctx, req)
+		m.save(server.GetResponseHeader(ctx), key, fp, rsp, err)
+		return rsp, err
+	}
+}
+
+// storeKey 按路由和认证主体隔离,避免不同接口或用户之间key冲突
+func (m *middleware) storeKey(ctx context.Context, req netx.Request, ikey string) string {
+	sb := strings.Builder{}
+	sb.WriteString(m.opts.Prefix)
+	if p, ok := auth.FromContext(ctx); ok {
+		sb.WriteString(p.ID)
+		sb.WriteByte(':')
+	}
+	if route := server.GetRoute(ctx); route != nil {
+		sb.WriteString(route.Method.String())
+		sb.WriteString(route.Path)
+	} else {
+		sb.WriteString(req.URI())
+	}
+	sb.WriteByte(':')
+	sb.WriteString(ikey)
+	return sb.String()
+}
+
+// replay 等待首个请求完成并返回保存的应答
+func (m *middleware) replay(ctx context.Context, key string, fp string) (netx.Response, error) {
+	deadline := time.Now().Add(m.opts.Wait)
+	for {
+		rec, err := m.load(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		if rec == nil {
+			// 首个请求执行失败,key已被删除,允许客户端重试
+			return nil, netx.Conflict("idempotency: previous request failed, retry")
+		}
+
+		if rec.Fingerprint != fp {
+			return nil, netx.NewError(http.StatusUnprocessableEntity, "", "idempotency: key reused with different request")
+		}
+
+		if rec.State == stateDone {
+			return toResponse(ctx, rec)
+		}
+
+		if m.opts.Wait <= 0 || time.Now().After(deadline) {
+			return nil, netx.Conflict("idempotency: request in progress")
+		}
+
+		timer := time.NewTimer(m.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, netx.Conflict("idempotency: request in progress")
+		case <-timer.C:
+		}
+	}
+}
+
+func (m *middleware) load(ctx context.Context, key string) (*record, error) {
+	data, err := m.store.Get(ctx, key)
+	if err == errorx.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &record{}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// save 保存应答,服务端错误或无法保存的流式应答会删除key,允许客户端重试
+//	使用独立的context,避免请求已取消或超时导致保存失败,key只能等待LockTTL过期
+func (m *middleware) save(header netx.Header, key string, fp string, rsp netx.Response, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.SaveTimeout)
+	defer cancel()
+
+	rec, ok := toRecord(header, fp, rsp, err)
+	if !ok {
+		if err := m.store.Delete(ctx, key); err != nil {
+			m.onSaveFail(key, err)
+		}
+		return
+	}
+
+	data, merr := json.Marshal(rec)
+	if merr != nil {
+		if err := m.store.Delete(ctx, key); err != nil {
+			m.onSaveFail(key, err)
+		}
+		return
+	}
+
+	if _, _, err := m.store.Put(ctx, key, data, store.WithTTL(m.opts.TTL)); err != nil {
+		m.onSaveFail(key, err)
+	}
+}
+
+func (m *middleware) onSaveFail(key string, err error) {
+	gSaveFailed.Inc()
+	log.Printf("idempotency: save %s fail, err=%+v", key, err)
+}
+
+// toRecord header为通过server.SetResponseHeader设置的header,rsp中的header优先
+func toRecord(header netx.Header, fp string, rsp netx.Response, err error) (*record, bool) {
+	rec := &record{State: stateDone, Fingerprint: fp}
+	h := netx.NewHeader()
+	h.Merge(header)
+	if rsp != nil {
+		h.Merge(rsp.Header())
+	}
+	for _, kv := range h {
+		rec.Header = append(rec.Header, metaPair{Key: kv.Key, Values: kv.Values})
+	}
+
+	if err != nil {
+		nerr, ok := err.(netx.Error)
+		if !ok || nerr.Code() >= 500 {
+			return nil, false
+		}
+		rec.Err = true
+		rec.Code = int32(nerr.Code())
+		rec.Status = nerr.Status()
+		return rec, true
+	}
+
+	if rsp == nil {
+		return rec, true
+	}
+
+	rec.Code = rsp.StatusCode()
+	rec.Status = rsp.StatusInfo()
+	if rec.Code >= 500 {
+		return nil, false
+	}
+	rec.Codec = rsp.Codec()
+
+	if b := rsp.Body(); b != nil {
+		buf, err := b.Buffer()
+		if err != nil {
+			return nil, false
+		}
+		if buf != nil {
+			rec.Body = buf.Bytes()
+		}
+	}
+
+	return rec, true
+}
+
+func toResponse(ctx context.Context, rec *record) (netx.Response, error) {
+	h := netx.NewHeader()
+	for _, kv := range rec.Header {
+		h.SetValues(kv.Key, kv.Values)
+	}
+	h.Set(HeaderReplayed, "true")
+	if rec.Err {
+		// 错误应答没有Response,通过server header返回
+		server.SetResponseHeader(ctx, h)
+		return nil, netx.NewError(int(rec.Code), rec.Status, "idempotent replay")
+	}
+
+	rsp := netx.NewResponse()
+	rsp.SetHeader(h)
+	rsp.SetCodec(rec.Codec)
+	if rec.Code != 0 {
+		rsp.SetStatus(rec.Code, rec.Status)
+	}
+	if len(rec.Body) > 0 {
+		buf := bytex.NewBuffer()
+		_ = buf.Append(rec.Body)
+		_, _ = buf.Seek(0, io.SeekStart)
+		rsp.SetBody(body.NewBufferBody(buf))
+	}
+
+	return rsp, nil
+}
+
+// fingerprint 请求指纹,sha256(method,uri,body)
+func fingerprint(req netx.Request, max int64) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(req.Method().String()))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(req.URI()))
+	h.Write([]byte{'\n'})
+	if b := req.Body(); b != nil {
+		buf, err := b.Buffer()
+		switch {
+		case err == body.ErrNotSupport:
+			data, err := peekStream(req, max)
+			if err != nil {
+				return "", err
+			}
+			h.Write(data)
+		case err != nil:
+			return "", err
+		case buf != nil:
+			h.Write(buf.Bytes())
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// peekStream 读取流式body的前max字节,读取的数据会放回body,handler仍可读取完整数据
+//	读取完毕时替换为BufferBody,否则使用prefixBody先返回已读取的数据
+func peekStream(req netx.Request, max int64) ([]byte, error) {
+	b := req.Body()
+	var data []byte
+	for int64(len(data)) < max {
+		buf, err := b.ReadFast(true)
+		if buf != nil {
+			data = append(data, buf.Bytes()...)
+		}
+		if err == io.EOF {
+			req.SetBody(body.NewBufferBody(newBuffer(data)))
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req.SetBody(&prefixBody{Body: b, prefix: newBuffer(data)})
+	return data[:max], nil
+}
+
+func newBuffer(data []byte) bytex.Buffer {
+	buf := bytex.NewBuffer()
+	_ = buf.Append(data)
+	_, _ = buf.Seek(0, io.SeekStart)
+	return buf
+}
+
+// prefixBody 先返回已经读取的数据,再读取原始body
+//	后续帧仍通过body.Writer写入原始body
+type prefixBody struct {
+	body.Body
+	prefix bytex.Buffer
+}
+
+func (b *prefixBody) Read(p []byte) (int, error) {
+	if b.prefix == nil {
+		return b.Body.Read(p)
+	}
+
+	n, _ := b.prefix.Read(p)
+	if b.prefix.Available() == 0 {
+		b.prefix = nil
+	}
+	return n, nil
+}
+
+func (b *prefixBody) ReadFast(blocking bool) (bytex.Buffer, error) {
+	if b.prefix == nil {
+		return b.Body.ReadFast(blocking)
+	}
+
+	buf := b.prefix
+	b.prefix = nil
+	return buf, nil
+}
+
+func (b *prefixBody) Buffer() (bytex.Buffer, error) {
+	return nil, body.ErrNotSupport
+}
+
+func (b *prefixBody) End() bool {
+	return b.prefix == nil && b.Body.End()
+}
+
+func (b *prefixBody) Write(data bytex.Buffer) error {
+	if w, ok := b.Body.(body.Writer); ok {
+		return w.Write(data)
+	}
+
+	return nil
+}
+
+func (b *prefixBody) Flush() {
+	if w, ok := b.Body.(body.Writer); ok {
+		w.Flush()
+	}
+}
@@ -0,0 +1,235 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/pkg/bytex"
+	"github.com/foredata/nova/store"
+	"github.com/foredata/nova/store/memory"
+)
+
+func newRequest(key string, body string) netx.Request {
+	req := netx.NewRequest()
+	req.SetMethod(netx.MethodPost)
+	req.SetURI("/charges")
+	h := netx.NewHeader()
+	h.Set(HeaderKey, key)
+	req.SetHeader(h)
+	_ = req.Encode(netx.CodecTypeJson, map[string]string{"amount": body})
+	return req
+}
+
+func TestIdempotency(t *testing.T) {
+	var calls int32
+	endpoint := New(memory.New())(func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		rsp := netx.NewResponse()
+		rsp.SetStatus(http.StatusCreated, "")
+		_ = rsp.Encode(netx.CodecTypeJson, map[string]string{"id": "ch_1"})
+		return rsp, nil
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		rsp, err := endpoint(ctx, newRequest("k1", "100"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.StatusCode() != http.StatusCreated {
+			t.Fatalf("bad status, %d", rsp.StatusCode())
+		}
+		res := map[string]string{}
+		if err := rsp.Decode(&res); err != nil || res["id"] != "ch_1" {
+			t.Fatalf("bad body, %+v %v", res, err)
+		}
+		if i > 0 && rsp.Header().Get(HeaderReplayed) != "true" {
+			t.Fatal("expect replayed header")
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times", calls)
+	}
+
+	_, err := endpoint(ctx, newRequest("k1", "200"))
+	if nerr, ok := err.(netx.Error); !ok || nerr.Code() != http.StatusUnprocessableEntity {
+		t.Fatalf("expect 422, %v", err)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		close(started)
+		<-release
+		return netx.NewResponse(), nil
+	}
+
+	s := memory.New()
+	endpoint := New(s)(handler)
+	waitEndpoint := New(s, WithWait(time.Second))(handler)
+	done := make(chan error, 1)
+	go func() {
+		_, err := endpoint(context.Background(), newRequest("k1", "100"))
+		done <- err
+	}()
+	<-started
+
+	// 不等待时直接返回409
+	_, err := endpoint(context.Background(), newRequest("k1", "100"))
+	if nerr, ok := err.(netx.Error); !ok || nerr.Code() != http.StatusConflict {
+		t.Fatalf("expect 409, %v", err)
+	}
+
+	// 等待首个请求完成后返回保存的应答
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	rsp, err := waitEndpoint(context.Background(), newRequest("k1", "100"))
+	if err != nil || rsp.Header().Get(HeaderReplayed) != "true" {
+		t.Fatalf("expect replayed, %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failStore 占用key成功,保存应答失败
+type failStore struct {
+	store.Store
+}
+
+func (s *failStore) Put(ctx context.Context, key string, value []byte, opts ...store.PutOption) (bool, []byte, error) {
+	o := store.PutOptions{}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if o.Mode != store.PutModeNX {
+		return false, nil, errors.New("store down")
+	}
+	return s.Store.Put(ctx, key, value, opts...)
+}
+
+func TestSave(t *testing.T) {
+	// 请求ctx取消后仍然能保存应答
+	var calls int32
+	endpoint := New(memory.New())(func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return netx.NewResponse(), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := endpoint(ctx, newRequest("k1", "100")); err != nil {
+		t.Fatal(err)
+	}
+	if rsp, err := endpoint(context.Background(), newRequest("k1", "100")); err != nil || rsp.Header().Get(HeaderReplayed) != "true" || calls != 1 {
+		t.Fatalf("expect replayed, %v", err)
+	}
+
+	// 保存失败时应答不受影响,重复请求按执行中处理
+	fs := &failStore{Store: memory.New()}
+	endpoint = New(fs)(func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return netx.NewResponse(), nil
+	})
+	if _, err := endpoint(context.Background(), newRequest("k1", "100")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := endpoint(context.Background(), newRequest("k1", "100"))
+	if nerr, ok := err.(netx.Error); !ok || nerr.Code() != http.StatusConflict {
+		t.Fatalf("expect 409, %v", err)
+	}
+}
+
+func TestRecordHeader(t *testing.T) {
+	header := netx.NewHeader()
+	header.Set("X-Request-Id", "r1")
+	header.Set("X-Shared", "server")
+	rsp := netx.NewResponse()
+	h := netx.NewHeader()
+	h.Set("X-Shared", "response")
+	rsp.SetHeader(h)
+
+	rec, ok := toRecord(header, "fp", rsp, nil)
+	if !ok {
+		t.Fatal("expect record")
+	}
+	out, err := toResponse(context.Background(), rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Header().Get("X-Request-Id") != "r1" || out.Header().Get("X-Shared") != "response" {
+		t.Fatalf("bad header, %+v", out.Header())
+	}
+}
+
+func newStreamRequest(key string, first string) (netx.Request, body.Writer) {
+	req := newRequest(key, "")
+	buf := bytex.NewBuffer()
+	_ = buf.Append(first)
+	_, _ = buf.Seek(0, io.SeekStart)
+	b := body.NewStreamBody(buf)
+	req.SetBody(b)
+	return req, b.(body.Writer)
+}
+
+// writeStream 模拟processor通过创建时保存的body.Writer写入后续帧
+func writeStream(w body.Writer, data string) {
+	if data != "" {
+		buf := bytex.NewBuffer()
+		_ = buf.Append(data)
+		_, _ = buf.Seek(0, io.SeekStart)
+		_ = w.Write(buf)
+	}
+	w.Flush()
+}
+
+// TestStreamBody 流式body读取前MaxBodySize字节计算指纹,handler仍能读取完整body
+func TestStreamBody(t *testing.T) {
+	var got []string
+	endpoint := New(memory.New(), WithMaxBodySize(4))(func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		data, err := ioutil.ReadAll(req.Body())
+		if err != nil {
+			return nil, err
+		}
+		got = append(got, string(data))
+		return netx.NewResponse(), nil
+	})
+
+	// body小于MaxBodySize
+	req, w := newStreamRequest("k1", "ab")
+	writeStream(w, "")
+	if _, err := endpoint(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// body超过MaxBodySize,后续数据在读取指纹后到达
+	req, w = newStreamRequest("k2", "abcdef")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		writeStream(w, "gh")
+	}()
+	if _, err := endpoint(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	req, w = newStreamRequest("k2", "abcdef")
+	writeStream(w, "gh")
+	rsp, err := endpoint(context.Background(), req)
+	if err != nil || rsp.Header().Get(HeaderReplayed) != "true" {
+		t.Fatalf("expect replayed, %v", err)
+	}
+
+	if len(got) != 2 || got[0] != "ab" || got[1] != "abcdefgh" {
+		t.Fatalf("bad body, %q", got)
+	}
+}
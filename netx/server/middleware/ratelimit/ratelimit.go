@@ -0,0 +1,203 @@
+// Package ratelimit 服务端限流中间件,限流算法见pkg/ratelimit
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/server/middleware/auth"
+	"github.com/foredata/nova/pkg/ratelimit"
+)
+
+var gRequests = metrics.NewCounterSet(&metrics.CounterOpts{
+	Namespace: "nova",
+	Subsystem: "ratelimit",
+	Name:      "requests",
+	Help:      "rate limit decisions",
+}, []string{"name", "result"})
+
+// KeyFunc 计算限流key,返回空表示不限流
+type KeyFunc func(ctx context.Context, req netx.Request) string
+
+// ByRoute 按路由限流
+func ByRoute() KeyFunc {
+	return func(ctx context.Context, req netx.Request) string {
+		route := server.GetRoute(ctx)
+		if route == nil {
+			return "noroute"
+		}
+		if route.Path != "" {
+			return route.Method.String() + " " + route.Path
+		}
+		return "cmd:" + strconv.FormatUint(uint64(route.CmdID), 10)
+	}
+}
+
+// ByIP 按客户端IP限流,trustProxy为true时优先使用X-Forwarded-For,X-Real-IP,
+// 只有服务部署在可信代理之后时才应开启,否则可以被伪造
+func ByIP(trustProxy bool) KeyFunc {
+	return func(ctx context.Context, req netx.Request) string {
+		if trustProxy {
+			if ip := clientIPFromHeader(req.Header()); ip != "" {
+				return ip
+			}
+		}
+
+		conn := server.GetConn(ctx)
+		if conn == nil {
+			return ""
+		}
+		addr := conn.RemoteAddr()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			return host
+		}
+		return addr
+	}
+}
+
+// ByPrincipal 按认证后的主体限流,需要在auth中间件之后执行,未认证时不限流
+func ByPrincipal() KeyFunc {
+	return func(ctx context.Context, req netx.Request) string {
+		if p, ok := auth.FromContext(ctx); ok {
+			return p.ID
+		}
+		return ""
+	}
+}
+
+// ByHeader 按header限流,例如租户ID
+func ByHeader(name string) KeyFunc {
+	return func(ctx context.Context, req netx.Request) string {
+		return netx.GetHeader(req.Header(), name)
+	}
+}
+
+// Compose 组合多个KeyFunc,任意一个为空则不限流
+func Compose(fns ...KeyFunc) KeyFunc {
+	return func(ctx context.Context, req netx.Request) string {
+		parts := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			k := fn(ctx, req)
+			if k == "" {
+				return ""
+			}
+			parts = append(parts, k)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// Options 中间件配置
+type Options struct {
+	Name      string                                          // 名字,用于区分metrics
+	Key       KeyFunc                                         // 限流key,默认按路由
+	Cost      func(ctx context.Context, req netx.Request) int // 单次请求消耗,默认1
+	FailClose bool                                            // Limiter出错时是否拒绝请求,默认放行
+}
+
+type Option func(o *Options)
+
+// WithName 设置名字
+func WithName(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+// WithKey 设置限流key
+func WithKey(fn KeyFunc) Option {
+	return func(o *Options) {
+		o.Key = fn
+	}
+}
+
+// WithCost 设置单次请求消耗
+func WithCost(fn func(ctx context.Context, req netx.Request) int) Option {
+	return func(o *Options) {
+		o.Cost = fn
+	}
+}
+
+// WithFailClose Limiter出错时拒绝请求
+func WithFailClose() Option {
+	return func(o *Options) {
+		o.FailClose = true
+	}
+}
+
+// New 创建限流中间件,超出限额返回429,并设置Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining
+func New(limiter ratelimit.Limiter, opts ...Option) netx.Middleware {
+	o := &Options{Name: "default", Key: ByRoute()}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	allowed := gRequests.Values(o.Name, "allowed")
+	rejected := gRequests.Values(o.Name, "rejected")
+	failed := gRequests.Values(o.Name, "error")
+
+	return func(next netx.Endpoint) netx.Endpoint {
+		return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+			key := o.Key(ctx, req)
+			if key == "" {
+				return next(ctx, req)
+			}
+
+			n := 1
+			if o.Cost != nil {
+				n = o.Cost(ctx, req)
+			}
+
+			res, err := limiter.Allow(ctx, key, n)
+			if err != nil {
+				failed.Inc()
+				if o.FailClose || err == ratelimit.ErrExceedsBurst {
+					return nil, netx.TooManyRequests("rate limit: %s", err.Error())
+				}
+				return next(ctx, req)
+			}
+
+			h := server.GetResponseHeader(ctx)
+			h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				server.SetResponseHeader(ctx, h)
+				rejected.Inc()
+				return nil, netx.TooManyRequests("rate limit exceeded")
+			}
+			server.SetResponseHeader(ctx, h)
+			allowed.Inc()
+
+			if res.Delay > 0 {
+				timer := time.NewTimer(res.Delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+
+			return next(ctx, req)
+		}
+	}
+}
+
+func clientIPFromHeader(h netx.Header) string {
+	if v := netx.GetHeader(h, "X-Forwarded-For"); v != "" {
+		if idx := strings.IndexByte(v, ','); idx != -1 {
+			v = v[:idx]
+		}
+		return strings.TrimSpace(v)
+	}
+
+	return strings.TrimSpace(netx.GetHeader(h, "X-Real-IP"))
+}
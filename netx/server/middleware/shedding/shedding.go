@@ -0,0 +1,143 @@
+// Package shedding 基于自适应并发限制的过载保护中间件
+//	并发超过当前限制时按优先级丢弃请求,优先级来自Route.Metadata,
+//	header(X-Priority)可由客户端伪造,默认仅对已认证请求生效,见WithTrustHeader
+//	已经超过deadline的请求直接丢弃,不再执行handler
+package shedding
+
+import (
+	"context"
+	"errors"
+
+	"github.com/foredata/nova/debug/metrics"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/server/middleware/auth"
+	"github.com/foredata/nova/pkg/concurrency"
+)
+
+// MetaPriority Route.Metadata中声明路由优先级的key,可信请求header中的优先级会覆盖该值
+const MetaPriority = "priority"
+
+var (
+	gLimit = metrics.NewGauge(&metrics.GaugeOpts{
+		Namespace: "nova",
+		Subsystem: "shedding",
+		Name:      "limit",
+		Help:      "current concurrency limit",
+	})
+	gInflight = metrics.NewGauge(&metrics.GaugeOpts{
+		Namespace: "nova",
+		Subsystem: "shedding",
+		Name:      "inflight",
+		Help:      "current inflight requests",
+	})
+	gRejected = metrics.NewCounterSet(&metrics.CounterOpts{
+		Namespace: "nova",
+		Subsystem: "shedding",
+		Name:      "rejected",
+		Help:      "rejected requests by priority",
+	}, []string{"priority"})
+)
+
+var priorityNames = [...]string{"low", "normal", "high", "critical"}
+
+// Options 可选配置参数
+type Options struct {
+	TrustHeader func(ctx context.Context, req netx.Request) bool // 是否信任header中的优先级,默认仅信任已认证请求
+}
+
+type Option func(o *Options)
+
+// WithTrustHeader 设置是否信任header中的优先级,fn为nil时总是信任,通常用于仅内网访问的服务
+func WithTrustHeader(fn func(ctx context.Context, req netx.Request) bool) Option {
+	return func(o *Options) {
+		if fn == nil {
+			fn = func(ctx context.Context, req netx.Request) bool { return true }
+		}
+		o.TrustHeader = fn
+	}
+}
+
+// New 创建过载保护中间件,limiter为nil时使用默认配置
+//	handler panic时视为丢弃,同超时一样减小并发限制
+func New(limiter *concurrency.Limiter, opts ...Option) netx.Middleware {
+	if limiter == nil {
+		limiter = concurrency.New()
+	}
+	o := &Options{TrustHeader: isAuthenticated}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return func(next netx.Endpoint) netx.Endpoint {
+		return func(ctx context.Context, req netx.Request) (rsp netx.Response, err error) {
+			if ctx.Err() != nil {
+				return nil, netx.ServiceUnavailable("deadline exceeded")
+			}
+
+			p := getPriority(ctx, req, o)
+			token := limiter.Acquire(p)
+			gLimit.Set(int64(limiter.Limit()))
+			if token == nil {
+				gRejected.Values(priorityNames[p]).Inc()
+				return nil, netx.ServiceUnavailable("server overloaded")
+			}
+			gInflight.Inc()
+
+			done := false
+			defer func() {
+				gInflight.Dec()
+				switch {
+				case !done:
+					// panic
+					token.Dropped()
+				case err == nil:
+					token.Success()
+				case isDropped(ctx, err):
+					token.Dropped()
+				default:
+					token.Ignore()
+				}
+			}()
+
+			rsp, err = next(ctx, req)
+			done = true
+			return rsp, err
+		}
+	}
+}
+
+func getPriority(ctx context.Context, req netx.Request, o *Options) concurrency.Priority {
+	if v := req.Header().Get(netx.XPriority); v != "" && o.TrustHeader(ctx, req) {
+		return concurrency.ParsePriority(v)
+	}
+
+	if route := server.GetRoute(ctx); route != nil {
+		return concurrency.ParsePriority(route.Metadata[MetaPriority])
+	}
+
+	return concurrency.PriorityNormal
+}
+
+// isAuthenticated 已经通过auth中间件认证
+func isAuthenticated(ctx context.Context, req netx.Request) bool {
+	_, ok := auth.FromContext(ctx)
+	return ok
+}
+
+// isDropped 超时或服务端过载类错误视为丢弃,其他业务错误不影响并发限制
+func isDropped(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var nerr netx.Error
+	if errors.As(err, &nerr) {
+		switch nerr.Code() {
+		case 503, 504, 408:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,98 @@
+package shedding
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server/middleware/auth"
+	"github.com/foredata/nova/pkg/concurrency"
+)
+
+// fixed 固定并发限制,记录最近一次Update
+type fixed struct {
+	limit   int
+	updates int
+	dropped bool
+}
+
+func (f *fixed) Limit() int { return f.limit }
+func (f *fixed) Update(rtt time.Duration, inflight int, dropped bool) {
+	f.updates++
+	f.dropped = dropped
+}
+
+func okHandler(ctx context.Context, req netx.Request) (netx.Response, error) {
+	return netx.NewResponse(), nil
+}
+
+func TestPriority(t *testing.T) {
+	// limit=2,Normal可用1.6,Critical可用2,占用1个后仅Critical可以通过
+	limiter := concurrency.New(concurrency.WithAlgorithm(&fixed{limit: 2}))
+	hold := limiter.Acquire(concurrency.PriorityCritical)
+	defer hold.Ignore()
+
+	req := netx.NewRequest()
+	h := netx.Header{}
+	h.Set(netx.XPriority, "critical")
+	req.SetHeader(h)
+
+	call := func(ctx context.Context, opts ...Option) error {
+		_, err := New(limiter, opts...)(okHandler)(ctx, req)
+		return err
+	}
+
+	if err := call(context.Background()); err == nil {
+		t.Fatal("untrusted header priority should be ignored")
+	}
+	if err := call(auth.NewContext(context.Background(), &auth.Principal{ID: "u1"})); err != nil {
+		t.Fatalf("authenticated header priority should be used, %v", err)
+	}
+	if err := call(context.Background(), WithTrustHeader(nil)); err != nil {
+		t.Fatalf("trusted header priority should be used, %v", err)
+	}
+}
+
+func TestDropped(t *testing.T) {
+	alg := &fixed{limit: 10}
+	limiter := concurrency.New(concurrency.WithAlgorithm(alg))
+	mw := New(limiter)
+
+	// panic视为丢弃
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expect panic")
+			}
+		}()
+		_, _ = mw(func(ctx context.Context, req netx.Request) (netx.Response, error) {
+			panic("boom")
+		})(context.Background(), netx.NewRequest())
+	}()
+	if alg.updates != 1 || !alg.dropped || limiter.Inflight() != 0 {
+		t.Fatalf("panic should be dropped, %+v inflight=%d", alg, limiter.Inflight())
+	}
+
+	// 503视为丢弃,业务错误不更新
+	_, _ = mw(func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return nil, netx.NewError(http.StatusServiceUnavailable, "", "busy")
+	})(context.Background(), netx.NewRequest())
+	if alg.updates != 2 || !alg.dropped {
+		t.Fatalf("503 should be dropped, %+v", alg)
+	}
+	_, _ = mw(func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		return nil, netx.NewError(http.StatusBadRequest, "", "bad")
+	})(context.Background(), netx.NewRequest())
+	if alg.updates != 2 || limiter.Inflight() != 0 {
+		t.Fatalf("business error should be ignored, %+v", alg)
+	}
+
+	// 已超时的请求直接拒绝
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mw(okHandler)(ctx, netx.NewRequest()); err == nil {
+		t.Fatal("expect deadline exceeded")
+	}
+}
@@ -0,0 +1,99 @@
+// Package tracing 服务端链路追踪中间件
+//	从请求header中提取上游SpanContext,创建server span并放入context,
+//	后续通过tracing.StartSpanFromContext创建的span会自动成为其子span
+package tracing
+
+import (
+	"context"
+
+	"github.com/foredata/nova/debug/tracing"
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server"
+)
+
+// NameFunc 计算span名字
+type NameFunc func(ctx context.Context, req netx.Request) string
+
+// Options 中间件配置
+type Options struct {
+	Tracer tracing.Tracer // 默认使用tracing.Default()
+	Name   NameFunc       // 默认使用路由名,不存在时使用URI
+}
+
+type Option func(o *Options)
+
+// WithTracer 设置Tracer
+func WithTracer(t tracing.Tracer) Option {
+	return func(o *Options) {
+		o.Tracer = t
+	}
+}
+
+// WithName 设置span名字计算方式
+func WithName(fn NameFunc) Option {
+	return func(o *Options) {
+		o.Name = fn
+	}
+}
+
+// New 创建链路追踪中间件
+func New(opts ...Option) netx.Middleware {
+	o := &Options{Name: spanName}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return func(next netx.Endpoint) netx.Endpoint {
+		return func(ctx context.Context, req netx.Request) (netx.Response, error) {
+			tracer := o.Tracer
+			if tracer == nil {
+				tracer = tracing.Default()
+			}
+
+			spanOpts := []tracing.StartSpanOption{
+				tracing.WithTag(tracing.SpanType, tracing.SpanTypeWeb),
+				tracing.WithTag(tracing.ResourceName, req.URI()),
+			}
+			if req.Method() != netx.MethodUnknown {
+				spanOpts = append(spanOpts, tracing.WithTag(tracing.HTTPMethod, req.Method().String()))
+			}
+			if sc, err := tracer.Extract(req.Header()); err == nil && sc != nil {
+				spanOpts = append(spanOpts, tracing.WithParentContext(sc))
+			}
+
+			span := tracer.StartSpan(o.Name(ctx, req), spanOpts...)
+			ctx = tracing.ContextWithSpan(ctx, span)
+			rsp, err := next(ctx, req)
+			if err != nil {
+				span.SetTag(tracing.Error, true)
+				span.SetTag(tracing.ErrorMsg, err.Error())
+				if nerr, ok := err.(netx.Error); ok {
+					span.SetTag(tracing.HTTPCode, nerr.Code())
+				}
+			} else if rsp != nil && rsp.StatusCode() != 0 {
+				span.SetTag(tracing.HTTPCode, rsp.StatusCode())
+			}
+			span.Finish()
+
+			return rsp, err
+		}
+	}
+}
+
+// Inject 将ctx中的span注入到header中,用于向下游透传
+func Inject(ctx context.Context, h *netx.Header) {
+	span := tracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	_ = tracing.Inject(span.Context(), h)
+}
+
+func spanName(ctx context.Context, req netx.Request) string {
+	if route := server.GetRoute(ctx); route != nil && route.Name != "" {
+		return route.Name
+	}
+
+	return req.URI()
+}
@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/admission"
 	"github.com/foredata/nova/netx/executor"
+	"github.com/foredata/nova/netx/health"
 	"github.com/foredata/nova/netx/processor"
 	"github.com/foredata/nova/netx/registry"
 	"github.com/foredata/nova/netx/transport"
@@ -13,28 +15,36 @@ import (
 )
 
 const (
-	defaultRegistryTTL = time.Second * 15
+	defaultRegistryTTL       = time.Second * 15
+	defaultCompressThreshold = 1024
 )
 
 // Options 可选配置参数
 type Options struct {
-	ID          string            // 唯一ID,如果不指定,则随机生成
-	Name        string            // 服务名
-	Version     string            // 服务版本
-	Metadata    map[string]string // Meta
-	Addr        string            // 监听地址
-	Tran        netx.Tran         // Transport
-	Detector    netx.Detector     // 协议探测,默认自动探测
-	Router      netx.Router       // 路由
-	Exec        netx.Executor     // 调度器,默认每条消息一个go routine并发执行
-	Node        *registry.Node    // node配置信息
-	Registry    registry.Registry // 服务注册
-	RegistryTTL time.Duration     // 注册过期时间
-	Signals     []os.Signal       // 需要监听的事件,默认syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT
-	Modules     []netx.Module     // 扩展模块
-	Binder      Binder            //
-	Validator   Validator         //
-	Codec       netx.CodecType    // 默认编解码协议
+	ID          string             // 唯一ID,如果不指定,则随机生成
+	Name        string             // 服务名
+	Version     string             // 服务版本
+	Metadata    map[string]string  // Meta
+	Addr        string             // 监听地址
+	Tran        netx.Tran          // Transport
+	TranFactory netx.Factory       // 未指定Tran时用于创建Tran,默认transport.New
+	Detector    netx.Detector      // 协议探测,默认自动探测
+	Router      netx.Router        // 路由
+	Exec        netx.Executor      // 调度器,默认每条消息一个go routine并发执行
+	Node        *registry.Node     // node配置信息
+	Registry    registry.Registry  // 服务注册
+	RegistryTTL time.Duration      // 注册过期时间
+	Signals     []os.Signal        // 需要监听的事件,默认syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT
+	Modules     []netx.Module      // 扩展模块
+	Binder      Binder             //
+	Validator   Validator          //
+	Codec       netx.CodecType     // 默认编解码协议
+	QueueTime   time.Duration      // 请求最长排队时间,超过则直接丢弃,0表示仅根据请求中携带的超时时间判断
+	TaskKey     processor.KeyFunc  // 请求任务的key,用于ordered等按key有序执行的Exec,默认使用连接ID
+	Threshold   int                // 应答body不小于该值时才压缩,默认1024,负数表示不压缩
+	Admission   []admission.Option // 连接准入限制,为空时不限制,仅在未指定Tran时生效
+	Health      health.Checker     // 健康检查,readiness失败时自动注销服务,恢复后重新注册
+	AdminAddr   string             // 管理端口,用于/healthz,/readyz,为空时使用业务端口
 }
 
 type Option func(o *Options)
@@ -64,8 +74,17 @@ func newOptions(opts ...Option) *Options {
 			o.Exec = executor.Default()
 		}
 
-		filter := processor.NewFilter(o.Exec, o.Router, o.Detector)
-		tran := transport.New()
+		filter := processor.NewFilter(o.Exec, o.Router, o.Detector, processor.WithMaxQueueTime(o.QueueTime), processor.WithKeyFunc(o.TaskKey))
+		factory := o.TranFactory
+		if factory == nil {
+			factory = transport.New
+		}
+		tran := factory()
+		if len(o.Admission) > 0 {
+			// 需要在协议探测前拒绝连接
+			aopts := append([]admission.Option{admission.WithDetector(o.Detector)}, o.Admission...)
+			tran.AddFilters(admission.NewFilter(aopts...))
+		}
 		tran.AddFilters(filter)
 		o.Tran = tran
 	}
@@ -78,6 +97,10 @@ func newOptions(opts ...Option) *Options {
 		o.RegistryTTL = defaultRegistryTTL
 	}
 
+	if o.Threshold == 0 {
+		o.Threshold = defaultCompressThreshold
+	}
+
 	return o
 }
 
@@ -117,6 +140,13 @@ func WithTran(t netx.Tran) Option {
 	}
 }
 
+// WithTranFactory 指定Tran创建方式,与WithTran不同,会自动添加消息处理filter
+func WithTranFactory(fn netx.Factory) Option {
+	return func(o *Options) {
+		o.TranFactory = fn
+	}
+}
+
 func WithDetector(d netx.Detector) Option {
 	return func(o *Options) {
 		o.Detector = d
@@ -176,3 +206,40 @@ func WithCodec(v netx.CodecType) Option {
 		o.Codec = v
 	}
 }
+
+// WithQueueTime 设置请求最长排队时间
+func WithQueueTime(d time.Duration) Option {
+	return func(o *Options) {
+		o.QueueTime = d
+	}
+}
+
+// WithTaskKey 设置请求任务的key,配合ordered.New()使用时,相同key的请求按顺序执行
+func WithTaskKey(fn processor.KeyFunc) Option {
+	return func(o *Options) {
+		o.TaskKey = fn
+	}
+}
+
+// WithCompressThreshold 设置应答压缩阈值,负数表示不压缩
+//	应答压缩方式与请求一致,请求未压缩时根据X-Accept-Compress协商
+func WithCompressThreshold(v int) Option {
+	return func(o *Options) {
+		o.Threshold = v
+	}
+}
+
+// WithAdmission 设置连接准入限制,包括最大连接数,单IP连接数,建连速率等
+func WithAdmission(opts ...admission.Option) Option {
+	return func(o *Options) {
+		o.Admission = append(o.Admission, opts...)
+	}
+}
+
+// WithHealth 设置健康检查,adminAddr不为空时在独立端口提供/healthz,/readyz,grpc Check同时在业务端口提供
+func WithHealth(h health.Checker, adminAddr string) Option {
+	return func(o *Options) {
+		o.Health = h
+		o.AdminAddr = adminAddr
+	}
+}
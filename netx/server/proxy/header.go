@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/server"
+)
+
+// hop-by-hop header,只对单条连接有效,不能转发
+// https://datatracker.ietf.org/doc/html/rfc7230#section-6.1
+var gHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+const (
+	headerHost           = "Host"
+	headerForwardedFor   = "X-Forwarded-For"
+	headerForwardedHost  = "X-Forwarded-Host"
+	headerForwardedProto = "X-Forwarded-Proto"
+)
+
+// hopHeaders 返回需要移除的header,包括Connection中声明的header
+func hopHeaders(connection []string) map[string]bool {
+	hops := make(map[string]bool, len(gHopHeaders)+len(connection))
+	for _, k := range gHopHeaders {
+		hops[k] = true
+	}
+	for _, v := range connection {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				hops[http.CanonicalHeaderKey(k)] = true
+			}
+		}
+	}
+
+	return hops
+}
+
+// requestHeader 生成上游请求header
+//	1: 移除hop-by-hop header
+//	2: 追加X-Forwarded-For,透传或设置X-Forwarded-Host,X-Forwarded-Proto
+func requestHeader(ctx context.Context, req netx.Request) http.Header {
+	src := req.Header()
+	hops := hopHeaders(values(src, "Connection"))
+	dst := make(http.Header, len(src)+3)
+	for _, kv := range src {
+		key := http.CanonicalHeaderKey(kv.Key)
+		if hops[key] || key == headerHost {
+			continue
+		}
+		dst[key] = append(dst[key], kv.Values...)
+	}
+
+	if conn := server.GetConn(ctx); conn != nil {
+		ip := conn.RemoteAddr()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		if prior := dst.Get(headerForwardedFor); prior != "" {
+			ip = prior + ", " + ip
+		}
+		dst.Set(headerForwardedFor, ip)
+	}
+
+	if dst.Get(headerForwardedHost) == "" {
+		if host := get(src, headerHost); host != "" {
+			dst.Set(headerForwardedHost, host)
+		}
+	}
+
+	if dst.Get(headerForwardedProto) == "" {
+		dst.Set(headerForwardedProto, "http")
+	}
+
+	return dst
+}
+
+// responseHeader 移除上游应答中的hop-by-hop header
+func responseHeader(src http.Header) netx.Header {
+	hops := hopHeaders(src.Values("Connection"))
+	dst := netx.NewHeader()
+	for key, vv := range src {
+		if !hops[http.CanonicalHeaderKey(key)] {
+			dst.SetValues(key, vv)
+		}
+	}
+
+	return dst
+}
+
+// get 忽略大小写查询header
+func get(h netx.Header, key string) string {
+	if vv := values(h, key); len(vv) > 0 {
+		return vv[0]
+	}
+
+	return ""
+}
+
+func values(h netx.Header, key string) []string {
+	for _, kv := range h {
+		if strings.EqualFold(kv.Key, key) {
+			return kv.Values
+		}
+	}
+
+	return nil
+}
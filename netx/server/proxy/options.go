@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/foredata/nova/netx/loadbalance"
+	"github.com/foredata/nova/netx/loadbalance/random"
+)
+
+// RewriteFunc 改写转发到上游的path
+type RewriteFunc func(path string) string
+
+// Options 反向代理配置
+type Options struct {
+	Balancer         loadbalance.Balancer // 负载均衡,默认random
+	Transport        http.RoundTripper    // 上游请求,默认使用内置http.Transport
+	Scheme           string               // 上游协议,默认http
+	StripPrefix      string               // 转发前去掉的path前缀
+	AddPrefix        string               // 转发前添加的path前缀
+	Rewrite          RewriteFunc          // 自定义path改写,在StripPrefix和AddPrefix之后执行
+	Host             string               // 指定上游Host,为空时使用上游地址
+	PreserveHost     bool                 // 透传客户端Host,优先级低于Host
+	Retries          int                  // 幂等请求失败后重试次数,默认2
+	DialTimeout      time.Duration        // websocket连接上游超时,默认5s
+	HandshakeTimeout time.Duration        // websocket握手超时,包含tls握手,默认10s
+	TLSConfig        *tls.Config          // https上游tls配置,用于默认Transport及websocket
+}
+
+type Option func(o *Options)
+
+func newOptions(opts ...Option) *Options {
+	o := &Options{
+		Scheme:           "http",
+		Retries:          2,
+		DialTimeout:      5 * time.Second,
+		HandshakeTimeout: 10 * time.Second,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Balancer == nil {
+		o.Balancer = random.New()
+	}
+
+	if o.Transport == nil {
+		o.Transport = newTransport(o.DialTimeout, o.TLSConfig)
+	}
+
+	return o
+}
+
+func newTransport(dialTimeout time.Duration, tlsConfig *tls.Config) http.RoundTripper {
+	return &http.Transport{
+		TLSClientConfig: tlsConfig,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          1024,
+		MaxIdleConnsPerHost:   64,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableCompression:    true,
+	}
+}
+
+// WithBalancer 设置负载均衡
+func WithBalancer(b loadbalance.Balancer) Option {
+	return func(o *Options) {
+		o.Balancer = b
+	}
+}
+
+// WithTransport 设置上游请求Transport
+func WithTransport(t http.RoundTripper) Option {
+	return func(o *Options) {
+		o.Transport = t
+	}
+}
+
+// WithScheme 设置上游协议,http或https
+func WithScheme(scheme string) Option {
+	return func(o *Options) {
+		o.Scheme = scheme
+	}
+}
+
+// WithStripPrefix 转发前去掉path前缀
+func WithStripPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.StripPrefix = prefix
+	}
+}
+
+// WithAddPrefix 转发前添加path前缀
+func WithAddPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.AddPrefix = prefix
+	}
+}
+
+// WithRewrite 自定义path改写
+func WithRewrite(fn RewriteFunc) Option {
+	return func(o *Options) {
+		o.Rewrite = fn
+	}
+}
+
+// WithHost 指定上游Host
+func WithHost(host string) Option {
+	return func(o *Options) {
+		o.Host = host
+	}
+}
+
+// WithPreserveHost 透传客户端Host
+func WithPreserveHost(v bool) Option {
+	return func(o *Options) {
+		o.PreserveHost = v
+	}
+}
+
+// WithRetries 设置幂等请求重试次数
+func WithRetries(n int) Option {
+	return func(o *Options) {
+		o.Retries = n
+	}
+}
+
+// WithDialTimeout 设置websocket连接上游超时
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.DialTimeout = d
+	}
+}
+
+// WithHandshakeTimeout 设置websocket握手超时
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.HandshakeTimeout = d
+	}
+}
+
+// WithTLSConfig 设置https上游tls配置
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *Options) {
+		o.TLSConfig = cfg
+	}
+}
@@ -0,0 +1,297 @@
+// Package proxy http1反向代理
+//	通过discovery.Resolver和loadbalance.Balancer选择上游,请求和应答body均以流的方式转发,不做缓存
+//	幂等且body可重放的请求在连接失败或上游返回502,503,504时会换节点重试
+//	支持websocket透传,握手成功后接管客户端连接,双向转发原始数据
+//	使用方式: svr.Any("/internal/*path", proxy.New(resolver, "internal-tools", proxy.WithStripPrefix("/internal")))
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/discovery"
+	"github.com/foredata/nova/netx/loadbalance"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+var (
+	ErrNoInstances = errors.New("proxy: no instances")
+)
+
+const chunkSize = 32 * 1024
+
+// New 创建反向代理handler,service为上游服务名,通过resolver解析
+func New(resolver discovery.Resolver, service string, opts ...Option) netx.Endpoint {
+	p := &proxy{
+		opts:     newOptions(opts...),
+		resolver: resolver,
+		service:  service,
+	}
+
+	return p.serve
+}
+
+type proxy struct {
+	opts     *Options
+	resolver discovery.Resolver
+	service  string
+}
+
+func (p *proxy) serve(ctx context.Context, req netx.Request) (netx.Response, error) {
+	picker, err := p.pick(ctx)
+	if err != nil {
+		return nil, netx.BadGateway("proxy: %+v", err)
+	}
+	defer picker.Recycle()
+
+	if isWebSocket(req.Header()) {
+		return p.serveWebSocket(ctx, req, picker)
+	}
+
+	newBody, size, replayable := requestBody(req)
+	attempts := 1
+	if replayable && isIdempotent(req.Method()) {
+		attempts += p.opts.Retries
+	}
+
+	header := requestHeader(ctx, req)
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		ins, err := picker.Next()
+		if err != nil {
+			lastErr = err
+			break
+		}
+
+		// 应答body在handler返回后继续转发,不能使用请求ctx,否则handler返回即被取消
+		uctx, cancel := detach(ctx)
+		hreq, err := p.newRequest(uctx, req, ins.Addr(), header)
+		if err != nil {
+			cancel()
+			return nil, netx.BadGateway("proxy: %+v", err)
+		}
+		hreq.Body = newBody()
+		hreq.ContentLength = size
+
+		hrsp, err := p.opts.Transport.RoundTrip(hreq)
+		if err != nil {
+			cancel()
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		if i < attempts-1 && isRetryStatus(hrsp.StatusCode) {
+			_ = hrsp.Body.Close()
+			cancel()
+			lastErr = errors.New(hrsp.Status)
+			continue
+		}
+
+		return newResponse(req, hrsp, cancel), nil
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, netx.GatewayTimeout("proxy: %+v", lastErr)
+	}
+
+	return nil, netx.BadGateway("proxy: %+v", lastErr)
+}
+
+func (p *proxy) pick(ctx context.Context) (loadbalance.Picker, error) {
+	result, err := p.resolver.Resolve(ctx, p.service)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Empty() {
+		return nil, ErrNoInstances
+	}
+
+	return p.opts.Balancer.Pick(result)
+}
+
+// newRequest 创建上游请求,改写path和host
+func (p *proxy) newRequest(ctx context.Context, req netx.Request, addr string, header http.Header) (*http.Request, error) {
+	target := &url.URL{Scheme: p.opts.Scheme, Host: addr}
+	if u := req.URL(); u != nil {
+		target.Path = p.rewrite(u.Path)
+		target.RawQuery = u.RawQuery
+	} else {
+		target.Path = p.rewrite(req.URI())
+	}
+
+	method := http.MethodGet
+	if m := req.Method(); m.IsValid() {
+		method = m.String()
+	}
+
+	hreq, err := http.NewRequest(method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	hreq = hreq.WithContext(ctx)
+
+	hreq.Header = header.Clone()
+	switch {
+	case p.opts.Host != "":
+		hreq.Host = p.opts.Host
+	case p.opts.PreserveHost:
+		hreq.Host = get(req.Header(), headerHost)
+	}
+
+	return hreq, nil
+}
+
+// rewrite 依次执行StripPrefix,AddPrefix,Rewrite
+func (p *proxy) rewrite(path string) string {
+	if p.opts.StripPrefix != "" {
+		path = strings.TrimPrefix(path, p.opts.StripPrefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+
+	if p.opts.AddPrefix != "" {
+		path = strings.TrimSuffix(p.opts.AddPrefix, "/") + path
+	}
+
+	if p.opts.Rewrite != nil {
+		path = p.opts.Rewrite(path)
+	}
+
+	return path
+}
+
+// requestBody 返回上游请求body构造函数
+//	buffer类型body已经完整接收,可以重放,stream类型边收边发,只能读取一次
+func requestBody(req netx.Request) (func() io.ReadCloser, int64, bool) {
+	bd := req.Body()
+	if bd == nil {
+		return func() io.ReadCloser { return nil }, 0, true
+	}
+
+	buf, err := bd.Buffer()
+	if err == nil {
+		var data []byte
+		if buf != nil {
+			data = buf.Bytes()
+		}
+		return func() io.ReadCloser {
+			if len(data) == 0 {
+				return nil
+			}
+			return ioutil.NopCloser(bytes.NewReader(data))
+		}, int64(len(data)), true
+	}
+
+	size := int64(-1)
+	if v := get(req.Header(), "Content-Length"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			size = n
+		}
+	}
+
+	return func() io.ReadCloser { return bd }, size, false
+}
+
+// newResponse 将上游应答转换为netx.Response,body由单独协程持续写入
+func newResponse(req netx.Request, hrsp *http.Response, cancel context.CancelFunc) netx.Response {
+	rsp := netx.NewResponse()
+	rsp.SetStatus(int32(hrsp.StatusCode), "")
+	header := responseHeader(hrsp.Header)
+	if len(hrsp.Trailer) > 0 {
+		keys := make([]string, 0, len(hrsp.Trailer))
+		for k := range hrsp.Trailer {
+			keys = append(keys, k)
+		}
+		header.Set("Trailer", strings.Join(keys, ", "))
+	}
+	rsp.SetHeader(header)
+
+	if req.Method() == netx.MethodHead || !bodyAllowed(hrsp.StatusCode) {
+		_ = hrsp.Body.Close()
+		cancel()
+		return rsp
+	}
+
+	bd := body.NewStreamBody(nil)
+	rsp.SetBody(bd)
+	go copyResponse(rsp, bd, hrsp, cancel)
+	return rsp
+}
+
+// copyResponse 将上游应答body逐块写入stream body,出错时关闭body中断转发
+func copyResponse(rsp netx.Response, bd netx.Body, hrsp *http.Response, cancel context.CancelFunc) {
+	defer cancel()
+	defer hrsp.Body.Close()
+
+	w := bd.(body.Writer)
+	for {
+		p := make([]byte, chunkSize)
+		n, err := hrsp.Body.Read(p)
+		if n > 0 {
+			buf := bytex.NewBuffer()
+			_ = buf.Append(p[:n])
+			_, _ = buf.Seek(0, io.SeekStart)
+			if w.Write(buf) != nil {
+				// 客户端已经断开
+				return
+			}
+		}
+
+		if err == io.EOF {
+			if len(hrsp.Trailer) > 0 {
+				trailer := netx.NewHeader()
+				for k, vv := range hrsp.Trailer {
+					trailer.SetValues(k, vv)
+				}
+				rsp.SetTrailer(trailer)
+			}
+			w.Flush()
+			return
+		}
+
+		if err != nil {
+			_ = bd.Close()
+			return
+		}
+	}
+}
+
+// detach 创建不随父ctx取消的context,但保留父ctx的deadline
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(context.Background(), deadline)
+	}
+
+	return context.WithCancel(context.Background())
+}
+
+func isIdempotent(m netx.Method) bool {
+	switch m {
+	case netx.MethodGet, netx.MethodHead, netx.MethodOptions, netx.MethodPut, netx.MethodDelete, netx.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func isRetryStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+func bodyAllowed(code int) bool {
+	return code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/discovery"
+	"github.com/foredata/nova/netx/loadbalance/round_robin"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+type testResolver struct {
+	addrs []string
+}
+
+func (r *testResolver) Name() string {
+	return "test"
+}
+
+func (r *testResolver) Resolve(ctx context.Context, service string) (*discovery.Result, error) {
+	instances := make([]discovery.Instance, 0, len(r.addrs))
+	for _, addr := range r.addrs {
+		instances = append(instances, discovery.NewInstance("", addr, 0, nil))
+	}
+	return discovery.NewResult(instances), nil
+}
+
+func (r *testResolver) Close() error {
+	return nil
+}
+
+func newTestRequest(method netx.Method, uri string, data string) netx.Request {
+	req := netx.NewRequest()
+	req.SetMethod(method)
+	req.SetURI(uri)
+	h := netx.NewHeader()
+	h.Set("Host", "edge.example.com")
+	h.Set("Connection", "keep-alive, X-Hop")
+	h.Set("X-Hop", "1")
+	h.Set("X-Request-Id", "rid")
+	req.SetHeader(h)
+	if data != "" {
+		buf := bytex.NewBuffer()
+		_ = buf.Append(data)
+		req.SetBody(body.NewBufferBody(buf))
+	}
+	return req
+}
+
+func TestProxyForward(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Hop") != "" || r.Header.Get("X-Request-Id") != "rid" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Host", r.Host)
+		w.Header().Set("X-Forwarded-Host", r.Header.Get("X-Forwarded-Host"))
+		w.Header().Set("Keep-Alive", "timeout=5")
+		w.Write([]byte(r.URL.RequestURI() + " " + string(data)))
+	}))
+	defer upstream.Close()
+
+	addr := strings.TrimPrefix(upstream.URL, "http://")
+	ep := New(&testResolver{addrs: []string{addr}}, "tools", WithStripPrefix("/internal"), WithAddPrefix("/v1"), WithHost("tools.local"))
+	rsp, err := ep(context.Background(), newTestRequest(netx.MethodPost, "/internal/users?id=1", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp.StatusCode() != http.StatusOK {
+		t.Fatalf("invalid status, %d", rsp.StatusCode())
+	}
+
+	data, err := ioutil.ReadAll(rsp.Body())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "/v1/users?id=1 hello" {
+		t.Fatalf("invalid body, %s", data)
+	}
+
+	h := rsp.Header()
+	if h.Get("X-Host") != "tools.local" || h.Get("X-Forwarded-Host") != "edge.example.com" || h.Get("Keep-Alive") != "" {
+		t.Fatalf("invalid header, %+v", h)
+	}
+}
+
+func TestProxyRetry(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer good.Close()
+
+	resolver := &testResolver{addrs: []string{
+		strings.TrimPrefix(bad.URL, "http://"),
+		strings.TrimPrefix(good.URL, "http://"),
+	}}
+
+	ep := New(resolver, "tools", WithBalancer(roundrobin.New()), WithRetries(1))
+	rsp, err := ep(context.Background(), newTestRequest(netx.MethodGet, "/ping", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode() != http.StatusOK {
+		t.Fatalf("expect retry success, %d", rsp.StatusCode())
+	}
+
+	// 非幂等请求不重试
+	for i := 0; i < 20; i++ {
+		rsp, err = ep(context.Background(), newTestRequest(netx.MethodPost, "/ping", "x"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.StatusCode() == http.StatusServiceUnavailable {
+			return
+		}
+	}
+	t.Fatal("expect post not retried")
+}
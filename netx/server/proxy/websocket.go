@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/loadbalance"
+	"github.com/foredata/nova/netx/protocol/http1"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/pkg/bytex"
+)
+
+// isWebSocket 判断是否为websocket升级请求
+func isWebSocket(h netx.Header) bool {
+	return strings.EqualFold(get(h, "Upgrade"), "websocket") && strings.Contains(strings.ToLower(get(h, "Connection")), "upgrade")
+}
+
+// serveWebSocket 透传websocket
+//	1: 直连上游并转发握手请求,https上游使用tls,握手需在HandshakeTimeout内完成
+//	2: 上游返回101后接管客户端连接,替换连接协议为tunnel,之后客户端数据原样写入上游
+//	3: 上游数据由单独协程读取并原样发送给客户端,任意一端关闭则全部关闭
+func (p *proxy) serveWebSocket(ctx context.Context, req netx.Request, picker loadbalance.Picker) (netx.Response, error) {
+	ins, err := picker.Next()
+	if err != nil {
+		return nil, netx.BadGateway("proxy: %+v", err)
+	}
+
+	upstream, reader, hrsp, err := p.handshake(ctx, req, ins.Addr())
+	if err != nil {
+		return nil, netx.BadGateway("proxy: %+v", err)
+	}
+
+	if hrsp.StatusCode != http.StatusSwitchingProtocols {
+		// 上游拒绝升级,按普通应答返回
+		defer upstream.Close()
+		data, err := ioutil.ReadAll(hrsp.Body)
+		_ = hrsp.Body.Close()
+		if err != nil {
+			return nil, netx.BadGateway("proxy: %+v", err)
+		}
+		rsp := netx.NewResponse()
+		rsp.SetStatus(int32(hrsp.StatusCode), "")
+		rsp.SetHeader(responseHeader(hrsp.Header))
+		if len(data) > 0 {
+			buf := bytex.NewBuffer()
+			_ = buf.Append(data)
+			rsp.SetBody(body.NewBufferBody(buf))
+		}
+		return rsp, nil
+	}
+
+	conn := server.Hijack(ctx)
+	if conn == nil {
+		_ = upstream.Close()
+		return nil, netx.BadGateway("proxy: connection can not be hijacked")
+	}
+
+	// 必须在发送101之前替换协议,客户端收到101后即开始发送websocket数据
+	t := &tunnel{upstream: upstream}
+	conn.SetProtocol(t)
+
+	rsp := netx.NewResponse()
+	rsp.SetSeqID(req.SeqID())
+	rsp.SetStatus(http.StatusSwitchingProtocols, "")
+	rh := responseHeader(hrsp.Header)
+	rh.Set("Connection", "Upgrade")
+	rh.Set("Upgrade", hrsp.Header.Get("Upgrade"))
+	rsp.SetHeader(rh)
+	if err := conn.Send(rsp); err != nil {
+		_ = upstream.Close()
+		return nil, err
+	}
+
+	go t.pump(reader, conn)
+	return nil, nil
+}
+
+// handshake 连接上游并转发握手请求,成功后清除deadline,失败时关闭连接
+func (p *proxy) handshake(ctx context.Context, req netx.Request, addr string) (net.Conn, *bufio.Reader, *http.Response, error) {
+	upstream, err := p.dial(addr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	deadline := time.Now().Add(p.opts.HandshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = upstream.SetDeadline(deadline)
+
+	header := requestHeader(ctx, req)
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", get(req.Header(), "Upgrade"))
+	hreq, err := p.newRequest(context.Background(), req, addr, header)
+	if err != nil {
+		_ = upstream.Close()
+		return nil, nil, nil, err
+	}
+
+	if err := hreq.Write(upstream); err != nil {
+		_ = upstream.Close()
+		return nil, nil, nil, err
+	}
+
+	reader := bufio.NewReader(upstream)
+	hrsp, err := http.ReadResponse(reader, hreq)
+	if err != nil {
+		_ = upstream.Close()
+		return nil, nil, nil, err
+	}
+
+	// 非101应答的body仍需在deadline内读取,101后为长连接,清除deadline
+	if hrsp.StatusCode == http.StatusSwitchingProtocols {
+		_ = upstream.SetDeadline(time.Time{})
+	}
+
+	return upstream, reader, hrsp, nil
+}
+
+// dial 连接上游,https上游使用tls,tls握手在首次读写时进行,受handshake deadline限制
+func (p *proxy) dial(addr string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", addr, p.opts.DialTimeout)
+	if err != nil || !isTLS(p.opts.Scheme) {
+		return conn, err
+	}
+
+	cfg := &tls.Config{}
+	if p.opts.TLSConfig != nil {
+		cfg = p.opts.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		host := p.opts.Host
+		if host == "" {
+			host = addr
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		cfg.ServerName = host
+	}
+
+	return tls.Client(conn, cfg), nil
+}
+
+func isTLS(scheme string) bool {
+	return strings.EqualFold(scheme, "https") || strings.EqualFold(scheme, "wss")
+}
+
+// tunnel websocket升级后的连接协议,不做解析,客户端数据原样写入上游
+type tunnel struct {
+	upstream net.Conn
+}
+
+func (t *tunnel) Name() string {
+	return "tunnel"
+}
+
+func (t *tunnel) Detect(p bytex.Peeker) bool {
+	return false
+}
+
+// Decode 将客户端数据写入上游,并丢弃已写入数据,不产生frame
+func (t *tunnel) Decode(conn netx.Conn, buf bytex.Buffer) (netx.Frame, error) {
+	if _, err := buf.WriteTo(t.upstream); err != nil {
+		return nil, err
+	}
+	buf.Discard()
+	return nil, nil
+}
+
+// Encode 仅用于发送101应答
+func (t *tunnel) Encode(conn netx.Conn, frame netx.Frame) (bytex.Buffer, error) {
+	return http1.New().Encode(conn, frame)
+}
+
+// Close 客户端连接关闭时关闭上游连接
+func (t *tunnel) Close() error {
+	return t.upstream.Close()
+}
+
+// pump 将上游数据原样发送给客户端
+func (t *tunnel) pump(reader io.Reader, conn netx.Conn) {
+	defer conn.Close()
+	defer t.upstream.Close()
+
+	for {
+		p := make([]byte, chunkSize)
+		n, err := reader.Read(p)
+		if n > 0 {
+			buf := bytex.NewBuffer()
+			_ = buf.Append(p[:n])
+			if conn.Send(buf) != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/netx"
+)
+
+func newUpgradeRequest() netx.Request {
+	req := newTestRequest(netx.MethodGet, "/ws", "")
+	h := req.Header()
+	h.Set("Connection", "Upgrade")
+	h.Set("Upgrade", "websocket")
+	req.SetHeader(h)
+	return req
+}
+
+// TestHandshakeTLS https上游使用tls握手
+func TestHandshakeTLS(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "websocket")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	defer upstream.Close()
+
+	p := &proxy{opts: newOptions(WithScheme("https"), WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))}
+	addr := strings.TrimPrefix(upstream.URL, "https://")
+	conn, _, hrsp, err := p.handshake(context.Background(), newUpgradeRequest(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*tls.Conn); !ok || hrsp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("bad handshake, %d", hrsp.StatusCode)
+	}
+}
+
+// TestHandshakeTimeout 上游不应答时握手超时
+func TestHandshakeTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	p := &proxy{opts: newOptions(WithHandshakeTimeout(50 * time.Millisecond))}
+	start := time.Now()
+	if _, _, _, err := p.handshake(context.Background(), newUpgradeRequest(), l.Addr().String()); err == nil {
+		t.Fatal("expect timeout")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("handshake should timeout")
+	}
+}
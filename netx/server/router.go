@@ -3,33 +3,173 @@ package server
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/foredata/nova/netx"
 )
 
 // NewRouter 创建router
+//	路由表使用copy-on-write方式更新,每次修改都会复制一份新的路由表并原子替换,
+//	Find无锁且总是看到完整一致的快照,实现了netx.RouteUpdater,适合运行时动态增删路由
 func NewRouter() netx.Router {
-	r := &router{
-		staticMap: make(map[string]*netx.Route),
-		nameMap:   make(map[string]*netx.Route),
-	}
+	r := &router{}
+	r.table.Store(newRouteTable())
 	return r
 }
 
 type router struct {
+	mux   sync.Mutex   // 写操作互斥
+	table atomic.Value // *routeTable,当前快照
+}
+
+// routeTable 路由表快照,发布后只读
+type routeTable struct {
 	noRoute   netx.Callback
 	indexed   []*netx.Route          // 通过cmdId索引
 	nameMap   map[string]*netx.Route // 通过Name映射
 	staticMap map[string]*netx.Route // 通过path静态路由,不存在通配符的情况
-	wildcard  tree                   // 通过path动态路由,存在通配符的情况
+	wildcard  *tree                  // 通过path动态路由,存在通配符的情况
 	routes    []*netx.Route          // 所有routes
 }
 
+func newRouteTable() *routeTable {
+	return &routeTable{
+		nameMap:   make(map[string]*netx.Route),
+		staticMap: make(map[string]*netx.Route),
+		wildcard:  &tree{},
+	}
+}
+
+func (t *routeTable) clone() *routeTable {
+	n := &routeTable{
+		noRoute:   t.noRoute,
+		indexed:   append([]*netx.Route(nil), t.indexed...),
+		nameMap:   make(map[string]*netx.Route, len(t.nameMap)),
+		staticMap: make(map[string]*netx.Route, len(t.staticMap)),
+		wildcard:  t.wildcard.clone(),
+		routes:    append([]*netx.Route(nil), t.routes...),
+	}
+	for k, v := range t.nameMap {
+		n.nameMap[k] = v
+	}
+	for k, v := range t.staticMap {
+		n.staticMap[k] = v
+	}
+
+	return n
+}
+
+// add 添加路由,未设置Name时使用Handler函数名,但不修改route,由调用方在更新成功后设置
+func (t *routeTable) add(route *netx.Route) error {
+	name := routeName(route)
+	if route.CmdID != 0 {
+		// add by command ID
+		if route.CmdID < uint(len(t.indexed)) && t.indexed[route.CmdID] != nil {
+			return fmt.Errorf("duplicate route by cmdId, %+v", route.CmdID)
+		}
+	}
+
+	if route.Path != "" {
+		if isStaticPath(route.Path) {
+			key := toMethodPath(route.Method, route.Path)
+			if _, ok := t.staticMap[key]; ok {
+				return fmt.Errorf("duplicate route,method=%s, path=%+v", route.Method.String(), route.Path)
+			}
+			t.staticMap[key] = route
+		} else if err := t.wildcard.Add(route); err != nil {
+			return err
+		}
+	}
+
+	if route.CmdID != 0 {
+		if route.CmdID >= uint(len(t.indexed)) {
+			indexed := make([]*netx.Route, route.CmdID*2)
+			copy(indexed, t.indexed)
+			t.indexed = indexed
+		}
+		t.indexed[route.CmdID] = route
+	}
+
+	// rpc use name
+	if name != "" {
+		t.nameMap[name] = route
+	}
+
+	t.routes = append(t.routes, route)
+	return nil
+}
+
+// remove 删除与route匹配的路由,匹配规则依次为CmdID,Method+Path,Name
+func (t *routeTable) remove(route *netx.Route) *netx.Route {
+	old := t.lookup(route)
+	if old == nil {
+		return nil
+	}
+
+	if old.CmdID != 0 && old.CmdID < uint(len(t.indexed)) && t.indexed[old.CmdID] == old {
+		t.indexed[old.CmdID] = nil
+	}
+
+	if old.Name != "" && t.nameMap[old.Name] == old {
+		delete(t.nameMap, old.Name)
+	}
+
+	if old.Path != "" {
+		if isStaticPath(old.Path) {
+			delete(t.staticMap, toMethodPath(old.Method, old.Path))
+		} else {
+			t.wildcard.Remove(old)
+		}
+	}
+
+	for i, r := range t.routes {
+		if r == old {
+			t.routes = append(t.routes[:i], t.routes[i+1:]...)
+			break
+		}
+	}
+
+	return old
+}
+
+func (t *routeTable) lookup(route *netx.Route) *netx.Route {
+	for _, r := range t.routes {
+		if r == route {
+			return r
+		}
+	}
+
+	if route.CmdID != 0 && route.CmdID < uint(len(t.indexed)) && t.indexed[route.CmdID] != nil {
+		return t.indexed[route.CmdID]
+	}
+
+	if route.Path != "" {
+		for _, r := range t.routes {
+			if r.Method == route.Method && r.Path == route.Path {
+				return r
+			}
+		}
+		return nil
+	}
+
+	if route.Name != "" {
+		return t.nameMap[route.Name]
+	}
+
+	return nil
+}
+
+func (r *router) load() *routeTable {
+	return r.table.Load().(*routeTable)
+}
+
 func (r *router) Find(packet netx.Packet) netx.Callback {
+	t := r.load()
 	ident := packet.Identifier()
 	// find by cmdId
-	if ident.CmdID != 0 && ident.CmdID < uint32(len(r.indexed)) {
-		route := r.indexed[ident.CmdID]
+	if ident.CmdID != 0 && ident.CmdID < uint32(len(t.indexed)) {
+		route := t.indexed[ident.CmdID]
 		if route != nil {
 			return route.Callback
 		}
@@ -37,11 +177,11 @@ func (r *router) Find(packet netx.Packet) netx.Callback {
 
 	// RPC不使用method
 	if ident.Method == netx.MethodUnknown {
-		if route, ok := r.nameMap[ident.URI]; ok {
+		if route, ok := t.nameMap[ident.URI]; ok {
 			return route.Callback
 		}
 
-		return r.noRoute
+		return t.noRoute
 	}
 
 	// find by static path
@@ -52,74 +192,98 @@ func (r *router) Find(packet netx.Packet) netx.Callback {
 	}
 
 	if path == "" {
-		return r.noRoute
+		return t.noRoute
 	}
 
 	key := toMethodPath(ident.Method, path)
-	if route, ok := r.staticMap[key]; ok {
+	if route, ok := t.staticMap[key]; ok {
 		return route.Callback
 	}
 
 	// any method
 	key = toMethodPath(netx.MethodAny, path)
-	if route, ok := r.staticMap[key]; ok {
+	if route, ok := t.staticMap[key]; ok {
 		return route.Callback
 	}
 
 	// find by dynamic path
+	if route, keys, values := t.wildcard.Match(ident.Method, path); route != nil {
+		ident.Params.Reset(keys, values)
+		return route.Callback
+	}
 
-	return r.noRoute
+	return t.noRoute
 }
 
+// Routes 返回所有路由的副本,修改返回值不影响路由表
 func (r *router) Routes() []*netx.Route {
-	return r.routes
+	return append([]*netx.Route(nil), r.load().routes...)
 }
 
 func (r *router) NoRoute(callback netx.Callback) {
-	r.noRoute = callback
+	r.mux.Lock()
+	t := r.load().clone()
+	t.noRoute = callback
+	r.table.Store(t)
+	r.mux.Unlock()
 }
 
+// Register 注册路由,重复时panic
 func (r *router) Register(route *netx.Route) {
-	if route.Name == "" {
-		route.Name = funcName(route.Handler)
+	if err := r.Update(nil, []*netx.Route{route}); err != nil {
+		panic(err)
 	}
+}
 
-	if route.CmdID != 0 {
-		// add by command ID
-		if route.CmdID >= uint(len(r.indexed)) {
-			r.indexed = make([]*netx.Route, route.CmdID*2)
-		}
-		if r.indexed[route.CmdID] != nil {
-			panic(fmt.Errorf("duplicate route by cmdId, %+v", route.CmdID))
-		}
-		r.indexed[route.CmdID] = route
+// Unregister 删除路由,返回是否存在
+func (r *router) Unregister(route *netx.Route) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	t := r.load().clone()
+	if t.remove(route) == nil {
+		return false
 	}
 
-	// rpc use name
-	if route.Name != "" {
-		r.nameMap[route.Name] = route
+	r.table.Store(t)
+	return true
+}
+
+// Update 先删除remove中的路由,再添加add中的路由,全部成功后原子替换,失败则不做任何修改
+//	不存在的remove路由会被忽略,可用于替换已有路由
+func (r *router) Update(remove []*netx.Route, add []*netx.Route) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	t := r.load().clone()
+	for _, route := range remove {
+		t.remove(route)
 	}
 
-	if route.Path == "" {
-		return
+	for _, route := range add {
+		if err := t.add(route); err != nil {
+			return err
+		}
 	}
 
-	if route.Path != "" {
-		if !strings.ContainsAny(route.Path, ":*{[(") {
-			// static path
-			key := toMethodPath(route.Method, route.Path)
-			if _, ok := r.staticMap[key]; ok {
-				panic(fmt.Errorf("duplicate route,method=%s, path=%+v", route.Method.String(), route.Path))
-			}
+	// 全部添加成功后再设置Name,失败时不修改调用方的route
+	for _, route := range add {
+		route.Name = routeName(route)
+	}
 
-			r.staticMap[key] = route
-		} else {
-			// dynamic path
-			if err := r.wildcard.Add(route); err != nil {
-				panic(err)
-			}
-		}
+	r.table.Store(t)
+	return nil
+}
+
+// routeName 未设置Name时使用Handler函数名
+func routeName(route *netx.Route) string {
+	if route.Name == "" && route.Handler != nil {
+		return funcName(route.Handler)
 	}
 
-	r.routes = append(r.routes, route)
+	return route.Name
+}
+
+func isStaticPath(path string) bool {
+	return !strings.ContainsAny(path, ":*{[(")
 }
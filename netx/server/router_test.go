@@ -0,0 +1,171 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/foredata/nova/netx"
+)
+
+func newTestRoute(method netx.Method, path string, name *string, value string) *netx.Route {
+	return &netx.Route{
+		Name:   value,
+		Method: method,
+		Path:   path,
+		Callback: func(conn netx.Conn, packet netx.Packet) error {
+			*name = value
+			return nil
+		},
+	}
+}
+
+func findRoute(r netx.Router, method netx.Method, uri string) (*netx.Identifier, bool) {
+	pkt := netx.NewPacket()
+	ident := &netx.Identifier{Method: method, URI: uri}
+	pkt.SetIdentifier(ident)
+	cb := r.Find(pkt)
+	if cb == nil {
+		return ident, false
+	}
+	_ = cb(nil, pkt)
+	return ident, true
+}
+
+func TestRouterWildcard(t *testing.T) {
+	r := NewRouter()
+	hit := ""
+	r.Register(newTestRoute(netx.MethodGet, "/users/:id", &hit, "user"))
+	r.Register(newTestRoute(netx.MethodGet, "/users/{id}/orders/:oid", &hit, "order"))
+	r.Register(newTestRoute(netx.MethodGet, "/users/me", &hit, "me"))
+	r.Register(newTestRoute(netx.MethodGet, "/static/*filepath", &hit, "static"))
+
+	req, ok := findRoute(r, netx.MethodGet, "/users/123")
+	if !ok || hit != "user" || req.Params.Get("id") != "123" {
+		t.Fatalf("match user fail, hit=%s", hit)
+	}
+
+	req, ok = findRoute(r, netx.MethodGet, "/users/1/orders/2")
+	if !ok || hit != "order" || req.Params.Get("id") != "1" || req.Params.Get("oid") != "2" {
+		t.Fatalf("match order fail, hit=%s", hit)
+	}
+
+	if _, ok = findRoute(r, netx.MethodGet, "/users/me"); !ok || hit != "me" {
+		t.Fatalf("match static fail, hit=%s", hit)
+	}
+
+	req, ok = findRoute(r, netx.MethodGet, "/static/js/app.js")
+	if !ok || hit != "static" || req.Params.Get("filepath") != "js/app.js" {
+		t.Fatalf("match catch-all fail, hit=%s", hit)
+	}
+
+	if _, ok = findRoute(r, netx.MethodPost, "/users/123"); ok {
+		t.Fatal("method should not match")
+	}
+}
+
+// TestRouterCatchAll 静态段相同时,参数路由优先于*,与注册顺序无关
+func TestRouterCatchAll(t *testing.T) {
+	for _, reverse := range []bool{false, true} {
+		r := NewRouter()
+		hit := ""
+		routes := []*netx.Route{
+			newTestRoute(netx.MethodGet, "/files/*path", &hit, "all"),
+			newTestRoute(netx.MethodGet, "/files/:name", &hit, "name"),
+		}
+		if reverse {
+			routes[0], routes[1] = routes[1], routes[0]
+		}
+		for _, route := range routes {
+			r.Register(route)
+		}
+
+		if req, ok := findRoute(r, netx.MethodGet, "/files/a.txt"); !ok || hit != "name" || req.Params.Get("name") != "a.txt" {
+			t.Fatalf("param route should win, hit=%s", hit)
+		}
+		if req, ok := findRoute(r, netx.MethodGet, "/files/a/b.txt"); !ok || hit != "all" || req.Params.Get("path") != "a/b.txt" {
+			t.Fatalf("catch-all should match rest, hit=%s", hit)
+		}
+	}
+}
+
+// TestRouterBacktrack 静态段方法不匹配或后续段不匹配时,回溯尝试参数段和*
+func TestRouterBacktrack(t *testing.T) {
+	r := NewRouter()
+	hit := ""
+	r.Register(newTestRoute(netx.MethodPost, "/users/new", &hit, "new"))
+	r.Register(newTestRoute(netx.MethodGet, "/users/:id", &hit, "user"))
+	r.Register(newTestRoute(netx.MethodGet, "/users/me/profile", &hit, "profile"))
+	r.Register(newTestRoute(netx.MethodGet, "/users/:id/:tab", &hit, "tab"))
+	r.Register(newTestRoute(netx.MethodAny, "/*rest", &hit, "rest"))
+
+	cases := []struct {
+		method netx.Method
+		uri    string
+		hit    string
+	}{
+		{netx.MethodPost, "/users/new", "new"},
+		{netx.MethodGet, "/users/new", "user"},
+		{netx.MethodGet, "/users/me/profile", "profile"},
+		{netx.MethodGet, "/users/me/orders", "tab"},
+		{netx.MethodDelete, "/users/1", "rest"},
+	}
+	for _, c := range cases {
+		hit = ""
+		if _, ok := findRoute(r, c.method, c.uri); !ok || hit != c.hit {
+			t.Fatalf("match %s %s fail, hit=%s", c.method.String(), c.uri, hit)
+		}
+	}
+
+	req, _ := findRoute(r, netx.MethodGet, "/users/me/orders")
+	if req.Params.Get("id") != "me" || req.Params.Get("tab") != "orders" {
+		t.Fatal("bad params")
+	}
+}
+
+func TestRouterUpdate(t *testing.T) {
+	r := NewRouter().(*router)
+	hit := ""
+	v1 := newTestRoute(netx.MethodGet, "/items/:id", &hit, "v1")
+	r.Register(v1)
+
+	dup := newTestRoute(netx.MethodGet, "/items/{id}", &hit, "")
+	dup.Handler = func() {}
+	if err := r.Update(nil, []*netx.Route{dup}); err == nil {
+		t.Fatal("duplicate route should fail")
+	}
+	if dup.Name != "" {
+		t.Fatalf("failed update should not change route, name=%s", dup.Name)
+	}
+
+	// 失败的更新不应修改路由表
+	if _, ok := findRoute(r, netx.MethodGet, "/items/1"); !ok || hit != "v1" {
+		t.Fatalf("route changed after failed update, hit=%s", hit)
+	}
+
+	v2 := newTestRoute(netx.MethodGet, "/items/:id", &hit, "v2")
+	if err := r.Update([]*netx.Route{v1}, []*netx.Route{v2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := findRoute(r, netx.MethodGet, "/items/1"); !ok || hit != "v2" {
+		t.Fatalf("replace fail, hit=%s", hit)
+	}
+
+	if !r.Unregister(v2) {
+		t.Fatal("unregister fail")
+	}
+	if r.Unregister(v2) {
+		t.Fatal("unregister twice should fail")
+	}
+	if _, ok := findRoute(r, netx.MethodGet, "/items/1"); ok {
+		t.Fatal("route should be removed")
+	}
+	if len(r.Routes()) != 0 {
+		t.Fatalf("routes should be empty, %d", len(r.Routes()))
+	}
+
+	// 修改Routes返回值不影响路由表
+	r.Register(v1)
+	r.Routes()[0] = v2
+	if _, ok := findRoute(r, netx.MethodGet, "/items/1"); !ok || hit != "v1" || r.Routes()[0] != v1 {
+		t.Fatalf("routes should be copied, hit=%s", hit)
+	}
+}
@@ -1,10 +1,226 @@
 package server
 
-import "github.com/foredata/nova/netx"
+import (
+	"fmt"
+	"strings"
 
+	"github.com/foredata/nova/netx"
+)
+
+// tree 通配符路由,按路径段组织的前缀树,支持以下格式,发布后只读,修改时需先clone
+//	/users/:id
+//	/users/{id}/orders
+//	/static/*filepath,*只能出现在最后一段,匹配剩余全部路径
+//	匹配时逐段查找,同一位置静态段优先,其次参数段,最后*,不匹配时回溯尝试下一种
 type tree struct {
+	root node
+}
+
+// node 对应一个路径段,参数段不区分参数名,参数名保存在wildRoute中
+type node struct {
+	statics  map[string]*node
+	param    *node
+	catchAll *node
+	routes   map[netx.Method]*wildRoute // 在此节点结束的路由
+}
+
+type wildRoute struct {
+	route *netx.Route
+	keys  []string // 参数名,与匹配出的参数值一一对应
+}
+
+type segmentKind uint8
+
+const (
+	segStatic segmentKind = iota
+	segParam
+	segCatchAll
+)
+
+type segment struct {
+	kind segmentKind
+	text string // 静态段为原文,参数段为参数名
+}
+
+func (t *tree) clone() *tree {
+	return &tree{root: *t.root.clone()}
+}
+
+func (n *node) clone() *node {
+	c := &node{}
+	if n.statics != nil {
+		c.statics = make(map[string]*node, len(n.statics))
+		for k, v := range n.statics {
+			c.statics[k] = v.clone()
+		}
+	}
+	if n.param != nil {
+		c.param = n.param.clone()
+	}
+	if n.catchAll != nil {
+		c.catchAll = n.catchAll.clone()
+	}
+	if n.routes != nil {
+		c.routes = make(map[netx.Method]*wildRoute, len(n.routes))
+		for k, v := range n.routes {
+			c.routes[k] = v
+		}
+	}
+
+	return c
 }
 
 func (t *tree) Add(route *netx.Route) error {
+	segs, err := parsePattern(route.Path)
+	if err != nil {
+		return err
+	}
+
+	wr := &wildRoute{route: route}
+	n := &t.root
+	for _, s := range segs {
+		switch s.kind {
+		case segStatic:
+			if n.statics == nil {
+				n.statics = make(map[string]*node)
+			}
+			child := n.statics[s.text]
+			if child == nil {
+				child = &node{}
+				n.statics[s.text] = child
+			}
+			n = child
+		case segParam:
+			if n.param == nil {
+				n.param = &node{}
+			}
+			n = n.param
+			wr.keys = append(wr.keys, s.text)
+		case segCatchAll:
+			if n.catchAll == nil {
+				n.catchAll = &node{}
+			}
+			n = n.catchAll
+			wr.keys = append(wr.keys, s.text)
+		}
+	}
+
+	if _, ok := n.routes[route.Method]; ok {
+		return fmt.Errorf("duplicate route,method=%s, path=%+v", route.Method.String(), route.Path)
+	}
+	if n.routes == nil {
+		n.routes = make(map[netx.Method]*wildRoute)
+	}
+	n.routes[route.Method] = wr
+
 	return nil
 }
+
+func (t *tree) Remove(route *netx.Route) bool {
+	segs, err := parsePattern(route.Path)
+	if err != nil {
+		return false
+	}
+
+	n := &t.root
+	for _, s := range segs {
+		switch s.kind {
+		case segStatic:
+			n = n.statics[s.text]
+		case segParam:
+			n = n.param
+		case segCatchAll:
+			n = n.catchAll
+		}
+		if n == nil {
+			return false
+		}
+	}
+
+	if wr, ok := n.routes[route.Method]; ok && wr.route == route {
+		delete(n.routes, route.Method)
+		return true
+	}
+
+	return false
+}
+
+// Match 查找路由,并返回解析出的参数
+func (t *tree) Match(method netx.Method, path string) (*netx.Route, []string, []string) {
+	wr, values := t.root.match(method, splitPath(path), nil)
+	if wr == nil {
+		return nil, nil, nil
+	}
+
+	return wr.route, wr.keys, values
+}
+
+// match 匹配剩余的路径段,失败时返回nil,由上层继续尝试其他分支
+func (n *node) match(method netx.Method, parts []string, values []string) (*wildRoute, []string) {
+	if len(parts) == 0 {
+		if wr := n.find(method); wr != nil {
+			return wr, values
+		}
+	} else {
+		if child := n.statics[parts[0]]; child != nil {
+			if wr, res := child.match(method, parts[1:], values); wr != nil {
+				return wr, res
+			}
+		}
+		if n.param != nil && parts[0] != "" {
+			if wr, res := n.param.match(method, parts[1:], append(values, parts[0])); wr != nil {
+				return wr, res
+			}
+		}
+	}
+
+	if n.catchAll != nil {
+		if wr := n.catchAll.find(method); wr != nil {
+			return wr, append(values, strings.Join(parts, "/"))
+		}
+	}
+
+	return nil, nil
+}
+
+// find 查找在此节点结束的路由,不存在时使用MethodAny
+func (n *node) find(method netx.Method) *wildRoute {
+	if wr, ok := n.routes[method]; ok {
+		return wr
+	}
+
+	return n.routes[netx.MethodAny]
+}
+
+func parsePattern(path string) ([]segment, error) {
+	parts := splitPath(path)
+	segs := make([]segment, 0, len(parts))
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, ":") && len(p) > 1:
+			segs = append(segs, segment{kind: segParam, text: p[1:]})
+		case strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") && len(p) > 2:
+			segs = append(segs, segment{kind: segParam, text: p[1 : len(p)-1]})
+		case strings.HasPrefix(p, "*"):
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("catch-all must be the last segment, path=%s", path)
+			}
+			name := p[1:]
+			if name == "" {
+				name = "*"
+			}
+			segs = append(segs, segment{kind: segCatchAll, text: name})
+		default:
+			if strings.ContainsAny(p, ":*{}") {
+				return nil, fmt.Errorf("invalid route path, %s", path)
+			}
+			segs = append(segs, segment{kind: segStatic, text: p})
+		}
+	}
+
+	return segs, nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
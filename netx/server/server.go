@@ -8,10 +8,13 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/health"
 	"github.com/foredata/nova/netx/registry"
 
 	// 强制注册codec
@@ -21,22 +24,37 @@ import (
 // New 创建Server
 func New(opts ...Option) netx.Server {
 	o := newOptions(opts...)
-	s := &server{opts: o}
+	s := &server{opts: o, ready: 1}
 	return s
 }
 
 type server struct {
 	opts        *Options
+	mux         sync.Mutex // 保护middlewares,noRoute
 	middlewares []netx.Middleware
+	noRoute     *netx.Route
 	service     *registry.Service
 	exit        chan os.Signal
 	addr        net.Addr
+	admin       *server // 管理端口
+	ready       int32      // readiness是否通过,未设置Health时总是通过
+	watching    int32      // 首次注册完成后,readiness变化时自动注册和注销服务
+	regMux      sync.Mutex // 串行执行服务注册和注销,避免定时注册与readiness变化交错
 }
 
 func (s *server) Addr() net.Addr {
 	return s.addr
 }
 
+// AdminAddr 管理端口监听地址,未启用时返回nil
+func (s *server) AdminAddr() net.Addr {
+	if s.admin == nil {
+		return nil
+	}
+
+	return s.admin.addr
+}
+
 func (s *server) Options() *Options {
 	return s.opts
 }
@@ -71,9 +89,13 @@ func (s *server) buildService() error {
 
 	routes := s.opts.Router.Routes()
 	for _, r := range routes {
+		// 复制一份,避免修改路由中的Metadata
 		ep := &registry.Endpoint{
 			Name:     r.Name,
-			Metadata: r.Metadata,
+			Metadata: make(map[string]string, len(r.Metadata)+1),
+		}
+		for k, v := range r.Metadata {
+			ep.Metadata[k] = v
 		}
 
 		if r.CmdID != 0 {
@@ -110,10 +132,22 @@ func (s *server) Start() error {
 		return err
 	}
 
+	if opts.Health != nil {
+		if err := s.startHealth(); err != nil {
+			return err
+		}
+	}
+
 	if s.opts.Registry != nil {
-		if err := s.opts.Registry.Register(context.Background(), s.service, s.opts.RegistryTTL); err != nil {
-			return fmt.Errorf("registry fail, %+v", s.service)
+		s.regMux.Lock()
+		if atomic.LoadInt32(&s.ready) == 1 {
+			if err := s.opts.Registry.Register(context.Background(), s.service, s.opts.RegistryTTL); err != nil {
+				s.regMux.Unlock()
+				return fmt.Errorf("registry fail, %+v", s.service)
+			}
 		}
+		atomic.StoreInt32(&s.watching, 1)
+		s.regMux.Unlock()
 
 		s.tickRegistry()
 	}
@@ -131,8 +165,19 @@ func (s *server) Start() error {
 func (s *server) Stop() error {
 	var errList []string
 
+	// 先标记readiness失败,探针及grpc Check返回NOT_SERVING,再注销服务
+	s.regMux.Lock()
+	atomic.StoreInt32(&s.watching, 0)
+	s.regMux.Unlock()
+	if s.opts.Health != nil {
+		s.opts.Health.Shutdown()
+	}
+
 	if s.opts.Registry != nil {
-		if err := s.opts.Registry.Deregister(context.Background(), s.service); err != nil {
+		s.regMux.Lock()
+		err := s.opts.Registry.Deregister(context.Background(), s.service)
+		s.regMux.Unlock()
+		if err != nil {
 			errList = append(errList, fmt.Sprintf("deregister fail, %+v", err.Error()))
 		}
 	}
@@ -141,6 +186,18 @@ func (s *server) Stop() error {
 		errList = append(errList, fmt.Sprintf("tran close fail, %+v", err.Error()))
 	}
 
+	if s.admin != nil {
+		if err := s.admin.Stop(); err != nil {
+			errList = append(errList, fmt.Sprintf("admin stop fail, %+v", err.Error()))
+		}
+	}
+
+	if s.opts.Health != nil {
+		if err := s.opts.Health.Stop(); err != nil {
+			errList = append(errList, fmt.Sprintf("health stop fail, %+v", err.Error()))
+		}
+	}
+
 	for _, m := range s.opts.Modules {
 		if err := m.Stop(); err != nil {
 			errList = append(errList, fmt.Sprintf("[%s] module stop fail, %s", m.Name(), err.Error()))
@@ -154,17 +211,70 @@ func (s *server) Stop() error {
 	return nil
 }
 
-// 定时自动服务注册
+// 定时自动服务注册,与onReady互斥,Stop后退出
 func (s *server) tickRegistry() {
 	ttl := s.opts.RegistryTTL
 	t := time.NewTicker(ttl / 3)
 	go func() {
+		defer t.Stop()
 		for range t.C {
-			_ = s.opts.Registry.Register(context.Background(), s.service, ttl)
+			s.regMux.Lock()
+			if atomic.LoadInt32(&s.watching) == 0 {
+				s.regMux.Unlock()
+				return
+			}
+			if atomic.LoadInt32(&s.ready) == 1 {
+				_ = s.opts.Registry.Register(context.Background(), s.service, ttl)
+			}
+			s.regMux.Unlock()
 		}
 	}()
 }
 
+// startHealth 注册健康检查路由,启动管理端口及后台检查
+func (s *server) startHealth() error {
+	h := s.opts.Health
+	h.Watch(s.onReady)
+	if s.opts.AdminAddr == "" {
+		for _, r := range health.Routes(h) {
+			s.Register(r)
+		}
+	} else {
+		s.Register(health.CheckRoute(h))
+		admin := New(WithAddr(s.opts.AdminAddr), WithTranFactory(s.opts.TranFactory)).(*server)
+		for _, r := range health.Routes(h) {
+			admin.Register(r)
+		}
+		if err := admin.Start(); err != nil {
+			return fmt.Errorf("admin start fail, %w", err)
+		}
+		s.admin = admin
+	}
+
+	return h.Start()
+}
+
+// onReady readiness变化时注销或者重新注册服务
+func (s *server) onReady(ready bool) {
+	s.regMux.Lock()
+	defer s.regMux.Unlock()
+	if !ready {
+		atomic.StoreInt32(&s.ready, 0)
+	} else {
+		atomic.StoreInt32(&s.ready, 1)
+	}
+
+	if s.opts.Registry == nil || atomic.LoadInt32(&s.watching) == 0 {
+		return
+	}
+
+	if ready {
+		_ = s.opts.Registry.Register(context.Background(), s.service, s.opts.RegistryTTL)
+	} else {
+		_ = s.opts.Registry.Deregister(context.Background(), s.service)
+	}
+}
+
 func (s *server) Wait() {
 	s.exit = make(chan os.Signal, 1)
 	signal.Notify(s.exit, s.opts.Signals...)
@@ -182,8 +292,13 @@ func (s *server) Group(prefix string, middlewares ...netx.Middleware) netx.Group
 	return newGroup(s, prefix, middlewares)
 }
 
+// Use 注册全局中间件,已注册的路由会重新生成Callback
 func (s *server) Use(middlewares ...netx.Middleware) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
 	s.middlewares = append(s.middlewares, middlewares...)
+	s.rebuild()
 }
 
 func (s *server) CONNECT(path string, handler interface{}, middlewares ...netx.Middleware) {
@@ -226,30 +341,105 @@ func (s *server) Any(path string, handler interface{}, middlewares ...netx.Middl
 	s.add(netx.MethodAny, path, 0, handler, middlewares)
 }
 
+// Register 注册路由,Handler不为空时会使用全局中间件,Middlewares和Handler生成Callback
+//	生成Callback和注册在同一个锁内,避免与Use并发时注册使用旧中间件生成的Callback
 func (s *server) Register(route *netx.Route) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.build(route)
 	s.opts.Router.Register(route)
 }
 
+// Unregister 删除路由,Router未实现netx.RouteUpdater时返回false
+func (s *server) Unregister(route *netx.Route) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if u, ok := s.opts.Router.(netx.RouteUpdater); ok {
+		return u.Unregister(route)
+	}
+
+	return false
+}
+
+// Update 原子批量更新路由,add中的路由会重新生成Callback,Router未实现netx.RouteUpdater时返回ErrNotSupport
+func (s *server) Update(remove, add []*netx.Route) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	u, ok := s.opts.Router.(netx.RouteUpdater)
+	if !ok {
+		return netx.ErrNotSupport
+	}
+	for _, r := range add {
+		s.build(r)
+	}
+
+	return u.Update(remove, add)
+}
+
 func (s *server) NoRoute(handler interface{}, middlewares ...netx.Middleware) {
-	middlewares = append(s.middlewares, middlewares...)
-	endpoint := netx.Apply(toEndpoint(handler, s.opts), middlewares)
-	callback := toCallback(endpoint)
-	s.opts.Router.NoRoute(callback)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.noRoute = &netx.Route{Handler: handler, Middlewares: middlewares}
+	s.opts.Router.NoRoute(s.buildCallback(nil, handler, middlewares))
 }
 
 func (s *server) add(method netx.Method, path string, cmdId uint, handler interface{}, middlewares []netx.Middleware) {
-	middlewares = append(s.middlewares, middlewares...)
-	endpoint := netx.Apply(toEndpoint(handler, s.opts), middlewares)
-	callback := toCallback(endpoint)
-
 	route := &netx.Route{
 		Method:      method,
 		Path:        path,
 		CmdID:       cmdId,
 		Handler:     handler,
 		Middlewares: middlewares,
-		Callback:    callback,
 	}
 
-	s.opts.Router.Register(route)
+	s.Register(route)
+}
+
+// build 生成Callback,需要持有锁
+func (s *server) build(route *netx.Route) {
+	if route.Handler != nil {
+		route.Callback = s.buildCallback(route, route.Handler, route.Middlewares)
+	}
+}
+
+func (s *server) buildCallback(route *netx.Route, handler interface{}, middlewares []netx.Middleware) netx.Callback {
+	mws := make([]netx.Middleware, 0, len(s.middlewares)+len(middlewares))
+	mws = append(mws, s.middlewares...)
+	mws = append(mws, middlewares...)
+	endpoint := netx.Apply(toEndpoint(handler, s.opts), mws)
+	return toCallback(route, endpoint, s.opts)
+}
+
+// rebuild 全局中间件变化后,复制并重新生成所有路由,然后原子替换,需要持有锁
+//	Router未实现netx.RouteUpdater时,全局中间件仅对之后注册的路由生效
+func (s *server) rebuild() {
+	if s.noRoute != nil {
+		s.opts.Router.NoRoute(s.buildCallback(nil, s.noRoute.Handler, s.noRoute.Middlewares))
+	}
+
+	u, ok := s.opts.Router.(netx.RouteUpdater)
+	if !ok {
+		return
+	}
+
+	var remove, add []*netx.Route
+	for _, r := range s.opts.Router.Routes() {
+		if r.Handler == nil {
+			continue
+		}
+		nr := *r
+		s.build(&nr)
+		remove = append(remove, r)
+		add = append(add, &nr)
+	}
+
+	if len(add) > 0 {
+		if err := u.Update(remove, add); err != nil {
+			panic(err)
+		}
+	}
 }
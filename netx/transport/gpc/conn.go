@@ -22,16 +22,22 @@ type gpcConn struct {
 	cond *sync.Cond //
 }
 
+// Open 先执行HandleOpen再启动读写协程,保证Filter在HandleOpen中完成初始化后才会收到数据
+// HandleOpen中关闭连接时不再读取数据,写协程负责发送剩余数据并关闭连接
 func (c *gpcConn) Open(conn net.Conn) error {
 	err := c.doOpen(conn)
-
-	if err == nil {
-		c.GetChain().HandleOpen(c)
-	} else {
+	if err != nil {
 		c.GetChain().HandleError(c, err)
+		return err
 	}
 
-	return err
+	c.GetChain().HandleOpen(c)
+	if c.IsStatus(netx.OPEN) {
+		go c.readLoop(conn)
+	}
+	go c.writeLoop()
+
+	return nil
 }
 
 func (c *gpcConn) Close() error {
@@ -84,18 +90,17 @@ func (c *gpcConn) doOpen(conn net.Conn) error {
 	c.SetRemoteAddr(conn.RemoteAddr().String())
 	c.GetReadBuffer().Clear()
 	c.SetStatus(netx.OPEN)
-	go c.readLoop()
-	go c.writeLoop()
 
 	return nil
 }
 
+// doClose 读写协程都可能触发关闭,需保证只通知一次
 func (c *gpcConn) doClose(err error) {
+	c.Lock()
 	if c.IsStatus(netx.CLOSED) {
+		c.Unlock()
 		return
 	}
-
-	c.Lock()
 	c.GetWriter().Clear()
 	c.SetStatus(netx.CLOSED)
 	if c.conn != nil {
@@ -107,12 +112,13 @@ func (c *gpcConn) doClose(err error) {
 	if err != nil {
 		c.onError(err)
 	}
+	c.GetChain().HandleClose(c)
 }
 
 // https://tonybai.com/2015/11/17/tcp-programming-in-golang/
 // http://www.zfcode.com/?p=315
-func (c *gpcConn) readLoop() {
-	conn := c.conn
+// readLoop conn通过参数传入,写协程关闭连接时c.conn可能已被置空
+func (c *gpcConn) readLoop(conn net.Conn) {
 	rb := c.GetReadBuffer()
 	for {
 		_, _ = rb.Seek(0, io.SeekEnd)
@@ -142,12 +148,15 @@ func (c *gpcConn) writeLoop() {
 		c.Unlock()
 
 		if closed {
+			writer.Clear()
 			break
 		}
 
 		if conn != nil && !writer.Empty() {
 			_, err := writer.WriteTo(conn)
 			if err != nil {
+				// 释放未发送完的数据,流式body关闭后写入方会收到错误
+				writer.Clear()
 				c.doClose(err)
 				break
 			}
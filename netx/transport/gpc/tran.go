@@ -1,6 +1,9 @@
 package gpc
 
 import (
+	"errors"
+	"net"
+
 	"github.com/foredata/nova/netx"
 )
 
@@ -25,10 +28,14 @@ func (t *gpcTran) Listen(addr string, opts ...netx.Option) (netx.Listener, error
 		return nil, err
 	}
 
+	t.AddListener(l)
 	go func() {
 		for {
 			sock, err := l.Accept()
 			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
 				continue
 			}
 			conn := newConn(t, false, o.Tag)
@@ -0,0 +1,259 @@
+package memory
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// some error
+var (
+	ErrReset = errors.New("memory: connection reset by peer")
+)
+
+const (
+	minRTO     = 200 * time.Millisecond // 首次重传超时
+	maxRetries = 5                      // 连续丢包超过该次数时重置连接
+)
+
+// chunk 一次写入的数据,ready之后才能被读取,用于模拟延迟
+type chunk struct {
+	data  []byte
+	ready time.Time
+}
+
+func newPipe() *pipe {
+	p := &pipe{}
+	p.cond = sync.NewCond(&p.mux)
+	return p
+}
+
+// pipe 单向无界缓冲管道,写入不会阻塞
+type pipe struct {
+	mux      sync.Mutex
+	cond     *sync.Cond
+	chunks   []chunk
+	closed   bool
+	reset    bool        // 连接被重置,剩余数据直接丢弃
+	deadline time.Time   // 读超时时间
+	timer    *time.Timer // 读超时后唤醒等待
+}
+
+func (p *pipe) write(data []byte, latency time.Duration) error {
+	c := chunk{data: append([]byte(nil), data...)}
+	if latency > 0 {
+		c.ready = time.Now().Add(latency)
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.reset {
+		return ErrReset
+	}
+	if p.closed {
+		return io.ErrClosedPipe
+	}
+	p.chunks = append(p.chunks, c)
+	p.cond.Signal()
+	return nil
+}
+
+func (p *pipe) read(b []byte) (int, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for {
+		if p.reset {
+			return 0, ErrReset
+		}
+
+		if !p.deadline.IsZero() && !time.Now().Before(p.deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		if len(p.chunks) > 0 {
+			c := &p.chunks[0]
+			if wait := time.Until(c.ready); !c.ready.IsZero() && wait > 0 {
+				// 等待期间可能被关闭,重置或超时,使用timer唤醒
+				t := time.AfterFunc(wait, p.wakeup)
+				p.cond.Wait()
+				t.Stop()
+				continue
+			}
+
+			n := copy(b, c.data)
+			c.data = c.data[n:]
+			if len(c.data) == 0 {
+				p.chunks[0] = chunk{}
+				p.chunks = p.chunks[1:]
+			}
+			return n, nil
+		}
+
+		if p.closed {
+			return 0, io.EOF
+		}
+
+		p.cond.Wait()
+	}
+}
+
+func (p *pipe) wakeup() {
+	p.mux.Lock()
+	p.cond.Broadcast()
+	p.mux.Unlock()
+}
+
+func (p *pipe) close() {
+	p.mux.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mux.Unlock()
+}
+
+// abort 重置连接,丢弃未读取的数据
+func (p *pipe) abort() {
+	p.mux.Lock()
+	p.closed = true
+	p.reset = true
+	p.chunks = nil
+	p.cond.Broadcast()
+	p.mux.Unlock()
+}
+
+// setDeadline 设置读超时,零值表示不超时,会唤醒正在等待的读操作
+func (p *pipe) setDeadline(t time.Time) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.deadline = t
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if !t.IsZero() {
+		p.timer = time.AfterFunc(time.Until(t), p.wakeup)
+	}
+	p.cond.Broadcast()
+}
+
+func newConn(local, remote net.Addr, r, w *pipe, opts *Options) *memConn {
+	return &memConn{local: local, remote: remote, r: r, w: w, opts: opts}
+}
+
+// memConn 实现net.Conn,由两个方向相反的pipe组成
+type memConn struct {
+	local     net.Addr
+	remote    net.Addr
+	r         *pipe // 读取对端数据
+	w         *pipe // 写入对端
+	opts      *Options
+	once      sync.Once
+	mux       sync.Mutex // 保护wdeadline
+	wdeadline time.Time  // 写超时时间
+}
+
+func (c *memConn) Read(b []byte) (int, error) {
+	return c.r.read(b)
+}
+
+func (c *memConn) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	c.mux.Lock()
+	deadline := c.wdeadline
+	c.mux.Unlock()
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	if c.opts.Bandwidth > 0 {
+		wait := time.Duration(float64(len(b)) / float64(c.opts.Bandwidth) * float64(time.Second))
+		if !deadline.IsZero() && time.Until(deadline) < wait {
+			time.Sleep(time.Until(deadline))
+			return 0, os.ErrDeadlineExceeded
+		}
+		time.Sleep(wait)
+	}
+
+	latency, ok := c.lossDelay()
+	if !ok {
+		c.reset()
+		return 0, ErrReset
+	}
+
+	if err := c.w.write(b, c.opts.Latency+latency); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// lossDelay 模拟tcp丢包重传,数据不会丢失或乱序,每次丢包增加一次指数退避的重传延迟,
+// 连续丢包超过maxRetries时返回false,此时连接被重置
+func (c *memConn) lossDelay() (time.Duration, bool) {
+	if c.opts.DropRate <= 0 {
+		return 0, true
+	}
+
+	var delay time.Duration
+	rto := minRTO
+	if rto < 2*c.opts.Latency {
+		rto = 2 * c.opts.Latency
+	}
+	for i := 0; rand.Float64() < c.opts.DropRate; i++ {
+		if i >= maxRetries {
+			return 0, false
+		}
+		delay += rto
+		rto *= 2
+	}
+
+	return delay, true
+}
+
+// reset 重置连接,两端读写均返回ErrReset
+func (c *memConn) reset() {
+	c.once.Do(func() {
+		c.r.abort()
+		c.w.abort()
+	})
+}
+
+// Close 同时关闭两个方向,对端读取完剩余数据后返回io.EOF
+func (c *memConn) Close() error {
+	c.once.Do(func() {
+		c.r.close()
+		c.w.close()
+	})
+	return nil
+}
+
+func (c *memConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *memConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *memConn) SetDeadline(t time.Time) error {
+	_ = c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *memConn) SetReadDeadline(t time.Time) error {
+	c.r.setDeadline(t)
+	return nil
+}
+
+func (c *memConn) SetWriteDeadline(t time.Time) error {
+	c.mux.Lock()
+	c.wdeadline = t
+	c.mux.Unlock()
+	return nil
+}
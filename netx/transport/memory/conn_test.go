@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestConns(opts *Options) (*memConn, *memConn) {
+	c2s, s2c := newPipe(), newPipe()
+	client := newConn(memAddr("client"), memAddr("server"), s2c, c2s, opts)
+	server := newConn(memAddr("server"), memAddr("client"), c2s, s2c, &Options{})
+	return client, server
+}
+
+func TestDeadline(t *testing.T) {
+	client, server := newTestConns(&Options{})
+	defer client.Close()
+
+	// 阻塞中的读操作在deadline到达后返回
+	start := time.Now()
+	_ = server.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := server.Read(make([]byte, 8)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("read should timeout")
+	}
+
+	// 清除deadline后可以继续读取
+	_ = server.SetReadDeadline(time.Time{})
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 8)
+	if n, err := server.Read(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("bad read, %q %v", buf[:n], err)
+	}
+
+	_ = client.SetWriteDeadline(time.Now().Add(-time.Second))
+	if _, err := client.Write([]byte("ping")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expect write deadline exceeded, %v", err)
+	}
+}
+
+// TestDropRate 丢包只增加延迟,数据完整有序
+func TestDropRate(t *testing.T) {
+	client, server := newTestConns(&Options{DropRate: 0.2})
+	expect := bytes.Buffer{}
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		data := []byte{byte(i), byte(i), byte(i)}
+		if _, err = client.Write(data); err == nil {
+			expect.Write(data)
+		}
+	}
+	if err != nil && err != ErrReset {
+		t.Fatal(err)
+	}
+	_ = client.Close()
+
+	got, rerr := io.ReadAll(server)
+	if err == ErrReset {
+		// 连续丢包导致连接重置,对端读取返回ErrReset
+		if rerr != ErrReset {
+			t.Fatalf("expect reset, %v", rerr)
+		}
+		return
+	}
+	if rerr != nil || !bytes.Equal(got, expect.Bytes()) {
+		t.Fatalf("stream corrupted, %v", rerr)
+	}
+}
+
+func TestReset(t *testing.T) {
+	client, server := newTestConns(&Options{DropRate: 1})
+	if _, err := client.Write([]byte("ping")); err != ErrReset {
+		t.Fatalf("expect reset, %v", err)
+	}
+	if _, err := server.Read(make([]byte, 8)); err != ErrReset {
+		t.Fatalf("expect reset, %v", err)
+	}
+}
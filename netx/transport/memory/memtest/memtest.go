@@ -0,0 +1,68 @@
+// Package memtest 基于内存transport的测试辅助函数,在同一进程内启动server和client
+//	svr := memtest.NewServer(t, "echo")
+//	svr.POST("/echo", onEcho)
+//	cli := memtest.NewClient(t)
+//	req.SetService(memtest.Addr("echo"))
+package memtest
+
+import (
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/discovery/static"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/transport/memory"
+)
+
+// starter server.New返回的server支持非阻塞启动和停止
+type starter interface {
+	Start() error
+	Stop() error
+}
+
+// Addr 返回name对应的内存地址,可直接作为请求的Service
+func Addr(name string) string {
+	return memory.Scheme + name
+}
+
+// NewServer 创建并启动监听mem://name的server,测试结束时自动停止
+//	路由支持运行时注册,可在启动后再添加
+func NewServer(tb testing.TB, name string, opts ...server.Option) netx.Server {
+	tb.Helper()
+	opts = append([]server.Option{
+		server.WithAddr(Addr(name)),
+		server.WithTranFactory(memory.Factory()),
+	}, opts...)
+
+	svr := server.New(opts...)
+	s, ok := svr.(starter)
+	if !ok {
+		tb.Fatalf("memtest: server can not start")
+	}
+
+	if err := s.Start(); err != nil {
+		tb.Fatalf("memtest: start server fail, %+v", err)
+	}
+	tb.Cleanup(func() {
+		_ = s.Stop()
+	})
+
+	return svr
+}
+
+// NewClient 创建使用内存transport的client,默认使用static服务发现,Service即为内存地址
+func NewClient(tb testing.TB, opts ...client.Option) netx.Client {
+	tb.Helper()
+	opts = append([]client.Option{
+		client.WithResolver(static.New()),
+		client.WithTranFactory(memory.Factory()),
+	}, opts...)
+
+	cli := client.New(opts...)
+	tb.Cleanup(func() {
+		_ = cli.Close()
+	})
+
+	return cli
+}
@@ -0,0 +1,91 @@
+package memtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/client"
+	"github.com/foredata/nova/netx/compress"
+	"github.com/foredata/nova/netx/protocol/rpc"
+)
+
+type echoRequest struct {
+	Text string `json:"text"`
+}
+
+type echoResponse struct {
+	Text string `json:"text"`
+}
+
+func TestEcho(t *testing.T) {
+	svr := NewServer(t, "memtest.echo")
+	svr.Register(&netx.Route{Name: "echo", Handler: func(ctx context.Context, req *echoRequest) (*echoResponse, error) {
+		return &echoResponse{Text: "echo:" + req.Text}, nil
+	}})
+
+	cli := NewClient(t, client.WithProtocol(rpc.New()))
+	for i := 0; i < 3; i++ {
+		req := netx.NewRequest()
+		req.SetService(Addr("memtest.echo"))
+		req.SetURI("echo")
+		if err := req.Encode(netx.CodecTypeJson, &echoRequest{Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := cli.Call(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := &echoResponse{}
+		if err := rsp.Decode(out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Text != "echo:hi" {
+			t.Fatalf("invalid response, %+v", out)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	text := strings.Repeat("compress", 256)
+	svr := NewServer(t, "memtest.compress")
+	svr.Register(&netx.Route{Name: "echo", Handler: func(ctx context.Context, req netx.Request) (netx.Response, error) {
+		if req.Compress() != compress.TypeSnappy {
+			t.Errorf("request not compressed, %d", req.Compress())
+		}
+		in := &echoRequest{}
+		if err := req.Decode(in); err != nil {
+			return nil, err
+		}
+		rsp := netx.NewResponse()
+		err := rsp.Encode(netx.CodecTypeJson, &echoResponse{Text: in.Text})
+		return rsp, err
+	}})
+
+	cli := NewClient(t, client.WithProtocol(rpc.New()), client.WithCompress(compress.TypeSnappy, 0))
+	req := netx.NewRequest()
+	req.SetService(Addr("memtest.compress"))
+	req.SetURI("echo")
+	if err := req.Encode(netx.CodecTypeJson, &echoRequest{Text: text}); err != nil {
+		t.Fatal(err)
+	}
+
+	rsp, err := cli.Call(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Compress() != compress.TypeSnappy {
+		t.Errorf("response not compressed, %d", rsp.Compress())
+	}
+
+	out := &echoResponse{}
+	if err := rsp.Decode(out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Text != text {
+		t.Fatalf("invalid response, %d", len(out.Text))
+	}
+}
@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/foredata/nova/netx"
+)
+
+// some error
+var (
+	ErrAddrInUse   = errors.New("memory: address already in use")
+	ErrConnRefused = errors.New("memory: connection refused")
+)
+
+const backlog = 128
+
+// gNetwork 进程内网络,按名字索引listener
+var gNetwork = &network{listeners: make(map[string]*listener)}
+
+var gMaxPort uint32
+
+type network struct {
+	mux       sync.Mutex
+	listeners map[string]*listener
+}
+
+func (n *network) add(l *listener) error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if _, ok := n.listeners[l.name]; ok {
+		return ErrAddrInUse
+	}
+	n.listeners[l.name] = l
+	return nil
+}
+
+func (n *network) remove(l *listener) {
+	n.mux.Lock()
+	if n.listeners[l.name] == l {
+		delete(n.listeners, l.name)
+	}
+	n.mux.Unlock()
+}
+
+func (n *network) get(name string) *listener {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return n.listeners[name]
+}
+
+// toName 去掉地址前缀
+func toName(addr string) string {
+	return strings.TrimPrefix(addr, Scheme)
+}
+
+func (t *memTran) listen(addr string, o *netx.Options) (net.Listener, error) {
+	l := &listener{
+		name:   toName(addr),
+		opts:   t.opts,
+		accept: make(chan net.Conn, backlog),
+		done:   make(chan struct{}),
+	}
+	if err := gNetwork.add(l); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (t *memTran) dial(addr string, o *netx.Options) (net.Conn, error) {
+	l := gNetwork.get(toName(addr))
+	if l == nil {
+		return nil, ErrConnRefused
+	}
+
+	// 客户端使用随机端口区分不同连接
+	local := memAddr(l.name + ":" + strconv.FormatUint(uint64(atomic.AddUint32(&gMaxPort, 1)), 10))
+	remote := memAddr(l.name)
+	c2s := newPipe()
+	s2c := newPipe()
+	client := newConn(local, remote, s2c, c2s, t.opts)
+	server := newConn(remote, local, c2s, s2c, l.opts)
+	if err := l.deliver(server); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+type listener struct {
+	name   string
+	opts   *Options
+	accept chan net.Conn
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (l *listener) deliver(c net.Conn) error {
+	select {
+	case l.accept <- c:
+		return nil
+	case <-l.done:
+		return ErrConnRefused
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.once.Do(func() {
+		gNetwork.remove(l)
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return memAddr(l.name)
+}
+
+// memAddr 实现net.Addr
+type memAddr string
+
+func (a memAddr) Network() string {
+	return "memory"
+}
+
+func (a memAddr) String() string {
+	return Scheme + string(a)
+}
@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"time"
+)
+
+// Options 链路模拟配置,作用于本端写出的数据
+type Options struct {
+	Latency   time.Duration // 单向延迟
+	Bandwidth int           // 带宽限制,字节/秒,0表示不限制
+	DropRate  float64       // 丢包率[0,1],按tcp语义模拟为重传延迟,连续丢包过多时重置连接,数据不会丢失或乱序
+}
+
+type Option func(o *Options)
+
+// WithLatency 设置单向延迟
+func WithLatency(d time.Duration) Option {
+	return func(o *Options) {
+		o.Latency = d
+	}
+}
+
+// WithBandwidth 设置带宽限制,字节/秒
+func WithBandwidth(n int) Option {
+	return func(o *Options) {
+		o.Bandwidth = n
+	}
+}
+
+// WithDropRate 设置丢包率,每次丢包增加指数退避的重传延迟(首次200ms),连续丢包5次以上时连接被重置
+func WithDropRate(rate float64) Option {
+	return func(o *Options) {
+		o.DropRate = rate
+	}
+}
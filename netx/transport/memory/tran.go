@@ -0,0 +1,48 @@
+// Package memory 进程内transport,不占用端口,用于编写隔离的单元测试
+//	Listen("mem://name")注册到进程内网络,Dial("mem://name")时创建一对内存连接
+//	连接读写及FilterChain复用gpc实现,可选模拟延迟,带宽及丢包
+package memory
+
+import (
+	"github.com/foredata/nova/netx"
+	"github.com/foredata/nova/netx/transport/gpc"
+)
+
+// Scheme 地址前缀
+const Scheme = "mem://"
+
+// New 创建内存transport
+func New(opts ...Option) netx.Tran {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &memTran{Tran: gpc.New(), opts: o}
+}
+
+// Factory 返回创建内存transport的Factory,可用于server.WithTranFactory,client.WithTranFactory
+func Factory(opts ...Option) netx.Factory {
+	return func() netx.Tran {
+		return New(opts...)
+	}
+}
+
+type memTran struct {
+	netx.Tran
+	opts *Options
+}
+
+func (t *memTran) String() string {
+	return "memory"
+}
+
+func (t *memTran) Listen(addr string, opts ...netx.Option) (netx.Listener, error) {
+	opts = append(opts, netx.WithListen(t.listen))
+	return t.Tran.Listen(addr, opts...)
+}
+
+func (t *memTran) Dial(addr string, opts ...netx.Option) (netx.Conn, error) {
+	opts = append(opts, netx.WithDial(t.dial))
+	return t.Tran.Dial(addr, opts...)
+}
@@ -172,4 +172,5 @@ func (c *nioConn) doClose(err error) {
 		c.fd = 0
 		c.conn = nil
 	}
+	c.GetChain().HandleClose(c)
 }
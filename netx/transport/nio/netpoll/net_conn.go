@@ -33,8 +33,21 @@ func (c *netConn) Read(p []byte) (int, error) {
 	return syscall.Read(c.fd, p)
 }
 
+// Write 非阻塞写,缓冲区已满只写入部分数据时返回syscall.EAGAIN,等待可写后从中断处继续
 func (c *netConn) Write(p []byte) (int, error) {
-	return syscall.Write(c.fd, p)
+	for {
+		n, err := syscall.Write(c.fd, p)
+		if err == syscall.EINTR {
+			continue
+		}
+		if n < 0 {
+			n = 0
+		}
+		if err == nil && n < len(p) {
+			err = syscall.EAGAIN
+		}
+		return n, err
+	}
 }
 
 func (c *netConn) Close() error {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync/atomic"
 	"time"
+
+	"github.com/foredata/nova/netx/compress"
 )
 
 var seqIdMax uint32
@@ -20,10 +22,12 @@ func NewSeqID() uint32 {
 
 // CallOptions Call时可选参数
 type CallOptions struct {
-	DialTimeout time.Duration // 连接超时
-	CallTimeout time.Duration //
-	Callback    interface{}   // 异步回调函数
-	RetryPolicy RetryPolicy   // 重试策略
+	DialTimeout       time.Duration // 连接超时
+	CallTimeout       time.Duration //
+	Callback          interface{}   // 异步回调函数
+	RetryPolicy       RetryPolicy   // 重试策略
+	Compress          compress.Type // 请求压缩方式
+	CompressThreshold int           // body不小于该值时才压缩请求
 }
 
 // CallOption .
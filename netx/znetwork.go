@@ -98,7 +98,7 @@ type Filter interface {
 	HandleRead(ctx FilterCtx) error
 	HandleWrite(ctx FilterCtx) error
 	HandleOpen(ctx FilterCtx) error
-	HandleClose(ctx FilterCtx) error
+	HandleClose(ctx FilterCtx) error // 连接关闭后调用,每个连接只会调用一次,此时已无法再读写数据
 	HandleError(ctx FilterCtx) error
 }
 
@@ -3,18 +3,61 @@ package netx
 import (
 	"context"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/foredata/nova/netx/body"
+	"github.com/foredata/nova/netx/compress"
 	"github.com/foredata/nova/netx/metadata"
 	"github.com/foredata/nova/pkg/bytex"
 )
 
 // 常见Header
 const (
-	XLogId   = "X-Log-Id"
-	XTraceId = "X-Trace-Id"
+	XLogId    = "X-Log-Id"
+	XTraceId  = "X-Trace-Id"
+	XTimeout  = "X-Timeout"  // 请求超时时间,单位毫秒,server据此计算deadline
+	XPriority = "X-Priority" // 请求优先级,用于过载时优先丢弃低优先级请求
+
+	XAcceptCompress = "X-Accept-Compress" // 可接受的压缩方式,逗号分隔,server据此压缩应答
 )
 
+// GetTimeout 从header中解析请求超时时间,不存在或非法返回0
+func GetTimeout(h Header) time.Duration {
+	v := h.Get(XTimeout)
+	if v == "" {
+		return 0
+	}
+
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0
+	}
+
+	return time.Duration(ms) * time.Millisecond
+}
+
+// SetTimeout 设置请求超时时间到header中
+func SetTimeout(h *Header, d time.Duration) {
+	h.Set(XTimeout, strconv.FormatInt(int64(d/time.Millisecond), 10))
+}
+
+// GetHeader 大小写不敏感查询header,http协议中header大小写并不固定
+func GetHeader(h Header, key string) string {
+	if v := h.Get(key); v != "" {
+		return v
+	}
+
+	for _, kv := range h {
+		if strings.EqualFold(kv.Key, key) && len(kv.Values) > 0 {
+			return kv.Values[0]
+		}
+	}
+
+	return ""
+}
+
 type Header = metadata.Metadata
 
 func NewHeader() Header {
@@ -38,19 +81,20 @@ const (
 
 // Identifier 消息标识,只会在header中使用
 type Identifier struct {
-	Version    uint     // 版本信息,对于http1则对应[09,10,11]
-	IsResponse bool     // 是否是应答消息
-	IsOneway   bool     // 是否需要应答
-	SeqID      uint32   // 动态唯一ID,用于查询Response回调
-	CmdID      uint32   // CmdID,用于查询Request回调
-	Method     Method   // http method
-	Service    string   // http host
-	URI        string   // http URI
-	Codec      uint32   // payload编码,区别于Content-Type,Codec只支持有限的编码方式
-	StatusCode int32    // response status code
-	StatusInfo string   // response status info
-	Params     Params   // 从path中解析获得的参数
-	url        *url.URL // 解析uri获得
+	Version    uint          // 版本信息,对于http1则对应[09,10,11]
+	IsResponse bool          // 是否是应答消息
+	IsOneway   bool          // 是否需要应答
+	SeqID      uint32        // 动态唯一ID,用于查询Response回调
+	CmdID      uint32        // CmdID,用于查询Request回调
+	Method     Method        // http method
+	Service    string        // http host
+	URI        string        // http URI
+	Codec      uint32        // payload编码,区别于Content-Type,Codec只支持有限的编码方式
+	Compress   compress.Type // payload压缩方式,协议编码时压缩,解码后payload已是解压后的数据
+	StatusCode int32         // response status code
+	StatusInfo string        // response status info
+	Params     Params        // 从path中解析获得的参数
+	url        *url.URL      // 解析uri获得
 }
 
 func (ident *Identifier) URL() *url.URL {
@@ -150,6 +194,8 @@ type Request interface {
 	SetSeqID(v uint32)
 	Codec() uint32
 	SetCodec(v uint32)
+	Compress() compress.Type
+	SetCompress(v compress.Type)
 	IsOneway() bool
 	SetOneway(v bool)
 	CmdID() uint32
@@ -181,6 +227,8 @@ type Response interface {
 	SetSeqID(v uint32)
 	Codec() uint32
 	SetCodec(v uint32)
+	Compress() compress.Type
+	SetCompress(v compress.Type)
 	StatusCode() int32
 	StatusInfo() string
 	SetStatus(code int32, info string)
@@ -206,6 +254,19 @@ type Protocol interface {
 	Encode(conn Conn, frame Frame) (bytex.Buffer, error)
 }
 
+// RawEncoder 可选接口,协议支持消息体不经编码原样发送时实现,例如http1已知Content-Length时
+//	用于sendfile等零拷贝发送文件,EncodeHeader只编码header帧,不包含payload
+//	size为消息体长度,ok为false表示当前消息不支持,需要按普通方式编码
+type RawEncoder interface {
+	EncodeHeader(conn Conn, frame Frame, size int64) (buf bytex.Buffer, ok bool, err error)
+}
+
+// Pinger 可选接口,协议支持心跳时实现,连接写空闲时发送心跳探测对端是否存活
+//	对端协议层收到心跳后自动应答,应答在Decode中消耗,不会产生Frame
+type Pinger interface {
+	Ping(conn Conn) error
+}
+
 // Detector 用于自动探测协议,某些协议有magic number,可以方便的感知协议类型,某些则不支持
 //	服务端需要探测协议,但仅需要探测一次即可,便于自动识别http,dubbo,grpc等协议
 //	客户端则不需要探测协议,因为调用方是知道使用哪种协议
@@ -43,7 +43,7 @@ type Route struct {
 	CmdID       uint              // 不宜过大尽量保持在uint16以内,底层数组存储
 	Metadata    map[string]string // 自定义字段,可用于服务发现中注册额外字段
 	Handler     interface{}       // 原始Handler,see handler.go中toEndpoint原型定义
-	Middlewares []Middleware      // 中间件
+	Middlewares []Middleware      // 中间件,不包含Server.Use注册的全局中间件
 	Callback    Callback          // Handler经过middleware加工后,转换成callback
 }
 
@@ -54,3 +54,10 @@ type Router interface {
 	NoRoute(callback Callback)
 	Find(packet Packet) Callback
 }
+
+// RouteUpdater 支持运行时增删路由,Server和Router可选实现
+//	实现需保证运行时增删路由与Find并发安全,不支持时返回ErrNotSupport
+type RouteUpdater interface {
+	Unregister(route *Route) bool      // 删除router
+	Update(remove, add []*Route) error // 原子批量删除和添加router
+}
@@ -235,7 +235,8 @@ func (b *bcursor) Reset(n *bnode, offs int) {
 
 // Skip 如果index到达结尾,则自动跳转到下一个节点
 func (b *bcursor) Skip() {
-	if b.offs == len(b.node.data) && b.node.next != nil {
+	// 空buffer时node为nil
+	if b.node != nil && b.offs == len(b.node.data) && b.node.next != nil {
 		b.node = b.node.next
 		b.offs = 0
 	}
@@ -703,15 +704,19 @@ func (b *buffer) freeUnusedBytes() {
 		x := len(node.data)
 		if x > n {
 			node.data = node.data[:x-n]
-			b.tail = node
 			break
 		}
 
 		n -= x
 		t := node
 		node = node.prev
+		t.prev = nil
 		t.Free()
 	}
+
+	node.next = nil
+	b.tail = node
+	b.cap = b.len
 }
 
 // grow 扩容size个字节
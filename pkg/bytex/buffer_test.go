@@ -53,3 +53,38 @@ func TestReadLine(t *testing.T) {
 		t.Log(line.String())
 	}
 }
+
+// TestAppendAfterGrow 末尾空闲节点被释放后,tail需要指向最后一个有效节点
+func TestAppendAfterGrow(t *testing.T) {
+	SetChunkSize(4)
+	b := newBuffer()
+	_, _ = b.Write([]byte("abcd"))
+	b.grow(4)
+	b.grow(4)
+	if err := b.Append("xy"); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "abcdxy" || b.Len() != 6 {
+		t.Fatalf("bad append, %q", b.String())
+	}
+	if err := b.Append("z"); err != nil || b.String() != "abcdxyz" {
+		t.Fatalf("bad append, %q", b.String())
+	}
+}
+
+func TestReadLineEmpty(t *testing.T) {
+	b := newBuffer()
+	if line := b.ReadLine(); line != nil {
+		t.Errorf("empty buffer should not return line")
+	}
+
+	_ = b.Append("a\r\n")
+	_, _ = b.Seek(0, io.SeekStart)
+	if line := b.ReadLine(); line == nil || line.String() != "a" {
+		t.Fatalf("bad line")
+	}
+	b.Discard()
+	if line := b.ReadLine(); line != nil {
+		t.Errorf("discarded buffer should not return line")
+	}
+}
@@ -0,0 +1,185 @@
+// Package concurrency 自适应并发限制
+//	根据观测到的延迟动态调整最大并发数,算法参考netflix concurrency-limits
+//	Vegas: 根据最小延迟估算排队长度,排队较少时增加,较多时减小
+//	Gradient: 根据长期延迟与短期延迟的比值(梯度)调整
+//	过载时按照优先级丢弃请求,低优先级请求只能使用部分并发额度
+package concurrency
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Algorithm 并发限制算法
+type Algorithm interface {
+	// Limit 当前最大并发数
+	Limit() int
+	// Update 请求完成后更新,rtt为处理耗时,inflight为请求开始时的并发数,dropped表示请求超时或被丢弃
+	Update(rtt time.Duration, inflight int, dropped bool)
+}
+
+// Priority 请求优先级
+type Priority int
+
+const (
+	PriorityLow      Priority = iota // 可丢弃,例如离线任务,预加载
+	PriorityNormal                   // 默认
+	PriorityHigh                     // 重要请求
+	PriorityCritical                 // 核心请求,例如支付,健康检查
+)
+
+// ParsePriority 解析优先级,支持名字或数字,非法时返回PriorityNormal
+func ParsePriority(s string) Priority {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return PriorityLow
+	case "normal", "":
+		return PriorityNormal
+	case "high":
+		return PriorityHigh
+	case "critical":
+		return PriorityCritical
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < int(PriorityLow) {
+		return PriorityNormal
+	}
+	if n > int(PriorityCritical) {
+		return PriorityCritical
+	}
+
+	return Priority(n)
+}
+
+// Options 限流器配置
+type Options struct {
+	Algorithm  Algorithm  // 算法,默认Gradient
+	Thresholds [4]float64 // 各优先级可使用的并发比例,默认Low:0.5,Normal:0.8,High:0.95,Critical:1.0
+}
+
+type Option func(o *Options)
+
+// WithAlgorithm 设置算法
+func WithAlgorithm(a Algorithm) Option {
+	return func(o *Options) {
+		o.Algorithm = a
+	}
+}
+
+// WithThreshold 设置优先级可使用的并发比例,非法优先级忽略,比例限制在[0,1]范围内
+func WithThreshold(p Priority, ratio float64) Option {
+	return func(o *Options) {
+		if p < PriorityLow || p > PriorityCritical {
+			return
+		}
+		o.Thresholds[p] = clamp(ratio, 0, 1)
+	}
+}
+
+// New 创建并发限制器
+func New(opts ...Option) *Limiter {
+	o := &Options{Thresholds: [4]float64{0.5, 0.8, 0.95, 1.0}}
+	for _, fn := range opts {
+		fn(o)
+	}
+	if o.Algorithm == nil {
+		o.Algorithm = NewGradient(nil)
+	}
+
+	return &Limiter{opts: o}
+}
+
+// Limiter 并发限制器
+type Limiter struct {
+	opts     *Options
+	inflight int64
+}
+
+// Limit 当前最大并发数
+func (l *Limiter) Limit() int {
+	return l.opts.Algorithm.Limit()
+}
+
+// Inflight 当前并发数
+func (l *Limiter) Inflight() int {
+	return int(atomic.LoadInt64(&l.inflight))
+}
+
+// Acquire 尝试获取许可,失败返回nil,成功后必须调用Token的Success,Dropped或Ignore之一
+func (l *Limiter) Acquire(p Priority) *Token {
+	if p < PriorityLow {
+		p = PriorityLow
+	} else if p > PriorityCritical {
+		p = PriorityCritical
+	}
+
+	limit := float64(l.opts.Algorithm.Limit()) * l.opts.Thresholds[p]
+	if limit < 1 {
+		limit = 1
+	}
+
+	inflight := atomic.AddInt64(&l.inflight, 1)
+	if float64(inflight) > limit {
+		atomic.AddInt64(&l.inflight, -1)
+		return nil
+	}
+
+	t := gTokenPool.Get().(*Token)
+	t.owner = l
+	t.start = time.Now()
+	t.inflight = int(inflight)
+	return t
+}
+
+var gTokenPool = sync.Pool{
+	New: func() interface{} {
+		return &Token{}
+	},
+}
+
+// Token 许可,用于请求结束时反馈结果
+type Token struct {
+	owner    *Limiter
+	start    time.Time
+	inflight int
+}
+
+// Success 请求正常完成,使用耗时更新限制
+func (t *Token) Success() {
+	t.release(true, false)
+}
+
+// Dropped 请求超时或因过载失败,算法会减小并发限制
+func (t *Token) Dropped() {
+	t.release(true, true)
+}
+
+// Ignore 请求失败但与负载无关,例如参数错误,不更新算法
+func (t *Token) Ignore() {
+	t.release(false, false)
+}
+
+func (t *Token) release(update bool, dropped bool) {
+	l := t.owner
+	atomic.AddInt64(&l.inflight, -1)
+	if update {
+		l.opts.Algorithm.Update(time.Since(t.start), t.inflight, dropped)
+	}
+	t.owner = nil
+	gTokenPool.Put(t)
+}
+
+// clamp 限制在[min,max]范围内
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
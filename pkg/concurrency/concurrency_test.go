@@ -0,0 +1,88 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriority(t *testing.T) {
+	l := New(WithAlgorithm(NewVegas(&VegasOptions{InitialLimit: 10})))
+	tokens := make([]*Token, 0)
+	// Low最多使用50%
+	for i := 0; i < 5; i++ {
+		tk := l.Acquire(PriorityLow)
+		if tk == nil {
+			t.Fatalf("expect acquired, %d", i)
+		}
+		tokens = append(tokens, tk)
+	}
+	if l.Acquire(PriorityLow) != nil {
+		t.Fatal("expect low priority rejected")
+	}
+
+	for i := 0; i < 5; i++ {
+		tk := l.Acquire(PriorityCritical)
+		if tk == nil {
+			t.Fatalf("expect critical acquired, %d", i)
+		}
+		tokens = append(tokens, tk)
+	}
+	if l.Acquire(PriorityCritical) != nil {
+		t.Fatal("expect rejected when full")
+	}
+
+	for _, tk := range tokens {
+		tk.Ignore()
+	}
+	if l.Inflight() != 0 {
+		t.Fatalf("bad inflight, %d", l.Inflight())
+	}
+}
+
+func TestGradient(t *testing.T) {
+	g := NewGradient(&GradientOptions{InitialLimit: 100, LongWindow: 10})
+	for i := 0; i < 10; i++ {
+		g.Update(10*time.Millisecond, 100, false)
+	}
+	base := g.Limit()
+
+	// 延迟大幅升高,limit应该下降
+	for i := 0; i < 3; i++ {
+		g.Update(100*time.Millisecond, 100, false)
+	}
+	if g.Limit() >= base {
+		t.Fatalf("expect limit decreased, %d >= %d", g.Limit(), base)
+	}
+
+	before := g.Limit()
+	g.Update(time.Millisecond, before, true)
+	if g.Limit() >= before {
+		t.Fatal("expect limit decreased when dropped")
+	}
+}
+
+func TestVegas(t *testing.T) {
+	v := NewVegas(&VegasOptions{InitialLimit: 20})
+	v.Update(10*time.Millisecond, 20, false)
+	for i := 0; i < 10; i++ {
+		v.Update(10*time.Millisecond, 20, false)
+	}
+	if v.Limit() <= 20 {
+		t.Fatalf("expect limit increased, %d", v.Limit())
+	}
+
+	before := v.Limit()
+	for i := 0; i < 10; i++ {
+		v.Update(100*time.Millisecond, before, false)
+	}
+	if v.Limit() >= before {
+		t.Fatalf("expect limit decreased, %d", v.Limit())
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	l := New(WithThreshold(Priority(10), 0.1), WithThreshold(-1, 0.1), WithThreshold(PriorityLow, 2))
+	if l.opts.Thresholds[PriorityLow] != 1 || l.opts.Thresholds[PriorityCritical] != 1 {
+		t.Fatalf("bad thresholds, %v", l.opts.Thresholds)
+	}
+}
@@ -0,0 +1,102 @@
+package concurrency
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// GradientOptions Gradient算法配置
+type GradientOptions struct {
+	InitialLimit int     // 初始并发数,默认20
+	MinLimit     int     // 最小并发数,默认1
+	MaxLimit     int     // 最大并发数,默认1000
+	Smoothing    float64 // 平滑系数(0,1],默认0.2
+	Tolerance    float64 // 允许长期延迟增长的倍数,默认1.5
+	LongWindow   int     // 长期延迟指数平均的窗口大小,默认600
+}
+
+// NewGradient 创建Gradient算法
+//	gradient = clamp(tolerance * longRTT / shortRTT, 0.5, 1)
+//	limit = limit * gradient + sqrt(limit)
+//	延迟增加时gradient小于1,limit随之减小,sqrt(limit)作为允许的排队长度
+func NewGradient(opts *GradientOptions) Algorithm {
+	o := GradientOptions{InitialLimit: 20, MinLimit: 1, MaxLimit: 1000, Smoothing: 0.2, Tolerance: 1.5, LongWindow: 600}
+	if x := opts; x != nil {
+		if x.InitialLimit > 0 {
+			o.InitialLimit = x.InitialLimit
+		}
+		if x.MinLimit > 0 {
+			o.MinLimit = x.MinLimit
+		}
+		if x.MaxLimit > 0 {
+			o.MaxLimit = x.MaxLimit
+		}
+		if x.Smoothing > 0 && x.Smoothing <= 1 {
+			o.Smoothing = x.Smoothing
+		}
+		if x.Tolerance >= 1 {
+			o.Tolerance = x.Tolerance
+		}
+		if x.LongWindow > 0 {
+			o.LongWindow = x.LongWindow
+		}
+	}
+
+	return &gradient{opts: o, limit: float64(o.InitialLimit)}
+}
+
+type gradient struct {
+	mux     sync.Mutex
+	opts    GradientOptions
+	limit   float64
+	longRTT float64 // 长期延迟,指数移动平均
+	samples int
+}
+
+func (g *gradient) Limit() int {
+	g.mux.Lock()
+	l := int(g.limit)
+	g.mux.Unlock()
+	return l
+}
+
+func (g *gradient) Update(rtt time.Duration, inflight int, dropped bool) {
+	if rtt <= 0 {
+		return
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	short := float64(rtt)
+	if g.samples < g.opts.LongWindow {
+		// 预热阶段使用算术平均
+		g.samples++
+		g.longRTT += (short - g.longRTT) / float64(g.samples)
+	} else {
+		factor := 2 / float64(g.opts.LongWindow+1)
+		g.longRTT = g.longRTT*(1-factor) + short*factor
+	}
+
+	// 长期延迟远高于当前延迟,说明负载已经恢复,加速收敛
+	if g.longRTT/short > 2 {
+		g.longRTT *= 0.95
+	}
+
+	limit := g.limit
+	if !dropped && float64(inflight) < limit/2 {
+		return
+	}
+
+	var next float64
+	if dropped {
+		next = limit / 2
+	} else {
+		grad := clamp(g.opts.Tolerance*g.longRTT/short, 0.5, 1)
+		next = limit*grad + math.Sqrt(limit)
+	}
+
+	next = (1-g.opts.Smoothing)*limit + g.opts.Smoothing*next
+	g.limit = clamp(next, float64(g.opts.MinLimit), float64(g.opts.MaxLimit))
+}
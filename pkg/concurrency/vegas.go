@@ -0,0 +1,104 @@
+package concurrency
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// VegasOptions Vegas算法配置
+type VegasOptions struct {
+	InitialLimit int     // 初始并发数,默认20
+	MinLimit     int     // 最小并发数,默认1
+	MaxLimit     int     // 最大并发数,默认1000
+	Smoothing    float64 // 平滑系数(0,1],默认1,即不平滑
+	ProbeCount   int     // 每隔多少次更新重置最小延迟,避免最小延迟长期失真,默认1000
+}
+
+// NewVegas 创建Vegas算法
+//	queue = limit * (1 - minRTT/rtt)
+//	queue < alpha时增加limit,queue > beta时减小limit,alpha与beta均与log10(limit)成正比
+func NewVegas(opts *VegasOptions) Algorithm {
+	o := VegasOptions{InitialLimit: 20, MinLimit: 1, MaxLimit: 1000, Smoothing: 1, ProbeCount: 1000}
+	if opts != nil {
+		if opts.InitialLimit > 0 {
+			o.InitialLimit = opts.InitialLimit
+		}
+		if opts.MinLimit > 0 {
+			o.MinLimit = opts.MinLimit
+		}
+		if opts.MaxLimit > 0 {
+			o.MaxLimit = opts.MaxLimit
+		}
+		if opts.Smoothing > 0 && opts.Smoothing <= 1 {
+			o.Smoothing = opts.Smoothing
+		}
+		if opts.ProbeCount > 0 {
+			o.ProbeCount = opts.ProbeCount
+		}
+	}
+
+	return &vegas{opts: o, limit: float64(o.InitialLimit)}
+}
+
+type vegas struct {
+	mux    sync.Mutex
+	opts   VegasOptions
+	limit  float64
+	minRTT time.Duration
+	count  int
+}
+
+func (v *vegas) Limit() int {
+	v.mux.Lock()
+	l := int(v.limit)
+	v.mux.Unlock()
+	return l
+}
+
+func (v *vegas) Update(rtt time.Duration, inflight int, dropped bool) {
+	if rtt <= 0 {
+		return
+	}
+
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	v.count++
+	if v.count >= v.opts.ProbeCount {
+		// 周期性重置,重新探测无负载延迟
+		v.count = 0
+		v.minRTT = 0
+	}
+
+	if v.minRTT == 0 || rtt < v.minRTT {
+		v.minRTT = rtt
+		return
+	}
+
+	limit := v.limit
+	log := math.Max(1, math.Log10(limit))
+	alpha := 3 * log
+	beta := 6 * log
+	queue := math.Ceil(limit * (1 - float64(v.minRTT)/float64(rtt)))
+
+	var next float64
+	switch {
+	case dropped:
+		next = limit - log
+	case float64(inflight)*2 < limit:
+		// 负载较低时延迟不具备参考意义
+		return
+	case queue <= log:
+		next = limit + beta
+	case queue < alpha:
+		next = limit + log
+	case queue > beta:
+		next = limit - log
+	default:
+		return
+	}
+
+	next = clamp(next, float64(v.opts.MinLimit), float64(v.opts.MaxLimit))
+	v.limit = (1-v.opts.Smoothing)*limit + v.opts.Smoothing*next
+}
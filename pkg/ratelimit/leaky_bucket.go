@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// NewLeakyBucket 创建漏桶,请求以固定速率rate(每秒)流出,capacity为最多可排队的请求数
+//	与令牌桶不同,漏桶会平滑流量,允许的请求可能需要等待Result.Delay后再处理
+func NewLeakyBucket(rate float64, capacity int) Limiter {
+	return &leakyBucket{interval: durationOf(1 / rate), capacity: capacity, states: newKeyedState(0)}
+}
+
+type leakyBucket struct {
+	interval time.Duration // 每个请求流出的间隔
+	capacity int
+	states   *keyedState
+}
+
+type leakyState struct {
+	next time.Time // 队列中最后一个请求流出的时间
+}
+
+func (b *leakyBucket) Allow(ctx context.Context, key string, n int) (*Result, error) {
+	if n > b.capacity {
+		return nil, ErrExceedsBurst
+	}
+
+	now := Now()
+	res := &Result{Limit: b.capacity}
+	b.states.do(key, now, func() interface{} {
+		return &leakyState{}
+	}, func(x interface{}) {
+		s := x.(*leakyState)
+		start := s.next
+		if start.Before(now) {
+			start = now
+		}
+
+		next := start.Add(time.Duration(n) * b.interval)
+		// 当前排队中的请求数
+		level := int((next.Sub(now) + b.interval - 1) / b.interval)
+		if level > b.capacity {
+			res.RetryAfter = next.Sub(now) - time.Duration(b.capacity)*b.interval
+			res.Remaining = 0
+			return
+		}
+
+		s.next = next
+		res.Allowed = true
+		res.Remaining = b.capacity - level
+		res.Delay = start.Sub(now)
+	})
+
+	return res, nil
+}
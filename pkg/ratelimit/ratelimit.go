@@ -0,0 +1,87 @@
+// Package ratelimit 限流器
+//	单机: 令牌桶(TokenBucket),漏桶(LeakyBucket),滑动窗口(SlidingWindow)
+//	分布式: 基于store.Store实现的滑动窗口及令牌桶
+//	所有Limiter均按key隔离,例如路由,IP,租户等
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrExceedsBurst 单次请求数超过桶容量,永远无法满足
+	ErrExceedsBurst = errors.New("ratelimit: n exceeds burst")
+	// ErrStoreBusy 分布式限流获取锁失败
+	ErrStoreBusy = errors.New("ratelimit: store busy")
+)
+
+// Result 限流结果
+type Result struct {
+	Allowed    bool          // 是否允许
+	Limit      int           // 限额,令牌桶为桶容量,滑动窗口为窗口内最大请求数
+	Remaining  int           // 剩余可用数
+	RetryAfter time.Duration // 被拒绝时建议的重试等待时间
+	Delay      time.Duration // 允许时需要等待的时间,仅漏桶会返回,用于流量整形
+}
+
+// Limiter 限流接口
+type Limiter interface {
+	// Allow 尝试为key获取n个许可
+	Allow(ctx context.Context, key string, n int) (*Result, error)
+}
+
+// Now 用于获取当前时间,测试时可以替换
+var Now = time.Now
+
+// defaultIdle 本地限流器中key闲置多久后回收
+const defaultIdle = 10 * time.Minute
+
+// keyedState 按key保存限流状态,并定期回收长时间未使用的key
+type keyedState struct {
+	mux       sync.Mutex
+	items     map[string]*stateItem
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+type stateItem struct {
+	state    interface{}
+	lastUsed time.Time
+}
+
+func newKeyedState(idle time.Duration) *keyedState {
+	if idle <= 0 {
+		idle = defaultIdle
+	}
+	return &keyedState{items: make(map[string]*stateItem), idle: idle}
+}
+
+// do 在锁内执行fn,state不存在时通过create创建
+func (k *keyedState) do(key string, now time.Time, create func() interface{}, fn func(state interface{})) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+
+	if now.Sub(k.lastSweep) > k.idle {
+		k.lastSweep = now
+		for key, it := range k.items {
+			if now.Sub(it.lastUsed) > k.idle {
+				delete(k.items, key)
+			}
+		}
+	}
+
+	it, ok := k.items[key]
+	if !ok {
+		it = &stateItem{state: create()}
+		k.items[key] = it
+	}
+	it.lastUsed = now
+	fn(it.state)
+}
+
+func durationOf(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
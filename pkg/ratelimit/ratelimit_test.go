@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/foredata/nova/store/memory"
+)
+
+func mockNow(t time.Time) func(d time.Duration) {
+	cur := t
+	Now = func() time.Time { return cur }
+	return func(d time.Duration) {
+		cur = cur.Add(d)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	advance := mockNow(time.Unix(1000, 0))
+	defer func() { Now = time.Now }()
+
+	l := NewTokenBucket(10, 5)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if res, _ := l.Allow(ctx, "a", 1); !res.Allowed {
+			t.Fatalf("expect allowed, %d", i)
+		}
+	}
+
+	res, _ := l.Allow(ctx, "a", 1)
+	if res.Allowed || res.RetryAfter != 100*time.Millisecond {
+		t.Fatalf("expect rejected, %+v", res)
+	}
+
+	// 其他key不受影响
+	if res, _ := l.Allow(ctx, "b", 1); !res.Allowed {
+		t.Fatal("expect allowed")
+	}
+
+	advance(100 * time.Millisecond)
+	if res, _ := l.Allow(ctx, "a", 1); !res.Allowed {
+		t.Fatal("expect allowed after refill")
+	}
+}
+
+func TestLeakyBucket(t *testing.T) {
+	mockNow(time.Unix(1000, 0))
+	defer func() { Now = time.Now }()
+
+	l := NewLeakyBucket(10, 3)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		res, _ := l.Allow(ctx, "a", 1)
+		if !res.Allowed || res.Delay != time.Duration(i)*100*time.Millisecond {
+			t.Fatalf("bad result, %d %+v", i, res)
+		}
+	}
+
+	if res, _ := l.Allow(ctx, "a", 1); res.Allowed {
+		t.Fatal("expect rejected")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	advance := mockNow(time.Unix(1000, 0))
+	defer func() { Now = time.Now }()
+
+	l := NewSlidingWindow(4, time.Second)
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		if res, _ := l.Allow(ctx, "a", 1); !res.Allowed {
+			t.Fatalf("expect allowed, %d", i)
+		}
+	}
+	if res, _ := l.Allow(ctx, "a", 1); res.Allowed {
+		t.Fatal("expect rejected")
+	}
+
+	// 下一个窗口的一半,上个窗口计数权重为0.5
+	advance(1500 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if res, _ := l.Allow(ctx, "a", 1); !res.Allowed {
+			t.Fatalf("expect allowed, %d", i)
+		}
+	}
+	if res, _ := l.Allow(ctx, "a", 1); res.Allowed {
+		t.Fatal("expect rejected")
+	}
+}
+
+func TestStoreLimiter(t *testing.T) {
+	advance := mockNow(time.Unix(1000, 0))
+	defer func() { Now = time.Now }()
+
+	ctx := context.Background()
+	s := memory.New()
+	w := NewStoreSlidingWindow(s, "rl:", 3, time.Minute)
+	for i := 0; i < 3; i++ {
+		if res, err := w.Allow(ctx, "a", 1); err != nil || !res.Allowed {
+			t.Fatalf("expect allowed, %d %+v", i, err)
+		}
+	}
+	if res, _ := w.Allow(ctx, "a", 1); res.Allowed || res.RetryAfter <= 0 {
+		t.Fatalf("expect rejected, %+v", res)
+	}
+
+	b := NewStoreTokenBucket(s, "tb:", 1, 2)
+	for i := 0; i < 2; i++ {
+		if res, err := b.Allow(ctx, "a", 1); err != nil || !res.Allowed {
+			t.Fatalf("expect allowed, %d %+v", i, err)
+		}
+	}
+	if res, _ := b.Allow(ctx, "a", 1); res.Allowed {
+		t.Fatal("expect rejected")
+	}
+	advance(time.Second)
+	if res, _ := b.Allow(ctx, "a", 1); !res.Allowed {
+		t.Fatal("expect allowed after refill")
+	}
+}
+
+func TestStoreUnlock(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	b := NewStoreTokenBucket(s, "tb:", 1, 2).(*storeBucket)
+
+	// 锁已过期并被其他请求获取,释放时不能删除
+	if err := b.lock(ctx, "k", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Put(ctx, "k", []byte("t2")); err != nil {
+		t.Fatal(err)
+	}
+	b.unlock(ctx, "k", "t1")
+	if data, err := s.Get(ctx, "k"); err != nil || string(data) != "t2" {
+		t.Fatalf("lock of other owner deleted, %s %v", data, err)
+	}
+	b.unlock(ctx, "k", "t2")
+	if ok, _ := s.Exists(ctx, "k"); ok {
+		t.Fatal("expect unlocked")
+	}
+}
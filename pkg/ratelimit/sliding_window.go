@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// NewSlidingWindow 创建滑动窗口限流,window内最多允许limit个请求
+//	使用当前窗口和上一个窗口的计数加权估算,内存占用固定
+func NewSlidingWindow(limit int, window time.Duration) Limiter {
+	return &slidingWindow{limit: limit, window: window, states: newKeyedState(0)}
+}
+
+type slidingWindow struct {
+	limit  int
+	window time.Duration
+	states *keyedState
+}
+
+type windowState struct {
+	start time.Time // 当前窗口起始时间
+	curr  int
+	prev  int
+}
+
+func (w *slidingWindow) Allow(ctx context.Context, key string, n int) (*Result, error) {
+	if n > w.limit {
+		return nil, ErrExceedsBurst
+	}
+
+	now := Now()
+	res := &Result{Limit: w.limit}
+	w.states.do(key, now, func() interface{} {
+		return &windowState{start: now.Truncate(w.window)}
+	}, func(x interface{}) {
+		s := x.(*windowState)
+		start := now.Truncate(w.window)
+		switch d := start.Sub(s.start); {
+		case d >= 2*w.window:
+			s.prev, s.curr = 0, 0
+		case d >= w.window:
+			s.prev, s.curr = s.curr, 0
+		}
+		s.start = start
+
+		count := estimate(s.prev, s.curr, now.Sub(start), w.window)
+		if count+float64(n) > float64(w.limit) {
+			res.RetryAfter = retryAfter(s.prev, s.curr, n, w.limit, now.Sub(start), w.window)
+			res.Remaining = remaining(w.limit, count)
+			return
+		}
+
+		s.curr += n
+		res.Allowed = true
+		res.Remaining = remaining(w.limit, count+float64(n))
+	})
+
+	return res, nil
+}
+
+// estimate 估算滑动窗口内的请求数
+func estimate(prev, curr int, elapsed, window time.Duration) float64 {
+	weight := 1 - float64(elapsed)/float64(window)
+	return float64(prev)*weight + float64(curr)
+}
+
+// retryAfter 计算上一个窗口的计数衰减到足够容纳n个请求所需时间
+func retryAfter(prev, curr, n, limit int, elapsed, window time.Duration) time.Duration {
+	rest := limit - curr - n
+	if rest < 0 || prev == 0 {
+		// 当前窗口已满,只能等到下个窗口,且下个窗口也需要按比例衰减
+		d := window - elapsed
+		if curr > 0 {
+			if f := 1 - float64(limit-n)/float64(curr); f > 0 {
+				d += time.Duration(f * float64(window))
+			}
+		}
+		return d
+	}
+
+	weight := 1 - float64(rest)/float64(prev)
+	d := time.Duration(weight*float64(window)) - elapsed
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+func remaining(limit int, count float64) int {
+	r := limit - int(count+0.999999)
+	if r < 0 {
+		return 0
+	}
+	return r
+}
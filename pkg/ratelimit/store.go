@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foredata/nova/errorx"
+	"github.com/foredata/nova/pkg/xid"
+	"github.com/foredata/nova/store"
+)
+
+// NewStoreSlidingWindow 基于store.Store的分布式滑动窗口限流
+//	每个窗口对应一个计数key,通过Incr原子累加,并设置2倍窗口的TTL自动过期,
+//	超出限额时通过Decr回滚本次计数
+func NewStoreSlidingWindow(s store.Store, prefix string, limit int, window time.Duration) Limiter {
+	return &storeWindow{store: s, prefix: prefix, limit: limit, window: window}
+}
+
+type storeWindow struct {
+	store  store.Store
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+func (w *storeWindow) Allow(ctx context.Context, key string, n int) (*Result, error) {
+	if n > w.limit {
+		return nil, ErrExceedsBurst
+	}
+
+	now := Now()
+	start := now.Truncate(w.window)
+	idx := start.UnixNano() / int64(w.window)
+	currKey := fmt.Sprintf("%s%s:%d", w.prefix, key, idx)
+	prevKey := fmt.Sprintf("%s%s:%d", w.prefix, key, idx-1)
+
+	// 先以NX方式创建计数并设置TTL,Incr不会修改TTL
+	if _, _, err := w.store.Put(ctx, currKey, []byte("0"), store.WithNX(), store.WithTTL(2*w.window)); err != nil {
+		return nil, err
+	}
+
+	if err := w.store.Incr(ctx, currKey, int64(n)); err != nil {
+		return nil, err
+	}
+
+	curr, err := getInt(ctx, w.store, currKey)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := getInt(ctx, w.store, prevKey)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := now.Sub(start)
+	res := &Result{Limit: w.limit}
+	count := estimate(int(prev), int(curr), elapsed, w.window)
+	if count > float64(w.limit) {
+		// 回滚本次计数
+		if err := w.store.Decr(ctx, currKey, int64(n)); err != nil {
+			return nil, err
+		}
+		res.RetryAfter = retryAfter(int(prev), int(curr)-n, n, w.limit, elapsed, w.window)
+		res.Remaining = 0
+		return res, nil
+	}
+
+	res.Allowed = true
+	res.Remaining = remaining(w.limit, count)
+	return res, nil
+}
+
+// NewStoreTokenBucket 基于store.Store的分布式令牌桶
+//	状态保存为"tokens:unixnano",读写期间通过Put NX+TTL获取短期锁保证原子性,
+//	锁的TTL用于防止持有者异常退出导致死锁,锁的value为随机token,释放时仅删除自己持有的锁,
+//	持有时间超过锁TTL时放弃写入,避免覆盖其他持有者的状态
+func NewStoreTokenBucket(s store.Store, prefix string, rate float64, burst int) Limiter {
+	ttl := durationOf(float64(burst)/rate) * 2
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+
+	return &storeBucket{
+		store:    s,
+		prefix:   prefix,
+		rate:     rate,
+		burst:    burst,
+		ttl:      ttl,
+		lockTTL:  time.Second,
+		retries:  5,
+		interval: 5 * time.Millisecond,
+	}
+}
+
+type storeBucket struct {
+	store    store.Store
+	prefix   string
+	rate     float64
+	burst    int
+	ttl      time.Duration // 状态过期时间,过期后等同于桶满
+	lockTTL  time.Duration // 锁过期时间
+	retries  int           // 获取锁重试次数
+	interval time.Duration // 获取锁重试间隔
+}
+
+func (b *storeBucket) Allow(ctx context.Context, key string, n int) (*Result, error) {
+	if n > b.burst {
+		return nil, ErrExceedsBurst
+	}
+
+	stateKey := b.prefix + key
+	lockKey := stateKey + ":lock"
+	token := xid.New().String()
+	if err := b.lock(ctx, lockKey, token); err != nil {
+		return nil, err
+	}
+	defer b.unlock(ctx, lockKey, token)
+
+	deadline := time.Now().Add(b.lockTTL)
+	now := Now()
+	s := &tokenState{tokens: float64(b.burst), last: now}
+	data, err := b.store.Get(ctx, stateKey)
+	switch err {
+	case nil:
+		if err := decodeTokenState(data, s); err != nil {
+			return nil, err
+		}
+	case errorx.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	res := &Result{Limit: b.burst}
+	takeToken(s, now, b.rate, b.burst, n, res)
+	if time.Now().After(deadline) {
+		// 锁可能已经过期并被其他请求获取
+		return nil, ErrStoreBusy
+	}
+	if _, _, err := b.store.Put(ctx, stateKey, encodeTokenState(s), store.WithTTL(b.ttl)); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (b *storeBucket) lock(ctx context.Context, key string, token string) error {
+	for i := 0; i <= b.retries; i++ {
+		ok, _, err := b.store.Put(ctx, key, []byte(token), store.WithNX(), store.WithTTL(b.lockTTL))
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.interval):
+		}
+	}
+
+	return ErrStoreBusy
+}
+
+// unlock 仅当锁仍由token持有时删除,锁已过期并被其他请求获取时不能删除
+func (b *storeBucket) unlock(ctx context.Context, key string, token string) {
+	data, err := b.store.Get(ctx, key)
+	if err == nil && string(data) == token {
+		_ = b.store.Delete(ctx, key)
+	}
+}
+
+func encodeTokenState(s *tokenState) []byte {
+	return []byte(strconv.FormatFloat(s.tokens, 'f', -1, 64) + ":" + strconv.FormatInt(s.last.UnixNano(), 10))
+}
+
+func decodeTokenState(data []byte, s *tokenState) error {
+	str := string(data)
+	idx := strings.IndexByte(str, ':')
+	if idx == -1 {
+		return fmt.Errorf("ratelimit: invalid state %s", str)
+	}
+
+	tokens, err := strconv.ParseFloat(str[:idx], 64)
+	if err != nil {
+		return err
+	}
+	last, err := strconv.ParseInt(str[idx+1:], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	s.tokens = tokens
+	s.last = time.Unix(0, last)
+	return nil
+}
+
+func getInt(ctx context.Context, s store.Store, key string) (int64, error) {
+	data, err := s.Get(ctx, key)
+	if err == errorx.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(data), 10, 64)
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// NewTokenBucket 创建令牌桶,rate为每秒产生的令牌数,burst为桶容量,允许一定突发
+func NewTokenBucket(rate float64, burst int) Limiter {
+	return &tokenBucket{rate: rate, burst: burst, states: newKeyedState(0)}
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  int
+	states *keyedState
+}
+
+type tokenState struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) Allow(ctx context.Context, key string, n int) (*Result, error) {
+	if n > b.burst {
+		return nil, ErrExceedsBurst
+	}
+
+	now := Now()
+	res := &Result{Limit: b.burst}
+	b.states.do(key, now, func() interface{} {
+		return &tokenState{tokens: float64(b.burst), last: now}
+	}, func(x interface{}) {
+		s := x.(*tokenState)
+		takeToken(s, now, b.rate, b.burst, n, res)
+	})
+
+	return res, nil
+}
+
+// takeToken 补充令牌并尝试获取n个,结果写入res
+func takeToken(s *tokenState, now time.Time, rate float64, burst int, n int, res *Result) {
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens = math.Min(float64(burst), s.tokens+elapsed.Seconds()*rate)
+	}
+	s.last = now
+
+	if s.tokens >= float64(n) {
+		s.tokens -= float64(n)
+		res.Allowed = true
+	} else if rate > 0 {
+		res.RetryAfter = durationOf((float64(n) - s.tokens) / rate)
+	}
+
+	res.Remaining = int(s.tokens)
+}
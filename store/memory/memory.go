@@ -0,0 +1,198 @@
+// Package memory 基于内存的store.Store实现,用于开发测试及单机场景
+package memory
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/foredata/nova/errorx"
+	"github.com/foredata/nova/store"
+)
+
+// New 创建内存store
+func New() store.Store {
+	return &memStore{items: make(map[string]*item)}
+}
+
+type item struct {
+	value  []byte
+	expire time.Time // 零值表示不过期
+}
+
+func (i *item) expired(now time.Time) bool {
+	return !i.expire.IsZero() && !now.Before(i.expire)
+}
+
+type memStore struct {
+	mux   sync.Mutex
+	items map[string]*item
+}
+
+func (s *memStore) Name() string {
+	return "memory"
+}
+
+// get 查询未过期数据,需要在锁内调用
+func (s *memStore) get(key string, now time.Time) *item {
+	it := s.items[key]
+	if it == nil {
+		return nil
+	}
+	if it.expired(now) {
+		delete(s.items, key)
+		return nil
+	}
+
+	return it
+}
+
+func (s *memStore) Incr(ctx context.Context, key string, delta int64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	it := s.get(key, time.Now())
+	if it == nil {
+		it = &item{}
+		s.items[key] = it
+	}
+
+	var v int64
+	if len(it.value) > 0 {
+		n, err := strconv.ParseInt(string(it.value), 10, 64)
+		if err != nil {
+			return err
+		}
+		v = n
+	}
+
+	it.value = []byte(strconv.FormatInt(v+delta, 10))
+	return nil
+}
+
+func (s *memStore) Decr(ctx context.Context, key string, delta int64) error {
+	return s.Incr(ctx, key, -delta)
+}
+
+func (s *memStore) Put(ctx context.Context, key string, value []byte, opts ...store.PutOption) (bool, []byte, error) {
+	o := store.PutOptions{}
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := time.Now()
+	old := s.get(key, now)
+	switch o.Mode {
+	case store.PutModeNX:
+		if old != nil {
+			return false, s.prev(old, o.Get), nil
+		}
+	case store.PutModeXX:
+		if old == nil {
+			return false, nil, nil
+		}
+	}
+
+	it := &item{value: append([]byte(nil), value...)}
+	if o.TTL > 0 {
+		it.expire = now.Add(o.TTL)
+	}
+	s.items[key] = it
+
+	return true, s.prev(old, o.Get), nil
+}
+
+func (s *memStore) prev(old *item, get bool) []byte {
+	if !get || old == nil {
+		return nil
+	}
+
+	return append([]byte(nil), old.value...)
+}
+
+func (s *memStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	it := s.get(key, time.Now())
+	if it == nil {
+		return nil, errorx.ErrNotFound
+	}
+
+	return append([]byte(nil), it.value...), nil
+}
+
+func (s *memStore) List(ctx context.Context, pattern string) ([]*store.KVPair, error) {
+	_, res, err := s.Scan(ctx, pattern, 0, 0)
+	return res, err
+}
+
+func (s *memStore) Delete(ctx context.Context, key string) error {
+	s.mux.Lock()
+	delete(s.items, key)
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *memStore) Exists(ctx context.Context, key string) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.get(key, time.Now()) != nil, nil
+}
+
+// Ping 实现health.Pinger
+func (s *memStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Scan 按key排序后分页遍历,cursor为下次起始位置,返回0表示遍历结束,count<=0表示不限制
+func (s *memStore) Scan(ctx context.Context, pattern string, cursor int64, count int) (int64, []*store.KVPair, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := time.Now()
+	keys := make([]string, 0, len(s.items))
+	for k, it := range s.items {
+		if it.expired(now) {
+			delete(s.items, k)
+			continue
+		}
+		if pattern != "" {
+			if ok, err := path.Match(pattern, k); err != nil {
+				return 0, nil, err
+			} else if !ok {
+				continue
+			}
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if cursor < 0 || cursor >= int64(len(keys)) {
+		return 0, nil, nil
+	}
+	keys = keys[cursor:]
+	next := int64(0)
+	if count > 0 && len(keys) > count {
+		keys = keys[:count]
+		next = cursor + int64(count)
+	}
+
+	res := make([]*store.KVPair, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, &store.KVPair{Key: k, Value: append([]byte(nil), s.items[k].value...)})
+	}
+
+	return next, res, nil
+}
+
+func (s *memStore) Close() error {
+	return nil
+}
@@ -30,6 +30,8 @@ func (b *bucket) Front() *timer {
 }
 
 func (b *bucket) Push(t *timer) {
+	t.prev = b.tail
+	t.next = nil
 	if b.tail != nil {
 		b.tail.next = t
 		b.tail = t
@@ -41,12 +43,17 @@ func (b *bucket) Push(t *timer) {
 	t.list = b
 }
 
+// Remove 删除时需要同步修改head和tail,否则Stop后的timer仍会被当作过期timer执行
 func (b *bucket) Remove(t *timer) {
 	if t.prev != nil {
 		t.prev.next = t.next
+	} else {
+		b.head = t.next
 	}
 	if t.next != nil {
 		t.next.prev = t.prev
+	} else {
+		b.tail = t.prev
 	}
 
 	t.list = nil
@@ -63,6 +70,7 @@ func (b *bucket) merge(other *bucket) {
 
 	if b.tail != nil {
 		b.tail.next = other.head
+		other.head.prev = b.tail
 		b.tail = other.tail
 		b.size += other.size
 	} else {
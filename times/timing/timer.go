@@ -115,7 +115,7 @@ func newTimer(expired, interval int64, callback Callback, data interface{}, o *O
 	}
 }
 
-type status uint8
+type status uint32
 
 const (
 	statusIdle     status = iota // 空闲状态
@@ -149,13 +149,13 @@ func (t *timer) ID() ID {
 func (t *timer) Stop() {
 	t.mux.Lock()
 
-	if t.status == statusTiming {
+	if t.getStatus() == statusTiming {
 		if t.engine.Stop(t) {
 			t.Recyle()
 		} else {
 			t.setStatus(statusStopping)
 		}
-	} else if t.status == statusExec {
+	} else if t.getStatus() == statusExec {
 		t.setStatus(statusStopping)
 	}
 
@@ -166,14 +166,14 @@ func (t *timer) invoke() {
 	t.mux.Lock()
 
 	canRecyle := false
-	if t.status == statusExec {
+	if t.getStatus() == statusExec {
 		t.callback(t.data)
 		if t.interval != 0 {
 			t.engine.Start(t)
 		} else {
 			canRecyle = true
 		}
-	} else if t.status == statusStopping {
+	} else if t.getStatus() == statusStopping {
 		canRecyle = true
 	}
 
@@ -186,8 +186,13 @@ func (t *timer) invoke() {
 	t.mux.Unlock()
 }
 
+// setStatus engine在持有自身锁时修改状态,Stop在持有timer锁时读取,需要使用原子操作
 func (t *timer) setStatus(s status) {
-	t.status = s
+	atomic.StoreUint32((*uint32)(&t.status), uint32(s))
+}
+
+func (t *timer) getStatus() status {
+	return status(atomic.LoadUint32((*uint32)(&t.status)))
 }
 
 func (t *timer) Recyle() {
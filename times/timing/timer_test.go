@@ -2,6 +2,7 @@ package timing_test
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,3 +28,34 @@ func TestTimer(t *testing.T) {
 
 	time.Sleep(time.Second * 10)
 }
+
+// TestStop 停止的timer不能被执行,同一个桶中的其他timer只执行一次
+func TestStop(t *testing.T) {
+	var mux sync.Mutex
+	fired := make(map[int]int)
+	ids := make([]timing.ID, 10)
+	for i := range ids {
+		ids[i] = timing.NewDelayer(50*time.Millisecond, func(data interface{}) {
+			mux.Lock()
+			fired[data.(int)]++
+			mux.Unlock()
+		}, i)
+	}
+	// 停止首尾及中间的timer
+	for _, i := range []int{0, 5, 9} {
+		timing.Stop(ids[i])
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	mux.Lock()
+	defer mux.Unlock()
+	for i := range ids {
+		expect := 1
+		if i == 0 || i == 5 || i == 9 {
+			expect = 0
+		}
+		if fired[i] != expect {
+			t.Errorf("timer %d fired %d times", i, fired[i])
+		}
+	}
+}
@@ -1,5 +1,47 @@
 package main
 
+import (
+	"log"
+
+	"github.com/foredata/nova/netx/gateway"
+	"github.com/foredata/nova/netx/server"
+	"github.com/foredata/nova/netx/server/middleware/auth"
+	"github.com/foredata/nova/netx/server/middleware/ratelimit"
+	"github.com/foredata/nova/netx/server/middleware/tracing"
+	rl "github.com/foredata/nova/pkg/ratelimit"
+	"github.com/foredata/nova/zdemo/micro/common"
+)
+
+// 转发到zdemo/micro/server,anonymous的路由不需要认证
+const rules = `[
+	{"method": "GET", "path": "/api/ping", "service": "127.0.0.1:8888", "uri": "onPing", "request": "common.PingRequest", "response": "common.PingResponse", "codec": "json", "timeout": "3s", "metadata": {"auth.anonymous": "true"}},
+	{"method": "POST", "path": "/api/login", "service": "127.0.0.1:8888", "uri": "onLogin"}
+]`
+
 func main() {
+	gateway.RegisterType("", &common.PingRequest{})
+	gateway.RegisterType("", &common.PingResponse{})
+
+	keys := auth.NewStaticKeyStore(map[string]*auth.Principal{
+		"demo-key": {ID: "demo"},
+	})
+
+	svr := server.New(server.WithAddr(":8080"))
+	svr.Use(tracing.New())
+
+	gw := gateway.New(svr, gateway.WithMiddlewares(
+		auth.New(auth.WithAuthenticator(auth.NewAPIKey(keys))),
+		ratelimit.New(rl.NewTokenBucket(100, 200), ratelimit.WithKey(ratelimit.ByIP(false))),
+	))
+
+	list, err := gateway.ParseRules([]byte(rules))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := gw.Add(list...); err != nil {
+		log.Fatal(err)
+	}
 
+	svr.Run()
 }